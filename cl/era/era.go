@@ -0,0 +1,310 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era reads and writes beacon chain `.era` files: era `N` contains blocks of slots
+// `[(N-1)*SlotsPerHistoricalRoot, N*SlotsPerHistoricalRoot)` and the state at slot `N*SlotsPerHistoricalRoot`.
+package era
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/cl/phase1/core/state"
+	e2 "github.com/erigontech/erigon/core/era"
+)
+
+// era file: Version | CompressedSignedBeaconBlock* | CompressedBeaconState | SlotIndex(blocks)? | SlotIndex(state)
+const (
+	TypeCompressedSignedBeaconBlock uint16 = 0x01
+	TypeCompressedBeaconState       uint16 = 0x02
+	TypeSlotIndex                   uint16 = 0x3269 // "i2"
+
+	Ext = ".era"
+)
+
+// Filename - `<network>-<era>-<first 4 bytes of historical root>.era`
+func Filename(network string, era uint64, root libcommon.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x%s", network, era, root[:4], Ext)
+}
+
+// List - sorted era files of `dir`
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), Ext) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Builder - writes era file. Blocks must be added in slot order, empty slots are skipped.
+type Builder struct {
+	cfg       *clparams.BeaconChainConfig
+	w         *e2.E2Writer
+	written   int64
+	startSlot uint64
+	offsets   []int64 // by slot, 0 for empty slot
+}
+
+func NewBuilder(w io.Writer, cfg *clparams.BeaconChainConfig, startSlot uint64) *Builder {
+	return &Builder{cfg: cfg, w: e2.NewE2Writer(w), startSlot: startSlot}
+}
+
+func (b *Builder) write(typ uint16, value []byte) error {
+	if b.written == 0 {
+		n, err := b.w.Write(e2.TypeVersion, nil)
+		if err != nil {
+			return err
+		}
+		b.written += int64(n)
+	}
+	n, err := b.w.Write(typ, value)
+	b.written += int64(n)
+	return err
+}
+
+func (b *Builder) writeCompressed(typ uint16, value []byte) error {
+	c, err := e2.Compress(value)
+	if err != nil {
+		return err
+	}
+	return b.write(typ, c)
+}
+
+func (b *Builder) AddBlock(block *cltypes.SignedBeaconBlock) error {
+	slot := block.Block.Slot
+	next := b.startSlot + uint64(len(b.offsets))
+	if slot < next {
+		return fmt.Errorf("era: block of slot %d is out of order, expected slot >= %d", slot, next)
+	}
+	if slot >= b.startSlot+b.cfg.SlotsPerHistoricalRoot {
+		return fmt.Errorf("era: block of slot %d is out of era starting at slot %d", slot, b.startSlot)
+	}
+	enc, err := block.EncodeSSZ(nil)
+	if err != nil {
+		return err
+	}
+	for ; next < slot; next++ {
+		b.offsets = append(b.offsets, 0)
+	}
+	b.offsets = append(b.offsets, b.written)
+	if b.written == 0 { // version entry will be written before the block
+		b.offsets[len(b.offsets)-1] = e2.HeaderSize
+	}
+	return b.writeCompressed(TypeCompressedSignedBeaconBlock, enc)
+}
+
+// Finalize - writes state and indices. State must be at slot which follows all blocks of the era.
+func (b *Builder) Finalize(s *state.CachingBeaconState) error {
+	stateSlot := s.Slot()
+	if stateSlot > 0 && stateSlot <= b.startSlot {
+		return fmt.Errorf("era: state of slot %d doesn't follow era starting at slot %d", stateSlot, b.startSlot)
+	}
+	if stateSlot < b.startSlot+uint64(len(b.offsets)) {
+		return fmt.Errorf("era: state of slot %d is older than blocks", stateSlot)
+	}
+	enc, err := s.EncodeSSZ(nil)
+	if err != nil {
+		return err
+	}
+	stateOffset := b.written
+	if stateOffset == 0 {
+		stateOffset = e2.HeaderSize
+	}
+	if err := b.writeCompressed(TypeCompressedBeaconState, enc); err != nil {
+		return err
+	}
+	if stateSlot > 0 { // genesis era has no blocks
+		for next := b.startSlot + uint64(len(b.offsets)); next < stateSlot; next++ {
+			b.offsets = append(b.offsets, 0)
+		}
+		if err := b.write(TypeSlotIndex, e2.EncodeIndex(b.startSlot, b.offsets, b.written)); err != nil {
+			return err
+		}
+	}
+	return b.write(TypeSlotIndex, e2.EncodeIndex(stateSlot, []int64{stateOffset}, b.written))
+}
+
+// Era - reader of era file
+type Era struct {
+	cfg    *clparams.BeaconChainConfig
+	r      *e2.E2Reader
+	closer io.Closer
+
+	startSlot   uint64
+	blocks      []int64 // by slot, 0 for empty slot
+	stateSlot   uint64
+	stateOffset int64
+}
+
+func Open(path string, cfg *clparams.BeaconChainConfig) (*Era, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e, err := From(f, st.Size(), cfg)
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	e.closer = f
+	return e, nil
+}
+
+func From(f io.ReaderAt, size int64, cfg *clparams.BeaconChainConfig) (*Era, error) {
+	e := &Era{cfg: cfg, r: e2.NewE2Reader(f)}
+	if _, _, err := e.r.ReadTypeAt(0, e2.TypeVersion); err != nil {
+		return nil, err
+	}
+	stateSlot, offsets, stateIndex, err := e.r.ReadIndex(size, TypeSlotIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) != 1 {
+		return nil, fmt.Errorf("era: state index must have 1 record, got %d", len(offsets))
+	}
+	e.stateSlot, e.stateOffset = stateSlot, offsets[0]
+	if stateSlot == 0 {
+		return e, nil
+	}
+	// blocks index is right before state index
+	if e.startSlot, e.blocks, _, err = e.r.ReadIndex(stateIndex, TypeSlotIndex); err != nil {
+		return nil, err
+	}
+	if uint64(len(e.blocks)) > cfg.SlotsPerHistoricalRoot || e.startSlot+uint64(len(e.blocks)) != stateSlot {
+		return nil, fmt.Errorf("era: blocks index [%d, %d) doesn't match state slot %d", e.startSlot, e.startSlot+uint64(len(e.blocks)), stateSlot)
+	}
+	return e, nil
+}
+
+func (e *Era) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+// StartSlot - first slot of the era
+func (e *Era) StartSlot() uint64 { return e.startSlot }
+
+// StateSlot - slot of the state, also end (not included) of blocks slots
+func (e *Era) StateSlot() uint64 { return e.stateSlot }
+
+func (e *Era) readCompressed(off int64, typ uint16) ([]byte, error) {
+	v, _, err := e.r.ReadTypeAt(off, typ)
+	if err != nil {
+		return nil, err
+	}
+	return e2.Decompress(v)
+}
+
+// Block - block of `slot`, nil if slot is empty
+func (e *Era) Block(slot uint64) (*cltypes.SignedBeaconBlock, error) {
+	if slot < e.startSlot || slot >= e.stateSlot {
+		return nil, fmt.Errorf("era: slot %d is out of range [%d, %d)", slot, e.startSlot, e.stateSlot)
+	}
+	off := e.blocks[slot-e.startSlot]
+	if off == 0 {
+		return nil, nil
+	}
+	v, err := e.readCompressed(off, TypeCompressedSignedBeaconBlock)
+	if err != nil {
+		return nil, err
+	}
+	block := cltypes.NewSignedBeaconBlock(e.cfg)
+	if err := block.DecodeSSZ(v, int(e.cfg.GetCurrentStateVersion(slot/e.cfg.SlotsPerEpoch))); err != nil {
+		return nil, fmt.Errorf("era: block of slot %d: %w", slot, err)
+	}
+	if block.Block.Slot != slot {
+		return nil, fmt.Errorf("era: block indexed by slot %d has slot %d", slot, block.Block.Slot)
+	}
+	return block, nil
+}
+
+func (e *Era) State() (*state.CachingBeaconState, error) {
+	v, err := e.readCompressed(e.stateOffset, TypeCompressedBeaconState)
+	if err != nil {
+		return nil, err
+	}
+	s := state.New(e.cfg)
+	if err := s.DecodeSSZ(v, int(e.cfg.GetCurrentStateVersion(e.stateSlot/e.cfg.SlotsPerEpoch))); err != nil {
+		return nil, fmt.Errorf("era: state of slot %d: %w", e.stateSlot, err)
+	}
+	if s.Slot() != e.stateSlot {
+		return nil, fmt.Errorf("era: state indexed by slot %d has slot %d", e.stateSlot, s.Slot())
+	}
+	return s, nil
+}
+
+// Verify - checks that blocks are the ones which state `s` (State of this era) remembers in block roots,
+// and empty slots are empty there. `f` is called for every block.
+func (e *Era) Verify(s *state.CachingBeaconState, f func(block *cltypes.SignedBeaconBlock) error) error {
+	if s.Slot() != e.stateSlot {
+		return fmt.Errorf("era: verify: state of slot %d, expected %d", s.Slot(), e.stateSlot)
+	}
+	var prev libcommon.Hash
+	for slot := e.startSlot; slot < e.stateSlot; slot++ {
+		expected, err := s.GetBlockRootAtSlot(slot)
+		if err != nil {
+			return err
+		}
+		block, err := e.Block(slot)
+		if err != nil {
+			return err
+		}
+		if block == nil {
+			if prev != (libcommon.Hash{}) && expected != prev {
+				return fmt.Errorf("era: slot %d is empty, but state has block %x there", slot, expected)
+			}
+			continue
+		}
+		root, err := block.Block.HashSSZ()
+		if err != nil {
+			return err
+		}
+		if root != expected {
+			return fmt.Errorf("era: block of slot %d: root mismatch: %x != %x", slot, root, expected)
+		}
+		if prev != (libcommon.Hash{}) && block.Block.ParentRoot != prev {
+			return fmt.Errorf("era: block of slot %d: parent root mismatch", slot)
+		}
+		prev = root
+		if f != nil {
+			if err := f(block); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package era_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon/cl/antiquary/tests"
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/cl/era"
+)
+
+func TestEraRoundTrip(t *testing.T) {
+	blocks, _, postState := tests.GetBellatrixRandom()
+	// test data is bellatrix since genesis
+	cfg := clparams.MainnetBeaconConfig
+	cfg.AltairForkEpoch, cfg.BellatrixForkEpoch = 0, 0
+
+	var buf bytes.Buffer
+	w := era.NewBuilder(&buf, &cfg, blocks[0].Block.Slot)
+	added := map[uint64]*cltypes.SignedBeaconBlock{}
+	for _, block := range blocks {
+		if block.Block.Slot >= postState.Slot() {
+			break
+		}
+		require.NoError(t, w.AddBlock(block))
+		added[block.Block.Slot] = block
+	}
+	require.NoError(t, w.Finalize(postState))
+
+	e, err := era.From(bytes.NewReader(buf.Bytes()), int64(buf.Len()), &cfg)
+	require.NoError(t, err)
+	require.Equal(t, blocks[0].Block.Slot, e.StartSlot())
+	require.Equal(t, postState.Slot(), e.StateSlot())
+
+	s, err := e.State()
+	require.NoError(t, err)
+	expectedRoot, err := postState.HashSSZ()
+	require.NoError(t, err)
+	root, err := s.HashSSZ()
+	require.NoError(t, err)
+	require.Equal(t, expectedRoot, root)
+
+	for slot := e.StartSlot(); slot < e.StateSlot(); slot++ {
+		block, err := e.Block(slot)
+		require.NoError(t, err)
+		expected, ok := added[slot]
+		if !ok {
+			require.Nil(t, block)
+			continue
+		}
+		expectedRoot, err := expected.HashSSZ()
+		require.NoError(t, err)
+		root, err := block.HashSSZ()
+		require.NoError(t, err)
+		require.Equal(t, expectedRoot, root)
+	}
+
+	verified := 0
+	require.NoError(t, e.Verify(s, func(*cltypes.SignedBeaconBlock) error { verified++; return nil }))
+	require.Equal(t, len(added), verified)
+
+	// state of another chain
+	_, _, phase0State := tests.GetPhase0Random()
+	require.Error(t, e.Verify(phase0State, nil))
+}
@@ -0,0 +1,132 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/evm/internal/statefuzz"
+)
+
+var (
+	FuzzSeedFlag = cli.Int64Flag{
+		Name:  "seed",
+		Usage: "seed of the first case, case i has seed+i",
+	}
+	FuzzIterationsFlag = cli.IntFlag{
+		Name:  "iterations",
+		Usage: "amount of cases for every fork, 0 - until interrupted",
+		Value: 1000,
+	}
+	FuzzForkFlag = cli.StringSliceFlag{
+		Name:  "fork",
+		Usage: "forks of cases",
+		Value: cli.NewStringSlice(statefuzz.Forks...),
+	}
+	FuzzReferenceFlag = cli.StringFlag{
+		Name:  "reference",
+		Usage: "t8n binary of other implementation (e.g. evm of go-ethereum) to cross-check post-state roots",
+	}
+	FuzzOutputFlag = cli.StringFlag{
+		Name:  "output",
+		Usage: "directory for state tests of failing cases",
+		Value: ".",
+	}
+)
+
+var stateFuzzCommand = cli.Command{
+	Action: stateFuzzCmd,
+	Name:   "fuzz",
+	Usage:  "generates state tests, executes them by t8n and cross-checks post-state",
+	Flags: []cli.Flag{
+		&FuzzSeedFlag,
+		&FuzzIterationsFlag,
+		&FuzzForkFlag,
+		&FuzzReferenceFlag,
+		&FuzzOutputFlag,
+	},
+	Description: `
+Every case is pre-state of random contracts and one transaction. It's executed by t8n, then gas accounting, refunds,
+intrinsic gas of access lists and conservation of ether are checked, and post-state root is compared with the state
+test runner and, with --reference, with t8n of other implementation. Failing cases are written to --output as state
+tests fuzz_<fork>_<seed>.json, which can be run by 'evm statetest'.`,
+}
+
+func stateFuzzCmd(ctx *cli.Context) error {
+	log.Root().SetHandler(log.LvlFilterHandler(log.LvlWarn, log.StderrHandler))
+
+	forks := ctx.StringSlice(FuzzForkFlag.Name)
+	for _, fork := range forks {
+		if !slices.Contains(statefuzz.Forks, fork) {
+			return fmt.Errorf("fork %s isn't supported, supported forks: %v", fork, statefuzz.Forks)
+		}
+	}
+	outDir := ctx.String(FuzzOutputFlag.Name)
+	if err := os.MkdirAll(outDir, 0755); err != nil {
+		return err
+	}
+	cfg := statefuzz.Config{Reference: ctx.String(FuzzReferenceFlag.Name)}
+
+	seed, iterations := ctx.Int64(FuzzSeedFlag.Name), ctx.Int(FuzzIterationsFlag.Name)
+	var cases, failures int
+	for i := 0; iterations == 0 || i < iterations; i++ {
+		for _, fork := range forks {
+			if err := ctx.Context.Err(); err != nil {
+				return err
+			}
+			c, err := statefuzz.Generate(seed+int64(i), fork)
+			if err != nil {
+				return err
+			}
+			cases++
+			err = statefuzz.Check(ctx.Context, c, cfg)
+			var failure *statefuzz.Failure
+			if !errors.As(err, &failure) {
+				if err != nil {
+					return err
+				}
+				continue
+			}
+			failures++
+			fixture, err := failure.Fixture()
+			if err != nil {
+				return err
+			}
+			path := filepath.Join(outDir, c.Name+".json")
+			if err = os.WriteFile(path, fixture, 0644); err != nil {
+				return err
+			}
+			fmt.Fprintf(os.Stderr, "%s, state test: %s\n", failure, path)
+		}
+		if (i+1)%100 == 0 {
+			fmt.Fprintf(os.Stderr, "cases: %d, failures: %d\n", cases, failures)
+		}
+	}
+	fmt.Fprintf(os.Stderr, "cases: %d, failures: %d\n", cases, failures)
+	if failures > 0 {
+		return fmt.Errorf("%d of %d cases failed", failures, cases)
+	}
+	return nil
+}
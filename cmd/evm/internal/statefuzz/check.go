@@ -0,0 +1,307 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package statefuzz
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"os"
+	"os/exec"
+	"path/filepath"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/kv/temporal/temporaltest"
+
+	"github.com/erigontech/erigon/cmd/evm/internal/t8ntool"
+	"github.com/erigontech/erigon/common/math"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/tests"
+)
+
+// Config - optional parts of checks
+type Config struct {
+	Reference string // t8n binary of reference implementation, called as `<Reference> t8n --input.alloc ...`; empty - no reference
+	TmpDir    string // for databases and files of reference t8n; empty - default directory for temporary files
+}
+
+// Failure - case which violates invariant of execution or has different post-state in other executor
+type Failure struct {
+	Case  *Case
+	Check string // e.g. "refund", "state test", "reference"
+	Err   error
+
+	// post-state of t8n
+	Root, Logs libcommon.Hash
+}
+
+func (f *Failure) Error() string {
+	return fmt.Sprintf("%s: %s: %v", f.Case.Name, f.Check, f.Err)
+}
+
+func (f *Failure) Unwrap() error {
+	return f.Err
+}
+
+// Fixture - state test of the failed case, it expects post-state of t8n
+func (f *Failure) Fixture() ([]byte, error) {
+	return Fixture(f.Case, f.Root, f.Logs)
+}
+
+// Check - executes case by t8n, checks invariants of execution and cross-checks post-state with state test runner
+// and reference t8n. Violations are returned as *Failure, other errors are failures of fuzzer itself.
+func Check(ctx context.Context, c *Case, cfg Config) error {
+	config, _, err := tests.GetChainConfig(c.Fork)
+	if err != nil {
+		return err
+	}
+	tmpDir, err := os.MkdirTemp(cfg.TmpDir, "statefuzz")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	tracer := &gasTracer{}
+	prestate := c.Env // Transition modifies env
+	vmConfig := vm.Config{Debug: true, StatelessExec: true}
+	getTracer := func(int, libcommon.Hash) (vm.EVMLogger, error) { return tracer, nil }
+	result, alloc, err := t8ntool.Transition(&prestate, types.Transactions{c.Tx}, config, vmConfig, getTracer, datadir.New(filepath.Join(tmpDir, "t8n")))
+	if err != nil {
+		return fmt.Errorf("%s: t8n: %w", c.Name, err)
+	}
+	fail := func(check string, format string, args ...any) error {
+		return &Failure{Case: c, Check: check, Err: fmt.Errorf(format, args...), Root: result.StateRoot, Logs: result.LogsHash}
+	}
+
+	if len(result.Rejected) > 0 {
+		return fail("rejected", "%s", result.Rejected[0].Err)
+	}
+	if len(result.Receipts) != 1 {
+		return fail("receipts", "%d receipts of 1 transaction", len(result.Receipts))
+	}
+	receipt := result.Receipts[0]
+	if receipt.GasUsed != uint64(result.GasUsed) || receipt.CumulativeGasUsed != receipt.GasUsed {
+		return fail("gas used", "receipt %d, cumulative %d, block %d", receipt.GasUsed, receipt.CumulativeGasUsed, result.GasUsed)
+	}
+	if check, err := checkGas(c, receipt.GasUsed, tracer, config.IsShanghai(c.Env.Env.Timestamp)); err != nil {
+		return fail(check, "%w", err)
+	}
+	if err := checkBalances(c.Env.Pre, alloc, receipt.GasUsed, c.Env.Env.BaseFee); err != nil {
+		return fail("balances", "%w", err)
+	}
+
+	fixture, err := Fixture(c, result.StateRoot, result.LogsHash)
+	if err != nil {
+		return err
+	}
+	if err := runStateTest(ctx, c, fixture, datadir.New(filepath.Join(tmpDir, "statetest"))); err != nil {
+		return fail("state test", "%w", err)
+	}
+
+	if cfg.Reference == "" {
+		return nil
+	}
+	ref, err := runReference(ctx, cfg.Reference, filepath.Join(tmpDir, "reference"), c)
+	if err != nil {
+		return fmt.Errorf("%s: reference t8n: %w", c.Name, err)
+	}
+	if ref.StateRoot != result.StateRoot {
+		return fail("reference", "state root %x, reference %x", result.StateRoot, ref.StateRoot)
+	}
+	if ref.GasUsed != result.GasUsed {
+		return fail("reference", "gas used %d, reference %d", result.GasUsed, ref.GasUsed)
+	}
+	return nil
+}
+
+// checkGas - gas accounting of the transaction as tracer observed it: intrinsic gas, including access list, is the
+// difference of gas limit and gas of the top call, refund is capped by 1/5 of gas used (EIP-3529)
+func checkGas(c *Case, gasUsed uint64, tracer *gasTracer, shanghai bool) (check string, err error) {
+	gasLimit := c.Tx.GetGas()
+	if tracer.txs != 1 || !tracer.ended {
+		return "tracer", fmt.Errorf("%d transactions traced, top call ended: %t", tracer.txs, tracer.ended)
+	}
+	if tracer.gasLimit != gasLimit {
+		return "gas limit", fmt.Errorf("transaction %d, traced %d", gasLimit, tracer.gasLimit)
+	}
+	if gasUsed > gasLimit {
+		return "gas used", fmt.Errorf("%d above gas limit %d", gasUsed, gasLimit)
+	}
+	intrinsic := intrinsicGas(c.Tx.GetData(), c.Tx.GetAccessList(), c.Tx.GetTo() == nil, shanghai)
+	if charged := gasLimit - tracer.callGas; charged != intrinsic {
+		return "intrinsic gas", fmt.Errorf("charged %d, expected %d", charged, intrinsic)
+	}
+	beforeRefund := intrinsic + tracer.execGas
+	if beforeRefund > gasLimit {
+		return "gas used", fmt.Errorf("%d by execution above gas limit %d", beforeRefund, gasLimit)
+	}
+	if final := gasLimit - tracer.restGas; final != gasUsed {
+		return "gas used", fmt.Errorf("receipt %d, traced %d", gasUsed, final)
+	}
+	if beforeRefund < gasUsed {
+		return "refund", fmt.Errorf("gas used %d above gas used before refund %d", gasUsed, beforeRefund)
+	}
+	if refund, expected := beforeRefund-gasUsed, min(beforeRefund/5, tracer.refund); refund != expected {
+		return "refund", fmt.Errorf("refunded %d, expected %d: counter %d, gas used before refund %d", refund, expected, tracer.refund, beforeRefund)
+	}
+	return "", nil
+}
+
+// checkBalances - ether is conserved: it only moves between accounts, except base fee which is burnt
+func checkBalances(pre, post map[libcommon.Address]types.GenesisAccount, gasUsed uint64, baseFee *big.Int) error {
+	sum := func(alloc map[libcommon.Address]types.GenesisAccount) *big.Int {
+		total := new(big.Int)
+		for _, account := range alloc {
+			if account.Balance != nil {
+				total.Add(total, account.Balance)
+			}
+		}
+		return total
+	}
+	burnt := new(big.Int).Mul(baseFee, new(big.Int).SetUint64(gasUsed))
+	preTotal, postTotal := sum(pre), sum(post)
+	if expected := new(big.Int).Sub(preTotal, burnt); postTotal.Cmp(expected) != 0 {
+		return fmt.Errorf("total balance %d, expected %d: pre %d, burnt %d", postTotal, expected, preTotal, burnt)
+	}
+	return nil
+}
+
+// runStateTest - runs fixture by state test runner, which executes transaction without block
+func runStateTest(ctx context.Context, c *Case, fixture []byte, dirs datadir.Dirs) error {
+	var stateTests map[string]tests.StateTest
+	if err := json.Unmarshal(fixture, &stateTests); err != nil {
+		return err
+	}
+	test := stateTests[c.Name]
+
+	db, agg := temporaltest.NewTestDB(nil, dirs)
+	defer db.Close()
+	defer agg.Close()
+	tx, err := db.BeginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	_, _, err = test.Run(tx, tests.StateSubtest{Fork: c.Fork}, vm.Config{}, dirs)
+	return err
+}
+
+type referenceResult struct {
+	StateRoot libcommon.Hash      `json:"stateRoot"`
+	GasUsed   math.HexOrDecimal64 `json:"gasUsed"`
+}
+
+// runReference - executes case by t8n of reference implementation, it gets the same input files as evm t8n
+func runReference(ctx context.Context, binary, dir string, c *Case) (*referenceResult, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	env, err := json.Marshal(c.Env.Env)
+	if err != nil {
+		return nil, err
+	}
+	var envFields map[string]any
+	if err = json.Unmarshal(env, &envFields); err != nil {
+		return nil, err
+	}
+	if c.Env.Env.Withdrawals != nil { // empty withdrawals are omitted
+		envFields["withdrawals"] = c.Env.Env.Withdrawals
+	}
+	inputs := map[string]any{"alloc.json": c.Env.Pre, "env.json": envFields, "txs.json": types.Transactions{c.Tx}}
+	for name, input := range inputs {
+		b, err := json.Marshal(input)
+		if err != nil {
+			return nil, err
+		}
+		if err = os.WriteFile(filepath.Join(dir, name), b, 0644); err != nil {
+			return nil, err
+		}
+	}
+
+	cmd := exec.CommandContext(ctx, binary, "t8n",
+		"--input.alloc", filepath.Join(dir, "alloc.json"),
+		"--input.env", filepath.Join(dir, "env.json"),
+		"--input.txs", filepath.Join(dir, "txs.json"),
+		"--state.fork", c.Fork,
+		"--state.chainid", "1",
+		"--output.basedir", dir,
+		"--output.result", "result.json",
+		"--output.alloc", "alloc_out.json",
+	)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err = cmd.Run(); err != nil {
+		return nil, fmt.Errorf("%w: %s", err, stderr.String())
+	}
+	b, err := os.ReadFile(filepath.Join(dir, "result.json"))
+	if err != nil {
+		return nil, err
+	}
+	var result referenceResult
+	if err = json.Unmarshal(b, &result); err != nil {
+		return nil, fmt.Errorf("result.json: %w", err)
+	}
+	return &result, nil
+}
+
+// gasTracer - records gas of the transaction and of its top call
+type gasTracer struct {
+	txs      int
+	ended    bool
+	gasLimit uint64 // gas of the transaction
+	callGas  uint64 // gas of the top call, after intrinsic gas is charged
+	execGas  uint64 // used by the top call
+	refund   uint64 // refund counter when the top call ends
+	restGas  uint64 // returned to sender, after refund
+
+	env *vm.EVM
+}
+
+func (t *gasTracer) CaptureTxStart(gasLimit uint64) {
+	t.txs++
+	t.gasLimit = gasLimit
+}
+
+func (t *gasTracer) CaptureTxEnd(restGas uint64) {
+	t.restGas = restGas
+}
+
+func (t *gasTracer) CaptureStart(env *vm.EVM, from libcommon.Address, to libcommon.Address, precompile bool, create bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+	t.env, t.callGas = env, gas
+}
+
+func (t *gasTracer) CaptureEnd(output []byte, usedGas uint64, err error) {
+	// state of failed call is already reverted, with refunds
+	t.ended, t.execGas, t.refund = true, usedGas, t.env.IntraBlockState().GetRefund()
+}
+
+func (t *gasTracer) CaptureEnter(typ vm.OpCode, from libcommon.Address, to libcommon.Address, precompile bool, create bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+}
+
+func (t *gasTracer) CaptureExit(output []byte, usedGas uint64, err error) {}
+
+func (t *gasTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+}
+
+func (t *gasTracer) CaptureFault(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, depth int, err error) {
+}
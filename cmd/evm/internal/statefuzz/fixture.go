@@ -0,0 +1,131 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package statefuzz
+
+import (
+	"encoding/json"
+	"fmt"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	types2 "github.com/erigontech/erigon-lib/types"
+
+	"github.com/erigontech/erigon/common/math"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+)
+
+// fixture of GeneralStateTests, as tests.StateTest and state test runners of other clients read it
+type fixture struct {
+	Env         fixtureEnv                    `json:"env"`
+	Pre         types.GenesisAlloc            `json:"pre"`
+	Transaction fixtureTx                     `json:"transaction"`
+	Post        map[string][]fixturePostState `json:"post"`
+	Info        map[string]string             `json:"_info,omitempty"`
+}
+
+type fixtureEnv struct {
+	Coinbase   libcommon.Address     `json:"currentCoinbase"`
+	Difficulty *math.HexOrDecimal256 `json:"currentDifficulty"`
+	Random     *math.HexOrDecimal256 `json:"currentRandom"`
+	GasLimit   math.HexOrDecimal64   `json:"currentGasLimit"`
+	Number     math.HexOrDecimal64   `json:"currentNumber"`
+	Timestamp  math.HexOrDecimal64   `json:"currentTimestamp"`
+	BaseFee    *math.HexOrDecimal256 `json:"currentBaseFee"`
+
+	ExcessBlobGas *math.HexOrDecimal64 `json:"currentExcessBlobGas,omitempty"`
+}
+
+type fixtureTx struct {
+	GasPrice             *math.HexOrDecimal256 `json:"gasPrice,omitempty"`
+	MaxFeePerGas         *math.HexOrDecimal256 `json:"maxFeePerGas,omitempty"`
+	MaxPriorityFeePerGas *math.HexOrDecimal256 `json:"maxPriorityFeePerGas,omitempty"`
+	Nonce                math.HexOrDecimal64   `json:"nonce"`
+	GasLimit             []math.HexOrDecimal64 `json:"gasLimit"`
+	SecretKey            hexutility.Bytes      `json:"secretKey"`
+	Sender               libcommon.Address     `json:"sender"`
+	To                   string                `json:"to"`
+	Data                 []string              `json:"data"`
+	Value                []string              `json:"value"`
+	AccessLists          []*types2.AccessList  `json:"accessLists,omitempty"`
+}
+
+type fixturePostState struct {
+	Hash    libcommon.Hash   `json:"hash"`
+	Logs    libcommon.Hash   `json:"logs"`
+	TxBytes hexutility.Bytes `json:"txbytes"`
+	Indexes struct {
+		Data  int `json:"data"`
+		Gas   int `json:"gas"`
+		Value int `json:"value"`
+	} `json:"indexes"`
+}
+
+// Fixture - state test of the case in JSON: {name: test}, root and logs are the expected post-state
+func Fixture(c *Case, root, logs libcommon.Hash) ([]byte, error) {
+	env := c.Env.Env
+	f := fixture{
+		Env: fixtureEnv{
+			Coinbase:   env.Coinbase,
+			Difficulty: math.NewHexOrDecimal256(0),
+			Random:     (*math.HexOrDecimal256)(env.Random),
+			GasLimit:   math.HexOrDecimal64(env.GasLimit),
+			Number:     math.HexOrDecimal64(env.Number),
+			Timestamp:  math.HexOrDecimal64(env.Timestamp),
+			BaseFee:    (*math.HexOrDecimal256)(env.BaseFee),
+
+			ExcessBlobGas: (*math.HexOrDecimal64)(env.ExcessBlobGas),
+		},
+		Pre:  c.Env.Pre,
+		Info: map[string]string{"comment": "generated by evm fuzz"},
+	}
+
+	txn := c.Tx
+	f.Transaction = fixtureTx{
+		Nonce:     math.HexOrDecimal64(txn.GetNonce()),
+		GasLimit:  []math.HexOrDecimal64{math.HexOrDecimal64(txn.GetGas())},
+		SecretKey: crypto.FromECDSA(c.Key),
+		Sender:    crypto.PubkeyToAddress(c.Key.PublicKey),
+		Data:      []string{hexutility.Encode(txn.GetData())},
+		Value:     []string{txn.GetValue().Hex()},
+	}
+	if to := txn.GetTo(); to != nil {
+		f.Transaction.To = to.Hex()
+	}
+	switch txn.Type() {
+	case types.LegacyTxType:
+		f.Transaction.GasPrice = (*math.HexOrDecimal256)(txn.GetPrice().ToBig())
+	case types.AccessListTxType:
+		f.Transaction.GasPrice = (*math.HexOrDecimal256)(txn.GetPrice().ToBig())
+		al := txn.GetAccessList()
+		f.Transaction.AccessLists = []*types2.AccessList{&al}
+	case types.DynamicFeeTxType:
+		f.Transaction.MaxFeePerGas = (*math.HexOrDecimal256)(txn.GetFeeCap().ToBig())
+		f.Transaction.MaxPriorityFeePerGas = (*math.HexOrDecimal256)(txn.GetTip().ToBig())
+		al := txn.GetAccessList()
+		f.Transaction.AccessLists = []*types2.AccessList{&al}
+	default:
+		return nil, fmt.Errorf("unsupported transaction type %d", txn.Type())
+	}
+
+	txBytes, err := types.MarshalTransactionsBinary(types.Transactions{txn})
+	if err != nil {
+		return nil, err
+	}
+	f.Post = map[string][]fixturePostState{c.Fork: {{Hash: root, Logs: logs, TxBytes: txBytes[0]}}}
+	return json.MarshalIndent(map[string]fixture{c.Name: f}, "", "  ")
+}
@@ -0,0 +1,372 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package statefuzz is a structured differential fuzzer of the EVM. It generates state tests - pre-state of
+// random contracts, one transaction and fork - executes them by t8n, checks invariants of the execution
+// (gas accounting, refunds, intrinsic gas of access lists, conservation of ether), cross-checks post-state root
+// with the state test runner and, optionally, with t8n of another client. Failing cases are state test fixtures.
+package statefuzz
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	types2 "github.com/erigontech/erigon-lib/types"
+
+	"github.com/erigontech/erigon/cmd/evm/internal/t8ntool"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/tests"
+)
+
+// Forks - forks of generated tests. They are post-merge: block has no rewards, so state test (which executes only
+// transaction) and t8n (which executes block) must have the same post-state
+var Forks = []string{"Paris", "Shanghai", "Cancun"}
+
+var (
+	senderKey, _ = crypto.HexToECDSA("45a915e4d060149eb4365960e6a7a45f334393093061116b197e3240065ff2d8")
+	coinbase     = libcommon.HexToAddress("0xc0ffee")
+)
+
+const (
+	baseFee   = 7
+	contracts = 4
+	eoas      = 3
+	slots     = 4
+)
+
+// Case - generated state test: one transaction on top of pre-state
+type Case struct {
+	Name string
+	Fork string
+	Env  t8ntool.Prestate // env and pre-state, as t8n reads them
+	Tx   types.Transaction
+	Key  *ecdsa.PrivateKey
+}
+
+// Generate - deterministic case of seed
+func Generate(seed int64, fork string) (*Case, error) {
+	config, _, err := tests.GetChainConfig(fork)
+	if err != nil {
+		return nil, err
+	}
+	g := &generator{rng: rand.New(rand.NewSource(seed)), shanghai: config.IsShanghai(0), cancun: config.IsCancun(0)}
+
+	c := &Case{Name: fmt.Sprintf("fuzz_%s_%d", fork, seed), Fork: fork, Key: senderKey}
+	pre := types.GenesisAlloc{
+		crypto.PubkeyToAddress(senderKey.PublicKey): {Balance: big.NewInt(params.Ether), Nonce: uint64(g.rng.Intn(3))},
+	}
+	for i := 0; i < contracts; i++ {
+		account := types.GenesisAccount{Code: g.code(), Balance: big.NewInt(g.rng.Int63n(1_000_000)), Storage: map[libcommon.Hash]libcommon.Hash{}}
+		for slot := 0; slot < slots; slot++ {
+			if g.rng.Intn(2) == 0 { // non-zero slots can be cleared for refund
+				account.Storage[libcommon.BigToHash(big.NewInt(int64(slot)))] = libcommon.BigToHash(big.NewInt(g.rng.Int63n(1000) + 1))
+			}
+		}
+		pre[contractAddr(i)] = account
+	}
+	for i := 0; i < eoas; i++ {
+		pre[eoaAddr(i)] = types.GenesisAccount{Balance: big.NewInt(g.rng.Int63n(1_000_000))}
+	}
+	random := new(big.Int).SetUint64(g.rng.Uint64())
+	c.Env.Pre = pre
+	c.Env.Env.Coinbase = coinbase
+	c.Env.Env.Difficulty = big.NewInt(0)
+	c.Env.Env.Random = random
+	c.Env.Env.MixDigest = libcommon.BigToHash(random)
+	c.Env.Env.GasLimit = 30_000_000
+	c.Env.Env.Number = 1
+	c.Env.Env.Timestamp = 1000
+	c.Env.Env.BaseFee = big.NewInt(baseFee)
+	if g.shanghai {
+		c.Env.Env.Withdrawals = []*types.Withdrawal{}
+	}
+	if g.cancun {
+		c.Env.Env.ParentBeaconBlockRoot = &libcommon.Hash{}
+		c.Env.Env.ExcessBlobGas = new(uint64)
+	}
+
+	signer := types.MakeSigner(config, c.Env.Env.Number, c.Env.Env.Timestamp)
+	if c.Tx, err = types.SignTx(g.tx(pre[crypto.PubkeyToAddress(senderKey.PublicKey)].Nonce), *signer, senderKey); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func contractAddr(i int) libcommon.Address {
+	return libcommon.BigToAddress(big.NewInt(0xc0de00 + int64(i)))
+}
+
+func eoaAddr(i int) libcommon.Address {
+	return libcommon.BigToAddress(big.NewInt(0xee00 + int64(i)))
+}
+
+type generator struct {
+	rng              *rand.Rand
+	shanghai, cancun bool
+}
+
+// program - bytecode builder
+type program struct {
+	code     []byte
+	shanghai bool
+}
+
+func (p *program) op(ops ...vm.OpCode) *program {
+	for _, op := range ops {
+		p.code = append(p.code, byte(op))
+	}
+	return p
+}
+
+func (p *program) push(v uint64) *program {
+	if v == 0 && p.shanghai {
+		return p.op(vm.PUSH0)
+	}
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], v)
+	i := 0
+	for i < 7 && b[i] == 0 {
+		i++
+	}
+	p.code = append(p.code, byte(vm.PUSH1)+byte(7-i))
+	p.code = append(p.code, b[i:]...)
+	return p
+}
+
+func (p *program) pushAddr(a libcommon.Address) *program {
+	p.code = append(p.code, byte(vm.PUSH20))
+	p.code = append(p.code, a[:]...)
+	return p
+}
+
+// code - random sequence of stack-balanced snippets and terminator
+func (g *generator) code() []byte {
+	p := &program{shanghai: g.shanghai}
+	for n := g.rng.Intn(12) + 1; n > 0; n-- {
+		g.snippet(p)
+	}
+	switch g.rng.Intn(8) {
+	case 0:
+		p.op(vm.STOP)
+	case 1:
+		p.push(32).push(0).op(vm.RETURN)
+	case 2:
+		p.push(0).push(0).op(vm.REVERT)
+	case 3:
+		p.op(vm.INVALID)
+	case 4:
+		// beneficiary is EOA: ether sent to destructed contract would be burnt, conservation check doesn't count it
+		p.pushAddr(eoaAddr(g.rng.Intn(eoas))).op(vm.SELFDESTRUCT)
+	default: // end of code
+	}
+	return p.code
+}
+
+// account - address accessed by code: contract, EOA, precompile or empty account
+func (g *generator) account() libcommon.Address {
+	switch g.rng.Intn(4) {
+	case 0:
+		return contractAddr(g.rng.Intn(contracts))
+	case 1:
+		return eoaAddr(g.rng.Intn(eoas))
+	case 2:
+		return libcommon.BigToAddress(big.NewInt(int64(g.rng.Intn(9) + 1)))
+	default:
+		return libcommon.BigToAddress(big.NewInt(0xdead00 + int64(g.rng.Intn(3))))
+	}
+}
+
+var (
+	binaryOps = []vm.OpCode{vm.ADD, vm.MUL, vm.SUB, vm.DIV, vm.SDIV, vm.MOD, vm.SMOD, vm.EXP, vm.SIGNEXTEND, vm.LT, vm.GT,
+		vm.SLT, vm.SGT, vm.EQ, vm.AND, vm.OR, vm.XOR, vm.BYTE, vm.SHL, vm.SHR, vm.SAR}
+	// BLOCKHASH and BLOBBASEFEE are absent: state test and t8n have different block hashes and blob base fee
+	envOps = []vm.OpCode{vm.ADDRESS, vm.ORIGIN, vm.CALLER, vm.CALLVALUE, vm.CALLDATASIZE, vm.CODESIZE, vm.GASPRICE,
+		vm.RETURNDATASIZE, vm.COINBASE, vm.TIMESTAMP, vm.NUMBER, vm.DIFFICULTY, vm.GASLIMIT, vm.CHAINID, vm.SELFBALANCE,
+		vm.BASEFEE, vm.GAS, vm.MSIZE, vm.PC}
+	accountOps = []vm.OpCode{vm.BALANCE, vm.EXTCODESIZE, vm.EXTCODEHASH}
+)
+
+func (g *generator) snippet(p *program) {
+	switch g.rng.Intn(15) {
+	case 0, 1:
+		p.push(uint64(g.rng.Intn(3))).push(uint64(g.rng.Intn(slots))).op(vm.SSTORE) // zero value clears slot
+	case 2:
+		p.push(uint64(g.rng.Intn(slots))).op(vm.SLOAD, vm.POP)
+	case 3:
+		p.push(g.rng.Uint64()).push(uint64(g.rng.Intn(64))).op(binaryOps[g.rng.Intn(len(binaryOps))], vm.POP)
+	case 4:
+		p.push(g.rng.Uint64()).push(uint64(g.rng.Intn(64))).op(vm.MSTORE)
+		p.push(uint64(g.rng.Intn(64))).push(uint64(g.rng.Intn(32))).op(vm.KECCAK256, vm.POP)
+	case 5:
+		topics := g.rng.Intn(3)
+		for i := 0; i < topics; i++ {
+			p.push(g.rng.Uint64())
+		}
+		p.push(uint64(g.rng.Intn(40))).push(uint64(g.rng.Intn(32))).op(vm.LOG0 + vm.OpCode(topics))
+	case 6, 7:
+		// call of contract without value: value sent to contract which self-destructs later would be burnt
+		p.push(0).push(0).push(uint64(g.rng.Intn(8))).push(0)
+		op := []vm.OpCode{vm.CALL, vm.CALLCODE, vm.DELEGATECALL, vm.STATICCALL}[g.rng.Intn(4)]
+		if op == vm.CALL || op == vm.CALLCODE {
+			p.push(0)
+		}
+		p.pushAddr(contractAddr(g.rng.Intn(contracts)))
+		if g.rng.Intn(2) == 0 {
+			p.op(vm.GAS)
+		} else {
+			p.push(uint64(g.rng.Intn(100_000)))
+		}
+		p.op(op, vm.POP)
+	case 8:
+		p.push(0).push(0).push(0).push(0).push(uint64(g.rng.Intn(1000)))
+		p.pushAddr(eoaAddr(g.rng.Intn(eoas))).push(0).op(vm.CALL, vm.POP)
+	case 9:
+		p.pushAddr(g.account()).op(accountOps[g.rng.Intn(len(accountOps))], vm.POP)
+	case 10:
+		p.op(envOps[g.rng.Intn(len(envOps))], vm.POP)
+	case 11:
+		if g.cancun {
+			p.push(uint64(g.rng.Intn(3))).push(uint64(g.rng.Intn(slots))).op(vm.TSTORE)
+			p.push(uint64(g.rng.Intn(slots))).op(vm.TLOAD, vm.POP)
+		} else {
+			p.push(uint64(g.rng.Intn(64))).op(vm.CALLDATALOAD, vm.POP)
+		}
+	case 12:
+		if g.rng.Intn(2) == 0 {
+			p.push(g.rng.Uint64()).push(0).push(0).push(0).op(vm.CREATE2, vm.POP)
+		} else {
+			p.push(0).push(0).push(0).op(vm.CREATE, vm.POP)
+		}
+	case 13:
+		if g.cancun {
+			p.push(uint64(g.rng.Intn(64))).push(uint64(g.rng.Intn(64))).push(uint64(g.rng.Intn(64))).op(vm.MCOPY)
+		} else {
+			p.push(uint64(g.rng.Intn(64))).push(uint64(g.rng.Intn(64))).push(0).op(vm.CALLDATACOPY)
+		}
+	case 14:
+		p.push(uint64(g.rng.Intn(32))).push(0).push(0).op(vm.RETURNDATACOPY)
+	}
+}
+
+// initCode - runs snippets and deploys runtime code
+func (g *generator) initCode() []byte {
+	p := &program{shanghai: g.shanghai}
+	for n := g.rng.Intn(4); n > 0; n-- {
+		g.snippet(p)
+	}
+	runtime := g.code()
+	// offset of runtime is known when the rest of init code is built, it's pushed by PUSH2
+	p.push(uint64(len(runtime))).op(vm.DUP1)
+	offsetAt := len(p.code) + 1
+	p.code = append(p.code, byte(vm.PUSH2), 0, 0)
+	p.push(0).op(vm.CODECOPY).push(0).op(vm.RETURN)
+	binary.BigEndian.PutUint16(p.code[offsetAt:], uint16(len(p.code)))
+	return append(p.code, runtime...)
+}
+
+func (g *generator) data() []byte {
+	data := make([]byte, g.rng.Intn(64))
+	for i := range data {
+		if g.rng.Intn(3) > 0 {
+			data[i] = byte(g.rng.Intn(256))
+		}
+	}
+	return data
+}
+
+func (g *generator) accessList() types2.AccessList {
+	var al types2.AccessList
+	for n := g.rng.Intn(4); n > 0; n-- {
+		tuple := types2.AccessTuple{Address: g.account(), StorageKeys: []libcommon.Hash{}}
+		for k := g.rng.Intn(3); k > 0; k-- {
+			tuple.StorageKeys = append(tuple.StorageKeys, libcommon.BigToHash(big.NewInt(int64(g.rng.Intn(slots)))))
+		}
+		al = append(al, tuple)
+	}
+	return al
+}
+
+func (g *generator) tx(nonce uint64) types.Transaction {
+	var to *libcommon.Address
+	var data []byte
+	switch r := g.rng.Intn(10); {
+	case r < 7:
+		a := contractAddr(g.rng.Intn(contracts))
+		to, data = &a, g.data()
+	case r < 9:
+		data = g.initCode()
+	default:
+		a := eoaAddr(g.rng.Intn(eoas))
+		to = &a
+	}
+	value := uint256.NewInt(uint64(g.rng.Intn(1_000_000)))
+	var al types2.AccessList
+	txType := g.rng.Intn(3)
+	if txType > 0 {
+		al = g.accessList()
+	}
+	gas := intrinsicGas(data, al, to == nil, g.shanghai)
+	if g.rng.Intn(8) > 0 { // exactly intrinsic gas otherwise
+		gas += uint64(g.rng.Intn(400_000))
+	}
+
+	common := func() types.CommonTx {
+		return types.CommonTx{Nonce: nonce, Gas: gas, To: to, Value: value, Data: data}
+	}
+	gasPrice := uint256.NewInt(baseFee + uint64(g.rng.Intn(3)))
+	switch txType {
+	case 0:
+		return &types.LegacyTx{CommonTx: common(), GasPrice: gasPrice}
+	case 1:
+		return &types.AccessListTx{LegacyTx: types.LegacyTx{CommonTx: common(), GasPrice: gasPrice}, ChainID: uint256.NewInt(1), AccessList: al}
+	default:
+		// tip can be above fee cap minus base fee, not above fee cap
+		feeCap := baseFee + uint64(g.rng.Intn(10))
+		return &types.DynamicFeeTransaction{CommonTx: common(), ChainID: uint256.NewInt(1), AccessList: al,
+			FeeCap: uint256.NewInt(feeCap), Tip: uint256.NewInt(uint64(g.rng.Intn(int(feeCap) + 1)))}
+	}
+}
+
+// intrinsicGas - gas charged before execution, independent of core.IntrinsicGas for cross-check
+func intrinsicGas(data []byte, al types2.AccessList, create, shanghai bool) uint64 {
+	gas := uint64(21_000)
+	if create {
+		gas += 32_000
+		if shanghai {
+			gas += 2 * ((uint64(len(data)) + 31) / 32) // EIP-3860: init code words
+		}
+	}
+	for _, b := range data {
+		if b == 0 {
+			gas += 4
+		} else {
+			gas += 16
+		}
+	}
+	for _, tuple := range al {
+		gas += 2400 + 1900*uint64(len(tuple.StorageKeys))
+	}
+	return gas
+}
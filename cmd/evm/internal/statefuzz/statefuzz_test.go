@@ -0,0 +1,139 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package statefuzz
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+)
+
+func TestGenerateDeterministic(t *testing.T) {
+	for _, fork := range Forks {
+		a, err := Generate(7, fork)
+		require.NoError(t, err)
+		b, err := Generate(7, fork)
+		require.NoError(t, err)
+		fa, err := Fixture(a, libcommon.Hash{}, libcommon.Hash{})
+		require.NoError(t, err)
+		fb, err := Fixture(b, libcommon.Hash{}, libcommon.Hash{})
+		require.NoError(t, err)
+		require.Equal(t, string(fa), string(fb))
+	}
+	_, err := Generate(7, "NoSuchFork")
+	require.Error(t, err)
+}
+
+func TestCheck(t *testing.T) {
+	seeds := 30
+	if testing.Short() {
+		seeds = 5
+	}
+	for _, fork := range Forks {
+		for seed := int64(0); seed < int64(seeds); seed++ {
+			c, err := Generate(seed, fork)
+			require.NoError(t, err)
+			if err = Check(context.Background(), c, Config{TmpDir: t.TempDir()}); err != nil {
+				var failure *Failure
+				if errors.As(err, &failure) {
+					fixture, _ := failure.Fixture()
+					t.Logf("%s", fixture)
+				}
+				t.Fatal(err)
+			}
+		}
+	}
+}
+
+func TestCheckFailure(t *testing.T) {
+	c, err := Generate(1, "Cancun")
+	require.NoError(t, err)
+
+	// expected root of fixture is wrong: state test runner fails
+	fixture, err := Fixture(c, libcommon.Hash{1}, libcommon.Hash{})
+	require.NoError(t, err)
+	dir := t.TempDir()
+	require.ErrorContains(t, runStateTest(context.Background(), c, fixture, datadir.New(dir)), "post state root mismatch")
+
+	// gas of top call is not gas limit minus intrinsic gas
+	tracer := &gasTracer{txs: 1, ended: true, gasLimit: c.Tx.GetGas(), callGas: c.Tx.GetGas()}
+	check, err := checkGas(c, 0, tracer, true)
+	require.Error(t, err)
+	require.Equal(t, "intrinsic gas", check)
+}
+
+func TestCheckReference(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("reference t8n is a shell script")
+	}
+	dir := t.TempDir()
+	// reference which disagrees on state root
+	reference := filepath.Join(dir, "t8n.sh")
+	script := `#!/bin/sh
+while [ $# -gt 0 ]; do
+	case "$1" in
+		--output.basedir) basedir="$2"; shift ;;
+		--input.alloc|--input.env|--input.txs) [ -s "$2" ] || exit 2; shift ;;
+	esac
+	shift
+done
+echo '{"stateRoot":"0x0100000000000000000000000000000000000000000000000000000000000000","gasUsed":"0x5208"}' > "$basedir/result.json"
+`
+	require.NoError(t, os.WriteFile(reference, []byte(script), 0755))
+
+	c, err := Generate(3, "Shanghai")
+	require.NoError(t, err)
+	err = Check(context.Background(), c, Config{Reference: reference, TmpDir: dir})
+	var failure *Failure
+	require.ErrorAs(t, err, &failure)
+	require.Equal(t, "reference", failure.Check)
+	require.Equal(t, c, failure.Case)
+
+	// failing case is a state test which passes in Erigon
+	fixture, err := failure.Fixture()
+	require.NoError(t, err)
+	require.NoError(t, runStateTest(context.Background(), c, fixture, datadir.New(t.TempDir())))
+
+	_, err = runReference(context.Background(), filepath.Join(dir, "no-such-t8n"), t.TempDir(), c)
+	require.Error(t, err)
+}
+
+func FuzzStateTest(f *testing.F) {
+	for seed := int64(0); seed < 4; seed++ {
+		f.Add(seed, uint8(seed))
+	}
+	f.Fuzz(func(t *testing.T, seed int64, fork uint8) {
+		c, err := Generate(seed, Forks[int(fork)%len(Forks)])
+		require.NoError(t, err)
+		if err = Check(context.Background(), c, Config{TmpDir: t.TempDir()}); err != nil {
+			var failure *Failure
+			if errors.As(err, &failure) {
+				fixture, _ := failure.Fixture()
+				t.Logf("%s", fixture)
+			}
+			t.Fatal(err)
+		}
+	})
+}
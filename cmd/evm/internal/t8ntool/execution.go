@@ -35,7 +35,6 @@ import (
 	"github.com/erigontech/erigon/core/state"
 	"github.com/erigontech/erigon/core/tracing"
 	"github.com/erigontech/erigon/core/types"
-	"github.com/erigontech/erigon/turbo/rpchelper"
 )
 
 type Prestate struct {
@@ -50,24 +49,26 @@ type ommer struct {
 
 //go:generate gencodec -type stEnv -field-override stEnvMarshaling -out gen_stenv.go
 type stEnv struct {
-	Coinbase         libcommon.Address                      `json:"currentCoinbase"   gencodec:"required"`
-	Difficulty       *big.Int                               `json:"currentDifficulty"`
-	Random           *big.Int                               `json:"currentRandom"`
-	MixDigest        libcommon.Hash                         `json:"mixHash,omitempty"`
-	ParentDifficulty *big.Int                               `json:"parentDifficulty"`
-	GasLimit         uint64                                 `json:"currentGasLimit"   gencodec:"required"`
-	Number           uint64                                 `json:"currentNumber"     gencodec:"required"`
-	Timestamp        uint64                                 `json:"currentTimestamp"  gencodec:"required"`
-	ParentTimestamp  uint64                                 `json:"parentTimestamp,omitempty"`
-	BlockHashes      map[math.HexOrDecimal64]libcommon.Hash `json:"blockHashes,omitempty"`
-	Ommers           []ommer                                `json:"ommers,omitempty"`
-	BaseFee          *big.Int                               `json:"currentBaseFee,omitempty"`
-	ParentUncleHash  libcommon.Hash                         `json:"parentUncleHash"`
-	UncleHash        libcommon.Hash                         `json:"uncleHash,omitempty"`
-	Withdrawals      []*types.Withdrawal                    `json:"withdrawals,omitempty"`
-	WithdrawalsHash  *libcommon.Hash                        `json:"withdrawalsRoot,omitempty"`
-	Requests         types.Requests                         `json:"requests,omitempty"`
-	RequestsRoot     *libcommon.Hash                        `json:"requestsRoot,omitempty"`
+	Coinbase              libcommon.Address                      `json:"currentCoinbase"   gencodec:"required"`
+	Difficulty            *big.Int                               `json:"currentDifficulty"`
+	Random                *big.Int                               `json:"currentRandom"`
+	MixDigest             libcommon.Hash                         `json:"mixHash,omitempty"`
+	ParentDifficulty      *big.Int                               `json:"parentDifficulty"`
+	GasLimit              uint64                                 `json:"currentGasLimit"   gencodec:"required"`
+	Number                uint64                                 `json:"currentNumber"     gencodec:"required"`
+	Timestamp             uint64                                 `json:"currentTimestamp"  gencodec:"required"`
+	ParentTimestamp       uint64                                 `json:"parentTimestamp,omitempty"`
+	BlockHashes           map[math.HexOrDecimal64]libcommon.Hash `json:"blockHashes,omitempty"`
+	Ommers                []ommer                                `json:"ommers,omitempty"`
+	BaseFee               *big.Int                               `json:"currentBaseFee,omitempty"`
+	ParentUncleHash       libcommon.Hash                         `json:"parentUncleHash"`
+	UncleHash             libcommon.Hash                         `json:"uncleHash,omitempty"`
+	Withdrawals           []*types.Withdrawal                    `json:"withdrawals,omitempty"`
+	WithdrawalsHash       *libcommon.Hash                        `json:"withdrawalsRoot,omitempty"`
+	Requests              types.Requests                         `json:"requests,omitempty"`
+	RequestsRoot          *libcommon.Hash                        `json:"requestsRoot,omitempty"`
+	ParentBeaconBlockRoot *libcommon.Hash                        `json:"parentBeaconBlockRoot,omitempty"`
+	ExcessBlobGas         *uint64                                `json:"currentExcessBlobGas,omitempty"`
 }
 
 type stEnvMarshaling struct {
@@ -80,12 +81,14 @@ type stEnvMarshaling struct {
 	Timestamp        math.HexOrDecimal64
 	ParentTimestamp  math.HexOrDecimal64
 	BaseFee          *math.HexOrDecimal256
+	ExcessBlobGas    *math.HexOrDecimal64
 }
 
 func MakePreState(chainRules *chain.Rules, tx kv.RwTx, sd *state3.SharedDomains, accounts types.GenesisAlloc) (state.StateReader, state.WriterWithChangeSets) {
 	var blockNr uint64 = 0
 
-	stateReader, stateWriter := rpchelper.NewLatestStateReader(tx), state.NewWriterV4(sd)
+	// pre-state isn't flushed to tx: reader reads domains
+	stateReader, stateWriter := state.NewReaderV4(sd), state.NewWriterV4(sd)
 	sd.SetBlockNum(blockNr)
 
 	statedb := state.New(stateReader) //ibs
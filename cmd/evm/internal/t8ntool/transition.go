@@ -230,22 +230,34 @@ func Main(ctx *cli.Context) error {
 		return NewError(ErrorJson, fmt.Errorf("failed signing transactions: %v", err))
 	}
 
+	result, alloc, err := Transition(&prestate, txs, chainConfig, vmConfig, getTracer, datadir.New(""))
+	if err != nil {
+		return err
+	}
+	body, _ := rlp.EncodeToBytes(txs)
+	return dispatchOutput(ctx, baseDir, result, alloc, body)
+}
+
+// Transition executes txs as a block of env on top of prestate's alloc, it's t8n without input and output files.
+// Env of prestate is completed: difficulty is calculated if it's not given. State is kept in memory, dirs are for temporary files.
+func Transition(prestate *Prestate, txs types.Transactions, chainConfig *chain.Config, vmConfig vm.Config,
+	getTracer func(txIndex int, txHash libcommon.Hash) (vm.EVMLogger, error), dirs datadir.Dirs) (*core.EphemeralExecResult, Alloc, error) {
 	eip1559 := chainConfig.IsLondon(prestate.Env.Number)
 	// Sanity check, to not `panic` in state_transition
 	if eip1559 {
 		if prestate.Env.BaseFee == nil {
-			return NewError(ErrorVMConfig, errors.New("EIP-1559 config but missing 'currentBaseFee' in env section"))
+			return nil, nil, NewError(ErrorVMConfig, errors.New("EIP-1559 config but missing 'currentBaseFee' in env section"))
 		}
 	} else {
 		prestate.Env.Random = nil
 	}
 
 	if chainConfig.IsShanghai(prestate.Env.Timestamp) && prestate.Env.Withdrawals == nil {
-		return NewError(ErrorVMConfig, errors.New("shanghai config but missing 'withdrawals' in env section"))
+		return nil, nil, NewError(ErrorVMConfig, errors.New("shanghai config but missing 'withdrawals' in env section"))
 	}
 
 	if chainConfig.IsPrague(prestate.Env.Timestamp) && prestate.Env.Requests == nil {
-		return NewError(ErrorVMConfig, errors.New("prague config but missing 'requests' in env section"))
+		return nil, nil, NewError(ErrorVMConfig, errors.New("prague config but missing 'requests' in env section"))
 	}
 
 	isMerged := chainConfig.TerminalTotalDifficulty != nil && chainConfig.TerminalTotalDifficulty.BitLen() == 0
@@ -256,20 +268,20 @@ func Main(ctx *cli.Context) error {
 		// - difficulty must be zero
 		switch {
 		case env.Random == nil:
-			return NewError(ErrorVMConfig, errors.New("post-merge requires currentRandom to be defined in env"))
+			return nil, nil, NewError(ErrorVMConfig, errors.New("post-merge requires currentRandom to be defined in env"))
 		case env.Difficulty != nil && env.Difficulty.BitLen() != 0:
-			return NewError(ErrorVMConfig, errors.New("post-merge difficulty must be zero (or omitted) in env"))
+			return nil, nil, NewError(ErrorVMConfig, errors.New("post-merge difficulty must be zero (or omitted) in env"))
 		}
 		prestate.Env.Difficulty = nil
 	} else if env.Difficulty == nil {
 		// If difficulty was not provided by caller, we need to calculate it.
 		switch {
 		case env.ParentDifficulty == nil:
-			return NewError(ErrorVMConfig, errors.New("currentDifficulty was not provided, and cannot be calculated due to missing parentDifficulty"))
+			return nil, nil, NewError(ErrorVMConfig, errors.New("currentDifficulty was not provided, and cannot be calculated due to missing parentDifficulty"))
 		case env.Number == 0:
-			return NewError(ErrorVMConfig, errors.New("currentDifficulty needs to be provided for block number 0"))
+			return nil, nil, NewError(ErrorVMConfig, errors.New("currentDifficulty needs to be provided for block number 0"))
 		case env.Timestamp <= env.ParentTimestamp:
-			return NewError(ErrorVMConfig, fmt.Errorf("currentDifficulty cannot be calculated -- currentTime (%d) needs to be after parent time (%d)",
+			return nil, nil, NewError(ErrorVMConfig, fmt.Errorf("currentDifficulty cannot be calculated -- currentTime (%d) needs to be after parent time (%d)",
 				env.Timestamp, env.ParentTimestamp))
 		}
 		prestate.Env.Difficulty = calcDifficulty(chainConfig, env.Number, env.Timestamp,
@@ -301,19 +313,19 @@ func Main(ctx *cli.Context) error {
 		return h
 	}
 
-	db, agg := temporaltest.NewTestDB(nil, datadir.New(""))
+	db, agg := temporaltest.NewTestDB(nil, dirs)
 	defer db.Close()
 	defer agg.Close()
 
 	tx, err := db.BeginRw(context.Background())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer tx.Rollback()
 
 	sd, err := libstate.NewSharedDomains(tx, log.New())
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 	defer sd.Close()
 
@@ -326,27 +338,29 @@ func Main(ctx *cli.Context) error {
 	chainReader := consensuschain.NewReader(chainConfig, tx, nil, t8logger)
 	result, err := core.ExecuteBlockEphemerally(chainConfig, &vmConfig, getHash, engine, block, reader, writer, chainReader, getTracer, t8logger)
 	if hashError != nil {
-		return NewError(ErrorMissingBlockhash, fmt.Errorf("blockhash error: %v", err))
+		return nil, nil, NewError(ErrorMissingBlockhash, fmt.Errorf("blockhash error: %v", hashError))
 	}
 
 	if err != nil {
-		return fmt.Errorf("error on EBE: %w", err)
+		return nil, nil, fmt.Errorf("error on EBE: %w", err)
 	}
 
 	// state root calculation
-	root, err := CalculateStateRoot(tx)
+	root, err := sd.ComputeCommitment(context.Background(), false, prestate.Env.Number, "")
 	if err != nil {
-		return err
+		return nil, nil, err
+	}
+	result.StateRoot = libcommon.BytesToHash(root)
+	if err = sd.Flush(context.Background(), tx); err != nil {
+		return nil, nil, err
 	}
-	result.StateRoot = *root
 
-	// Dump the execution result
-	body, _ := rlp.EncodeToBytes(txs)
+	// Dump the execution result: state isn't indexed by blocks, it's written as of sd's txNum
 	collector := make(Alloc)
 
-	dumper := state.NewDumper(tx, prestate.Env.Number)
+	dumper := state.NewDumperAtTxNum(tx, sd.TxNum()+1)
 	dumper.DumpToCollector(collector, false, false, libcommon.Address{}, 0)
-	return dispatchOutput(ctx, baseDir, result, collector, body)
+	return result, collector, nil
 }
 
 // txWithKey is a helper-struct, to allow us to use the types.Transaction along with
@@ -617,6 +631,8 @@ func NewHeader(env stEnv) *types.Header {
 	header.UncleHash = env.UncleHash
 	header.WithdrawalsHash = env.WithdrawalsHash
 	header.RequestsRoot = env.RequestsRoot
+	header.ParentBeaconBlockRoot = env.ParentBeaconBlockRoot
+	header.ExcessBlobGas = env.ExcessBlobGas
 
 	return &header
 }
@@ -190,6 +190,7 @@ func init() {
 		&runCommand,
 		&stateTestCommand,
 		&stateTransitionCommand,
+		&stateFuzzCommand,
 	}
 }
 
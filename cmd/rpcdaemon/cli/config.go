@@ -71,6 +71,7 @@ import (
 	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/core/vm/evmtypes"
 	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/ethdb/prune"
 	"github.com/erigontech/erigon/node"
 	"github.com/erigontech/erigon/node/nodecfg"
 	"github.com/erigontech/erigon/polygon/bor"
@@ -93,7 +94,8 @@ var rootCmd = &cobra.Command{
 }
 
 var (
-	stateCacheStr string
+	stateCacheStr  string
+	resultCacheStr string
 )
 
 func RootCommand() (*cobra.Command, *httpcfg.HttpCfg) {
@@ -145,6 +147,8 @@ func RootCommand() (*cobra.Command, *httpcfg.HttpCfg) {
 
 	rootCmd.PersistentFlags().BoolVar(&cfg.SocketServerEnabled, "socket.enabled", false, "Enable IPC server")
 	rootCmd.PersistentFlags().StringVar(&cfg.SocketListenUrl, "socket.url", "unix:///var/run/erigon.sock", "IPC server listening url. prefix supported are tcp, unix")
+	rootCmd.PersistentFlags().StringVar(&cfg.AuthRpcURL, utils.AuthRpcURL.Name, utils.AuthRpcURL.Value, utils.AuthRpcURL.Usage)
+	rootCmd.PersistentFlags().StringVar(&cfg.AuthRpcIPCPath, utils.AuthRpcIPCPath.Name, utils.AuthRpcIPCPath.Value, utils.AuthRpcIPCPath.Usage)
 
 	rootCmd.PersistentFlags().BoolVar(&cfg.TraceRequests, utils.HTTPTraceFlag.Name, false, "Trace HTTP requests with INFO level")
 	rootCmd.PersistentFlags().DurationVar(&cfg.HTTPTimeouts.ReadTimeout, "http.timeouts.read", rpccfg.DefaultHTTPTimeouts.ReadTimeout, "Maximum duration for reading the entire request, including the body.")
@@ -160,6 +164,8 @@ func RootCommand() (*cobra.Command, *httpcfg.HttpCfg) {
 	rootCmd.PersistentFlags().IntVar(&cfg.RpcFiltersConfig.RpcSubscriptionFiltersMaxTopics, "rpc.subscription.filters.maxtopics", rpchelper.DefaultFiltersConfig.RpcSubscriptionFiltersMaxTopics, "Maximum number of topics per subscription to filter logs by.")
 	rootCmd.PersistentFlags().IntVar(&cfg.BatchLimit, utils.RpcBatchLimit.Name, utils.RpcBatchLimit.Value, utils.RpcBatchLimit.Usage)
 	rootCmd.PersistentFlags().IntVar(&cfg.ReturnDataLimit, utils.RpcReturnDataLimit.Name, utils.RpcReturnDataLimit.Value, utils.RpcReturnDataLimit.Usage)
+	rootCmd.PersistentFlags().StringVar(&resultCacheStr, utils.RpcResultCacheSizeFlag.Name, utils.RpcResultCacheSizeFlag.Value, utils.RpcResultCacheSizeFlag.Usage)
+	rootCmd.PersistentFlags().StringVar(&cfg.ResultCacheDir, utils.RpcResultCacheDirFlag.Name, "", utils.RpcResultCacheDirFlag.Usage)
 	rootCmd.PersistentFlags().BoolVar(&cfg.AllowUnprotectedTxs, utils.AllowUnprotectedTxs.Name, utils.AllowUnprotectedTxs.Value, utils.AllowUnprotectedTxs.Usage)
 	rootCmd.PersistentFlags().IntVar(&cfg.MaxGetProofRewindBlockCount, utils.RpcMaxGetProofRewindBlockCount.Name, utils.RpcMaxGetProofRewindBlockCount.Value, utils.RpcMaxGetProofRewindBlockCount.Usage)
 	rootCmd.PersistentFlags().Uint64Var(&cfg.OtsMaxPageSize, utils.OtsSearchMaxCapFlag.Name, utils.OtsSearchMaxCapFlag.Value, utils.OtsSearchMaxCapFlag.Usage)
@@ -185,6 +191,10 @@ func RootCommand() (*cobra.Command, *httpcfg.HttpCfg) {
 			return fmt.Errorf("state.cache value of %v is not valid", stateCacheStr)
 		}
 
+		if err = cfg.ResultCacheSize.UnmarshalText([]byte(resultCacheStr)); err != nil {
+			return fmt.Errorf("%s value of %v is not valid", utils.RpcResultCacheSizeFlag.Name, resultCacheStr)
+		}
+
 		cfg.WithDatadir = cfg.DataDir != ""
 		if cfg.WithDatadir {
 			if cfg.DataDir == "" {
@@ -402,6 +412,16 @@ func RemoteServices(ctx context.Context, cfg *httpcfg.HttpCfg, logger log.Logger
 			return nil, nil, nil, nil, nil, nil, nil, ff, fmt.Errorf("create aggregator: %w", err)
 		}
 		_ = agg.OpenFolder() //TODO: must use analog of `OptimisticReopenWithDB`
+		var pm prune.Mode
+		if err := db.View(ctx, func(tx kv.Tx) (err error) {
+			pm, err = prune.Get(tx)
+			return err
+		}); err != nil {
+			return nil, nil, nil, nil, nil, nil, nil, ff, fmt.Errorf("read prune mode: %w", err)
+		}
+		if keep := pm.HistoryKeyFilter(); keep != nil { // to fail reads of history which Erigon doesn't write
+			agg.LimitHistoryToKeys(kv.AccountsDomain, keep).LimitHistoryToKeys(kv.StorageDomain, keep).LimitHistoryToKeys(kv.CodeDomain, keep)
+		}
 
 		db.View(context.Background(), func(tx kv.Tx) error {
 			aggTx := agg.BeginFilesRo()
@@ -753,6 +773,7 @@ type engineInfo struct {
 	Srv                *rpc.Server
 	EngineSrv          *rpc.Server
 	EngineListener     *http.Server
+	EngineIPCListener  net.Listener // nil if Engine API isn't served over IPC
 	EngineHttpEndpoint string
 }
 
@@ -763,7 +784,13 @@ func startAuthenticatedRpcServer(cfg *httpcfg.HttpCfg, rpcAPI []rpc.API, logger
 	if err != nil {
 		return nil, fmt.Errorf("could not start RPC api for engine: %w", err)
 	}
-	return &engineInfo{Srv: srv, EngineSrv: engineSrv, EngineListener: engineListener, EngineHttpEndpoint: engineHttpEndpoint}, nil
+	engineIPCListener, err := createEngineIPCListener(cfg, engineSrv, logger)
+	if err != nil {
+		_ = engineListener.Close()
+		engineSrv.Stop()
+		return nil, fmt.Errorf("could not start IPC endpoint for engine: %w", err)
+	}
+	return &engineInfo{Srv: srv, EngineSrv: engineSrv, EngineListener: engineListener, EngineIPCListener: engineIPCListener, EngineHttpEndpoint: engineHttpEndpoint}, nil
 }
 
 func stopAuthenticatedRpcServer(ctx context.Context, engineInfo *engineInfo, logger log.Logger) {
@@ -779,6 +806,10 @@ func stopAuthenticatedRpcServer(ctx context.Context, engineInfo *engineInfo, log
 			_ = engineInfo.EngineListener.Shutdown(shutdownCtx)
 			logger.Info("Engine HTTP endpoint close", "url", engineInfo.EngineHttpEndpoint)
 		}
+		if engineInfo.EngineIPCListener != nil {
+			_ = engineInfo.EngineIPCListener.Close()
+			logger.Info("Engine IPC endpoint close")
+		}
 	}()
 	<-ctx.Done()
 	logger.Info("Exiting Engine...")
@@ -846,6 +877,13 @@ func createHandler(cfg *httpcfg.HttpCfg, apiList []rpc.API, httpHandler http.Han
 
 func createEngineListener(cfg *httpcfg.HttpCfg, engineApi []rpc.API, logger log.Logger) (*http.Server, *rpc.Server, string, error) {
 	engineHttpEndpoint := fmt.Sprintf("tcp://%s:%d", cfg.AuthRpcHTTPListenAddress, cfg.AuthRpcPort)
+	if cfg.AuthRpcURL != "" {
+		engineHttpEndpoint = cfg.AuthRpcURL
+	}
+	socketPath, err := unixSocketPath(engineHttpEndpoint)
+	if err != nil {
+		return nil, nil, "", err
+	}
 
 	engineSrv := rpc.NewServer(cfg.RpcBatchConcurrency, cfg.TraceRequests, cfg.DebugSingleRequest, true, logger, cfg.RPCSlowLogThreshold)
 
@@ -869,19 +907,114 @@ func createEngineListener(cfg *httpcfg.HttpCfg, engineApi []rpc.API, logger log.
 		return nil, nil, "", err
 	}
 
-	engineListener, engineAddr, err := node.StartHTTPEndpoint(engineHttpEndpoint, &node.HttpEndpointConfig{
-		Timeouts: cfg.AuthRpcTimeouts,
-	}, engineApiHandler)
-	if err != nil {
+	httpEndpointCfg := &node.HttpEndpointConfig{Timeouts: cfg.AuthRpcTimeouts}
+	var engineListener *http.Server
+	var engineAddr net.Addr
+	if socketPath != "" {
+		var listener net.Listener
+		if listener, err = listenPrivateUnixSocket(socketPath); err != nil {
+			return nil, nil, "", fmt.Errorf("could not start RPC api: %w", err)
+		}
+		engineListener, engineAddr = node.ServeHTTPEndpoint(listener, httpEndpointCfg, engineApiHandler), &net.UnixAddr{Name: socketPath, Net: "unix"}
+	} else if engineListener, engineAddr, err = node.StartHTTPEndpoint(engineHttpEndpoint, httpEndpointCfg, engineApiHandler); err != nil {
 		return nil, nil, "", fmt.Errorf("could not start RPC api: %w", err)
 	}
 
-	engineInfo := []interface{}{"url", engineAddr, "ws", true, "ws.compression", cfg.WebsocketCompression}
+	engineInfo := []interface{}{"url", engineAddr, "ws", cfg.WebsocketEnabled, "ws.compression", cfg.WebsocketCompression}
 	logger.Info("HTTP endpoint opened for Engine API", engineInfo...)
 
 	return engineListener, engineSrv, engineAddr.String(), nil
 }
 
+// createEngineIPCListener - serves Engine API over IPC (plain JSON-RPC stream, not HTTP) if it's enabled.
+// JWT is checked once per connection: see rpc.Server.ServeAuthenticatedListener.
+func createEngineIPCListener(cfg *httpcfg.HttpCfg, engineSrv *rpc.Server, logger log.Logger) (net.Listener, error) {
+	if cfg.AuthRpcIPCPath == "" {
+		return nil, nil
+	}
+	jwtSecret, err := ObtainJWTSecret(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	listener, err := listenPrivateUnixSocket(cfg.AuthRpcIPCPath)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		if err := engineSrv.ServeAuthenticatedListener(listener, jwtSecret); err != nil && !errors.Is(err, net.ErrClosed) {
+			logger.Error("Engine IPC Listener Fatal Error", "err", err)
+		}
+	}()
+	logger.Info("IPC endpoint opened for Engine API", "path", cfg.AuthRpcIPCPath)
+	return listener, nil
+}
+
+// unixSocketPath - returns path of socket file or empty string for non-unix endpoints.
+func unixSocketPath(endpoint string) (string, error) {
+	socketUrl, err := url.Parse(endpoint)
+	if err != nil {
+		return "", fmt.Errorf("malformatted listen url %s: %w", endpoint, err)
+	}
+	if socketUrl.Scheme != "unix" {
+		return "", nil
+	}
+	return socketUrl.Host + socketUrl.EscapedPath(), nil
+}
+
+// listenPrivateUnixSocket - listens on unix socket which only current OS user can connect to.
+// Socket is created inside of new 0700 directory, gets 0600 permissions there and only then is moved to `path`:
+// there is no moment when other users can connect to it.
+func listenPrivateUnixSocket(path string) (net.Listener, error) {
+	if err := removeStaleUnixSocket(path); err != nil {
+		return nil, err
+	}
+	tmpDir, err := os.MkdirTemp(filepath.Dir(path), ".sock-") // 0700
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	tmpPath := filepath.Join(tmpDir, filepath.Base(path))
+	listener, err := net.ListenUnix("unix", &net.UnixAddr{Name: tmpPath, Net: "unix"})
+	if err != nil {
+		return nil, err
+	}
+	listener.SetUnlinkOnClose(false) // socket file is moved: privateUnixListener.Close unlinks it
+	if err = os.Chmod(tmpPath, 0600); err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = listener.Close()
+		return nil, err
+	}
+	return &privateUnixListener{UnixListener: listener, path: path}, nil
+}
+
+type privateUnixListener struct {
+	*net.UnixListener
+	path string
+}
+
+func (l *privateUnixListener) Close() error {
+	err := l.UnixListener.Close()
+	_ = os.Remove(l.path)
+	return err
+}
+
+// removeStaleUnixSocket - removes socket file left by previous (not gracefully stopped) run.
+func removeStaleUnixSocket(path string) error {
+	fi, err := os.Lstat(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	if fi.Mode()&os.ModeSocket == 0 {
+		return fmt.Errorf("can't listen on %s: file exists and is not a socket", path)
+	}
+	return os.Remove(path)
+}
+
 var remoteConsensusEngineNotReadyErr = errors.New("remote consensus engine not ready")
 
 type remoteConsensusEngine struct {
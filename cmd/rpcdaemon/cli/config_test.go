@@ -17,10 +17,24 @@
 package cli
 
 import (
+	"context"
+	"io"
+	"net"
+	"net/http"
 	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/gorilla/websocket"
 	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon/cmd/rpcdaemon/cli/httpcfg"
+	"github.com/erigontech/erigon/rpc"
 )
 
 func TestParseSocketUrl(t *testing.T) {
@@ -35,3 +49,152 @@ func TestParseSocketUrl(t *testing.T) {
 		require.EqualValues(t, "localhost:1234", socketUrl.Host+socketUrl.EscapedPath())
 	})
 }
+
+type testEngineService struct{}
+
+func (testEngineService) Ping() string { return "pong" }
+
+func TestEngineListenerUnixSocket(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "engine.sock")
+	require.NoError(t, os.WriteFile(socketPath, nil, 0600)) // not a socket: must not be removed
+	cfg := &httpcfg.HttpCfg{
+		AuthRpcURL:       "unix://" + socketPath,
+		JWTSecretPath:    filepath.Join(dir, "jwt.hex"),
+		WebsocketEnabled: true,
+	}
+	apis := []rpc.API{{Namespace: "engine", Public: true, Service: testEngineService{}, Version: "1.0"}}
+	_, _, _, err := createEngineListener(cfg, apis, log.New())
+	require.ErrorContains(t, err, "not a socket")
+	require.NoError(t, os.Remove(socketPath))
+
+	// stale socket of previous run
+	l, err := net.Listen("unix", socketPath)
+	require.NoError(t, err)
+	l.(*net.UnixListener).SetUnlinkOnClose(false)
+	require.NoError(t, l.Close())
+
+	listener, srv, _, err := createEngineListener(cfg, apis, log.New())
+	require.NoError(t, err)
+	defer srv.Stop()
+	defer listener.Close()
+
+	fi, err := os.Stat(socketPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+
+	jwtSecret, err := ObtainJWTSecret(cfg, log.New())
+	require.NoError(t, err)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": time.Now().Unix()}).SignedString(jwtSecret)
+	require.NoError(t, err)
+	dial := func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+	}
+
+	t.Run("http", func(t *testing.T) {
+		client := &http.Client{Transport: &http.Transport{DialContext: dial}}
+		post := func(token string) *http.Response {
+			req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1/", strings.NewReader(`{"jsonrpc":"2.0","id":1,"method":"engine_ping"}`))
+			require.NoError(t, err)
+			req.Header.Set("Content-Type", "application/json")
+			if token != "" {
+				req.Header.Set("Authorization", "Bearer "+token)
+			}
+			resp, err := client.Do(req)
+			require.NoError(t, err)
+			resp.Body.Close()
+			return resp
+		}
+		require.Equal(t, http.StatusForbidden, post("").StatusCode)
+		require.Equal(t, http.StatusOK, post(token).StatusCode)
+	})
+
+	t.Run("ws", func(t *testing.T) {
+		dialer := websocket.Dialer{NetDialContext: dial}
+		_, resp, err := dialer.Dial("ws://127.0.0.1/", nil)
+		require.Error(t, err)
+		require.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+		conn, _, err := dialer.Dial("ws://127.0.0.1/", http.Header{"Authorization": []string{"Bearer " + token}})
+		require.NoError(t, err)
+		defer conn.Close()
+		require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte(`{"jsonrpc":"2.0","id":1,"method":"engine_ping"}`)))
+		_, msg, err := conn.ReadMessage()
+		require.NoError(t, err)
+		require.Contains(t, string(msg), `"result":"pong"`)
+	})
+}
+
+func TestEngineListenerWebsocketDisabled(t *testing.T) {
+	dir := t.TempDir()
+	socketPath := filepath.Join(dir, "engine.sock")
+	cfg := &httpcfg.HttpCfg{AuthRpcURL: "unix://" + socketPath, JWTSecretPath: filepath.Join(dir, "jwt.hex")}
+	apis := []rpc.API{{Namespace: "engine", Public: true, Service: testEngineService{}, Version: "1.0"}}
+	listener, srv, _, err := createEngineListener(cfg, apis, log.New())
+	require.NoError(t, err)
+	defer srv.Stop()
+	defer listener.Close()
+
+	jwtSecret, err := ObtainJWTSecret(cfg, log.New())
+	require.NoError(t, err)
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": time.Now().Unix()}).SignedString(jwtSecret)
+	require.NoError(t, err)
+	dialer := websocket.Dialer{NetDialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return (&net.Dialer{}).DialContext(ctx, "unix", socketPath)
+	}}
+	_, _, err = dialer.Dial("ws://127.0.0.1/", http.Header{"Authorization": []string{"Bearer " + token}})
+	require.Error(t, err)
+}
+
+func TestEngineIPCListener(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &httpcfg.HttpCfg{AuthRpcIPCPath: filepath.Join(dir, "engine.ipc"), JWTSecretPath: filepath.Join(dir, "jwt.hex")}
+	srv := rpc.NewServer(1, false, false, true, log.New(), 0)
+	defer srv.Stop()
+	require.NoError(t, srv.RegisterName("engine", testEngineService{}))
+	listener, err := createEngineIPCListener(cfg, srv, log.New())
+	require.NoError(t, err)
+
+	fi, err := os.Stat(cfg.AuthRpcIPCPath)
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0600), fi.Mode().Perm())
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	require.Len(t, entries, 2) // socket and jwt.hex: no temporary directory left
+
+	jwtSecret, err := ObtainJWTSecret(cfg, log.New())
+	require.NoError(t, err)
+	ctx := context.Background()
+
+	t.Run("authenticated", func(t *testing.T) {
+		client, err := rpc.DialIPCWithJWT(ctx, cfg.AuthRpcIPCPath, jwtSecret, log.New())
+		require.NoError(t, err)
+		defer client.Close()
+		var res string
+		require.NoError(t, client.CallContext(ctx, &res, "engine_ping"))
+		require.Equal(t, "pong", res)
+	})
+
+	t.Run("wrong secret", func(t *testing.T) {
+		client, err := rpc.DialIPCWithJWT(ctx, cfg.AuthRpcIPCPath, make([]byte, 32), log.New())
+		require.NoError(t, err)
+		defer client.Close()
+		var res string
+		require.Error(t, client.CallContext(ctx, &res, "engine_ping"))
+	})
+
+	t.Run("no handshake", func(t *testing.T) {
+		conn, err := net.Dial("unix", cfg.AuthRpcIPCPath)
+		require.NoError(t, err)
+		defer conn.Close()
+		_, err = conn.Write([]byte(`{"jsonrpc":"2.0","id":1,"method":"engine_ping"}`))
+		require.NoError(t, err)
+		msg, err := io.ReadAll(conn)
+		require.NoError(t, err)
+		require.Contains(t, string(msg), "authentication failed: missing token")
+	})
+
+	require.NoError(t, listener.Close())
+	_, err = os.Stat(cfg.AuthRpcIPCPath)
+	require.ErrorIs(t, err, os.ErrNotExist)
+}
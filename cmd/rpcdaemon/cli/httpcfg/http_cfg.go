@@ -19,6 +19,8 @@ package httpcfg
 import (
 	"time"
 
+	"github.com/c2h5oh/datasize"
+
 	"github.com/erigontech/erigon/turbo/rpchelper"
 
 	"github.com/erigontech/erigon-lib/common/datadir"
@@ -35,6 +37,8 @@ type HttpCfg struct {
 	DataDir                  string
 	Dirs                     datadir.Dirs
 	AuthRpcHTTPListenAddress string
+	AuthRpcURL               string // overrides AuthRpcHTTPListenAddress and AuthRpcPort, e.g. unix:///path/engine.sock
+	AuthRpcIPCPath           string // unix socket of Engine API over IPC, empty - disabled
 	TLSCertfile              string
 	TLSCACert                string
 	TLSKeyFile               string
@@ -99,10 +103,12 @@ type HttpCfg struct {
 	LogDirVerbosity string
 	LogDirPath      string
 
-	BatchLimit                  int  // Maximum number of requests in a batch
-	ReturnDataLimit             int  // Maximum number of bytes returned from calls (like eth_call)
-	AllowUnprotectedTxs         bool // Whether to allow non EIP-155 protected transactions  txs over RPC
-	MaxGetProofRewindBlockCount int  //Max GetProof rewind block count
+	BatchLimit                  int               // Maximum number of requests in a batch
+	ReturnDataLimit             int               // Maximum number of bytes returned from calls (like eth_call)
+	ResultCacheSize             datasize.ByteSize // RAM for cached results of requests about finalized blocks. 0 - disabled
+	ResultCacheDir              string            // If set - cached results also persisted to this dir
+	AllowUnprotectedTxs         bool              // Whether to allow non EIP-155 protected transactions  txs over RPC
+	MaxGetProofRewindBlockCount int               //Max GetProof rewind block count
 	// Ots API
 	OtsMaxPageSize uint64
 
@@ -452,6 +452,45 @@ func main() {
 	}
 	with(replayCmd, withErigonUrl, withRecord)
 
+	var requestsFile, corpusFile, normalizationFile, reportFile string
+	withCorpus := func(cmd *cobra.Command) {
+		cmd.Flags().StringVar(&corpusFile, "corpus", "", "File of recorded requests and responses, one JSON object per line")
+		must(cmd.MarkFlagRequired("corpus"))
+	}
+	var recordCorpusCmd = &cobra.Command{
+		Use:   "recordCorpus",
+		Short: "Records responses of reference node (--gethUrl) to requests of --requests file",
+		Long:  `Requests file has one JSON-RPC request per line, lines starting with # are skipped`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return rpctest.RecordCorpus(gethURL, requestsFile, corpusFile)
+		},
+	}
+	with(recordCorpusCmd, withGethUrl, withCorpus)
+	recordCorpusCmd.Flags().StringVar(&requestsFile, "requests", "", "File of requests to record, one JSON-RPC request per line")
+	must(recordCorpusCmd.MarkFlagRequired("requests"))
+
+	var replayCorpusCmd = &cobra.Command{
+		Use:   "replayCorpus",
+		Short: "Replays recorded corpus against Erigon and reports differences of responses",
+		Long: `Normalization file is JSON object:
+{"hexCase": true, "hexQuantities": true, "quantityFields": ["myField"], "unorderedArrays": ["result.logs"],
+ "allow": [{"method": "eth_getBlockByNumber", "path": "result.totalDifficulty", "reason": "..."}]}
+Command fails if there are differences, which aren't allowed, or failed requests`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			report, err := rpctest.ReplayCorpus(erigonURL, corpusFile, normalizationFile, reportFile)
+			if err != nil {
+				return err
+			}
+			if report.Failed() {
+				return fmt.Errorf("%d responses differ, %d requests failed", report.Diff, report.Error)
+			}
+			return nil
+		},
+	}
+	with(replayCorpusCmd, withErigonUrl, withCorpus)
+	replayCorpusCmd.Flags().StringVar(&normalizationFile, "normalization", "", "JSON file of normalization rules and known divergences")
+	replayCorpusCmd.Flags().StringVar(&reportFile, "report", "", "File where to write JSON report of differences")
+
 	var tmpDataDir, tmpDataDirOrig string
 	var notRegenerateGethData bool
 	var compareAccountRange = &cobra.Command{
@@ -504,6 +543,8 @@ func main() {
 		benchEthGetBalanceCmd,
 		benchOtsGetBlockTransactions,
 		replayCmd,
+		recordCorpusCmd,
+		replayCorpusCmd,
 	)
 	if err := rootCmd.ExecuteContext(rootContext()); err != nil {
 		fmt.Println(err)
@@ -511,6 +552,12 @@ func main() {
 	}
 }
 
+func must(err error) {
+	if err != nil {
+		panic(err)
+	}
+}
+
 func rootContext() context.Context {
 	ctx, cancel := context.WithCancel(context.Background())
 	go func() {
@@ -0,0 +1,220 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// CorpusEntry - request and response of reference node, corpus file has one entry per line
+type CorpusEntry struct {
+	Request  json.RawMessage `json:"request"`
+	Response json.RawMessage `json:"response"`
+}
+
+// RecordCorpus - sends requests of requestsFile (one JSON-RPC request per line, lines starting with # are skipped)
+// to the reference node and writes them with its responses to corpusFile
+func RecordCorpus(referenceURL, requestsFile, corpusFile string) error {
+	setRoutes("", referenceURL)
+	reqGen := &RequestGenerator{client: &http.Client{Timeout: time.Second * 600}}
+	in, err := os.Open(requestsFile)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+	out, err := os.Create(corpusFile)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+	w := bufio.NewWriter(out)
+	s := bufio.NewScanner(in)
+	var buf [64 * 1024 * 1024]byte // 64 Mb line buffer
+	s.Buffer(buf[:], len(buf))
+	var count int
+	for s.Scan() {
+		request := strings.TrimSpace(s.Text())
+		if request == "" || strings.HasPrefix(request, "#") {
+			continue
+		}
+		if !json.Valid([]byte(request)) {
+			return fmt.Errorf("invalid request %s", request)
+		}
+		res := reqGen.Geth2("", request)
+		if res.Err != nil {
+			return fmt.Errorf("could not record %s: %w", request, res.Err)
+		}
+		line, err := json.Marshal(CorpusEntry{Request: json.RawMessage(request), Response: bytes.TrimSpace(res.Response)})
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(append(line, '\n')); err != nil {
+			return err
+		}
+		count++
+	}
+	if err := s.Err(); err != nil {
+		return err
+	}
+	fmt.Printf("Recorded %d requests to %s\n", count, corpusFile)
+	return w.Flush()
+}
+
+const (
+	ReplayMatch   = "match"
+	ReplayAllowed = "allowed" // only known divergences
+	ReplayDiff    = "diff"
+	ReplayError   = "error" // request failed
+)
+
+// ReplayReport - machine-readable result of corpus replay
+type ReplayReport struct {
+	Total   int            `json:"total"`
+	Match   int            `json:"match"`
+	Allowed int            `json:"allowed"`
+	Diff    int            `json:"diff"`
+	Error   int            `json:"error"`
+	Entries []ReplayResult `json:"entries"` // all but matching ones
+}
+
+type ReplayResult struct {
+	Line    int             `json:"line"`
+	Method  string          `json:"method"`
+	Request json.RawMessage `json:"request"`
+	Status  string          `json:"status"`
+	Error   string          `json:"error,omitempty"`
+	Diffs   []JsonDiff      `json:"diffs,omitempty"`
+}
+
+func (r *ReplayReport) add(res ReplayResult) {
+	r.Total++
+	switch res.Status {
+	case ReplayMatch:
+		r.Match++
+		return
+	case ReplayAllowed:
+		r.Allowed++
+	case ReplayDiff:
+		r.Diff++
+	case ReplayError:
+		r.Error++
+	}
+	r.Entries = append(r.Entries, res)
+}
+
+func (r *ReplayReport) Failed() bool { return r.Diff > 0 || r.Error > 0 }
+
+// ReplayCorpus - replays corpus against Erigon and compares normalized responses with the recorded ones.
+// Fields "id" and "jsonrpc" of responses aren't compared. Report is written to reportFile, if it's set.
+func ReplayCorpus(erigonURL, corpusFile, normalizationFile, reportFile string) (*ReplayReport, error) {
+	setRoutes(erigonURL, "")
+	reqGen := &RequestGenerator{client: &http.Client{Timeout: time.Second * 600}}
+	n, err := ReadNormalization(normalizationFile)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(corpusFile)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	var buf [64 * 1024 * 1024]byte // 64 Mb line buffer
+	s.Buffer(buf[:], len(buf))
+
+	report := &ReplayReport{Entries: []ReplayResult{}}
+	for line := 1; s.Scan(); line++ {
+		if len(bytes.TrimSpace(s.Bytes())) == 0 {
+			continue
+		}
+		var entry CorpusEntry
+		if err := json.Unmarshal(s.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("corpus %s line %d: %w", corpusFile, line, err)
+		}
+		report.add(replayEntry(reqGen, n, line, entry))
+	}
+	if err := s.Err(); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Replayed %d requests: %d match, %d allowed, %d diff, %d error\n", report.Total, report.Match, report.Allowed, report.Diff, report.Error)
+
+	if reportFile != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := os.WriteFile(reportFile, data, 0644); err != nil {
+			return nil, err
+		}
+	}
+	return report, nil
+}
+
+func replayEntry(reqGen *RequestGenerator, n *Normalization, line int, entry CorpusEntry) ReplayResult {
+	var request struct {
+		Method string `json:"method"`
+	}
+	_ = json.Unmarshal(entry.Request, &request)
+	res := ReplayResult{Line: line, Method: request.Method, Request: entry.Request}
+
+	call := reqGen.Erigon2(request.Method, string(entry.Request))
+	if call.Err != nil {
+		res.Status, res.Error = ReplayError, call.Err.Error()
+		return res
+	}
+	expected, err := decodeResponse(entry.Response)
+	if err != nil {
+		res.Status, res.Error = ReplayError, fmt.Sprintf("recorded response: %v", err)
+		return res
+	}
+	actual, err := decodeResponse(call.Response)
+	if err != nil {
+		res.Status, res.Error = ReplayError, fmt.Sprintf("response: %v", err)
+		return res
+	}
+
+	res.Diffs = n.Diff(request.Method, "", expected, actual)
+	res.Status = ReplayMatch
+	for _, d := range res.Diffs {
+		if d.Allowed == "" {
+			res.Status = ReplayDiff
+			break
+		}
+		res.Status = ReplayAllowed
+	}
+	return res
+}
+
+// decodeResponse - response without "id" and "jsonrpc", numbers are kept as they are
+func decodeResponse(data []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var res map[string]any
+	if err := dec.Decode(&res); err != nil {
+		return nil, err
+	}
+	delete(res, "id")
+	delete(res, "jsonrpc")
+	return res, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctest
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// rpcStub - responds to requests by method
+func rpcStub(t *testing.T, responses map[string]string) *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		var req struct {
+			Method string `json:"method"`
+		}
+		require.NoError(t, json.Unmarshal(body, &req))
+		_, _ = w.Write([]byte(responses[req.Method]))
+	}))
+}
+
+func TestRecordReplayCorpus(t *testing.T) {
+	dir := t.TempDir()
+	requests := filepath.Join(dir, "requests.txt")
+	require.NoError(t, os.WriteFile(requests, []byte(`# corpus
+{"jsonrpc":"2.0","method":"eth_blockNumber","params":[],"id":1}
+{"jsonrpc":"2.0","method":"eth_getBlockByNumber","params":["0x1",false],"id":2}
+{"jsonrpc":"2.0","method":"eth_getLogs","params":[{}],"id":3}
+{"jsonrpc":"2.0","method":"eth_call","params":[],"id":4}
+`), 0644))
+
+	reference := rpcStub(t, map[string]string{
+		"eth_blockNumber":      `{"jsonrpc":"2.0","id":1,"result":"0x0a"}`,
+		"eth_getBlockByNumber": `{"jsonrpc":"2.0","id":2,"result":{"hash":"0xABCD","number":"0x1","totalDifficulty":"0x5","transactions":[]}}`,
+		"eth_getLogs":          `{"jsonrpc":"2.0","id":3,"result":[{"logIndex":"0x0"},{"logIndex":"0x1"}]}`,
+		"eth_call":             `{"jsonrpc":"2.0","id":4,"error":{"code":-32000,"message":"execution reverted"}}`,
+	})
+	defer reference.Close()
+	corpus := filepath.Join(dir, "corpus.jsonl")
+	require.NoError(t, RecordCorpus(reference.URL, requests, corpus))
+
+	erigon := rpcStub(t, map[string]string{
+		"eth_blockNumber":      `{"id":1,"jsonrpc":"2.0","result":"0xa"}`,
+		"eth_getBlockByNumber": `{"jsonrpc":"2.0","id":2,"result":{"transactions":[],"number":"0x1","hash":"0xabcd"}}`,
+		"eth_getLogs":          `{"jsonrpc":"2.0","id":3,"result":[{"logIndex":"0x1"},{"logIndex":"0x0"}]}`,
+		"eth_call":             `{"jsonrpc":"2.0","id":4,"error":{"code":3,"message":"execution reverted"}}`,
+	})
+	defer erigon.Close()
+
+	report, err := ReplayCorpus(erigon.URL, corpus, "", "")
+	require.NoError(t, err)
+	require.Equal(t, 4, report.Total)
+	require.Equal(t, 4, report.Diff)
+	require.True(t, report.Failed())
+
+	normalization := filepath.Join(dir, "normalization.json")
+	require.NoError(t, os.WriteFile(normalization, []byte(`{
+		"hexCase": true,
+		"hexQuantities": true,
+		"unorderedArrays": ["result"],
+		"allow": [{"method": "eth_getBlockByNumber", "path": "result.totalDifficulty", "reason": "removed after the merge"}]
+	}`), 0644))
+	reportFile := filepath.Join(dir, "report.json")
+	report, err = ReplayCorpus(erigon.URL, corpus, normalization, reportFile)
+	require.NoError(t, err)
+	require.Equal(t, 2, report.Match)
+	require.Equal(t, 1, report.Allowed)
+	require.Equal(t, 1, report.Diff)
+
+	data, err := os.ReadFile(reportFile)
+	require.NoError(t, err)
+	var written ReplayReport
+	require.NoError(t, json.Unmarshal(data, &written))
+	require.Len(t, written.Entries, 2)
+	require.Equal(t, "eth_getBlockByNumber", written.Entries[0].Method)
+	require.Equal(t, ReplayAllowed, written.Entries[0].Status)
+	require.Equal(t, []JsonDiff{{Path: "result.totalDifficulty", Expected: "0x5", Actual: nil, Allowed: "removed after the merge"}}, written.Entries[0].Diffs)
+	require.Equal(t, ReplayDiff, written.Entries[1].Status)
+	require.Equal(t, "error.code", written.Entries[1].Diffs[0].Path)
+}
+
+func TestNormalizationHexQuantities(t *testing.T) {
+	n := &Normalization{HexQuantities: true, QuantityFields: []string{"custom"}}
+	expected := map[string]any{"result": map[string]any{
+		"number": "0x01", "baseFeePerGas": []any{"0x0a", "0x0"}, "custom": "0x002",
+		"hash": "0x00ab", "logsBloom": "0x0001", "input": "0x00",
+	}}
+	actual := map[string]any{"result": map[string]any{
+		"number": "0x1", "baseFeePerGas": []any{"0xa", "0x0"}, "custom": "0x2",
+		"hash": "0xab", "logsBloom": "0x01", "input": "0x00",
+	}}
+	diffs := n.Diff("eth_getBlockByNumber", "", expected, actual)
+	require.Equal(t, []JsonDiff{
+		{Path: "result.hash", Expected: "0x00ab", Actual: "0xab"},
+		{Path: "result.logsBloom", Expected: "0x0001", Actual: "0x01"},
+	}, diffs, "leading zeros of data are significant")
+
+	require.Empty(t, n.Diff("eth_blockNumber", "", map[string]any{"result": "0x0a"}, map[string]any{"result": "0xa"}))
+	require.Len(t, n.Diff("eth_call", "", map[string]any{"result": "0x0a"}, map[string]any{"result": "0xa"}), 1)
+}
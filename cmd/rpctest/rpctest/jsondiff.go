@@ -0,0 +1,251 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package rpctest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Normalization - how responses are normalized before comparison, it's read from JSON file.
+// Order of object fields never matters.
+type Normalization struct {
+	HexCase         bool              `json:"hexCase"`         // compare hex strings case-insensitively
+	HexQuantities   bool              `json:"hexQuantities"`   // "0x01" equals "0x1" in quantity fields, see quantityFields
+	QuantityFields  []string          `json:"quantityFields"`  // names of quantity fields in addition to the known ones
+	UnorderedArrays []string          `json:"unorderedArrays"` // paths of arrays which are compared as sets, e.g. "result.logs"
+	Allow           []KnownDivergence `json:"allow"`
+}
+
+// KnownDivergence - difference which is reported, but doesn't fail replay
+type KnownDivergence struct {
+	Method string `json:"method"` // empty - any method
+	Path   string `json:"path"`   // value at path and below it, "[*]" matches any array index, e.g. "result.transactions[*].v"
+	Reason string `json:"reason"`
+}
+
+func ReadNormalization(file string) (*Normalization, error) {
+	n := &Normalization{}
+	if file == "" {
+		return n, nil
+	}
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(n); err != nil {
+		return nil, fmt.Errorf("normalization %s: %w", file, err)
+	}
+	return n, nil
+}
+
+// JsonDiff - value at Path differs: Expected is reference's one, Actual is Erigon's one. Missing values are nil.
+type JsonDiff struct {
+	Path     string `json:"path"`
+	Expected any    `json:"expected"`
+	Actual   any    `json:"actual"`
+	Allowed  string `json:"allowed,omitempty"` // reason of known divergence
+}
+
+var arrayIndex = regexp.MustCompile(`\[\d+\]`)
+
+// quantityFields - JSON-RPC fields of blocks, transactions, receipts, logs, proofs and traces which are hex quantities.
+// Leading zeros of other hex strings are significant: they are byte arrays.
+var quantityFields = map[string]bool{
+	"amount": true, "balance": true, "baseFeePerBlobGas": true, "baseFeePerGas": true, "blobGasPrice": true,
+	"blobGasUsed": true, "blockNumber": true, "chainId": true, "cumulativeGasUsed": true, "currentBlock": true,
+	"difficulty": true, "effectiveGasPrice": true, "excessBlobGas": true, "gas": true, "gasLimit": true,
+	"gasPrice": true, "gasUsed": true, "highestBlock": true, "index": true, "logIndex": true, "maxFeePerBlobGas": true,
+	"maxFeePerGas": true, "maxPriorityFeePerGas": true, "nonce": true, "number": true, "oldestBlock": true, "r": true,
+	"reward": true, "s": true, "size": true, "startingBlock": true, "status": true, "timestamp": true,
+	"totalDifficulty": true, "transactionIndex": true, "type": true, "v": true, "validatorIndex": true, "value": true,
+	"yParity": true,
+}
+
+// quantityMethods - methods which result is a hex quantity, it's the `result` field of response
+var quantityMethods = map[string]bool{
+	"eth_blobBaseFee": true, "eth_blockNumber": true, "eth_chainId": true, "eth_estimateGas": true, "eth_gasPrice": true,
+	"eth_getBalance": true, "eth_getBlockTransactionCountByHash": true, "eth_getBlockTransactionCountByNumber": true,
+	"eth_getTransactionCount": true, "eth_getUncleCountByBlockHash": true, "eth_getUncleCountByBlockNumber": true,
+	"eth_maxPriorityFeePerGas": true, "eth_protocolVersion": true, "net_peerCount": true,
+}
+
+func (n *Normalization) quantityField(name string) bool {
+	if quantityFields[name] {
+		return true
+	}
+	for _, f := range n.QuantityFields {
+		if f == name {
+			return true
+		}
+	}
+	return false
+}
+
+// allowed - reason of known divergence of method at path, "" if it's not known
+func (n *Normalization) allowed(method, path string) string {
+	generic := arrayIndex.ReplaceAllString(path, "[*]")
+	for _, a := range n.Allow {
+		if a.Method != "" && a.Method != method {
+			continue
+		}
+		if generic == a.Path || strings.HasPrefix(generic, a.Path+".") || strings.HasPrefix(generic, a.Path+"[") {
+			return a.Reason
+		}
+	}
+	return ""
+}
+
+func (n *Normalization) unordered(path string) bool {
+	generic := arrayIndex.ReplaceAllString(path, "[*]")
+	for _, p := range n.UnorderedArrays {
+		if p == generic {
+			return true
+		}
+	}
+	return false
+}
+
+// normalize - value with hex strings normalized and unordered arrays sorted. Quantity is whether v is a quantity
+// field, or an array of them.
+func (n *Normalization) normalize(method, path string, v any, quantity bool) any {
+	switch v := v.(type) {
+	case map[string]any:
+		res := make(map[string]any, len(v))
+		for k, item := range v {
+			q := n.quantityField(k) || path == "" && k == "result" && quantityMethods[method]
+			res[k] = n.normalize(method, field(path, k), item, q)
+		}
+		return res
+	case []any:
+		res := make([]any, len(v))
+		for i, item := range v {
+			res[i] = n.normalize(method, fmt.Sprintf("%s[%d]", path, i), item, quantity)
+		}
+		if n.unordered(path) {
+			keys := make([]string, len(res))
+			for i, item := range res {
+				keys[i] = canonical(item)
+			}
+			sort.Sort(byKeys{keys, res})
+		}
+		return res
+	case string:
+		if !strings.HasPrefix(v, "0x") && !strings.HasPrefix(v, "0X") {
+			return v
+		}
+		digits := v[2:]
+		if n.HexCase {
+			digits = strings.ToLower(digits)
+		}
+		if n.HexQuantities && quantity && len(digits) > 0 && len(digits) <= 64 && isHex(digits) {
+			if digits = strings.TrimLeft(digits, "0"); digits == "" {
+				digits = "0"
+			}
+		}
+		return "0x" + digits
+	default:
+		return v
+	}
+}
+
+func field(path, key string) string {
+	if path == "" {
+		return key
+	}
+	return path + "." + key
+}
+
+type byKeys struct {
+	keys  []string
+	items []any
+}
+
+func (s byKeys) Len() int           { return len(s.keys) }
+func (s byKeys) Less(i, j int) bool { return s.keys[i] < s.keys[j] }
+func (s byKeys) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.items[i], s.items[j] = s.items[j], s.items[i]
+}
+
+func isHex(s string) bool {
+	for _, c := range s {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f' || c >= 'A' && c <= 'F') {
+			return false
+		}
+	}
+	return true
+}
+
+// canonical - JSON with sorted object fields
+func canonical(v any) string {
+	b, _ := json.Marshal(v) // maps are marshalled with sorted keys
+	return string(b)
+}
+
+// Diff - differences of normalized values, known divergences of method are marked as allowed
+func (n *Normalization) Diff(method, path string, expected, actual any) []JsonDiff {
+	var diffs []JsonDiff
+	n.diff(path, n.normalize(method, path, expected, false), n.normalize(method, path, actual, false), &diffs)
+	for i := range diffs {
+		diffs[i].Allowed = n.allowed(method, diffs[i].Path)
+	}
+	return diffs
+}
+
+func (n *Normalization) diff(path string, expected, actual any, diffs *[]JsonDiff) {
+	switch e := expected.(type) {
+	case map[string]any:
+		a, ok := actual.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(e)+len(a))
+		for k := range e {
+			keys = append(keys, k)
+		}
+		for k := range a {
+			if _, ok := e[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			n.diff(field(path, k), e[k], a[k], diffs)
+		}
+		return
+	case []any:
+		a, ok := actual.([]any)
+		if !ok || len(a) != len(e) {
+			break
+		}
+		for i := range e {
+			n.diff(fmt.Sprintf("%s[%d]", path, i), e[i], a[i], diffs)
+		}
+		return
+	}
+	if canonical(expected) != canonical(actual) {
+		*diffs = append(*diffs, JsonDiff{Path: path, Expected: expected, Actual: actual})
+	}
+}
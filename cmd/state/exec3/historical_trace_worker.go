@@ -74,7 +74,8 @@ type HistoricalTraceWorker struct {
 }
 
 type TraceConsumer struct {
-	NewTracer func() GenericTracer
+	//NewTracer attached to execution of transaction, nil - transaction isn't traced. Task may be executed more than once.
+	NewTracer func(txTask *state.TxTask) GenericTracer
 	//Reduce receiving results of execution. They are sorted and have no gaps.
 	Reduce func(task *state.TxTask, tx kv.Tx) error
 }
@@ -180,8 +181,13 @@ func (rw *HistoricalTraceWorker) RunTxTask(txTask *state.TxTask) {
 	default:
 		txHash := txTask.Tx.Hash()
 		rw.taskGasPool.Reset(txTask.Tx.GetGas(), rw.execArgs.ChainConfig.GetMaxBlobGasPerBlock())
-		if tracer := rw.consumer.NewTracer(); tracer != nil {
-			rw.vmConfig.Debug = true
+		var tracer GenericTracer
+		if rw.consumer.NewTracer != nil {
+			tracer = rw.consumer.NewTracer(txTask)
+		}
+		rw.vmConfig.Debug = tracer != nil
+		rw.vmConfig.Tracer = nil
+		if tracer != nil {
 			rw.vmConfig.Tracer = tracer
 		}
 		rw.vmConfig.SkipAnalysis = txTask.SkipAnalysis
@@ -209,7 +215,6 @@ func (rw *HistoricalTraceWorker) RunTxTask(txTask *state.TxTask) {
 			ibs.SoftFinalise()
 			txTask.Logs = ibs.GetLogs(txHash)
 		}
-		//txTask.Tracer = tracer
 	}
 }
 func (rw *HistoricalTraceWorker) ResetTx(chainTx kv.Tx) {
@@ -327,7 +332,7 @@ func processResultQueueHistorical(consumer TraceConsumer, rws *state.ResultsQueu
 			applyWorker.RunTxTask(txTask)
 		}
 		if txTask.Error != nil {
-			return outputTxNum, false, err
+			return outputTxNum, false, fmt.Errorf("blockNum=%d, txIndex=%d: %w", txTask.BlockNum, txTask.TxIndex, txTask.Error)
 		}
 		if err := consumer.Reduce(txTask, applyWorker.chainTx); err != nil {
 			return outputTxNum, false, err
@@ -360,6 +365,9 @@ func processResultQueueHistorical(consumer TraceConsumer, rws *state.ResultsQueu
 
 func CustomTraceMapReduce(fromBlock, toBlock uint64, consumer TraceConsumer, ctx context.Context, tx kv.TemporalTx, cfg *ExecArgs, logger log.Logger) (err error) {
 	log.Info("[CustomTraceMapReduce] start", "fromBlock", fromBlock, "toBlock", toBlock, "workers", cfg.Workers)
+	// producer stops adding tasks when workers have exited, e.g. on error of `consumer.Reduce`
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
 	br := cfg.BlockReader
 	chainConfig := cfg.ChainConfig
 	getHeaderFunc := func(hash common.Hash, number uint64) (h *types.Header) {
@@ -404,6 +412,7 @@ func CustomTraceMapReduce(fromBlock, toBlock uint64, consumer TraceConsumer, ctx
 	go func() {
 		workers.Wait()
 		workersExited.Store(true)
+		cancel()
 	}()
 
 	inputTxNum, err := rawdbv3.TxNums.Min(tx, fromBlock)
@@ -22,6 +22,7 @@ package utils
 
 import (
 	"crypto/ecdsa"
+	"encoding/hex"
 	"fmt"
 	"math/big"
 	"path/filepath"
@@ -267,13 +268,30 @@ var (
 	}
 	MinerRecommitIntervalFlag = cli.DurationFlag{
 		Name:  "miner.recommit",
-		Usage: "Time interval to recreate the block being mined",
+		Usage: "Time interval to recreate the block being mined. PoS payloads are rebuilt at most this often on new transactions, until they are requested",
 		Value: ethconfig.Defaults.Miner.Recommit,
 	}
 	MinerNoVerfiyFlag = cli.BoolFlag{
 		Name:  "miner.noverify",
 		Usage: "Disable remote sealing verification",
 	}
+	BuilderRelaysFlag = cli.StringFlag{
+		Name:  "builder.relays",
+		Usage: "Comma separated list of MEV-boost relays to submit built blocks to (Flashbots relay API). Enables block builder. Consensus client must send payload attributes for every slot",
+	}
+	BuilderSecretKeyFlag = cli.StringFlag{
+		Name:  "builder.secretkey",
+		Usage: "Hex encoded BLS secret key of block builder, signs bids to relays",
+	}
+	BuilderIntervalFlag = cli.DurationFlag{
+		Name:  "builder.interval",
+		Usage: "Time interval between re-builds of block for relays within a slot: bid is re-submitted when its value grows",
+		Value: 500 * time.Millisecond,
+	}
+	BuilderGenesisTimeFlag = cli.Uint64Flag{
+		Name:  "builder.genesistime",
+		Usage: "Beacon chain genesis time, for networks which block builder doesn't know",
+	}
 	VMEnableDebugFlag = cli.BoolFlag{
 		Name:  "vmdebug",
 		Usage: "Record information useful for VM and contract debugging",
@@ -346,6 +364,14 @@ var (
 		Usage: "HTTP-RPC server listening port for the Engine API",
 		Value: nodecfg.DefaultAuthRpcPort,
 	}
+	AuthRpcURL = cli.StringFlag{
+		Name:  "authrpc.url",
+		Usage: "Engine API listening url. will OVERRIDE authrpc.addr and authrpc.port. prefix supported are tcp, unix (e.g. unix:///path/engine.sock). Serves HTTP (and WebSocket if --ws), JWT is required on both",
+	}
+	AuthRpcIPCPath = cli.StringFlag{
+		Name:  "authrpc.ipc",
+		Usage: "Path of unix socket to serve Engine API over IPC (JSON-RPC stream, not HTTP). Every connection must start with {\"jwt\":\"<token>\"}. Empty - disabled",
+	}
 
 	JWTSecretPath = cli.StringFlag{
 		Name:  "authrpc.jwtsecret",
@@ -400,6 +426,15 @@ var (
 		Usage: "Maximum number of bytes returned from eth_call or similar invocations",
 		Value: 100_000,
 	}
+	RpcResultCacheSizeFlag = cli.StringFlag{
+		Name:  "rpc.resultcache.size",
+		Usage: "RAM for cached results of requests about finalized blocks (eth_getBlockReceipts, eth_getLogs, trace_block, debug_traceTransaction), e.g. 256MB. 0 - disabled",
+		Value: "0",
+	}
+	RpcResultCacheDirFlag = cli.StringFlag{
+		Name:  "rpc.resultcache.dir",
+		Usage: "Directory to persist results cache (--rpc.resultcache.size) - to survive restarts and evictions from RAM. Default: RAM only",
+	}
 	HTTPTraceFlag = cli.BoolFlag{
 		Name:  "http.trace",
 		Usage: "Print all HTTP requests to logs with INFO level",
@@ -893,6 +928,16 @@ var (
 		Usage: "Comma separated list of support session ids to connect to",
 	}
 
+	ShadowCommitmentFlag = cli.StringFlag{
+		Name:  "experimental.commitment.shadow",
+		Usage: "Compute second commitment (not in consensus) alongside of main one and store it's root per block. Supported: binary",
+	}
+	ShadowCommitmentConversionFlag = cli.Uint64Flag{
+		Name:  "experimental.commitment.shadow.conversion",
+		Usage: "Amount of existing state keys moved to shadow commitment per commitment computation (0 - only touched keys are moved)",
+		Value: 1_000,
+	}
+
 	SilkwormExecutionFlag = cli.BoolFlag{
 		Name:  "silkworm.exec",
 		Usage: "Enable Silkworm block execution",
@@ -1652,6 +1697,16 @@ func setMiner(ctx *cli.Context, cfg *params.MiningConfig) {
 	if ctx.IsSet(MinerNoVerfiyFlag.Name) {
 		cfg.Noverify = ctx.Bool(MinerNoVerfiyFlag.Name)
 	}
+	if ctx.IsSet(BuilderRelaysFlag.Name) {
+		cfg.BuilderRelays = libcommon.CliString2Array(ctx.String(BuilderRelaysFlag.Name))
+		key, err := hex.DecodeString(strings.TrimPrefix(ctx.String(BuilderSecretKeyFlag.Name), "0x"))
+		if err != nil || len(key) == 0 {
+			Fatalf("Option %q is required by %q and must be hex encoded BLS secret key", BuilderSecretKeyFlag.Name, BuilderRelaysFlag.Name)
+		}
+		cfg.BuilderSecretKey = key
+		cfg.BuilderInterval = ctx.Duration(BuilderIntervalFlag.Name)
+		cfg.BuilderGenesisTime = ctx.Uint64(BuilderGenesisTimeFlag.Name)
+	}
 }
 
 func setWhitelist(ctx *cli.Context, cfg *ethconfig.Config) {
@@ -1724,6 +1779,18 @@ func setSilkworm(ctx *cli.Context, cfg *ethconfig.Config) {
 	cfg.SilkwormRpcJsonCompatibility = ctx.Bool(SilkwormRpcJsonCompatibilityFlag.Name)
 }
 
+func setShadowCommitment(ctx *cli.Context, cfg *ethconfig.Config) {
+	switch v := ctx.String(ShadowCommitmentFlag.Name); v {
+	case "", "binary":
+		cfg.ShadowCommitment = v
+	case "verkle":
+		Fatalf("Option %s: verkle shadow commitment is not supported yet, use binary", ShadowCommitmentFlag.Name)
+	default:
+		Fatalf("Option %s: unknown commitment %q", ShadowCommitmentFlag.Name, v)
+	}
+	cfg.ShadowCommitmentConversion = ctx.Uint64(ShadowCommitmentConversionFlag.Name)
+}
+
 // CheckExclusive verifies that only a single instance of the provided flags was
 // set by the user. Each flag might optionally be followed by a string type to
 // specialize it further.
@@ -1840,6 +1907,7 @@ func SetEthConfig(ctx *cli.Context, nodeConfig *nodecfg.Config, cfg *ethconfig.C
 	setWhitelist(ctx, cfg)
 	setBorConfig(ctx, cfg)
 	setSilkworm(ctx, cfg)
+	setShadowCommitment(ctx, cfg)
 	if err := setBeaconAPI(ctx, cfg); err != nil {
 		log.Error("Failed to set beacon API", "err", err)
 	}
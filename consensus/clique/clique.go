@@ -544,6 +544,9 @@ func (c *Clique) APIs(chain consensus.ChainHeaderReader) []rpc.API {
 
 func NewCliqueAPI(db kv.RoDB, engine consensus.EngineReader, blockReader services.FullBlockReader) rpc.API {
 	var c *Clique
+	if wrapper, ok := engine.(interface{ InnerEngine() consensus.Engine }); ok {
+		engine = wrapper.InnerEngine()
+	}
 	if casted, ok := engine.(*Clique); ok {
 		c = casted
 	}
@@ -54,10 +54,11 @@ type BlockGen struct {
 	stateReader state.StateReader
 	ibs         *state.IntraBlockState
 
-	gasPool  *GasPool
-	txs      []types.Transaction
-	receipts []*types.Receipt
-	uncles   []*types.Header
+	gasPool     *GasPool
+	txs         []types.Transaction
+	receipts    []*types.Receipt
+	uncles      []*types.Header
+	withdrawals []*types.Withdrawal
 
 	config *chain.Config
 	engine consensus.Engine
@@ -76,6 +77,9 @@ func (b *BlockGen) SetCoinbase(addr libcommon.Address) {
 	}
 	b.header.Coinbase = addr
 	b.gasPool = new(GasPool).AddGas(b.header.GasLimit)
+	if b.config.IsCancun(b.header.Time) {
+		b.gasPool.AddBlobGas(b.config.GetMaxBlobGasPerBlock())
+	}
 }
 
 // SetExtra sets the extra data field of the generated block.
@@ -185,6 +189,12 @@ func (b *BlockGen) AddUncle(h *types.Header) {
 	b.uncles = append(b.uncles, h)
 }
 
+// AddWithdrawal adds a withdrawal to the generated block.
+// Withdrawals are included only into Shanghai blocks.
+func (b *BlockGen) AddWithdrawal(w *types.Withdrawal) {
+	b.withdrawals = append(b.withdrawals, w)
+}
+
 // PrevBlock returns a previously generated block by number. It panics if
 // num is greater or equal to the number of the block being generated.
 // For index -1, PrevBlock returns the parent block given to GenerateChain.
@@ -356,7 +366,7 @@ func GenerateChain(config *chain.Config, parent *types.Block, engine consensus.E
 			}
 		}
 		if b.engine != nil {
-			err := InitializeBlockExecution(b.engine, nil, b.header, config, ibs, logger, nil)
+			err := InitializeBlockExecution(b.engine, chainreader, b.header, config, ibs, logger, nil)
 			if err != nil {
 				return nil, nil, fmt.Errorf("call to InitializeBlockExecution: %w", err)
 			}
@@ -366,9 +376,13 @@ func GenerateChain(config *chain.Config, parent *types.Block, engine consensus.E
 			gen(i, b)
 		}
 		txNumIncrement()
+		var withdrawals []*types.Withdrawal
+		if config.IsShanghai(b.header.Time) {
+			withdrawals = append([]*types.Withdrawal{}, b.withdrawals...)
+		}
 		if b.engine != nil {
 			// Finalize and seal the block
-			if _, _, _, err := b.engine.FinalizeAndAssemble(config, b.header, ibs, b.txs, b.uncles, b.receipts, nil, nil, nil, nil, nil, logger); err != nil {
+			if _, _, _, err := b.engine.FinalizeAndAssemble(config, b.header, ibs, b.txs, b.uncles, b.receipts, withdrawals, nil, nil, nil, nil, logger); err != nil {
 				return nil, nil, fmt.Errorf("call to FinaliseAndAssemble: %w", err)
 			}
 			// Write state changes to db
@@ -392,7 +406,7 @@ func GenerateChain(config *chain.Config, parent *types.Block, engine consensus.E
 			b.header.Root = libcommon.BytesToHash(stateRoot)
 
 			// Recreating block to make sure Root makes it into the header
-			block := types.NewBlock(b.header, b.txs, b.uncles, b.receipts, nil /* withdrawals */, nil /*requests*/)
+			block := types.NewBlock(b.header, b.txs, b.uncles, b.receipts, withdrawals, nil /*requests*/)
 			return block, b.receipts, nil
 		}
 		return nil, nil, errors.New("no engine to generate blocks")
@@ -605,14 +619,22 @@ func makeHeader(chain consensus.ChainReader, parent *types.Block, state *state.I
 
 	header := MakeEmptyHeader(parent.Header(), chain.Config(), time, nil)
 	header.Coinbase = parent.Coinbase()
-	header.Difficulty = engine.CalcDifficulty(chain, time,
-		time-10,
-		parent.Difficulty(),
-		parent.NumberU64(),
-		parent.Hash(),
-		parent.UncleHash(),
-		parent.Header().AuRaStep,
-	)
+	if chain.Config().TerminalTotalDifficultyPassed {
+		// chain reader doesn't know total difficulty, so engine can't tell PoS blocks
+		header.Difficulty = new(big.Int).Set(merge.ProofOfStakeDifficulty)
+	} else {
+		header.Difficulty = engine.CalcDifficulty(chain, time,
+			time-10,
+			parent.Difficulty(),
+			parent.NumberU64(),
+			parent.Hash(),
+			parent.UncleHash(),
+			parent.Header().AuRaStep,
+		)
+	}
+	if chain.Config().IsCancun(header.Time) {
+		header.ParentBeaconBlockRoot = &libcommon.Hash{}
+	}
 	header.AuRaSeal = engine.GenerateSeal(chain, header, parent.Header(), nil)
 
 	return header
@@ -0,0 +1,51 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"fmt"
+	"math/big"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/cl/merkle_tree"
+	"github.com/erigontech/erigon/cl/utils"
+)
+
+// ComputeAccumulator - SSZ hash_tree_root of `List[HeaderRecord, MaxEra1Size]`,
+// where `HeaderRecord = Container{block_hash: Bytes32, total_difficulty: uint256}`
+func ComputeAccumulator(hashes []common.Hash, tds []*big.Int) (common.Hash, error) {
+	if len(hashes) != len(tds) {
+		return common.Hash{}, fmt.Errorf("era1: accumulator: %d hashes, but %d total difficulties", len(hashes), len(tds))
+	}
+	if len(hashes) > MaxEra1Size {
+		return common.Hash{}, fmt.Errorf("era1: accumulator: too many records: %d", len(hashes))
+	}
+	leaves := make([][32]byte, len(hashes))
+	for i := range hashes {
+		td, err := encodeTd(tds[i])
+		if err != nil {
+			return common.Hash{}, err
+		}
+		leaves[i] = utils.Sha256(hashes[i][:], td)
+	}
+	root, err := merkle_tree.MerkleizeVector(leaves, MaxEra1Size)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	lenLeaf := merkle_tree.Uint64Root(uint64(len(hashes)))
+	return utils.Sha256(root[:], lenLeaf[:]), nil
+}
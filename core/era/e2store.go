@@ -0,0 +1,157 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package era implements history archives of EIP-4444 history distribution: `era1` files of
+// pre-merge execution blocks. Both `era1` and beacon `era` files are e2store files:
+// sequence of entries `type(2 bytes) | length(4 bytes) | reserved(2 bytes) | value`, little-endian.
+package era
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/golang/snappy"
+)
+
+const HeaderSize = 8
+
+const TypeVersion uint16 = 0x3265 // "e2"
+
+// Entry - one record of e2store file
+type Entry struct {
+	Type  uint16
+	Value []byte
+}
+
+type E2Writer struct {
+	w   io.Writer
+	hdr [HeaderSize]byte
+}
+
+func NewE2Writer(w io.Writer) *E2Writer { return &E2Writer{w: w} }
+
+// Write - writes entry, returns amount of written bytes (header included)
+func (w *E2Writer) Write(typ uint16, value []byte) (int, error) {
+	binary.LittleEndian.PutUint16(w.hdr[0:], typ)
+	binary.LittleEndian.PutUint32(w.hdr[2:], uint32(len(value)))
+	binary.LittleEndian.PutUint16(w.hdr[6:], 0)
+	n, err := w.w.Write(w.hdr[:])
+	if err != nil {
+		return n, err
+	}
+	m, err := w.w.Write(value)
+	return n + m, err
+}
+
+type E2Reader struct {
+	r io.ReaderAt
+}
+
+func NewE2Reader(r io.ReaderAt) *E2Reader { return &E2Reader{r: r} }
+
+// ReadAt - reads entry which starts at `off`. Returns entry and it's size (header included).
+func (r *E2Reader) ReadAt(off int64) (*Entry, int64, error) {
+	var hdr [HeaderSize]byte
+	if _, err := r.r.ReadAt(hdr[:], off); err != nil {
+		return nil, 0, err
+	}
+	if reserved := binary.LittleEndian.Uint16(hdr[6:]); reserved != 0 {
+		return nil, 0, fmt.Errorf("e2store: entry at %d: reserved bytes must be 0, got %d", off, reserved)
+	}
+	e := &Entry{Type: binary.LittleEndian.Uint16(hdr[0:]), Value: make([]byte, binary.LittleEndian.Uint32(hdr[2:]))}
+	if _, err := r.r.ReadAt(e.Value, off+HeaderSize); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, fmt.Errorf("e2store: entry at %d: %w", off, io.ErrUnexpectedEOF)
+		}
+		return nil, 0, err
+	}
+	return e, HeaderSize + int64(len(e.Value)), nil
+}
+
+// ReadTypeAt - same as ReadAt, but fails if entry has another type
+func (r *E2Reader) ReadTypeAt(off int64, typ uint16) ([]byte, int64, error) {
+	e, n, err := r.ReadAt(off)
+	if err != nil {
+		return nil, 0, err
+	}
+	if e.Type != typ {
+		return nil, 0, fmt.Errorf("e2store: entry at %d: expected type 0x%04x, got 0x%04x", off, typ, e.Type)
+	}
+	return e.Value, n, nil
+}
+
+// Compress - snappy framing format: all compressed entries of era files use it
+func Compress(b []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w := snappy.NewBufferedWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func Decompress(b []byte) ([]byte, error) {
+	return io.ReadAll(snappy.NewReader(bytes.NewReader(b)))
+}
+
+// EncodeIndex - index entry value: `start | offset_0 | ... | offset_{n-1} | count`, all int64.
+// Offsets are relative to beginning of index entry (`indexOffset`). Zero offset means "no record".
+func EncodeIndex(start uint64, offsets []int64, indexOffset int64) []byte {
+	b := make([]byte, 16+8*len(offsets))
+	binary.LittleEndian.PutUint64(b, start)
+	for i, off := range offsets {
+		if off != 0 {
+			off -= indexOffset
+		}
+		binary.LittleEndian.PutUint64(b[8+8*i:], uint64(off))
+	}
+	binary.LittleEndian.PutUint64(b[8+8*len(offsets):], uint64(len(offsets)))
+	return b
+}
+
+// ReadIndex - reads index entry which is last entry of file of size `size`. Returns absolute offsets.
+func (r *E2Reader) ReadIndex(size int64, typ uint16) (start uint64, offsets []int64, indexOffset int64, err error) {
+	var cnt [8]byte
+	if size < HeaderSize+16 {
+		return 0, nil, 0, fmt.Errorf("e2store: file is too small: %d", size)
+	}
+	if _, err := r.r.ReadAt(cnt[:], size-8); err != nil {
+		return 0, nil, 0, err
+	}
+	count := binary.LittleEndian.Uint64(cnt[:])
+	if count > uint64(size-HeaderSize-16)/8 {
+		return 0, nil, 0, fmt.Errorf("e2store: invalid index count: %d", count)
+	}
+	indexOffset = size - HeaderSize - 16 - 8*int64(count)
+	v, _, err := r.ReadTypeAt(indexOffset, typ)
+	if err != nil {
+		return 0, nil, 0, err
+	}
+	start = binary.LittleEndian.Uint64(v)
+	offsets = make([]int64, count)
+	for i := range offsets {
+		if off := int64(binary.LittleEndian.Uint64(v[8+8*i:])); off != 0 {
+			offsets[i] = indexOffset + off
+		}
+	}
+	return start, offsets, indexOffset, nil
+}
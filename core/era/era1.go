@@ -0,0 +1,357 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package era
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/rlp"
+)
+
+// era1 file: Version | (CompressedHeader | CompressedBody | CompressedReceipts | TotalDifficulty)* | Accumulator | BlockIndex
+const (
+	TypeCompressedHeader   uint16 = 0x03
+	TypeCompressedBody     uint16 = 0x04
+	TypeCompressedReceipts uint16 = 0x05
+	TypeTotalDifficulty    uint16 = 0x06
+	TypeAccumulator        uint16 = 0x07
+	TypeBlockIndex         uint16 = 0x3266 // "f2"
+
+	MaxEra1Size = 8192 // blocks per file
+	Era1Ext     = ".era1"
+)
+
+// Filename - `<network>-<epoch>-<first 4 bytes of accumulator root>.era1`
+func Filename(network string, epoch uint64, root common.Hash) string {
+	return fmt.Sprintf("%s-%05d-%x%s", network, epoch, root[:4], Era1Ext)
+}
+
+// List - sorted era1 files of `dir`
+func List(dir string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []string
+	for _, e := range entries {
+		if !e.IsDir() && strings.HasSuffix(e.Name(), Era1Ext) {
+			files = append(files, filepath.Join(dir, e.Name()))
+		}
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Builder - writes era1 file. Blocks must be added in order, at most MaxEra1Size of them.
+type Builder struct {
+	w       *E2Writer
+	written int64
+	start   *uint64
+	offsets []int64
+	hashes  []common.Hash
+	tds     []*big.Int
+}
+
+func NewBuilder(w io.Writer) *Builder { return &Builder{w: NewE2Writer(w)} }
+
+func (b *Builder) write(typ uint16, value []byte) error {
+	n, err := b.w.Write(typ, value)
+	b.written += int64(n)
+	return err
+}
+
+func (b *Builder) writeCompressed(typ uint16, value []byte) error {
+	c, err := Compress(value)
+	if err != nil {
+		return err
+	}
+	return b.write(typ, c)
+}
+
+func (b *Builder) Add(block *types.Block, receipts types.Receipts, td *big.Int) error {
+	if len(b.offsets) == MaxEra1Size {
+		return fmt.Errorf("era1: exceeds max size %d", MaxEra1Size)
+	}
+	if b.start == nil {
+		if err := b.write(TypeVersion, nil); err != nil {
+			return err
+		}
+		n := block.NumberU64()
+		b.start = &n
+	} else if expected := *b.start + uint64(len(b.offsets)); block.NumberU64() != expected {
+		return fmt.Errorf("era1: expected block %d, got %d", expected, block.NumberU64())
+	}
+	b.offsets = append(b.offsets, b.written)
+	b.hashes = append(b.hashes, block.Hash())
+	b.tds = append(b.tds, new(big.Int).Set(td))
+
+	header, err := rlp.EncodeToBytes(block.HeaderNoCopy())
+	if err != nil {
+		return err
+	}
+	if err := b.writeCompressed(TypeCompressedHeader, header); err != nil {
+		return err
+	}
+	body, err := rlp.EncodeToBytes(block.Body())
+	if err != nil {
+		return err
+	}
+	if err := b.writeCompressed(TypeCompressedBody, body); err != nil {
+		return err
+	}
+	if receipts == nil {
+		receipts = types.Receipts{}
+	}
+	rs, err := rlp.EncodeToBytes(receipts)
+	if err != nil {
+		return err
+	}
+	if err := b.writeCompressed(TypeCompressedReceipts, rs); err != nil {
+		return err
+	}
+	tdLE, err := encodeTd(td)
+	if err != nil {
+		return err
+	}
+	return b.write(TypeTotalDifficulty, tdLE)
+}
+
+// Finalize - writes accumulator and block index, returns accumulator root
+func (b *Builder) Finalize() (common.Hash, error) {
+	if b.start == nil {
+		return common.Hash{}, errors.New("era1: no blocks")
+	}
+	root, err := ComputeAccumulator(b.hashes, b.tds)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.write(TypeAccumulator, root[:]); err != nil {
+		return common.Hash{}, err
+	}
+	if err := b.write(TypeBlockIndex, EncodeIndex(*b.start, b.offsets, b.written)); err != nil {
+		return common.Hash{}, err
+	}
+	return root, nil
+}
+
+func encodeTd(td *big.Int) ([]byte, error) {
+	v, overflow := uint256.FromBig(td)
+	if overflow {
+		return nil, fmt.Errorf("era1: total difficulty overflow: %s", td)
+	}
+	be := v.Bytes32()
+	for i, j := 0, len(be)-1; i < j; i, j = i+1, j-1 {
+		be[i], be[j] = be[j], be[i]
+	}
+	return be[:], nil
+}
+
+func decodeTd(le []byte) (*big.Int, error) {
+	if len(le) != 32 {
+		return nil, fmt.Errorf("era1: invalid total difficulty length: %d", len(le))
+	}
+	be := make([]byte, 32)
+	for i := range le {
+		be[31-i] = le[i]
+	}
+	return new(big.Int).SetBytes(be), nil
+}
+
+// Era1 - reader of era1 file
+type Era1 struct {
+	f       io.ReaderAt
+	closer  io.Closer
+	r       *E2Reader
+	start   uint64
+	offsets []int64
+	index   int64 // offset of block index entry
+}
+
+func Open(path string) (*Era1, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	st, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	e, err := From(f, st.Size())
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("%s: %w", filepath.Base(path), err)
+	}
+	e.closer = f
+	return e, nil
+}
+
+func From(f io.ReaderAt, size int64) (*Era1, error) {
+	r := NewE2Reader(f)
+	if _, _, err := r.ReadTypeAt(0, TypeVersion); err != nil {
+		return nil, err
+	}
+	start, offsets, index, err := r.ReadIndex(size, TypeBlockIndex)
+	if err != nil {
+		return nil, err
+	}
+	if len(offsets) == 0 || len(offsets) > MaxEra1Size {
+		return nil, fmt.Errorf("era1: invalid amount of blocks: %d", len(offsets))
+	}
+	return &Era1{f: f, r: r, start: start, offsets: offsets, index: index}, nil
+}
+
+func (e *Era1) Close() error {
+	if e.closer == nil {
+		return nil
+	}
+	return e.closer.Close()
+}
+
+func (e *Era1) Start() uint64 { return e.start }
+func (e *Era1) Count() uint64 { return uint64(len(e.offsets)) }
+
+// Accumulator - root stored in file. Use Verify to check that it matches blocks.
+func (e *Era1) Accumulator() (common.Hash, error) {
+	// accumulator entry is right before block index
+	v, _, err := e.r.ReadTypeAt(e.index-HeaderSize-32, TypeAccumulator)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	return common.BytesToHash(v), nil
+}
+
+func (e *Era1) readCompressed(off int64, typ uint16) ([]byte, int64, error) {
+	v, n, err := e.r.ReadTypeAt(off, typ)
+	if err != nil {
+		return nil, 0, err
+	}
+	v, err = Decompress(v)
+	return v, n, err
+}
+
+// Block - block `num` with receipts and total difficulty. Receipts have only consensus fields.
+func (e *Era1) Block(num uint64) (*types.Block, types.Receipts, *big.Int, error) {
+	if num < e.start || num >= e.start+e.Count() {
+		return nil, nil, nil, fmt.Errorf("era1: block %d is out of range [%d, %d)", num, e.start, e.start+e.Count())
+	}
+	off := e.offsets[num-e.start]
+
+	v, n, err := e.readCompressed(off, TypeCompressedHeader)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	off += n
+	header := &types.Header{}
+	if err := rlp.DecodeBytes(v, header); err != nil {
+		return nil, nil, nil, fmt.Errorf("era1: header %d: %w", num, err)
+	}
+	if v, n, err = e.readCompressed(off, TypeCompressedBody); err != nil {
+		return nil, nil, nil, err
+	}
+	off += n
+	body := &types.Body{}
+	if err := rlp.DecodeBytes(v, body); err != nil {
+		return nil, nil, nil, fmt.Errorf("era1: body %d: %w", num, err)
+	}
+	if v, n, err = e.readCompressed(off, TypeCompressedReceipts); err != nil {
+		return nil, nil, nil, err
+	}
+	off += n
+	var receipts types.Receipts
+	if err := rlp.DecodeBytes(v, &receipts); err != nil {
+		return nil, nil, nil, fmt.Errorf("era1: receipts %d: %w", num, err)
+	}
+	if v, _, err = e.r.ReadTypeAt(off, TypeTotalDifficulty); err != nil {
+		return nil, nil, nil, err
+	}
+	td, err := decodeTd(v)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	return types.NewBlockFromNetwork(header, body), receipts, td, nil
+}
+
+// Verify - checks that blocks match their headers (transactions, uncles and receipts roots), numbers are sequential
+// and parent hashes link blocks. Then re-computes accumulator and compares with stored one.
+// `f` is called for every verified block: it also may check block (for example: link with previous file).
+func (e *Era1) Verify(f func(block *types.Block, receipts types.Receipts, td *big.Int) error) (common.Hash, error) {
+	hashes := make([]common.Hash, 0, e.Count())
+	tds := make([]*big.Int, 0, e.Count())
+	for num := e.start; num < e.start+e.Count(); num++ {
+		block, receipts, td, err := e.Block(num)
+		if err != nil {
+			return common.Hash{}, err
+		}
+		if err := VerifyBlock(block, receipts); err != nil {
+			return common.Hash{}, err
+		}
+		if block.NumberU64() != num {
+			return common.Hash{}, fmt.Errorf("era1: block %d has number %d", num, block.NumberU64())
+		}
+		if len(hashes) > 0 && block.ParentHash() != hashes[len(hashes)-1] {
+			return common.Hash{}, fmt.Errorf("era1: block %d: parent hash mismatch", num)
+		}
+		if len(tds) > 0 && td.Cmp(new(big.Int).Add(tds[len(tds)-1], block.Difficulty())) != 0 {
+			return common.Hash{}, fmt.Errorf("era1: block %d: total difficulty mismatch", num)
+		}
+		if f != nil {
+			if err := f(block, receipts, td); err != nil {
+				return common.Hash{}, err
+			}
+		}
+		hashes, tds = append(hashes, block.Hash()), append(tds, td)
+	}
+	expected, err := e.Accumulator()
+	if err != nil {
+		return common.Hash{}, err
+	}
+	root, err := ComputeAccumulator(hashes, tds)
+	if err != nil {
+		return common.Hash{}, err
+	}
+	if root != expected {
+		return common.Hash{}, fmt.Errorf("era1: accumulator mismatch: file has %x, blocks give %x", expected, root)
+	}
+	return root, nil
+}
+
+// VerifyBlock - body and receipts match header
+func VerifyBlock(block *types.Block, receipts types.Receipts) error {
+	header := block.HeaderNoCopy()
+	if h := types.DeriveSha(block.Transactions()); h != header.TxHash {
+		return fmt.Errorf("era1: block %d: transactions root mismatch: %x != %x", block.NumberU64(), h, header.TxHash)
+	}
+	if h := types.CalcUncleHash(block.Uncles()); h != header.UncleHash {
+		return fmt.Errorf("era1: block %d: uncles hash mismatch: %x != %x", block.NumberU64(), h, header.UncleHash)
+	}
+	if h := types.DeriveSha(receipts); h != header.ReceiptHash {
+		return fmt.Errorf("era1: block %d: receipts root mismatch: %x != %x", block.NumberU64(), h, header.ReceiptHash)
+	}
+	return nil
+}
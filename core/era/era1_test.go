@@ -0,0 +1,142 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package era_test
+
+import (
+	"bytes"
+	"math"
+	"math/big"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/era"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+func generateEra1(t *testing.T, n int) (*core.ChainPack, []*big.Int, []byte, libcommon.Hash) {
+	t.Helper()
+	var (
+		key, _ = crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+		addr   = crypto.PubkeyToAddress(key.PublicKey)
+		gspec  = &types.Genesis{
+			Config: params.TestChainConfig,
+			Alloc:  types.GenesisAlloc{addr: {Balance: big.NewInt(math.MaxInt64)}},
+		}
+		signer = types.LatestSigner(gspec.Config)
+	)
+	m := mock.MockWithGenesis(t, gspec, key, false)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, n, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(libcommon.Address{1})
+		tx, err := types.SignTx(types.NewTransaction(b.TxNonce(addr), libcommon.HexToAddress("deadbeef"), uint256.NewInt(100), 21000, uint256.NewInt(uint64(int64(i+1)*params.GWei)), nil), *signer, key)
+		require.NoError(t, err)
+		b.AddTx(tx)
+	})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	w := era.NewBuilder(&buf)
+	tds := make([]*big.Int, 0, n)
+	td := new(big.Int).Set(m.Genesis.Difficulty())
+	for i, block := range chain.Blocks {
+		td = new(big.Int).Add(td, block.Difficulty())
+		tds = append(tds, td)
+		require.NoError(t, w.Add(block, chain.Receipts[i], td))
+	}
+	root, err := w.Finalize()
+	require.NoError(t, err)
+	return chain, tds, buf.Bytes(), root
+}
+
+func TestEra1RoundTrip(t *testing.T) {
+	chain, tds, file, root := generateEra1(t, 16)
+
+	e, err := era.From(bytes.NewReader(file), int64(len(file)))
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), e.Start())
+	require.Equal(t, uint64(16), e.Count())
+	stored, err := e.Accumulator()
+	require.NoError(t, err)
+	require.Equal(t, root, stored)
+
+	for i, expected := range chain.Blocks {
+		block, receipts, td, err := e.Block(expected.NumberU64())
+		require.NoError(t, err)
+		require.Equal(t, expected.Hash(), block.Hash())
+		require.Equal(t, expected.Transactions().Len(), block.Transactions().Len())
+		require.Equal(t, types.DeriveSha(chain.Receipts[i]), types.DeriveSha(receipts))
+		require.Equal(t, tds[i], td)
+	}
+	_, _, _, err = e.Block(17)
+	require.Error(t, err)
+
+	verified, err := e.Verify(nil)
+	require.NoError(t, err)
+	require.Equal(t, root, verified)
+	require.Equal(t, "mainnet-00000-"+root.Hex()[2:10]+".era1", era.Filename("mainnet", 0, root))
+}
+
+func TestEra1Tampered(t *testing.T) {
+	chain, tds, file, _ := generateEra1(t, 4)
+
+	// total difficulty is accumulated, but not included into block hash: only accumulator may catch it
+	var buf bytes.Buffer
+	w := era.NewBuilder(&buf)
+	for i, block := range chain.Blocks {
+		require.NoError(t, w.Add(block, chain.Receipts[i], tds[i]))
+	}
+	_, err := w.Finalize()
+	require.NoError(t, err)
+	require.Equal(t, file, buf.Bytes())
+
+	// accumulator is located right before block index: index = 8 + 8*(2+count)
+	tampered := bytes.Clone(file)
+	tampered[len(tampered)-8-8*(2+4)-1] ^= 0xff
+	e, err := era.From(bytes.NewReader(tampered), int64(len(tampered)))
+	require.NoError(t, err)
+	_, err = e.Verify(nil)
+	require.ErrorContains(t, err, "accumulator mismatch")
+
+	// receipts don't match header
+	buf.Reset()
+	w = era.NewBuilder(&buf)
+	for i, block := range chain.Blocks {
+		receipts := chain.Receipts[i]
+		if i == 2 {
+			receipts = types.Receipts{}
+		}
+		require.NoError(t, w.Add(block, receipts, tds[i]))
+	}
+	_, err = w.Finalize()
+	require.NoError(t, err)
+	e, err = era.From(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	require.NoError(t, err)
+	_, err = e.Verify(nil)
+	require.ErrorContains(t, err, "receipts root mismatch")
+
+	// gap in numbers
+	w = era.NewBuilder(&bytes.Buffer{})
+	require.NoError(t, w.Add(chain.Blocks[0], chain.Receipts[0], tds[0]))
+	require.Error(t, w.Add(chain.Blocks[2], chain.Receipts[2], tds[2]))
+}
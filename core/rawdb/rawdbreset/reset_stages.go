@@ -134,6 +134,12 @@ func ResetExec(ctx context.Context, db kv.RwDB, chain string, tmpDir string, log
 	cleanupList = append(cleanupList, stateHistoryBuckets...)
 	cleanupList = append(cleanupList, stateHistoryV3Buckets...)
 	cleanupList = append(cleanupList, stateV3Buckets...)
+	for _, cfg := range kv.UserInvertedIdxs() {
+		cleanupList = append(cleanupList, cfg.KeysTable, cfg.IndexTable)
+	}
+	for _, cfg := range kv.UserDomains() {
+		cleanupList = append(cleanupList, cfg.ValuesTable, cfg.HistoryKeysTable, cfg.HistoryValsTable, cfg.HistoryIdxTable)
+	}
 
 	return db.Update(ctx, func(tx kv.RwTx) error {
 		if err := clearStageProgress(tx, stages.Execution); err != nil {
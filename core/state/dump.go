@@ -37,6 +37,7 @@ import (
 
 type Dumper struct {
 	blockNumber uint64
+	txNum       *uint64 // dump state as of txNum instead of blockNumber
 	db          kv.Tx
 	hashedState bool
 }
@@ -134,6 +135,14 @@ func NewDumper(db kv.Tx, blockNumber uint64) *Dumper {
 	}
 }
 
+// NewDumperAtTxNum - dumper of state as of txNum, for state which isn't indexed by blocks
+func NewDumperAtTxNum(db kv.Tx, txNum uint64) *Dumper {
+	return &Dumper{
+		db:    db,
+		txNum: &txNum,
+	}
+}
+
 func (d *Dumper) DumpToCollector(c DumpCollector, excludeCode, excludeStorage bool, startAddress libcommon.Address, maxResults int) ([]byte, error) {
 	var emptyCodeHash = crypto.Keccak256Hash(nil)
 	var emptyHash = libcommon.Hash{}
@@ -148,11 +157,7 @@ func (d *Dumper) DumpToCollector(c DumpCollector, excludeCode, excludeStorage bo
 	c.OnRoot(emptyHash) // We do not calculate the root
 
 	ttx := d.db.(kv.TemporalTx)
-	txNum, err := rawdbv3.TxNums.Min(ttx, d.blockNumber+1)
-	if err != nil {
-		return nil, err
-	}
-	txNumForStorage, err := rawdbv3.TxNums.Min(ttx, d.blockNumber)
+	txNum, txNumForStorage, err := d.txNums(ttx)
 	if err != nil {
 		return nil, err
 	}
@@ -239,6 +244,20 @@ func (d *Dumper) DumpToCollector(c DumpCollector, excludeCode, excludeStorage bo
 }
 
 // RawDump returns the entire state an a single large object
+// txNums - as of which txNums accounts and storage are read
+func (d *Dumper) txNums(tx kv.TemporalTx) (accounts, storage uint64, err error) {
+	if d.txNum != nil {
+		return *d.txNum, *d.txNum, nil
+	}
+	if accounts, err = rawdbv3.TxNums.Min(tx, d.blockNumber+1); err != nil {
+		return 0, 0, err
+	}
+	if storage, err = rawdbv3.TxNums.Min(tx, d.blockNumber); err != nil {
+		return 0, 0, err
+	}
+	return accounts, storage, nil
+}
+
 func (d *Dumper) RawDump(excludeCode, excludeStorage bool) Dump {
 	dump := &Dump{
 		Accounts: make(map[libcommon.Address]DumpAccount),
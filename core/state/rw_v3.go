@@ -210,13 +210,31 @@ func (rs *StateV3) ApplyState4(ctx context.Context, txTask *TxTask) error {
 }
 
 func (rs *StateV3) ApplyLogsAndTraces4(txTask *TxTask, domains *libstate.SharedDomains) error {
+	// user-defined domains are state: written even if history is discarded
+	for _, writer := range txDomainWriters {
+		domain := writer.domain
+		get := func(key []byte) ([]byte, error) {
+			v, _, err := domains.DomainGet(domain, key, nil)
+			return v, err
+		}
+		put := func(key, val []byte) error {
+			if val == nil {
+				return domains.DomainDel(domain, key, nil, nil, 0)
+			}
+			return domains.DomainPut(domain, key, nil, val, nil, 0)
+		}
+		if err := writer.fn(txTask, get, put); err != nil {
+			return fmt.Errorf("%s domain writer: %w", domain, err)
+		}
+	}
 	if dbg.DiscardHistory() {
 		return nil
 	}
-	shouldPruneNonEssentials := txTask.PruneNonEssentials && txTask.Config != nil
+	pruneLogs := txTask.PruneNonEssentialLogs && txTask.Config != nil
+	pruneTraces := txTask.PruneNonEssentialTraces && txTask.Config != nil
 
 	for addr := range txTask.TraceFroms {
-		if shouldPruneNonEssentials && addr != txTask.Config.DepositContract {
+		if pruneTraces && addr != txTask.Config.DepositContract {
 			continue
 		}
 		if err := domains.IndexAdd(kv.TblTracesFromIdx, addr[:]); err != nil {
@@ -225,7 +243,7 @@ func (rs *StateV3) ApplyLogsAndTraces4(txTask *TxTask, domains *libstate.SharedD
 	}
 
 	for addr := range txTask.TraceTos {
-		if shouldPruneNonEssentials && addr != txTask.Config.DepositContract {
+		if pruneTraces && addr != txTask.Config.DepositContract {
 			continue
 		}
 		if err := domains.IndexAdd(kv.TblTracesToIdx, addr[:]); err != nil {
@@ -234,7 +252,7 @@ func (rs *StateV3) ApplyLogsAndTraces4(txTask *TxTask, domains *libstate.SharedD
 	}
 
 	for _, lg := range txTask.Logs {
-		if shouldPruneNonEssentials && lg.Address != txTask.Config.DepositContract {
+		if pruneLogs && lg.Address != txTask.Config.DepositContract {
 			continue
 		}
 		if err := domains.IndexAdd(kv.TblLogAddressIdx, lg.Address[:]); err != nil {
@@ -246,15 +264,62 @@ func (rs *StateV3) ApplyLogsAndTraces4(txTask *TxTask, domains *libstate.SharedD
 			}
 		}
 	}
+
+	for _, indexer := range txIndexers {
+		name := indexer.name
+		if err := indexer.fn(txTask, func(key []byte) error { return domains.IndexAdd(name, key) }); err != nil {
+			return fmt.Errorf("%s indexer: %w", name, err)
+		}
+	}
 	return nil
 }
 
+// TxIndexer - adds keys of executed txn to user-defined inverted index (see kv.RegisterInvertedIdx).
+// Called for each TxTask (including block init/finalisation, see TxTask.TxIndex and TxTask.Final) in txNum order
+type TxIndexer func(txTask *TxTask, add func(key []byte) error) error
+
+type txIndexer struct {
+	name kv.InvertedIdx
+	fn   TxIndexer
+}
+
+var txIndexers []txIndexer
+
+// RegisterTxIndexer - makes execution maintain inverted index `name`, which must be registered by kv.RegisterInvertedIdx.
+// Not thread-safe: must be called before execution start (for example from `init()`)
+func RegisterTxIndexer(name kv.InvertedIdx, fn TxIndexer) {
+	if _, ok := kv.InvertedIdxPosByName(name); !ok {
+		panic(fmt.Sprintf("RegisterTxIndexer: unknown inverted index %s", name))
+	}
+	txIndexers = append(txIndexers, txIndexer{name: name, fn: fn})
+}
+
+// TxDomainWriter - updates user-defined domain (see kv.RegisterDomain) by executed txn: `get` returns latest value of key,
+// `put` with nil value deletes key. Called for each TxTask like TxIndexer
+type TxDomainWriter func(txTask *TxTask, get func(key []byte) ([]byte, error), put func(key, val []byte) error) error
+
+type txDomainWriter struct {
+	domain kv.Domain
+	fn     TxDomainWriter
+}
+
+var txDomainWriters []txDomainWriter
+
+// RegisterTxDomainWriter - makes execution maintain domain `domain`, which must be registered by kv.RegisterDomain.
+// Not thread-safe: must be called before execution start (for example from `init()`)
+func RegisterTxDomainWriter(domain kv.Domain, fn TxDomainWriter) {
+	if _, ok := kv.UserDomain(domain); !ok {
+		panic(fmt.Sprintf("RegisterTxDomainWriter: unknown user-defined domain %s", domain))
+	}
+	txDomainWriters = append(txDomainWriters, txDomainWriter{domain: domain, fn: fn})
+}
+
 var (
 	mxState3UnwindRunning = metrics.GetOrCreateGauge("state3_unwind_running")
 	mxState3Unwind        = metrics.GetOrCreateSummary("state3_unwind")
 )
 
-func (rs *StateV3) Unwind(ctx context.Context, tx kv.RwTx, blockUnwindTo, txUnwindTo uint64, accumulator *shards.Accumulator, changeset *[kv.DomainLen][]state.DomainEntryDiff) error {
+func (rs *StateV3) Unwind(ctx context.Context, tx kv.RwTx, blockUnwindTo, txUnwindTo uint64, accumulator *shards.Accumulator, changeset [][]state.DomainEntryDiff) error {
 	mxState3UnwindRunning.Inc()
 	defer mxState3UnwindRunning.Dec()
 	st := time.Now()
@@ -36,28 +36,30 @@ import (
 // which is processed by a single thread that writes into the ReconState1 and
 // flushes to the database
 type TxTask struct {
-	TxNum              uint64
-	BlockNum           uint64
-	Rules              *chain.Rules
-	Header             *types.Header
-	Txs                types.Transactions
-	Uncles             []*types.Header
-	Coinbase           libcommon.Address
-	Withdrawals        types.Withdrawals
-	BlockHash          libcommon.Hash
-	Sender             *libcommon.Address
-	SkipAnalysis       bool
-	PruneNonEssentials bool
-	TxIndex            int // -1 for block initialisation
-	Final              bool
-	Failed             bool
-	Tx                 types.Transaction
-	GetHashFn          func(n uint64) libcommon.Hash
-	TxAsMessage        types.Message
-	EvmBlockContext    evmtypes.BlockContext
+	TxNum           uint64
+	BlockNum        uint64
+	Rules           *chain.Rules
+	Header          *types.Header
+	Txs             types.Transactions
+	Uncles          []*types.Header
+	Coinbase        libcommon.Address
+	Withdrawals     types.Withdrawals
+	BlockHash       libcommon.Hash
+	Sender          *libcommon.Address
+	SkipAnalysis    bool
+	TxIndex         int // -1 for block initialisation
+	Final           bool
+	Failed          bool
+	Tx              types.Transaction
+	GetHashFn       func(n uint64) libcommon.Hash
+	TxAsMessage     types.Message
+	EvmBlockContext evmtypes.BlockContext
 
 	HistoryExecution bool // use history reader for that txn instead of state reader
 
+	PruneNonEssentialLogs   bool // index only logs of DepositContract
+	PruneNonEssentialTraces bool // index only call traces of DepositContract
+
 	BalanceIncreaseSet map[libcommon.Address]uint256.Int
 	ReadLists          map[string]*state.KvList
 	WriteLists         map[string]*state.KvList
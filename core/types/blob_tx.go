@@ -63,6 +63,19 @@ func (stx *BlobTx) GetBlobGas() uint64 {
 	return fixedgas.BlobGasPerBlob * uint64(len(stx.BlobVersionedHashes))
 }
 
+func (stx *BlobTx) WithSignature(signer Signer, sig []byte) (Transaction, error) {
+	cpy := stx.copy()
+	r, s, v, err := signer.SignatureValues(stx, sig)
+	if err != nil {
+		return nil, err
+	}
+	cpy.R.Set(r)
+	cpy.S.Set(s)
+	cpy.V.Set(v)
+	cpy.ChainID = signer.ChainID()
+	return cpy, nil
+}
+
 func (stx *BlobTx) AsMessage(s Signer, baseFee *big.Int, rules *chain.Rules) (Message, error) {
 	msg := Message{
 		nonce:      stx.Nonce,
@@ -31,6 +31,7 @@ import (
 	"sync/atomic"
 
 	"github.com/gballet/go-verkle"
+	"github.com/holiman/uint256"
 
 	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/hexutil"
@@ -1550,6 +1551,10 @@ func DecodeOnlyTxMetadataFromBody(payload []byte) (baseTxnID BaseTxnID, txCount
 type BlockWithReceipts struct {
 	Block    *Block
 	Receipts Receipts
+
+	// CoinbaseDelta - balance change of the fee recipient by transactions of the block, set by block building.
+	// Unlike tips it counts direct payments to the fee recipient. nil if it's unknown.
+	CoinbaseDelta *uint256.Int
 }
 
 type rlpEncodable interface {
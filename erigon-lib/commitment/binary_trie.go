@@ -0,0 +1,588 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package commitment
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/log/v3"
+)
+
+// BinaryTrie - EIP-7864 binary tree: account header, storage and code chunks are leaves of 256-values stems,
+// stems are placed at the shallowest depth of binary tree where they don't share path with other stems.
+// All hashes are sha256.
+//
+// Nodes and stems are stored by PatriciaContext.PutBranch under ShadowKeyPrefix (so they live in the commitment
+// domain next to hex-trie branches and are unwound/pruned with them). BinaryTrie doesn't keep anything in memory
+// between Process calls.
+//
+// Trie is not in consensus: it's used as shadow commitment to study cost of state conversion.
+type BinaryTrie struct {
+	ctx   PatriciaContext
+	trace bool
+}
+
+// ShadowKeyPrefix - prefix of commitment domain keys of shadow commitment. Hex-trie branch keys are compacted
+// nibbles: their first byte is < 0x40, so they never start with this prefix.
+var ShadowKeyPrefix = []byte("shadow")
+
+// IsShadowKey - key of commitment domain which belongs to shadow commitment (its value isn't BranchData)
+func IsShadowKey(key []byte) bool { return bytes.HasPrefix(key, ShadowKeyPrefix) }
+
+// CodeReader - BinaryTrie keeps contract code as leaves, so it's PatriciaContext has to implement this interface
+type CodeReader interface {
+	Code(plainKey []byte) ([]byte, error)
+}
+
+const (
+	binaryStemLen   = 31
+	binaryStemWidth = 256
+
+	binaryBasicDataLeafKey     = 0
+	binaryCodeHashLeafKey      = 1
+	binaryHeaderStorageOffset  = 64
+	binaryCodeOffset           = 128
+	binaryCodeChunkLen         = 31
+	binaryBasicDataCodeSizePos = 5
+	binaryBasicDataNoncePos    = 8
+	binaryBasicDataBalancePos  = 16
+
+	binaryNodeInternal byte = 1
+	binaryNodeStem     byte = 2
+)
+
+func NewBinaryTrie(ctx PatriciaContext) *BinaryTrie { return &BinaryTrie{ctx: ctx} }
+
+func (t *BinaryTrie) SetTrace(trace bool)              { t.trace = trace }
+func (t *BinaryTrie) Variant() TrieVariant             { return VariantBinaryTrie }
+func (t *BinaryTrie) Reset()                           {}
+func (t *BinaryTrie) ResetContext(ctx PatriciaContext) { t.ctx = ctx }
+
+func (t *BinaryTrie) RootHash() ([]byte, error) {
+	root, err := t.loadNode(binaryNodeKey(nil, 0))
+	if err != nil {
+		return nil, err
+	}
+	return common.Copy(root.hash[:]), nil
+}
+
+// Process - applies updates. Values are read from PatriciaContext: Updates only tell which keys are touched.
+func (t *BinaryTrie) Process(ctx context.Context, updates *Updates, logPrefix string) (rootHash []byte, err error) {
+	if t.ctx == nil {
+		return nil, errors.New("binary trie: context is not set")
+	}
+	w := &binaryWrites{t: t, stems: map[string]*binaryStem{}}
+	if err := updates.HashSort(ctx, func(_, plainKey []byte, _ *Update) error {
+		switch len(plainKey) {
+		case length.Addr:
+			return w.account(plainKey)
+		case length.Addr + length.Hash:
+			return w.storage(plainKey)
+		default:
+			return fmt.Errorf("binary trie: unexpected key length %d: %x", len(plainKey), plainKey)
+		}
+	}); err != nil {
+		return nil, err
+	}
+
+	changes := make([]binaryNode, 0, len(w.stems))
+	for stem, s := range w.stems {
+		if !s.dirty {
+			continue
+		}
+		if err := t.put(binaryStemKey([]byte(stem)), s.encode()); err != nil {
+			return nil, err
+		}
+		n := binaryNode{kind: binaryNodeStem, stem: []byte(stem)}
+		if !s.empty() {
+			n.hash = s.hash(n.stem)
+		}
+		changes = append(changes, n)
+	}
+	slices.SortFunc(changes, func(a, b binaryNode) int { return bytes.Compare(a.stem, b.stem) })
+	if len(changes) > 0 {
+		if _, err := t.update(0, changes); err != nil {
+			return nil, err
+		}
+	}
+	rootHash, err = t.RootHash()
+	if err != nil {
+		return nil, err
+	}
+	if t.trace {
+		log.Debug(fmt.Sprintf("[%s] binary trie", logPrefix), "stems", len(changes), "root", fmt.Sprintf("%x", rootHash))
+	}
+	return rootHash, nil
+}
+
+// binaryNode - stored node. Node with empty hash is absent.
+type binaryNode struct {
+	kind byte
+	hash common.Hash
+	stem []byte // for stem nodes
+}
+
+func (n binaryNode) empty() bool { return n.hash == (common.Hash{}) }
+
+func (n binaryNode) encode() []byte {
+	if n.empty() {
+		return nil
+	}
+	v := make([]byte, 0, 1+length.Hash+binaryStemLen)
+	v = append(v, n.kind)
+	v = append(v, n.hash[:]...)
+	return append(v, n.stem...)
+}
+
+func (t *BinaryTrie) loadNode(key []byte) (n binaryNode, err error) {
+	v, _, err := t.ctx.Branch(key)
+	if err != nil || len(v) == 0 {
+		return n, err
+	}
+	if len(v) < 1+length.Hash {
+		return n, fmt.Errorf("binary trie: node %x is corrupted", key)
+	}
+	n.kind = v[0]
+	copy(n.hash[:], v[1:])
+	if n.kind == binaryNodeStem {
+		n.stem = common.Copy(v[1+length.Hash:])
+	}
+	return n, nil
+}
+
+func (t *BinaryTrie) put(key, value []byte) error {
+	prev, prevStep, err := t.ctx.Branch(key)
+	if err != nil {
+		return err
+	}
+	if bytes.Equal(prev, value) {
+		return nil
+	}
+	return t.ctx.PutBranch(key, value, prev, prevStep)
+}
+
+// update - applies changed stems (sorted, all under same path of `depth` bits) to subtree, returns its new root
+func (t *BinaryTrie) update(depth int, changes []binaryNode) (binaryNode, error) {
+	key := binaryNodeKey(changes[0].stem, depth)
+	old, err := t.loadNode(key)
+	if err != nil {
+		return binaryNode{}, err
+	}
+	if old.kind == binaryNodeInternal {
+		split := sort.Search(len(changes), func(i int) bool { return binaryBit(changes[i].stem, depth) == 1 })
+		var children [2]binaryNode
+		for side, part := range [2][]binaryNode{changes[:split], changes[split:]} {
+			if len(part) > 0 {
+				children[side], err = t.update(depth+1, part)
+			} else {
+				children[side], err = t.loadNode(binaryNodeKey(binaryWithBit(changes[0].stem, depth, side), depth+1))
+			}
+			if err != nil {
+				return binaryNode{}, err
+			}
+		}
+		if children[0].empty() && children[1].empty() {
+			return binaryNode{}, t.put(key, nil)
+		}
+		if children[0].empty() || children[1].empty() {
+			side := 0
+			if children[0].empty() {
+				side = 1
+			}
+			if children[side].kind == binaryNodeStem {
+				// subtree has 1 stem: stem moves up
+				if err := t.put(binaryNodeKey(children[side].stem, depth+1), nil); err != nil {
+					return binaryNode{}, err
+				}
+				return children[side], t.put(key, children[side].encode())
+			}
+		}
+		n := binaryNode{kind: binaryNodeInternal, hash: binaryHash(children[0].hash[:], children[1].hash[:])}
+		return n, t.put(key, n.encode())
+	}
+
+	// subtree is empty or has 1 stem: build it from scratch
+	stems := make([]binaryNode, 0, len(changes)+1)
+	if old.kind == binaryNodeStem {
+		if i, found := slices.BinarySearchFunc(changes, old.stem, func(n binaryNode, stem []byte) int { return bytes.Compare(n.stem, stem) }); !found {
+			changes = slices.Insert(slices.Clone(changes), i, old)
+		}
+	}
+	for _, c := range changes {
+		if !c.empty() {
+			stems = append(stems, c)
+		}
+	}
+	return t.build(key, depth, stems)
+}
+
+func (t *BinaryTrie) build(key []byte, depth int, stems []binaryNode) (binaryNode, error) {
+	switch len(stems) {
+	case 0:
+		return binaryNode{}, t.put(key, nil)
+	case 1:
+		return stems[0], t.put(key, stems[0].encode())
+	}
+	split := sort.Search(len(stems), func(i int) bool { return binaryBit(stems[i].stem, depth) == 1 })
+	var children [2]binaryNode
+	for side, part := range [2][]binaryNode{stems[:split], stems[split:]} {
+		if len(part) == 0 {
+			continue
+		}
+		var err error
+		if children[side], err = t.build(binaryNodeKey(part[0].stem, depth+1), depth+1, part); err != nil {
+			return binaryNode{}, err
+		}
+	}
+	n := binaryNode{kind: binaryNodeInternal, hash: binaryHash(children[0].hash[:], children[1].hash[:])}
+	return n, t.put(key, n.encode())
+}
+
+// binaryHash - sha256 of concatenation, but hash of zeroes is zero
+func binaryHash(parts ...[]byte) (h common.Hash) {
+	zero := true
+	for _, p := range parts {
+		if !bytesAreZero(p) {
+			zero = false
+			break
+		}
+	}
+	if zero {
+		return h
+	}
+	hasher := sha256.New()
+	for _, p := range parts {
+		hasher.Write(p)
+	}
+	hasher.Sum(h[:0])
+	return h
+}
+
+func bytesAreZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func binaryBit(stem []byte, depth int) int { return int(stem[depth/8]>>(7-depth%8)) & 1 }
+
+func binaryWithBit(stem []byte, depth, bit int) []byte {
+	s := common.Copy(stem)
+	s[depth/8] &^= 1 << (7 - depth%8)
+	s[depth/8] |= byte(bit) << (7 - depth%8)
+	return s
+}
+
+// binaryNodeKey - `prefix | 'n' | depth | first depth bits of stem`
+func binaryNodeKey(stem []byte, depth int) []byte {
+	n := (depth + 7) / 8
+	k := make([]byte, 0, len(ShadowKeyPrefix)+2+n)
+	k = append(k, ShadowKeyPrefix...)
+	k = append(k, 'n', byte(depth))
+	k = append(k, stem[:n]...)
+	if depth%8 != 0 {
+		k[len(k)-1] &= 0xff << (8 - depth%8)
+	}
+	return k
+}
+
+// binaryStemKey - `prefix | 's' | stem`
+func binaryStemKey(stem []byte) []byte {
+	k := make([]byte, 0, len(ShadowKeyPrefix)+1+binaryStemLen)
+	k = append(k, ShadowKeyPrefix...)
+	k = append(k, 's')
+	return append(k, stem...)
+}
+
+// binaryStem - values of stem, nil value means absent
+type binaryStem struct {
+	values [binaryStemWidth][]byte
+	dirty  bool
+}
+
+// encode - `bitmap of present values (32 bytes) | values`
+func (s *binaryStem) encode() []byte {
+	if s.empty() {
+		return nil
+	}
+	v := make([]byte, binaryStemWidth/8, binaryStemWidth/8+length.Hash*8)
+	for i, val := range s.values {
+		if val != nil {
+			v[i/8] |= 1 << (7 - i%8)
+			v = append(v, val...)
+		}
+	}
+	return v
+}
+
+func (s *binaryStem) decode(v []byte) error {
+	if len(v) == 0 {
+		return nil
+	}
+	if len(v) < binaryStemWidth/8 {
+		return errors.New("binary trie: stem is corrupted")
+	}
+	pos := binaryStemWidth / 8
+	for i := range s.values {
+		if v[i/8]&(1<<(7-i%8)) == 0 {
+			continue
+		}
+		if len(v) < pos+length.Hash {
+			return errors.New("binary trie: stem is corrupted")
+		}
+		s.values[i] = common.Copy(v[pos : pos+length.Hash])
+		pos += length.Hash
+	}
+	return nil
+}
+
+func (s *binaryStem) empty() bool {
+	for _, v := range s.values {
+		if v != nil {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *binaryStem) hash(stem []byte) common.Hash {
+	var level [binaryStemWidth]common.Hash
+	for i, v := range s.values {
+		if v != nil {
+			level[i] = sha256.Sum256(v)
+		}
+	}
+	for n := binaryStemWidth; n > 1; n /= 2 {
+		for i := 0; i < n/2; i++ {
+			level[i] = binaryHash(level[2*i][:], level[2*i+1][:])
+		}
+	}
+	return binaryHash(stem, []byte{0}, level[0][:])
+}
+
+// binaryWrites - stems touched by one Process
+type binaryWrites struct {
+	t     *BinaryTrie
+	stems map[string]*binaryStem
+}
+
+func (w *binaryWrites) stem(stem []byte) (*binaryStem, error) {
+	if s, ok := w.stems[string(stem)]; ok {
+		return s, nil
+	}
+	v, _, err := w.t.ctx.Branch(binaryStemKey(stem))
+	if err != nil {
+		return nil, err
+	}
+	s := &binaryStem{}
+	if err := s.decode(v); err != nil {
+		return nil, err
+	}
+	w.stems[string(stem)] = s
+	return s, nil
+}
+
+func (w *binaryWrites) get(treeKey []byte) ([]byte, error) {
+	s, err := w.stem(treeKey[:binaryStemLen])
+	if err != nil {
+		return nil, err
+	}
+	return s.values[treeKey[binaryStemLen]], nil
+}
+
+func (w *binaryWrites) set(treeKey, value []byte) error {
+	s, err := w.stem(treeKey[:binaryStemLen])
+	if err != nil {
+		return err
+	}
+	sub := treeKey[binaryStemLen]
+	if bytes.Equal(s.values[sub], value) && (s.values[sub] == nil) == (value == nil) { // absent != zero value
+		return nil
+	}
+	s.values[sub], s.dirty = value, true
+	return nil
+}
+
+func (w *binaryWrites) account(addr []byte) error {
+	u, err := w.t.ctx.Account(addr)
+	if err != nil {
+		return err
+	}
+	basicKey := BinaryTreeKey(addr, uint256.NewInt(0), binaryBasicDataLeafKey)
+	codeHashKey := BinaryTreeKey(addr, uint256.NewInt(0), binaryCodeHashLeafKey)
+	oldBasic, err := w.get(basicKey)
+	if err != nil {
+		return err
+	}
+	oldCodeHash, err := w.get(codeHashKey)
+	if err != nil {
+		return err
+	}
+	var oldCodeSize uint64
+	if oldBasic != nil {
+		oldCodeSize = uint64(binary.BigEndian.Uint32(oldBasic[binaryBasicDataCodeSizePos-1:]) & 0xffffff)
+	}
+
+	if u.Flags&DeleteUpdate != 0 {
+		if err := w.setCode(addr, oldCodeSize, nil); err != nil {
+			return err
+		}
+		if err := w.set(basicKey, nil); err != nil {
+			return err
+		}
+		return w.set(codeHashKey, nil)
+	}
+
+	codeHash := u.CodeHash
+	if codeHash == (common.Hash{}) {
+		codeHash = EmptyCodeHashArray
+	}
+	codeSize := oldCodeSize
+	if oldCodeHash == nil || !bytes.Equal(oldCodeHash, codeHash[:]) {
+		var code []byte
+		if codeHash != EmptyCodeHashArray {
+			cr, ok := w.t.ctx.(CodeReader)
+			if !ok {
+				return errors.New("binary trie: context can't read code")
+			}
+			if code, err = cr.Code(addr); err != nil {
+				return err
+			}
+		}
+		if err := w.setCode(addr, oldCodeSize, code); err != nil {
+			return err
+		}
+		codeSize = uint64(len(code))
+	}
+
+	basic := make([]byte, length.Hash)
+	binary.BigEndian.PutUint32(basic[binaryBasicDataCodeSizePos-1:], uint32(codeSize))
+	basic[binaryBasicDataCodeSizePos-1] = 0 // version
+	binary.BigEndian.PutUint64(basic[binaryBasicDataNoncePos:], u.Nonce)
+	balance := u.Balance.Bytes32()
+	copy(basic[binaryBasicDataBalancePos:], balance[16:])
+	if err := w.set(basicKey, basic); err != nil {
+		return err
+	}
+	return w.set(codeHashKey, common.Copy(codeHash[:]))
+}
+
+// setCode - replaces code chunks of account
+func (w *binaryWrites) setCode(addr []byte, oldCodeSize uint64, code []byte) error {
+	chunks := ChunkifyCode(code)
+	oldChunks := (oldCodeSize + binaryCodeChunkLen - 1) / binaryCodeChunkLen
+	for i := uint64(0); i < max(oldChunks, uint64(len(chunks))); i++ {
+		var chunk []byte
+		if i < uint64(len(chunks)) {
+			chunk = chunks[i]
+		}
+		if err := w.set(binaryCodeChunkKey(addr, i), chunk); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *binaryWrites) storage(plainKey []byte) error {
+	u, err := w.t.ctx.Storage(plainKey)
+	if err != nil {
+		return err
+	}
+	var value []byte
+	if u.Flags&DeleteUpdate == 0 && u.StorageLen > 0 {
+		value = make([]byte, length.Hash)
+		copy(value[length.Hash-u.StorageLen:], u.Storage[:u.StorageLen])
+	}
+	return w.set(BinaryStorageKey(plainKey[:length.Addr], plainKey[length.Addr:]), value)
+}
+
+// BinaryTreeKey - EIP-7864 get_tree_key: `sha256(address32 | tree_index as 32 bytes little-endian)[:31] | sub_index`
+func BinaryTreeKey(addr []byte, treeIndex *uint256.Int, subIndex byte) []byte {
+	var buf [64]byte
+	copy(buf[32-length.Addr:32], addr)
+	index := treeIndex.Bytes32()
+	for i := 0; i < 32; i++ {
+		buf[32+i] = index[31-i]
+	}
+	h := sha256.Sum256(buf[:])
+	h[binaryStemLen] = subIndex
+	return h[:]
+}
+
+// BinaryStorageKey - tree key of storage slot: first slots are in account header stem, others are in main storage
+func BinaryStorageKey(addr, slot []byte) []byte {
+	s := new(uint256.Int).SetBytes(slot)
+	if s.LtUint64(binaryCodeOffset - binaryHeaderStorageOffset) {
+		return BinaryTreeKey(addr, uint256.NewInt(0), byte(binaryHeaderStorageOffset+s.Uint64()))
+	}
+	sub := byte(s.Uint64())
+	// MAIN_STORAGE_OFFSET = 256**31: tree_index = 256**30 + slot/256
+	index := new(uint256.Int).Rsh(s, 8)
+	index.Add(index, new(uint256.Int).Lsh(uint256.NewInt(1), 240))
+	return BinaryTreeKey(addr, index, sub)
+}
+
+func binaryCodeChunkKey(addr []byte, chunk uint64) []byte {
+	pos := binaryCodeOffset + chunk
+	return BinaryTreeKey(addr, uint256.NewInt(pos/binaryStemWidth), byte(pos%binaryStemWidth))
+}
+
+// ChunkifyCode - splits code to 31-bytes chunks, each prefixed by amount of leading bytes which are PUSH data
+func ChunkifyCode(code []byte) [][]byte {
+	if len(code) == 0 {
+		return nil
+	}
+	const push1, push32 = 0x60, 0x7f
+	n := (len(code) + binaryCodeChunkLen - 1) / binaryCodeChunkLen
+	padded := make([]byte, n*binaryCodeChunkLen)
+	copy(padded, code)
+	pushData := make([]byte, len(padded)+32) // amount of remaining push data bytes at position
+	for pos := 0; pos < len(padded); {
+		op := padded[pos]
+		pos++
+		if op >= push1 && op <= push32 {
+			size := int(op-push1) + 1
+			for x := 0; x < size; x++ {
+				pushData[pos+x] = byte(size - x)
+			}
+			pos += size
+		}
+	}
+	chunks := make([][]byte, n)
+	for i := range chunks {
+		pos := i * binaryCodeChunkLen
+		chunk := make([]byte, length.Hash)
+		chunk[0] = min(pushData[pos], binaryCodeChunkLen)
+		copy(chunk[1:], padded[pos:pos+binaryCodeChunkLen])
+		chunks[i] = chunk
+	}
+	return chunks
+}
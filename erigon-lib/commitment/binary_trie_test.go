@@ -0,0 +1,174 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package commitment
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// mockCodeState - MockState which also stores contract code
+type mockCodeState struct {
+	*MockState
+	code map[string][]byte
+}
+
+func (ms *mockCodeState) Code(plainKey []byte) ([]byte, error) { return ms.code[string(plainKey)], nil }
+
+func processBinary(t *testing.T, ms PatriciaContext, mock *MockState, keys [][]byte, updates []Update) []byte {
+	t.Helper()
+	require.NoError(t, mock.applyPlainUpdates(keys, updates))
+	trie := NewBinaryTrie(ms)
+	upd := WrapKeyUpdates(t, ModeDirect, keyHasherNoop, keys, updates)
+	defer upd.Close()
+	root, err := trie.Process(context.Background(), upd, "")
+	require.NoError(t, err)
+	return root
+}
+
+func binaryTestBatches() []*UpdateBuilder {
+	addr := func(i int) string { return fmt.Sprintf("%040x", i*7919) }
+	slot := func(i int) string { return fmt.Sprintf("%064x", i*1000003) }
+	var batches []*UpdateBuilder
+	b := NewUpdateBuilder()
+	for i := 1; i < 40; i++ {
+		b.Balance(addr(i), uint64(i)*1000).Nonce(addr(i), uint64(i))
+		if i%3 == 0 {
+			b.Storage(addr(i), slot(i), fmt.Sprintf("%02x", i))
+			b.Storage(addr(i), fmt.Sprintf("%064x", i%5), "ff01")
+		}
+	}
+	batches = append(batches, b)
+
+	b = NewUpdateBuilder()
+	for i := 1; i < 40; i += 4 {
+		b.Delete(addr(i))
+	}
+	for i := 3; i < 40; i += 6 {
+		b.DeleteStorage(addr(i), slot(i))
+	}
+	b.Balance(addr(100), 1)
+	batches = append(batches, b)
+
+	b = NewUpdateBuilder()
+	for i := 2; i < 40; i += 5 {
+		b.Balance(addr(i), 7).Storage(addr(i), slot(i+1), "01")
+	}
+	batches = append(batches, b)
+	return batches
+}
+
+func TestBinaryTrie_EmptyRoot(t *testing.T) {
+	t.Parallel()
+	ms := NewMockState(t)
+	root, err := NewBinaryTrie(ms).RootHash()
+	require.NoError(t, err)
+	require.Equal(t, make([]byte, 32), root)
+}
+
+func TestBinaryTrie_IncrementalEqualsFromScratch(t *testing.T) {
+	t.Parallel()
+	incremental := NewMockState(t)
+	var roots [][]byte
+	for _, b := range binaryTestBatches() {
+		keys, updates := b.Build()
+		roots = append(roots, processBinary(t, incremental, incremental, keys, updates))
+	}
+	require.NotEqual(t, roots[0], roots[1])
+	require.NotEqual(t, roots[1], roots[2])
+
+	// same state, computed in one go
+	final := NewMockState(t)
+	keys := make([][]byte, 0, len(incremental.sm))
+	updates := make([]Update, 0, len(incremental.sm))
+	for k := range incremental.sm {
+		keys = append(keys, []byte(k))
+		var u *Update
+		var err error
+		if len(k) == 20 {
+			u, err = incremental.Account([]byte(k))
+		} else {
+			u, err = incremental.Storage([]byte(k))
+		}
+		require.NoError(t, err)
+		updates = append(updates, *u)
+	}
+	require.Equal(t, roots[2], processBinary(t, final, final, keys, updates))
+}
+
+func TestBinaryTrie_DeleteEverything(t *testing.T) {
+	t.Parallel()
+	ms := NewMockState(t)
+	keys, updates := NewUpdateBuilder().
+		Balance("00000000000000000000000000000000000000aa", 5).
+		Balance("00000000000000000000000000000000000000bb", 6).
+		Storage("00000000000000000000000000000000000000bb", "0000000000000000000000000000000000000000000000000000000000000101", "33").
+		Build()
+	root := processBinary(t, ms, ms, keys, updates)
+	require.NotEqual(t, make([]byte, 32), root)
+
+	keys, updates = NewUpdateBuilder().
+		Delete("00000000000000000000000000000000000000aa").
+		Delete("00000000000000000000000000000000000000bb").
+		DeleteStorage("00000000000000000000000000000000000000bb", "0000000000000000000000000000000000000000000000000000000000000101").
+		Build()
+	root = processBinary(t, ms, ms, keys, updates)
+	require.Equal(t, make([]byte, 32), root)
+	for k, v := range ms.cm {
+		require.True(t, IsShadowKey([]byte(k)))
+		require.Empty(t, v, "key %x", k)
+	}
+}
+
+func TestBinaryTrie_Code(t *testing.T) {
+	t.Parallel()
+	const addr = "00000000000000000000000000000000000000cc"
+	ms := &mockCodeState{MockState: NewMockState(t), code: map[string][]byte{}}
+	ms.code[string(decodeHex(addr))] = make([]byte, 100)
+	keys, updates := NewUpdateBuilder().CodeHash(addr, "0102030405060708091011121314151617181920212223242526272829303132").Build()
+	withCode := processBinary(t, ms, ms.MockState, keys, updates)
+
+	noCode := NewMockState(t)
+	require.NoError(t, noCode.applyPlainUpdates(keys, updates))
+	_, err := NewBinaryTrie(noCode).Process(context.Background(), WrapKeyUpdates(t, ModeDirect, keyHasherNoop, keys, updates), "")
+	require.ErrorContains(t, err, "can't read code")
+
+	// code is replaced with shorter one: stale chunks are removed
+	ms.code[string(decodeHex(addr))] = make([]byte, 10)
+	keys, updates = NewUpdateBuilder().CodeHash(addr, "3132333435363738394041424344454647484950515253545556575859606162").Build()
+	shorter := processBinary(t, ms, ms.MockState, keys, updates)
+	require.NotEqual(t, withCode, shorter)
+
+	fresh := &mockCodeState{MockState: NewMockState(t), code: ms.code}
+	require.Equal(t, shorter, processBinary(t, fresh, fresh.MockState, keys, updates))
+}
+
+func TestChunkifyCode(t *testing.T) {
+	t.Parallel()
+	code := make([]byte, 40)
+	code[28] = 0x63 // PUSH4: 2 bytes of data are in the first chunk, 2 in the second
+	chunks := ChunkifyCode(code)
+	require.Len(t, chunks, 2)
+	require.Equal(t, byte(0), chunks[0][0])
+	require.Equal(t, byte(2), chunks[1][0])
+	require.Equal(t, code[:31], chunks[0][1:])
+	require.Equal(t, append(code[31:], make([]byte, 22)...), chunks[1][1:])
+	require.Nil(t, ChunkifyCode(nil))
+}
@@ -85,6 +85,8 @@ const (
 	VariantHexPatriciaTrie TrieVariant = "hex-patricia-hashed"
 	// VariantBinPatriciaTrie - Experimental mode with binary key representation
 	VariantBinPatriciaTrie TrieVariant = "bin-patricia-hashed"
+	// VariantBinaryTrie - EIP-7864 binary tree. Not in consensus: used only as shadow commitment
+	VariantBinaryTrie TrieVariant = "binary-trie"
 )
 
 func InitializeTrieAndUpdates(tv TrieVariant, mode Mode, tmpdir string) (Trie, *Updates) {
@@ -95,6 +97,8 @@ func InitializeTrieAndUpdates(tv TrieVariant, mode Mode, tmpdir string) (Trie, *
 		//tree := NewUpdateTree(mode, tmpdir, fn)
 		//return trie, tree
 		panic("omg its not supported")
+	case VariantBinaryTrie:
+		return NewBinaryTrie(nil), NewUpdates(mode, tmpdir, keyHasherNoop)
 	case VariantHexPatriciaTrie:
 		fallthrough
 	default:
@@ -684,6 +688,8 @@ func ParseTrieVariant(s string) TrieVariant {
 	switch s {
 	case "bin":
 		trieVariant = VariantBinPatriciaTrie
+	case "binary":
+		trieVariant = VariantBinaryTrie
 	case "hex":
 		fallthrough
 	default:
@@ -983,19 +989,24 @@ func (t *Updates) HashSort(ctx context.Context, fn func(hk, pk []byte, update *U
 
 		t.initCollector()
 	case ModeUpdate:
+		var err error
 		t.tree.Ascend(func(item *KeyUpdate) bool {
 			select {
 			case <-ctx.Done():
+				err = ctx.Err()
 				return false
 			default:
 			}
 
-			if err := fn(item.hashedKey, item.plainKey, item.update); err != nil {
+			if err = fn(item.hashedKey, item.plainKey, item.update); err != nil {
 				return false
 			}
 			return true
 		})
 		t.tree.Clear(true)
+		if err != nil {
+			return err
+		}
 	default:
 		return nil
 	}
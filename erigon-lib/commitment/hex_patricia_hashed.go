@@ -81,6 +81,7 @@ type HexPatriciaHashed struct {
 	hashAuxBuffer [128]byte     // buffer to compute cell hash or write hash-related things
 	auxBuffer     *bytes.Buffer // auxiliary buffer used during branch updates encoding
 	branchEncoder *BranchEncoder
+	subtries      [16]*HexPatriciaHashed // grids to fold top-level subtries concurrently, allocated on demand
 }
 
 func NewHexPatriciaHashed(accountKeyLen int, ctx PatriciaContext, tmpdir string) *HexPatriciaHashed {
@@ -1260,11 +1261,49 @@ func (hph *HexPatriciaHashed) RootHash() ([]byte, error) {
 	return rootHash[1:], nil // first byte is 128+hash_len=160
 }
 
+// followAndUpdate folds and unfolds the grid until it is positioned at hashedKey and applies the update to the cell.
+// If stateUpdate is nil, the latest value of plainKey is read from the context.
+func (hph *HexPatriciaHashed) followAndUpdate(hashedKey, plainKey []byte, stateUpdate *Update) (err error) {
+	if hph.trace {
+		fmt.Printf("\nplainKey [%x] hashedKey [%x] currentKey [%x]\n", plainKey, hashedKey, hph.currentKey[:hph.currentKeyLen])
+	}
+	// Keep folding until the currentKey is the prefix of the key we modify
+	for hph.needFolding(hashedKey) {
+		if err := hph.fold(); err != nil {
+			return fmt.Errorf("fold: %w", err)
+		}
+	}
+	// Now unfold until we step on an empty cell
+	for unfolding := hph.needUnfolding(hashedKey); unfolding > 0; unfolding = hph.needUnfolding(hashedKey) {
+		if err := hph.unfold(hashedKey, unfolding); err != nil {
+			return fmt.Errorf("unfold: %w", err)
+		}
+	}
+
+	update := stateUpdate
+	if update == nil {
+		// Update the cell
+		if len(plainKey) == hph.accountKeyLen {
+			update, err = hph.ctx.Account(plainKey)
+			if err != nil {
+				return fmt.Errorf("GetAccount for key %x failed: %w", plainKey, err)
+			}
+		} else {
+			update, err = hph.ctx.Storage(plainKey)
+			if err != nil {
+				return fmt.Errorf("GetStorage for key %x failed: %w", plainKey, err)
+			}
+		}
+	}
+	hph.updateCell(plainKey, hashedKey, update)
+	return nil
+}
+
 func (hph *HexPatriciaHashed) Process(ctx context.Context, updates *Updates, logPrefix string) (rootHash []byte, err error) {
 	var (
-		m      runtime.MemStats
-		ki     uint64
-		update *Update
+		m  runtime.MemStats
+		ki uint64
+		pf *parallelFold
 
 		updatesCount = updates.Size()
 		logEvery     = time.NewTicker(20 * time.Second)
@@ -1281,50 +1320,30 @@ func (hph *HexPatriciaHashed) Process(ctx context.Context, updates *Updates, log
 
 		default:
 		}
+		ki++
 
-		if hph.trace {
-			fmt.Printf("\n%d/%d) plainKey [%x] hashedKey [%x] currentKey [%x]\n", ki+1, updatesCount, plainKey, hashedKey, hph.currentKey[:hph.currentKeyLen])
-		}
-		// Keep folding until the currentKey is the prefix of the key we modify
-		for hph.needFolding(hashedKey) {
-			if err := hph.fold(); err != nil {
-				return fmt.Errorf("fold: %w", err)
-			}
+		if pf != nil {
+			return pf.process(hashedKey, plainKey, stateUpdate)
 		}
-		// Now unfold until we step on an empty cell
-		for unfolding := hph.needUnfolding(hashedKey); unfolding > 0; unfolding = hph.needUnfolding(hashedKey) {
-			if err := hph.unfold(hashedKey, unfolding); err != nil {
-				return fmt.Errorf("unfold: %w", err)
-			}
+		if err := hph.followAndUpdate(hashedKey, plainKey, stateUpdate); err != nil {
+			return err
 		}
+		mxKeys.Inc()
 
-		if stateUpdate == nil {
-			// Update the cell
-			if len(plainKey) == hph.accountKeyLen {
-				update, err = hph.ctx.Account(plainKey)
-				if err != nil {
-					return fmt.Errorf("GetAccount for key %x failed: %w", plainKey, err)
-				}
-			} else {
-				update, err = hph.ctx.Storage(plainKey)
-				if err != nil {
-					return fmt.Errorf("GetStorage for key %x failed: %w", plainKey, err)
-				}
-			}
-		} else {
-			if update == nil {
-				update = stateUpdate
-			} else {
-				update.Reset()
-				update.Merge(stateUpdate)
-			}
+		// Once the root is unfolded into the row of depth 1, this row is not folded until all updates are applied
+		// and subtries of other nibbles don't depend on each other
+		if pf == nil && updatesCount >= parallelFoldMinUpdates && hph.activeRows > 0 && hph.depths[0] == 1 {
+			pf = newParallelFold(ctx, hph, int(hashedKey[0]))
 		}
-		hph.updateCell(plainKey, hashedKey, update)
-
-		mxKeys.Inc()
-		ki++
 		return nil
 	})
+	if pf != nil {
+		if err == nil {
+			err = pf.merge()
+		} else {
+			pf.wait()
+		}
+	}
 	if err != nil {
 		return nil, fmt.Errorf("hash sort failed: %w", err)
 	}
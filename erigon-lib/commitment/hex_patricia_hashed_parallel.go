@@ -0,0 +1,327 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package commitment
+
+import (
+	"context"
+	"fmt"
+
+	"golang.org/x/sync/errgroup"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/dbg"
+)
+
+// parallelFoldMinUpdates - Process folds top-level subtries concurrently only if there are at least that many updates.
+// Negative value of COMMITMENT_PARALLEL_MIN_KEYS disables concurrent folding.
+var parallelFoldMinUpdates = uint64(dbg.EnvInt("COMMITMENT_PARALLEL_MIN_KEYS", 1024))
+
+// parallelFold folds top-level subtries of HexPatriciaHashed concurrently.
+//
+// Once the root is unfolded into the row of depth 1, this row is never folded until all updates are applied, and
+// cells of this row are independent: updates of the subtrie of nibble N only touch cell N and bit N of the row's
+// touch/after maps. Subtrie of the nibble being processed when folding went parallel stays with hph, updates of
+// every other nibble are dispatched to a separate grid with a copy of the row. Then folded cells are moved back to
+// hph and the row is folded as usual.
+//
+// Each subtrie goes through exactly the same folds and unfolds as with sequential processing: when the next update
+// belongs to another subtrie, previous subtrie is folded up to the row of depth 1. So subtries produce the same
+// branch updates, which are buffered and applied after hph's own ones. Rows of the grid, which are left after
+// processing, are a part of encoded trie state, so for each row the latest subtrie which used it is tracked.
+//
+// PatriciaContext is not required to be thread-safe (and its db transaction could be bound to the thread), so
+// subtries read state through the goroutine which runs Process.
+type parallelFold struct {
+	hph    *HexPatriciaHashed
+	nibble int // nibble of the subtrie folded by hph itself
+	last   int // nibble of the latest update
+	seq    uint64
+
+	g      *errgroup.Group
+	ctx    context.Context
+	calls  chan subtrieCall
+	keys   [16]chan subtrieKey
+	states [16]*subtrieContext
+	rows   [16][128]uint64 // for each subtrie and row of the grid, seq of the latest step which used the row
+
+	waited  bool
+	waitErr error
+}
+
+// subtrieKey - step of subtrie processing: apply update or fold up to the row of depth 1
+type subtrieKey struct {
+	seq                 uint64
+	fold                bool
+	hashedKey, plainKey []byte
+	update              *Update
+}
+
+// subtrieCall - request to run fn with PatriciaContext of hph
+type subtrieCall struct {
+	fn   func(pc PatriciaContext)
+	done chan struct{}
+}
+
+func newParallelFold(ctx context.Context, hph *HexPatriciaHashed, nibble int) *parallelFold {
+	pf := &parallelFold{hph: hph, nibble: nibble, last: nibble, calls: make(chan subtrieCall)}
+	pf.g, pf.ctx = errgroup.WithContext(ctx)
+	return pf
+}
+
+// process applies update to the subtrie of its nibble: by hph itself or by dispatching it to the subtrie grid
+func (pf *parallelFold) process(hashedKey, plainKey []byte, update *Update) error {
+	nibble := int(hashedKey[0])
+	if nibble != pf.last {
+		if err := pf.send(pf.last, subtrieKey{fold: true}); err != nil {
+			return err
+		}
+		pf.last = nibble
+	}
+	return pf.send(nibble, subtrieKey{hashedKey: hashedKey, plainKey: plainKey, update: update})
+}
+
+func (pf *parallelFold) send(nibble int, k subtrieKey) error {
+	pf.seq++
+	k.seq = pf.seq
+	if nibble == pf.nibble {
+		return pf.step(pf.hph, &pf.rows[nibble], k)
+	}
+	if pf.keys[nibble] == nil {
+		pf.start(nibble)
+	}
+	k.hashedKey, k.plainKey = common.Copy(k.hashedKey), common.Copy(k.plainKey)
+	for {
+		// serve pending reads first: subtries are blocked by them, while keys are buffered
+		select {
+		case c := <-pf.calls:
+			pf.serve(c)
+			continue
+		default:
+		}
+		select {
+		case pf.keys[nibble] <- k:
+			return nil
+		case c := <-pf.calls:
+			pf.serve(c)
+		case <-pf.ctx.Done():
+			if err := pf.wait(); err != nil {
+				return err
+			}
+			return pf.ctx.Err()
+		}
+	}
+}
+
+// step applies update or folds grid up to the row of depth 1, and marks rows of the grid which it used
+func (pf *parallelFold) step(grid *HexPatriciaHashed, rows *[128]uint64, k subtrieKey) error {
+	prevRows := grid.activeRows
+	for k.fold && grid.activeRows > 1 || !k.fold && grid.needFolding(k.hashedKey) {
+		if err := grid.fold(); err != nil {
+			return fmt.Errorf("fold: %w", err)
+		}
+	}
+	// fold of the row changes the row above it
+	from, to := grid.activeRows-1, prevRows
+	if !k.fold {
+		if err := grid.followAndUpdate(k.hashedKey, k.plainKey, k.update); err != nil {
+			return err
+		}
+		mxKeys.Inc()
+		if from == prevRows-1 {
+			// nothing folded: unfold changes new rows, update - the last row
+			from = min(prevRows, grid.activeRows-1)
+		}
+		to = max(to, grid.activeRows)
+	}
+	for row := max(1, from); row < to; row++ {
+		rows[row] = k.seq
+	}
+	return nil
+}
+
+func (pf *parallelFold) serve(c subtrieCall) {
+	c.fn(pf.hph.ctx)
+	c.done <- struct{}{}
+}
+
+func (pf *parallelFold) start(nibble int) {
+	hph := pf.hph
+	sub := hph.subtries[nibble]
+	if sub == nil {
+		sub = NewHexPatriciaHashed(hph.accountKeyLen, nil, hph.branchEncoder.tmpdir)
+		hph.subtries[nibble] = sub
+	}
+	sc := &subtrieContext{pf: pf, done: make(chan struct{}, 1), branches: make(map[string]subtrieBranch)}
+	sub.ctx = sc
+	sub.trace = hph.trace
+	sub.activeRows, sub.currentKeyLen = 1, 0
+	sub.depths[0] = hph.depths[0]
+	sub.branchBefore[0] = hph.branchBefore[0]
+	bit := uint16(1) << nibble
+	sub.touchMap[0], sub.afterMap[0] = hph.touchMap[0]&bit, hph.afterMap[0]&bit
+	sub.grid[0][nibble] = hph.grid[0][nibble]
+
+	keys := make(chan subtrieKey, 1024)
+	pf.keys[nibble], pf.states[nibble] = keys, sc
+	pf.g.Go(func() error {
+		for k := range keys {
+			if err := pf.step(sub, &pf.rows[nibble], k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// wait stops dispatching and serves state reads until all subtries are folded
+func (pf *parallelFold) wait() error {
+	if pf.waited {
+		return pf.waitErr
+	}
+	pf.waited = true
+	for _, keys := range pf.keys {
+		if keys != nil {
+			close(keys)
+		}
+	}
+	finished := make(chan error, 1)
+	go func() { finished <- pf.g.Wait() }()
+	for {
+		select {
+		case c := <-pf.calls:
+			pf.serve(c)
+		case pf.waitErr = <-finished:
+			return pf.waitErr
+		}
+	}
+}
+
+// merge folds the latest subtrie, waits for all subtries, applies their branch updates and moves folded cells to
+// the row of depth 1 of hph
+func (pf *parallelFold) merge() error {
+	if err := pf.send(pf.last, subtrieKey{fold: true}); err != nil {
+		pf.wait()
+		return err
+	}
+	if err := pf.wait(); err != nil {
+		return err
+	}
+	hph := pf.hph
+	for nibble, sc := range pf.states {
+		if sc == nil {
+			continue
+		}
+		for _, put := range sc.puts {
+			if err := hph.ctx.PutBranch(put.prefix, put.data, put.prev, put.prevStep); err != nil {
+				return err
+			}
+		}
+		sub, bit := hph.subtries[nibble], uint16(1)<<nibble
+		hph.grid[0][nibble] = sub.grid[0][nibble]
+		hph.touchMap[0] = hph.touchMap[0]&^bit | sub.touchMap[0]&bit
+		hph.afterMap[0] = hph.afterMap[0]&^bit | sub.afterMap[0]&bit
+		sub.ctx = nil
+	}
+	for row := 1; row < len(hph.depths); row++ {
+		latest := pf.nibble
+		for nibble := range pf.rows {
+			if pf.rows[nibble][row] > pf.rows[latest][row] {
+				latest = nibble
+			}
+		}
+		if latest == pf.nibble {
+			continue
+		}
+		sub := hph.subtries[latest]
+		hph.depths[row] = sub.depths[row]
+		hph.touchMap[row], hph.afterMap[row] = sub.touchMap[row], sub.afterMap[row]
+		hph.branchBefore[row] = sub.branchBefore[row]
+	}
+	return nil
+}
+
+type subtrieBranch struct {
+	data []byte
+	step uint64
+}
+
+type subtriePut struct {
+	prefix, data, prev []byte
+	prevStep           uint64
+}
+
+// subtrieContext - PatriciaContext of a subtrie grid: reads state through parallelFold and buffers branch updates
+type subtrieContext struct {
+	pf       *parallelFold
+	done     chan struct{}
+	branches map[string]subtrieBranch // branches updated by subtrie
+	puts     []subtriePut
+}
+
+func (sc *subtrieContext) call(fn func(pc PatriciaContext)) error {
+	select {
+	case sc.pf.calls <- subtrieCall{fn: fn, done: sc.done}:
+	case <-sc.pf.ctx.Done():
+		return sc.pf.ctx.Err()
+	}
+	select {
+	case <-sc.done:
+		return nil
+	case <-sc.pf.ctx.Done():
+		return sc.pf.ctx.Err()
+	}
+}
+
+func (sc *subtrieContext) Branch(prefix []byte) ([]byte, uint64, error) {
+	if b, ok := sc.branches[string(prefix)]; ok {
+		return b.data, b.step, nil
+	}
+	var data []byte
+	var step uint64
+	var err error
+	if cerr := sc.call(func(pc PatriciaContext) {
+		data, step, err = pc.Branch(prefix)
+		data = common.Copy(data)
+	}); cerr != nil {
+		return nil, 0, cerr
+	}
+	return data, step, err
+}
+
+func (sc *subtrieContext) PutBranch(prefix []byte, data []byte, prevData []byte, prevStep uint64) error {
+	sc.branches[string(prefix)] = subtrieBranch{data: data, step: prevStep}
+	sc.puts = append(sc.puts, subtriePut{prefix: prefix, data: data, prev: prevData, prevStep: prevStep})
+	return nil
+}
+
+func (sc *subtrieContext) Account(plainKey []byte) (*Update, error) {
+	var u *Update
+	var err error
+	if cerr := sc.call(func(pc PatriciaContext) { u, err = pc.Account(plainKey) }); cerr != nil {
+		return nil, cerr
+	}
+	return u, err
+}
+
+func (sc *subtrieContext) Storage(plainKey []byte) (*Update, error) {
+	var u *Update
+	var err error
+	if cerr := sc.call(func(pc PatriciaContext) { u, err = pc.Storage(plainKey) }); cerr != nil {
+		return nil, cerr
+	}
+	return u, err
+}
@@ -20,6 +20,7 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"math"
 	"math/rand"
 	"sort"
 	"testing"
@@ -1131,3 +1132,103 @@ func TestCell_setFromUpdate(t *testing.T) {
 	require.EqualValues(t, update.StorageLen, target.StorageLen)
 	require.EqualValues(t, update.Storage[:update.StorageLen], target.Storage[:target.StorageLen])
 }
+
+func Test_HexPatriciaHashed_ParallelFold(t *testing.T) {
+	ctx := context.Background()
+	rnd := rand.New(rand.NewSource(42))
+	defer func(v uint64) { parallelFoldMinUpdates = v }(parallelFoldMinUpdates)
+
+	var accounts, slots [][]byte // accounts without storage and storage keys
+	randomUpdates := func(newAccounts, changes, deletes int) (plainKeys [][]byte, updates []Update) {
+		add := func(key []byte, u Update) {
+			plainKeys = append(plainKeys, key)
+			updates = append(updates, u)
+		}
+		for i := 0; i < newAccounts; i++ {
+			addr := make([]byte, length.Addr)
+			rnd.Read(addr)
+			var u Update
+			u.Flags = BalanceUpdate | NonceUpdate
+			u.Balance.SetUint64(rnd.Uint64())
+			u.Nonce = uint64(i)
+			add(addr, u)
+			if i%10 == 0 {
+				for j := 0; j < 1+rnd.Intn(20); j++ {
+					loc := make([]byte, length.Hash)
+					rnd.Read(loc)
+					var s Update
+					s.Flags = StorageUpdate
+					s.StorageLen = 1 + rnd.Intn(length.Hash)
+					rnd.Read(s.Storage[:s.StorageLen])
+					slots = append(slots, append(common.Copy(addr), loc...))
+					add(slots[len(slots)-1], s)
+				}
+			} else {
+				accounts = append(accounts, addr)
+			}
+		}
+		for i := 0; i < changes; i++ {
+			var u Update
+			if i%2 == 0 {
+				u.Flags = StorageUpdate
+				u.StorageLen = 1 + rnd.Intn(length.Hash)
+				rnd.Read(u.Storage[:u.StorageLen])
+				add(slots[rnd.Intn(len(slots))], u)
+				continue
+			}
+			u.Flags = BalanceUpdate
+			u.Balance.SetUint64(rnd.Uint64())
+			add(accounts[rnd.Intn(len(accounts))], u)
+		}
+		for i := 0; i < deletes; i++ {
+			if i%4 == 0 {
+				add(slots[rnd.Intn(len(slots))], Update{Flags: DeleteUpdate})
+				continue
+			}
+			add(accounts[rnd.Intn(len(accounts))], Update{Flags: DeleteUpdate})
+		}
+		return plainKeys, updates
+	}
+
+	for _, mode := range []Mode{ModeDirect, ModeUpdate} {
+		t.Run(mode.String(), func(t *testing.T) {
+			accounts, slots = accounts[:0], slots[:0]
+			stateSeq, statePar := NewMockState(t), NewMockState(t)
+			trieSeq := NewHexPatriciaHashed(length.Addr, stateSeq, stateSeq.TempDir())
+			triePar := NewHexPatriciaHashed(length.Addr, statePar, statePar.TempDir())
+
+			for round, sizes := range [][3]int{{3000, 0, 0}, {500, 1000, 300}, {0, 5, 0}, {0, 0, 2000}, {10, 0, 0}} {
+				plainKeys, updates := randomUpdates(sizes[0], sizes[1], sizes[2])
+				// updates of the same key in one batch are merged
+				for i := range plainKeys {
+					require.NoError(t, stateSeq.applyPlainUpdates(plainKeys[i:i+1], updates[i:i+1]))
+					require.NoError(t, statePar.applyPlainUpdates(plainKeys[i:i+1], updates[i:i+1]))
+				}
+
+				parallelFoldMinUpdates = math.MaxUint64
+				upds := WrapKeyUpdates(t, mode, trieSeq.hashAndNibblizeKey, plainKeys, updates)
+				rootSeq, err := trieSeq.Process(ctx, upds, "")
+				require.NoError(t, err)
+				upds.Close()
+
+				parallelFoldMinUpdates = 1
+				upds = WrapKeyUpdates(t, mode, triePar.hashAndNibblizeKey, plainKeys, updates)
+				rootPar, err := triePar.Process(ctx, upds, "")
+				require.NoError(t, err)
+				upds.Close()
+
+				require.EqualValues(t, rootSeq, rootPar, "round %d", round)
+				require.Equal(t, len(stateSeq.cm), len(statePar.cm), "round %d", round)
+				for prefix, branch := range stateSeq.cm {
+					require.EqualValues(t, branch, statePar.cm[prefix], "round %d prefix %x", round, prefix)
+				}
+
+				stateSeqEnc, err := trieSeq.EncodeCurrentState(nil)
+				require.NoError(t, err)
+				stateParEnc, err := triePar.EncodeCurrentState(nil)
+				require.NoError(t, err)
+				require.EqualValues(t, stateSeqEnc, stateParEnc, "round %d", round)
+			}
+		})
+	}
+}
@@ -130,6 +130,50 @@ func (c *MockKVClientDomainRangeCall) DoAndReturn(f func(context.Context, *Domai
 	return c
 }
 
+// DomainRangeStream mocks base method.
+func (m *MockKVClient) DomainRangeStream(arg0 context.Context, arg1 *DomainRangeReq, arg2 ...grpc.CallOption) (KV_DomainRangeStreamClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "DomainRangeStream", varargs...)
+	ret0, _ := ret[0].(KV_DomainRangeStreamClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DomainRangeStream indicates an expected call of DomainRangeStream.
+func (mr *MockKVClientMockRecorder) DomainRangeStream(arg0, arg1 any, arg2 ...any) *MockKVClientDomainRangeStreamCall {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0, arg1}, arg2...)
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DomainRangeStream", reflect.TypeOf((*MockKVClient)(nil).DomainRangeStream), varargs...)
+	return &MockKVClientDomainRangeStreamCall{Call: call}
+}
+
+// MockKVClientDomainRangeStreamCall wrap *gomock.Call
+type MockKVClientDomainRangeStreamCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockKVClientDomainRangeStreamCall) Return(arg0 KV_DomainRangeStreamClient, arg1 error) *MockKVClientDomainRangeStreamCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockKVClientDomainRangeStreamCall) Do(f func(context.Context, *DomainRangeReq, ...grpc.CallOption) (KV_DomainRangeStreamClient, error)) *MockKVClientDomainRangeStreamCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockKVClientDomainRangeStreamCall) DoAndReturn(f func(context.Context, *DomainRangeReq, ...grpc.CallOption) (KV_DomainRangeStreamClient, error)) *MockKVClientDomainRangeStreamCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // HistoryRange mocks base method.
 func (m *MockKVClient) HistoryRange(arg0 context.Context, arg1 *HistoryRangeReq, arg2 ...grpc.CallOption) (*Pairs, error) {
 	m.ctrl.T.Helper()
@@ -174,6 +218,50 @@ func (c *MockKVClientHistoryRangeCall) DoAndReturn(f func(context.Context, *Hist
 	return c
 }
 
+// HistoryRangeStream mocks base method.
+func (m *MockKVClient) HistoryRangeStream(arg0 context.Context, arg1 *HistoryRangeReq, arg2 ...grpc.CallOption) (KV_HistoryRangeStreamClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "HistoryRangeStream", varargs...)
+	ret0, _ := ret[0].(KV_HistoryRangeStreamClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HistoryRangeStream indicates an expected call of HistoryRangeStream.
+func (mr *MockKVClientMockRecorder) HistoryRangeStream(arg0, arg1 any, arg2 ...any) *MockKVClientHistoryRangeStreamCall {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0, arg1}, arg2...)
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HistoryRangeStream", reflect.TypeOf((*MockKVClient)(nil).HistoryRangeStream), varargs...)
+	return &MockKVClientHistoryRangeStreamCall{Call: call}
+}
+
+// MockKVClientHistoryRangeStreamCall wrap *gomock.Call
+type MockKVClientHistoryRangeStreamCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockKVClientHistoryRangeStreamCall) Return(arg0 KV_HistoryRangeStreamClient, arg1 error) *MockKVClientHistoryRangeStreamCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockKVClientHistoryRangeStreamCall) Do(f func(context.Context, *HistoryRangeReq, ...grpc.CallOption) (KV_HistoryRangeStreamClient, error)) *MockKVClientHistoryRangeStreamCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockKVClientHistoryRangeStreamCall) DoAndReturn(f func(context.Context, *HistoryRangeReq, ...grpc.CallOption) (KV_HistoryRangeStreamClient, error)) *MockKVClientHistoryRangeStreamCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // HistorySeek mocks base method.
 func (m *MockKVClient) HistorySeek(arg0 context.Context, arg1 *HistorySeekReq, arg2 ...grpc.CallOption) (*HistorySeekReply, error) {
 	m.ctrl.T.Helper()
@@ -394,6 +482,50 @@ func (c *MockKVClientStateChangesCall) DoAndReturn(f func(context.Context, *Stat
 	return c
 }
 
+// StateDiff mocks base method.
+func (m *MockKVClient) StateDiff(arg0 context.Context, arg1 *StateDiffReq, arg2 ...grpc.CallOption) (KV_StateDiffClient, error) {
+	m.ctrl.T.Helper()
+	varargs := []any{arg0, arg1}
+	for _, a := range arg2 {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "StateDiff", varargs...)
+	ret0, _ := ret[0].(KV_StateDiffClient)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// StateDiff indicates an expected call of StateDiff.
+func (mr *MockKVClientMockRecorder) StateDiff(arg0, arg1 any, arg2 ...any) *MockKVClientStateDiffCall {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]any{arg0, arg1}, arg2...)
+	call := mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "StateDiff", reflect.TypeOf((*MockKVClient)(nil).StateDiff), varargs...)
+	return &MockKVClientStateDiffCall{Call: call}
+}
+
+// MockKVClientStateDiffCall wrap *gomock.Call
+type MockKVClientStateDiffCall struct {
+	*gomock.Call
+}
+
+// Return rewrite *gomock.Call.Return
+func (c *MockKVClientStateDiffCall) Return(arg0 KV_StateDiffClient, arg1 error) *MockKVClientStateDiffCall {
+	c.Call = c.Call.Return(arg0, arg1)
+	return c
+}
+
+// Do rewrite *gomock.Call.Do
+func (c *MockKVClientStateDiffCall) Do(f func(context.Context, *StateDiffReq, ...grpc.CallOption) (KV_StateDiffClient, error)) *MockKVClientStateDiffCall {
+	c.Call = c.Call.Do(f)
+	return c
+}
+
+// DoAndReturn rewrite *gomock.Call.DoAndReturn
+func (c *MockKVClientStateDiffCall) DoAndReturn(f func(context.Context, *StateDiffReq, ...grpc.CallOption) (KV_StateDiffClient, error)) *MockKVClientStateDiffCall {
+	c.Call = c.Call.DoAndReturn(f)
+	return c
+}
+
 // Tx mocks base method.
 func (m *MockKVClient) Tx(arg0 context.Context, arg1 ...grpc.CallOption) (KV_TxClient, error) {
 	m.ctrl.T.Helper()
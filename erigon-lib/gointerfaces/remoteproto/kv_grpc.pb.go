@@ -21,16 +21,19 @@ import (
 const _ = grpc.SupportPackageIsVersion8
 
 const (
-	KV_Version_FullMethodName      = "/remote.KV/Version"
-	KV_Tx_FullMethodName           = "/remote.KV/Tx"
-	KV_StateChanges_FullMethodName = "/remote.KV/StateChanges"
-	KV_Snapshots_FullMethodName    = "/remote.KV/Snapshots"
-	KV_Range_FullMethodName        = "/remote.KV/Range"
-	KV_DomainGet_FullMethodName    = "/remote.KV/DomainGet"
-	KV_HistorySeek_FullMethodName  = "/remote.KV/HistorySeek"
-	KV_IndexRange_FullMethodName   = "/remote.KV/IndexRange"
-	KV_HistoryRange_FullMethodName = "/remote.KV/HistoryRange"
-	KV_DomainRange_FullMethodName  = "/remote.KV/DomainRange"
+	KV_Version_FullMethodName            = "/remote.KV/Version"
+	KV_Tx_FullMethodName                 = "/remote.KV/Tx"
+	KV_StateChanges_FullMethodName       = "/remote.KV/StateChanges"
+	KV_Snapshots_FullMethodName          = "/remote.KV/Snapshots"
+	KV_Range_FullMethodName              = "/remote.KV/Range"
+	KV_DomainGet_FullMethodName          = "/remote.KV/DomainGet"
+	KV_HistorySeek_FullMethodName        = "/remote.KV/HistorySeek"
+	KV_IndexRange_FullMethodName         = "/remote.KV/IndexRange"
+	KV_HistoryRange_FullMethodName       = "/remote.KV/HistoryRange"
+	KV_DomainRange_FullMethodName        = "/remote.KV/DomainRange"
+	KV_StateDiff_FullMethodName          = "/remote.KV/StateDiff"
+	KV_DomainRangeStream_FullMethodName  = "/remote.KV/DomainRangeStream"
+	KV_HistoryRangeStream_FullMethodName = "/remote.KV/HistoryRangeStream"
 )
 
 // KVClient is the client API for KV service.
@@ -61,6 +64,12 @@ type KVClient interface {
 	IndexRange(ctx context.Context, in *IndexRangeReq, opts ...grpc.CallOption) (*IndexRangeReply, error)
 	HistoryRange(ctx context.Context, in *HistoryRangeReq, opts ...grpc.CallOption) (*Pairs, error)
 	DomainRange(ctx context.Context, in *DomainRangeReq, opts ...grpc.CallOption) (*Pairs, error)
+	// StateDiff streams keys of domains changed in [from_ts, to_ts) with values before and after the range
+	StateDiff(ctx context.Context, in *StateDiffReq, opts ...grpc.CallOption) (KV_StateDiffClient, error)
+	// DomainRangeStream - same as DomainRange, but streams pages: next page is read after client received previous one
+	DomainRangeStream(ctx context.Context, in *DomainRangeReq, opts ...grpc.CallOption) (KV_DomainRangeStreamClient, error)
+	// HistoryRangeStream - same as HistoryRange, but streams pages: next page is read after client received previous one
+	HistoryRangeStream(ctx context.Context, in *HistoryRangeReq, opts ...grpc.CallOption) (KV_HistoryRangeStreamClient, error)
 }
 
 type kVClient struct {
@@ -216,6 +225,105 @@ func (c *kVClient) DomainRange(ctx context.Context, in *DomainRangeReq, opts ...
 	return out, nil
 }
 
+func (c *kVClient) StateDiff(ctx context.Context, in *StateDiffReq, opts ...grpc.CallOption) (KV_StateDiffClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[2], KV_StateDiff_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVStateDiffClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_StateDiffClient interface {
+	Recv() (*StateDiffReply, error)
+	grpc.ClientStream
+}
+
+type kVStateDiffClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVStateDiffClient) Recv() (*StateDiffReply, error) {
+	m := new(StateDiffReply)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) DomainRangeStream(ctx context.Context, in *DomainRangeReq, opts ...grpc.CallOption) (KV_DomainRangeStreamClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[3], KV_DomainRangeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVDomainRangeStreamClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_DomainRangeStreamClient interface {
+	Recv() (*Pairs, error)
+	grpc.ClientStream
+}
+
+type kVDomainRangeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVDomainRangeStreamClient) Recv() (*Pairs, error) {
+	m := new(Pairs)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *kVClient) HistoryRangeStream(ctx context.Context, in *HistoryRangeReq, opts ...grpc.CallOption) (KV_HistoryRangeStreamClient, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &KV_ServiceDesc.Streams[4], KV_HistoryRangeStream_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &kVHistoryRangeStreamClient{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type KV_HistoryRangeStreamClient interface {
+	Recv() (*Pairs, error)
+	grpc.ClientStream
+}
+
+type kVHistoryRangeStreamClient struct {
+	grpc.ClientStream
+}
+
+func (x *kVHistoryRangeStreamClient) Recv() (*Pairs, error) {
+	m := new(Pairs)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 // KVServer is the server API for KV service.
 // All implementations must embed UnimplementedKVServer
 // for forward compatibility
@@ -244,6 +352,12 @@ type KVServer interface {
 	IndexRange(context.Context, *IndexRangeReq) (*IndexRangeReply, error)
 	HistoryRange(context.Context, *HistoryRangeReq) (*Pairs, error)
 	DomainRange(context.Context, *DomainRangeReq) (*Pairs, error)
+	// StateDiff streams keys of domains changed in [from_ts, to_ts) with values before and after the range
+	StateDiff(*StateDiffReq, KV_StateDiffServer) error
+	// DomainRangeStream - same as DomainRange, but streams pages: next page is read after client received previous one
+	DomainRangeStream(*DomainRangeReq, KV_DomainRangeStreamServer) error
+	// HistoryRangeStream - same as HistoryRange, but streams pages: next page is read after client received previous one
+	HistoryRangeStream(*HistoryRangeReq, KV_HistoryRangeStreamServer) error
 	mustEmbedUnimplementedKVServer()
 }
 
@@ -281,6 +395,15 @@ func (UnimplementedKVServer) HistoryRange(context.Context, *HistoryRangeReq) (*P
 func (UnimplementedKVServer) DomainRange(context.Context, *DomainRangeReq) (*Pairs, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method DomainRange not implemented")
 }
+func (UnimplementedKVServer) StateDiff(*StateDiffReq, KV_StateDiffServer) error {
+	return status.Errorf(codes.Unimplemented, "method StateDiff not implemented")
+}
+func (UnimplementedKVServer) DomainRangeStream(*DomainRangeReq, KV_DomainRangeStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method DomainRangeStream not implemented")
+}
+func (UnimplementedKVServer) HistoryRangeStream(*HistoryRangeReq, KV_HistoryRangeStreamServer) error {
+	return status.Errorf(codes.Unimplemented, "method HistoryRangeStream not implemented")
+}
 func (UnimplementedKVServer) mustEmbedUnimplementedKVServer() {}
 
 // UnsafeKVServer may be embedded to opt out of forward compatibility for this service.
@@ -485,6 +608,69 @@ func _KV_DomainRange_Handler(srv interface{}, ctx context.Context, dec func(inte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _KV_StateDiff_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StateDiffReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).StateDiff(m, &kVStateDiffServer{ServerStream: stream})
+}
+
+type KV_StateDiffServer interface {
+	Send(*StateDiffReply) error
+	grpc.ServerStream
+}
+
+type kVStateDiffServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVStateDiffServer) Send(m *StateDiffReply) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KV_DomainRangeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(DomainRangeReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).DomainRangeStream(m, &kVDomainRangeStreamServer{ServerStream: stream})
+}
+
+type KV_DomainRangeStreamServer interface {
+	Send(*Pairs) error
+	grpc.ServerStream
+}
+
+type kVDomainRangeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVDomainRangeStreamServer) Send(m *Pairs) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _KV_HistoryRangeStream_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(HistoryRangeReq)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(KVServer).HistoryRangeStream(m, &kVHistoryRangeStreamServer{ServerStream: stream})
+}
+
+type KV_HistoryRangeStreamServer interface {
+	Send(*Pairs) error
+	grpc.ServerStream
+}
+
+type kVHistoryRangeStreamServer struct {
+	grpc.ServerStream
+}
+
+func (x *kVHistoryRangeStreamServer) Send(m *Pairs) error {
+	return x.ServerStream.SendMsg(m)
+}
+
 // KV_ServiceDesc is the grpc.ServiceDesc for KV service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -537,6 +723,21 @@ var KV_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _KV_StateChanges_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StateDiff",
+			Handler:       _KV_StateDiff_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "DomainRangeStream",
+			Handler:       _KV_DomainRangeStream_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "HistoryRangeStream",
+			Handler:       _KV_HistoryRangeStream_Handler,
+			ServerStreams: true,
+		},
 	},
 	Metadata: "remote/kv.proto",
 }
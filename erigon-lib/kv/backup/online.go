@@ -0,0 +1,417 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package backup
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/common/dir"
+	"github.com/erigontech/erigon-lib/config3"
+	"github.com/erigontech/erigon-lib/downloader/snaptype"
+	"github.com/erigontech/erigon-lib/kv"
+	mdbx2 "github.com/erigontech/erigon-lib/kv/mdbx"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+	"github.com/erigontech/erigon-lib/log/v3"
+)
+
+const ManifestFileName = "backup.json"
+
+// Manifest - description of complete backup. Stored in root of backup datadir as last step of backup:
+// backup without manifest is incomplete and can't be restored.
+type Manifest struct {
+	ViewID  uint64            `json:"viewID"` // id of chaindata read transaction backup was made from
+	Tables  map[string]uint64 `json:"tables"` // table -> id of last transaction which modified it
+	Files   []string          `json:"files"`  // files of snapshots dir, relative to it
+	Created time.Time         `json:"created"`
+}
+
+// ReadManifest - returns nil if `dataDir` has no complete backup
+func ReadManifest(dataDir string) (*Manifest, error) {
+	b, err := os.ReadFile(filepath.Join(dataDir, ManifestFileName))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(b, m); err != nil {
+		return nil, fmt.Errorf("%s: %w", ManifestFileName, err)
+	}
+	return m, nil
+}
+
+func (m *Manifest) write(dataDir string) error {
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := filepath.Join(dataDir, ManifestFileName+".tmp")
+	if err := os.WriteFile(tmp, b, 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, filepath.Join(dataDir, ManifestFileName))
+}
+
+// Online - point-in-time backup of chaindata and snapshots of running Erigon.
+//
+// Consistency: all chaindata tables are read by one read transaction. Snapshot files are immutable -
+// they are hard-linked (or copied if backup is on another filesystem) right after the read transaction
+// is opened, before tables are copied: so data which Erigon moves from chaindata to files is in backup
+// either way. State files ahead of execution progress of the transaction are skipped. If Erigon merged
+// state files across execution progress of the transaction - files it replaced may be already removed:
+// then the transaction is re-opened.
+//
+// Incremental: if `to` has complete backup - only tables modified since it are copied (by id of
+// last transaction which modified table) and only new files are linked. Modified table is copied in full:
+// MDBX doesn't tell which pages changed. Files which Erigon removed (merged) are removed from backup.
+func Online(ctx context.Context, from, to datadir.Dirs, readAheadThreads int, logger log.Logger) (*Manifest, error) {
+	prev, err := ReadManifest(to.DataDir)
+	if err != nil {
+		return nil, err
+	}
+	// until new manifest is written - backup is incomplete
+	if err := os.Remove(filepath.Join(to.DataDir, ManifestFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	if prev == nil {
+		if err := os.RemoveAll(to.Chaindata); err != nil {
+			return nil, err
+		}
+		if err := os.MkdirAll(to.Chaindata, 0740); err != nil {
+			return nil, err
+		}
+	}
+
+	src, dst := OpenPair(from.Chaindata, to.Chaindata, kv.ChainDB, 0, logger)
+	defer src.Close()
+	defer dst.Close()
+
+	srcTx, files, err := beginWithSnapshots(ctx, src, from.Snap, to.Snap, logger)
+	if err != nil {
+		return nil, err
+	}
+	defer srcTx.Rollback()
+
+	m := &Manifest{ViewID: srcTx.ViewID(), Tables: map[string]uint64{}, Files: files, Created: time.Now().UTC()}
+	logEvery := time.NewTicker(20 * time.Second)
+	defer logEvery.Stop()
+
+	var copied, skipped int
+	for name, cfg := range src.AllTables() {
+		if cfg.IsDeprecated {
+			continue
+		}
+		modTxId, err := tableModTxId(srcTx, name)
+		if err != nil {
+			return nil, fmt.Errorf("table %s: %w", name, err)
+		}
+		m.Tables[name] = modTxId
+		if prevTxId, ok := prev.table(name); ok && modTxId != 0 && prevTxId == modTxId {
+			skipped++
+			continue
+		}
+		if err := backupTable(ctx, src, srcTx, dst, name, readAheadThreads, logEvery, logger); err != nil {
+			return nil, err
+		}
+		copied++
+	}
+	logger.Info("[backup] chaindata", "copied_tables", copied, "unchanged_tables", skipped, "view_id", m.ViewID)
+
+	if err := m.write(to.DataDir); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+var errStateFilesMerged = errors.New("state files were merged across execution progress")
+
+// beginWithSnapshots - opens read transaction and links snapshot files which match it
+func beginWithSnapshots(ctx context.Context, src kv.RoDB, fromSnap, toSnap string, logger log.Logger) (kv.Tx, []string, error) {
+	const attempts = 10
+	for i := 0; i < attempts; i++ {
+		tx, err := src.BeginRo(ctx)
+		if err != nil {
+			return nil, nil, err
+		}
+		maxTxNum, err := executedTxNum(tx)
+		if err != nil {
+			tx.Rollback()
+			return nil, nil, err
+		}
+		files, err := linkSnapshots(fromSnap, toSnap, maxTxNum, logger)
+		if err != nil {
+			tx.Rollback()
+			if errors.Is(err, errStateFilesMerged) {
+				// files are merged only after execution passed them: new transaction sees it
+				continue
+			}
+			return nil, nil, err
+		}
+		return tx, files, nil
+	}
+	return nil, nil, fmt.Errorf("snapshots dir %s: %w too often", fromSnap, errStateFilesMerged)
+}
+
+// tableModTxId - id of last transaction which modified table, 0 if table wasn't created yet
+func tableModTxId(tx kv.Tx, name string) (uint64, error) {
+	return tx.(*mdbx2.MdbxTx).TableModTxId(name)
+}
+
+func (m *Manifest) table(name string) (uint64, bool) {
+	if m == nil {
+		return 0, false
+	}
+	id, ok := m.Tables[name]
+	return id, ok
+}
+
+// executedTxNum - amount of txs which state of `tx` includes
+func executedTxNum(tx kv.Tx) (uint64, error) {
+	v, err := tx.GetOne(kv.SyncStageProgress, []byte("Execution")) //TODO: move stages to erigon-lib
+	if err != nil {
+		return 0, err
+	}
+	if len(v) < 8 {
+		return 0, nil
+	}
+	maxTxNum, err := rawdbv3.TxNums.Max(tx, binary.BigEndian.Uint64(v))
+	if err != nil {
+		return 0, err
+	}
+	return maxTxNum + 1, nil
+}
+
+// linkSnapshots - makes `to` contain same files as `from`, except state files ahead of `maxTxNum`.
+// Erigon may remove (merge) files while they are linked: then list of files is read again.
+func linkSnapshots(from, to string, maxTxNum uint64, logger log.Logger) ([]string, error) {
+	const attempts = 10
+	for i := 0; i < attempts; i++ {
+		files, err := listSnapshots(from, maxTxNum)
+		if err != nil {
+			return nil, err
+		}
+		var linked int
+		missing := false
+		for _, f := range files {
+			ok, created, err := linkOrCopy(filepath.Join(from, f), filepath.Join(to, f), isImmutable(f))
+			if err != nil {
+				return nil, err
+			}
+			if !ok {
+				missing = true
+				break
+			}
+			if created {
+				linked++
+			}
+		}
+		if missing {
+			continue
+		}
+		removed, err := removeUnlisted(to, files)
+		if err != nil {
+			return nil, err
+		}
+		logger.Info("[backup] snapshots", "files", len(files), "new", linked, "removed", removed)
+		return files, nil
+	}
+	return nil, fmt.Errorf("snapshots dir %s: files are changing too fast", from)
+}
+
+func listSnapshots(snapDir string, maxTxNum uint64) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(snapDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		name := d.Name()
+		if d.IsDir() {
+			if name == "tmp" || name == "db" { // temporary files and downloader's db (it's re-created)
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || strings.HasSuffix(name, ".tmp") || (strings.HasSuffix(name, ".lock") && name != "prohibit_new_downloads.lock") {
+			return nil
+		}
+		if res, isStateFile, ok := snaptype.ParseFileName("", strings.TrimSuffix(name, ".torrent")); ok && isStateFile &&
+			res.To*config3.HistoryV3AggregationStep > maxTxNum {
+			// file of one step is built after execution passed it: its data is still in chaindata of the transaction.
+			// merged file replaces files of previous steps, which may be already removed
+			if res.To-res.From > 1 && res.From*config3.HistoryV3AggregationStep < maxTxNum {
+				return fmt.Errorf("%w: %s", errStateFilesMerged, name)
+			}
+			return nil
+		}
+		rel, err := filepath.Rel(snapDir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, rel)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+var blockFileRegex = regexp.MustCompile("^v[0-9.]+-[0-9]+-[0-9]+-")
+
+// isImmutable - files with block or step range in name never change, other files (like salt) are copied
+func isImmutable(file string) bool {
+	name := strings.TrimSuffix(filepath.Base(file), ".torrent")
+	return blockFileRegex.MatchString(name) || snaptype.IsStateFile(name)
+}
+
+// linkOrCopy - !ok if `from` doesn't exist anymore. Existing immutable files are not touched.
+func linkOrCopy(from, to string, immutable bool) (ok, created bool, err error) {
+	if immutable {
+		exists, err := dir.FileExist(to)
+		if err != nil {
+			return false, false, err
+		}
+		if exists {
+			return true, false, nil
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(to), 0755); err != nil {
+		return false, false, err
+	}
+	if immutable {
+		if err := os.Link(from, to); err == nil {
+			return true, true, nil
+		} else if errors.Is(err, os.ErrNotExist) {
+			return false, false, nil
+		}
+		// another filesystem: copy
+	}
+	if err := copyFile(from, to); err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return false, false, nil
+		}
+		return false, false, err
+	}
+	return true, true, nil
+}
+
+func copyFile(from, to string) error {
+	r, err := os.Open(from)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+	tmp := to + ".tmp"
+	w, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(w, r); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Sync(); err != nil {
+		w.Close()
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, to)
+}
+
+// removeUnlisted - removes files of `snapDir` which are not in `files`
+func removeUnlisted(snapDir string, files []string) (removed int, err error) {
+	keep := make(map[string]struct{}, len(files))
+	for _, f := range files {
+		keep[f] = struct{}{}
+	}
+	err = filepath.WalkDir(snapDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(snapDir, path)
+		if err != nil {
+			return err
+		}
+		if _, ok := keep[rel]; ok {
+			return nil
+		}
+		removed++
+		return os.Remove(path)
+	})
+	return removed, err
+}
+
+// Restore - creates datadir `to` from complete backup `from`. Chaindata is copied: it's mutable.
+// Snapshot files are hard-linked if possible: Erigon never modifies them.
+func Restore(ctx context.Context, from, to datadir.Dirs, logger log.Logger) error {
+	m, err := ReadManifest(from.DataDir)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return fmt.Errorf("%s has no complete backup: %s not found", from.DataDir, ManifestFileName)
+	}
+	exists, err := dir.FileExist(filepath.Join(to.Chaindata, "mdbx.dat"))
+	if err != nil {
+		return err
+	}
+	if exists {
+		return fmt.Errorf("%s already has chaindata", to.DataDir)
+	}
+
+	logger.Info("[restore] chaindata", "view_id", m.ViewID, "created", m.Created)
+	if err := copyFile(filepath.Join(from.Chaindata, "mdbx.dat"), filepath.Join(to.Chaindata, "mdbx.dat")); err != nil {
+		return err
+	}
+	for _, f := range m.Files {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ok, _, err := linkOrCopy(filepath.Join(from.Snap, f), filepath.Join(to.Snap, f), isImmutable(f))
+		if err != nil {
+			return err
+		}
+		if !ok {
+			return fmt.Errorf("backup is damaged: %s not found", f)
+		}
+	}
+	logger.Info("[restore] snapshots", "files", len(m.Files))
+	return nil
+}
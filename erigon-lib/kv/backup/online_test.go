@@ -0,0 +1,129 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package backup
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/config3"
+	"github.com/erigontech/erigon-lib/kv"
+	mdbx2 "github.com/erigontech/erigon-lib/kv/mdbx"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+	"github.com/erigontech/erigon-lib/log/v3"
+)
+
+func TestOnline(t *testing.T) {
+	ctx, logger := context.Background(), log.New()
+	from, to, restored := datadir.New(t.TempDir()), datadir.New(t.TempDir()), datadir.New(t.TempDir())
+
+	put := func(dirs datadir.Dirs, table string, k, v []byte) {
+		db := mdbx2.NewMDBX(logger).Path(dirs.Chaindata).Label(kv.ChainDB).MustOpen()
+		defer db.Close()
+		require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error { return tx.Put(table, k, v) }))
+	}
+	get := func(dirs datadir.Dirs, table string, k []byte) (v []byte) {
+		db := mdbx2.NewMDBX(logger).Path(dirs.Chaindata).Label(kv.ChainDB).Readonly().MustOpen()
+		defer db.Close()
+		require.NoError(t, db.View(ctx, func(tx kv.Tx) (err error) {
+			v, err = tx.GetOne(table, k)
+			v = common.Copy(v)
+			return err
+		}))
+		return v
+	}
+	writeFile := func(path string) {
+		require.NoError(t, os.WriteFile(path, []byte(filepath.Base(path)), 0644))
+	}
+
+	put(from, kv.Headers, []byte{1}, []byte{1})
+	put(from, kv.HeaderNumber, []byte{2}, []byte{2})
+	seg := filepath.Join(from.Snap, "v1-000000-000500-headers.seg")
+	writeFile(seg)
+	writeFile(filepath.Join(from.Snap, "salt-blocks.txt"))
+	writeFile(filepath.Join(from.SnapDomain, "v1-accounts.0-1.kv")) // ahead of execution progress
+
+	m, err := Online(ctx, from, to, 1, logger)
+	require.NoError(t, err)
+	require.Equal(t, []string{"salt-blocks.txt", "v1-000000-000500-headers.seg"}, m.Files)
+	fromInfo, err := os.Stat(seg)
+	require.NoError(t, err)
+	toInfo, err := os.Stat(filepath.Join(to.Snap, "v1-000000-000500-headers.seg"))
+	require.NoError(t, err)
+	require.True(t, os.SameFile(fromInfo, toInfo))
+	require.Equal(t, []byte{1}, get(to, kv.Headers, []byte{1}))
+
+	// incremental: only modified table is copied, merged file replaces old one
+	put(to, kv.Headers, []byte{9}, []byte{9})
+	put(to, kv.HeaderNumber, []byte{9}, []byte{9})
+	put(from, kv.Headers, []byte{1}, []byte{3})
+	require.NoError(t, os.Remove(seg))
+	writeFile(filepath.Join(from.Snap, "v1-000000-001000-headers.seg"))
+
+	m2, err := Online(ctx, from, to, 1, logger)
+	require.NoError(t, err)
+	require.NotZero(t, m.Tables[kv.HeaderNumber])
+	require.NotEqual(t, m.Tables[kv.Headers], m2.Tables[kv.Headers])
+	require.Equal(t, m.Tables[kv.HeaderNumber], m2.Tables[kv.HeaderNumber])
+	require.Nil(t, get(to, kv.Headers, []byte{9}))                   // copied again
+	require.Equal(t, []byte{9}, get(to, kv.HeaderNumber, []byte{9})) // skipped
+	require.Equal(t, []string{"salt-blocks.txt", "v1-000000-001000-headers.seg"}, m2.Files)
+	require.NoFileExists(t, filepath.Join(to.Snap, "v1-000000-000500-headers.seg"))
+	require.Equal(t, []byte{3}, get(to, kv.Headers, []byte{1}))
+	require.Equal(t, []byte{2}, get(to, kv.HeaderNumber, []byte{2}))
+
+	require.NoError(t, Restore(ctx, to, restored, logger))
+	require.Equal(t, []byte{3}, get(restored, kv.Headers, []byte{1}))
+	require.FileExists(t, filepath.Join(restored.Snap, "v1-000000-001000-headers.seg"))
+	require.Error(t, Restore(ctx, to, restored, logger)) // chaindata already exists
+}
+
+func TestOnlineMergedStateFiles(t *testing.T) {
+	ctx, logger := context.Background(), log.New()
+	from, to := datadir.New(t.TempDir()), datadir.New(t.TempDir())
+
+	// executed: 1.5 steps
+	db := mdbx2.NewMDBX(logger).Path(from.Chaindata).Label(kv.ChainDB).MustOpen()
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		if err := tx.Put(kv.SyncStageProgress, []byte("Execution"), hexutility.EncodeTs(1)); err != nil {
+			return err
+		}
+		return rawdbv3.TxNums.Append(tx, 1, config3.HistoryV3AggregationStep*3/2)
+	}))
+	db.Close()
+
+	// file of not executed step is skipped
+	require.NoError(t, os.WriteFile(filepath.Join(from.SnapDomain, "v1-accounts.0-1.kv"), []byte{1}, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(from.SnapDomain, "v1-accounts.1-2.kv"), []byte{1}, 0644))
+	m, err := Online(ctx, from, to, 1, logger)
+	require.NoError(t, err)
+	require.Equal(t, []string{filepath.Join("domain", "v1-accounts.0-1.kv")}, m.Files)
+
+	// merged file replaced executed step: backup can't be consistent with the transaction
+	require.NoError(t, os.Remove(filepath.Join(from.SnapDomain, "v1-accounts.0-1.kv")))
+	require.NoError(t, os.Remove(filepath.Join(from.SnapDomain, "v1-accounts.1-2.kv")))
+	require.NoError(t, os.WriteFile(filepath.Join(from.SnapDomain, "v1-accounts.0-2.kv"), []byte{1}, 0644))
+	_, err = Online(ctx, from, to, 1, logger)
+	require.ErrorIs(t, err, errStateFilesMerged)
+}
@@ -17,6 +17,7 @@
 package kv
 
 import (
+	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -29,6 +30,8 @@ import (
 	"github.com/erigontech/mdbx-go/mdbx"
 
 	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv/order"
+	"github.com/erigontech/erigon-lib/kv/stream"
 )
 
 func DefaultPageSize() uint64 {
@@ -237,3 +240,59 @@ func IncrementKey(tx RwTx, table string, k []byte) error {
 	version++
 	return tx.Put(table, k, hexutility.EncodeTs(version))
 }
+
+// StateDiff - walks over keys of `domain` changed in [fromTs, toTs) in ascending order, starting from `fromKey`.
+// If `tx` implements HistoryRangerFromKey - history of keys before `fromKey` is not read: pages are cheap to continue.
+// `before` is value at `fromTs`, `after` is value at `toTs` (latest value if toTs < 0), empty value means: key doesn't exist.
+// Keys which got back their value at `fromTs` are skipped. Non-empty `prefixes` filter keys.
+// k, before, after are valid only inside `walker`. Walking stops when `walker` returns false.
+func StateDiff(tx TemporalTx, domain Domain, fromTs, toTs int, fromKey []byte, prefixes [][]byte, walker func(k, before, after []byte) (bool, error)) error {
+	var it stream.KV
+	var err error
+	if r, ok := tx.(HistoryRangerFromKey); ok {
+		it, err = r.HistoryRangeFromKey(domain.History(), fromKey, fromTs, toTs, order.Asc, Unlim)
+	} else {
+		it, err = tx.HistoryRange(domain.History(), fromTs, toTs, order.Asc, Unlim)
+	}
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+	for it.HasNext() {
+		k, before, err := it.Next()
+		if err != nil {
+			return err
+		}
+		if bytes.Compare(k, fromKey) < 0 || !hasAnyPrefix(k, prefixes) {
+			continue
+		}
+		var after []byte
+		if toTs < 0 {
+			after, _, err = tx.DomainGet(domain, k, nil)
+		} else {
+			after, _, err = tx.DomainGetAsOf(domain, k, nil, uint64(toTs))
+		}
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(before, after) {
+			continue
+		}
+		if ok, err := walker(k, before, after); err != nil || !ok {
+			return err
+		}
+	}
+	return nil
+}
+
+func hasAnyPrefix(k []byte, prefixes [][]byte) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, prefix := range prefixes {
+		if bytes.HasPrefix(k, prefix) {
+			return true
+		}
+	}
+	return false
+}
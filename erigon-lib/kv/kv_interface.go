@@ -580,6 +580,11 @@ type TemporalTx interface {
 	AppendableGet(name Appendable, ts TxnId) ([]byte, bool, error)
 }
 
+// HistoryRangerFromKey - TemporalTx which can start HistoryRange from given key without reading history of smaller keys
+type HistoryRangerFromKey interface {
+	HistoryRangeFromKey(name History, fromKey []byte, fromTs, toTs int, asc order.By, limit int) (it stream.KV, err error)
+}
+
 type TxnId uint64 // internal auto-increment ID. can't cast to eth-network canonical blocks txNum
 
 type TemporalCommitment interface {
@@ -0,0 +1,55 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package kvtest
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/internal/testhooks"
+)
+
+// RegisterInvertedIdx - kv.RegisterInvertedIdx which is undone on test cleanup. As in real use, call it before
+// creation of db and Aggregator. Indices registered by nested tests are removed first.
+func RegisterInvertedIdx(tb testing.TB, name kv.InvertedIdx, filenameBase string) kv.InvertedIdxPos {
+	tb.Helper()
+	pos := kv.RegisterInvertedIdx(name, filenameBase)
+	tb.Cleanup(func() {
+		idxs := kv.UserInvertedIdxs()
+		if len(idxs) == 0 || idxs[len(idxs)-1].Pos != pos {
+			panic(fmt.Sprintf("kvtest: %s is not last registered index", pos))
+		}
+		testhooks.UnregisterLastInvertedIdx()
+	})
+	return pos
+}
+
+// RegisterDomain - kv.RegisterDomain which is undone on test cleanup. As in real use, call it before
+// creation of db and Aggregator. Domains registered by nested tests are removed first.
+func RegisterDomain(tb testing.TB, name, filenameBase string) kv.Domain {
+	tb.Helper()
+	d := kv.RegisterDomain(name, filenameBase)
+	tb.Cleanup(func() {
+		domains := kv.UserDomains()
+		if len(domains) == 0 || domains[len(domains)-1].Name != d {
+			panic(fmt.Sprintf("kvtest: %s is not last registered domain", d))
+		}
+		testhooks.UnregisterLastDomain()
+	})
+	return d
+}
@@ -0,0 +1,48 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package kvtest
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/kv"
+)
+
+func TestRegisterDomainCleanup(t *testing.T) {
+	domainsLen, tablesLen := kv.DomainsLen(), len(kv.ChaindataTables)
+	t.Run("register", func(t *testing.T) {
+		d := RegisterDomain(t, "TestKvtestBalances", "testkvtestbalances")
+		require.Equal(t, domainsLen+1, kv.DomainsLen())
+		cfg, ok := kv.UserDomain(d)
+		require.True(t, ok)
+		require.Contains(t, kv.ChaindataTables, cfg.ValuesTable)
+		require.Contains(t, kv.ChaindataTablesCfg, cfg.ValuesTable)
+		RegisterInvertedIdx(t, "TestKvtestTransfers", "testkvtesttransfers")
+	})
+	require.Equal(t, domainsLen, kv.DomainsLen())
+	require.Len(t, kv.ChaindataTables, tablesLen)
+	require.True(t, slices.IsSorted(kv.ChaindataTables))
+	for _, table := range []string{"TestKvtestBalancesVals", "TestKvtestBalancesIdx", "TestKvtestTransfers", "TestKvtestTransfersKeys"} {
+		require.NotContains(t, kv.ChaindataTables, table)
+		require.NotContains(t, kv.ChaindataTablesCfg, table)
+	}
+	_, ok := kv.InvertedIdxPosByName("TestKvtestTransfers")
+	require.False(t, ok)
+}
@@ -1310,6 +1310,29 @@ func (tx *MdbxTx) BucketStat(name string) (*mdbx.Stat, error) {
 	return st, nil
 }
 
+// mdbxTreeSize - size of MDBX_db: record of table in main DBI, see mdbxdist/mdbx.c
+const mdbxTreeSize = 48
+
+// TableModTxId - id of last committed transaction which modified table (ms_mod_txnid of mdbx_dbi_stat),
+// 0 if table doesn't exist. mdbx-go's StatDBI doesn't fill Stat.LastTxId: it's read from record of table.
+func (tx *MdbxTx) TableModTxId(name string) (uint64, error) {
+	root, err := tx.tx.OpenRoot(0)
+	if err != nil {
+		return 0, err
+	}
+	v, err := tx.tx.Get(root, []byte(name))
+	if err != nil {
+		if mdbx.IsNotFound(err) {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("table: %s, %w", name, err)
+	}
+	if len(v) != mdbxTreeSize {
+		return 0, fmt.Errorf("table: %s, unexpected size of record: %d", name, len(v))
+	}
+	return binary.NativeEndian.Uint64(v[mdbxTreeSize-8:]), nil // md_mod_txnid is last field
+}
+
 func (tx *MdbxTx) DBSize() (uint64, error) {
 	info, err := tx.db.env.Info(tx.tx)
 	if err != nil {
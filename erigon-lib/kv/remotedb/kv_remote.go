@@ -21,6 +21,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
 	"runtime"
 	"unsafe"
 
@@ -638,17 +639,17 @@ func (tx *tx) DomainGet(name kv.Domain, k, k2 []byte) (v []byte, step uint64, er
 	if err != nil {
 		return nil, 0, err
 	}
-	return reply.V, 0, nil
+	return reply.V, 0, nil // step is not sent by server
 }
 
 func (tx *tx) DomainRange(name kv.Domain, fromKey, toKey []byte, ts uint64, asc order.By, limit int) (it stream.KV, err error) {
-	return stream.PaginateKV(func(pageToken string) (keys, vals [][]byte, nextPageToken string, err error) {
-		reply, err := tx.db.remoteKV.DomainRange(tx.ctx, &remote.DomainRangeReq{TxId: tx.id, Table: name.String(), FromKey: fromKey, ToKey: toKey, Ts: ts, OrderAscend: bool(asc), Limit: int64(limit)})
-		if err != nil {
-			return nil, nil, "", err
-		}
-		return reply.Keys, reply.Values, reply.NextPageToken, nil
-	}), nil
+	ctx, cancel := context.WithCancel(tx.ctx)
+	s, err := tx.db.remoteKV.DomainRangeStream(ctx, &remote.DomainRangeReq{TxId: tx.id, Table: name.String(), FromKey: fromKey, ToKey: toKey, Ts: ts, OrderAscend: bool(asc), Limit: int64(limit)})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return tx.newPairsStream(s.Recv, cancel), nil
 }
 func (tx *tx) HistorySeek(name kv.History, k []byte, ts uint64) (v []byte, ok bool, err error) {
 	reply, err := tx.db.remoteKV.HistorySeek(tx.ctx, &remote.HistorySeekReq{TxId: tx.id, Table: string(name), K: k, Ts: ts})
@@ -658,13 +659,13 @@ func (tx *tx) HistorySeek(name kv.History, k []byte, ts uint64) (v []byte, ok bo
 	return reply.V, reply.Ok, nil
 }
 func (tx *tx) HistoryRange(name kv.History, fromTs, toTs int, asc order.By, limit int) (it stream.KV, err error) {
-	return stream.PaginateKV(func(pageToken string) (keys, vals [][]byte, nextPageToken string, err error) {
-		reply, err := tx.db.remoteKV.HistoryRange(tx.ctx, &remote.HistoryRangeReq{TxId: tx.id, Table: string(name), FromTs: int64(fromTs), ToTs: int64(toTs), OrderAscend: bool(asc), Limit: int64(limit)})
-		if err != nil {
-			return nil, nil, "", err
-		}
-		return reply.Keys, reply.Values, reply.NextPageToken, nil
-	}), nil
+	ctx, cancel := context.WithCancel(tx.ctx)
+	s, err := tx.db.remoteKV.HistoryRangeStream(ctx, &remote.HistoryRangeReq{TxId: tx.id, Table: string(name), FromTs: int64(fromTs), ToTs: int64(toTs), OrderAscend: bool(asc), Limit: int64(limit)})
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	return tx.newPairsStream(s.Recv, cancel), nil
 }
 
 func (tx *tx) IndexRange(name kv.InvertedIdx, k []byte, fromTs, toTs int, asc order.By, limit int) (timestamps stream.U64, err error) {
@@ -678,7 +679,7 @@ func (tx *tx) IndexRange(name kv.InvertedIdx, k []byte, fromTs, toTs int, asc or
 	}), nil
 }
 func (tx *tx) AppendableGet(name kv.Appendable, ts kv.TxnId) ([]byte, bool, error) {
-	panic("not implemented yet")
+	return nil, false, fmt.Errorf("remote db doesn't support appendable %s", name)
 }
 
 func (tx *tx) Prefix(table string, prefix []byte) (stream.KV, error) {
@@ -715,3 +716,54 @@ func (tx *tx) RangeDupSort(table string, key []byte, fromPrefix, toPrefix []byte
 func (tx *tx) CHandle() unsafe.Pointer {
 	panic("CHandle not implemented")
 }
+
+// pairsStream - stream.KV over server-streaming RPC. Next page is received only when previous one is consumed:
+// server doesn't read ahead of client (gRPC flow control).
+type pairsStream struct {
+	recv         func() (*remote.Pairs, error)
+	cancel       context.CancelFunc
+	keys, values [][]byte
+	i            int
+	err          error
+	done         bool
+}
+
+// newPairsStream - stream is closed by tx.Rollback if user didn't close it
+func (tx *tx) newPairsStream(recv func() (*remote.Pairs, error), cancel context.CancelFunc) *pairsStream {
+	s := &pairsStream{recv: recv, cancel: cancel}
+	tx.streams = append(tx.streams, s)
+	return s
+}
+
+func (s *pairsStream) HasNext() bool {
+	for s.i >= len(s.keys) && !s.done && s.err == nil {
+		reply, err := s.recv()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				s.Close()
+			} else {
+				s.err = err
+			}
+			break
+		}
+		s.keys, s.values, s.i = reply.Keys, reply.Values, 0
+	}
+	return s.err != nil || s.i < len(s.keys)
+}
+
+func (s *pairsStream) Next() ([]byte, []byte, error) {
+	if s.err != nil {
+		return nil, nil, s.err
+	}
+	k, v := s.keys[s.i], s.values[s.i]
+	s.i++
+	return k, v, nil
+}
+
+func (s *pairsStream) Close() {
+	if s.done {
+		return
+	}
+	s.done = true
+	s.cancel()
+}
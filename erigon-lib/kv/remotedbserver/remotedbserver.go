@@ -17,6 +17,7 @@
 package remotedbserver
 
 import (
+	"bytes"
 	"context"
 	"encoding/base64"
 	"errors"
@@ -63,7 +64,8 @@ const MaxTxTTL = 60 * time.Second
 // 6.0.0 - Blocks now have system-txs - in the begin/end of block
 // 6.1.0 - Add methods Range, IndexRange, HistorySeek, HistoryRange
 // 6.2.0 - Add HistoryFiles to reply of Snapshots() method
-var KvServiceAPIVersion = &types.VersionReply{Major: 7, Minor: 0, Patch: 0}
+// 7.1.0 - Add methods DomainRangeStream, HistoryRangeStream. remotedb reads DomainRange, HistoryRange by them
+var KvServiceAPIVersion = &types.VersionReply{Major: 7, Minor: 1, Patch: 0}
 
 type KvServer struct {
 	remote.UnimplementedKVServer // must be embedded to have forward compatible implementations.
@@ -654,6 +656,214 @@ func (s *KvServer) HistoryRange(_ context.Context, req *remote.HistoryRangeReq)
 	return reply, nil
 }
 
+func (s *KvServer) DomainRange(_ context.Context, req *remote.DomainRangeReq) (*remote.Pairs, error) {
+	domain, err := kv.String2Domain(req.Table)
+	if err != nil {
+		return nil, err
+	}
+	from, limit := req.FromKey, int(req.Limit)
+	if req.PageToken != "" {
+		var pagination remote.ParisPagination
+		if err := unmarshalPagination(req.PageToken, &pagination); err != nil {
+			return nil, err
+		}
+		from, limit = pagination.NextKey, int(pagination.Limit)
+	}
+	if limit <= 0 {
+		limit = -1
+	}
+	if req.PageSize <= 0 || req.PageSize > PageSizeLimit {
+		req.PageSize = PageSizeLimit
+	}
+
+	reply := &remote.Pairs{}
+	if err := s.with(req.TxId, func(tx kv.Tx) error {
+		ttx, ok := tx.(kv.TemporalTx)
+		if !ok {
+			return errors.New("server DB doesn't implement kv.Temporal interface")
+		}
+		it, err := ttx.DomainRange(domain, from, req.ToKey, req.Ts, order.By(req.OrderAscend), limit)
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		for len(reply.Keys) < int(req.PageSize) && it.HasNext() {
+			k, v, err := it.Next()
+			if err != nil {
+				return err
+			}
+			reply.Keys = append(reply.Keys, bytesCopy(k))
+			reply.Values = append(reply.Values, bytesCopy(v))
+			if limit > 0 {
+				limit--
+			}
+		}
+		if it.HasNext() {
+			nextK, _, err := it.Next()
+			if err != nil {
+				return err
+			}
+			reply.NextPageToken, err = marshalPagination(&remote.ParisPagination{NextKey: bytesCopy(nextK), Limit: int64(limit)})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+func (s *KvServer) DomainRangeStream(req *remote.DomainRangeReq, server remote.KV_DomainRangeStreamServer) error {
+	domain, err := kv.String2Domain(req.Table)
+	if err != nil {
+		return err
+	}
+	asc := order.By(req.OrderAscend)
+	return s.streamPairs(req.TxId, req.PageSize, asc, int(req.Limit), func(ttx kv.TemporalTx, after []byte) (stream.KV, error) {
+		from := req.FromKey
+		if after != nil {
+			from = after // inclusive: `streamPairs` skips it
+		}
+		return ttx.DomainRange(domain, from, req.ToKey, req.Ts, asc, -1)
+	}, server.Send)
+}
+
+func (s *KvServer) HistoryRangeStream(req *remote.HistoryRangeReq, server remote.KV_HistoryRangeStreamServer) error {
+	asc := order.By(req.OrderAscend)
+	return s.streamPairs(req.TxId, req.PageSize, asc, int(req.Limit), func(ttx kv.TemporalTx, after []byte) (stream.KV, error) {
+		if r, ok := ttx.(kv.HistoryRangerFromKey); ok && after != nil {
+			// inclusive: `streamPairs` skips it
+			return r.HistoryRangeFromKey(kv.History(req.Table), after, int(req.FromTs), int(req.ToTs), asc, -1)
+		}
+		return ttx.HistoryRange(kv.History(req.Table), int(req.FromTs), int(req.ToTs), asc, -1)
+	}, server.Send)
+}
+
+// streamPairs - sends pairs of iterator created by `open` page by page.
+//
+// Iterator is advanced only inside `with`: stream doesn't block other users of same `tx` while client is receiving.
+// Backpressure: `send` blocks while client doesn't read (gRPC flow control) - so server holds at most 1 page per stream.
+// If `tx` got renewed (see MaxTxTTL) - iterator is re-opened by `open(tx, lastSentKey)` and keys up to last sent are skipped.
+// `limit` <= 0 means no limit.
+func (s *KvServer) streamPairs(txID uint64, pageSize int32, asc order.By, limit int, open func(ttx kv.TemporalTx, after []byte) (stream.KV, error), send func(*remote.Pairs) error) error {
+	if pageSize <= 0 || pageSize > PageSizeLimit {
+		pageSize = PageSizeLimit
+	}
+	var it stream.KV
+	var owner kv.Tx // tx which `it` belongs to
+	var lastKey []byte
+	defer func() {
+		if it == nil {
+			return
+		}
+		_ = s.with(txID, func(tx kv.Tx) error {
+			if tx == owner { // renewed tx already closed iterators of previous one
+				it.Close()
+			}
+			return nil
+		})
+	}()
+
+	sent := 0
+	for {
+		reply := &remote.Pairs{}
+		done := false
+		if err := s.with(txID, func(tx kv.Tx) (err error) {
+			if it == nil || tx != owner {
+				ttx, ok := tx.(kv.TemporalTx)
+				if !ok {
+					return errors.New("server DB doesn't implement kv.Temporal interface")
+				}
+				if it, err = open(ttx, lastKey); err != nil {
+					it = nil
+					return err
+				}
+				owner = tx
+			}
+			for len(reply.Keys) < int(pageSize) && (limit <= 0 || sent+len(reply.Keys) < limit) && it.HasNext() {
+				k, v, err := it.Next()
+				if err != nil {
+					return err
+				}
+				if lastKey != nil && ((asc && bytes.Compare(k, lastKey) <= 0) || (!asc && bytes.Compare(k, lastKey) >= 0)) {
+					continue
+				}
+				reply.Keys = append(reply.Keys, bytesCopy(k))
+				reply.Values = append(reply.Values, bytesCopy(v))
+			}
+			done = !it.HasNext() || (limit > 0 && sent+len(reply.Keys) >= limit)
+			return nil
+		}); err != nil {
+			return err
+		}
+		if len(reply.Keys) > 0 {
+			sent += len(reply.Keys)
+			lastKey = reply.Keys[len(reply.Keys)-1]
+			if err := send(reply); err != nil {
+				return err
+			}
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+// StateDiff - streams keys of domains changed in [from_ts, to_ts) with values before and after the range.
+// Every page is read in own `with` call: stream doesn't block other users of same `tx` while client is receiving.
+func (s *KvServer) StateDiff(req *remote.StateDiffReq, server remote.KV_StateDiffServer) error {
+	tables := req.Tables
+	if len(tables) == 0 {
+		tables = []string{kv.AccountsDomain.String(), kv.StorageDomain.String(), kv.CodeDomain.String()}
+	}
+	pageSize := int(req.PageSize)
+	if pageSize <= 0 || pageSize > PageSizeLimit {
+		pageSize = PageSizeLimit
+	}
+
+	for _, table := range tables {
+		domain, err := kv.String2Domain(table)
+		if err != nil {
+			return err
+		}
+		var fromKey []byte
+		for {
+			reply := &remote.StateDiffReply{Table: table}
+			var nextKey []byte
+			if err := s.with(req.TxId, func(tx kv.Tx) error {
+				ttx, ok := tx.(kv.TemporalTx)
+				if !ok {
+					return fmt.Errorf("server DB doesn't implement kv.Temporal interface")
+				}
+				return kv.StateDiff(ttx, domain, int(req.FromTs), int(req.ToTs), fromKey, req.Prefixes, func(k, before, after []byte) (bool, error) {
+					if len(reply.Keys) == pageSize {
+						nextKey = bytesCopy(k)
+						return false, nil
+					}
+					reply.Keys = append(reply.Keys, bytesCopy(k))
+					reply.Before = append(reply.Before, bytesCopy(before))
+					reply.After = append(reply.After, bytesCopy(after))
+					return true, nil
+				})
+			}); err != nil {
+				return err
+			}
+			if len(reply.Keys) > 0 {
+				if err := server.Send(reply); err != nil {
+					return err
+				}
+			}
+			if nextKey == nil {
+				break
+			}
+			fromKey = nextKey
+		}
+	}
+	return nil
+}
+
 func (s *KvServer) Range(_ context.Context, req *remote.RangeReq) (*remote.Pairs, error) {
 	from, limit := req.FromPrefix, int(req.Limit)
 	if req.PageToken != "" {
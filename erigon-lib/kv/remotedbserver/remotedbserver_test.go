@@ -18,16 +18,29 @@ package remotedbserver
 
 import (
 	"context"
+	"net"
 	"runtime"
 	"testing"
 
 	"github.com/stretchr/testify/require"
 	"go.uber.org/mock/gomock"
 	"golang.org/x/sync/errgroup"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
 
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/gointerfaces"
+	remote "github.com/erigontech/erigon-lib/gointerfaces/remoteproto"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/kv/order"
+	"github.com/erigontech/erigon-lib/kv/remotedb"
+	"github.com/erigontech/erigon-lib/kv/stream"
+	"github.com/erigontech/erigon-lib/kv/temporal/temporaltest"
 	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/state"
 )
 
 func TestKvServer_renew(t *testing.T) {
@@ -139,3 +152,196 @@ func TestKVServerSnapshotsReturnsEmptyIfNoBlockSnapshots(t *testing.T) {
 	require.Empty(t, reply.BlocksFiles)
 	require.Empty(t, reply.HistoryFiles)
 }
+
+type stateDiffServer struct {
+	grpc.ServerStream
+	replies []*remote.StateDiffReply
+}
+
+func (s *stateDiffServer) Send(m *remote.StateDiffReply) error {
+	s.replies = append(s.replies, m)
+	return nil
+}
+
+var (
+	addr1, addr2, addr3 = []byte{1}, []byte{2}, []byte{3}
+	v1, v2, v3          = []byte{0x11}, []byte{0x22}, []byte{0x33}
+)
+
+// newTestAccountsDB - temporal db with accounts changed at txNums 1..5
+func newTestAccountsDB(t *testing.T) kv.RwDB {
+	t.Helper()
+	ctx := context.Background()
+	db, _ := temporaltest.NewTestDB(t, datadir.New(t.TempDir()))
+	require.NoError(t, db.Update(ctx, func(tx kv.RwTx) error {
+		d, err := state.NewSharedDomains(tx, log.New())
+		if err != nil {
+			return err
+		}
+		defer d.Close()
+		put := func(txNum uint64, k, v, prev []byte) {
+			d.SetTxNum(txNum)
+			require.NoError(t, d.DomainPut(kv.AccountsDomain, k, nil, v, prev, 0))
+		}
+		put(1, addr1, v1, nil)
+		put(1, addr2, v1, nil)
+		put(2, addr1, v2, v1)
+		put(3, addr2, v2, v1)
+		put(4, addr2, v1, v2) // got back value of txNum 1
+		put(5, addr3, v3, nil)
+		return d.Flush(ctx, tx)
+	}))
+	return db
+}
+
+func TestKVServerStateDiff(t *testing.T) {
+	ctx := context.Background()
+	db := newTestAccountsDB(t)
+
+	s := NewKvServer(ctx, db, nil, nil, nil, log.New())
+	id, err := s.begin(ctx)
+	require.NoError(t, err)
+	defer s.rollback(id)
+
+	server := &stateDiffServer{}
+	require.NoError(t, s.StateDiff(&remote.StateDiffReq{TxId: id, Tables: []string{"accounts"}, FromTs: 2, ToTs: 5}, server))
+	require.Len(t, server.replies, 1)
+	require.Equal(t, [][]byte{addr1}, server.replies[0].Keys)
+	require.Equal(t, [][]byte{v1}, server.replies[0].Before)
+	require.Equal(t, [][]byte{v2}, server.replies[0].After)
+
+	// latest state, one key per page
+	server = &stateDiffServer{}
+	require.NoError(t, s.StateDiff(&remote.StateDiffReq{TxId: id, Tables: []string{"accounts"}, FromTs: 2, ToTs: -1, PageSize: 1}, server))
+	require.Len(t, server.replies, 2)
+	require.Equal(t, [][]byte{addr1}, server.replies[0].Keys)
+	require.Equal(t, [][]byte{addr3}, server.replies[1].Keys)
+	require.Empty(t, server.replies[1].Before[0])
+	require.Equal(t, v3, server.replies[1].After[0])
+
+	server = &stateDiffServer{}
+	require.NoError(t, s.StateDiff(&remote.StateDiffReq{TxId: id, FromTs: 0, ToTs: -1, Prefixes: [][]byte{addr2}}, server))
+	require.Len(t, server.replies, 1)
+	require.Equal(t, "accounts", server.replies[0].Table)
+	require.Equal(t, [][]byte{addr2}, server.replies[0].Keys)
+	require.Empty(t, server.replies[0].Before[0])
+	require.Equal(t, v1, server.replies[0].After[0])
+}
+
+type pairsServer struct {
+	grpc.ServerStream
+	replies []*remote.Pairs
+	onSend  func()
+}
+
+func (s *pairsServer) Send(m *remote.Pairs) error {
+	s.replies = append(s.replies, m)
+	if s.onSend != nil {
+		s.onSend()
+	}
+	return nil
+}
+
+func (s *pairsServer) keys() (keys [][]byte) {
+	for _, r := range s.replies {
+		keys = append(keys, r.Keys...)
+	}
+	return keys
+}
+
+func TestKVServerHistoryRangeStream(t *testing.T) {
+	ctx := context.Background()
+	db := newTestAccountsDB(t)
+	s := NewKvServer(ctx, db, nil, nil, nil, log.New())
+	id, err := s.begin(ctx)
+	require.NoError(t, err)
+	defer s.rollback(id)
+
+	server := &pairsServer{}
+	require.NoError(t, s.HistoryRangeStream(&remote.HistoryRangeReq{TxId: id, Table: string(kv.AccountsHistory), FromTs: 0, ToTs: -1, OrderAscend: true, PageSize: 1}, server))
+	require.Len(t, server.replies, 3)
+	require.Equal(t, [][]byte{addr1, addr2, addr3}, server.keys())
+
+	server = &pairsServer{}
+	require.NoError(t, s.HistoryRangeStream(&remote.HistoryRangeReq{TxId: id, Table: string(kv.AccountsHistory), FromTs: 0, ToTs: -1, OrderAscend: true, Limit: 2}, server))
+	require.Equal(t, [][]byte{addr1, addr2}, server.keys())
+
+	// tx renewed between pages: iterator is re-opened and continues after last sent key
+	req := &remote.DomainRangeReq{TxId: id, Table: kv.AccountsDomain.String(), Ts: 6, OrderAscend: true, PageSize: 1}
+	server = &pairsServer{}
+	require.NoError(t, s.DomainRangeStream(req, server))
+	require.Equal(t, [][]byte{addr1, addr2, addr3}, server.keys())
+	renewed := &pairsServer{onSend: func() { require.NoError(t, s.renew(ctx, id)) }}
+	require.NoError(t, s.DomainRangeStream(req, renewed))
+	require.Equal(t, server.replies, renewed.replies)
+
+	// history is re-opened from last sent key
+	historyReq := &remote.HistoryRangeReq{TxId: id, Table: string(kv.AccountsHistory), FromTs: 0, ToTs: -1, OrderAscend: true, PageSize: 1}
+	server = &pairsServer{}
+	require.NoError(t, s.HistoryRangeStream(historyReq, server))
+	renewed = &pairsServer{onSend: func() { require.NoError(t, s.renew(ctx, id)) }}
+	require.NoError(t, s.HistoryRangeStream(historyReq, renewed))
+	require.Equal(t, server.replies, renewed.replies)
+}
+
+func TestRemoteTemporalRange(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("fix me on win please")
+	}
+	ctx, logger := context.Background(), log.New()
+	db := newTestAccountsDB(t)
+	grpcServer, conn := grpc.NewServer(), bufconn.Listen(1024*1024)
+	remote.RegisterKVServer(grpcServer, NewKvServer(ctx, db, nil, nil, nil, logger))
+	go grpcServer.Serve(conn) //nolint:errcheck
+	defer grpcServer.Stop()
+
+	cc, err := grpc.Dial("", grpc.WithTransportCredentials(insecure.NewCredentials()), grpc.WithContextDialer(func(ctx context.Context, url string) (net.Conn, error) { return conn.Dial() }))
+	require.NoError(t, err)
+	defer cc.Close()
+	remoteDB, err := remotedb.NewRemote(gointerfaces.VersionFromProto(KvServiceAPIVersion), logger, remote.NewKVClient(cc)).Open()
+	require.NoError(t, err)
+
+	localRoTx, err := db.BeginRo(ctx)
+	require.NoError(t, err)
+	defer localRoTx.Rollback()
+	localTx := localRoTx.(kv.TemporalTx)
+	remoteTx, err := remoteDB.BeginTemporalRo(ctx)
+	require.NoError(t, err)
+	defer remoteTx.Rollback()
+
+	collect := func(it stream.KV, err error) (keys, values [][]byte) {
+		require.NoError(t, err)
+		defer it.Close()
+		for it.HasNext() {
+			k, v, err := it.Next()
+			require.NoError(t, err)
+			keys, values = append(keys, common.Copy(k)), append(values, common.Copy(v))
+		}
+		return keys, values
+	}
+	for _, ts := range []uint64{2, 4, 6} {
+		wantK, wantV := collect(localTx.DomainRange(kv.AccountsDomain, nil, nil, ts, order.Asc, -1))
+		require.NotEmpty(t, wantK)
+		gotK, gotV := collect(remoteTx.DomainRange(kv.AccountsDomain, nil, nil, ts, order.Asc, -1))
+		require.Equal(t, wantK, gotK, ts)
+		require.Equal(t, wantV, gotV, ts)
+	}
+
+	wantK, wantV := collect(localTx.HistoryRange(kv.AccountsHistory, 2, 5, order.Asc, -1))
+	gotK, gotV := collect(remoteTx.HistoryRange(kv.AccountsHistory, 2, 5, order.Asc, -1))
+	require.Equal(t, [][]byte{addr1, addr2}, gotK)
+	require.Equal(t, wantK, gotK)
+	require.Equal(t, wantV, gotV)
+
+	gotK, _ = collect(remoteTx.HistoryRange(kv.AccountsHistory, 0, -1, order.Asc, 1))
+	require.Equal(t, [][]byte{addr1}, gotK)
+
+	// not consumed iterator doesn't block tx
+	it, err := remoteTx.DomainRange(kv.AccountsDomain, nil, nil, 6, order.Asc, -1)
+	require.NoError(t, err)
+	require.True(t, it.HasNext())
+	v, _, err := remoteTx.DomainGet(kv.AccountsDomain, addr3, nil)
+	require.NoError(t, err)
+	require.Equal(t, v3, v)
+	it.Close()
+}
@@ -22,6 +22,7 @@ import (
 	"strings"
 
 	types "github.com/erigontech/erigon-lib/gointerfaces/typesproto"
+	"github.com/erigontech/erigon-lib/kv/internal/testhooks"
 )
 
 // DBSchemaVersion versions list
@@ -234,6 +235,9 @@ const VerkleRoots = "VerkleRoots"
 // Mapping [Verkle Root] => [Rlp-Encoded Verkle Node]
 const VerkleTrie = "VerkleTrie"
 
+// Mapping [block number] => [shadow commitment root (32 bytes) + conversion is complete (1 byte)]
+const ShadowCommitmentRoots = "ShadowCommitmentRoots"
+
 const (
 	// DatabaseInfo is used to store information about data layout.
 	DatabaseInfo = "DbInfo"
@@ -474,9 +478,19 @@ var (
 	PruneHistory   = []byte("pruneHistory")
 	PruneBlocks    = []byte("pruneBlocks")
 
+	PruneLogs             = []byte("pruneLogs")
+	PruneCallTraces       = []byte("pruneCallTraces")
+	PruneStateHistory     = []byte("pruneStateHistory")
+	PruneCodeHistory      = []byte("pruneCodeHistory")
+	PruneHistoryAllowlist = []byte("pruneHistoryAllowlist")
+	PruneMigrationPending = []byte("pruneMigrationPending") // target prune mode of interrupted `prune-mode migrate`
+	PruneGrowPendingFrom  = []byte("pruneGrowPendingFrom")  // state and code history of blocks before this one is not downloaded yet
+
 	DBSchemaVersionKey = []byte("dbVersion")
 	GenesisKey         = []byte("genesis")
 
+	DevChainOverrides = []byte("devChainOverrides") // prefix of dev chain state overrides: parent block number + hash => overrides
+
 	BittorrentPeerID = "peerID"
 
 	PlainStateVersion = []byte("PlainStateVersion")
@@ -586,6 +600,7 @@ var ChaindataTables = []string{
 
 	VerkleRoots,
 	VerkleTrie,
+	ShadowCommitmentRoots,
 	// Beacon stuff
 	BeaconBlocks,
 	CanonicalBlockRoots,
@@ -790,6 +805,8 @@ func sortBuckets() {
 
 func init() {
 	reinit()
+	testhooks.UnregisterLastDomain = unregisterLastDomain
+	testhooks.UnregisterLastInvertedIdx = unregisterLastInvertedIdx
 }
 
 func reinit() {
@@ -855,7 +872,7 @@ const (
 	StorageDomain    Domain = 1
 	CodeDomain       Domain = 2
 	CommitmentDomain Domain = 3
-	DomainLen        Domain = 4
+	DomainLen        Domain = 4 // amount of built-in domains, user-defined ones follow them: see RegisterDomain
 )
 
 const (
@@ -900,10 +917,231 @@ func (iip InvertedIdxPos) String() string {
 	case TracesToIdxPos:
 		return "traceTo"
 	default:
+		if cfg, ok := UserInvertedIdx(iip); ok {
+			return cfg.FilenameBase
+		}
 		return "unknown inverted index"
 	}
 }
 
+// InvertedIdxCfg - user-defined inverted index: maintained during execution by SharedDomains.IndexAdd,
+// built into .ef files, merged and pruned by Aggregator like built-in ones
+type InvertedIdxCfg struct {
+	Name         InvertedIdx
+	Pos          InvertedIdxPos
+	FilenameBase string // name of files: v1-<FilenameBase>.0-1.ef
+	KeysTable    string // txNum -> key
+	IndexTable   string // key -> txNum
+}
+
+var userInvertedIdxs []InvertedIdxCfg
+
+// RegisterInvertedIdx - adds inverted index after built-in ones. Tables of index are named `<name>Keys` and `<name>`.
+// Not thread-safe: must be called before opening of chaindata and creation of Aggregator (for example from `init()`),
+// and in same order in every process which opens datadir.
+func RegisterInvertedIdx(name InvertedIdx, filenameBase string) InvertedIdxPos {
+	if name == "" || filenameBase == "" {
+		panic("RegisterInvertedIdx: empty name")
+	}
+	if _, ok := InvertedIdxPosByName(name); ok {
+		panic(fmt.Sprintf("RegisterInvertedIdx: index %s already registered", name))
+	}
+	keysTable, idxTable := string(name)+"Keys", string(name)
+	for _, existing := range ChaindataTables {
+		if existing == keysTable || existing == idxTable {
+			panic(fmt.Sprintf("RegisterInvertedIdx: table %s already exists", existing))
+		}
+	}
+	for _, cfg := range userInvertedIdxs {
+		if cfg.FilenameBase == filenameBase {
+			panic(fmt.Sprintf("RegisterInvertedIdx: files %s already registered", filenameBase))
+		}
+	}
+	switch filenameBase {
+	case FileLogAddressIdx, FileLogTopicsIdx, FileTracesFromIdx, FileTracesToIdx:
+		panic(fmt.Sprintf("RegisterInvertedIdx: files %s are built-in", filenameBase))
+	}
+	if _, err := String2Domain(filenameBase); err == nil {
+		panic(fmt.Sprintf("RegisterInvertedIdx: files %s belong to domain", filenameBase))
+	}
+
+	cfg := InvertedIdxCfg{
+		Name:         name,
+		Pos:          StandaloneIdxLen + InvertedIdxPos(len(userInvertedIdxs)),
+		FilenameBase: filenameBase,
+		KeysTable:    keysTable,
+		IndexTable:   idxTable,
+	}
+	userInvertedIdxs = append(userInvertedIdxs, cfg)
+	ChaindataTables = append(ChaindataTables, keysTable, idxTable)
+	ChaindataTablesCfg[keysTable] = TableCfgItem{Flags: DupSort}
+	ChaindataTablesCfg[idxTable] = TableCfgItem{Flags: DupSort}
+	reinit()
+	return cfg.Pos
+}
+
+// UserInvertedIdxs - inverted indices added by RegisterInvertedIdx, ordered by position
+func UserInvertedIdxs() []InvertedIdxCfg { return userInvertedIdxs }
+
+// UserInvertedIdx - user-defined inverted index at given position
+func UserInvertedIdx(pos InvertedIdxPos) (InvertedIdxCfg, bool) {
+	if pos < StandaloneIdxLen || int(pos-StandaloneIdxLen) >= len(userInvertedIdxs) {
+		return InvertedIdxCfg{}, false
+	}
+	return userInvertedIdxs[pos-StandaloneIdxLen], true
+}
+
+// InvertedIdxLen - amount of standalone inverted indices: built-in and user-defined
+func InvertedIdxLen() int { return int(StandaloneIdxLen) + len(userInvertedIdxs) }
+
+// InvertedIdxPosByName - position of standalone inverted index by its name or by name of its index table
+func InvertedIdxPosByName(name InvertedIdx) (InvertedIdxPos, bool) {
+	switch name {
+	case LogAddrIdx, TblLogAddressIdx:
+		return LogAddrIdxPos, true
+	case LogTopicIdx, TblLogTopicsIdx, LogTopicIndex:
+		return LogTopicIdxPos, true
+	case TracesFromIdx: // same as TblTracesFromIdx
+		return TracesFromIdxPos, true
+	case TracesToIdx: // same as TblTracesToIdx
+		return TracesToIdxPos, true
+	}
+	for _, cfg := range userInvertedIdxs {
+		if cfg.Name == name || cfg.IndexTable == string(name) {
+			return cfg.Pos, true
+		}
+	}
+	return 0, false
+}
+
+// DomainCfg - user-defined domain: latest values with history, written during execution by SharedDomains.DomainPut,
+// built into .kv/.v/.ef files, merged and pruned by Aggregator like built-in ones. It's not a part of commitment.
+type DomainCfg struct {
+	Name             Domain
+	FilenameBase     string // name of files: v1-<FilenameBase>.0-1.kv
+	History          History
+	HistoryIdx       InvertedIdx
+	ValuesTable      string // key -> ^step+value
+	HistoryKeysTable string // txNum -> key
+	HistoryValsTable string // key+txNum -> value
+	HistoryIdxTable  string // key -> txNum
+}
+
+var userDomains []DomainCfg
+
+// RegisterDomain - adds domain after built-in ones. Tables of domain are named `<name>Vals`, `<name>HistoryKeys`,
+// `<name>HistoryVals` and `<name>Idx`, its history is `<name>History` and history index is `<name>HistoryIdx`.
+// Same restrictions as for RegisterInvertedIdx: call it before opening of chaindata and creation of Aggregator,
+// in same order in every process which opens datadir.
+func RegisterDomain(name string, filenameBase string) Domain {
+	if name == "" || filenameBase == "" {
+		panic("RegisterDomain: empty name")
+	}
+	if _, err := String2Domain(filenameBase); err == nil {
+		panic(fmt.Sprintf("RegisterDomain: files %s already registered", filenameBase))
+	}
+	switch filenameBase {
+	case FileLogAddressIdx, FileLogTopicsIdx, FileTracesFromIdx, FileTracesToIdx:
+		panic(fmt.Sprintf("RegisterDomain: files %s belong to inverted index", filenameBase))
+	}
+	for _, cfg := range userInvertedIdxs {
+		if cfg.FilenameBase == filenameBase {
+			panic(fmt.Sprintf("RegisterDomain: files %s belong to inverted index", filenameBase))
+		}
+	}
+	cfg := DomainCfg{
+		Name:             DomainLen + Domain(len(userDomains)),
+		FilenameBase:     filenameBase,
+		History:          History(name + "History"),
+		HistoryIdx:       InvertedIdx(name + "HistoryIdx"),
+		ValuesTable:      name + "Vals",
+		HistoryKeysTable: name + "HistoryKeys",
+		HistoryValsTable: name + "HistoryVals",
+		HistoryIdxTable:  name + "Idx",
+	}
+	if _, ok := DomainByHistory(cfg.History); ok {
+		panic(fmt.Sprintf("RegisterDomain: domain %s already registered", name))
+	}
+	tables := []string{cfg.ValuesTable, cfg.HistoryKeysTable, cfg.HistoryValsTable, cfg.HistoryIdxTable}
+	for _, existing := range ChaindataTables {
+		for _, table := range tables {
+			if existing == table {
+				panic(fmt.Sprintf("RegisterDomain: table %s already exists", existing))
+			}
+		}
+	}
+
+	userDomains = append(userDomains, cfg)
+	ChaindataTables = append(ChaindataTables, tables...)
+	for _, table := range tables {
+		ChaindataTablesCfg[table] = TableCfgItem{Flags: DupSort}
+	}
+	reinit()
+	return cfg.Name
+}
+
+// UserDomains - domains added by RegisterDomain, ordered by position
+func UserDomains() []DomainCfg { return userDomains }
+
+// UserDomain - user-defined domain by its position
+func UserDomain(d Domain) (DomainCfg, bool) {
+	if d < DomainLen || int(d-DomainLen) >= len(userDomains) {
+		return DomainCfg{}, false
+	}
+	return userDomains[d-DomainLen], true
+}
+
+// DomainsLen - amount of domains: built-in and user-defined
+func DomainsLen() int { return int(DomainLen) + len(userDomains) }
+
+// DomainByHistory - domain by name of its history
+func DomainByHistory(name History) (Domain, bool) {
+	for d := Domain(0); int(d) < DomainsLen(); d++ {
+		if d.History() == name {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// DomainByHistoryIdx - domain by name of inverted index of its history
+func DomainByHistoryIdx(name InvertedIdx) (Domain, bool) {
+	for d := Domain(0); int(d) < DomainsLen(); d++ {
+		if d.HistoryIdx() == name {
+			return d, true
+		}
+	}
+	return 0, false
+}
+
+// unregisterUserTables - removes tables added by Register* functions
+func unregisterUserTables(tables ...string) {
+	for _, table := range tables {
+		for i, existing := range ChaindataTables {
+			if existing == table {
+				ChaindataTables = append(ChaindataTables[:i], ChaindataTables[i+1:]...)
+				break
+			}
+		}
+		delete(ChaindataTablesCfg, table)
+	}
+	reinit()
+}
+
+// unregisterLastDomain - undo of RegisterDomain, exposed to tests by kvtest.RegisterDomain
+func unregisterLastDomain() {
+	cfg := userDomains[len(userDomains)-1]
+	userDomains = userDomains[:len(userDomains)-1]
+	unregisterUserTables(cfg.ValuesTable, cfg.HistoryKeysTable, cfg.HistoryValsTable, cfg.HistoryIdxTable)
+}
+
+// unregisterLastInvertedIdx - undo of RegisterInvertedIdx, exposed to tests by kvtest.RegisterInvertedIdx
+func unregisterLastInvertedIdx() {
+	cfg := userInvertedIdxs[len(userInvertedIdxs)-1]
+	userInvertedIdxs = userInvertedIdxs[:len(userInvertedIdxs)-1]
+	unregisterUserTables(cfg.KeysTable, cfg.IndexTable)
+}
+
 func (d Domain) String() string {
 	switch d {
 	case AccountsDomain:
@@ -915,10 +1153,51 @@ func (d Domain) String() string {
 	case CommitmentDomain:
 		return "commitment"
 	default:
+		if cfg, ok := UserDomain(d); ok {
+			return cfg.FilenameBase
+		}
 		return "unknown domain"
 	}
 }
 
+// History - name of history of domain
+func (d Domain) History() History {
+	switch d {
+	case AccountsDomain:
+		return AccountsHistory
+	case StorageDomain:
+		return StorageHistory
+	case CodeDomain:
+		return CodeHistory
+	case CommitmentDomain:
+		return CommitmentHistory
+	default:
+		if cfg, ok := UserDomain(d); ok {
+			return cfg.History
+		}
+		return ""
+	}
+}
+
+// HistoryIdx - name of inverted index of history of domain
+func (d Domain) HistoryIdx() InvertedIdx {
+	switch d {
+	case AccountsDomain:
+		return AccountsHistoryIdx
+	case StorageDomain:
+		return StorageHistoryIdx
+	case CodeDomain:
+		return CodeHistoryIdx
+	case CommitmentDomain:
+		return CommitmentHistoryIdx
+	default:
+		if cfg, ok := UserDomain(d); ok {
+			return cfg.HistoryIdx
+		}
+		return ""
+	}
+}
+
 func String2Domain(in string) (Domain, error) {
 	switch in {
 	case "accounts":
@@ -930,6 +1209,11 @@ func String2Domain(in string) (Domain, error) {
 	case "commitment":
 		return CommitmentDomain, nil
 	default:
+		for _, cfg := range userDomains {
+			if cfg.FilenameBase == in {
+				return cfg.Name, nil
+			}
+		}
 		return Domain(MaxUint16), fmt.Errorf("unknown history name: %s", in)
 	}
 }
@@ -243,6 +243,15 @@ func (tx *Tx) HistoryRange(name kv.History, fromTs, toTs int, asc order.By, limi
 	return it, nil
 }
 
+func (tx *Tx) HistoryRangeFromKey(name kv.History, fromKey []byte, fromTs, toTs int, asc order.By, limit int) (stream.KV, error) {
+	it, err := tx.filesTx.HistoryRangeFromKey(name, fromKey, fromTs, toTs, asc, limit, tx.MdbxTx)
+	if err != nil {
+		return nil, err
+	}
+	tx.resourcesToClose = append(tx.resourcesToClose, it)
+	return it, nil
+}
+
 func (tx *Tx) AppendableGet(name kv.Appendable, ts kv.TxnId) ([]byte, bool, error) {
 	return tx.filesTx.AppendableGet(name, ts, tx.MdbxTx)
 }
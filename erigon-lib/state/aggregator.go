@@ -17,7 +17,6 @@
 package state
 
 import (
-	"bytes"
 	"context"
 	"encoding/binary"
 	"errors"
@@ -39,6 +38,7 @@ import (
 	"golang.org/x/sync/errgroup"
 	"golang.org/x/sync/semaphore"
 
+	"github.com/erigontech/erigon-lib/commitment"
 	common2 "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/background"
 	"github.com/erigontech/erigon-lib/common/datadir"
@@ -55,8 +55,8 @@ import (
 
 type Aggregator struct {
 	db              kv.RoDB
-	d               [kv.DomainLen]*Domain
-	iis             [kv.StandaloneIdxLen]*InvertedIndex
+	d               []*Domain                     // built-in domains at kv.Domain, then kv.UserDomains
+	iis             []*InvertedIndex              // built-in indices at kv.InvertedIdxPos, then kv.UserInvertedIdxs
 	ap              [kv.AppendableLen]*Appendable //nolint
 	dirs            datadir.Dirs
 	tmpdir          string
@@ -97,6 +97,9 @@ type Aggregator struct {
 	ctxAutoIncrement atomic.Uint64
 
 	produce bool
+
+	shadowCommitment     commitment.TrieVariant // second commitment computed alongside of main one, empty if disabled
+	shadowConversionKeys int                    // amount of not-touched keys moved to shadow commitment per computation
 }
 
 type OnFreezeFunc func(frozenFileNames []string)
@@ -122,6 +125,7 @@ func NewAggregator(ctx context.Context, dirs datadir.Dirs, aggregationStep uint6
 		leakDetector:           dbg.NewLeakDetector("agg", dbg.SlowTx()),
 		ps:                     background.NewProgressSet(),
 		logger:                 logger,
+		d:                      make([]*Domain, kv.DomainsLen()),
 		collateAndBuildWorkers: 1,
 		mergeWorkers:           1,
 
@@ -200,6 +204,17 @@ func NewAggregator(ctx context.Context, dirs datadir.Dirs, aggregationStep uint6
 	if a.d[kv.CommitmentDomain], err = NewDomain(cfg, aggregationStep, kv.CommitmentDomain, kv.TblCommitmentVals, kv.TblCommitmentHistoryKeys, kv.TblCommitmentHistoryVals, kv.TblCommitmentIdx, integrityCheck, logger); err != nil {
 		return nil, err
 	}
+	for _, dc := range kv.UserDomains() {
+		cfg = domainCfg{
+			hist: histCfg{
+				iiCfg:             iiCfg{salt: salt, dirs: dirs, db: db},
+				withLocalityIndex: false, withExistenceIndex: false, compression: CompressNone, historyLargeValues: false,
+			},
+		}
+		if a.d[dc.Name], err = NewDomain(cfg, aggregationStep, dc.Name, dc.ValuesTable, dc.HistoryKeysTable, dc.HistoryValsTable, dc.HistoryIdxTable, integrityCheck, logger); err != nil {
+			return nil, err
+		}
+	}
 	//aCfg := AppendableCfg{
 	//	Salt: salt, Dirs: dirs, DB: db, iters: iters,
 	//}
@@ -218,6 +233,11 @@ func NewAggregator(ctx context.Context, dirs datadir.Dirs, aggregationStep uint6
 	if err := a.registerII(kv.TracesToIdxPos, salt, dirs, db, aggregationStep, kv.FileTracesToIdx, kv.TblTracesToKeys, kv.TblTracesToIdx, logger); err != nil {
 		return nil, err
 	}
+	for _, cfg := range kv.UserInvertedIdxs() {
+		if err := a.registerII(cfg.Pos, salt, dirs, db, aggregationStep, cfg.FilenameBase, cfg.KeysTable, cfg.IndexTable, logger); err != nil {
+			return nil, err
+		}
+	}
 	a.KeepRecentTxnsOfHistoriesWithDisabledSnapshots(100_000) // ~1k blocks of history
 	a.recalcVisibleFiles()
 
@@ -271,11 +291,14 @@ func getStateIndicesSalt(baseDir string) (salt *uint32, err error) {
 
 func (a *Aggregator) registerII(idx kv.InvertedIdxPos, salt *uint32, dirs datadir.Dirs, db kv.RoDB, aggregationStep uint64, filenameBase, indexKeysTable, indexTable string, logger log.Logger) error {
 	idxCfg := iiCfg{salt: salt, dirs: dirs, db: db}
-	var err error
-	a.iis[idx], err = NewInvertedIndex(idxCfg, aggregationStep, filenameBase, indexKeysTable, indexTable, nil, logger)
+	if int(idx) != len(a.iis) {
+		return fmt.Errorf("inverted index %s registered at position %d, expected %d", filenameBase, idx, len(a.iis))
+	}
+	ii, err := NewInvertedIndex(idxCfg, aggregationStep, filenameBase, indexKeysTable, indexTable, nil, logger)
 	if err != nil {
 		return err
 	}
+	a.iis = append(a.iis, ii)
 	return nil
 }
 
@@ -387,9 +410,107 @@ func (a *Aggregator) EnableHistory(name kv.Domain) *Aggregator {
 	return a
 }
 
+// LimitHistoryToKeys - write history of domain only for keys accepted by `keep` (nil - for all keys).
+// `keep` receives first part of key on writes (for example address in case of storage domain) and whole key on reads,
+// so it must look only at prefix. Reads of history of other keys fail with ErrHistoryNotKept: it's incomplete.
+// Doesn't affect existing files and must be set before creation of writers.
+func (a *Aggregator) LimitHistoryToKeys(name kv.Domain, keep func(key1 []byte) bool) *Aggregator {
+	a.d[name].History.keepKey = keep
+	return a
+}
+
+// FilesRetention - txNum from which history of domain or inverted index is needed. 0 - keep everything
+type FilesRetention struct {
+	Histories map[kv.Domain]uint64
+	Indices   map[kv.InvertedIdxPos]uint64
+}
+
+// PruneFiles - removes history and inverted index files which contain only txNums before retention.
+// Files are never split: file is removed only if all its txNums are before retention.
+// Returns paths of removed files relative to snapshots dir.
+func (a *Aggregator) PruneFiles(retention FilesRetention) (deleted []string, err error) {
+	a.dirtyFilesLock.Lock()
+	defer a.dirtyFilesLock.Unlock()
+
+	type garbage struct {
+		dirtyFiles   *btree.BTreeG[*filesItem]
+		filenameBase string
+		outs         []*filesItem
+	}
+	var toDelete []garbage
+	collect := func(dirtyFiles *btree.BTreeG[*filesItem], filenameBase string, txNum uint64) {
+		outs := filesBefore(dirtyFiles, txNum)
+		if len(outs) > 0 {
+			toDelete = append(toDelete, garbage{dirtyFiles: dirtyFiles, filenameBase: filenameBase, outs: outs})
+		}
+	}
+	for id, d := range a.d {
+		txNum := retention.Histories[kv.Domain(id)]
+		if txNum == 0 || d.snapshotsDisabled {
+			continue
+		}
+		collect(d.History.dirtyFiles, d.filenameBase, txNum)
+		collect(d.History.InvertedIndex.dirtyFiles, d.filenameBase, txNum)
+	}
+	for pos, txNum := range retention.Indices {
+		if int(pos) >= len(a.iis) {
+			return nil, fmt.Errorf("PruneFiles: unknown inverted index %d", pos)
+		}
+		if txNum == 0 {
+			continue
+		}
+		collect(a.iis[pos].dirtyFiles, a.iis[pos].filenameBase, txNum)
+	}
+	if len(toDelete) == 0 {
+		return nil, nil
+	}
+
+	// hide files from new readers first: readers which already see them will remove them on close
+	for _, g := range toDelete {
+		for _, out := range g.outs {
+			out.canDelete.Store(true)
+			deleted = append(deleted, a.filesItemPaths(out)...)
+		}
+	}
+	a.recalcVisibleFiles()
+	for _, g := range toDelete {
+		for _, out := range g.outs {
+			out.frozen = false // retention removes frozen files too
+		}
+		deleteMergeFile(g.dirtyFiles, g.outs, g.filenameBase, a.logger)
+	}
+	return deleted, nil
+}
+
+func (a *Aggregator) filesItemPaths(item *filesItem) (paths []string) {
+	add := func(fPath string) {
+		if rel, err := filepath.Rel(a.dirs.Snap, fPath); err == nil {
+			paths = append(paths, rel)
+		}
+	}
+	if item.decompressor != nil {
+		add(item.decompressor.FilePath())
+	}
+	if item.index != nil {
+		add(item.index.FilePath())
+	}
+	if item.bindex != nil {
+		add(item.bindex.FilePath())
+	}
+	if item.existence != nil {
+		add(item.existence.FilePath)
+	}
+	return paths
+}
+
 func (a *Aggregator) HasBackgroundFilesBuild() bool { return a.ps.Has() }
 func (a *Aggregator) BackgroundProgress() string    { return a.ps.String() }
 
+// HistoryFilesStartTxNum - first txNum from which history files of domain have no gaps. false if there are no files
+func (ac *AggregatorRoTx) HistoryFilesStartTxNum(name kv.Domain) (uint64, bool) {
+	return ac.d[name].ht.files.ContiguousStartTxNum()
+}
+
 func (ac *AggregatorRoTx) Files() []string {
 	var res []string
 	if ac == nil {
@@ -596,8 +717,8 @@ func (c AggV3Collation) Close() {
 }
 
 type AggV3StaticFiles struct {
-	d          [kv.DomainLen]StaticFiles
-	ivfs       [kv.StandaloneIdxLen]InvertedFiles
+	d          []StaticFiles
+	ivfs       []InvertedFiles
 	appendable [kv.AppendableLen]AppendableFiles
 }
 
@@ -623,7 +744,7 @@ func (a *Aggregator) buildFiles(ctx context.Context, step uint64) error {
 		txTo          = a.FirstTxNumOfStep(step + 1)
 		stepStartedAt = time.Now()
 
-		static          AggV3StaticFiles
+		static          = AggV3StaticFiles{d: make([]StaticFiles, len(a.d)), ivfs: make([]InvertedFiles, len(a.iis))}
 		closeCollations = true
 		collListMu      = sync.Mutex{}
 		collations      = make([]Collation, 0)
@@ -677,8 +798,8 @@ func (a *Aggregator) buildFiles(ctx context.Context, step uint64) error {
 	closeCollations = false
 
 	// indices are built concurrently
-	for _, ii := range a.iis {
-		ii := ii
+	for iikey, ii := range a.iis {
+		iikey, ii := iikey, ii
 		a.wg.Add(1)
 		g.Go(func() error {
 			defer a.wg.Done()
@@ -696,19 +817,7 @@ func (a *Aggregator) buildFiles(ctx context.Context, step uint64) error {
 				sf.CleanupOnError()
 				return err
 			}
-
-			switch ii.indexKeysTable {
-			case kv.TblLogTopicsKeys:
-				static.ivfs[kv.LogTopicIdxPos] = sf
-			case kv.TblLogAddressKeys:
-				static.ivfs[kv.LogAddrIdxPos] = sf
-			case kv.TblTracesFromKeys:
-				static.ivfs[kv.TracesFromIdxPos] = sf
-			case kv.TblTracesToKeys:
-				static.ivfs[kv.TracesToIdxPos] = sf
-			default:
-				panic("unknown index " + ii.indexKeysTable)
-			}
+			static.ivfs[iikey] = sf
 			return nil
 		})
 	}
@@ -1079,7 +1188,7 @@ func (ac *AggregatorRoTx) PruneSmallBatches(ctx context.Context, timeout time.Du
 }
 
 func (a *Aggregator) StepsRangeInDBAsStr(tx kv.Tx) string {
-	steps := make([]string, 0, kv.DomainLen+4)
+	steps := make([]string, 0, len(a.d)+len(a.iis))
 	for _, d := range a.d {
 		steps = append(steps, d.stepsRangeInDBAsStr(tx))
 	}
@@ -1229,8 +1338,8 @@ func (ac *AggregatorRoTx) Prune(ctx context.Context, tx kv.RwTx, limit uint64, l
 			return aggStat, err
 		}
 	}
-	var stats [kv.StandaloneIdxLen]*InvertedIndexPruneStat
-	for i := 0; i < int(kv.StandaloneIdxLen); i++ {
+	stats := make([]*InvertedIndexPruneStat, len(ac.iis))
+	for i := 0; i < len(ac.iis); i++ {
 		stat, err := ac.iis[i].Prune(ctx, tx, txFrom, txTo, limit, logEvery, false, nil)
 		if err != nil {
 			return nil, err
@@ -1238,7 +1347,7 @@ func (ac *AggregatorRoTx) Prune(ctx context.Context, tx kv.RwTx, limit uint64, l
 		stats[i] = stat
 	}
 
-	for i := 0; i < int(kv.StandaloneIdxLen); i++ {
+	for i := 0; i < len(ac.iis); i++ {
 		aggStat.Indices[ac.iis[i].ii.filenameBase] = stats[i]
 	}
 
@@ -1387,8 +1496,8 @@ func (a *Aggregator) recalcVisibleFilesMinimaxTxNum() {
 }
 
 type RangesV3 struct {
-	domain        [kv.DomainLen]DomainRanges
-	invertedIndex [kv.StandaloneIdxLen]*MergeRange
+	domain        []DomainRanges
+	invertedIndex []*MergeRange
 	appendable    [kv.AppendableLen]*MergeRange
 }
 
@@ -1434,7 +1543,7 @@ func (r RangesV3) any() bool {
 }
 
 func (ac *AggregatorRoTx) findMergeRange(maxEndTxNum, maxSpan uint64) RangesV3 {
-	var r RangesV3
+	r := RangesV3{domain: make([]DomainRanges, len(ac.d))}
 	if ac.a.commitmentValuesTransform {
 		lmrAcc := ac.d[kv.AccountsDomain].files.LatestMergedRange()
 		lmrSto := ac.d[kv.StorageDomain].files.LatestMergedRange()
@@ -1457,8 +1566,8 @@ func (ac *AggregatorRoTx) findMergeRange(maxEndTxNum, maxSpan uint64) RangesV3 {
 		restorePrevRange := false
 		for k, dr := range r.domain {
 			kd := kv.Domain(k)
-			if kd == kv.CommitmentDomain || cr.values.Equal(&dr.values) {
-				continue
+			if kd == kv.CommitmentDomain || kd >= kv.DomainLen || cr.values.Equal(&dr.values) {
+				continue // user-defined domains are not a part of commitment
 			}
 			// commitment waits until storage and account are merged so it may be a bit behind (if merge was interrupted before)
 			if !dr.values.needMerge || cr.values.to < dr.values.from {
@@ -1474,13 +1583,14 @@ func (ac *AggregatorRoTx) findMergeRange(maxEndTxNum, maxSpan uint64) RangesV3 {
 			}
 		}
 		if restorePrevRange {
-			for k, dr := range r.domain {
+			for k, dr := range r.domain[:kv.DomainLen] {
 				r.domain[k].values = MergeRange{}
 				ac.a.logger.Debug("findMergeRange: commitment range is different than accounts or storage, cancel kv merge",
 					ac.d[k].d.filenameBase, dr.values.String("", ac.a.StepSize()))
 			}
 		}
 	}
+	r.invertedIndex = make([]*MergeRange, len(ac.iis))
 	for id, ii := range ac.iis {
 		r.invertedIndex[id] = ii.findMergeRange(maxEndTxNum, maxSpan)
 	}
@@ -1585,7 +1695,7 @@ func (ac *AggregatorRoTx) SqueezeCommitmentFiles() error {
 					continue
 				}
 
-				if !bytes.Equal(k, keyCommitmentState) {
+				if isCommitmentBranchKey(k) {
 					v, err = vt(v, af.startTxNum, af.endTxNum)
 					if err != nil {
 						return fmt.Errorf("failed to transform commitment value: %w", err)
@@ -1671,7 +1781,12 @@ func (ac *AggregatorRoTx) RestrictSubsetFileDeletions(b bool) {
 }
 
 func (ac *AggregatorRoTx) mergeFiles(ctx context.Context, files SelectedStaticFilesV3, r RangesV3) (MergedFilesV3, error) {
-	var mf MergedFilesV3
+	mf := MergedFilesV3{
+		d:     make([]*filesItem, len(ac.d)),
+		dHist: make([]*filesItem, len(ac.d)),
+		dIdx:  make([]*filesItem, len(ac.d)),
+		iis:   make([]*filesItem, len(ac.iis)),
+	}
 	g, ctx := errgroup.WithContext(ctx)
 	g.SetLimit(ac.a.mergeWorkers)
 	closeFiles := true
@@ -1819,6 +1934,13 @@ func (a *Aggregator) SetProduceMod(produce bool) {
 	a.produce = produce
 }
 
+// SetShadowCommitment enables computation of second commitment (not in consensus) with given trie variant.
+// Every computation also moves `conversionKeys` of existing state keys to shadow commitment (state conversion),
+// so eventually it covers whole state.
+func (a *Aggregator) SetShadowCommitment(tv commitment.TrieVariant, conversionKeys int) {
+	a.shadowCommitment, a.shadowConversionKeys = tv, conversionKeys
+}
+
 // Returns channel which is closed when aggregation is done
 func (a *Aggregator) BuildFilesInBackground(txNum uint64) chan struct{} {
 	fin := make(chan struct{})
@@ -1927,6 +2049,12 @@ func (ac *AggregatorRoTx) IndexRange(name kv.InvertedIdx, k []byte, fromTs, toTs
 	case kv.TracesToIdx:
 		return ac.iis[kv.TracesToIdxPos].IdxRange(k, fromTs, toTs, asc, limit, tx)
 	default:
+		if pos, ok := kv.InvertedIdxPosByName(name); ok && int(pos) < len(ac.iis) {
+			return ac.iis[pos].IdxRange(k, fromTs, toTs, asc, limit, tx)
+		}
+		if d, ok := kv.DomainByHistoryIdx(name); ok && int(d) < len(ac.d) {
+			return ac.d[d].ht.IdxRange(k, fromTs, toTs, asc, limit, tx)
+		}
 		return nil, fmt.Errorf("unexpected history name: %s", name)
 	}
 }
@@ -1953,11 +2081,19 @@ func (ac *AggregatorRoTx) HistorySeek(name kv.History, key []byte, ts uint64, tx
 	//case kv.GasUsedHistory:
 	//	return ac.d[kv.GasUsedDomain].ht.HistorySeek(key, ts, tx)
 	default:
+		if d, ok := kv.DomainByHistory(name); ok && int(d) < len(ac.d) {
+			return ac.d[d].ht.HistorySeek(key, ts, tx)
+		}
 		panic(fmt.Sprintf("unexpected: %s", name))
 	}
 }
 
 func (ac *AggregatorRoTx) HistoryRange(name kv.History, fromTs, toTs int, asc order.By, limit int, tx kv.Tx) (it stream.KV, err error) {
+	return ac.HistoryRangeFromKey(name, nil, fromTs, toTs, asc, limit, tx)
+}
+
+// HistoryRangeFromKey - same as HistoryRange, but starts from key `fromKey` (inclusive)
+func (ac *AggregatorRoTx) HistoryRangeFromKey(name kv.History, fromKey []byte, fromTs, toTs int, asc order.By, limit int, tx kv.Tx) (it stream.KV, err error) {
 	//TODO: aggTx to store array of histories
 	var domainName kv.Domain
 
@@ -1969,10 +2105,14 @@ func (ac *AggregatorRoTx) HistoryRange(name kv.History, fromTs, toTs int, asc or
 	case kv.CodeHistory:
 		domainName = kv.CodeDomain
 	default:
-		return nil, fmt.Errorf("unexpected history name: %s", name)
+		d, ok := kv.DomainByHistory(name)
+		if !ok || d < kv.DomainLen || int(d) >= len(ac.d) { // commitment history is not iterable
+			return nil, fmt.Errorf("unexpected history name: %s", name)
+		}
+		domainName = d
 	}
 
-	hr, err := ac.d[domainName].ht.HistoryRange(fromTs, toTs, asc, limit, tx)
+	hr, err := ac.d[domainName].ht.HistoryRangeFromKey(fromKey, fromTs, toTs, asc, limit, tx)
 	if err != nil {
 		return nil, err
 	}
@@ -1987,8 +2127,8 @@ func (ac *AggregatorRoTx) HistoryRange(name kv.History, fromTs, toTs int, asc or
 //   - last reader removing garbage files inside `Close` method
 type AggregatorRoTx struct {
 	a          *Aggregator
-	d          [kv.DomainLen]*DomainRoTx
-	iis        [kv.StandaloneIdxLen]*InvertedIndexRoTx
+	d          []*DomainRoTx
+	iis        []*InvertedIndexRoTx
 	appendable [kv.AppendableLen]*AppendableRoTx
 
 	id      uint64 // auto-increment id of ctx for logs
@@ -1998,6 +2138,8 @@ type AggregatorRoTx struct {
 func (a *Aggregator) BeginFilesRo() *AggregatorRoTx {
 	ac := &AggregatorRoTx{
 		a:       a,
+		d:       make([]*DomainRoTx, len(a.d)),
+		iis:     make([]*InvertedIndexRoTx, len(a.iis)),
 		id:      a.ctxAutoIncrement.Add(1),
 		_leakID: a.leakDetector.Add(),
 	}
@@ -2098,7 +2240,14 @@ func (ac *AggregatorRoTx) DebugEFAllValuesAreInRange(ctx context.Context, name k
 			return err
 		}
 	default:
-		panic(fmt.Sprintf("unexpected: %s", name))
+		if d, ok := kv.DomainByHistoryIdx(name); ok && int(d) < len(ac.d) {
+			return ac.d[d].ht.iit.DebugEFAllValuesAreInRange(ctx, failFast, fromStep)
+		}
+		pos, ok := kv.InvertedIdxPosByName(name)
+		if !ok || int(pos) >= len(ac.iis) {
+			panic(fmt.Sprintf("unexpected: %s", name))
+		}
+		return ac.iis[pos].DebugEFAllValuesAreInRange(ctx, failFast, fromStep)
 	}
 	return nil
 }
@@ -27,15 +27,15 @@ import (
 )
 
 type SelectedStaticFilesV3 struct {
-	d          [kv.DomainLen][]*filesItem
-	dHist      [kv.DomainLen][]*filesItem
-	dIdx       [kv.DomainLen][]*filesItem
-	ii         [kv.StandaloneIdxLen][]*filesItem
+	d          [][]*filesItem
+	dHist      [][]*filesItem
+	dIdx       [][]*filesItem
+	ii         [][]*filesItem
 	appendable [kv.AppendableLen][]*filesItem
 }
 
 func (sf SelectedStaticFilesV3) Close() {
-	clist := make([][]*filesItem, 0, int(kv.DomainLen)+int(kv.StandaloneIdxLen))
+	clist := make([][]*filesItem, 0, len(sf.d)*3+len(sf.ii))
 	for id := range sf.d {
 		clist = append(clist, sf.d[id], sf.dIdx[id], sf.dHist[id])
 	}
@@ -58,6 +58,8 @@ func (sf SelectedStaticFilesV3) Close() {
 }
 
 func (ac *AggregatorRoTx) staticFilesInRange(r RangesV3) (sf SelectedStaticFilesV3, err error) {
+	sf.d, sf.dIdx, sf.dHist = make([][]*filesItem, len(ac.d)), make([][]*filesItem, len(ac.d)), make([][]*filesItem, len(ac.d))
+	sf.ii = make([][]*filesItem, len(ac.iis))
 	for id := range ac.d {
 		if !r.domain[id].any() {
 			continue
@@ -80,10 +82,10 @@ func (ac *AggregatorRoTx) staticFilesInRange(r RangesV3) (sf SelectedStaticFiles
 }
 
 type MergedFilesV3 struct {
-	d          [kv.DomainLen]*filesItem
-	dHist      [kv.DomainLen]*filesItem
-	dIdx       [kv.DomainLen]*filesItem
-	iis        [kv.StandaloneIdxLen]*filesItem
+	d          []*filesItem
+	dHist      []*filesItem
+	dIdx       []*filesItem
+	iis        []*filesItem
 	appendable [kv.AppendableLen]*filesItem
 }
 
@@ -110,11 +112,11 @@ func (mf MergedFilesV3) FrozenList() (frozen []string) {
 	return frozen
 }
 func (mf MergedFilesV3) Close() {
-	clist := make([]*filesItem, 0, kv.DomainLen+4)
+	clist := make([]*filesItem, 0, len(mf.d)*3+len(mf.iis))
 	for id := range mf.d {
 		clist = append(clist, mf.d[id], mf.dHist[id], mf.dIdx[id])
 	}
-	clist = append(clist, mf.iis[:]...)
+	clist = append(clist, mf.iis...)
 
 	for _, item := range clist {
 		if item != nil {
@@ -129,12 +131,13 @@ func (mf MergedFilesV3) Close() {
 }
 
 type MergedFiles struct {
-	d     [kv.DomainLen]*filesItem
-	dHist [kv.DomainLen]*filesItem
-	dIdx  [kv.DomainLen]*filesItem
+	d     []*filesItem
+	dHist []*filesItem
+	dIdx  []*filesItem
 }
 
 func (mf MergedFiles) FillV3(m *MergedFilesV3) MergedFiles {
+	mf.d, mf.dHist, mf.dIdx = make([]*filesItem, len(m.d)), make([]*filesItem, len(m.d)), make([]*filesItem, len(m.d))
 	for id := range m.d {
 		mf.d[id], mf.dHist[id], mf.dIdx[id] = m.d[id], m.dHist[id], m.dIdx[id]
 	}
@@ -26,6 +26,8 @@ import (
 	"math/rand"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
 	"sync/atomic"
 	"testing"
 	"time"
@@ -40,6 +42,7 @@ import (
 	"github.com/erigontech/erigon-lib/common/length"
 	"github.com/erigontech/erigon-lib/etl"
 	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/kvtest"
 	"github.com/erigontech/erigon-lib/kv/mdbx"
 	"github.com/erigontech/erigon-lib/kv/order"
 	"github.com/erigontech/erigon-lib/kv/rawdbv3"
@@ -164,6 +167,289 @@ func TestAggregatorV3_Merge(t *testing.T) {
 	require.EqualValues(t, otherMaxWrite, binary.BigEndian.Uint64(v[:]))
 }
 
+func TestAggregatorV3_UserInvertedIndex(t *testing.T) {
+	testTransfersIdx := kv.InvertedIdx("TestTransfersIdx")
+	testTransfersIdxPos := kvtest.RegisterInvertedIdx(t, testTransfersIdx, "testtransfers")
+	db, agg := testDbAndAggregatorv3(t, 100)
+	require.Equal(t, int(testTransfersIdxPos)+1, len(agg.iis))
+	require.Equal(t, "testtransfers", testTransfersIdxPos.String())
+
+	rwTx, err := db.BeginRwNosync(context.Background())
+	require.NoError(t, err)
+	defer func() {
+		if rwTx != nil {
+			rwTx.Rollback()
+		}
+	}()
+	ac := agg.BeginFilesRo()
+	defer ac.Close()
+	domains, err := NewSharedDomains(WrapTxWithCtx(rwTx, ac), log.New())
+	require.NoError(t, err)
+	defer domains.Close()
+
+	holder1, holder2 := []byte("holder1"), []byte("holder2")
+	txs := uint64(5000)
+	var expect1, expect2 []uint64
+	for txNum := uint64(1); txNum <= txs; txNum++ {
+		domains.SetTxNum(txNum)
+		// pruning and merging of indices follows progress of domain files
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], txNum)
+		for _, d := range []kv.Domain{kv.AccountsDomain, kv.StorageDomain, kv.CodeDomain, kv.CommitmentDomain} {
+			require.NoError(t, domains.DomainPut(d, holder1, nil, v[:], nil, 0))
+		}
+		if txNum%3 == 0 {
+			require.NoError(t, domains.IndexAdd(testTransfersIdx, holder1))
+			expect1 = append(expect1, txNum)
+		}
+		if txNum%7 == 0 {
+			require.NoError(t, domains.IndexAdd(kv.InvertedIdx(kv.UserInvertedIdxs()[0].IndexTable), holder2))
+			expect2 = append(expect2, txNum)
+		}
+	}
+	require.NoError(t, domains.Flush(context.Background(), rwTx))
+	domains.Close()
+	require.NoError(t, rwTx.Commit())
+	rwTx = nil
+
+	require.NoError(t, agg.BuildFiles(txs))
+
+	rwTx, err = db.BeginRw(context.Background())
+	require.NoError(t, err)
+	logEvery := time.NewTicker(30 * time.Second)
+	defer logEvery.Stop()
+	pruneTx := agg.BeginFilesRo()
+	defer pruneTx.Close()
+	stat, err := pruneTx.Prune(context.Background(), rwTx, 0, logEvery)
+	require.NoError(t, err)
+	require.NotNil(t, stat.Indices["testtransfers"])
+	pruneTx.Close()
+	require.NoError(t, rwTx.Commit())
+	rwTx = nil
+
+	require.NoError(t, agg.MergeLoop(context.Background()))
+
+	roTx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer roTx.Rollback()
+
+	// only txNums of last unfinished step left in db
+	cnt, err := roTx.Count(kv.UserInvertedIdxs()[0].KeysTable)
+	require.NoError(t, err)
+	require.Less(t, cnt, uint64(agg.StepSize()))
+
+	files := agg.BeginFilesRo()
+	defer files.Close()
+	require.Equal(t, txs/agg.StepSize()*agg.StepSize(), files.iis[testTransfersIdxPos].files.EndTxNum())
+	require.Less(t, len(files.iis[testTransfersIdxPos].files), int(txs/agg.StepSize()), "files are merged")
+
+	it, err := files.IndexRange(testTransfersIdx, holder1, -1, -1, order.Asc, -1, roTx)
+	require.NoError(t, err)
+	got, err := stream.ToArrayU64(it)
+	require.NoError(t, err)
+	require.Equal(t, expect1, got)
+
+	it, err = files.IndexRange(testTransfersIdx, holder2, 1000, 2000, order.Asc, -1, roTx)
+	require.NoError(t, err)
+	got, err = stream.ToArrayU64(it)
+	require.NoError(t, err)
+	var expectRange []uint64
+	for _, txNum := range expect2 {
+		if txNum >= 1000 && txNum < 2000 {
+			expectRange = append(expectRange, txNum)
+		}
+	}
+	require.Equal(t, expectRange, got)
+}
+
+func TestAggregatorV3_HistoryRetention(t *testing.T) {
+	db, agg := testDbAndAggregatorv3(t, 10)
+	kept, skipped := []byte("kept"), []byte("skipped")
+	agg.LimitHistoryToKeys(kv.AccountsDomain, func(key1 []byte) bool { return bytes.Equal(key1, kept) })
+
+	rwTx, err := db.BeginRwNosync(context.Background())
+	require.NoError(t, err)
+	defer func() {
+		if rwTx != nil {
+			rwTx.Rollback()
+		}
+	}()
+	ac := agg.BeginFilesRo()
+	defer ac.Close()
+	domains, err := NewSharedDomains(WrapTxWithCtx(rwTx, ac), log.New())
+	require.NoError(t, err)
+	defer domains.Close()
+
+	txs := uint64(200)
+	for txNum := uint64(1); txNum <= txs; txNum++ {
+		domains.SetTxNum(txNum)
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], txNum)
+		for _, d := range []kv.Domain{kv.AccountsDomain, kv.StorageDomain, kv.CodeDomain, kv.CommitmentDomain} {
+			require.NoError(t, domains.DomainPut(d, kept, nil, v[:], nil, 0))
+		}
+		require.NoError(t, domains.DomainPut(kv.AccountsDomain, skipped, nil, v[:], nil, 0))
+		require.NoError(t, domains.IndexAdd(kv.LogAddrIdx, kept))
+	}
+	require.NoError(t, domains.Flush(context.Background(), rwTx))
+	domains.Close()
+	require.NoError(t, rwTx.Commit())
+	rwTx = nil
+
+	require.NoError(t, agg.BuildFiles(txs))
+	rwTx, err = db.BeginRw(context.Background())
+	require.NoError(t, err)
+	pruneTx := agg.BeginFilesRo()
+	_, err = pruneTx.Prune(context.Background(), rwTx, 0, nil)
+	pruneTx.Close()
+	require.NoError(t, err)
+	require.NoError(t, rwTx.Commit())
+	rwTx = nil
+
+	const retention = 170
+	deleted, err := agg.PruneFiles(FilesRetention{
+		Histories: map[kv.Domain]uint64{kv.AccountsDomain: retention},
+		Indices:   map[kv.InvertedIdxPos]uint64{kv.LogAddrIdxPos: retention},
+	})
+	require.NoError(t, err)
+	require.NotEmpty(t, deleted)
+	for _, f := range deleted {
+		require.True(t, strings.Contains(f, "accounts") || strings.Contains(f, "logaddrs"), f)
+		_, err := os.Stat(filepath.Join(agg.dirs.Snap, f))
+		require.True(t, os.IsNotExist(err), f)
+	}
+
+	roTx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer roTx.Rollback()
+	files := agg.BeginFilesRo()
+	defer files.Close()
+
+	for _, name := range []kv.InvertedIdx{kv.AccountsHistoryIdx, kv.LogAddrIdx} {
+		it, err := files.IndexRange(name, kept, -1, -1, order.Asc, -1, roTx)
+		require.NoError(t, err)
+		got, err := stream.ToArrayU64(it)
+		require.NoError(t, err)
+		require.NotEmpty(t, got, name)
+		require.GreaterOrEqual(t, got[0], uint64(100), name)
+		require.Equal(t, txs, got[len(got)-1], name)
+	}
+	// storage history is not affected
+	it, err := files.IndexRange(kv.StorageHistoryIdx, kept, -1, -1, order.Asc, -1, roTx)
+	require.NoError(t, err)
+	got, err := stream.ToArrayU64(it)
+	require.NoError(t, err)
+	require.Equal(t, uint64(1), got[0])
+
+	it, err = files.IndexRange(kv.AccountsHistoryIdx, skipped, -1, -1, order.Asc, -1, roTx)
+	require.NoError(t, err)
+	got, err = stream.ToArrayU64(it)
+	require.NoError(t, err)
+	require.Empty(t, got)
+
+	// history of skipped key is unknown: reads must not fall back to latest value
+	v, ok, err := files.DomainGetAsOf(roTx, kv.AccountsDomain, kept, 180)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.EqualValues(t, 179, binary.BigEndian.Uint64(v))
+	_, _, err = files.DomainGetAsOf(roTx, kv.AccountsDomain, skipped, 180)
+	require.ErrorIs(t, err, ErrHistoryNotKept)
+	_, _, err = files.HistorySeek(kv.AccountsHistory, skipped, 180, roTx)
+	require.ErrorIs(t, err, ErrHistoryNotKept)
+}
+
+func TestAggregatorV3_UserDomain(t *testing.T) {
+	balances := kvtest.RegisterDomain(t, "TestBalances", "testbalances")
+	db, agg := testDbAndAggregatorv3(t, 100)
+	require.Equal(t, int(balances)+1, len(agg.d))
+	require.Equal(t, "testbalances", balances.String())
+	dom, err := kv.String2Domain("testbalances")
+	require.NoError(t, err)
+	require.Equal(t, balances, dom)
+
+	rwTx, err := db.BeginRwNosync(context.Background())
+	require.NoError(t, err)
+	defer func() {
+		if rwTx != nil {
+			rwTx.Rollback()
+		}
+	}()
+	ac := agg.BeginFilesRo()
+	defer ac.Close()
+	domains, err := NewSharedDomains(WrapTxWithCtx(rwTx, ac), log.New())
+	require.NoError(t, err)
+	defer domains.Close()
+
+	holder := []byte("holder")
+	txs := uint64(1000)
+	var prevVal []byte
+	var prevStep uint64
+	for txNum := uint64(1); txNum <= txs; txNum++ {
+		domains.SetTxNum(txNum)
+		var v [8]byte
+		binary.BigEndian.PutUint64(v[:], txNum)
+		// user domain files are built along with files of built-in domains
+		for _, d := range []kv.Domain{kv.AccountsDomain, kv.StorageDomain, kv.CodeDomain, kv.CommitmentDomain} {
+			require.NoError(t, domains.DomainPut(d, holder, nil, v[:], nil, 0))
+		}
+		if txNum%10 == 0 {
+			require.NoError(t, domains.DomainPut(balances, holder, nil, v[:], prevVal, prevStep))
+			prevVal, prevStep = common.Copy(v[:]), txNum/agg.StepSize()
+		}
+	}
+	require.NoError(t, domains.Flush(context.Background(), rwTx))
+	domains.Close()
+	require.NoError(t, rwTx.Commit())
+	rwTx = nil
+
+	require.NoError(t, agg.BuildFiles(txs))
+
+	rwTx, err = db.BeginRw(context.Background())
+	require.NoError(t, err)
+	logEvery := time.NewTicker(30 * time.Second)
+	defer logEvery.Stop()
+	pruneTx := agg.BeginFilesRo()
+	defer pruneTx.Close()
+	_, err = pruneTx.Prune(context.Background(), rwTx, 0, logEvery)
+	require.NoError(t, err)
+	pruneTx.Close()
+	require.NoError(t, rwTx.Commit())
+	rwTx = nil
+
+	require.NoError(t, agg.MergeLoop(context.Background()))
+
+	roTx, err := db.BeginRo(context.Background())
+	require.NoError(t, err)
+	defer roTx.Rollback()
+
+	files := agg.BeginFilesRo()
+	defer files.Close()
+	require.Equal(t, txs/agg.StepSize()*agg.StepSize(), files.d[balances].files.EndTxNum())
+	require.Less(t, len(files.d[balances].files), int(txs/agg.StepSize()), "files are merged")
+
+	v, _, ok, err := files.GetLatest(balances, holder, nil, roTx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.EqualValues(t, txs, binary.BigEndian.Uint64(v))
+
+	// value as of txNum: written by previous multiple of 10
+	v, ok, err = files.DomainGetAsOf(roTx, balances, holder, 555)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.EqualValues(t, 550, binary.BigEndian.Uint64(v))
+
+	v, ok, err = files.HistorySeek(balances.History(), holder, 555, roTx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.EqualValues(t, 550, binary.BigEndian.Uint64(v))
+
+	it, err := files.IndexRange(balances.HistoryIdx(), holder, 500, 550, order.Asc, -1, roTx)
+	require.NoError(t, err)
+	got, err := stream.ToArrayU64(it)
+	require.NoError(t, err)
+	require.Equal(t, []uint64{500, 510, 520, 530, 540}, got)
+}
+
 func TestAggregatorV3_MergeValTransform(t *testing.T) {
 	db, agg := testDbAndAggregatorv3(t, 1000)
 	rwTx, err := db.BeginRwNosync(context.Background())
@@ -1160,8 +1446,8 @@ func TestAggregatorV3_SharedDomains(t *testing.T) {
 	domains, err := NewSharedDomains(WrapTxWithCtx(rwTx, ac), log.New())
 	require.NoError(t, err)
 	defer domains.Close()
-	changesetAt5 := &StateChangeSet{}
-	changesetAt3 := &StateChangeSet{}
+	changesetAt5 := NewStateChangeSet()
+	changesetAt3 := NewStateChangeSet()
 
 	keys, vals := generateInputData(t, 20, 16, 10)
 	keys = keys[:2]
@@ -1206,11 +1492,11 @@ func TestAggregatorV3_SharedDomains(t *testing.T) {
 	domains, err = NewSharedDomains(WrapTxWithCtx(rwTx, ac), log.New())
 	require.NoError(t, err)
 	defer domains.Close()
-	diffs := [kv.DomainLen][]DomainEntryDiff{}
+	diffs := make([][]DomainEntryDiff, kv.DomainsLen())
 	for idx := range changesetAt5.Diffs {
 		diffs[idx] = changesetAt5.Diffs[idx].GetDiffSet()
 	}
-	err = domains.Unwind(context.Background(), rwTx, 0, pruneFrom, &diffs)
+	err = domains.Unwind(context.Background(), rwTx, 0, pruneFrom, diffs)
 	require.NoError(t, err)
 
 	domains.SetChangesetAccumulator(changesetAt3)
@@ -1248,7 +1534,7 @@ func TestAggregatorV3_SharedDomains(t *testing.T) {
 	for idx := range changesetAt3.Diffs {
 		diffs[idx] = changesetAt3.Diffs[idx].GetDiffSet()
 	}
-	err = domains.Unwind(context.Background(), rwTx, 0, pruneFrom, &diffs)
+	err = domains.Unwind(context.Background(), rwTx, 0, pruneFrom, diffs)
 	require.NoError(t, err)
 
 	for i = int(pruneFrom); i < len(vals); i++ {
@@ -0,0 +1,200 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package state
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/erigontech/erigon-lib/commitment"
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+)
+
+// shadowCommitment - second commitment, which is not in consensus. It's computed from the same touched keys as
+// main one and stored in commitment domain under commitment.ShadowKeyPrefix, so it follows main commitment on
+// unwind/prune/merge. Keys which were not touched since shadow commitment was enabled are moved to it by
+// state conversion: a few keys per computation, in order of accounts then storage domain.
+type shadowCommitment struct {
+	trie           commitment.Trie
+	updates        *commitment.Updates
+	conversionKeys int
+	roots          map[uint64][]byte // block number => root and conversion state, not yet flushed to kv.ShadowCommitmentRoots
+}
+
+// keyShadowConversion - key of conversion cursor: `domain | next key`, or shadowConversionDone
+var keyShadowConversion = append(common.Copy(commitment.ShadowKeyPrefix), 'c')
+
+const shadowConversionDone = 0xff
+
+func newShadowCommitment(sdc *SharedDomainsCommitmentContext, tv commitment.TrieVariant, conversionKeys int) *shadowCommitment {
+	s := &shadowCommitment{conversionKeys: conversionKeys, roots: map[uint64][]byte{}}
+	s.trie, s.updates = commitment.InitializeTrieAndUpdates(tv, commitment.ModeDirect, sdc.sharedDomains.aggTx.a.tmpdir)
+	s.trie.ResetContext(sdc)
+	return s
+}
+
+// Code - implements commitment.CodeReader: shadow tries may keep code as part of the tree
+func (sdc *SharedDomainsCommitmentContext) Code(plainKey []byte) ([]byte, error) {
+	code, _, err := sdc.sharedDomains.DomainGet(kv.CodeDomain, plainKey, nil)
+	if err != nil {
+		return nil, fmt.Errorf("shadow commitment: failed to read code: %w", err)
+	}
+	return code, nil
+}
+
+func (s *shadowCommitment) touchKey(d kv.Domain, key []byte) {
+	switch d {
+	case kv.AccountsDomain, kv.CodeDomain:
+		s.updates.TouchPlainKey(key, nil, s.updates.TouchAccount)
+	case kv.StorageDomain:
+		s.updates.TouchPlainKey(key, nil, s.updates.TouchStorage)
+	}
+}
+
+// computeShadowCommitment - converts next portion of state and applies touched keys to shadow commitment
+func (sdc *SharedDomainsCommitmentContext) computeShadowCommitment(ctx context.Context, saveState bool, blockNum uint64, logPrefix string) error {
+	s := sdc.shadow
+	defer func(t time.Time) { mxShadowCommitmentTook.ObserveDuration(t) }(time.Now())
+
+	complete, err := sdc.convertToShadow()
+	if err != nil {
+		return fmt.Errorf("shadow commitment conversion: %w", err)
+	}
+	var rootHash []byte
+	if s.updates.Size() == 0 {
+		rootHash, err = s.trie.RootHash()
+	} else {
+		s.trie.SetTrace(sdc.sharedDomains.trace)
+		rootHash, err = s.trie.Process(ctx, s.updates, logPrefix)
+	}
+	if err != nil {
+		return fmt.Errorf("shadow commitment %s: %w", s.trie.Variant(), err)
+	}
+	if saveState {
+		v := make([]byte, length.Hash+1)
+		copy(v, rootHash)
+		if complete {
+			v[length.Hash] = 1
+		}
+		s.roots[blockNum] = v
+	}
+	return nil
+}
+
+// convertToShadow - touches next conversionKeys keys of state, returns true if whole state is converted
+func (sdc *SharedDomainsCommitmentContext) convertToShadow() (complete bool, err error) {
+	cursor, step, err := sdc.Branch(keyShadowConversion)
+	if err != nil {
+		return false, err
+	}
+	if len(cursor) == 1 && cursor[0] == shadowConversionDone {
+		return true, nil
+	}
+	if sdc.shadow.conversionKeys <= 0 {
+		return false, nil
+	}
+	domain, from := kv.AccountsDomain, []byte(nil)
+	if len(cursor) > 0 {
+		domain, from = kv.Domain(cursor[0]), cursor[1:]
+	}
+
+	roTx := sdc.sharedDomains.roTx
+	for left := sdc.shadow.conversionKeys; left > 0; {
+		it, err := sdc.sharedDomains.aggTx.DomainRangeLatest(roTx, domain, from, nil, left)
+		if err != nil {
+			return false, err
+		}
+		var last []byte
+		var n int
+		for it.HasNext() {
+			k, _, err := it.Next()
+			if err != nil {
+				it.Close()
+				return false, err
+			}
+			sdc.shadow.touchKey(domain, k)
+			last = append(last[:0], k...)
+			n++
+		}
+		it.Close()
+		mxShadowConvertedKeys.AddInt(n)
+		if last != nil {
+			from = append(last, 0) // next key after last
+		}
+		if left -= n; left <= 0 {
+			break
+		}
+		// domain is exhausted
+		if domain == kv.StorageDomain {
+			complete = true
+			break
+		}
+		domain, from = kv.StorageDomain, nil
+	}
+
+	next := []byte{shadowConversionDone}
+	if !complete {
+		next = append([]byte{byte(domain)}, from...)
+	}
+	return complete, sdc.PutBranch(keyShadowConversion, next, cursor, step)
+}
+
+// flushShadowCommitmentRoots - writes roots of computed blocks to kv.ShadowCommitmentRoots
+func (sd *SharedDomains) flushShadowCommitmentRoots(tx kv.RwTx) error {
+	if sd.sdCtx.shadow == nil {
+		return nil
+	}
+	for blockNum, v := range sd.sdCtx.shadow.roots {
+		if err := tx.Put(kv.ShadowCommitmentRoots, binary.BigEndian.AppendUint64(nil, blockNum), v); err != nil {
+			return err
+		}
+	}
+	clear(sd.sdCtx.shadow.roots)
+	return nil
+}
+
+// unwindShadowCommitmentRoots - removes roots of blocks after blockUnwindTo
+func unwindShadowCommitmentRoots(tx kv.RwTx, blockUnwindTo uint64) error {
+	c, err := tx.RwCursor(kv.ShadowCommitmentRoots)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	for k, _, err := c.Seek(binary.BigEndian.AppendUint64(nil, blockUnwindTo+1)); k != nil || err != nil; k, _, err = c.Next() {
+		if err != nil {
+			return err
+		}
+		if err = c.DeleteCurrent(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReadShadowCommitmentRoot - root of shadow commitment after block and whether state conversion was complete at
+// that block. Returns ok=false if shadow commitment wasn't computed for the block.
+func ReadShadowCommitmentRoot(tx kv.Getter, blockNum uint64) (root common.Hash, complete bool, ok bool, err error) {
+	v, err := tx.GetOne(kv.ShadowCommitmentRoots, binary.BigEndian.AppendUint64(nil, blockNum))
+	if err != nil || len(v) != length.Hash+1 {
+		return root, false, false, err
+	}
+	return common.BytesToHash(v[:length.Hash]), v[length.Hash] == 1, true, nil
+}
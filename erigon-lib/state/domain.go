@@ -714,6 +714,12 @@ func domainReadMetric(name kv.Domain, level int) metrics.Summary {
 	if level > 4 {
 		level = 5
 	}
+	if int(name) >= len(mxsKVGet) { // user-defined domain
+		if level == 5 {
+			return metrics.GetOrCreateSummary(fmt.Sprintf(`kv_get{level="recent",domain="%s"}`, name))
+		}
+		return metrics.GetOrCreateSummary(fmt.Sprintf(`kv_get{level="L%d",domain="%s"}`, level, name))
+	}
 	return mxsKVGet[name][level]
 }
 
@@ -92,11 +92,11 @@ type SharedDomains struct {
 	//muMaps   sync.RWMutex
 	//walLock sync.RWMutex
 
-	domains [kv.DomainLen]map[string]dataWithPrevStep
+	domains []map[string]dataWithPrevStep
 	storage *btree2.Map[string, dataWithPrevStep]
 
-	domainWriters    [kv.DomainLen]*domainBufferedWriter
-	iiWriters        [kv.StandaloneIdxLen]*invertedIndexBufferedWriter
+	domainWriters    []*domainBufferedWriter
+	iiWriters        []*invertedIndexBufferedWriter
 	appendableWriter [kv.AppendableLen]*appendableBufferedWriter
 
 	currentChangesAccumulator *StateChangeSet
@@ -121,10 +121,13 @@ func NewSharedDomains(tx kv.Tx, logger log.Logger) (*SharedDomains, error) {
 
 	sd.aggTx.a.DiscardHistory(kv.CommitmentDomain)
 
+	sd.iiWriters = make([]*invertedIndexBufferedWriter, len(sd.aggTx.iis))
 	for id, ii := range sd.aggTx.iis {
 		sd.iiWriters[id] = ii.NewWriter()
 	}
 
+	sd.domains = make([]map[string]dataWithPrevStep, len(sd.aggTx.d))
+	sd.domainWriters = make([]*domainBufferedWriter, len(sd.aggTx.d))
 	for id, d := range sd.aggTx.d {
 		sd.domains[id] = map[string]dataWithPrevStep{}
 		sd.domainWriters[id] = d.NewWriter()
@@ -164,17 +167,16 @@ func (sd *SharedDomains) SavePastChangesetAccumulator(blockHash common.Hash, blo
 	sd.pastChangesAccumulator[string(key[:])] = acc
 }
 
-func (sd *SharedDomains) GetDiffset(tx kv.RwTx, blockHash common.Hash, blockNumber uint64) ([kv.DomainLen][]DomainEntryDiff, bool, error) {
+func (sd *SharedDomains) GetDiffset(tx kv.RwTx, blockHash common.Hash, blockNumber uint64) ([][]DomainEntryDiff, bool, error) {
 	var key [40]byte
 	binary.BigEndian.PutUint64(key[:8], blockNumber)
 	copy(key[8:], blockHash[:])
 	if changeset, ok := sd.pastChangesAccumulator[string(key[:])]; ok {
-		return [kv.DomainLen][]DomainEntryDiff{
-			changeset.Diffs[kv.AccountsDomain].GetDiffSet(),
-			changeset.Diffs[kv.StorageDomain].GetDiffSet(),
-			changeset.Diffs[kv.CodeDomain].GetDiffSet(),
-			changeset.Diffs[kv.CommitmentDomain].GetDiffSet(),
-		}, true, nil
+		diffs := make([][]DomainEntryDiff, len(changeset.Diffs))
+		for i := range changeset.Diffs {
+			diffs[i] = changeset.Diffs[i].GetDiffSet()
+		}
+		return diffs, true, nil
 	}
 	return ReadDiffSet(tx, blockNumber, blockHash)
 }
@@ -186,7 +188,7 @@ func (sd *SharedDomains) CanonicalReader() CanonicalsReader {
 }
 
 // aggregator context should call aggTx.Unwind before this one.
-func (sd *SharedDomains) Unwind(ctx context.Context, rwTx kv.RwTx, blockUnwindTo, txUnwindTo uint64, changeset *[kv.DomainLen][]DomainEntryDiff) error {
+func (sd *SharedDomains) Unwind(ctx context.Context, rwTx kv.RwTx, blockUnwindTo, txUnwindTo uint64, changeset [][]DomainEntryDiff) error {
 	step := txUnwindTo / sd.aggTx.a.StepSize()
 	logEvery := time.NewTicker(30 * time.Second)
 	defer logEvery.Stop()
@@ -201,7 +203,11 @@ func (sd *SharedDomains) Unwind(ctx context.Context, rwTx kv.RwTx, blockUnwindTo
 	}
 
 	for idx, d := range sd.aggTx.d {
-		if err := d.Unwind(ctx, rwTx, step, txUnwindTo, changeset[idx]); err != nil {
+		var diffs []DomainEntryDiff
+		if idx < len(changeset) {
+			diffs = changeset[idx]
+		}
+		if err := d.Unwind(ctx, rwTx, step, txUnwindTo, diffs); err != nil {
 			return err
 		}
 	}
@@ -216,6 +222,9 @@ func (sd *SharedDomains) Unwind(ctx context.Context, rwTx kv.RwTx, blockUnwindTo
 			return err
 		}
 	}
+	if err := unwindShadowCommitmentRoots(rwTx, blockUnwindTo); err != nil {
+		return err
+	}
 
 	sd.ClearRam(true)
 	sd.SetTxNum(txUnwindTo)
@@ -319,6 +328,9 @@ func (sd *SharedDomains) ClearRam(resetCommitment bool) {
 	}
 	if resetCommitment {
 		sd.sdCtx.updates.Reset()
+		if sd.sdCtx.shadow != nil {
+			sd.sdCtx.shadow.updates.Reset()
+		}
 		sd.sdCtx.Reset()
 	}
 
@@ -394,7 +406,7 @@ func (sd *SharedDomains) LatestCommitment(prefix []byte) ([]byte, uint64, error)
 		return nil, 0, fmt.Errorf("commitment prefix %x read error: %w", prefix, err)
 	}
 
-	if !sd.aggTx.a.commitmentValuesTransform || bytes.Equal(prefix, keyCommitmentState) {
+	if !sd.aggTx.a.commitmentValuesTransform || !isCommitmentBranchKey(prefix) {
 		return v, endTx / sd.aggTx.a.StepSize(), nil
 	}
 
@@ -415,7 +427,7 @@ func (sd *SharedDomains) replaceShortenedKeysInBranch(prefix []byte, branch comm
 	if !sd.aggTx.a.commitmentValuesTransform ||
 		len(branch) == 0 ||
 		sd.aggTx.minimaxTxNumInDomainFiles() == 0 ||
-		bytes.Equal(prefix, keyCommitmentState) || ((fEndTxNum-fStartTxNum)/sd.aggTx.a.StepSize())%2 != 0 {
+		!isCommitmentBranchKey(prefix) || ((fEndTxNum-fStartTxNum)/sd.aggTx.a.StepSize())%2 != 0 {
 
 		return branch, nil // do not transform, return as is
 	}
@@ -586,19 +598,11 @@ func (sd *SharedDomains) delAccountStorage(addr, loc []byte, preVal []byte, prev
 }
 
 func (sd *SharedDomains) IndexAdd(table kv.InvertedIdx, key []byte) (err error) {
-	switch table {
-	case kv.LogAddrIdx, kv.TblLogAddressIdx:
-		err = sd.iiWriters[kv.LogAddrIdxPos].Add(key)
-	case kv.LogTopicIdx, kv.TblLogTopicsIdx, kv.LogTopicIndex:
-		err = sd.iiWriters[kv.LogTopicIdxPos].Add(key)
-	case kv.TblTracesToIdx:
-		err = sd.iiWriters[kv.TracesToIdxPos].Add(key)
-	case kv.TblTracesFromIdx:
-		err = sd.iiWriters[kv.TracesFromIdxPos].Add(key)
-	default:
+	pos, ok := kv.InvertedIdxPosByName(table)
+	if !ok || int(pos) >= len(sd.iiWriters) {
 		panic(fmt.Errorf("unknown shared index %s", table))
 	}
-	return err
+	return sd.iiWriters[pos].Add(key)
 }
 
 func (sd *SharedDomains) SetTx(tx kv.Tx) {
@@ -837,6 +841,9 @@ func (sd *SharedDomains) Flush(ctx context.Context, tx kv.RwTx) error {
 		_, f, l, _ := runtime.Caller(1)
 		fmt.Printf("[SD aggTx=%d] FLUSHING at tx %d [%x], caller %s:%d\n", sd.aggTx.id, sd.TxNum(), fh, filepath.Base(f), l)
 	}
+	if err := sd.flushShadowCommitmentRoots(tx); err != nil {
+		return err
+	}
 	for _, w := range sd.domainWriters {
 		if w == nil {
 			continue
@@ -1023,6 +1030,7 @@ type SharedDomainsCommitmentContext struct {
 	updates       *commitment.Updates
 	patriciaTrie  commitment.Trie
 	justRestored  atomic.Bool
+	shadow        *shadowCommitment // nil if shadow commitment is disabled
 }
 
 func NewSharedDomainsCommitmentContext(sd *SharedDomains, mode commitment.Mode, trieVariant commitment.TrieVariant) *SharedDomainsCommitmentContext {
@@ -1035,11 +1043,17 @@ func NewSharedDomainsCommitmentContext(sd *SharedDomains, mode commitment.Mode,
 
 	ctx.patriciaTrie, ctx.updates = commitment.InitializeTrieAndUpdates(trieVariant, mode, sd.aggTx.a.tmpdir)
 	ctx.patriciaTrie.ResetContext(ctx)
+	if tv := sd.aggTx.a.shadowCommitment; tv != "" {
+		ctx.shadow = newShadowCommitment(ctx, tv, sd.aggTx.a.shadowConversionKeys)
+	}
 	return ctx
 }
 
 func (sdc *SharedDomainsCommitmentContext) Close() {
 	sdc.updates.Close()
+	if sdc.shadow != nil {
+		sdc.shadow.updates.Close()
+	}
 }
 
 type cachedBranch struct {
@@ -1180,12 +1194,18 @@ func (sdc *SharedDomainsCommitmentContext) TouchKey(d kv.Domain, key string, val
 	default:
 		panic(fmt.Errorf("TouchKey: unknown domain %s", d))
 	}
+	if sdc.shadow != nil {
+		sdc.shadow.touchKey(d, ks)
+	}
 }
 
 // Evaluates commitment for processed state.
 func (sdc *SharedDomainsCommitmentContext) ComputeCommitment(ctx context.Context, saveState bool, blockNum uint64, logPrefix string) (rootHash []byte, err error) {
 	if dbg.DiscardCommitment() {
 		sdc.updates.Reset()
+		if sdc.shadow != nil {
+			sdc.shadow.updates.Reset()
+		}
 		return nil, nil
 	}
 	sdc.ResetBranchCache()
@@ -1199,6 +1219,12 @@ func (sdc *SharedDomainsCommitmentContext) ComputeCommitment(ctx context.Context
 	if sdc.sharedDomains.trace {
 		defer sdc.sharedDomains.logger.Trace("ComputeCommitment", "block", blockNum, "keys", updateCount, "mode", sdc.updates.Mode())
 	}
+	if sdc.shadow != nil {
+		// shadow commitment doesn't depend on main trie, computed first to keep early return below
+		if err := sdc.computeShadowCommitment(ctx, saveState, blockNum, logPrefix); err != nil {
+			return nil, err
+		}
+	}
 	if updateCount == 0 {
 		rootHash, err = sdc.patriciaTrie.RootHash()
 		return rootHash, err
@@ -1277,6 +1303,11 @@ func (sdc *SharedDomainsCommitmentContext) encodeCommitmentState(blockNum, txNum
 // by that key stored latest root hash and tree state
 var keyCommitmentState = []byte("state")
 
+// isCommitmentBranchKey - value of key is commitment.BranchData, so it's keys could be shortened/replaced
+func isCommitmentBranchKey(key []byte) bool {
+	return !bytes.Equal(key, keyCommitmentState) && !commitment.IsShadowKey(key)
+}
+
 func (sd *SharedDomains) LatestCommitmentState(tx kv.Tx, sinceTx, untilTx uint64) (blockNum, txNum uint64, state []byte, err error) {
 	return sd.sdCtx.LatestCommitmentState()
 }
@@ -27,6 +27,8 @@ import (
 	"github.com/holiman/uint256"
 	"github.com/stretchr/testify/require"
 
+	"github.com/erigontech/erigon-lib/commitment"
+	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/rawdbv3"
 	"github.com/erigontech/erigon-lib/log/v3"
@@ -124,7 +126,7 @@ func TestSharedDomain_Unwind(t *testing.T) {
 	require.NoError(t, err)
 	defer domains.Close()
 
-	stateChangeset := &StateChangeSet{}
+	stateChangeset := NewStateChangeSet()
 	domains.SetChangesetAccumulator(stateChangeset)
 
 	maxTx := stepSize
@@ -180,11 +182,11 @@ Loop:
 	domains.currentChangesAccumulator = nil
 
 	acu := agg.BeginFilesRo()
-	var a [kv.DomainLen][]DomainEntryDiff
+	a := make([][]DomainEntryDiff, kv.DomainsLen())
 	for idx, d := range stateChangeset.Diffs {
 		a[idx] = d.GetDiffSet()
 	}
-	err = domains.Unwind(ctx, rwTx, 0, unwindTo, &a)
+	err = domains.Unwind(ctx, rwTx, 0, unwindTo, a)
 	require.NoError(t, err)
 	acu.Close()
 
@@ -514,3 +516,94 @@ func TestSharedDomain_StorageIter(t *testing.T) {
 	domains.Close()
 	ac.Close()
 }
+
+func TestSharedDomain_ShadowCommitment(t *testing.T) {
+	stepSize := uint64(100)
+	ctx := context.Background()
+	const blocks = 5
+
+	execute := func(t *testing.T, db kv.RwDB, agg *Aggregator) {
+		t.Helper()
+		rwTx, err := db.BeginRw(ctx)
+		require.NoError(t, err)
+		defer rwTx.Rollback()
+		ac := agg.BeginFilesRo()
+		defer ac.Close()
+		domains, err := NewSharedDomains(WrapTxWithCtx(rwTx, ac), log.New())
+		require.NoError(t, err)
+		defer domains.Close()
+
+		for b := uint64(1); b <= blocks; b++ {
+			domains.SetBlockNum(b)
+			domains.SetTxNum(b)
+			for i := 0; i < 20; i++ {
+				addr := make([]byte, length.Addr)
+				addr[0], addr[length.Addr-1] = byte(i), byte(b)
+				acc := types.EncodeAccountBytesV3(b, uint256.NewInt(uint64(i)*1000+b), nil, 0)
+				require.NoError(t, domains.DomainPut(kv.AccountsDomain, addr, nil, acc, nil, 0))
+				if i%4 == 0 {
+					slot := make([]byte, length.Hash)
+					slot[length.Hash-1] = byte(i)
+					require.NoError(t, domains.DomainPut(kv.StorageDomain, addr, slot, []byte{byte(b), 1}, nil, 0))
+				}
+			}
+			_, err = domains.ComputeCommitment(ctx, true, b, "")
+			require.NoError(t, err)
+		}
+		fillRawdbTxNumsIndexForSharedDomains(t, rwTx, blocks, 1)
+		require.NoError(t, domains.Flush(ctx, rwTx))
+		require.NoError(t, rwTx.Commit())
+	}
+
+	// shadow commitment is enabled from the beginning: conversion is not needed
+	db, agg := testDbAndAggregatorv3(t, stepSize)
+	agg.SetShadowCommitment(commitment.VariantBinaryTrie, 0)
+	execute(t, db, agg)
+
+	rwTx, err := db.BeginRw(ctx)
+	require.NoError(t, err)
+	defer rwTx.Rollback()
+	expected, complete, ok, err := ReadShadowCommitmentRoot(rwTx, blocks)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.False(t, complete)
+	require.NotEqual(t, common.Hash{}, expected)
+
+	prev, _, ok, err := ReadShadowCommitmentRoot(rwTx, blocks-1)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.NotEqual(t, expected, prev)
+
+	require.NoError(t, unwindShadowCommitmentRoots(rwTx, blocks-1))
+	_, _, ok, err = ReadShadowCommitmentRoot(rwTx, blocks)
+	require.NoError(t, err)
+	require.False(t, ok)
+	rwTx.Rollback()
+
+	// shadow commitment is enabled after execution: state conversion must give the same root
+	db, agg = testDbAndAggregatorv3(t, stepSize)
+	execute(t, db, agg)
+	agg.SetShadowCommitment(commitment.VariantBinaryTrie, 7)
+
+	rwTx, err = db.BeginRw(ctx)
+	require.NoError(t, err)
+	defer rwTx.Rollback()
+	ac := agg.BeginFilesRo()
+	defer ac.Close()
+	domains, err := NewSharedDomains(WrapTxWithCtx(rwTx, ac), log.New())
+	require.NoError(t, err)
+	defer domains.Close()
+
+	var root common.Hash
+	for b := uint64(blocks + 1); !complete; b++ {
+		require.Less(t, b, uint64(100), "conversion didn't finish")
+		domains.SetBlockNum(b)
+		_, err = domains.ComputeCommitment(ctx, true, b, "")
+		require.NoError(t, err)
+		require.NoError(t, domains.Flush(ctx, rwTx))
+		root, complete, ok, err = ReadShadowCommitmentRoot(rwTx, b)
+		require.NoError(t, err)
+		require.True(t, ok)
+	}
+	require.Equal(t, expected, root)
+}
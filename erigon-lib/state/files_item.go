@@ -173,6 +173,22 @@ func deleteMergeFile(dirtyFiles *btree2.BTreeG[*filesItem], outs []*filesItem, f
 	}
 }
 
+// filesBefore - files which contain only txNums before `txNum`
+func filesBefore(dirtyFiles *btree2.BTreeG[*filesItem], txNum uint64) (outs []*filesItem) {
+	dirtyFiles.Walk(func(items []*filesItem) bool {
+		for _, item := range items {
+			if item.endTxNum > txNum {
+				return false
+			}
+			if !item.canDelete.Load() {
+				outs = append(outs, item)
+			}
+		}
+		return true
+	})
+	return outs
+}
+
 // visibleFile is like filesItem but only for good/visible files (indexed, not overlaped, not marked for deletion, etc...)
 // it's ok to store visibleFile in array
 type visibleFile struct {
@@ -268,6 +284,18 @@ func (files visibleFiles) EndTxNum() uint64 {
 	return files[len(files)-1].endTxNum
 }
 
+// ContiguousStartTxNum - first txNum of files which have no gaps up to the latest file. false if there are no files
+func (files visibleFiles) ContiguousStartTxNum() (uint64, bool) {
+	if len(files) == 0 {
+		return 0, false
+	}
+	i := len(files) - 1
+	for i > 0 && files[i-1].endTxNum == files[i].startTxNum {
+		i--
+	}
+	return files[i].startTxNum, true
+}
+
 func (files visibleFiles) LatestMergedRange() MergeRange {
 	if len(files) == 0 {
 		return MergeRange{}
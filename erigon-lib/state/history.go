@@ -89,6 +89,8 @@ type History struct {
 	snapshotsDisabled bool   // don't produce .v and .ef files, keep in db table. old data will be pruned anyway.
 	historyDisabled   bool   // skip all write operations to this History (even in DB)
 	keepRecentTxnInDB uint64 // When dontProduceHistoryFiles=true, keepRecentTxInDB is used to keep this amount of txn in db before pruning
+
+	keepKey func(key1 []byte) bool // if set: write history only of keys accepted by it
 }
 
 type histCfg struct {
@@ -462,6 +464,9 @@ func (w *historyBufferedWriter) AddPrevValue(key1, key2, original []byte, origin
 	if w.discard {
 		return nil
 	}
+	if w.keepKey != nil && !w.keepKey(key1) {
+		return nil
+	}
 
 	if original == nil {
 		original = []byte{}
@@ -520,6 +525,7 @@ type historyBufferedWriter struct {
 	historyVals      *etl.Collector
 	historyKey       []byte
 	discard          bool
+	keepKey          func(key1 []byte) bool
 	historyValsTable string
 
 	// not large:
@@ -548,6 +554,7 @@ func (w *historyBufferedWriter) close() {
 func (ht *HistoryRoTx) newWriter(tmpdir string, discard bool) *historyBufferedWriter {
 	w := &historyBufferedWriter{
 		discard: discard,
+		keepKey: ht.h.keepKey,
 
 		historyKey:       make([]byte, 128),
 		largeValues:      ht.h.historyLargeValues,
@@ -1269,6 +1276,9 @@ func (ht *HistoryRoTx) encodeTs(txNum uint64) []byte {
 
 // HistorySeek searches history for a value of specified key before txNum
 // second return value is true if the value is found in the history (even if it is nil)
+// ErrHistoryNotKept - history of key is not written because of History.keepKey: value as of given txNum is unknown
+var ErrHistoryNotKept = errors.New("history of key is not kept")
+
 func (ht *HistoryRoTx) HistorySeek(key []byte, txNum uint64, roTx kv.Tx) ([]byte, bool, error) {
 	v, ok, err := ht.historySeekInFiles(key, txNum)
 	if err != nil {
@@ -1278,7 +1288,15 @@ func (ht *HistoryRoTx) HistorySeek(key []byte, txNum uint64, roTx kv.Tx) ([]byte
 		return v, true, nil
 	}
 
-	return ht.historySeekInDB(key, txNum, roTx)
+	v, ok, err = ht.historySeekInDB(key, txNum, roTx)
+	if err != nil || ok {
+		return v, ok, err
+	}
+	// no changes after txNum are recorded: for not kept key it doesn't mean that latest value is correct
+	if ht.h.keepKey != nil && !ht.h.keepKey(key) {
+		return nil, false, fmt.Errorf("%w: %s %x", ErrHistoryNotKept, ht.h.filenameBase, key)
+	}
+	return nil, false, nil
 }
 
 func (ht *HistoryRoTx) valsCursor(tx kv.Tx) (c kv.Cursor, err error) {
@@ -1627,7 +1645,7 @@ func (hi *StateAsOfIterDB) Next() ([]byte, []byte, error) {
 	return hi.kBackup, hi.vBackup, nil
 }
 
-func (ht *HistoryRoTx) iterateChangedFrozen(fromTxNum, toTxNum int, asc order.By, limit int) (stream.KV, error) {
+func (ht *HistoryRoTx) iterateChangedFrozen(fromKey []byte, fromTxNum, toTxNum int, asc order.By, limit int) (stream.KV, error) {
 	if asc == false {
 		panic("not supported yet")
 	}
@@ -1657,9 +1675,14 @@ func (ht *HistoryRoTx) iterateChangedFrozen(fromTxNum, toTxNum int, asc order.By
 		}
 		g := NewArchiveGetter(item.src.decompressor.MakeGetter(), ht.h.compression)
 		g.Reset(0)
-		if g.HasNext() {
+		for g.HasNext() {
 			key, offset := g.Next(nil)
+			if bytes.Compare(key, fromKey) < 0 { // file has no index by keys: skip values of smaller keys without reading them
+				g.Skip()
+				continue
+			}
 			heap.Push(&s.h, &ReconItem{g: g, key: key, startTxNum: item.startTxNum, endTxNum: item.endTxNum, txNum: item.endTxNum, startOffset: offset, lastOffset: offset})
+			break
 		}
 	}
 	if err := s.advance(); err != nil {
@@ -1669,7 +1692,7 @@ func (ht *HistoryRoTx) iterateChangedFrozen(fromTxNum, toTxNum int, asc order.By
 	return s, nil
 }
 
-func (ht *HistoryRoTx) iterateChangedRecent(fromTxNum, toTxNum int, asc order.By, limit int, roTx kv.Tx) (stream.KVS, error) {
+func (ht *HistoryRoTx) iterateChangedRecent(fromKey []byte, fromTxNum, toTxNum int, asc order.By, limit int, roTx kv.Tx) (stream.KVS, error) {
 	if asc == order.Desc {
 		panic("not supported yet")
 	}
@@ -1678,6 +1701,7 @@ func (ht *HistoryRoTx) iterateChangedRecent(fromTxNum, toTxNum int, asc order.By
 		return stream.EmptyKVS, nil
 	}
 	s := &HistoryChangesIterDB{
+		fromKey:     fromKey,
 		endTxNum:    toTxNum,
 		roTx:        roTx,
 		largeValues: ht.h.historyLargeValues,
@@ -1695,14 +1719,20 @@ func (ht *HistoryRoTx) iterateChangedRecent(fromTxNum, toTxNum int, asc order.By
 }
 
 func (ht *HistoryRoTx) HistoryRange(fromTxNum, toTxNum int, asc order.By, limit int, roTx kv.Tx) (stream.KVS, error) {
+	return ht.HistoryRangeFromKey(nil, fromTxNum, toTxNum, asc, limit, roTx)
+}
+
+// HistoryRangeFromKey - same as HistoryRange, but starts from key `fromKey` (inclusive).
+// DB is seeked to `fromKey`, values of smaller keys in files are skipped without reading.
+func (ht *HistoryRoTx) HistoryRangeFromKey(fromKey []byte, fromTxNum, toTxNum int, asc order.By, limit int, roTx kv.Tx) (stream.KVS, error) {
 	if asc == order.Desc {
 		panic("not supported yet")
 	}
-	itOnFiles, err := ht.iterateChangedFrozen(fromTxNum, toTxNum, asc, limit)
+	itOnFiles, err := ht.iterateChangedFrozen(fromKey, fromTxNum, toTxNum, asc, limit)
 	if err != nil {
 		return nil, err
 	}
-	itOnDB, err := ht.iterateChangedRecent(fromTxNum, toTxNum, asc, limit, roTx)
+	itOnDB, err := ht.iterateChangedRecent(fromKey, fromTxNum, toTxNum, asc, limit, roTx)
 	if err != nil {
 		return nil, err
 	}
@@ -1884,6 +1914,7 @@ type HistoryChangesIterDB struct {
 	valsTable       string
 	limit, endTxNum int
 	startTxKey      [8]byte
+	fromKey         []byte
 
 	nextKey, nextVal []byte
 	nextStep         uint64
@@ -1920,7 +1951,7 @@ func (hi *HistoryChangesIterDB) advanceLargeVals() error {
 		if hi.valsC, err = hi.roTx.Cursor(hi.valsTable); err != nil {
 			return err
 		}
-		firstKey, _, err := hi.valsC.First()
+		firstKey, _, err := hi.valsC.Seek(hi.fromKey)
 		if err != nil {
 			return err
 		}
@@ -1990,7 +2021,7 @@ func (hi *HistoryChangesIterDB) advanceSmallVals() (err error) {
 			return err
 		}
 
-		if k, _, err = hi.valsCDup.First(); err != nil {
+		if k, _, err = hi.valsCDup.Seek(hi.fromKey); err != nil {
 			return err
 		}
 	} else {
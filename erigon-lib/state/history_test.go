@@ -1137,6 +1137,21 @@ func TestIterateChanged(t *testing.T) {
 		require.Equal([]string{"0100000000000001", "0100000000000002"}, keys)
 		require.Equal([]string{"ff000000000003cf", "ff000000000001e7"}, vals)
 		require.Equal(make([]uint64, 2), steps)
+
+		// from key
+		it, err = ic.HistoryRangeFromKey(hexutility.MustDecodeHex("0100000000000009"), 995, -1, order.Asc, -1, tx)
+		require.NoError(err)
+		keys, vals, steps = keys[:0], vals[:0], steps[:0]
+		for it.HasNext() {
+			k, v, step, err := it.Next()
+			require.NoError(err)
+			keys = append(keys, fmt.Sprintf("%x", k))
+			vals = append(vals, fmt.Sprintf("%x", v))
+			steps = append(steps, step)
+		}
+		require.Equal([]string{"0100000000000009", "010000000000000a", "010000000000000c", "0100000000000014", "0100000000000019", "010000000000001b"}, keys)
+		require.Equal([]string{"ff0000000000006e", "ff00000000000063", "ff00000000000052", "ff00000000000031", "ff00000000000027", "ff00000000000024"}, vals)
+		require.Equal(make([]uint64, 6), steps)
 	}
 	t.Run("large_values", func(t *testing.T) {
 		db, h, txs := filledHistory(t, true, logger)
@@ -1250,6 +1265,16 @@ func TestIterateChanged2(t *testing.T) {
 			require.Equal(make([]uint64, 19), steps)
 			keys, vals, steps = keys[:0], vals[:0], steps[:0]
 
+			it, err = hc.HistoryRangeFromKey(hexutility.MustDecodeHex("0100000000000011"), 2, 20, order.Asc, -1, roTx)
+			require.NoError(err)
+			for it.HasNext() {
+				k, _, _, err := it.Next()
+				require.NoError(err)
+				keys = append(keys, fmt.Sprintf("%x", k))
+			}
+			require.Equal([]string{"0100000000000011", "0100000000000012", "0100000000000013"}, keys)
+			keys = keys[:0]
+
 			it, err = hc.HistoryRange(995, 1000, order.Asc, -1, roTx)
 			require.NoError(err)
 			for it.HasNext() {
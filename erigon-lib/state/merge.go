@@ -511,7 +511,7 @@ func (dt *DomainRoTx) mergeFiles(ctx context.Context, domainFiles, indexFiles, h
 		if !deleted {
 			if keyBuf != nil {
 				if vt != nil {
-					if !bytes.Equal(keyBuf, keyCommitmentState) { // no replacement for state key
+					if isCommitmentBranchKey(keyBuf) { // no replacement for state key
 						valBuf, err = vt(valBuf, keyFileStartTxNum, keyFileEndTxNum)
 						if err != nil {
 							return nil, nil, nil, fmt.Errorf("merge: valTransform failed: %w", err)
@@ -532,7 +532,7 @@ func (dt *DomainRoTx) mergeFiles(ctx context.Context, domainFiles, indexFiles, h
 	}
 	if keyBuf != nil {
 		if vt != nil {
-			if !bytes.Equal(keyBuf, keyCommitmentState) { // no replacement for state key
+			if isCommitmentBranchKey(keyBuf) { // no replacement for state key
 				valBuf, err = vt(valBuf, keyFileStartTxNum, keyFileEndTxNum)
 				if err != nil {
 					return nil, nil, nil, fmt.Errorf("merge: valTransform failed: %w", err)
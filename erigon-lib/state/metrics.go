@@ -59,10 +59,12 @@ var (
 	mxFlushTook            = metrics.GetOrCreateSummary("domain_flush_took")
 	mxCommitmentRunning    = metrics.GetOrCreateGauge("domain_running_commitment")
 	mxCommitmentTook       = metrics.GetOrCreateSummary("domain_commitment_took")
+	mxShadowCommitmentTook = metrics.GetOrCreateSummary("domain_shadow_commitment_took")
+	mxShadowConvertedKeys  = metrics.GetOrCreateCounter("domain_shadow_commitment_converted_keys")
 )
 
 var (
-	mxsKVGet = [kv.DomainLen][]metrics.Summary{
+	mxsKVGet = [...][]metrics.Summary{
 		kv.AccountsDomain: {
 			metrics.GetOrCreateSummary(`kv_get{level="L0",domain="account"}`),
 			metrics.GetOrCreateSummary(`kv_get{level="L1",domain="account"}`),
@@ -28,15 +28,19 @@ import (
 )
 
 type StateChangeSet struct {
-	Diffs [kv.DomainLen]StateDiffDomain // there are 4 domains of state changes
+	Diffs []StateDiffDomain // built-in domains of state changes, then user-defined ones
+}
+
+func NewStateChangeSet() *StateChangeSet {
+	return &StateChangeSet{Diffs: make([]StateDiffDomain, kv.DomainsLen())}
 }
 
 func (s *StateChangeSet) Copy() *StateChangeSet {
-	res := *s
+	res := &StateChangeSet{Diffs: make([]StateDiffDomain, len(s.Diffs))}
 	for i := range s.Diffs {
 		res.Diffs[i] = *s.Diffs[i].Copy()
 	}
-	return &res
+	return res
 }
 
 type DomainEntryDiff struct {
@@ -255,9 +259,14 @@ func (d *StateChangeSet) SerializeKeys(out []byte) []byte {
 	return ret
 }
 
-func DeserializeKeys(in []byte) [kv.DomainLen][]DomainEntryDiff {
-	var ret [kv.DomainLen][]DomainEntryDiff
+// DeserializeKeys - diffs of all domains. Changesets written before registration of user-defined domain
+// have no part for it: its diff is empty.
+func DeserializeKeys(in []byte) [][]DomainEntryDiff {
+	ret := make([][]DomainEntryDiff, kv.DomainsLen())
 	for i := range ret {
+		if len(in) == 0 {
+			break
+		}
 		diffSetLen := binary.BigEndian.Uint32(in)
 		in = in[4:]
 		ret[i] = DeserializeDiffSet(in[:diffSetLen])
@@ -298,18 +307,18 @@ func WriteDiffSet(tx kv.RwTx, blockNumber uint64, blockHash common.Hash, diffSet
 	return nil
 }
 
-func ReadDiffSet(tx kv.Tx, blockNumber uint64, blockHash common.Hash) ([kv.DomainLen][]DomainEntryDiff, bool, error) {
+func ReadDiffSet(tx kv.Tx, blockNumber uint64, blockHash common.Hash) ([][]DomainEntryDiff, bool, error) {
 	// Read the diffSet from the database
 	chunkCountBytes, err := tx.GetOne(kv.ChangeSets3, dbutils.BlockBodyKey(blockNumber, blockHash))
 	if err != nil {
-		return [kv.DomainLen][]DomainEntryDiff{}, false, err
+		return nil, false, err
 	}
 	if len(chunkCountBytes) == 0 {
-		return [kv.DomainLen][]DomainEntryDiff{}, false, nil
+		return nil, false, nil
 	}
 	chunkCount, err := dbutils.DecodeBlockNumber(chunkCountBytes)
 	if err != nil {
-		return [kv.DomainLen][]DomainEntryDiff{}, false, err
+		return nil, false, err
 	}
 
 	key := make([]byte, 48)
@@ -320,10 +329,10 @@ func ReadDiffSet(tx kv.Tx, blockNumber uint64, blockHash common.Hash) ([kv.Domai
 		binary.BigEndian.PutUint64(key[40:], i)
 		chunk, err := tx.GetOne(kv.ChangeSets3, key)
 		if err != nil {
-			return [kv.DomainLen][]DomainEntryDiff{}, false, err
+			return nil, false, err
 		}
 		if len(chunk) == 0 {
-			return [kv.DomainLen][]DomainEntryDiff{}, false, nil
+			return nil, false, nil
 		}
 		val = append(val, chunk...)
 	}
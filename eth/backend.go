@@ -51,6 +51,7 @@ import (
 	"github.com/erigontech/erigon-lib/chain"
 	"github.com/erigontech/erigon-lib/chain/networkname"
 	"github.com/erigontech/erigon-lib/chain/snapcfg"
+	"github.com/erigontech/erigon-lib/commitment"
 	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon-lib/common/dbg"
@@ -102,6 +103,7 @@ import (
 	"github.com/erigontech/erigon/core/vm"
 	"github.com/erigontech/erigon/crypto"
 	"github.com/erigontech/erigon/eth/consensuschain"
+	"github.com/erigontech/erigon/eth/devchain"
 	"github.com/erigontech/erigon/eth/ethconfig"
 	"github.com/erigontech/erigon/eth/ethconsensusconfig"
 	"github.com/erigontech/erigon/eth/ethutils"
@@ -126,12 +128,14 @@ import (
 	polygonsync "github.com/erigontech/erigon/polygon/sync"
 	"github.com/erigontech/erigon/rpc"
 	"github.com/erigontech/erigon/turbo/builder"
+	"github.com/erigontech/erigon/turbo/builder/relay"
 	"github.com/erigontech/erigon/turbo/engineapi"
 	"github.com/erigontech/erigon/turbo/engineapi/engine_block_downloader"
 	"github.com/erigontech/erigon/turbo/engineapi/engine_helpers"
 	"github.com/erigontech/erigon/turbo/execution/eth1"
 	"github.com/erigontech/erigon/turbo/execution/eth1/eth1_chain_reader.go"
 	"github.com/erigontech/erigon/turbo/jsonrpc"
+	"github.com/erigontech/erigon/turbo/jsonrpc/resultcache"
 	"github.com/erigontech/erigon/turbo/services"
 	"github.com/erigontech/erigon/turbo/shards"
 	"github.com/erigontech/erigon/turbo/silkworm"
@@ -152,7 +156,10 @@ type Ethereum struct {
 	chainDB    kv.RwDB
 	privateAPI *grpc.Server
 
-	engine consensus.Engine
+	engine   consensus.Engine
+	devChain *devchain.Controller // dev chain RPC, nil if it's not enabled
+
+	relayBuilder *relay.Builder // block builder for MEV-boost relays, nil if it's not enabled
 
 	gasPrice  *uint256.Int
 	etherbase libcommon.Address
@@ -162,6 +169,7 @@ type Ethereum struct {
 	lock         sync.RWMutex // Protects the variadic fields (e.g. gas price and etherbase)
 	chainConfig  *chain.Config
 	apiList      []rpc.API
+	resultCache  *resultcache.Cache // of RPC results, nil if disabled
 	genesisBlock *types.Block
 	genesisHash  libcommon.Hash
 
@@ -202,6 +210,7 @@ type Ethereum struct {
 	txPoolSend              *txpool.Send
 	txPoolGrpcServer        txpoolproto.TxpoolServer
 	notifyMiningAboutNewTxs chan struct{}
+	txsNotifier             *builder.TxsNotifier // new transactions of txpool for rebuilds of PoS payloads
 	forkValidator           *engine_helpers.ForkValidator
 	downloader              *downloader.Downloader
 
@@ -265,6 +274,9 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		if err != nil {
 			return err
 		}
+		if err := config.Prune.Validate(); err != nil {
+			return err
+		}
 
 		return nil
 	}); err != nil {
@@ -557,6 +569,19 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 	}
 
 	backend.engine = ethconsensusconfig.CreateConsensusEngine(ctx, stack.Config(), chainConfig, consensusConfig, config.Miner.Notify, config.Miner.Noverify, heimdallClient, config.WithoutHeimdall, blockReader, false /* readonly */, logger, polygonBridge, heimdallService)
+	if chainConfig.ChainName == networkname.DevChainName && config.Miner.Enabled && devchain.IsEnabled(stack.Config().Http.API) {
+		backend.devChain = devchain.NewController(backend.chainDB, chainConfig, blockReader, func() {
+			select {
+			case backend.notifyMiningAboutNewTxs <- struct{}{}:
+			default:
+			}
+		}, logger)
+		if err := backend.devChain.LoadOverrides(ctx); err != nil {
+			return nil, err
+		}
+		backend.engine = devchain.NewEngine(backend.engine, backend.devChain, core.DevnetSignPrivateKey)
+		logger.Warn("Dev chain RPC is enabled: state overrides are not part of blocks, the chain can't be synced by other nodes")
+	}
 
 	inMemoryExecution := func(txc wrap.TxContainer, header *types.Header, body *types.RawBody, unwindPoint uint64, headersChain []*types.Header, bodiesChain []*types.RawBody,
 		notifications *shards.Notifications) error {
@@ -651,6 +676,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 	}
 
 	backend.notifyMiningAboutNewTxs = make(chan struct{}, 1)
+	backend.txsNotifier = builder.NewTxsNotifier()
 	backend.miningSealingQuit = make(chan struct{})
 	backend.pendingBlocks = make(chan *types.Block, 1)
 	backend.minedBlocks = make(chan *types.Block, 1)
@@ -668,6 +694,10 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		recents = bor.Recents
 		signatures = bor.Signatures
 	}
+	var miningTxPool stagedsync.TxPoolForMining = backend.txPool
+	if backend.devChain != nil {
+		miningTxPool = devchain.NewTxPool(backend.txPool, backend.devChain)
+	}
 	// proof-of-work mining
 	mining := stagedsync.New(
 		config.Sync,
@@ -691,7 +721,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 				stages2.SilkwormForExecutionStage(backend.silkworm, config),
 			),
 			stagedsync.StageSendersCfg(backend.chainDB, chainConfig, config.Sync, false, dirs.Tmp, config.Prune, blockReader, backend.sentriesClient.Hd),
-			stagedsync.StageMiningExecCfg(backend.chainDB, miner, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, nil, 0, backend.txPool, backend.txPoolDB, blockReader),
+			stagedsync.StageMiningExecCfg(backend.chainDB, miner, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, nil, 0, miningTxPool, backend.txPoolDB, blockReader),
 			stagedsync.StageMiningFinishCfg(backend.chainDB, *backend.chainConfig, backend.engine, miner, backend.miningSealingQuit, backend.blockReader, latestBlockBuiltStore),
 		), stagedsync.MiningUnwindOrder, stagedsync.MiningPruneOrder,
 		logger)
@@ -707,8 +737,8 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 	}
 
 	// proof-of-stake mining
-	assembleBlockPOS := func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
-		miningStatePos := stagedsync.NewProposingState(&config.Miner)
+	buildBlockPOS := func(param *core.BlockBuilderParameters, minerCfg *params.MiningConfig, txPool stagedsync.TxPoolForMining, interrupt *int32) (*types.BlockWithReceipts, error) {
+		miningStatePos := stagedsync.NewProposingState(minerCfg)
 		miningStatePos.MiningConfig.Etherbase = param.SuggestedFeeRecipient
 		proposingSync := stagedsync.New(
 			config.Sync,
@@ -733,7 +763,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 					stages2.SilkwormForExecutionStage(backend.silkworm, config),
 				),
 				stagedsync.StageSendersCfg(backend.chainDB, chainConfig, config.Sync, false, dirs.Tmp, config.Prune, blockReader, backend.sentriesClient.Hd),
-				stagedsync.StageMiningExecCfg(backend.chainDB, miningStatePos, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, interrupt, param.PayloadId, backend.txPool, backend.txPoolDB, blockReader),
+				stagedsync.StageMiningExecCfg(backend.chainDB, miningStatePos, backend.notifications.Events, *backend.chainConfig, backend.engine, &vm.Config{}, tmpdir, interrupt, param.PayloadId, txPool, backend.txPoolDB, blockReader),
 				stagedsync.StageMiningFinishCfg(backend.chainDB, *backend.chainConfig, backend.engine, miningStatePos, backend.miningSealingQuit, backend.blockReader, latestBlockBuiltStore)), stagedsync.MiningUnwindOrder, stagedsync.MiningPruneOrder, logger)
 		// We start the mining step
 		if err := stages2.MiningStep(ctx, backend.chainDB, proposingSync, tmpdir, logger); err != nil {
@@ -742,6 +772,15 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		block := <-miningStatePos.MiningResultPOSCh
 		return block, nil
 	}
+	assembleBlockPOS := func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+		return buildBlockPOS(param, &config.Miner, backend.txPool, interrupt)
+	}
+	if len(config.Miner.BuilderRelays) > 0 {
+		if backend.relayBuilder, err = newRelayBuilder(ctx, config, chainConfig, backend.chainDB, backend.txPool, buildBlockPOS, logger); err != nil {
+			return nil, err
+		}
+		assembleBlockPOS = backend.relayBuilder.Wrap(assembleBlockPOS)
+	}
 
 	// Initialize ethbackend
 	ethBackendRPC := privateapi.NewEthBackendServer(ctx, backend, backend.chainDB, backend.notifications.Events, blockReader, logger, latestBlockBuiltStore)
@@ -795,6 +834,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		go txpool.MainLoop(backend.sentryCtx,
 			backend.txPoolDB, backend.txPool, backend.newTxs, backend.txPoolSend, newTxsBroadcaster,
 			func() {
+				backend.txsNotifier.Notify()
 				select {
 				case backend.notifyMiningAboutNewTxs <- struct{}{}:
 				default:
@@ -807,6 +847,11 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 		for {
 			select {
 			case b := <-backend.minedBlocks:
+				if backend.devChain != nil {
+					if err := backend.devChain.WriteSenders(ctx, b); err != nil {
+						logger.Error("write senders of mined block", "err", err)
+					}
+				}
 				// Add mined header and block body before broadcast. This is because the broadcast call
 				// will trigger the staged sync which will require headers and blocks to be available
 				// in their respective cache in the download stage. If not found, it would cause a
@@ -914,7 +959,7 @@ func New(ctx context.Context, stack *node.Node, config *ethconfig.Config, logger
 	checkStateRoot := true
 	pipelineStages := stages2.NewPipelineStages(ctx, backend.chainDB, config, p2pConfig, backend.sentriesClient, backend.notifications, backend.downloaderClient, blockReader, blockRetire, backend.agg, backend.silkworm, backend.forkValidator, logger, checkStateRoot)
 	backend.pipelineStagedSync = stagedsync.New(config.Sync, pipelineStages, stagedsync.PipelineUnwindOrder, stagedsync.PipelinePruneOrder, logger)
-	backend.eth1ExecutionServer = eth1.NewEthereumExecutionModule(blockReader, backend.chainDB, backend.pipelineStagedSync, backend.forkValidator, chainConfig, assembleBlockPOS, hook, backend.notifications.Accumulator, backend.notifications.StateChangesConsumer, logger, backend.engine, config.Sync, ctx)
+	backend.eth1ExecutionServer = eth1.NewEthereumExecutionModule(blockReader, backend.chainDB, backend.pipelineStagedSync, backend.forkValidator, chainConfig, assembleBlockPOS, builder.RebuildConfig{Interval: config.Miner.Recommit, NewTxs: backend.txsNotifier}, hook, backend.notifications.Accumulator, backend.notifications.StateChangesConsumer, logger, backend.engine, config.Sync, ctx)
 	executionRpc := direct.NewExecutionClientDirect(backend.eth1ExecutionServer)
 
 	var executionEngine executionclient.ExecutionEngine
@@ -1049,7 +1094,16 @@ func (s *Ethereum) Init(stack *node.Node, config *ethconfig.Config, chainConfig
 		}
 	}
 
-	s.apiList = jsonrpc.APIList(chainKv, ethRpcClient, txPoolRpcClient, miningRpcClient, ff, stateCache, blockReader, &httpRpcCfg, s.engine, s.logger, s.polygonBridge)
+	if s.resultCache, err = resultcache.New(ctx, httpRpcCfg.ResultCacheSize, httpRpcCfg.ResultCacheDir, s.logger); err != nil {
+		s.logger.Warn("[rpc] results cache is disabled", "err", err)
+	}
+	s.apiList = jsonrpc.APIList(chainKv, ethRpcClient, txPoolRpcClient, miningRpcClient, ff, stateCache, blockReader, &httpRpcCfg, s.engine, s.logger, s.polygonBridge, s.resultCache)
+	if s.devChain != nil {
+		s.apiList = append(s.apiList, devchain.APIs(s.devChain)...)
+	}
+	if s.relayBuilder != nil {
+		s.apiList = append(s.apiList, relay.APIs(s.relayBuilder, s.chainConfig)...)
+	}
 
 	if config.SilkwormRpcDaemon && httpRpcCfg.Enabled {
 		interface_log_settings := silkworm.RpcInterfaceLogSettings{
@@ -1138,12 +1192,21 @@ func (s *Ethereum) shouldPreserve(block *types.Block) bool { //nolint
 	// is A, F and G sign the block of round5 and reject the block of opponents
 	// and in the round6, the last available signer B is offline, the whole
 	// network is stuck.
-	if _, ok := s.engine.(*clique.Clique); ok {
+	if _, ok := s.cliqueEngine(); ok {
 		return false
 	}
 	return s.isLocalBlock(block)
 }
 
+func (s *Ethereum) cliqueEngine() (*clique.Clique, bool) {
+	if dev, ok := s.engine.(*devchain.Engine); ok {
+		c, ok := dev.InnerEngine().(*clique.Clique)
+		return c, ok
+	}
+	c, ok := s.engine.(*clique.Clique)
+	return c, ok
+}
+
 // StartMining starts the miner with the given number of CPU threads. If mining
 // is already running, this method adjust the number of threads allowed to use
 // and updates the minimum price required by the transaction pool.
@@ -1197,7 +1260,8 @@ func (s *Ethereum) StartMining(ctx context.Context, db kv.RwDB, stateDiffClient
 				}
 			}
 		} else if s.chainConfig.Consensus == chain.CliqueConsensus {
-			s.engine.(*clique.Clique).Authorize(eb, func(_ libcommon.Address, _ string, msg []byte) ([]byte, error) {
+			c, _ := s.cliqueEngine()
+			c.Authorize(eb, func(_ libcommon.Address, _ string, msg []byte) ([]byte, error) {
 				return crypto.Sign(crypto.Keccak256(msg), miner.MiningConfig.SigKey)
 			})
 		} else {
@@ -1471,6 +1535,12 @@ func setUpBlockReader(ctx context.Context, db kv.RwDB, dirs datadir.Dirs, snConf
 	}
 
 	agg.SetProduceMod(snConfig.Snapshot.ProduceE3)
+	if snConfig.ShadowCommitment != "" {
+		agg.SetShadowCommitment(commitment.ParseTrieVariant(snConfig.ShadowCommitment), int(snConfig.ShadowCommitmentConversion))
+	}
+	if keep := snConfig.Prune.HistoryKeyFilter(); keep != nil {
+		agg.LimitHistoryToKeys(kv.AccountsDomain, keep).LimitHistoryToKeys(kv.StorageDomain, keep).LimitHistoryToKeys(kv.CodeDomain, keep)
+	}
 
 	g := &errgroup.Group{}
 	g.Go(func() error {
@@ -1536,6 +1606,9 @@ func (s *Ethereum) Start() error {
 	time.Sleep(10 * time.Millisecond) // just to reduce logs order confusion
 
 	hook := stages2.NewHook(s.sentryCtx, s.chainDB, s.notifications, s.stagedSync, s.blockReader, s.chainConfig, s.logger, s.sentriesClient.SetStatus)
+	if s.devChain != nil {
+		hook.SetUnwindRequests(s.devChain)
+	}
 
 	currentTDProvider := func() *big.Int {
 		currentTD, err := readCurrentTotalDifficulty(s.sentryCtx, s.chainDB, s.blockReader)
@@ -1629,6 +1702,7 @@ func (s *Ethereum) Stop() error {
 		s.agg.Close()
 	}
 	s.chainDB.Close()
+	s.resultCache.Close()
 
 	if s.silkwormRPCDaemonService != nil {
 		if err := s.silkwormRPCDaemonService.Stop(); err != nil {
@@ -1764,6 +1838,37 @@ func setBorDefaultTxPoolPriceLimit(chainConfig *chain.Config, config txpoolcfg.C
 	}
 }
 
+// newRelayBuilder - builder of blocks for MEV-boost relays on top of PoS block building.
+// Blocks are built with gas limit registered by proposer of slot.
+func newRelayBuilder(ctx context.Context, config *ethconfig.Config, chainConfig *chain.Config, db kv.RoDB, txPool stagedsync.TxPoolForMining,
+	build func(param *core.BlockBuilderParameters, minerCfg *params.MiningConfig, txPool stagedsync.TxPoolForMining, interrupt *int32) (*types.BlockWithReceipts, error),
+	logger log.Logger) (*relay.Builder, error) {
+	_, beaconCfg, _, err := clparams.GetConfigsByNetworkName(chainConfig.ChainName)
+	if err != nil {
+		return nil, fmt.Errorf("builder: beacon chain config of %s: %w", chainConfig.ChainName, err)
+	}
+	genesisTime := config.Miner.BuilderGenesisTime
+	if genesisTime == 0 {
+		var ok bool
+		if genesisTime, ok = relay.GenesisTime(chainConfig.ChainName); !ok {
+			return nil, fmt.Errorf("builder: beacon chain genesis time of %s is unknown, set it by --builder.genesistime", chainConfig.ChainName)
+		}
+	}
+	cfg := relay.Config{
+		Relays:       config.Miner.BuilderRelays,
+		SecretKey:    config.Miner.BuilderSecretKey,
+		Interval:     config.Miner.BuilderInterval,
+		GenesisTime:  genesisTime,
+		BeaconConfig: beaconCfg,
+	}
+	buildFunc := func(param *core.BlockBuilderParameters, gasLimit uint64, txPool stagedsync.TxPoolForMining, interrupt *int32) (*types.BlockWithReceipts, error) {
+		minerCfg := config.Miner
+		minerCfg.GasLimit = gasLimit
+		return build(param, &minerCfg, txPool, interrupt)
+	}
+	return relay.New(ctx, cfg, db, buildFunc, txPool, logger)
+}
+
 func polygonSyncSentry(sentries []direct.SentryClient) direct.SentryClient {
 	// TODO - pending sentry multi client refactor
 	//      - sentry multi client should conform to the SentryClient interface and internally
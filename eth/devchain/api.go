@@ -0,0 +1,260 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package devchain
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/adapter/ethapi"
+)
+
+// Namespaces - RPC namespaces of dev chain API. Hardhat and Anvil names of the same methods are both supported.
+var Namespaces = []string{"evm", "anvil", "hardhat"}
+
+// IsEnabled - whether any of dev chain namespaces is in the list of enabled RPC APIs
+func IsEnabled(apis []string) bool {
+	for _, api := range apis {
+		for _, ns := range Namespaces {
+			if api == ns {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// APIs - dev chain RPC. eth_sendTransaction and eth_sendUnsignedTransaction are also served, for impersonated accounts.
+func APIs(c *Controller) []rpc.API {
+	accounts := &AccountsAPI{c: c}
+	return []rpc.API{
+		{Namespace: "evm", Public: true, Service: &EvmAPI{c: c}, Version: "1.0"},
+		{Namespace: "anvil", Public: true, Service: accounts, Version: "1.0"},
+		{Namespace: "hardhat", Public: true, Service: accounts, Version: "1.0"},
+		{Namespace: "eth", Public: true, Service: &EthAPI{c: c}, Version: "1.0"},
+	}
+}
+
+// EvmAPI - evm_* methods: snapshots and reverts, mining and time travel
+type EvmAPI struct {
+	c *Controller
+}
+
+// Snapshot implements evm_snapshot. Returns id of snapshot of the current head.
+func (api *EvmAPI) Snapshot(ctx context.Context) (hexutil.Uint64, error) {
+	id, err := api.c.Snapshot(ctx)
+	return hexutil.Uint64(id), err
+}
+
+// Revert implements evm_revert. Reverts chain to the snapshot and removes it together with all later snapshots.
+func (api *EvmAPI) Revert(ctx context.Context, id hexutil.Uint64) (bool, error) {
+	return api.c.Revert(ctx, uint64(id))
+}
+
+// Mine implements evm_mine. Mines one block, optionally with given timestamp.
+func (api *EvmAPI) Mine(ctx context.Context, timestamp *hexutil.Uint64) (string, error) {
+	var t uint64
+	if timestamp != nil {
+		t = uint64(*timestamp)
+	}
+	if _, err := api.c.Mine(ctx, t); err != nil {
+		return "", err
+	}
+	return "0x0", nil
+}
+
+// IncreaseTime implements evm_increaseTime. Returns total time adjustment in seconds.
+func (api *EvmAPI) IncreaseTime(seconds hexutil.Uint64) hexutil.Uint64 {
+	return hexutil.Uint64(api.c.IncreaseTime(uint64(seconds)))
+}
+
+// SetNextBlockTimestamp implements evm_setNextBlockTimestamp.
+func (api *EvmAPI) SetNextBlockTimestamp(ctx context.Context, timestamp hexutil.Uint64) error {
+	return api.c.SetNextBlockTimestamp(ctx, uint64(timestamp))
+}
+
+// AccountsAPI - anvil_*/hardhat_* methods: state overrides and impersonation. Every state override is a block.
+type AccountsAPI struct {
+	c *Controller
+}
+
+// SetBalance implements anvil_setBalance.
+func (api *AccountsAPI) SetBalance(ctx context.Context, addr libcommon.Address, balance hexutil.Big) error {
+	b, overflow := uint256.FromBig(balance.ToInt())
+	if overflow {
+		return fmt.Errorf("balance %s is too big", balance.String())
+	}
+	return api.c.setOverride(ctx, override{addr: addr, balance: b})
+}
+
+// SetNonce implements anvil_setNonce.
+func (api *AccountsAPI) SetNonce(ctx context.Context, addr libcommon.Address, nonce hexutil.Uint64) error {
+	n := uint64(nonce)
+	return api.c.setOverride(ctx, override{addr: addr, nonce: &n})
+}
+
+// SetCode implements anvil_setCode.
+func (api *AccountsAPI) SetCode(ctx context.Context, addr libcommon.Address, code hexutility.Bytes) error {
+	if code == nil {
+		code = []byte{}
+	}
+	return api.c.setOverride(ctx, override{addr: addr, code: code})
+}
+
+// SetStorageAt implements anvil_setStorageAt.
+func (api *AccountsAPI) SetStorageAt(ctx context.Context, addr libcommon.Address, slot libcommon.Hash, value libcommon.Hash) (bool, error) {
+	o := override{addr: addr, slot: &slot}
+	o.value.SetBytes32(value[:])
+	if err := api.c.setOverride(ctx, o); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// ImpersonateAccount implements anvil_impersonateAccount: eth_sendTransaction is accepted from addr.
+func (api *AccountsAPI) ImpersonateAccount(addr libcommon.Address) {
+	api.c.Impersonate(addr, true)
+}
+
+// StopImpersonatingAccount implements anvil_stopImpersonatingAccount.
+func (api *AccountsAPI) StopImpersonatingAccount(addr libcommon.Address) {
+	api.c.Impersonate(addr, false)
+}
+
+// EthAPI - sending transactions on behalf of accounts without their keys. Transaction is mined immediately.
+type EthAPI struct {
+	c *Controller
+}
+
+// SendTransaction implements eth_sendTransaction for impersonated accounts.
+func (api *EthAPI) SendTransaction(ctx context.Context, args ethapi.CallArgs) (libcommon.Hash, error) {
+	if args.From == nil {
+		return libcommon.Hash{}, fmt.Errorf("missing from")
+	}
+	if !api.c.isImpersonated(*args.From) {
+		return libcommon.Hash{}, fmt.Errorf("account %x is not impersonated, see anvil_impersonateAccount", *args.From)
+	}
+	return api.SendUnsignedTransaction(ctx, args)
+}
+
+// SendUnsignedTransaction implements eth_sendUnsignedTransaction: sends transaction from any account.
+func (api *EthAPI) SendUnsignedTransaction(ctx context.Context, args ethapi.CallArgs) (libcommon.Hash, error) {
+	if args.From == nil {
+		return libcommon.Hash{}, fmt.Errorf("missing from")
+	}
+	txn, err := api.c.newTransaction(ctx, args)
+	if err != nil {
+		return libcommon.Hash{}, err
+	}
+	if err := api.c.SendTransaction(ctx, txn); err != nil {
+		return libcommon.Hash{}, err
+	}
+	return txn.Hash(), nil
+}
+
+// newTransaction - impersonated transaction from CallArgs, missing fields are filled from the current head
+func (c *Controller) newTransaction(ctx context.Context, args ethapi.CallArgs) (types.Transaction, error) {
+	if args.GasPrice != nil && (args.MaxFeePerGas != nil || args.MaxPriorityFeePerGas != nil) {
+		return nil, fmt.Errorf("both gasPrice and (maxFeePerGas or maxPriorityFeePerGas) specified")
+	}
+	head, err := c.currentHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var nonce uint64
+	if args.Nonce != nil {
+		nonce = uint64(*args.Nonce)
+	} else if err := c.db.View(ctx, func(tx kv.Tx) error {
+		acc, err := state.NewReaderV4(tx.(kv.TemporalGetter)).ReadAccountData(*args.From)
+		if acc != nil {
+			nonce = acc.Nonce
+		}
+		return err
+	}); err != nil {
+		return nil, err
+	}
+	gas := head.GasLimit
+	if args.Gas != nil {
+		gas = uint64(*args.Gas)
+	}
+	value := new(uint256.Int)
+	if args.Value != nil {
+		value, _ = uint256.FromBig(args.Value.ToInt())
+	}
+	var data []byte
+	if args.Input != nil {
+		data = *args.Input
+	} else if args.Data != nil {
+		data = *args.Data
+	}
+
+	var txn types.Transaction
+	if head.BaseFee == nil || args.GasPrice != nil {
+		gasPrice := uint256.NewInt(params.GWei)
+		if args.GasPrice != nil {
+			gasPrice, _ = uint256.FromBig(args.GasPrice.ToInt())
+		} else if head.BaseFee != nil {
+			gasPrice, _ = uint256.FromBig(new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+		}
+		txn = &types.LegacyTx{CommonTx: types.CommonTx{Nonce: nonce, Gas: gas, To: args.To, Value: value, Data: data}, GasPrice: gasPrice}
+	} else {
+		tip := new(uint256.Int)
+		if args.MaxPriorityFeePerGas != nil {
+			tip, _ = uint256.FromBig(args.MaxPriorityFeePerGas.ToInt())
+		}
+		feeCap, _ := uint256.FromBig(new(big.Int).Mul(head.BaseFee, big.NewInt(2)))
+		feeCap.Add(feeCap, tip)
+		if args.MaxFeePerGas != nil {
+			feeCap, _ = uint256.FromBig(args.MaxFeePerGas.ToInt())
+		}
+		chainID, _ := uint256.FromBig(c.chainConfig.ChainID)
+		dynamic := &types.DynamicFeeTransaction{CommonTx: types.CommonTx{Nonce: nonce, Gas: gas, To: args.To, Value: value, Data: data}, ChainID: chainID, Tip: tip, FeeCap: feeCap}
+		if args.AccessList != nil {
+			dynamic.AccessList = *args.AccessList
+		}
+		txn = dynamic
+	}
+	return impersonate(txn, c.chainConfig, *args.From)
+}
+
+// impersonate - signs txn on behalf of `from` without its key: R=0 (never produced by a real signature) and S=from.
+// Such signature is invalid, so the sender is set explicitly, see Controller.WriteSenders.
+func impersonate(txn types.Transaction, chainConfig *chain.Config, from libcommon.Address) (types.Transaction, error) {
+	sig := make([]byte, crypto.SignatureLength)
+	copy(sig[64-length.Addr:64], from[:])
+	txn, err := txn.WithSignature(*types.LatestSigner(chainConfig), sig)
+	if err != nil {
+		return nil, err
+	}
+	txn.SetSender(from)
+	return txn, nil
+}
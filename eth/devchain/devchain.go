@@ -0,0 +1,498 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package devchain - Anvil/Hardhat-style developer RPC of the `--chain dev` mode: snapshots and reverts, manual
+// mining and time travel, state overrides and impersonated accounts.
+//
+// Everything is done by regular blocks of the dev chain: state overrides are applied by the consensus engine at the
+// end of the next block, reverts are unwinds which mark reverted blocks as bad. Overrides are stored in the db to
+// re-execute blocks after unwind or restart, but they are not part of blocks: the chain can't be synced by other nodes.
+// Impersonated transactions don't have valid signatures, their senders are written to the db together with own blocks.
+package devchain
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/tracing"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/turbo/services"
+)
+
+var (
+	// mineTimeout - how long RPC waits for the requested block to be mined and executed
+	mineTimeout = 30 * time.Second
+	// revertTimeout - how long RPC waits for requested unwind, it's applied only between sync cycles
+	revertTimeout = time.Minute
+	pollInterval  = 50 * time.Millisecond
+)
+
+// override - state change requested by RPC, applied at the end of the block
+type override struct {
+	addr    libcommon.Address
+	balance *uint256.Int
+	nonce   *uint64
+	code    []byte
+	slot    *libcommon.Hash
+	value   uint256.Int
+}
+
+func (o *override) apply(ibs *state.IntraBlockState) {
+	switch {
+	case o.balance != nil:
+		ibs.SetBalance(o.addr, o.balance, tracing.BalanceChangeUnspecified)
+	case o.nonce != nil:
+		ibs.SetNonce(o.addr, *o.nonce)
+	case o.code != nil:
+		ibs.SetCode(o.addr, o.code)
+	case o.slot != nil:
+		ibs.SetState(o.addr, o.slot, o.value)
+	}
+}
+
+type snapshot struct {
+	number     uint64
+	hash       libcommon.Hash
+	timeOffset uint64
+}
+
+type unwindRequest struct {
+	unwindPoint uint64
+	badBlock    libcommon.Hash
+}
+
+// Controller - state of dev chain RPC. Changes requested for the next block are bound to the current head: they are
+// applied by Engine to the block built on top of it, by whoever mines it.
+type Controller struct {
+	db           kv.RwDB
+	chainConfig  *chain.Config
+	blockReader  services.FullBlockReader
+	notifyMining func()
+	logger       log.Logger
+
+	opLock sync.Mutex // serialises RPC calls which change the chain
+
+	lock         sync.Mutex
+	head         libcommon.Hash // parent of the next block
+	headNumber   uint64
+	pending      []override                        // state changes for the next block
+	applied      map[libcommon.Hash]blockOverrides // parent hash => state changes applied to the block on top of it
+	txs          []types.Transaction               // impersonated transactions for the next block
+	nextTime     uint64                            // timestamp of the next block, 0 - not set
+	timeOffset   uint64                            // seconds added to wall clock by evm_increaseTime
+	force        bool                              // seal the next block even if it's empty
+	generation   uint64                            // written to extra-data of blocks mined after revert, see Engine.Prepare
+	unwind       *unwindRequest
+	snapshots    map[uint64]snapshot
+	lastSnapshot uint64
+	impersonated map[libcommon.Address]struct{}
+}
+
+func NewController(db kv.RwDB, chainConfig *chain.Config, blockReader services.FullBlockReader, notifyMining func(), logger log.Logger) *Controller {
+	return &Controller{
+		db:           db,
+		chainConfig:  chainConfig,
+		blockReader:  blockReader,
+		notifyMining: notifyMining,
+		logger:       logger,
+		applied:      map[libcommon.Hash]blockOverrides{},
+		snapshots:    map[uint64]snapshot{},
+		impersonated: map[libcommon.Address]struct{}{},
+	}
+}
+
+// overrides - state changes for the block built on top of parent
+func (c *Controller) overrides(parent libcommon.Hash) []override {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if o, ok := c.applied[parent]; ok {
+		return o.overrides
+	}
+	if parent == c.head {
+		return c.pending
+	}
+	return nil
+}
+
+// blockTime - timestamp of the block built on top of parent, minTime is the earliest one allowed by consensus
+func (c *Controller) blockTime(parent libcommon.Hash, minTime uint64) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if parent == c.head && c.nextTime != 0 {
+		return max(c.nextTime, minTime)
+	}
+	return max(uint64(time.Now().Unix())+c.timeOffset, minTime)
+}
+
+// sealDelay - how long Engine waits before sealing the block, forced blocks are sealed immediately
+func (c *Controller) sealDelay(header *types.Header) (delay time.Duration, forced bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if header.ParentHash == c.head && c.force {
+		return 0, true
+	}
+	if header.Time < c.timeOffset {
+		return 0, false
+	}
+	return time.Until(time.Unix(int64(header.Time-c.timeOffset), 0)), false
+}
+
+func (c *Controller) extraGeneration() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.generation
+}
+
+func (c *Controller) pendingTxs() []types.Transaction {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.txs
+}
+
+// NextUnwind - implements stages.UnwindRequests
+func (c *Controller) NextUnwind() (unwindPoint uint64, badBlock libcommon.Hash, ok bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.unwind == nil {
+		return 0, libcommon.Hash{}, false
+	}
+	u := c.unwind
+	c.unwind = nil
+	return u.unwindPoint, u.badBlock, true
+}
+
+// currentHead - last executed block
+func (c *Controller) currentHead(ctx context.Context) (header *types.Header, err error) {
+	err = c.db.View(ctx, func(tx kv.Tx) error {
+		number, err := stages.GetStageProgress(tx, stages.Finish)
+		if err != nil {
+			return err
+		}
+		hash, err := c.blockReader.CanonicalHash(ctx, tx, number)
+		if err != nil {
+			return err
+		}
+		header, err = c.blockReader.Header(ctx, tx, hash, number)
+		if err == nil && header == nil {
+			err = fmt.Errorf("head header %d not found", number)
+		}
+		return err
+	})
+	return header, err
+}
+
+// refresh - moves changes bound to the previous head to c.applied, if a block was built on top of it
+func (c *Controller) refresh(ctx context.Context) (*types.Header, error) {
+	head, err := c.currentHead(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	prev, prevNumber := c.head, c.headNumber
+	c.lock.Unlock()
+	built := head.ParentHash == prev
+	if !built && head.Hash() != prev && head.Number.Uint64() > prevNumber+1 {
+		// more than one block was built since the last refresh
+		if err := c.db.View(ctx, func(tx kv.Tx) error {
+			child, err := c.blockReader.HeaderByNumber(ctx, tx, prevNumber+1)
+			built = child != nil && child.ParentHash == prev
+			return err
+		}); err != nil {
+			return nil, err
+		}
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.advance(head, built)
+	return head, nil
+}
+
+// advance - sets new head, built is whether the next block on top of the previous head is canonical
+func (c *Controller) advance(head *types.Header, built bool) {
+	hash := head.Hash()
+	if hash == c.head {
+		return
+	}
+	if built {
+		if len(c.pending) > 0 {
+			c.applied[c.head] = blockOverrides{parentNumber: c.headNumber, overrides: c.pending}
+		}
+		c.pending, c.txs, c.nextTime, c.force = nil, nil, 0, false
+	}
+	c.head, c.headNumber = hash, head.Number.Uint64()
+}
+
+// mine - asks miner for the block on top of the current head and waits until it's executed
+func (c *Controller) mine(ctx context.Context) (*types.Header, error) {
+	head, err := c.refresh(ctx)
+	if err != nil {
+		return nil, err
+	}
+	c.lock.Lock()
+	c.force = true
+	c.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, mineTimeout)
+	defer cancel()
+	notify := time.NewTicker(time.Second)
+	defer notify.Stop()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	c.notifyMining()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("block on top of %d was not mined: %w", head.Number.Uint64(), ctx.Err())
+		case <-notify.C:
+			c.notifyMining()
+		case <-poll.C:
+			newHead, err := c.refresh(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if newHead.Number.Uint64() > head.Number.Uint64() {
+				return newHead, nil
+			}
+		}
+	}
+}
+
+func (c *Controller) setOverride(ctx context.Context, o override) error {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+	if _, err := c.refresh(ctx); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	c.pending = append(c.pending, o)
+	pending, head, headNumber := c.pending, c.head, c.headNumber
+	c.lock.Unlock()
+	if err := c.saveOverrides(ctx, headNumber, head, pending); err != nil {
+		return err
+	}
+	_, err := c.mine(ctx)
+	return err
+}
+
+// Mine - mines one block, with given timestamp if it's not 0
+func (c *Controller) Mine(ctx context.Context, timestamp uint64) (*types.Header, error) {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+	if timestamp != 0 {
+		if err := c.setNextBlockTimestamp(ctx, timestamp); err != nil {
+			return nil, err
+		}
+	}
+	return c.mine(ctx)
+}
+
+// IncreaseTime - moves clock of the chain forward, returns total offset from wall clock in seconds
+func (c *Controller) IncreaseTime(seconds uint64) uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.timeOffset += seconds
+	return c.timeOffset
+}
+
+// SetNextBlockTimestamp - timestamp of the next block, following blocks continue from it
+func (c *Controller) SetNextBlockTimestamp(ctx context.Context, timestamp uint64) error {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+	return c.setNextBlockTimestamp(ctx, timestamp)
+}
+
+func (c *Controller) setNextBlockTimestamp(ctx context.Context, timestamp uint64) error {
+	head, err := c.refresh(ctx)
+	if err != nil {
+		return err
+	}
+	if minTime := head.Time + c.period(); timestamp < minTime || timestamp == head.Time {
+		return fmt.Errorf("timestamp %d is lower than or equal to previous block's timestamp %d (period %d)", timestamp, head.Time, c.period())
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.nextTime = timestamp
+	if now := uint64(time.Now().Unix()); timestamp > now+c.timeOffset {
+		c.timeOffset = timestamp - now
+	}
+	return nil
+}
+
+func (c *Controller) period() uint64 {
+	if c.chainConfig.Clique == nil {
+		return 0
+	}
+	return c.chainConfig.Clique.Period
+}
+
+// Snapshot - remembers the current head, returns id of snapshot
+func (c *Controller) Snapshot(ctx context.Context) (uint64, error) {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+	head, err := c.refresh(ctx)
+	if err != nil {
+		return 0, err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lastSnapshot++
+	c.snapshots[c.lastSnapshot] = snapshot{number: head.Number.Uint64(), hash: head.Hash(), timeOffset: c.timeOffset}
+	return c.lastSnapshot, nil
+}
+
+// Revert - reverts chain to the snapshot, this snapshot and all later ones are removed.
+// Returns false if there is no such snapshot.
+func (c *Controller) Revert(ctx context.Context, id uint64) (bool, error) {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+	c.lock.Lock()
+	s, ok := c.snapshots[id]
+	for i := range c.snapshots {
+		if i >= id {
+			delete(c.snapshots, i)
+		}
+	}
+	c.lock.Unlock()
+	if !ok {
+		return false, nil
+	}
+
+	var badBlock libcommon.Hash
+	if err := c.db.View(ctx, func(tx kv.Tx) (err error) {
+		badBlock, err = c.blockReader.CanonicalHash(ctx, tx, s.number+1)
+		return err
+	}); err != nil {
+		return false, err
+	}
+	c.lock.Lock()
+	if badBlock != (libcommon.Hash{}) {
+		c.unwind = &unwindRequest{unwindPoint: s.number, badBlock: badBlock}
+	}
+	c.pending, c.txs, c.nextTime, c.force = nil, nil, 0, false
+	c.timeOffset = s.timeOffset
+	c.generation = uint64(time.Now().UnixNano())
+	c.lock.Unlock()
+	if err := c.deleteOverrides(ctx, s.number); err != nil {
+		return false, err
+	}
+	if badBlock == (libcommon.Hash{}) {
+		return true, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, revertTimeout)
+	defer cancel()
+	poll := time.NewTicker(pollInterval)
+	defer poll.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return false, fmt.Errorf("unwind to %d was not applied: %w", s.number, ctx.Err())
+		case <-poll.C:
+			head, err := c.refresh(ctx)
+			if err != nil {
+				return false, err
+			}
+			if head.Hash() == s.hash {
+				c.logger.Info("[devchain] reverted", "snapshot", id, "block", s.number)
+				return true, nil
+			}
+		}
+	}
+}
+
+// Impersonate - starts or stops impersonating addr, see SendTransaction
+func (c *Controller) Impersonate(addr libcommon.Address, impersonate bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if impersonate {
+		c.impersonated[addr] = struct{}{}
+	} else {
+		delete(c.impersonated, addr)
+	}
+}
+
+func (c *Controller) isImpersonated(addr libcommon.Address) bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	_, ok := c.impersonated[addr]
+	return ok
+}
+
+var ErrNotIncluded = errors.New("transaction was not included into block")
+
+// SendTransaction - mines block with txn, txn is signed by impersonate and its sender is not checked
+func (c *Controller) SendTransaction(ctx context.Context, txn types.Transaction) error {
+	c.opLock.Lock()
+	defer c.opLock.Unlock()
+	if _, err := c.refresh(ctx); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	c.txs = append(c.txs, txn)
+	c.lock.Unlock()
+	head, err := c.mine(ctx)
+	if err != nil {
+		return err
+	}
+	return c.db.View(ctx, func(tx kv.Tx) error {
+		body, err := c.blockReader.BodyWithTransactions(ctx, tx, head.Hash(), head.Number.Uint64())
+		if err != nil {
+			return err
+		}
+		if body == nil {
+			return fmt.Errorf("block %d not found", head.Number.Uint64())
+		}
+		for _, included := range body.Transactions {
+			if included.Hash() == txn.Hash() {
+				return nil
+			}
+		}
+		return ErrNotIncluded
+	})
+}
+
+// WriteSenders - writes senders of own mined block before it's inserted: impersonated transactions don't have valid
+// signatures, so senders stage can't recover them
+func (c *Controller) WriteSenders(ctx context.Context, block *types.Block) error {
+	signer := types.MakeSigner(c.chainConfig, block.NumberU64(), block.Time())
+	senders := make([]libcommon.Address, len(block.Transactions()))
+	for i, txn := range block.Transactions() {
+		sender, ok := txn.GetSender()
+		if !ok {
+			var err error
+			if sender, err = signer.Sender(txn); err != nil {
+				return fmt.Errorf("block %d, txn %x: %w", block.NumberU64(), txn.Hash(), err)
+			}
+		}
+		senders[i] = sender
+	}
+	return c.db.Update(ctx, func(tx kv.RwTx) error {
+		return rawdb.WriteSenders(tx, block.Hash(), block.NumberU64(), senders)
+	})
+}
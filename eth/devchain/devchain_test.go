@@ -0,0 +1,282 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package devchain
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/chain/networkname"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/log/v3"
+	types2 "github.com/erigontech/erigon-lib/types"
+	"github.com/erigontech/erigon-lib/wrap"
+
+	"github.com/erigontech/erigon/consensus/clique"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/stages"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+func devMock(t *testing.T) (*mock.MockSentry, *Controller) {
+	config := *params.AllCliqueProtocolChanges
+	config.ChainName = networkname.DevChainName
+	c := NewController(nil, &config, nil, func() {}, log.New())
+	engine := NewEngine(clique.New(&config, params.CliqueSnapshot, memdb.NewTestDB(t), log.New()), c, core.DevnetSignPrivateKey)
+	genspec := &types.Genesis{
+		ExtraData: make([]byte, clique.ExtraVanity+length.Addr+clique.ExtraSeal),
+		Alloc:     types.GenesisAlloc{core.DevnetEtherbase: {Balance: big.NewInt(params.Ether)}},
+		Config:    &config,
+	}
+	copy(genspec.ExtraData[clique.ExtraVanity:], core.DevnetEtherbase[:])
+	m := mock.MockWithGenesisEngine(t, genspec, engine, false, true /* checkStateRoot */)
+	c.db, c.blockReader = m.DB, m.BlockReader
+	return m, c
+}
+
+func sealChain(t *testing.T, chain *core.ChainPack) {
+	for i, block := range chain.Blocks {
+		header := block.Header()
+		if i > 0 {
+			header.ParentHash = chain.Blocks[i-1].Hash()
+		}
+		header.Extra = make([]byte, clique.ExtraVanity+clique.ExtraSeal)
+		header.Difficulty = clique.DiffInTurn
+		sig, err := crypto.Sign(clique.SealHash(header).Bytes(), core.DevnetSignPrivateKey)
+		require.NoError(t, err)
+		copy(header.Extra[len(header.Extra)-clique.ExtraSeal:], sig)
+		chain.Headers[i] = header
+		chain.Blocks[i] = block.WithSeal(header)
+	}
+	chain.TopBlock = chain.Blocks[len(chain.Blocks)-1]
+}
+
+func TestOverridesAndImpersonation(t *testing.T) {
+	m, c := devMock(t)
+	var (
+		rich     = libcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+		contract = libcommon.HexToAddress("0x1000000000000000000000000000000000000002")
+		to       = libcommon.HexToAddress("0x1000000000000000000000000000000000000003")
+		slot     = libcommon.HexToHash("0x01")
+	)
+	c.head = m.Genesis.Hash()
+	o := override{addr: contract, slot: &slot}
+	o.value.SetUint64(42)
+	nonce := uint64(7)
+	c.pending = []override{
+		{addr: rich, balance: uint256.NewInt(params.Ether)},
+		{addr: contract, code: []byte{0x60, 0x00}},
+		o,
+		{addr: contract, nonce: &nonce},
+	}
+
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 2, func(i int, b *core.BlockGen) {
+		b.SetDifficulty(clique.DiffInTurn)
+		if i == 1 {
+			// no tip: block generator and clique don't agree on coinbase
+			baseFee, _ := uint256.FromBig(b.GetHeader().BaseFee)
+			txn, err := impersonate(types.NewTransaction(0, to, uint256.NewInt(1000), params.TxGas, baseFee, nil), m.ChainConfig, rich)
+			require.NoError(t, err)
+			b.AddTx(txn)
+		}
+	})
+	require.NoError(t, err)
+	sealChain(t, chain)
+	ctx := context.Background()
+	require.NoError(t, c.saveOverrides(ctx, 0, m.Genesis.Hash(), c.pending))
+	for _, block := range chain.Blocks {
+		require.NoError(t, c.WriteSenders(ctx, block))
+	}
+	require.NoError(t, m.InsertChain(chain))
+
+	head, err := c.refresh(ctx)
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), head.Number.Uint64())
+	require.Empty(t, c.pending)
+	require.Len(t, c.overrides(m.Genesis.Hash()), 4, "applied overrides are kept for re-execution")
+
+	restarted := NewController(m.DB, m.ChainConfig, m.BlockReader, func() {}, log.New())
+	require.NoError(t, restarted.LoadOverrides(ctx))
+	require.Equal(t, c.overrides(m.Genesis.Hash()), restarted.overrides(m.Genesis.Hash()), "overrides survive restart")
+
+	require.NoError(t, m.DB.View(ctx, func(tx kv.Tx) error {
+		r := state.NewReaderV4(tx.(kv.TemporalGetter))
+		acc, err := r.ReadAccountData(rich)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), acc.Nonce)
+		acc, err = r.ReadAccountData(to)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1000), acc.Balance.Uint64())
+		acc, err = r.ReadAccountData(contract)
+		require.NoError(t, err)
+		require.Equal(t, nonce, acc.Nonce)
+		code, err := r.ReadAccountCode(contract, acc.Incarnation, acc.CodeHash)
+		require.NoError(t, err)
+		require.Equal(t, []byte{0x60, 0x00}, code)
+		v, err := r.ReadAccountStorage(contract, acc.Incarnation, &slot)
+		require.NoError(t, err)
+		require.Equal(t, []byte{42}, v)
+		return nil
+	}))
+}
+
+func TestImpersonatedTxWithoutSenders(t *testing.T) {
+	m, c := devMock(t)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 1, func(i int, b *core.BlockGen) {
+		b.SetDifficulty(clique.DiffInTurn)
+		baseFee, _ := uint256.FromBig(b.GetHeader().BaseFee)
+		txn, err := impersonate(types.NewTransaction(0, core.DevnetEtherbase, uint256.NewInt(1000), params.TxGas, baseFee, nil), m.ChainConfig, core.DevnetEtherbase)
+		require.NoError(t, err)
+		b.AddTx(txn)
+	})
+	require.NoError(t, err)
+	sealChain(t, chain)
+
+	// R=0 is not a valid signature: without senders written by the dev chain the block is rejected by senders stage
+	_, err = types.MakeSigner(m.ChainConfig, 1, chain.TopBlock.Time()).Sender(chain.TopBlock.Transactions()[0])
+	require.Error(t, err)
+	require.ErrorContains(t, m.InsertChain(chain), "Execution stage progress")
+	head, err := c.currentHead(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, m.Genesis.Hash(), head.Hash())
+}
+
+func TestBlockTime(t *testing.T) {
+	c := NewController(nil, params.AllCliqueProtocolChanges, nil, func() {}, log.New())
+	parent := libcommon.Hash{1}
+	now := uint64(time.Now().Unix())
+	require.GreaterOrEqual(t, c.blockTime(parent, 0), now)
+	require.Equal(t, now+1000, c.blockTime(parent, now+1000))
+
+	c.IncreaseTime(3600)
+	require.GreaterOrEqual(t, c.blockTime(parent, 0), now+3600)
+
+	c.head, c.nextTime = parent, now+7200
+	require.Equal(t, now+7200, c.blockTime(parent, 0))
+	require.GreaterOrEqual(t, c.blockTime(libcommon.Hash{2}, 0), now+3600, "next timestamp is only for the block on top of head")
+
+	delay, forced := c.sealDelay(&types.Header{ParentHash: libcommon.Hash{2}, Time: now + 3600 + 60})
+	require.False(t, forced)
+	require.InDelta(t, 60, delay.Seconds(), 2)
+	c.force = true
+	delay, forced = c.sealDelay(&types.Header{ParentHash: parent, Time: now + 7200})
+	require.True(t, forced)
+	require.Zero(t, delay)
+}
+
+func TestTxPool(t *testing.T) {
+	c := NewController(nil, params.AllCliqueProtocolChanges, nil, func() {}, log.New())
+	from := libcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+	txn, err := impersonate(types.NewTransaction(0, from, uint256.NewInt(1), params.TxGas, uint256.NewInt(1), nil), params.AllCliqueProtocolChanges, from)
+	require.NoError(t, err)
+	c.txs = []types.Transaction{txn}
+
+	pool := NewTxPool(emptyPool{}, c)
+	var txs types2.TxsRlp
+	toSkip := mapset.NewSet[[32]byte]()
+	_, count, err := pool.YieldBest(16, &txs, nil, 0, 0, 0, toSkip)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	require.Equal(t, from[:], txs.Senders.At(0))
+	decoded, err := types.DecodeWrappedTransaction(txs.Txs[0])
+	require.NoError(t, err)
+	require.Equal(t, txn.Hash(), decoded.Hash())
+
+	// already yielded
+	_, count, err = pool.YieldBest(16, &txs, nil, 0, 0, 0, toSkip)
+	require.NoError(t, err)
+	require.Zero(t, count)
+}
+
+type emptyPool struct{}
+
+func (emptyPool) YieldBest(_ uint16, txs *types2.TxsRlp, _ kv.Tx, _, _, _ uint64, _ mapset.Set[[32]byte]) (bool, int, error) {
+	txs.Resize(0)
+	return true, 0, nil
+}
+
+func TestRevertRequest(t *testing.T) {
+	m, c := devMock(t)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 3, func(i int, b *core.BlockGen) {
+		b.SetDifficulty(clique.DiffInTurn)
+	})
+	require.NoError(t, err)
+	sealChain(t, chain)
+	require.NoError(t, m.InsertChain(chain.Slice(0, 1)))
+
+	ctx := context.Background()
+	id, err := c.Snapshot(ctx)
+	require.NoError(t, err)
+	require.NoError(t, m.InsertChain(chain.Slice(1, 3)))
+
+	_, _, ok := c.NextUnwind()
+	require.False(t, ok)
+	ok, err = c.Revert(ctx, id+1)
+	require.NoError(t, err)
+	require.False(t, ok, "unknown snapshot")
+
+	// unwind is applied by staged sync before the next cycle
+	hook := stages.NewHook(m.Ctx, m.DB, nil, m.Sync, m.BlockReader, m.ChainConfig, m.Log, nil)
+	hook.SetUnwindRequests(c)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			select {
+			case <-done:
+				return
+			default:
+			}
+			if err := stages.StageLoopIteration(m.Ctx, m.DB, wrap.TxContainer{}, m.Sync, false, false, m.Log, m.BlockReader, hook); err != nil {
+				t.Error(err)
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}()
+	balance := override{addr: core.DevnetEtherbase, balance: uint256.NewInt(1)}
+	require.NoError(t, c.saveOverrides(ctx, 0, libcommon.Hash{1}, []override{balance}))
+	require.NoError(t, c.saveOverrides(ctx, 2, chain.Blocks[1].Hash(), []override{balance}))
+	require.NoError(t, c.LoadOverrides(ctx))
+	ok, err = c.Revert(ctx, id)
+	require.NoError(t, err)
+	require.True(t, ok)
+	head, err := c.currentHead(ctx)
+	require.NoError(t, err)
+	require.Equal(t, chain.Blocks[0].Hash(), head.Hash())
+	require.NotZero(t, c.extraGeneration())
+	require.NoError(t, c.LoadOverrides(ctx))
+	require.Len(t, c.overrides(libcommon.Hash{1}), 1)
+	require.Empty(t, c.overrides(chain.Blocks[1].Hash()), "overrides of reverted blocks are removed")
+
+	ok, err = c.Revert(ctx, id)
+	require.NoError(t, err)
+	require.False(t, ok, "snapshot is removed by revert")
+}
@@ -0,0 +1,131 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package devchain
+
+import (
+	"crypto/ecdsa"
+	"encoding/binary"
+	"errors"
+	"time"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/common/debug"
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/consensus/clique"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+)
+
+// Engine - clique engine of the dev chain, extended by Controller:
+//   - Prepare sets timestamps requested by evm_increaseTime/evm_setNextBlockTimestamp
+//   - Finalize applies state overrides
+//   - Seal signs requested blocks immediately, including empty ones
+//   - VerifyHeader accepts own blocks from the future
+type Engine struct {
+	consensus.Engine
+	c      *Controller
+	period uint64
+	key    *ecdsa.PrivateKey
+	signer libcommon.Address
+}
+
+func NewEngine(inner consensus.Engine, c *Controller, key *ecdsa.PrivateKey) *Engine {
+	return &Engine{Engine: inner, c: c, period: c.period(), key: key, signer: crypto.PubkeyToAddress(key.PublicKey)}
+}
+
+// InnerEngine returns the wrapped clique engine.
+func (e *Engine) InnerEngine() consensus.Engine {
+	return e.Engine
+}
+
+func (e *Engine) VerifyHeader(chain consensus.ChainHeaderReader, header *types.Header, seal bool) error {
+	err := e.Engine.VerifyHeader(chain, header, seal)
+	if errors.Is(err, consensus.ErrFutureBlock) {
+		if author, authorErr := e.Engine.Author(header); authorErr == nil && author == e.signer {
+			return nil
+		}
+	}
+	return err
+}
+
+func (e *Engine) Prepare(chain consensus.ChainHeaderReader, header *types.Header, state *state.IntraBlockState) error {
+	if err := e.Engine.Prepare(chain, header, state); err != nil {
+		return err
+	}
+	parent := chain.GetHeader(header.ParentHash, header.Number.Uint64()-1)
+	if parent == nil {
+		return consensus.ErrUnknownAncestor
+	}
+	header.Time = e.c.blockTime(header.ParentHash, parent.Time+e.period)
+	// blocks mined after revert must not be equal to reverted ones: those are marked as bad
+	if generation := e.c.extraGeneration(); generation != 0 {
+		binary.BigEndian.PutUint64(header.Extra[clique.ExtraVanity-8:clique.ExtraVanity], generation)
+	}
+	return nil
+}
+
+func (e *Engine) Finalize(config *chain.Config, header *types.Header, state *state.IntraBlockState,
+	txs types.Transactions, uncles []*types.Header, r types.Receipts, withdrawals []*types.Withdrawal, requests types.Requests,
+	chain consensus.ChainReader, syscall consensus.SystemCall, logger log.Logger,
+) (types.Transactions, types.Receipts, types.Requests, error) {
+	for _, o := range e.c.overrides(header.ParentHash) {
+		o.apply(state)
+	}
+	return e.Engine.Finalize(config, header, state, txs, uncles, r, withdrawals, requests, chain, syscall, logger)
+}
+
+func (e *Engine) FinalizeAndAssemble(config *chain.Config, header *types.Header, state *state.IntraBlockState,
+	txs types.Transactions, uncles []*types.Header, receipts types.Receipts, withdrawals []*types.Withdrawal, requests types.Requests,
+	chain consensus.ChainReader, syscall consensus.SystemCall, call consensus.Call, logger log.Logger,
+) (*types.Block, types.Transactions, types.Receipts, error) {
+	for _, o := range e.c.overrides(header.ParentHash) {
+		o.apply(state)
+	}
+	return e.Engine.FinalizeAndAssemble(config, header, state, txs, uncles, receipts, withdrawals, requests, chain, syscall, call, logger)
+}
+
+func (e *Engine) Seal(chain consensus.ChainHeaderReader, block *types.Block, results chan<- *types.Block, stop <-chan struct{}) error {
+	header := block.Header()
+	delay, forced := e.c.sealDelay(header)
+	if !forced && e.period == 0 && len(block.Transactions()) == 0 {
+		// clique doesn't seal empty blocks of 0-period chain
+		return e.Engine.Seal(chain, block, results, stop)
+	}
+	sig, err := crypto.Sign(clique.SealHash(header).Bytes(), e.key)
+	if err != nil {
+		return err
+	}
+	copy(header.Extra[len(header.Extra)-clique.ExtraSeal:], sig)
+	go func() {
+		defer debug.LogPanic()
+		select {
+		case <-stop:
+			return
+		case <-time.After(delay):
+		}
+		select {
+		case results <- block.WithSeal(header):
+		default:
+			log.Warn("[devchain] sealing result is not read by miner", "block", header.Number.Uint64())
+		}
+	}()
+	return nil
+}
@@ -0,0 +1,202 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package devchain
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+)
+
+// blockOverrides - state changes applied to the block built on top of parent
+type blockOverrides struct {
+	parentNumber uint64
+	overrides    []override
+}
+
+const (
+	overrideBalance byte = iota + 1
+	overrideNonce
+	overrideCode
+	overrideSlot
+)
+
+var errMalformedOverrides = errors.New("malformed dev chain overrides")
+
+func overridesKey(parentNumber uint64, parent libcommon.Hash) []byte {
+	k := make([]byte, len(kv.DevChainOverrides)+8+length.Hash)
+	n := copy(k, kv.DevChainOverrides)
+	binary.BigEndian.PutUint64(k[n:], parentNumber)
+	copy(k[n+8:], parent[:])
+	return k
+}
+
+// encodeOverrides - kind, address and value of every override, code is prefixed by its length
+func encodeOverrides(overrides []override) []byte {
+	var buf []byte
+	for _, o := range overrides {
+		switch {
+		case o.balance != nil:
+			buf = append(append(buf, overrideBalance), o.addr[:]...)
+			b := o.balance.Bytes32()
+			buf = append(buf, b[:]...)
+		case o.nonce != nil:
+			buf = append(append(buf, overrideNonce), o.addr[:]...)
+			buf = binary.BigEndian.AppendUint64(buf, *o.nonce)
+		case o.code != nil:
+			buf = append(append(buf, overrideCode), o.addr[:]...)
+			buf = binary.BigEndian.AppendUint32(buf, uint32(len(o.code)))
+			buf = append(buf, o.code...)
+		case o.slot != nil:
+			buf = append(append(buf, overrideSlot), o.addr[:]...)
+			v := o.value.Bytes32()
+			buf = append(append(buf, o.slot[:]...), v[:]...)
+		}
+	}
+	return buf
+}
+
+func decodeOverrides(buf []byte) ([]override, error) {
+	var overrides []override
+	for len(buf) > 0 {
+		if len(buf) < 1+length.Addr {
+			return nil, errMalformedOverrides
+		}
+		kind := buf[0]
+		o := override{addr: libcommon.BytesToAddress(buf[1 : 1+length.Addr])}
+		buf = buf[1+length.Addr:]
+		size := 0
+		switch kind {
+		case overrideBalance:
+			size = 32
+			if len(buf) >= size {
+				o.balance = new(uint256.Int).SetBytes(buf[:size])
+			}
+		case overrideNonce:
+			size = 8
+			if len(buf) >= size {
+				nonce := binary.BigEndian.Uint64(buf[:size])
+				o.nonce = &nonce
+			}
+		case overrideCode:
+			if len(buf) < 4 {
+				return nil, errMalformedOverrides
+			}
+			size = 4 + int(binary.BigEndian.Uint32(buf))
+			if len(buf) >= size {
+				o.code = libcommon.Copy(buf[4:size])
+			}
+		case overrideSlot:
+			size = length.Hash + 32
+			if len(buf) >= size {
+				slot := libcommon.BytesToHash(buf[:length.Hash])
+				o.slot = &slot
+				o.value.SetBytes(buf[length.Hash:size])
+			}
+		default:
+			return nil, fmt.Errorf("%w: unknown kind %d", errMalformedOverrides, kind)
+		}
+		if len(buf) < size {
+			return nil, errMalformedOverrides
+		}
+		overrides = append(overrides, o)
+		buf = buf[size:]
+	}
+	return overrides, nil
+}
+
+// LoadOverrides - reads state changes requested before restart, they are needed to re-execute blocks after unwind
+func (c *Controller) LoadOverrides(ctx context.Context) error {
+	applied := map[libcommon.Hash]blockOverrides{}
+	if err := c.db.View(ctx, func(tx kv.Tx) error {
+		it, err := tx.Prefix(kv.DatabaseInfo, kv.DevChainOverrides)
+		if err != nil {
+			return err
+		}
+		defer it.Close()
+		for it.HasNext() {
+			k, v, err := it.Next()
+			if err != nil {
+				return err
+			}
+			k = k[len(kv.DevChainOverrides):]
+			if len(k) != 8+length.Hash {
+				return fmt.Errorf("%w: key %x", errMalformedOverrides, k)
+			}
+			overrides, err := decodeOverrides(v)
+			if err != nil {
+				return err
+			}
+			applied[libcommon.BytesToHash(k[8:])] = blockOverrides{parentNumber: binary.BigEndian.Uint64(k[:8]), overrides: overrides}
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.applied = applied
+	return nil
+}
+
+func (c *Controller) saveOverrides(ctx context.Context, parentNumber uint64, parent libcommon.Hash, overrides []override) error {
+	return c.db.Update(ctx, func(tx kv.RwTx) error {
+		return tx.Put(kv.DatabaseInfo, overridesKey(parentNumber, parent), encodeOverrides(overrides))
+	})
+}
+
+// deleteOverrides - removes state changes of reverted blocks: the ones on top of block `from` and later
+func (c *Controller) deleteOverrides(ctx context.Context, from uint64) error {
+	c.lock.Lock()
+	for hash, o := range c.applied {
+		if o.parentNumber >= from {
+			delete(c.applied, hash)
+		}
+	}
+	c.lock.Unlock()
+	return c.db.Update(ctx, func(tx kv.RwTx) error {
+		it, err := tx.Prefix(kv.DatabaseInfo, kv.DevChainOverrides)
+		if err != nil {
+			return err
+		}
+		var keys [][]byte
+		for it.HasNext() {
+			k, _, err := it.Next()
+			if err != nil {
+				it.Close()
+				return err
+			}
+			if n := k[len(kv.DevChainOverrides):]; len(n) >= 8 && binary.BigEndian.Uint64(n) >= from {
+				keys = append(keys, libcommon.Copy(k))
+			}
+		}
+		it.Close()
+		for _, k := range keys {
+			if err := tx.Delete(kv.DatabaseInfo, k); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,68 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package devchain
+
+import (
+	"bytes"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	"github.com/erigontech/erigon-lib/kv"
+	types2 "github.com/erigontech/erigon-lib/types"
+
+	"github.com/erigontech/erigon/eth/stagedsync"
+)
+
+// TxPool - txpool for mining, which yields impersonated transactions of the next block before the ones of wrapped pool.
+// Impersonated transactions never get to the txpool: it doesn't accept transactions without valid signature.
+type TxPool struct {
+	stagedsync.TxPoolForMining
+	c *Controller
+}
+
+func NewTxPool(inner stagedsync.TxPoolForMining, c *Controller) *TxPool {
+	return &TxPool{TxPoolForMining: inner, c: c}
+}
+
+func (p *TxPool) YieldBest(n uint16, txs *types2.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte]) (bool, int, error) {
+	var own types2.TxsRlp
+	for _, txn := range p.c.pendingTxs() {
+		if len(own.Txs) >= int(n) {
+			break
+		}
+		if toSkip.Contains(txn.Hash()) {
+			continue
+		}
+		var buf bytes.Buffer
+		if err := txn.MarshalBinary(&buf); err != nil {
+			return false, 0, err
+		}
+		sender, _ := txn.GetSender()
+		own.Resize(uint(len(own.Txs) + 1))
+		own.Txs[len(own.Txs)-1] = buf.Bytes()
+		copy(own.Senders.At(len(own.Txs)-1), sender[:])
+		toSkip.Add(txn.Hash())
+	}
+	onTime, count, err := p.TxPoolForMining.YieldBest(n-uint16(len(own.Txs)), txs, tx, onTopOf, availableGas, availableBlobGas, toSkip)
+	if err != nil || len(own.Txs) == 0 {
+		return onTime, count, err
+	}
+	txs.Txs = append(own.Txs, txs.Txs...)
+	txs.Senders = append(own.Senders, txs.Senders...)
+	txs.IsLocal = append(own.IsLocal, txs.IsLocal...)
+	return onTime, count + len(own.Txs), nil
+}
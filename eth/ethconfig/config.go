@@ -265,6 +265,11 @@ type Config struct {
 	SilkwormRpcJsonCompatibility bool
 
 	DisableTxPoolGossip bool
+
+	// Shadow commitment: second commitment (not in consensus) computed alongside of main one. Empty if disabled.
+	ShadowCommitment string
+	// Amount of existing state keys moved to shadow commitment per commitment computation
+	ShadowCommitmentConversion uint64
 }
 
 type Sync struct {
@@ -34,7 +34,14 @@ func E3EfFiles(ctx context.Context, chainDB kv.RwDB, agg *state.Aggregator, fail
 		return err
 	}
 	g := &errgroup.Group{}
-	for _, idx := range []kv.InvertedIdx{kv.AccountsHistoryIdx, kv.StorageHistoryIdx, kv.CodeHistoryIdx, kv.CommitmentHistoryIdx, kv.LogTopicIdx, kv.LogAddrIdx, kv.TracesFromIdx, kv.TracesToIdx} {
+	idxs := []kv.InvertedIdx{kv.AccountsHistoryIdx, kv.StorageHistoryIdx, kv.CodeHistoryIdx, kv.CommitmentHistoryIdx, kv.LogTopicIdx, kv.LogAddrIdx, kv.TracesFromIdx, kv.TracesToIdx}
+	for _, cfg := range kv.UserDomains() {
+		idxs = append(idxs, cfg.HistoryIdx)
+	}
+	for _, cfg := range kv.UserInvertedIdxs() {
+		idxs = append(idxs, cfg.Name)
+	}
+	for _, idx := range idxs {
 		idx := idx
 		g.Go(func() error {
 			tx, err := db.BeginTemporalRo(ctx)
@@ -122,7 +122,7 @@ func SpawnCustomTrace(s *StageState, txc wrap.TxContainer, cfg CustomTraceCfg, c
 	////TODO: new tracer may get tracer from pool, maybe add it to TxTask field
 	///// maybe need startTxNum/endTxNum
 	//if err = exec3.CustomTraceMapReduce(startBlock, endBlock, exec3.TraceConsumer{
-	//	NewTracer: func() exec3.GenericTracer { return nil },
+	//	NewTracer: func(*state.TxTask) exec3.GenericTracer { return nil },
 	//	Reduce: func(txTask *state.TxTask, tx kv.Tx) error {
 	//		if txTask.Error != nil {
 	//			return err
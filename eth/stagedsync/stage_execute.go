@@ -176,14 +176,14 @@ func unwindExec3(u *UnwindState, s *StageState, txc wrap.TxContainer, ctx contex
 		return err
 	}
 	t := time.Now()
-	var changeset *[kv.DomainLen][]libstate.DomainEntryDiff
+	var changeset [][]libstate.DomainEntryDiff
 	for currentBlock := u.CurrentBlockNumber; currentBlock > u.UnwindPoint; currentBlock-- {
 		currentHash, err := rawdb.ReadCanonicalHash(txc.Tx, currentBlock)
 		if err != nil {
 			return err
 		}
 		var ok bool
-		var currentKeys [kv.DomainLen][]libstate.DomainEntryDiff
+		var currentKeys [][]libstate.DomainEntryDiff
 		currentKeys, ok, err = domains.GetDiffset(txc.Tx, currentHash, currentBlock)
 		if !ok {
 			return fmt.Errorf("domains.GetDiffset(%d, %s): not found", currentBlock, currentHash)
@@ -192,7 +192,7 @@ func unwindExec3(u *UnwindState, s *StageState, txc wrap.TxContainer, ctx contex
 			return err
 		}
 		if changeset == nil {
-			changeset = &currentKeys
+			changeset = currentKeys
 		} else {
 			for i := range currentKeys {
 				changeset[i] = libstate.MergeDiffSets(changeset[i], currentKeys[i])
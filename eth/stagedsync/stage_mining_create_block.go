@@ -24,6 +24,7 @@ import (
 	"time"
 
 	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/holiman/uint256"
 
 	"github.com/erigontech/erigon-lib/log/v3"
 
@@ -50,6 +51,7 @@ type MiningBlock struct {
 	Withdrawals      []*types.Withdrawal
 	PreparedTxs      types.TransactionsStream
 	Requests         types.Requests
+	CoinbaseDelta    *uint256.Int // see types.BlockWithReceipts
 }
 
 type MiningState struct {
@@ -109,6 +109,7 @@ func SpawnMiningExecStage(s *StageState, txc wrap.TxContainer, cfg MiningExecCfg
 	)
 	stateReader = state.NewReaderV4(txc.Doms)
 	ibs := state.New(stateReader)
+	coinbaseBalance := ibs.GetBalance(cfg.miningState.MiningConfig.Etherbase).Clone()
 	// Clique consensus needs forced author in the evm context
 	if cfg.chainConfig.Consensus == chain.CliqueConsensus {
 		execCfg.author = &cfg.miningState.MiningConfig.Etherbase
@@ -193,6 +194,12 @@ func SpawnMiningExecStage(s *StageState, txc wrap.TxContainer, cfg MiningExecCfg
 	if current.Receipts == nil {
 		current.Receipts = types.Receipts{}
 	}
+	// before withdrawals and rewards: they aren't paid by transactions
+	if balance := ibs.GetBalance(cfg.miningState.MiningConfig.Etherbase); balance.Gt(coinbaseBalance) {
+		current.CoinbaseDelta = new(uint256.Int).Sub(balance, coinbaseBalance)
+	} else {
+		current.CoinbaseDelta = uint256.NewInt(0)
+	}
 	chainReader := ChainReaderImpl{config: &cfg.chainConfig, tx: txc.Tx, blockReader: cfg.blockReader, logger: logger}
 
 	if err := cfg.engine.Prepare(chainReader, current.Header, ibs); err != nil {
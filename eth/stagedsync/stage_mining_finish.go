@@ -69,7 +69,7 @@ func SpawnMiningFinishStage(s *StageState, tx kv.RwTx, cfg MiningFinishCfg, quit
 	//}
 
 	block := types.NewBlock(current.Header, current.Txs, current.Uncles, current.Receipts, current.Withdrawals, current.Requests)
-	blockWithReceipts := &types.BlockWithReceipts{Block: block, Receipts: current.Receipts}
+	blockWithReceipts := &types.BlockWithReceipts{Block: block, Receipts: current.Receipts, CoinbaseDelta: current.CoinbaseDelta}
 	*current = MiningBlock{} // hack to clean global data
 
 	//sealHash := engine.SealHash(block.Header())
@@ -318,6 +318,9 @@ func DownloadAndIndexSnapshotsIfNeed(s *StageState, ctx context.Context, tx kv.R
 	if err := FillDBFromSnapshots(s.LogPrefix(), ctx, tx, cfg.dirs, cfg.blockReader, cfg.agg, logger); err != nil {
 		return err
 	}
+	if err := snapshotsync.UpdateGrowPending(tx, cfg.agg); err != nil {
+		return err
+	}
 	if casted, ok := tx.(*temporal.Tx); ok {
 		casted.ForceReopenAggCtx() // otherwise next stages will not see just-indexed-files
 	}
@@ -504,7 +507,18 @@ func SnapshotsPrune(s *PruneState, cfg SnapshotsCfg, ctx context.Context, tx kv.
 	}
 
 	freezingCfg := cfg.blockReader.FreezingCfg()
-	if cfg.blockRetire.HasNewFrozenFiles() || cfg.agg.HasNewFrozenFiles() {
+	newFrozenFiles := cfg.blockRetire.HasNewFrozenFiles() || cfg.agg.HasNewFrozenFiles()
+	if newFrozenFiles || s.CurrentSyncCycle.IsInitialCycle {
+		deleted, err := pruneHistorySnapshots(ctx, cfg, tx)
+		if err != nil {
+			return err
+		}
+		if len(deleted) > 0 {
+			logger.Info(fmt.Sprintf("[%s] Pruned history snapshots", s.LogPrefix()), "files", len(deleted))
+			newFrozenFiles = true
+		}
+	}
+	if newFrozenFiles {
 		ac := cfg.agg.BeginFilesRo()
 		defer ac.Close()
 		aggFiles := ac.Files()
@@ -600,6 +614,24 @@ func SnapshotsPrune(s *PruneState, cfg SnapshotsCfg, ctx context.Context, tx kv.
 	return nil
 }
 
+// pruneHistorySnapshots - removes state history files which are older than retention of prune mode
+func pruneHistorySnapshots(ctx context.Context, cfg SnapshotsCfg, tx kv.Tx) ([]string, error) {
+	executionProgress, err := stages.GetStageProgress(tx, stages.Execution)
+	if err != nil {
+		return nil, err
+	}
+	deleted, err := snapshotsync.PruneHistoryFiles(tx, cfg.agg, cfg.prune, executionProgress)
+	if err != nil {
+		return nil, err
+	}
+	if len(deleted) > 0 && cfg.snapshotDownloader != nil && !reflect.ValueOf(cfg.snapshotDownloader).IsNil() {
+		if _, err := cfg.snapshotDownloader.Delete(ctx, &protodownloader.DeleteRequest{Paths: deleted}); err != nil {
+			return deleted, err
+		}
+	}
+	return deleted, nil
+}
+
 func pruneBlockSnapshots(ctx context.Context, cfg SnapshotsCfg, logger log.Logger) (bool, error) {
 	tx, err := cfg.db.BeginRo(ctx)
 	if err != nil {
@@ -607,53 +639,19 @@ func pruneBlockSnapshots(ctx context.Context, cfg SnapshotsCfg, logger log.Logge
 	}
 	defer tx.Rollback()
 	// Prune snapshots if necessary (remove .segs or idx files appropriatelly)
-	headNumber := cfg.blockReader.FrozenBlocks()
 	executionProgress, err := stages.GetStageProgress(tx, stages.Execution)
 	if err != nil {
 		return false, err
 	}
-	// If we are behind the execution stage, we should not prune snapshots
-	if headNumber > executionProgress || !cfg.prune.Blocks.Enabled() {
-		return false, nil
-	}
-
-	// Keep at least 2 block snapshots as we do not want FrozenBlocks to be 0
-	pruneTo := cfg.prune.Blocks.PruneTo(headNumber)
-
-	if pruneTo > executionProgress {
-		return false, nil
-	}
-
-	snapshotFileNames := cfg.blockReader.FrozenFiles()
-	filesDeleted := false
-	// Prune blocks snapshots if necessary
-	for _, file := range snapshotFileNames {
-		if !cfg.prune.Blocks.Enabled() || headNumber == 0 || !strings.Contains(file, "transactions") {
-			continue
-		}
-
-		// take the snapshot file name and parse it to get the "from"
-		info, _, ok := snaptype.ParseFileName(cfg.dirs.Snap, file)
-		if !ok {
-			continue
-		}
-		if info.To >= pruneTo {
-			continue
-		}
-		if info.To-info.From != snaptype.Erigon2MergeLimit {
-			continue
-		}
+	deleted, err := snapshotsync.PruneBlockFiles(cfg.blockReader, cfg.dirs.Snap, cfg.prune, executionProgress, func(file string) error {
 		if cfg.snapshotDownloader != nil {
 			if _, err := cfg.snapshotDownloader.Delete(ctx, &protodownloader.DeleteRequest{Paths: []string{file}}); err != nil {
-				return filesDeleted, err
+				return err
 			}
 		}
-		if err := cfg.blockReader.Snapshots().Delete(file); err != nil {
-			return filesDeleted, err
-		}
-		filesDeleted = true
-	}
-	return filesDeleted, nil
+		return nil
+	})
+	return len(deleted) > 0, err
 }
 
 type uploadState struct {
@@ -0,0 +1,232 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package prune
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+)
+
+// Change - how amount of kept data of one kind changes when datadir moves to another prune mode
+type Change uint8
+
+const (
+	Unchanged Change = iota
+	Shrink           // less data is kept: data out of new retention must be removed
+	Grow             // more data is kept: removed data must be downloaded again
+)
+
+func (c Change) String() string {
+	switch c {
+	case Unchanged:
+		return "unchanged"
+	case Shrink:
+		return "shrink"
+	case Grow:
+		return "grow"
+	default:
+		return "unknown change"
+	}
+}
+
+func compareRetention(from, to BlockAmount) Change {
+	switch f, t := from.toValue(), to.toValue(); {
+	case t < f:
+		return Shrink
+	case t > f:
+		return Grow
+	default:
+		return Unchanged
+	}
+}
+
+// Migration - what has to be done with data of datadir to move it from one prune mode to another
+type Migration struct {
+	From, To Mode
+	Blocks   Change
+	History  Change // data which follows Mode.History: TxLookup, user-defined indices
+	Parts    map[HistoryPart]Change
+}
+
+var ErrAllowlistWidened = errors.New("history of addresses out of --prune.history.allowlist was never written: datadir must be re-synced")
+
+// NewMigration - plans migration of datadir from prune mode `from` to `to`.
+// History which was not produced because of allowlist can't be restored - such migration is refused.
+func NewMigration(from, to Mode) (*Migration, error) {
+	if !allowlistCovers(from.HistoryAllowlist, to.HistoryAllowlist) {
+		return nil, ErrAllowlistWidened
+	}
+	m := &Migration{
+		From:    from,
+		To:      to,
+		Blocks:  compareRetention(from.Blocks, to.Blocks),
+		History: compareRetention(from.History, to.History),
+		Parts:   make(map[HistoryPart]Change, len(HistoryParts)),
+	}
+	for _, p := range HistoryParts {
+		m.Parts[p] = compareRetention(from.Retention(p), to.Retention(p))
+	}
+	return m, nil
+}
+
+// allowlistCovers - if everything `to` keeps was kept by `from`. Empty allowlist means: all addresses
+func allowlistCovers(from, to []common.Address) bool {
+	if len(from) == 0 {
+		return true
+	}
+	if len(to) == 0 {
+		return false
+	}
+	kept := make(map[common.Address]struct{}, len(from))
+	for _, addr := range from {
+		kept[addr] = struct{}{}
+	}
+	for _, addr := range to {
+		if _, ok := kept[addr]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// Empty - if prune modes are equal and nothing has to be done
+func (m *Migration) Empty() bool { return reflect.DeepEqual(m.From, m.To) }
+
+// NeedsDownload - if some of data which new mode keeps was already removed
+func (m *Migration) NeedsDownload() bool { return m.has(Grow) }
+
+// NeedsPrune - if some of data which old mode kept must be removed
+func (m *Migration) NeedsPrune() bool { return m.has(Shrink) }
+
+func (m *Migration) has(c Change) bool {
+	if m.Blocks == c || m.History == c {
+		return true
+	}
+	for _, pc := range m.Parts {
+		if pc == c {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Migration) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "blocks=%s history=%s", m.Blocks, m.History)
+	for _, p := range HistoryParts {
+		fmt.Fprintf(&sb, " %s=%s", p, m.Parts[p])
+	}
+	return sb.String()
+}
+
+var ErrMigrationPending = errors.New("prune mode migration was interrupted")
+
+// BeginMigration - marks datadir as being migrated to `m.To`: Erigon refuses to start until Migrate is done.
+// Interrupted migration can be continued only to the same mode.
+func BeginMigration(tx kv.RwTx, m *Migration) error {
+	current, err := Get(tx)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(current, m.From) {
+		return fmt.Errorf("prune mode changed during migration: expected %q, found %q", m.From.String(), current.String())
+	}
+	pending, err := MigrationPending(tx)
+	if err != nil {
+		return err
+	}
+	if pending != "" && pending != migrationTarget(m.To) {
+		return fmt.Errorf("%w: finish it first with %q", ErrMigrationPending, pending)
+	}
+	return tx.Put(kv.DatabaseInfo, kv.PruneMigrationPending, []byte(migrationTarget(m.To)))
+}
+
+// migrationTarget - flags of prune mode `to`, never empty: empty value means that there is no pending migration
+func migrationTarget(to Mode) string {
+	if s := to.String(); s != "" {
+		return s
+	}
+	return "--prune.mode=archive"
+}
+
+// Migrate - stores new prune mode and finishes migration started by BeginMigration. Caller is responsible for files
+// and tables which migration affects: removing of them must happen before Migrate, in same `tx` or be idempotent.
+// If state or code history grows - history of blocks before retention of `m.From` at `head` is not available
+// until it's downloaded, see GrowPendingFrom.
+func Migrate(tx kv.RwTx, m *Migration, head uint64) error {
+	current, err := Get(tx)
+	if err != nil {
+		return err
+	}
+	if !reflect.DeepEqual(current, m.From) {
+		return fmt.Errorf("prune mode changed during migration: expected %q, found %q", m.From.String(), current.String())
+	}
+	if m.Parts[StateHistory] == Grow || m.Parts[CodeHistory] == Grow {
+		missingTo := max(m.From.Retention(StateHistory).PruneTo(head), m.From.Retention(CodeHistory).PruneTo(head))
+		from, _, err := GrowPendingFrom(tx)
+		if err != nil {
+			return err
+		}
+		if missingTo > from {
+			if err := SetGrowPendingFrom(tx, missingTo); err != nil {
+				return err
+			}
+		}
+	}
+	if err := Override(tx, m.To); err != nil {
+		return err
+	}
+	return tx.Delete(kv.DatabaseInfo, kv.PruneMigrationPending)
+}
+
+// MigrationPending - flags of target mode of interrupted migration, empty if there is no such migration
+func MigrationPending(tx kv.Getter) (string, error) {
+	v, err := tx.GetOne(kv.DatabaseInfo, kv.PruneMigrationPending)
+	if err != nil {
+		return "", err
+	}
+	return string(v), nil
+}
+
+// GrowPendingFrom - state and code history of blocks before returned one was removed by previous prune mode
+// and is not downloaded yet. false if all history kept by current prune mode is available.
+func GrowPendingFrom(tx kv.Getter) (uint64, bool, error) {
+	v, err := tx.GetOne(kv.DatabaseInfo, kv.PruneGrowPendingFrom)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(v) != 8 {
+		return 0, false, nil
+	}
+	return binary.BigEndian.Uint64(v), true, nil
+}
+
+func SetGrowPendingFrom(tx kv.Putter, block uint64) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, block)
+	return tx.Put(kv.DatabaseInfo, kv.PruneGrowPendingFrom, v)
+}
+
+func ClearGrowPending(tx kv.RwTx) error {
+	return tx.Delete(kv.DatabaseInfo, kv.PruneGrowPendingFrom)
+}
@@ -0,0 +1,153 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package prune
+
+import (
+	"testing"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon/common/math"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigration(t *testing.T) {
+	archive := Mode{Initialised: true, History: Distance(math.MaxUint64), Blocks: Distance(math.MaxUint64)}
+	full := Mode{Initialised: true, History: Distance(0), Blocks: Distance(math.MaxUint64)}
+	minimal := Mode{Initialised: true, History: Distance(0), Blocks: Distance(2048)}
+
+	m, err := NewMigration(archive, full)
+	require.NoError(t, err)
+	require.Equal(t, Unchanged, m.Blocks)
+	require.Equal(t, Shrink, m.History)
+	require.Equal(t, Shrink, m.Parts[LogsHistory])
+	require.True(t, m.NeedsPrune())
+	require.False(t, m.NeedsDownload())
+
+	m, err = NewMigration(minimal, full)
+	require.NoError(t, err)
+	require.Equal(t, Grow, m.Blocks)
+	require.Equal(t, Unchanged, m.History)
+	require.True(t, m.NeedsDownload())
+	require.False(t, m.NeedsPrune())
+
+	// part of history may keep more while the rest keeps less
+	fullWithLogs := full
+	fullWithLogs.Logs = Distance(math.MaxUint64)
+	m, err = NewMigration(archive, fullWithLogs)
+	require.NoError(t, err)
+	require.Equal(t, Unchanged, m.Parts[LogsHistory])
+	require.Equal(t, Shrink, m.Parts[StateHistory])
+
+	m, err = NewMigration(full, full)
+	require.NoError(t, err)
+	require.True(t, m.Empty())
+}
+
+func TestMigrationAllowlist(t *testing.T) {
+	a, b := libcommon.HexToAddress("0x01"), libcommon.HexToAddress("0x02")
+	both := Mode{Initialised: true, History: Distance(math.MaxUint64), Blocks: Distance(math.MaxUint64), HistoryAllowlist: []libcommon.Address{a, b}}
+	one := both
+	one.HistoryAllowlist = []libcommon.Address{a}
+	all := both
+	all.HistoryAllowlist = nil
+
+	_, err := NewMigration(both, one)
+	require.NoError(t, err)
+	_, err = NewMigration(all, one)
+	require.NoError(t, err)
+	_, err = NewMigration(one, both)
+	require.ErrorIs(t, err, ErrAllowlistWidened)
+	_, err = NewMigration(one, all)
+	require.ErrorIs(t, err, ErrAllowlistWidened)
+}
+
+func TestMigrate(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	archive := Mode{Initialised: true, History: Distance(math.MaxUint64), Blocks: Distance(math.MaxUint64), Experiments: Experiments{}}
+	full := Mode{Initialised: true, History: Distance(0), Blocks: Distance(math.MaxUint64), Experiments: Experiments{}}
+	full.SetRetention(CallTracesHistory, Distance(100_000))
+
+	_, err := EnsureNotChanged(tx, archive)
+	require.NoError(t, err)
+
+	m, err := NewMigration(full, archive)
+	require.NoError(t, err)
+	require.Error(t, Migrate(tx, m, 0)) // datadir is not in `full` mode
+
+	m, err = NewMigration(archive, full)
+	require.NoError(t, err)
+	require.NoError(t, Migrate(tx, m, 0))
+
+	pm, err := EnsureNotChanged(tx, full)
+	require.NoError(t, err)
+	require.Equal(t, full, pm)
+}
+
+func TestMigrateInterrupted(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	archive := Mode{Initialised: true, History: Distance(math.MaxUint64), Blocks: Distance(math.MaxUint64), Experiments: Experiments{}}
+	full := Mode{Initialised: true, History: Distance(0), Blocks: Distance(math.MaxUint64), Experiments: Experiments{}}
+	minimal := Mode{Initialised: true, History: Distance(0), Blocks: Distance(2048), Experiments: Experiments{}}
+
+	_, err := EnsureNotChanged(tx, archive)
+	require.NoError(t, err)
+
+	m, err := NewMigration(archive, full)
+	require.NoError(t, err)
+	require.NoError(t, BeginMigration(tx, m))
+
+	// mode is not changed yet, but Erigon must not start until migration is done
+	_, err = EnsureNotChanged(tx, archive)
+	require.ErrorIs(t, err, ErrMigrationPending)
+
+	// interrupted migration can be continued only to the same mode
+	other, err := NewMigration(archive, minimal)
+	require.NoError(t, err)
+	require.ErrorIs(t, BeginMigration(tx, other), ErrMigrationPending)
+	require.NoError(t, BeginMigration(tx, m))
+
+	require.NoError(t, Migrate(tx, m, 1_000))
+	pm, err := EnsureNotChanged(tx, full)
+	require.NoError(t, err)
+	require.Equal(t, full, pm)
+}
+
+func TestMigrateGrowPending(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	archive := Mode{Initialised: true, History: Distance(math.MaxUint64), Blocks: Distance(math.MaxUint64), Experiments: Experiments{}}
+	full := Mode{Initialised: true, History: Distance(100), Blocks: Distance(math.MaxUint64), Experiments: Experiments{}}
+
+	_, err := EnsureNotChanged(tx, full)
+	require.NoError(t, err)
+
+	m, err := NewMigration(full, archive)
+	require.NoError(t, err)
+	require.NoError(t, BeginMigration(tx, m))
+	require.NoError(t, Migrate(tx, m, 1_000))
+
+	// history before retention of old mode is not downloaded yet
+	from, ok, err := GrowPendingFrom(tx)
+	require.NoError(t, err)
+	require.True(t, ok)
+	require.Equal(t, uint64(900), from)
+
+	require.NoError(t, ClearGrowPending(tx))
+	_, ok, err = GrowPendingFrom(tx)
+	require.NoError(t, err)
+	require.False(t, ok)
+}
@@ -17,13 +17,17 @@
 package prune
 
 import (
+	"bytes"
 	"encoding/binary"
 	"errors"
 	"fmt"
 	"math"
 	"reflect"
+	"strconv"
 	"strings"
 
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
 	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon/params"
 )
@@ -75,6 +79,23 @@ func Get(db kv.Getter) (Mode, error) {
 		prune.Blocks = blockAmount
 	}
 
+	for _, part := range HistoryParts {
+		if *prune.part(part), err = get(db, part.dbKey()); err != nil {
+			return prune, err
+		}
+	}
+
+	allowlist, err := db.GetOne(kv.DatabaseInfo, kv.PruneHistoryAllowlist)
+	if err != nil {
+		return prune, err
+	}
+	if len(allowlist)%length.Addr != 0 {
+		return prune, fmt.Errorf("unexpected length of history allowlist: %d", len(allowlist))
+	}
+	for i := 0; i < len(allowlist); i += length.Addr {
+		prune.HistoryAllowlist = append(prune.HistoryAllowlist, common.BytesToAddress(allowlist[i:i+length.Addr]))
+	}
+
 	return prune, nil
 }
 
@@ -83,6 +104,145 @@ type Mode struct {
 	History     BlockAmount
 	Blocks      BlockAmount
 	Experiments Experiments
+
+	// Retention of parts of history, nil - same as History. See Retention
+	Logs         BlockAmount // receipts, logs and their indices: LogAddrIdx, LogTopicIdx
+	CallTraces   BlockAmount // call-trace indices: TracesFromIdx, TracesToIdx
+	StateHistory BlockAmount // accounts and storage history
+	CodeHistory  BlockAmount
+
+	// HistoryAllowlist - if not empty: execution writes accounts, storage and code history only of these addresses.
+	// History which was downloaded or produced before is not affected.
+	HistoryAllowlist []common.Address
+}
+
+// HistoryPart - part of history which retention can be set independently of Mode.History
+type HistoryPart uint8
+
+const (
+	LogsHistory HistoryPart = iota
+	CallTracesHistory
+	StateHistory
+	CodeHistory
+)
+
+var HistoryParts = []HistoryPart{LogsHistory, CallTracesHistory, StateHistory, CodeHistory}
+
+func (p HistoryPart) String() string {
+	switch p {
+	case LogsHistory:
+		return "logs"
+	case CallTracesHistory:
+		return "traces"
+	case StateHistory:
+		return "state"
+	case CodeHistory:
+		return "code"
+	default:
+		return "unknown history part"
+	}
+}
+
+func (p HistoryPart) dbKey() []byte {
+	switch p {
+	case LogsHistory:
+		return kv.PruneLogs
+	case CallTracesHistory:
+		return kv.PruneCallTraces
+	case StateHistory:
+		return kv.PruneStateHistory
+	case CodeHistory:
+		return kv.PruneCodeHistory
+	default:
+		panic(fmt.Sprintf("unknown history part %d", p))
+	}
+}
+
+func (m *Mode) part(p HistoryPart) *BlockAmount {
+	switch p {
+	case LogsHistory:
+		return &m.Logs
+	case CallTracesHistory:
+		return &m.CallTraces
+	case StateHistory:
+		return &m.StateHistory
+	case CodeHistory:
+		return &m.CodeHistory
+	default:
+		panic(fmt.Sprintf("unknown history part %d", p))
+	}
+}
+
+// Retention - how much of part of history to keep
+func (m Mode) Retention(p HistoryPart) BlockAmount {
+	if amount := *m.part(p); amount != nil {
+		return amount
+	}
+	return m.History
+}
+
+// SetRetention - overrides History for part of history
+func (m *Mode) SetRetention(p HistoryPart, amount BlockAmount) { *m.part(p) = amount }
+
+// HistoryEnabled - if any part of history is pruned
+func (m Mode) HistoryEnabled() bool {
+	for _, p := range HistoryParts {
+		if m.Retention(p).Enabled() {
+			return true
+		}
+	}
+	return m.History.Enabled()
+}
+
+// HistoryPruneTo - first block, starting from which all parts of history are kept
+func (m Mode) HistoryPruneTo(stageHead uint64) uint64 {
+	pruneTo := m.History.PruneTo(stageHead)
+	for _, p := range HistoryParts {
+		pruneTo = min(pruneTo, m.Retention(p).PruneTo(stageHead))
+	}
+	return pruneTo
+}
+
+// Validate - checks that kept parts of history can be served. Logs and call traces are produced by re-execution
+// of blocks: they can't be kept longer than state and code history, and with HistoryAllowlist they can't be kept at all
+func (m Mode) Validate() error {
+	state, code := m.Retention(StateHistory), m.Retention(CodeHistory)
+	for _, p := range []HistoryPart{LogsHistory, CallTracesHistory} {
+		retention := m.Retention(p)
+		if len(m.HistoryAllowlist) > 0 && retention.toValue() > 0 {
+			return fmt.Errorf("%s history can't be kept with history allowlist: blocks can't be re-executed", p)
+		}
+		if retention.toValue() > state.toValue() || retention.toValue() > code.toValue() {
+			return fmt.Errorf("%s history is kept longer than state and code history (%s > min(%s, %s)): blocks can't be re-executed",
+				p, amountString(retention), amountString(state), amountString(code))
+		}
+	}
+	return nil
+}
+
+func amountString(amount BlockAmount) string {
+	if !amount.Enabled() {
+		return "all"
+	}
+	return fmt.Sprintf("%d blocks", amount.toValue())
+}
+
+// HistoryKeyFilter - accepts keys of domains (accounts, storage, code) which history must be kept. nil if all
+func (m Mode) HistoryKeyFilter() func(key1 []byte) bool {
+	if len(m.HistoryAllowlist) == 0 {
+		return nil
+	}
+	allowed := make(map[common.Address]struct{}, len(m.HistoryAllowlist))
+	for _, addr := range m.HistoryAllowlist {
+		allowed[addr] = struct{}{}
+	}
+	return func(key1 []byte) bool {
+		if len(key1) < length.Addr {
+			return false
+		}
+		_, ok := allowed[common.Address(key1[:length.Addr])]
+		return ok
+	}
 }
 
 type BlockAmount interface {
@@ -135,10 +295,41 @@ func (m Mode) String() string {
 			long += fmt.Sprintf(" --prune.b.%s=%d", m.Blocks.dbType(), m.Blocks.toValue())
 		}
 	}
+	for _, p := range HistoryParts {
+		if amount := *m.part(p); amount != nil {
+			long += fmt.Sprintf(" --prune.distance.%s=%s", p, distanceString(amount))
+		}
+	}
+	if len(m.HistoryAllowlist) > 0 {
+		addrs := make([]string, len(m.HistoryAllowlist))
+		for i, addr := range m.HistoryAllowlist {
+			addrs[i] = addr.Hex()
+		}
+		long += " --prune.history.allowlist=" + strings.Join(addrs, ",")
+	}
 
 	return strings.TrimLeft(short+long, " ")
 }
 
+// ParseDistance - parses distance of prune flags: amount of blocks or "all" to keep everything
+func ParseDistance(s string) (Distance, error) {
+	if s == "all" {
+		return Distance(math.MaxUint64), nil
+	}
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("expected amount of blocks or \"all\": %w", err)
+	}
+	return Distance(v), nil
+}
+
+func distanceString(amount BlockAmount) string {
+	if !amount.Enabled() {
+		return "all"
+	}
+	return strconv.FormatUint(amount.toValue(), 10)
+}
+
 func Override(db kv.RwTx, sm Mode) error {
 	var (
 		err error
@@ -154,12 +345,40 @@ func Override(db kv.RwTx, sm Mode) error {
 		return err
 	}
 
+	for _, p := range HistoryParts {
+		if amount := *sm.part(p); amount != nil {
+			err = set(db, p.dbKey(), amount)
+		} else {
+			err = del(db, p.dbKey())
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(sm.HistoryAllowlist) > 0 {
+		err = db.Put(kv.DatabaseInfo, kv.PruneHistoryAllowlist, encodeAllowlist(sm.HistoryAllowlist))
+	} else {
+		err = db.Delete(kv.DatabaseInfo, kv.PruneHistoryAllowlist)
+	}
+	if err != nil {
+		return err
+	}
+
 	return nil
 }
 
 // EnsureNotChanged - prohibit change some configs after node creation. prohibit from human mistakes
 func EnsureNotChanged(tx kv.GetPut, pruneMode Mode) (Mode, error) {
-	err := setIfNotExist(tx, pruneMode)
+	pending, err := MigrationPending(tx)
+	if err != nil {
+		return pruneMode, err
+	}
+	if pending != "" {
+		return pruneMode, fmt.Errorf("%w, re-run: erigon prune-mode migrate %s", ErrMigrationPending, pending)
+	}
+
+	err = setIfNotExist(tx, pruneMode)
 	if err != nil {
 		return pruneMode, err
 	}
@@ -187,10 +406,28 @@ func setIfNotExist(db kv.GetPut, pm Mode) error {
 		pm = DefaultMode
 	}
 
+	// parts of history are stored only at creation of db: EnsureNotChanged must see them added later
+	created, err := db.GetOne(kv.DatabaseInfo, kv.PruneHistory)
+	if err != nil {
+		return err
+	}
+
 	pruneDBData := map[string]BlockAmount{
 		string(kv.PruneHistory): pm.History,
 		string(kv.PruneBlocks):  pm.Blocks,
 	}
+	if len(created) == 0 {
+		for _, p := range HistoryParts {
+			if amount := *pm.part(p); amount != nil {
+				pruneDBData[string(p.dbKey())] = amount
+			}
+		}
+		if len(pm.HistoryAllowlist) > 0 {
+			if err = db.Put(kv.DatabaseInfo, kv.PruneHistoryAllowlist, encodeAllowlist(pm.HistoryAllowlist)); err != nil {
+				return err
+			}
+		}
+	}
 
 	for key, value := range pruneDBData {
 		err = setOnEmpty(db, []byte(key), value)
@@ -253,8 +490,23 @@ func set(db kv.Putter, key []byte, blockAmount BlockAmount) error {
 	return nil
 }
 
+func del(db kv.RwTx, key []byte) error {
+	if err := db.Delete(kv.DatabaseInfo, key); err != nil {
+		return err
+	}
+	return db.Delete(kv.DatabaseInfo, keyType(key))
+}
+
+func encodeAllowlist(addrs []common.Address) []byte {
+	var buf bytes.Buffer
+	for _, addr := range addrs {
+		buf.Write(addr[:])
+	}
+	return buf.Bytes()
+}
+
 func keyType(name []byte) []byte {
-	return append(name, []byte("Type")...)
+	return append(common.Copy(name), []byte("Type")...)
 }
 
 func setOnEmpty(db kv.GetPut, key []byte, blockAmount BlockAmount) error {
@@ -20,23 +20,103 @@ import (
 	"strconv"
 	"testing"
 
+	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/kv/memdb"
 	"github.com/erigontech/erigon/common/math"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestSetStorageModeIfNotExist(t *testing.T) {
 	_, tx := memdb.NewTestTx(t)
 	prune, err := Get(tx)
 	assert.NoError(t, err)
-	assert.Equal(t, Mode{true, Distance(math.MaxUint64), Distance(math.MaxUint64), Experiments{}}, prune)
+	assert.Equal(t, Mode{Initialised: true, History: Distance(math.MaxUint64), Blocks: Distance(math.MaxUint64), Experiments: Experiments{}}, prune)
 
-	err = setIfNotExist(tx, Mode{true, Distance(1), Distance(2), Experiments{}})
+	err = setIfNotExist(tx, Mode{Initialised: true, History: Distance(1), Blocks: Distance(2), Experiments: Experiments{}})
 	assert.NoError(t, err)
 
 	prune, err = Get(tx)
 	assert.NoError(t, err)
-	assert.Equal(t, Mode{true, Distance(1), Distance(2), Experiments{}}, prune)
+	assert.Equal(t, Mode{Initialised: true, History: Distance(1), Blocks: Distance(2), Experiments: Experiments{}}, prune)
+}
+
+func TestHistoryParts(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	addr := libcommon.HexToAddress("0x00000000219ab540356cbb839cbe05303d7705fa")
+	mode := Mode{
+		Initialised:      true,
+		History:          Distance(90_000),
+		Blocks:           Distance(math.MaxUint64),
+		Logs:             Distance(math.MaxUint64),
+		CallTraces:       Distance(10),
+		HistoryAllowlist: []libcommon.Address{addr},
+	}
+	pm, err := EnsureNotChanged(tx, mode)
+	require.NoError(t, err)
+	require.Equal(t, mode, pm)
+	require.Equal(t, "--prune.h.older=90000 --prune.distance.logs=all --prune.distance.traces=10 --prune.history.allowlist="+addr.Hex(), pm.String())
+
+	require.False(t, pm.Retention(LogsHistory).Enabled())
+	require.Equal(t, Distance(10), pm.Retention(CallTracesHistory))
+	require.Equal(t, Distance(90_000), pm.Retention(StateHistory))
+	require.Equal(t, uint64(0), pm.HistoryPruneTo(1_000_000))
+
+	keep := pm.HistoryKeyFilter()
+	require.True(t, keep(append(addr.Bytes(), 1, 2, 3)))
+	require.False(t, keep(libcommon.Address{}.Bytes()))
+
+	// parts of history can't be changed silently
+	changed := mode
+	changed.Logs = nil
+	_, err = EnsureNotChanged(tx, changed)
+	require.Error(t, err)
+
+	require.NoError(t, Override(tx, changed))
+	pm, err = Get(tx)
+	require.NoError(t, err)
+	require.Nil(t, pm.Logs)
+	require.Equal(t, Distance(90_000), pm.Retention(LogsHistory))
+}
+
+func TestHistoryPartsAddedLater(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	_, err := EnsureNotChanged(tx, Mode{Initialised: true, History: Distance(90_000), Blocks: Distance(math.MaxUint64)})
+	require.NoError(t, err)
+
+	_, err = EnsureNotChanged(tx, Mode{Initialised: true, History: Distance(90_000), Blocks: Distance(math.MaxUint64), StateHistory: Distance(10)})
+	require.Error(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	archive := Mode{Initialised: true, History: Distance(math.MaxUint64), Blocks: Distance(math.MaxUint64)}
+	require.NoError(t, archive.Validate())
+
+	// logs are kept forever, but state needed to re-execute blocks is pruned
+	m := archive
+	m.StateHistory = Distance(90_000)
+	require.ErrorContains(t, m.Validate(), "logs history is kept longer")
+	m.Logs, m.CallTraces = Distance(90_000), Distance(1_000)
+	require.NoError(t, m.Validate())
+	m.CodeHistory = Distance(10)
+	require.ErrorContains(t, m.Validate(), "logs history is kept longer")
+
+	m = archive
+	m.HistoryAllowlist = []libcommon.Address{libcommon.HexToAddress("0x01")}
+	require.ErrorContains(t, m.Validate(), "allowlist")
+	m.Logs, m.CallTraces = Distance(0), Distance(0)
+	require.NoError(t, m.Validate())
+}
+
+func TestParseDistance(t *testing.T) {
+	d, err := ParseDistance("all")
+	require.NoError(t, err)
+	require.False(t, d.Enabled())
+	d, err = ParseDistance("90000")
+	require.NoError(t, err)
+	require.Equal(t, Distance(90_000), d)
+	_, err = ParseDistance("forever")
+	require.Error(t, err)
 }
 
 var distanceTests = []struct {
@@ -58,6 +58,11 @@ func StartHTTPEndpoint(urlEndpoint string, cfg *HttpEndpointConfig, handler http
 	if listener, err = net.Listen(socketUrl.Scheme, socketUrl.Host+socketUrl.EscapedPath()); err != nil {
 		return nil, nil, err
 	}
+	return ServeHTTPEndpoint(listener, cfg, handler), listener.Addr(), nil
+}
+
+// ServeHTTPEndpoint serves the HTTP RPC endpoint on already opened listener.
+func ServeHTTPEndpoint(listener net.Listener, cfg *HttpEndpointConfig, handler http.Handler) *http.Server {
 	// make sure timeout values are meaningful
 	CheckTimeouts(&cfg.Timeouts)
 	// create the http2 server for handling h2c
@@ -87,7 +92,7 @@ func StartHTTPEndpoint(urlEndpoint string, cfg *HttpEndpointConfig, handler http
 			}
 		}
 	}()
-	return httpSrv, listener.Addr(), err
+	return httpSrv
 }
 
 func isIgnoredHttpServerError(serveErr error) bool {
@@ -37,4 +37,9 @@ type MiningConfig struct {
 	GasLimit   uint64            // Target gas limit for mined blocks.
 	GasPrice   *big.Int          // Minimum gas price for mining a transaction
 	Recommit   time.Duration     // The time interval for miner to re-create mining work.
+
+	BuilderRelays      []string      `toml:",omitempty"` // MEV-boost relays the block builder submits blocks to
+	BuilderSecretKey   []byte        `toml:",omitempty"` // BLS secret key of block builder, signs bids
+	BuilderInterval    time.Duration // The time interval between re-builds of block for relays within a slot
+	BuilderGenesisTime uint64        // Beacon chain genesis time, needed for networks which builder doesn't know
 }
@@ -312,6 +312,36 @@ func TestClientSubscribe(t *testing.T) {
 	}
 }
 
+func TestClientSubscribeServerFailure(t *testing.T) {
+	logger := log.New()
+	server := newTestServer(logger)
+	defer server.Stop()
+	client := DialInProc(server, logger)
+	defer client.Close()
+
+	nc := make(chan int)
+	sub, err := client.Subscribe(context.Background(), "nftest", nc, "failingSubscription", 7)
+	if err != nil {
+		t.Fatal("can't subscribe:", err)
+	}
+	if val := <-nc; val != 7 {
+		t.Fatalf("value mismatch: got %d, want %d", val, 7)
+	}
+	select {
+	case err := <-sub.Err():
+		if err == nil || err.Error() != "subscription failed" {
+			t.Fatalf("wrong error: %v", err)
+		}
+	case <-time.After(1 * time.Second):
+		t.Fatalf("subscription not failed within 1s")
+	}
+	// server doesn't know subscription anymore
+	var ok bool
+	if err := client.Call(&ok, "nftest_unsubscribe", sub.subid); err == nil {
+		t.Fatal("unsubscribe of failed subscription succeeded")
+	}
+}
+
 // In this test, the connection drops while Subscribe is waiting for a response.
 func TestClientSubscribeClose(t *testing.T) {
 	logger := log.New()
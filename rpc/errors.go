@@ -56,6 +56,21 @@ func (e *parseError) ErrorCode() int { return -32700 }
 
 func (e *parseError) Error() string { return e.message }
 
+// PreStreamError marks error of streaming method which happened before anything was written to the stream
+// (e.g. invalid params): client gets regular error response instead of `"result":null` followed by error.
+func PreStreamError(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &preStreamError{err: err}
+}
+
+type preStreamError struct{ err error }
+
+func (e *preStreamError) Error() string { return e.err.Error() }
+
+func (e *preStreamError) Unwrap() error { return e.err }
+
 // received message isn't a valid request
 type invalidRequestError struct{ message string }
 
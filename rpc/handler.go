@@ -23,6 +23,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"reflect"
 	"strconv"
 	"strings"
@@ -364,9 +365,16 @@ func (h *handler) handleSubscriptionResult(msg *jsonrpcMessage) {
 		h.logger.Trace("Dropping invalid subscription message")
 		return
 	}
-	if h.clientSubs[result.ID] != nil {
-		h.clientSubs[result.ID].deliver(result.Result)
+	sub := h.clientSubs[result.ID]
+	if sub == nil {
+		return
+	}
+	if result.Error != nil {
+		delete(h.clientSubs, result.ID)
+		sub.quitWithError(false, result.Error)
+		return
 	}
+	sub.deliver(result.Result)
 }
 
 // handleResponse processes method call responses.
@@ -538,17 +546,24 @@ func (h *handler) runMethod(ctx context.Context, msg *jsonrpcMessage, callb *cal
 		return msg.response(result)
 	}
 
+	start := len(stream.Buffer())
 	stream.WriteObjectStart()
 	stream.WriteObjectField("jsonrpc")
 	stream.WriteString("2.0")
 	stream.WriteMore()
 	if msg.ID != nil {
 		stream.WriteObjectField("id")
-		stream.Write(msg.ID)
+		stream.WriteRaw(string(msg.ID)) // not flushed: response can still be replaced by PreStreamError
 		stream.WriteMore()
 	}
 	stream.WriteObjectField("result")
+	resultStart := len(stream.Buffer())
 	_, err := callb.call(ctx, msg.Method, args, stream)
+	var preStreamErr *preStreamError
+	if errors.As(err, &preStreamErr) && len(stream.Buffer()) == resultStart {
+		stream.SetBuffer(stream.Buffer()[:start])
+		return msg.errorResponse(preStreamErr.err)
+	}
 	if err != nil {
 		writeNilIfNotPresent(stream)
 		stream.WriteMore()
@@ -604,6 +619,17 @@ func writeNilIfNotPresent(stream *jsoniter.Stream) {
 	stream.WriteNil()
 }
 
+// removeServerSubscription - subscription is ended by server.
+func (h *handler) removeServerSubscription(id ID) {
+	h.subLock.Lock()
+	defer h.subLock.Unlock()
+
+	if s := h.serverSubs[id]; s != nil {
+		close(s.err)
+		delete(h.serverSubs, id)
+	}
+}
+
 // unsubscribe is the callback function for all *_unsubscribe calls.
 func (h *handler) unsubscribe(ctx context.Context, id ID) (bool, error) {
 	h.subLock.Lock()
@@ -19,6 +19,7 @@ package rpc
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"reflect"
 	"testing"
@@ -112,3 +113,32 @@ func TestHandlerDoesNotDoubleWriteNull(t *testing.T) {
 	}
 
 }
+
+func TestHandlerPreStreamError(t *testing.T) {
+	msg := jsonrpcMessage{Version: "2.0", ID: []byte{49}, Method: "test_test", Params: []byte("[1]")}
+	dummyFunc := func(id int, stream *jsoniter.Stream) error {
+		return PreStreamError(&InvalidParamsError{"invalid id"})
+	}
+	var arg1 int
+	cb := &callback{
+		fn:         reflect.ValueOf(dummyFunc),
+		rcvr:       reflect.Value{},
+		argTypes:   []reflect.Type{reflect.TypeOf(arg1)},
+		errPos:     0,
+		streamable: true,
+	}
+	args, err := parsePositionalArguments(msg.Params, cb.argTypes)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	stream := jsoniter.NewStream(jsoniter.ConfigDefault, &buf, 4096)
+	h := handler{}
+	answer := h.runMethod(context.Background(), &msg, cb, args, stream)
+	assert.NoError(t, stream.Flush())
+	assert.Empty(t, buf.String(), "nothing is written to the stream")
+	res, err := json.Marshal(answer)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"jsonrpc":"2.0","id":1,"error":{"code":-32602,"message":"invalid id"}}`, string(res))
+}
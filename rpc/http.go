@@ -318,10 +318,17 @@ func CheckJwtSecret(w http.ResponseWriter, r *http.Request, jwtSecret []byte) bo
 	if auth := r.Header.Get("Authorization"); strings.HasPrefix(auth, "Bearer ") {
 		tokenStr = strings.TrimPrefix(auth, "Bearer ")
 	}
+	if err := ValidateJwtToken(tokenStr, jwtSecret); err != nil {
+		http.Error(w, err.Error(), http.StatusForbidden)
+		return false
+	}
+	return true
+}
 
+// ValidateJwtToken - checks token of authenticated (engine) endpoint, regardless of transport it came with.
+func ValidateJwtToken(tokenStr string, jwtSecret []byte) error {
 	if len(tokenStr) == 0 {
-		http.Error(w, "missing token", http.StatusForbidden)
-		return false
+		return errors.New("missing token")
 	}
 
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
@@ -337,20 +344,17 @@ func CheckJwtSecret(w http.ResponseWriter, r *http.Request, jwtSecret []byte) bo
 
 	switch {
 	case err != nil:
-		http.Error(w, err.Error(), http.StatusForbidden)
+		return err
 	case !token.Valid:
-		http.Error(w, "invalid token", http.StatusForbidden)
+		return errors.New("invalid token")
 	case !claims.VerifyExpiresAt(time.Now(), false): // optional
-		http.Error(w, "token is expired", http.StatusForbidden)
+		return errors.New("token is expired")
 	case claims.IssuedAt == nil:
-		http.Error(w, "missing issued-at", http.StatusForbidden)
+		return errors.New("missing issued-at")
 	case time.Since(claims.IssuedAt.Time) > jwtTokenExpiry:
-		http.Error(w, "stale token", http.StatusForbidden)
+		return errors.New("stale token")
 	case time.Until(claims.IssuedAt.Time) > jwtTokenExpiry:
-		http.Error(w, "future token", http.StatusForbidden)
-	default:
-		return true
+		return errors.New("future token")
 	}
-
-	return false
+	return nil
 }
@@ -20,7 +20,12 @@
 package rpc
 
 import (
+	"context"
+	"encoding/json"
 	"net"
+	"time"
+
+	"github.com/golang-jwt/jwt/v4"
 
 	"github.com/erigontech/erigon-lib/log/v3"
 	"github.com/erigontech/erigon/p2p/netutil"
@@ -28,6 +33,63 @@ import (
 
 // ServeListener accepts connections on l, serving JSON-RPC on them.
 func (s *Server) ServeListener(l net.Listener) error {
+	return serveListener(l, func(conn net.Conn) { s.ServeCodec(NewCodec(conn), 0) })
+}
+
+// ServeAuthenticatedListener is like ServeListener, but every connection must start with
+// `{"jwt":"<token>"}` - the same token which HTTP and WebSocket clients send in `Authorization: Bearer` header.
+// Connections with missing or invalid token get an error message and are closed.
+func (s *Server) ServeAuthenticatedListener(l net.Listener, jwtSecret []byte) error {
+	return serveListener(l, func(conn net.Conn) { s.serveAuthenticated(conn, jwtSecret) })
+}
+
+// jwtHandshake - first message of authenticated IPC connection
+type jwtHandshake struct {
+	JWT string `json:"jwt"`
+}
+
+func (s *Server) serveAuthenticated(conn net.Conn, jwtSecret []byte) {
+	enc := json.NewEncoder(conn)
+	dec := json.NewDecoder(conn)
+	dec.UseNumber()
+
+	_ = conn.SetReadDeadline(time.Now().Add(jwtTokenExpiry))
+	var handshake jwtHandshake
+	err := dec.Decode(&handshake)
+	if err == nil {
+		err = ValidateJwtToken(handshake.JWT, jwtSecret)
+	}
+	if err != nil {
+		_ = conn.SetWriteDeadline(time.Now().Add(jwtTokenExpiry))
+		_ = enc.Encode(errorMessage(&invalidRequestError{"authentication failed: " + err.Error()}))
+		_ = conn.Close()
+		return
+	}
+	_ = conn.SetReadDeadline(time.Time{})
+	s.ServeCodec(NewFuncCodec(conn, enc.Encode, dec.Decode), 0)
+}
+
+// DialIPCWithJWT connects to unix socket served by ServeAuthenticatedListener.
+// New token is issued on every (re)connect.
+func DialIPCWithJWT(ctx context.Context, path string, jwtSecret []byte, logger log.Logger) (*Client, error) {
+	return newClient(ctx, func(ctx context.Context) (ServerCodec, error) {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"iat": time.Now().Unix()}).SignedString(jwtSecret)
+		if err != nil {
+			return nil, err
+		}
+		conn, err := (&net.Dialer{}).DialContext(ctx, "unix", path)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.NewEncoder(conn).Encode(jwtHandshake{JWT: token}); err != nil {
+			_ = conn.Close()
+			return nil, err
+		}
+		return NewCodec(conn), nil
+	}, logger)
+}
+
+func serveListener(l net.Listener, serve func(conn net.Conn)) error {
 	for {
 		conn, err := l.Accept()
 		if netutil.IsTemporaryError(err) {
@@ -37,6 +99,6 @@ func (s *Server) ServeListener(l net.Listener) error {
 			return err
 		}
 		log.Trace("Accepted RPC connection", "conn", conn.RemoteAddr())
-		go s.ServeCodec(NewCodec(conn), 0)
+		go serve(conn)
 	}
 }
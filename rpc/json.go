@@ -47,6 +47,7 @@ var null = json.RawMessage("null")
 type subscriptionResult struct {
 	ID     string          `json:"subscription"`
 	Result json.RawMessage `json:"result,omitempty"`
+	Error  *jsonError      `json:"error,omitempty"` // subscription is ended by server
 }
 
 // A value of this type can a JSON-RPC request, notification, successful response or
@@ -99,6 +99,7 @@ type Notifier struct {
 	mu           sync.Mutex
 	sub          *Subscription
 	buffer       []json.RawMessage
+	failure      error // sent after buffer on activation
 	callReturned bool
 	activated    bool
 }
@@ -143,6 +144,24 @@ func (n *Notifier) Notify(id ID, data interface{}) error {
 	return nil
 }
 
+// Fail ends the subscription with an error: client gets the last notification with `error` instead of `result`.
+// Nothing can be sent to the subscription after this call.
+func (n *Notifier) Fail(id ID, failure error) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if n.sub == nil {
+		panic("can't Fail before subscription is created")
+	} else if n.sub.ID != id {
+		panic("Fail with wrong ID")
+	}
+	if n.activated {
+		return n.sendFailure(n.sub, failure)
+	}
+	n.failure = failure
+	return nil
+}
+
 // Closed returns a channel that is closed when the RPC connection is closed.
 // Deprecated: use subscription error channel
 func (n *Notifier) Closed() <-chan interface{} {
@@ -171,9 +190,22 @@ func (n *Notifier) activate() error {
 		}
 	}
 	n.activated = true
+	if n.failure != nil {
+		return n.sendFailure(n.sub, n.failure)
+	}
 	return nil
 }
 
+func (n *Notifier) sendFailure(sub *Subscription, failure error) error {
+	n.h.removeServerSubscription(sub.ID)
+	params, _ := json.Marshal(&subscriptionResult{ID: string(sub.ID), Error: errorMessage(failure).Error})
+	return n.h.conn.WriteJSON(context.Background(), &jsonrpcMessage{
+		Version: vsn,
+		Method:  n.namespace + notificationMethodSuffix,
+		Params:  params,
+	})
+}
+
 func (n *Notifier) send(sub *Subscription, data json.RawMessage) error {
 	params, _ := json.Marshal(&subscriptionResult{ID: string(sub.ID), Result: data})
 	ctx := context.Background()
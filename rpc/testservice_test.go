@@ -189,6 +189,22 @@ func (s *notificationTestService) SomeSubscription(ctx context.Context, n, val i
 	return subscription, nil
 }
 
+// FailingSubscription sends `val` and ends the subscription with an error.
+func (s *notificationTestService) FailingSubscription(ctx context.Context, val int) (*Subscription, error) {
+	notifier, supported := NotifierFromContext(ctx)
+	if !supported {
+		return nil, ErrNotificationsUnsupported
+	}
+	subscription := notifier.CreateSubscription()
+	go func() {
+		if err := notifier.Notify(subscription.ID, val); err != nil {
+			return
+		}
+		notifier.Fail(subscription.ID, errors.New("subscription failed"))
+	}()
+	return subscription, nil
+}
+
 // HangSubscription blocks on s.unblockHangSubscription before sending anything.
 func (s *notificationTestService) HangSubscription(ctx context.Context, val int) (*Subscription, error) {
 	notifier, supported := NotifierFromContext(ctx)
@@ -229,6 +229,10 @@ type BlockNumberOrHash struct {
 	BlockNumber      *BlockNumber `json:"blockNumber,omitempty"`
 	BlockHash        *common.Hash `json:"blockHash,omitempty"`
 	RequireCanonical bool         `json:"requireCanonical,omitempty"`
+
+	// TransactionIndex - if set: state right before execution of this transaction of the block.
+	// Equal to amount of transactions in block - state after all transactions, but before block rewards.
+	TransactionIndex *hexutil.Uint64 `json:"transactionIndex,omitempty"`
 }
 
 func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
@@ -242,9 +246,13 @@ func (bnh *BlockNumberOrHash) UnmarshalJSON(data []byte) error {
 		if e.BlockNumber == nil && e.BlockHash == nil {
 			return errors.New("at least one of BlockNumber or BlockHash is needed if a dictionary is provided")
 		}
+		if e.TransactionIndex != nil && e.BlockNumber != nil && *e.BlockNumber == PendingBlockNumber {
+			return errors.New("TransactionIndex can't be used with pending block")
+		}
 		bnh.BlockNumber = e.BlockNumber
 		bnh.BlockHash = e.BlockHash
 		bnh.RequireCanonical = e.RequireCanonical
+		bnh.TransactionIndex = e.TransactionIndex
 		return nil
 	}
 	// Try simple number first
@@ -319,14 +327,28 @@ func (bnh *BlockNumberOrHash) Hash() (common.Hash, bool) {
 	return common.Hash{}, false
 }
 
+// TxIndex - index of transaction before which state is requested. false if state at end of block is requested
+func (bnh *BlockNumberOrHash) TxIndex() (uint64, bool) {
+	if bnh.TransactionIndex != nil {
+		return uint64(*bnh.TransactionIndex), true
+	}
+	return 0, false
+}
+
 func (bnh *BlockNumberOrHash) String() string {
-	if bnh.BlockNumber != nil {
-		return bnh.BlockNumber.String()
+	var s string
+	switch {
+	case bnh.BlockNumber != nil:
+		s = bnh.BlockNumber.String()
+	case bnh.BlockHash != nil:
+		s = bnh.BlockHash.String()
+	default:
+		return "nil"
 	}
-	if bnh.BlockHash != nil {
-		return bnh.BlockHash.String()
+	if bnh.TransactionIndex != nil {
+		s += fmt.Sprintf("[tx %d]", uint64(*bnh.TransactionIndex))
 	}
-	return "nil"
+	return s
 }
 
 func BlockNumberOrHashWithNumber(blockNr BlockNumber) BlockNumberOrHash {
@@ -24,6 +24,7 @@ import (
 	"testing"
 
 	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
 
 	"github.com/erigontech/erigon/common/math"
 )
@@ -70,6 +71,11 @@ func TestBlockNumberJSONUnmarshal(t *testing.T) {
 	}
 }
 
+func withTxIndex(bnh BlockNumberOrHash, txIndex uint64) BlockNumberOrHash {
+	bnh.TransactionIndex = (*hexutil.Uint64)(&txIndex)
+	return bnh
+}
+
 func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -104,6 +110,9 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		25: {`{"blockNumber":"0x1", "blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000"}`, true, BlockNumberOrHash{}},
 		26: {`{}`, true, BlockNumberOrHash{}},
 		27: {`{"jsonrpc":"2.0","result":{"code":418,"message":"blabla"},"id":""}]`, true, BlockNumberOrHash{}},
+		28: {`{"blockNumber":"pending","transactionIndex":"0x1"}`, true, BlockNumberOrHash{}},
+		29: {`{"blockNumber":"0x1","transactionIndex":"0x0"}`, false, withTxIndex(BlockNumberOrHashWithNumber(1), 0)},
+		30: {`{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000000","transactionIndex":"0x10"}`, false, withTxIndex(BlockNumberOrHashWithHash(libcommon.Hash{}, false), 16)},
 	}
 
 	for i, test := range tests {
@@ -121,7 +130,10 @@ func TestBlockNumberOrHash_UnmarshalJSON(t *testing.T) {
 		expectedHash, expectedHashOk := test.expected.Hash()
 		num, numOk := bnh.Number()
 		expectedNum, expectedNumOk := test.expected.Number()
+		txIndex, txIndexOk := bnh.TxIndex()
+		expectedTxIndex, expectedTxIndexOk := test.expected.TxIndex()
 		if bnh.RequireCanonical != test.expected.RequireCanonical ||
+			txIndex != expectedTxIndex || txIndexOk != expectedTxIndexOk ||
 			hash != expectedHash || hashOk != expectedHashOk ||
 			num != expectedNum || numOk != expectedNumOk {
 			t.Errorf("Test %d got unexpected value, want %v, got %v", i, test.expected, bnh)
@@ -17,6 +17,7 @@
 package app
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -64,12 +65,45 @@ TODO:
 	}),
 }
 
+var onlineBackupCommand = cli.Command{
+	Name:  "backup",
+	Usage: "Consistent backup of chaindata and snapshots of running Erigon",
+	Subcommands: []*cli.Command{
+		{
+			Name: "create",
+			Usage: `Point-in-time backup of chaindata and snapshots: erigon backup create --datadir=<your_datadir> --to.datadir=<backup_datadir>
+If --to.datadir has complete backup - it's updated incrementally: only new snapshot files are linked and only modified tables are copied (in full).
+Snapshot files are hard-linked: keep backup on same filesystem to not copy them.`,
+			Action: doOnlineBackup,
+			Flags: joinFlags([]cli.Flag{
+				&utils.DataDirFlag,
+				&ToDatadirFlag,
+				&WarmupThreadsFlag,
+			}),
+		},
+		{
+			Name:   "restore",
+			Usage:  "Create datadir from backup: erigon backup restore --from.datadir=<backup_datadir> --datadir=<new_datadir>",
+			Action: doRestoreBackup,
+			Flags: joinFlags([]cli.Flag{
+				&utils.DataDirFlag,
+				&FromDatadirFlag,
+			}),
+		},
+	},
+}
+
 var (
 	ToDatadirFlag = flags.DirectoryFlag{
 		Name:     "to.datadir",
 		Usage:    "Target datadir",
 		Required: true,
 	}
+	FromDatadirFlag = flags.DirectoryFlag{
+		Name:     "from.datadir",
+		Usage:    "Source datadir",
+		Required: true,
+	}
 	BackupLabelsFlag = cli.StringFlag{
 		Name:  "labels",
 		Usage: "Name of component to backup. Example: chaindata,txpool,downloader",
@@ -167,3 +201,45 @@ func doBackup(cliCtx *cli.Context) error {
 
 	return nil
 }
+
+func doOnlineBackup(cliCtx *cli.Context) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	dirs := datadir.New(cliCtx.String(utils.DataDirFlag.Name))
+	toDirs := datadir.New(cliCtx.String(ToDatadirFlag.Name))
+	if dirs.DataDir == toDirs.DataDir {
+		return errors.New("--to.datadir must differ from --datadir")
+	}
+	readAheadThreads := backup.ReadAheadThreads
+	if cliCtx.IsSet(WarmupThreadsFlag.Name) {
+		readAheadThreads = int(cliCtx.Uint64(WarmupThreadsFlag.Name))
+	}
+
+	m, err := backup.Online(cliCtx.Context, dirs, toDirs, readAheadThreads, logger)
+	if err != nil {
+		return err
+	}
+	logger.Info("[backup] done", "view_id", m.ViewID, "files", len(m.Files), "to", toDirs.DataDir)
+	return nil
+}
+
+func doRestoreBackup(cliCtx *cli.Context) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	dirs, l, err := datadir.New(cliCtx.String(utils.DataDirFlag.Name)).MustFlock()
+	if err != nil {
+		return err
+	}
+	defer l.Unlock()
+	fromDirs := datadir.New(cliCtx.String(FromDatadirFlag.Name))
+
+	if err := backup.Restore(cliCtx.Context, fromDirs, dirs, logger); err != nil {
+		return err
+	}
+	logger.Info("[restore] done", "datadir", dirs.DataDir)
+	return nil
+}
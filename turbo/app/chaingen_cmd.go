@@ -0,0 +1,135 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/utils"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth"
+	"github.com/erigontech/erigon/turbo/chaingen"
+	"github.com/erigontech/erigon/turbo/debug"
+	turboNode "github.com/erigontech/erigon/turbo/node"
+)
+
+var generateChainCommand = cli.Command{
+	Action: MigrateFlags(generateChain),
+	Name:   "generate-chain",
+	Usage:  "Generate deterministic synthetic chain from scenario file into empty datadir: erigon generate-chain --datadir=<datadir> --scenario=<file>",
+	Flags: []cli.Flag{
+		&utils.DataDirFlag,
+		&ChainGenScenarioFlag,
+		&ChainGenNoRetireFlag,
+	},
+	Description: `
+Scenario is a JSON file, e.g.:
+  {"seed": 1, "blocks": 2000000, "accounts": 1000, "transfers": 50, "tokenTransfers": 50, "deployEvery": 10000,
+   "selfDestructEvery": 100, "blobTxEvery": 10, "blobsPerTx": 3, "withdrawalsPerBlock": 16, "reorgEvery": 10000, "reorgDepth": 3}
+See chaingen.Scenario for all fields. Chain is PoS from genesis, its network id is "chainId" of scenario.
+Blocks are executed by usual stages, after that blocks are retired to snapshots and state files are built,
+as "erigon snapshots retire" does.`,
+}
+
+var (
+	ChainGenScenarioFlag = cli.StringFlag{
+		Name:     "scenario",
+		Usage:    "Scenario file of generated chain",
+		Required: true,
+	}
+	ChainGenNoRetireFlag = cli.BoolFlag{
+		Name:  "no-retire",
+		Usage: "Keep generated blocks and state in db: don't build snapshots and state files",
+	}
+)
+
+func generateChain(cliCtx *cli.Context) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	scenario, err := chaingen.ReadScenario(cliCtx.String(ChainGenScenarioFlag.Name))
+	if err != nil {
+		return err
+	}
+	generator, err := chaingen.New(scenario)
+	if err != nil {
+		return err
+	}
+
+	nodeCfg := turboNode.NewNodConfigUrfave(cliCtx, logger)
+	nodeCfg.P2P.NoDiscovery = true
+	nodeCfg.P2P.MaxPeers = 0
+	ethCfg := turboNode.NewEthConfigUrfave(cliCtx, nodeCfg, logger)
+	ethCfg.Genesis = generator.Genesis()
+	ethCfg.NetworkID = scenario.ChainID
+	ethCfg.Snapshot.NoDownloader = true
+	ethCfg.InternalCL = false
+
+	stack := makeConfigNode(cliCtx.Context, nodeCfg, logger)
+	ethereum, err := eth.New(cliCtx.Context, stack, ethCfg, logger)
+	if err != nil {
+		stack.Close()
+		return err
+	}
+	if err = ethereum.Init(stack, ethCfg, ethCfg.Genesis.Config); err == nil {
+		err = generateInto(cliCtx.Context, ethereum, generator, logger)
+	}
+	// node isn't started, so Ethereum.Stop would wait for stage loop forever: db is closed here for retire to open it
+	ethereum.ChainDB().Close()
+	stack.Close()
+	if err != nil || cliCtx.Bool(ChainGenNoRetireFlag.Name) {
+		return err
+	}
+	return retire(cliCtx.Context, datadir.New(cliCtx.String(utils.DataDirFlag.Name)), 0, 0, 0, logger)
+}
+
+func generateInto(ctx context.Context, ethereum *eth.Ethereum, generator *chaingen.Generator, logger log.Logger) error {
+	// state of genesis is written by execution of block 0
+	if err := stageLoopIteration(ethereum, logger); err != nil {
+		return err
+	}
+	db := ethereum.ChainDB()
+	blockReader, _ := ethereum.BlockIO()
+	var genesis *types.Block
+	if err := db.View(ctx, func(tx kv.Tx) error {
+		var err error
+		if head := rawdb.ReadCurrentHeader(tx); head != nil && head.Number.Uint64() > 0 {
+			return fmt.Errorf("datadir is not empty, head block is %d", head.Number.Uint64())
+		}
+		genesis, err = blockReader.BlockByNumber(ctx, tx, 0)
+		return err
+	}); err != nil {
+		return err
+	}
+	if genesis == nil {
+		return errors.New("genesis block is not found")
+	}
+	return generator.Run(ctx, db, genesis, func(chain *core.ChainPack) error {
+		return InsertChain(ethereum, chain, logger)
+	}, logger)
+}
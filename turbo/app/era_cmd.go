@@ -0,0 +1,560 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/downloader/snaptype"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/temporal"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/cltypes"
+	clera "github.com/erigontech/erigon/cl/era"
+	"github.com/erigontech/erigon/cl/persistence/beacon_indicies"
+	"github.com/erigontech/erigon/cl/utils"
+	"github.com/erigontech/erigon/cmd/caplin/caplin1"
+	"github.com/erigontech/erigon/cmd/hack/tool/fromdb"
+	cmdutils "github.com/erigontech/erigon/cmd/utils"
+	"github.com/erigontech/erigon/cmd/utils/flags"
+	"github.com/erigontech/erigon/core/era"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/eth/ethconfig/estimate"
+	"github.com/erigontech/erigon/eth/ethconsensusconfig"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/turbo/debug"
+	"github.com/erigontech/erigon/turbo/jsonrpc/receipts"
+	"github.com/erigontech/erigon/turbo/services"
+	"github.com/erigontech/erigon/turbo/snapshotsync/freezeblocks"
+)
+
+var importEraCommand = cli.Command{
+	Name:      "import-era",
+	Usage:     "Import history from era1 (execution blocks) and era (beacon blocks and states) files",
+	ArgsUsage: "<file or dir> (<file or dir 2> ... <file or dir N>)",
+	Action: func(cliCtx *cli.Context) error {
+		dirs, l, err := datadir.New(cliCtx.String(cmdutils.DataDirFlag.Name)).MustFlock()
+		if err != nil {
+			return err
+		}
+		defer l.Unlock()
+		return doImportEra(cliCtx, dirs)
+	},
+	Flags: joinFlags([]cli.Flag{
+		&cmdutils.DataDirFlag,
+		&Era1TrustFilesFlag,
+	}),
+	Description: `
+Datadir must be initialized (by "erigon init" or by first run with --chain): blocks are linked to its genesis.
+Every era1 file is verified - block bodies and receipts against headers, accumulator against blocks - and
+blocks are written to chaindata, then moved to block snapshots. Blocks which datadir already has are skipped.
+Erigon doesn't ship accumulator roots of published era1 files: a file is checked only against itself and the
+datadir's blocks, so --era1.trust-files is required - import only files from a source you trust.
+
+Beacon blocks of era files are verified against block roots of era's state and written to Caplin's db,
+complete ranges are moved to beacon blocks snapshots. State of the last era file becomes Caplin's latest
+state: Caplin starts from it if checkpoint sync is disabled.`,
+}
+
+var exportEraCommand = cli.Command{
+	Name:  "export-era",
+	Usage: "Export pre-merge blocks to era1 files: erigon export-era --datadir=<datadir> --to.dir=<dir>",
+	Action: func(cliCtx *cli.Context) error {
+		dirs, l, err := datadir.New(cliCtx.String(cmdutils.DataDirFlag.Name)).MustFlock()
+		if err != nil {
+			return err
+		}
+		defer l.Unlock()
+		return doExportEra(cliCtx, dirs)
+	},
+	Flags: joinFlags([]cli.Flag{
+		&cmdutils.DataDirFlag,
+		&EraToDirFlag,
+		&SnapshotFromFlag,
+		&SnapshotToFlag,
+	}),
+	Description: `
+Files are aligned to 8192 blocks: --from is rounded down to file start. Export stops at --to (or at last
+pre-merge block). Receipts are re-generated by execution, so state history of the range must be available.`,
+}
+
+var Era1TrustFilesFlag = cli.BoolFlag{
+	Name:  "era1.trust-files",
+	Usage: "Required to import era1 files: their accumulators are not checked against published roots, files must come from a trusted source",
+}
+
+var EraToDirFlag = flags.DirectoryFlag{
+	Name:     "to.dir",
+	Usage:    "Directory for era1 files",
+	Required: true,
+}
+
+// eraFiles - expands dirs to era1 and era files, keeps order of arguments
+func eraFiles(args []string) (era1s, eras []string, err error) {
+	for _, arg := range args {
+		st, err := os.Stat(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !st.IsDir() {
+			switch {
+			case strings.HasSuffix(arg, era.Era1Ext):
+				era1s = append(era1s, arg)
+			case strings.HasSuffix(arg, clera.Ext):
+				eras = append(eras, arg)
+			default:
+				return nil, nil, fmt.Errorf("unknown file type: %s", arg)
+			}
+			continue
+		}
+		files, err := era.List(arg)
+		if err != nil {
+			return nil, nil, err
+		}
+		era1s = append(era1s, files...)
+		if files, err = clera.List(arg); err != nil {
+			return nil, nil, err
+		}
+		eras = append(eras, files...)
+	}
+	return era1s, eras, nil
+}
+
+func doImportEra(cliCtx *cli.Context, dirs datadir.Dirs) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	ctx := cliCtx.Context
+	if cliCtx.NArg() < 1 {
+		return errors.New("era files or dirs are required")
+	}
+	era1s, eras, err := eraFiles(cliCtx.Args().Slice())
+	if err != nil {
+		return err
+	}
+	if len(era1s) > 0 && !cliCtx.Bool(Era1TrustFilesFlag.Name) {
+		return fmt.Errorf("era1 files are verified only against their own accumulators, Erigon doesn't know roots of published files: use --%s to import files from a trusted source", Era1TrustFilesFlag.Name)
+	}
+
+	db := dbCfg(kv.ChainDB, dirs.Chaindata).MustOpen()
+	defer db.Close()
+	_, _, caplinSnaps, br, _, clean, err := openSnaps(ctx, ethconfig.NewSnapCfg(false, true, true), dirs, db, logger)
+	if err != nil {
+		return err
+	}
+	defer clean()
+
+	if len(era1s) > 0 {
+		if err := importEra1(ctx, db, br, era1s, logger); err != nil {
+			return err
+		}
+	}
+	if len(eras) > 0 {
+		if err := importEra(ctx, db, dirs, caplinSnaps, eras, logger); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func importEra1(ctx context.Context, db kv.RwDB, br *freezeblocks.BlockRetire, files []string, logger log.Logger) error {
+	blockReader, _ := br.IO()
+	chainConfig := fromdb.ChainConfig(db)
+	if chainConfig == nil {
+		return errors.New("datadir has no genesis: run `erigon init` first")
+	}
+
+	var progress uint64
+	var head *types.Header
+	var headTd *big.Int
+	if err := db.View(ctx, func(tx kv.Tx) (err error) {
+		// blocks are written by all stages of finishEra1Batch: continue from the least advanced one
+		progress = math.MaxUint64
+		for _, stage := range era1Stages {
+			stageProgress, err := stages.GetStageProgress(tx, stage)
+			if err != nil {
+				return err
+			}
+			progress = min(progress, stageProgress)
+		}
+		if head, err = blockReader.HeaderByNumber(ctx, tx, progress); err != nil {
+			return err
+		}
+		if head == nil {
+			return fmt.Errorf("header %d not found", progress)
+		}
+		headTd, err = rawdb.ReadTd(tx, head.Hash(), progress)
+		return err
+	}); err != nil {
+		return err
+	}
+	if headTd == nil {
+		return fmt.Errorf("total difficulty of block %d not found", progress)
+	}
+
+	from := progress + 1
+	for _, file := range files {
+		e, err := era.Open(file)
+		if err != nil {
+			return err
+		}
+		if e.Start()+e.Count() <= progress+1 {
+			logger.Info("[era] skip: already have blocks", "file", filepath.Base(file))
+			e.Close()
+			continue
+		}
+		if e.Start() > progress+1 {
+			e.Close()
+			return fmt.Errorf("%s: starts at block %d, but datadir has blocks up to %d", filepath.Base(file), e.Start(), progress)
+		}
+
+		tx, err := db.BeginRw(ctx)
+		if err != nil {
+			e.Close()
+			return err
+		}
+		root, err := e.Verify(func(block *types.Block, _ types.Receipts, td *big.Int) error {
+			num := block.NumberU64()
+			if num <= progress {
+				return nil
+			}
+			if block.ParentHash() != head.Hash() {
+				return fmt.Errorf("block %d: parent hash doesn't match datadir's block %d", num, head.Number.Uint64())
+			}
+			if expected := new(big.Int).Add(headTd, block.Difficulty()); td.Cmp(expected) != 0 {
+				return fmt.Errorf("block %d: total difficulty %s, expected %s", num, td, expected)
+			}
+			if err := writeEra1Block(tx, chainConfig, block, td); err != nil {
+				return err
+			}
+			head, headTd, progress = block.HeaderNoCopy(), td, num
+			return nil
+		})
+		e.Close()
+		if err == nil {
+			err = finishEra1Batch(tx, from, head)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+		from = progress + 1
+		logger.Info("[era] imported", "file", filepath.Base(file), "accumulator", root, "block", progress)
+	}
+
+	logger.Info("[era] moving blocks to snapshots", "to", progress)
+	if err := br.RetireBlocks(ctx, 0, progress, log.LvlInfo, nil, nil, nil); err != nil {
+		return err
+	}
+	if err := db.Update(ctx, func(tx kv.RwTx) error {
+		blockReader, _ := br.IO()
+		return rawdb.WriteSnapshots(tx, blockReader.FrozenFiles(), nil)
+	}); err != nil {
+		return err
+	}
+	for deleted := math.MaxInt; deleted > 0; { // prune happens by small steps, so need many runs
+		if err := db.UpdateNosync(ctx, func(tx kv.RwTx) (err error) {
+			deleted, err = br.PruneAncientBlocks(tx, 100)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeEra1Block - writes block as Headers, BlockHashes, Bodies and Senders stages do.
+// Senders are recovered here: block snapshots store them with transactions.
+func writeEra1Block(tx kv.RwTx, chainConfig *chain.Config, block *types.Block, td *big.Int) error {
+	hash, num := block.Hash(), block.NumberU64()
+	if err := rawdb.WriteHeader(tx, block.HeaderNoCopy()); err != nil {
+		return err
+	}
+	if err := rawdb.WriteTd(tx, hash, num, td); err != nil {
+		return err
+	}
+	if err := rawdb.WriteCanonicalHash(tx, hash, num); err != nil {
+		return err
+	}
+	if err := rawdb.WriteBody(tx, hash, num, block.Body()); err != nil {
+		return err
+	}
+	signer := types.MakeSigner(chainConfig, num, block.Time())
+	senders := make([]libcommon.Address, len(block.Transactions()))
+	for i, txn := range block.Transactions() {
+		sender, err := signer.Sender(txn)
+		if err != nil {
+			return fmt.Errorf("block %d, txn %d: %w", num, i, err)
+		}
+		senders[i] = sender
+	}
+	return rawdb.WriteSenders(tx, hash, num, senders)
+}
+
+// era1Stages - stages which data writeEra1Block writes
+var era1Stages = []stages.SyncStage{stages.Headers, stages.BlockHashes, stages.Bodies, stages.Senders}
+
+func finishEra1Batch(tx kv.RwTx, from uint64, head *types.Header) error {
+	if err := rawdb.AppendCanonicalTxNums(tx, from); err != nil {
+		return err
+	}
+	num := head.Number.Uint64()
+	for _, stage := range era1Stages {
+		if err := stages.SaveStageProgress(tx, stage, num); err != nil {
+			return err
+		}
+	}
+	return rawdb.WriteHeadHeaderHash(tx, head.Hash())
+}
+
+func importEra(ctx context.Context, db kv.RwDB, dirs datadir.Dirs, caplinSnaps *freezeblocks.CaplinSnapshots, files []string, logger log.Logger) error {
+	chainConfig := fromdb.ChainConfig(db)
+	if chainConfig == nil {
+		return errors.New("datadir has no genesis: run `erigon init` first")
+	}
+	_, beaconConfig, _, err := clparams.GetConfigsByNetworkName(chainConfig.ChainName)
+	if err != nil {
+		return err
+	}
+	indiciesDB, _, err := caplin1.OpenCaplinDatabase(ctx, beaconConfig, nil, dirs.CaplinIndexing, dirs.CaplinBlobs, nil, false, 0)
+	if err != nil {
+		return err
+	}
+	defer indiciesDB.Close()
+
+	var lastRoot libcommon.Hash
+	var lastState []byte
+	var lastSlot uint64
+	for _, file := range files {
+		e, err := clera.Open(file, beaconConfig)
+		if err != nil {
+			return err
+		}
+		s, err := e.State()
+		if err != nil {
+			e.Close()
+			return err
+		}
+		if err := indiciesDB.Update(ctx, func(tx kv.RwTx) error {
+			return e.Verify(s, func(block *cltypes.SignedBeaconBlock) error {
+				if lastRoot != (libcommon.Hash{}) && block.Block.ParentRoot != lastRoot {
+					return fmt.Errorf("block of slot %d doesn't follow previous file", block.Block.Slot)
+				}
+				root, err := block.Block.HashSSZ()
+				if err != nil {
+					return err
+				}
+				lastRoot = root
+				return beacon_indicies.WriteBeaconBlockAndIndicies(ctx, tx, block, true)
+			})
+		}); err != nil {
+			e.Close()
+			return fmt.Errorf("%s: %w", filepath.Base(file), err)
+		}
+		e.Close()
+		if lastState, err = utils.EncodeSSZSnappy(s); err != nil {
+			return err
+		}
+		lastSlot = s.Slot()
+		logger.Info("[era] imported", "file", filepath.Base(file), "slot", lastSlot)
+	}
+
+	from := caplinSnaps.BlocksAvailable()
+	if from > 0 {
+		from++
+	}
+	salt, err := snaptype.GetIndexSalt(dirs.Snap)
+	if err != nil {
+		return err
+	}
+	logger.Info("[era] moving beacon blocks to snapshots", "from", from, "to", lastSlot)
+	if err := freezeblocks.DumpBeaconBlocks(ctx, indiciesDB, from, lastSlot, salt, dirs, estimate.CompressSnapshot.Workers(), log.LvlInfo, logger); err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(dirs.CaplinLatest, 0755); err != nil {
+		return err
+	}
+	f, err := os.Create(filepath.Join(dirs.CaplinLatest, clparams.LatestStateFileName))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	if _, err := w.Write(lastState); err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return f.Sync()
+}
+
+func doExportEra(cliCtx *cli.Context, dirs datadir.Dirs) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	ctx := cliCtx.Context
+	toDir := cliCtx.String(EraToDirFlag.Name)
+	if err := os.MkdirAll(toDir, 0755); err != nil {
+		return err
+	}
+
+	chainDB := dbCfg(kv.ChainDB, dirs.Chaindata).MustOpen()
+	defer chainDB.Close()
+	_, _, _, br, agg, clean, err := openSnaps(ctx, ethconfig.NewSnapCfg(false, true, true), dirs, chainDB, logger)
+	if err != nil {
+		return err
+	}
+	defer clean()
+	db, err := temporal.New(chainDB, agg)
+	if err != nil {
+		return err
+	}
+
+	chainConfig := fromdb.ChainConfig(db)
+	blockReader, _ := br.IO()
+	engine := ethconsensusconfig.CreateConsensusEngineBareBones(ctx, chainConfig, logger)
+	generator := receipts.NewGenerator(era.MaxEra1Size, blockReader, engine)
+
+	tx, err := db.BeginTemporalRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	to := cliCtx.Uint64(SnapshotToFlag.Name)
+	progress, err := stages.GetStageProgress(tx, stages.Execution)
+	if err != nil {
+		return err
+	}
+	if to == 0 || to > progress {
+		to = progress
+	}
+	for epoch := cliCtx.Uint64(SnapshotFromFlag.Name) / era.MaxEra1Size; epoch*era.MaxEra1Size <= to; epoch++ {
+		done, err := exportEra1(ctx, tx, blockReader, generator, chainConfig, toDir, epoch, to, logger)
+		if err != nil {
+			return err
+		}
+		if done {
+			break
+		}
+	}
+	return nil
+}
+
+// exportEra1 - writes era1 file of `epoch`, returns true if merge (or `to`) is reached
+func exportEra1(ctx context.Context, tx kv.TemporalTx, blockReader services.FullBlockReader, generator *receipts.Generator,
+	chainConfig *chain.Config, toDir string, epoch, to uint64, logger log.Logger) (done bool, err error) {
+	tmp, err := os.CreateTemp(toDir, "era1-*.tmp")
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	w := bufio.NewWriter(tmp)
+	b := era.NewBuilder(w)
+	from := epoch * era.MaxEra1Size
+	var last uint64
+	added := 0
+	for num := from; num < from+era.MaxEra1Size; num++ {
+		if num > to {
+			done = true
+			break
+		}
+		hash, err := blockReader.CanonicalHash(ctx, tx, num)
+		if err != nil {
+			return false, err
+		}
+		td, err := rawdb.ReadTd(tx, hash, num)
+		if err != nil {
+			return false, err
+		}
+		if td == nil {
+			return false, fmt.Errorf("total difficulty of block %d not found", num)
+		}
+		block, err := blockReader.BlockByHash(ctx, tx, hash)
+		if err != nil {
+			return false, err
+		}
+		if block == nil {
+			return false, fmt.Errorf("block %d not found", num)
+		}
+		if block.Difficulty().Sign() == 0 && num > 0 { // merged
+			done = true
+			break
+		}
+		var rs types.Receipts
+		if num > 0 {
+			if rs, err = generator.GetReceipts(ctx, chainConfig, tx, block); err != nil {
+				return false, fmt.Errorf("receipts of block %d: %w", num, err)
+			}
+		}
+		if err := era.VerifyBlock(block, rs); err != nil {
+			return false, err
+		}
+		if err := b.Add(block, rs, td); err != nil {
+			return false, err
+		}
+		last, added = num, added+1
+	}
+	if added == 0 {
+		return true, nil
+	}
+	root, err := b.Finalize()
+	if err != nil {
+		return false, err
+	}
+	if err := w.Flush(); err != nil {
+		return false, err
+	}
+	if err := tmp.Sync(); err != nil {
+		return false, err
+	}
+	if err := tmp.Close(); err != nil {
+		return false, err
+	}
+	name := filepath.Join(toDir, era.Filename(chainConfig.ChainName, epoch, root))
+	if err := os.Rename(tmp.Name(), name); err != nil {
+		return false, err
+	}
+	logger.Info("[era] exported", "file", filepath.Base(name), "blocks", fmt.Sprintf("%d-%d", from, last))
+	return done, nil
+}
@@ -0,0 +1,135 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/temporal"
+
+	"github.com/erigontech/erigon/cmd/hack/tool/fromdb"
+	"github.com/erigontech/erigon/cmd/rpcdaemon/cli/httpcfg"
+	cmdutils "github.com/erigontech/erigon/cmd/utils"
+	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/rpc/rpccfg"
+	"github.com/erigontech/erigon/turbo/debug"
+	"github.com/erigontech/erigon/turbo/fork"
+)
+
+var forkCommand = cli.Command{
+	Name:  "fork",
+	Usage: "Run local dev chain on top of state of datadir at block N: erigon fork --datadir=<datadir> --fork.block=N",
+	Action: func(cliCtx *cli.Context) error {
+		// source datadir is only read: it can be used by running Erigon, no flock
+		return doFork(cliCtx, datadir.New(cliCtx.String(cmdutils.DataDirFlag.Name)))
+	},
+	Flags: joinFlags([]cli.Flag{
+		&cmdutils.DataDirFlag,
+		&ForkBlockFlag,
+		&cmdutils.MinerEtherbaseFlag,
+		&cmdutils.HTTPListenAddrFlag,
+		&cmdutils.HTTPPortFlag,
+		&ForkHTTPApiFlag,
+		&cmdutils.HTTPCORSDomainFlag,
+		&cmdutils.HTTPVirtualHostsFlag,
+		&cmdutils.WSEnabledFlag,
+		&cmdutils.RpcGasCapFlag,
+		&cmdutils.RpcBatchLimit,
+		&cmdutils.RpcReturnDataLimit,
+	}),
+	Description: `
+Datadir is opened read-only: state is read as of --fork.block from its history, blocks after --fork.block are hidden.
+Every eth_sendRawTransaction is mined into a new block at once by dev consensus (fake ethash before the Merge, no seal
+after it). Mined blocks and their state are kept in memory and lost on exit. State root of mined blocks is not computed.`,
+}
+
+var (
+	ForkBlockFlag = cli.Uint64Flag{
+		Name:     "fork.block",
+		Usage:    "Block of datadir to fork from. State history of it must be available (not pruned)",
+		Required: true,
+	}
+	ForkHTTPApiFlag = cli.StringFlag{
+		Name:  "http.api",
+		Usage: "API's offered over the HTTP-RPC interface",
+		Value: "eth,erigon,web3,net,debug,trace,txpool,ots",
+	}
+)
+
+func doFork(cliCtx *cli.Context, dirs datadir.Dirs) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	ctx := cliCtx.Context
+
+	chainDB := dbCfg(kv.ChainDB, dirs.Chaindata).Readonly().MustOpen()
+	defer chainDB.Close()
+	_, _, _, br, agg, clean, err := openSnaps(ctx, ethconfig.NewSnapCfg(false, true, true), dirs, chainDB, logger)
+	if err != nil {
+		return err
+	}
+	defer clean()
+	baseDB, err := temporal.New(chainDB, agg)
+	if err != nil {
+		return err
+	}
+	chainConfig := fromdb.ChainConfig(baseDB)
+	blockReader, _ := br.IO()
+
+	db, err := fork.New(ctx, baseDB, blockReader, cliCtx.Uint64(ForkBlockFlag.Name), os.TempDir(), logger)
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	etherbase := libcommon.HexToAddress(cliCtx.String(cmdutils.MinerEtherbaseFlag.Name))
+	miner, err := fork.NewMiner(ctx, db, chainConfig, etherbase, logger)
+	if err != nil {
+		return err
+	}
+	logger.Info("[fork] forked", "chain", chainConfig.ChainName, "block", db.ForkBlock(), "etherbase", etherbase)
+
+	httpCfg := &httpcfg.HttpCfg{
+		Enabled:             true,
+		HttpServerEnabled:   true,
+		Dirs:                dirs,
+		HttpListenAddress:   cliCtx.String(cmdutils.HTTPListenAddrFlag.Name),
+		HttpPort:            cliCtx.Int(cmdutils.HTTPPortFlag.Name),
+		HttpCORSDomain:      libcommon.CliString2Array(cliCtx.String(cmdutils.HTTPCORSDomainFlag.Name)),
+		HttpVirtualHost:     libcommon.CliString2Array(cliCtx.String(cmdutils.HTTPVirtualHostsFlag.Name)),
+		API:                 libcommon.CliString2Array(cliCtx.String(ForkHTTPApiFlag.Name)),
+		WebsocketEnabled:    cliCtx.IsSet(cmdutils.WSEnabledFlag.Name),
+		HTTPTimeouts:        rpccfg.DefaultHTTPTimeouts,
+		EvmCallTimeout:      rpccfg.DefaultEvmCallTimeout,
+		RpcBatchConcurrency: cmdutils.RpcBatchConcurrencyFlag.Value,
+		Gascap:              uint64(cliCtx.Uint(cmdutils.RpcGasCapFlag.Name)),
+		Feecap:              cmdutils.RPCGlobalTxFeeCapFlag.Value,
+		MaxTraces:           uint64(cmdutils.TraceMaxtracesFlag.Value),
+		BatchLimit:          cliCtx.Int(cmdutils.RpcBatchLimit.Name),
+		ReturnDataLimit:     cliCtx.Int(cmdutils.RpcReturnDataLimit.Name),
+		OtsMaxPageSize:      cmdutils.OtsSearchMaxCapFlag.Value,
+
+		MaxGetProofRewindBlockCount:       cmdutils.RpcMaxGetProofRewindBlockCount.Value,
+		WebsocketSubscribeLogsChannelSize: cmdutils.WSSubscribeLogsChannelSize.Value,
+	}
+	return fork.Serve(ctx, db, miner, httpCfg, logger)
+}
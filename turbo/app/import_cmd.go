@@ -233,23 +233,25 @@ func missingBlocks(chainDB kv.RwDB, blocks []*types.Block, blockReader services.
 
 func InsertChain(ethereum *eth.Ethereum, chain *core.ChainPack, logger log.Logger) error {
 	sentryControlServer := ethereum.SentryControlServer()
-	initialCycle, firstCycle := false, false
 	for _, b := range chain.Blocks {
 		sentryControlServer.Hd.AddMinedHeader(b.Header())
 		sentryControlServer.Bd.AddToPrefetch(b.Header(), b.RawBody())
 	}
 	sentryControlServer.Hd.MarkAllVerified()
-	blockReader, _ := ethereum.BlockIO()
-
-	hook := stages.NewHook(ethereum.SentryCtx(), ethereum.ChainDB(), ethereum.Notifications(), ethereum.StagedSync(), blockReader, ethereum.ChainConfig(), logger, sentryControlServer.SetStatus)
-	err := stages.StageLoopIteration(ethereum.SentryCtx(), ethereum.ChainDB(), wrap.TxContainer{}, ethereum.StagedSync(), initialCycle, firstCycle, logger, blockReader, hook)
-	if err != nil {
+	if err := stageLoopIteration(ethereum, logger); err != nil {
 		return err
 	}
 
 	return insertPosChain(ethereum, chain, logger)
 }
 
+func stageLoopIteration(ethereum *eth.Ethereum, logger log.Logger) error {
+	initialCycle, firstCycle := false, false
+	blockReader, _ := ethereum.BlockIO()
+	hook := stages.NewHook(ethereum.SentryCtx(), ethereum.ChainDB(), ethereum.Notifications(), ethereum.StagedSync(), blockReader, ethereum.ChainConfig(), logger, ethereum.SentryControlServer().SetStatus)
+	return stages.StageLoopIteration(ethereum.SentryCtx(), ethereum.ChainDB(), wrap.TxContainer{}, ethereum.StagedSync(), initialCycle, firstCycle, logger, blockReader, hook)
+}
+
 func insertPosChain(ethereum *eth.Ethereum, chain *core.ChainPack, logger log.Logger) error {
 	posBlockStart := 0
 	for i, b := range chain.Blocks {
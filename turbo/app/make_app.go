@@ -82,6 +82,13 @@ func MakeApp(name string, action cli.ActionFunc, cliFlags []cli.Flag) *cli.App {
 		&importCommand,
 		&snapshotCommand,
 		&supportCommand,
+		&pruneModeCommand,
+		&onlineBackupCommand,
+		&importEraCommand,
+		&exportEraCommand,
+		&forkCommand,
+		&generateChainCommand,
+		&replayCommand,
 		//&backupCommand,
 	}
 	return app
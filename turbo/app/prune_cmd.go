@@ -0,0 +1,203 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/urfave/cli/v2"
+
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/downloader"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon/cmd/hack/tool/fromdb"
+	"github.com/erigontech/erigon/cmd/utils"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/rawdb/rawdbreset"
+	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/ethdb/prune"
+	erigoncli "github.com/erigontech/erigon/turbo/cli"
+	"github.com/erigontech/erigon/turbo/debug"
+	"github.com/erigontech/erigon/turbo/snapshotsync"
+)
+
+var pruneModeCommand = cli.Command{
+	Name:  "prune-mode",
+	Usage: "Show or change prune mode of existing datadir",
+	Subcommands: []*cli.Command{
+		{
+			Name:  "show",
+			Usage: "Print prune mode of datadir as flags",
+			Action: func(cliCtx *cli.Context) error {
+				return doPruneModeShow(cliCtx, datadir.New(cliCtx.String(utils.DataDirFlag.Name)))
+			},
+			Flags: joinFlags([]cli.Flag{
+				&utils.DataDirFlag,
+			}),
+		},
+		{
+			Name:  "migrate",
+			Usage: "Convert datadir to prune mode given by --prune.* flags: erigon prune-mode migrate --datadir=<path> --prune.mode=full",
+			Action: func(cliCtx *cli.Context) error {
+				dirs, l, err := datadir.New(cliCtx.String(utils.DataDirFlag.Name)).MustFlock()
+				if err != nil {
+					return err
+				}
+				defer l.Unlock()
+
+				return doPruneModeMigrate(cliCtx, dirs)
+			},
+			Flags: joinFlags([]cli.Flag{
+				&utils.DataDirFlag,
+				&erigoncli.PruneModeFlag,
+				&erigoncli.PruneDistanceFlag,
+				&erigoncli.PruneBlocksDistanceFlag,
+				&erigoncli.PruneLogsDistanceFlag,
+				&erigoncli.PruneTracesDistanceFlag,
+				&erigoncli.PruneStateDistanceFlag,
+				&erigoncli.PruneCodeDistanceFlag,
+				&erigoncli.PruneHistoryAllowlistFlag,
+			}),
+		},
+	},
+}
+
+func doPruneModeShow(cliCtx *cli.Context, dirs datadir.Dirs) error {
+	db := dbCfg(kv.ChainDB, dirs.Chaindata).Readonly().MustOpen()
+	defer db.Close()
+
+	return db.View(cliCtx.Context, func(tx kv.Tx) error {
+		pm, err := prune.Get(tx)
+		if err != nil {
+			return err
+		}
+		fmt.Println(pm.String())
+		return nil
+	})
+}
+
+// doPruneModeMigrate - moves datadir to another prune mode.
+// Datadir is marked as being migrated first and Erigon refuses to start until migration is done. Then files out of
+// retention of new mode are removed (removal is idempotent), and only then new mode is stored - so interrupted
+// migration is finished by re-run of this command with same flags.
+func doPruneModeMigrate(cliCtx *cli.Context, dirs datadir.Dirs) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	ctx := cliCtx.Context
+
+	db := dbCfg(kv.ChainDB, dirs.Chaindata).MustOpen()
+	defer db.Close()
+
+	chainConfig := fromdb.ChainConfig(db)
+	to := erigoncli.PruneModeFromFlags(cliCtx, chainConfig.ChainID.Uint64())
+	var from prune.Mode
+	var execProgress uint64
+	if err := db.View(ctx, func(tx kv.Tx) error {
+		if from, err = prune.Get(tx); err != nil {
+			return err
+		}
+		execProgress, err = stages.GetStageProgress(tx, stages.Execution)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	m, err := prune.NewMigration(from, to)
+	if err != nil {
+		return err
+	}
+	if m.Empty() {
+		logger.Info("Prune mode is not changed", "mode", from.String())
+		return nil
+	}
+	logger.Info("Migrating prune mode", "from", from.String(), "to", to.String(), "changes", m.String())
+
+	if err := db.Update(ctx, func(tx kv.RwTx) error {
+		return prune.BeginMigration(tx, m)
+	}); err != nil {
+		return err
+	}
+
+	_, _, _, br, agg, clean, err := openSnaps(ctx, ethconfig.NewSnapCfg(false, true, true), dirs, db, logger)
+	if err != nil {
+		return err
+	}
+	defer clean()
+	blockReader, _ := br.IO()
+
+	var deleted []string
+	if m.NeedsPrune() {
+		if err := db.View(ctx, func(tx kv.Tx) error {
+			if deleted, err = snapshotsync.PruneHistoryFiles(tx, agg, to, execProgress); err != nil {
+				return err
+			}
+			blockFiles, err := snapshotsync.PruneBlockFiles(blockReader, dirs.Snap, to, execProgress, func(file string) error {
+				// Downloader is not running: remove .torrent file, otherwise Downloader will download the file again
+				if err := os.Remove(filepath.Join(dirs.Snap, file+".torrent")); err != nil && !errors.Is(err, os.ErrNotExist) {
+					return err
+				}
+				return nil
+			})
+			deleted = append(deleted, blockFiles...)
+			return err
+		}); err != nil {
+			return err
+		}
+	}
+
+	if m.NeedsDownload() {
+		// Erigon downloads files only once - allow it to download files which new mode keeps
+		if err := os.Remove(filepath.Join(dirs.Snap, downloader.ProhibitNewDownloadsFileName)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+
+	if err := db.Update(ctx, func(tx kv.RwTx) error {
+		if err := prune.Migrate(tx, m, execProgress); err != nil {
+			return err
+		}
+		if m.History == prune.Grow {
+			// lookups of pruned blocks were removed: index all blocks again
+			if err := rawdbreset.ResetTxLookup(tx); err != nil {
+				return err
+			}
+		}
+		if !m.NeedsPrune() {
+			return nil
+		}
+		ac := agg.BeginFilesRo()
+		defer ac.Close()
+		return rawdb.WriteSnapshots(tx, blockReader.FrozenFiles(), ac.Files())
+	}); err != nil {
+		return err
+	}
+	if m.NeedsPrune() {
+		logger.Info("Removed files out of retention", "files", len(deleted))
+	}
+	if m.NeedsDownload() {
+		logger.Warn("Missing files will be downloaded on next start. Only preverified files can be downloaded: history of newer blocks is not restored. RPC serves only history which is already available")
+	}
+
+	logger.Info("Prune mode migrated, start Erigon with same --prune.* flags", "mode", to.String())
+	return nil
+}
@@ -0,0 +1,170 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/urfave/cli/v2"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/datadir"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/temporal"
+
+	"github.com/erigontech/erigon/cmd/hack/tool/fromdb"
+	"github.com/erigontech/erigon/cmd/state/exec3"
+	"github.com/erigontech/erigon/cmd/utils"
+	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/eth/ethconsensusconfig"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/turbo/debug"
+	"github.com/erigontech/erigon/turbo/replay"
+)
+
+var replayCommand = cli.Command{
+	Action: MigrateFlags(doReplay),
+	Name:   "replay",
+	Usage:  "Re-execute historical blocks and compare receipts with datadir: erigon replay --datadir=<datadir> --from=<block> --to=<block>",
+	Flags: []cli.Flag{
+		&utils.DataDirFlag,
+		&SnapshotFromFlag,
+		&SnapshotToFlag,
+		&ReplayTracerFlag,
+		&ReplayTracerConfigFlag,
+		&ReplayTraceOutFlag,
+		&ReplayWorkersFlag,
+	},
+	Description: `
+Blocks are executed on top of state history by parallel workers, nothing is written to datadir, so it can be used
+by running node. Receipts of every block are compared with stored receipts (if node persists them), then gas used,
+receipts root and logs bloom are compared with header. Replay stops at the first divergence: it's printed with
+state diff of prestateTracer, command fails.
+With --tracer every transaction is traced by tracer of debug_traceTransaction, results are written as JSON lines.`,
+}
+
+var (
+	ReplayTracerFlag = cli.StringFlag{
+		Name:  "tracer",
+		Usage: "Tracer for every transaction, e.g. callTracer, prestateTracer or JS code",
+	}
+	ReplayTracerConfigFlag = cli.StringFlag{
+		Name:  "tracer.config",
+		Usage: `Config of tracer in JSON, e.g. {"onlyTopCall":true}`,
+	}
+	ReplayTraceOutFlag = cli.StringFlag{
+		Name:  "trace.out",
+		Usage: "File for results of tracer, stdout if not set",
+	}
+	ReplayWorkersFlag = cli.IntFlag{
+		Name:  "workers",
+		Usage: "Amount of execution workers, 0 - by amount of CPUs",
+	}
+)
+
+func doReplay(cliCtx *cli.Context) error {
+	logger, _, _, err := debug.Setup(cliCtx, true /* rootLogger */)
+	if err != nil {
+		return err
+	}
+	ctx := cliCtx.Context
+	dirs := datadir.New(cliCtx.String(utils.DataDirFlag.Name))
+
+	chainDB := dbCfg(kv.ChainDB, dirs.Chaindata).Readonly().MustOpen()
+	defer chainDB.Close()
+	_, _, _, br, agg, clean, err := openSnaps(ctx, ethconfig.NewSnapCfg(false, true, true), dirs, chainDB, logger)
+	if err != nil {
+		return err
+	}
+	defer clean()
+	db, err := temporal.New(chainDB, agg)
+	if err != nil {
+		return err
+	}
+
+	chainConfig := fromdb.ChainConfig(db)
+	blockReader, _ := br.IO()
+	execArgs := &exec3.ExecArgs{
+		ChainDB:     db,
+		BlockReader: blockReader,
+		Engine:      ethconsensusconfig.CreateConsensusEngineBareBones(ctx, chainConfig, logger),
+		Dirs:        dirs,
+		ChainConfig: chainConfig,
+		Workers:     cliCtx.Int(ReplayWorkersFlag.Name),
+	}
+
+	cfg := replay.Config{
+		From:   cliCtx.Uint64(SnapshotFromFlag.Name),
+		To:     cliCtx.Uint64(SnapshotToFlag.Name),
+		Tracer: cliCtx.String(ReplayTracerFlag.Name),
+	}
+	if c := cliCtx.String(ReplayTracerConfigFlag.Name); c != "" {
+		cfg.TracerConfig = json.RawMessage(c)
+	}
+	var progress uint64
+	if err = db.View(ctx, func(tx kv.Tx) error {
+		progress, err = stages.GetStageProgress(tx, stages.Execution)
+		return err
+	}); err != nil {
+		return err
+	}
+	if cfg.To == 0 || cfg.To > progress {
+		cfg.To = progress
+	}
+	if cfg.From == 0 {
+		cfg.From = 1
+	}
+
+	if cfg.Tracer != "" {
+		var out io.Writer = os.Stdout
+		if path := cliCtx.String(ReplayTraceOutFlag.Name); path != "" {
+			f, err := os.Create(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			out = f
+		}
+		w := bufio.NewWriter(out)
+		defer w.Flush()
+		enc := json.NewEncoder(w)
+		cfg.OnTrace = func(blockNum uint64, txIndex int, txHash libcommon.Hash, result json.RawMessage) error {
+			return enc.Encode(struct {
+				BlockNumber uint64          `json:"blockNumber"`
+				TxIndex     int             `json:"txIndex"`
+				TxHash      libcommon.Hash  `json:"txHash"`
+				Result      json.RawMessage `json:"result"`
+			}{blockNum, txIndex, txHash, result})
+		}
+	}
+
+	logger.Info("[replay] start", "from", cfg.From, "to", cfg.To, "tracer", cfg.Tracer)
+	divergence, err := replay.Run(ctx, cfg, execArgs, logger)
+	if err != nil {
+		return err
+	}
+	if divergence == nil {
+		logger.Info("[replay] receipts of all blocks match", "from", cfg.From, "to", cfg.To)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "divergence at %s\nstate diff of re-execution:\n%s\n", divergence, divergence.StateDiff)
+	return fmt.Errorf("replay diverged at block %d", divergence.BlockNum)
+}
@@ -68,11 +68,13 @@ import (
 	"github.com/erigontech/erigon/eth/ethconfig/estimate"
 	"github.com/erigontech/erigon/eth/integrity"
 	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/ethdb/prune"
 	"github.com/erigontech/erigon/params"
 	erigoncli "github.com/erigontech/erigon/turbo/cli"
 	"github.com/erigontech/erigon/turbo/debug"
 	"github.com/erigontech/erigon/turbo/logging"
 	"github.com/erigontech/erigon/turbo/node"
+	"github.com/erigontech/erigon/turbo/snapshotsync"
 	"github.com/erigontech/erigon/turbo/snapshotsync/freezeblocks"
 )
 
@@ -674,9 +676,19 @@ func checkIfStateSnapshotsPublishable(dir datadir.Dirs) error {
 		}
 
 		viTypes := []string{"accounts", "storage", "code"}
+		for _, cfg := range kv.UserDomains() {
+			viTypes = append(viTypes, cfg.FilenameBase)
+		}
 
 		// do a range check over all snapshots types (sanitizes domain and history folder)
-		for _, snapType := range []string{"accounts", "storage", "code", "logtopics", "logaddrs", "tracesfrom", "tracesto"} {
+		snapTypes := []string{"accounts", "storage", "code", "logtopics", "logaddrs", "tracesfrom", "tracesto"}
+		for _, cfg := range kv.UserDomains() {
+			snapTypes = append(snapTypes, cfg.FilenameBase)
+		}
+		for _, cfg := range kv.UserInvertedIdxs() {
+			snapTypes = append(snapTypes, cfg.FilenameBase)
+		}
+		for _, snapType := range snapTypes {
 			expectedFileName := strings.Replace(info.Name(), "accounts", snapType, 1)
 			if _, err := os.Stat(filepath.Join(dir.SnapIdx, expectedFileName)); err != nil {
 				return fmt.Errorf("missing file %s at path %s", expectedFileName, filepath.Join(dir.SnapIdx, expectedFileName))
@@ -1131,12 +1143,11 @@ func doRetireCommand(cliCtx *cli.Context, dirs datadir.Dirs) error {
 		return err
 	}
 	defer logger.Info("Done")
-	ctx := cliCtx.Context
-
-	from := cliCtx.Uint64(SnapshotFromFlag.Name)
-	to := cliCtx.Uint64(SnapshotToFlag.Name)
-	every := cliCtx.Uint64(SnapshotEveryFlag.Name)
+	return retire(cliCtx.Context, dirs, cliCtx.Uint64(SnapshotFromFlag.Name), cliCtx.Uint64(SnapshotToFlag.Name), cliCtx.Uint64(SnapshotEveryFlag.Name), logger)
+}
 
+// retire - moves blocks of datadir to snapshots and builds state files, datadir must not be used by other process
+func retire(ctx context.Context, dirs datadir.Dirs, from, to, every uint64, logger log.Logger) error {
 	db := dbCfg(kv.ChainDB, dirs.Chaindata).MustOpen()
 	defer db.Close()
 
@@ -1290,6 +1301,26 @@ func doRetireCommand(cliCtx *cli.Context, dirs datadir.Dirs) error {
 	if err = agg.BuildMissedIndices(ctx, indexWorkers); err != nil {
 		return err
 	}
+	if err := db.View(ctx, func(tx kv.Tx) error {
+		pm, err := prune.Get(tx)
+		if err != nil {
+			return err
+		}
+		execProgress, err := stages.GetStageProgress(tx, stages.Execution)
+		if err != nil {
+			return err
+		}
+		deleted, err := snapshotsync.PruneHistoryFiles(tx, agg, pm, execProgress)
+		if err != nil {
+			return err
+		}
+		if len(deleted) > 0 {
+			logger.Info("Pruned state history snapshots", "files", len(deleted))
+		}
+		return nil
+	}); err != nil {
+		return err
+	}
 	if err := db.UpdateNosync(ctx, func(tx kv.RwTx) error {
 		blockReader, _ := br.IO()
 		ac := agg.BeginFilesRo()
@@ -21,27 +21,49 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/holiman/uint256"
+
 	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/metrics"
 
 	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/params"
+)
+
+var (
+	rebuildsCounter = metrics.GetOrCreateCounter(`block_builder_rebuilds`)
+	improvedCounter = metrics.GetOrCreateCounter(`block_builder_rebuilds_improved`)
+	valueGain       = metrics.GetOrCreateSummary(`block_builder_value_gain_gwei`)
 )
 
 type BlockBuilderFunc func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error)
 
+// RebuildConfig - payload is rebuilt until it's requested or its slot starts, the most valuable one is kept
+type RebuildConfig struct {
+	Interval time.Duration // min time between rebuilds, 0 - payload is built once
+	NewTxs   *TxsNotifier  // if set, payload is rebuilt only when txpool got new transactions
+}
+
 // BlockBuilder wraps a goroutine that builds Proof-of-Stake payloads (PoS "mining")
 type BlockBuilder struct {
 	interrupt int32
+	stop      chan struct{}
+	stopOnce  sync.Once
 	syncCond  *sync.Cond
 	result    *types.BlockWithReceipts
+	value     *uint256.Int
 	err       error
+	done      bool
 }
 
-func NewBlockBuilder(build BlockBuilderFunc, param *core.BlockBuilderParameters) *BlockBuilder {
-	builder := new(BlockBuilder)
+func NewBlockBuilder(build BlockBuilderFunc, param *core.BlockBuilderParameters, rebuild RebuildConfig) *BlockBuilder {
+	builder := &BlockBuilder{stop: make(chan struct{})}
 	builder.syncCond = sync.NewCond(new(sync.Mutex))
 
 	go func() {
+		newTxs, unsubscribe := rebuild.NewTxs.subscribe() // transactions which arrive during the first build count
+
 		log.Info("Building block...")
 		t := time.Now()
 		result, err := build(param, &builder.interrupt)
@@ -53,21 +75,93 @@ func NewBlockBuilder(build BlockBuilderFunc, param *core.BlockBuilderParameters)
 		}
 
 		builder.syncCond.L.Lock()
-		defer builder.syncCond.L.Unlock()
 		builder.result = result
 		builder.err = err
+		if result != nil {
+			builder.value = BlockValue(result)
+		}
+		builder.syncCond.Broadcast()
+		builder.syncCond.L.Unlock()
+
+		if err == nil && rebuild.Interval > 0 {
+			builder.rebuild(build, param, rebuild.Interval, newTxs)
+		}
+		unsubscribe()
+
+		builder.syncCond.L.Lock()
+		defer builder.syncCond.L.Unlock()
+		builder.done = true
 		builder.syncCond.Broadcast()
 	}()
 
 	return builder
 }
 
+// rebuild - re-runs build until payload is requested or its slot starts, keeps the most valuable result
+func (b *BlockBuilder) rebuild(build BlockBuilderFunc, param *core.BlockBuilderParameters, interval time.Duration, newTxs <-chan struct{}) {
+	deadline := time.NewTimer(time.Until(time.Unix(int64(param.Timestamp), 0)))
+	defer deadline.Stop()
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	first := b.value.Clone()
+	var rebuilds int
+	defer func() {
+		if rebuilds == 0 {
+			return
+		}
+		gain := new(uint256.Int).Sub(b.value, first)
+		valueGain.Observe(float64(new(uint256.Int).Div(gain, uint256.NewInt(params.GWei)).Uint64()))
+		log.Info("Rebuilt block", "rebuilds", rebuilds, "value", b.value.Dec(), "gain", gain.Dec())
+	}()
+
+	for {
+		select {
+		case <-b.stop:
+			return
+		case <-deadline.C:
+			return
+		case <-ticker.C:
+		}
+		if newTxs != nil { // nothing to gain from the same transactions
+			select {
+			case <-b.stop:
+				return
+			case <-deadline.C:
+				return
+			case <-newTxs:
+			}
+		}
+		select {
+		case <-b.stop: // requested while waiting
+			return
+		default:
+		}
+		result, err := build(param, &b.interrupt)
+		rebuilds++
+		rebuildsCounter.Inc()
+		if err != nil {
+			log.Warn("Failed to rebuild a block", "err", err)
+			continue
+		}
+		value := BlockValue(result)
+		b.syncCond.L.Lock()
+		if value.Gt(b.value) {
+			b.result, b.value = result, value
+			improvedCounter.Inc()
+		}
+		b.syncCond.L.Unlock()
+	}
+}
+
+// Stop - stops rebuilding and returns the most valuable payload
 func (b *BlockBuilder) Stop() (*types.BlockWithReceipts, error) {
 	atomic.StoreInt32(&b.interrupt, 1)
+	b.stopOnce.Do(func() { close(b.stop) })
 
 	b.syncCond.L.Lock()
 	defer b.syncCond.L.Unlock()
-	for b.result == nil && b.err == nil {
+	for !b.done {
 		b.syncCond.Wait()
 	}
 
@@ -83,3 +177,56 @@ func (b *BlockBuilder) Block() *types.Block {
 	}
 	return b.result.Block
 }
+
+// BlockValue - payment of fee recipient by block: its balance change if it's known, tips of transactions otherwise
+func BlockValue(result *types.BlockWithReceipts) *uint256.Int {
+	if result.CoinbaseDelta != nil {
+		return result.CoinbaseDelta.Clone()
+	}
+	baseFee, _ := uint256.FromBig(result.Block.BaseFee())
+	value := uint256.NewInt(0)
+	for i, txn := range result.Block.Transactions() {
+		tip := txn.GetEffectiveGasTip(baseFee)
+		value.Add(value, new(uint256.Int).Mul(tip, uint256.NewInt(result.Receipts[i].GasUsed)))
+	}
+	return value
+}
+
+// TxsNotifier - notifies block builders about new transactions of txpool
+type TxsNotifier struct {
+	lock sync.Mutex
+	subs map[uint64]chan struct{}
+	id   uint64
+}
+
+func NewTxsNotifier() *TxsNotifier {
+	return &TxsNotifier{subs: map[uint64]chan struct{}{}}
+}
+
+// Notify - doesn't block, subscribers which are busy are notified once
+func (n *TxsNotifier) Notify() {
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	for _, ch := range n.subs {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (n *TxsNotifier) subscribe() (<-chan struct{}, func()) {
+	if n == nil {
+		return nil, func() {}
+	}
+	n.lock.Lock()
+	defer n.lock.Unlock()
+	n.id++
+	id, ch := n.id, make(chan struct{}, 1)
+	n.subs[id] = ch
+	return ch, func() {
+		n.lock.Lock()
+		defer n.lock.Unlock()
+		delete(n.subs, id)
+	}
+}
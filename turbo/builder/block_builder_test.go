@@ -0,0 +1,96 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package builder
+
+import (
+	"math/big"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+)
+
+// valuesBuilder - n-th build pays values[n] to fee recipient, the last value is repeated
+func valuesBuilder(values ...uint64) (BlockBuilderFunc, *atomic.Int32) {
+	var builds atomic.Int32
+	return func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+		n := int(builds.Add(1)) - 1
+		value := values[min(n, len(values)-1)]
+		block := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(1), GasLimit: 1, Nonce: types.EncodeNonce(uint64(n))})
+		return &types.BlockWithReceipts{Block: block, CoinbaseDelta: uint256.NewInt(value)}, nil
+	}, &builds
+}
+
+func futureParam() *core.BlockBuilderParameters {
+	return &core.BlockBuilderParameters{Timestamp: uint64(time.Now().Add(time.Minute).Unix())}
+}
+
+func TestBuildOnce(t *testing.T) {
+	t.Parallel()
+	build, builds := valuesBuilder(1, 2)
+	b := NewBlockBuilder(build, futureParam(), RebuildConfig{})
+	time.Sleep(50 * time.Millisecond)
+	result, err := b.Stop()
+	require.NoError(t, err)
+	require.Equal(t, int32(1), builds.Load())
+	require.Equal(t, uint64(1), result.CoinbaseDelta.Uint64())
+}
+
+func TestRebuildKeepsMostValuable(t *testing.T) {
+	t.Parallel()
+	build, builds := valuesBuilder(5, 9, 3)
+	b := NewBlockBuilder(build, futureParam(), RebuildConfig{Interval: time.Millisecond})
+	require.Eventually(t, func() bool { return builds.Load() >= 3 }, 5*time.Second, time.Millisecond)
+	result, err := b.Stop()
+	require.NoError(t, err)
+	require.Equal(t, uint64(9), result.CoinbaseDelta.Uint64())
+	require.Equal(t, uint64(1), result.Block.Nonce().Uint64())
+	require.Equal(t, result.Block, b.Block())
+}
+
+func TestRebuildOnNewTxs(t *testing.T) {
+	t.Parallel()
+	build, builds := valuesBuilder(1, 2)
+	notifier := NewTxsNotifier()
+	b := NewBlockBuilder(build, futureParam(), RebuildConfig{Interval: time.Millisecond, NewTxs: notifier})
+	require.Eventually(t, func() bool { return b.Block() != nil }, 5*time.Second, time.Millisecond)
+	time.Sleep(20 * time.Millisecond)
+	require.Equal(t, int32(1), builds.Load())
+
+	notifier.Notify()
+	require.Eventually(t, func() bool { return builds.Load() == 2 }, 5*time.Second, time.Millisecond)
+	result, err := b.Stop()
+	require.NoError(t, err)
+	require.Equal(t, uint64(2), result.CoinbaseDelta.Uint64())
+	require.Empty(t, notifier.subs)
+}
+
+func TestRebuildUntilSlot(t *testing.T) {
+	t.Parallel()
+	build, builds := valuesBuilder(1)
+	param := &core.BlockBuilderParameters{Timestamp: uint64(time.Now().Unix()) - 1}
+	b := NewBlockBuilder(build, param, RebuildConfig{Interval: time.Millisecond})
+	time.Sleep(50 * time.Millisecond)
+	_, err := b.Stop()
+	require.NoError(t, err)
+	require.Equal(t, int32(1), builds.Load())
+}
@@ -0,0 +1,84 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/rpc"
+)
+
+// APIs - eth_sendBundle of builder. It's served only by RPC embedded into Erigon: bundles are kept in its memory.
+func APIs(b *Builder, chainConfig *chain.Config) []rpc.API {
+	return []rpc.API{
+		{Namespace: "eth", Public: true, Service: &BundleAPI{pool: b.bundles, chainConfig: chainConfig}, Version: "1.0"},
+	}
+}
+
+type BundleAPI struct {
+	pool        *BundlePool
+	chainConfig *chain.Config
+}
+
+// SendBundleArgs - arguments of eth_sendBundle, as Flashbots defines them
+type SendBundleArgs struct {
+	Txs               []hexutility.Bytes `json:"txs"`
+	BlockNumber       hexutil.Uint64     `json:"blockNumber"`
+	MinTimestamp      *uint64            `json:"minTimestamp"`
+	MaxTimestamp      *uint64            `json:"maxTimestamp"`
+	RevertingTxHashes []libcommon.Hash   `json:"revertingTxHashes"`
+}
+
+type SendBundleResult struct {
+	BundleHash libcommon.Hash `json:"bundleHash"`
+}
+
+// SendBundle implements eth_sendBundle. Bundle is included at the top of block as a whole or not at all.
+func (api *BundleAPI) SendBundle(ctx context.Context, args SendBundleArgs) (*SendBundleResult, error) {
+	if len(args.Txs) == 0 {
+		return nil, errors.New("bundle has no transactions")
+	}
+	signer := types.LatestSigner(api.chainConfig)
+	bundle := &Bundle{BlockNumber: uint64(args.BlockNumber), RevertingTxHashes: args.RevertingTxHashes}
+	if args.MinTimestamp != nil {
+		bundle.MinTimestamp = *args.MinTimestamp
+	}
+	if args.MaxTimestamp != nil {
+		bundle.MaxTimestamp = *args.MaxTimestamp
+	}
+	for i, enc := range args.Txs {
+		txn, err := types.DecodeWrappedTransaction(enc)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		sender, err := txn.Sender(*signer)
+		if err != nil {
+			return nil, fmt.Errorf("transaction %d: %w", i, err)
+		}
+		txn.SetSender(sender)
+		bundle.Txs = append(bundle.Txs, txn)
+	}
+	return &SendBundleResult{BundleHash: api.pool.Add(bundle)}, nil
+}
@@ -0,0 +1,343 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package relay - external block builder: builds blocks for proposers registered with MEV-boost relays
+// and submits them as bids by Flashbots relay API.
+//
+// Builder follows payload attributes of `engine_forkchoiceUpdated`, so its consensus client must send them
+// for every slot, not only for slots of own validators (e.g. Lighthouse `--always-prepare-payload`).
+package relay
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/metrics"
+
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/cl/utils"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/stagedsync"
+	"github.com/erigontech/erigon/turbo/builder"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+)
+
+var (
+	submissionsCounter = metrics.GetOrCreateCounter(`builder_relay_submissions{result="ok"}`)
+	failuresCounter    = metrics.GetOrCreateCounter(`builder_relay_submissions{result="error"}`)
+	droppedBundles     = metrics.GetOrCreateCounter(`builder_relay_bundles_dropped`)
+)
+
+// genesisTimes - beacon chain genesis of known networks, slot of payload is computed from it
+var genesisTimes = map[string]uint64{
+	"mainnet": 1606824023,
+	"sepolia": 1655733600,
+	"holesky": 1695902400,
+	"gnosis":  1638993340,
+	"chiado":  1665396300,
+}
+
+// GenesisTime - beacon chain genesis time of known network
+func GenesisTime(chainName string) (uint64, bool) {
+	t, ok := genesisTimes[chainName]
+	return t, ok
+}
+
+type Config struct {
+	Relays       []string
+	SecretKey    []byte        // BLS secret key of builder
+	Interval     time.Duration // between builds of the same slot: bid is re-submitted when its value grows
+	GenesisTime  uint64
+	BeaconConfig *clparams.BeaconChainConfig
+}
+
+// BuildFunc - builds block with fee recipient and gas limit of proposer, with transactions of txPool
+type BuildFunc func(param *core.BlockBuilderParameters, gasLimit uint64, txPool stagedsync.TxPoolForMining, interrupt *int32) (*types.BlockWithReceipts, error)
+
+// slotDuty - proposer of slot and relays it's registered with
+type slotDuty struct {
+	duty   ProposerDuty
+	relays []*Client
+}
+
+type Builder struct {
+	cfg     Config
+	relays  []*Client
+	signer  *Signer
+	build   BuildFunc
+	txPool  stagedsync.TxPoolForMining
+	bundles *BundlePool
+	db      kv.RoDB
+
+	lock        sync.Mutex
+	duties      map[uint64]slotDuty
+	fetchedSlot uint64 // duties are fetched at most once per slot
+	building    *core.BlockBuilderParameters
+	cancel      context.CancelFunc
+
+	ctx    context.Context
+	logger log.Logger
+}
+
+func New(ctx context.Context, cfg Config, db kv.RoDB, build BuildFunc, txPool stagedsync.TxPoolForMining, logger log.Logger) (*Builder, error) {
+	if len(cfg.Relays) == 0 {
+		return nil, errors.New("builder: no relays")
+	}
+	if cfg.Interval <= 0 {
+		return nil, fmt.Errorf("builder: invalid interval %s", cfg.Interval)
+	}
+	signer, err := NewSigner(cfg.SecretKey, cfg.BeaconConfig.DomainApplicationBuilder, utils.Uint32ToBytes4(uint32(cfg.BeaconConfig.GenesisForkVersion)))
+	if err != nil {
+		return nil, err
+	}
+	b := &Builder{cfg: cfg, signer: signer, build: build, txPool: txPool, bundles: NewBundlePool(), db: db, duties: map[uint64]slotDuty{}, ctx: ctx, logger: logger}
+	for _, u := range cfg.Relays {
+		c, err := NewClient(u)
+		if err != nil {
+			return nil, err
+		}
+		b.relays = append(b.relays, c)
+	}
+	logger.Info("[builder] relay builder is enabled", "pubkey", signer.Pubkey(), "relays", len(b.relays))
+	return b, nil
+}
+
+func (b *Builder) Bundles() *BundlePool { return b.bundles }
+
+// Wrap - local payload building, which also starts building of the same slot for relays
+func (b *Builder) Wrap(build builder.BlockBuilderFunc) builder.BlockBuilderFunc {
+	return func(param *core.BlockBuilderParameters, interrupt *int32) (*types.BlockWithReceipts, error) {
+		b.OnPayloadAttributes(param)
+		return build(param, interrupt)
+	}
+}
+
+// OnPayloadAttributes - starts building of slot of payload until its start. Building of previous slot is stopped.
+func (b *Builder) OnPayloadAttributes(param *core.BlockBuilderParameters) {
+	if param.Timestamp < b.cfg.GenesisTime {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	if b.building != nil && b.building.ParentHash == param.ParentHash && b.building.Timestamp == param.Timestamp {
+		return
+	}
+	if b.cancel != nil {
+		b.cancel()
+	}
+	p := *param
+	ctx, cancel := context.WithDeadline(b.ctx, time.Unix(int64(param.Timestamp), 0))
+	b.building, b.cancel = &p, cancel
+	go b.buildSlot(ctx, &p)
+}
+
+func (b *Builder) slot(timestamp uint64) uint64 {
+	return (timestamp - b.cfg.GenesisTime) / b.cfg.BeaconConfig.SecondsPerSlot
+}
+
+func (b *Builder) buildSlot(ctx context.Context, param *core.BlockBuilderParameters) {
+	slot := b.slot(param.Timestamp)
+	duty, ok, err := b.proposer(ctx, slot)
+	if err != nil {
+		b.logger.Warn("[builder] proposer duties", "slot", slot, "err", err)
+		return
+	}
+	if !ok {
+		b.logger.Debug("[builder] proposer of slot is not registered with relays", "slot", slot)
+		return
+	}
+	gasLimit, err := duty.duty.GasLimit()
+	if err != nil {
+		b.logger.Warn("[builder] gas limit of proposer", "slot", slot, "err", err)
+		return
+	}
+	param.SuggestedFeeRecipient = duty.duty.Entry.Message.FeeRecipient
+
+	var interrupt int32
+	go func() {
+		<-ctx.Done()
+		atomic.StoreInt32(&interrupt, 1)
+	}()
+
+	var best *uint256.Int
+	ticker := time.NewTicker(b.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		result, value, err := b.buildBlock(param, gasLimit, &interrupt)
+		switch {
+		case err != nil:
+			b.logger.Warn("[builder] failed to build block", "slot", slot, "err", err)
+		case ctx.Err() != nil: // interrupted: block can be incomplete
+		case best == nil || value.Gt(best):
+			best = value
+			b.submit(ctx, slot, duty, result, value)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// buildBlock - builds block with bundles which fit into it. Bundles which aren't fully included are dropped, block is re-built without them.
+func (b *Builder) buildBlock(param *core.BlockBuilderParameters, gasLimit uint64, interrupt *int32) (*types.BlockWithReceipts, *uint256.Int, error) {
+	var bundles []*Bundle
+	if err := b.db.View(b.ctx, func(tx kv.Tx) error {
+		if number := rawdb.ReadHeaderNumber(tx, param.ParentHash); number != nil {
+			b.bundles.Prune(*number+1, param.Timestamp)
+			bundles = b.bundles.ForBlock(*number+1, param.Timestamp)
+		}
+		return nil
+	}); err != nil {
+		return nil, nil, err
+	}
+	for {
+		result, err := b.build(param, gasLimit, &bundleTxPool{TxPoolForMining: b.txPool, bundles: bundles}, interrupt)
+		if err != nil {
+			return nil, nil, err
+		}
+		included := bundles[:0:0]
+		for _, bundle := range bundles {
+			if bundle.included(result) {
+				included = append(included, bundle)
+			}
+		}
+		if len(included) == len(bundles) {
+			return result, builder.BlockValue(result), nil
+		}
+		droppedBundles.AddInt(len(bundles) - len(included))
+		bundles = included
+	}
+}
+
+func (b *Builder) submit(ctx context.Context, slot uint64, duty slotDuty, result *types.BlockWithReceipts, value *uint256.Int) {
+	block := result.Block
+	bid := &BidTrace{
+		Slot:                 slot,
+		ParentHash:           block.ParentHash(),
+		BlockHash:            block.Hash(),
+		BuilderPubkey:        b.signer.Pubkey(),
+		ProposerPubkey:       duty.duty.Entry.Message.PubKey,
+		ProposerFeeRecipient: duty.duty.Entry.Message.FeeRecipient,
+		GasLimit:             block.GasLimit(),
+		GasUsed:              block.GasUsed(),
+		Value:                value,
+	}
+	sig, err := b.signer.Sign(bid)
+	if err != nil {
+		b.logger.Warn("[builder] failed to sign bid", "slot", slot, "err", err)
+		return
+	}
+	req := &SubmitBlockRequest{
+		Message:          bid,
+		ExecutionPayload: cltypes.NewEth1BlockFromHeaderAndBody(block.Header(), block.RawBody(), b.cfg.BeaconConfig),
+		Signature:        sig,
+	}
+	if block.Header().BlobGasUsed != nil {
+		if req.BlobsBundle, err = blobsBundle(block); err != nil {
+			b.logger.Warn("[builder] blobs of block", "slot", slot, "err", err)
+			return
+		}
+	}
+
+	var wg sync.WaitGroup
+	for _, relay := range duty.relays {
+		wg.Add(1)
+		go func(relay *Client) {
+			defer wg.Done()
+			if err := relay.SubmitBlock(ctx, req); err != nil {
+				failuresCounter.Inc()
+				b.logger.Warn("[builder] submission failed", "relay", relay, "slot", slot, "err", err)
+				return
+			}
+			submissionsCounter.Inc()
+			b.logger.Info("[builder] block submitted", "relay", relay, "slot", slot, "block", block.NumberU64(), "hash", block.Hash(), "txs", block.Transactions().Len(), "value", value.Dec())
+		}(relay)
+	}
+	wg.Wait()
+}
+
+// proposer - proposer duty of slot from relays
+func (b *Builder) proposer(ctx context.Context, slot uint64) (slotDuty, bool, error) {
+	b.lock.Lock()
+	duty, ok := b.duties[slot]
+	fetch := !ok && b.fetchedSlot != slot
+	b.lock.Unlock()
+	if !fetch {
+		return duty, ok, nil
+	}
+
+	duties := map[uint64]slotDuty{}
+	var errs []error
+	for _, relay := range b.relays {
+		relayDuties, err := relay.ProposerDuties(ctx)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		for _, d := range relayDuties {
+			if d.Entry == nil {
+				continue
+			}
+			sd := duties[d.Slot]
+			sd.duty = d
+			sd.relays = append(sd.relays, relay)
+			duties[d.Slot] = sd
+		}
+	}
+	if len(errs) == len(b.relays) {
+		return slotDuty{}, false, errors.Join(errs...)
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.duties, b.fetchedSlot = duties, slot
+	duty, ok = duties[slot]
+	return duty, ok, nil
+}
+
+// blobsBundle - blobs of blob transactions of block, it's part of submission since Deneb
+func blobsBundle(block *types.Block) (*engine_types.BlobsBundleV1, error) {
+	bundle := &engine_types.BlobsBundleV1{Commitments: []hexutility.Bytes{}, Proofs: []hexutility.Bytes{}, Blobs: []hexutility.Bytes{}}
+	for _, txn := range block.Transactions() {
+		if txn.Type() != types.BlobTxType {
+			continue
+		}
+		blobTx, ok := txn.(*types.BlobTxWrapper)
+		if !ok {
+			return nil, fmt.Errorf("blob transaction %x without blobs", txn.Hash())
+		}
+		for i := range blobTx.Blobs {
+			bundle.Commitments = append(bundle.Commitments, blobTx.Commitments[i][:])
+			bundle.Proofs = append(bundle.Proofs, blobTx.Proofs[i][:])
+			bundle.Blobs = append(bundle.Blobs, blobTx.Blobs[i][:])
+		}
+	}
+	return bundle, nil
+}
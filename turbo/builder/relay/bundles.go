@@ -0,0 +1,179 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	mapset "github.com/deckarep/golang-set/v2"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+	types2 "github.com/erigontech/erigon-lib/types"
+
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/eth/stagedsync"
+)
+
+// Bundle - transactions which are included at the top of block all together and in given order, or not at all.
+// Senders of transactions must be set.
+type Bundle struct {
+	Txs               types.Transactions
+	BlockNumber       uint64 // block the bundle is for, 0 - any block
+	MinTimestamp      uint64 // 0 - no lower bound
+	MaxTimestamp      uint64 // 0 - no upper bound
+	RevertingTxHashes []libcommon.Hash
+	seq               uint64
+}
+
+// Hash - keccak of concatenated hashes of transactions
+func (b *Bundle) Hash() libcommon.Hash {
+	hashes := make([]byte, 0, len(b.Txs)*length.Hash)
+	for _, txn := range b.Txs {
+		h := txn.Hash()
+		hashes = append(hashes, h[:]...)
+	}
+	return crypto.Keccak256Hash(hashes)
+}
+
+func (b *Bundle) fits(number, timestamp uint64) bool {
+	return (b.BlockNumber == 0 || b.BlockNumber == number) &&
+		(b.MinTimestamp == 0 || timestamp >= b.MinTimestamp) &&
+		(b.MaxTimestamp == 0 || timestamp <= b.MaxTimestamp)
+}
+
+// included - whether all transactions of bundle are in block and succeeded, except ones allowed to revert
+func (b *Bundle) included(block *types.BlockWithReceipts) bool {
+	positions := make(map[libcommon.Hash]int, block.Block.Transactions().Len())
+	for i, txn := range block.Block.Transactions() {
+		positions[txn.Hash()] = i
+	}
+	for _, txn := range b.Txs {
+		i, ok := positions[txn.Hash()]
+		if !ok {
+			return false
+		}
+		if block.Receipts[i].Status != types.ReceiptStatusSuccessful && !b.mayRevert(txn.Hash()) {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *Bundle) mayRevert(hash libcommon.Hash) bool {
+	for _, h := range b.RevertingTxHashes {
+		if h == hash {
+			return true
+		}
+	}
+	return false
+}
+
+// BundlePool - bundles submitted by eth_sendBundle, kept until their block is passed
+type BundlePool struct {
+	lock    sync.Mutex
+	bundles map[libcommon.Hash]*Bundle
+	seq     uint64
+}
+
+func NewBundlePool() *BundlePool {
+	return &BundlePool{bundles: map[libcommon.Hash]*Bundle{}}
+}
+
+func (p *BundlePool) Add(b *Bundle) libcommon.Hash {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	hash := b.Hash()
+	if _, ok := p.bundles[hash]; !ok {
+		p.seq++
+		b.seq = p.seq
+		p.bundles[hash] = b
+	}
+	return hash
+}
+
+// ForBlock - bundles for block in order of submission
+func (p *BundlePool) ForBlock(number, timestamp uint64) []*Bundle {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	var res []*Bundle
+	for _, b := range p.bundles {
+		if b.fits(number, timestamp) {
+			res = append(res, b)
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].seq < res[j].seq })
+	return res
+}
+
+// Prune - removes bundles for blocks before number and ones which are expired by timestamp
+func (p *BundlePool) Prune(number, timestamp uint64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for hash, b := range p.bundles {
+		if (b.BlockNumber != 0 && b.BlockNumber < number) || (b.MaxTimestamp != 0 && b.MaxTimestamp < timestamp) {
+			delete(p.bundles, hash)
+		}
+	}
+}
+
+func (p *BundlePool) Len() int {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return len(p.bundles)
+}
+
+// bundleTxPool - txpool for mining, which yields transactions of bundles before the ones of wrapped pool
+type bundleTxPool struct {
+	stagedsync.TxPoolForMining
+	bundles []*Bundle
+}
+
+func (p *bundleTxPool) YieldBest(n uint16, txs *types2.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte]) (bool, int, error) {
+	var own types2.TxsRlp
+	for _, b := range p.bundles {
+		for _, txn := range b.Txs {
+			if len(own.Txs) >= int(n) {
+				break
+			}
+			if toSkip.Contains(txn.Hash()) {
+				continue
+			}
+			var buf bytes.Buffer
+			if err := txn.MarshalBinary(&buf); err != nil {
+				return false, 0, err
+			}
+			sender, _ := txn.GetSender()
+			own.Resize(uint(len(own.Txs) + 1))
+			own.Txs[len(own.Txs)-1] = buf.Bytes()
+			copy(own.Senders.At(len(own.Txs)-1), sender[:])
+			toSkip.Add(txn.Hash())
+		}
+	}
+	onTime, count, err := p.TxPoolForMining.YieldBest(n-uint16(len(own.Txs)), txs, tx, onTopOf, availableGas, availableBlobGas, toSkip)
+	if err != nil || len(own.Txs) == 0 {
+		return onTime, count, err
+	}
+	txs.Txs = append(own.Txs, txs.Txs...)
+	txs.Senders = append(own.Senders, txs.Senders...)
+	txs.IsLocal = append(own.IsLocal, txs.IsLocal...)
+	return onTime, count + len(own.Txs), nil
+}
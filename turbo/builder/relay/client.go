@@ -0,0 +1,104 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Client - builder side of relay API.
+// ref: https://flashbots.github.io/relay-specs/#/Builder
+type Client struct {
+	httpClient *http.Client
+	url        *url.URL
+}
+
+func NewClient(baseUrl string) (*Client, error) {
+	u, err := url.Parse(baseUrl)
+	if err != nil {
+		return nil, fmt.Errorf("relay url %q: %w", baseUrl, err)
+	}
+	return &Client{httpClient: &http.Client{Timeout: 5 * time.Second}, url: u}, nil
+}
+
+func (c *Client) String() string { return c.url.Host }
+
+// ProposerDuties - proposers of current and next epoch registered with relay
+func (c *Client) ProposerDuties(ctx context.Context) ([]ProposerDuty, error) {
+	// https://flashbots.github.io/relay-specs/#/Builder/getValidators
+	var duties []ProposerDuty
+	if err := c.call(ctx, http.MethodGet, "/relay/v1/builder/validators", nil, nil, &duties); err != nil {
+		return nil, err
+	}
+	return duties, nil
+}
+
+// SubmitBlock - submits signed bid with its payload
+func (c *Client) SubmitBlock(ctx context.Context, req *SubmitBlockRequest) error {
+	// https://flashbots.github.io/relay-specs/#/Builder/submitBlock
+	payload, err := json.Marshal(req)
+	if err != nil {
+		return err
+	}
+	headers := map[string]string{"Eth-Consensus-Version": req.ExecutionPayload.Version().String()}
+	return c.call(ctx, http.MethodPost, "/relay/v1/builder/blocks", headers, payload, nil)
+}
+
+func (c *Client) call(ctx context.Context, method, path string, headers map[string]string, payload []byte, result any) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	request, err := http.NewRequestWithContext(ctx, method, c.url.JoinPath(path).String(), body)
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		request.Header.Set(k, v)
+	}
+	response, err := c.httpClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	content, err := io.ReadAll(response.Body)
+	if err != nil {
+		return err
+	}
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		// relay returns {"code": ..., "message": ...}
+		var relayErr struct {
+			Message string `json:"message"`
+		}
+		if json.Unmarshal(content, &relayErr) == nil && relayErr.Message != "" {
+			return fmt.Errorf("relay %s: status code %d: %s", c, response.StatusCode, relayErr.Message)
+		}
+		return fmt.Errorf("relay %s: status code %d", c, response.StatusCode)
+	}
+	if result == nil || len(content) == 0 {
+		return nil
+	}
+	return json.Unmarshal(content, result)
+}
@@ -0,0 +1,213 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	mapset "github.com/deckarep/golang-set/v2"
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+	types2 "github.com/erigontech/erigon-lib/types"
+
+	"github.com/erigontech/erigon/cl/clparams"
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/stagedsync"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+var (
+	proposerFeeRecipient = libcommon.HexToAddress("0xfee")
+	proposerPubkey       = libcommon.Bytes48{0x01, 0x02}
+	tip                  = uint256.NewInt(2_000_000_000)
+)
+
+// emptyTxPool - txpool without transactions, only bundles are mined
+type emptyTxPool struct{}
+
+func (emptyTxPool) YieldBest(n uint16, txs *types2.TxsRlp, tx kv.Tx, onTopOf, availableGas, availableBlobGas uint64, toSkip mapset.Set[[32]byte]) (bool, int, error) {
+	return true, 0, nil
+}
+
+// fakeBuild - builds block of transactions of txPool without execution: transactions of reverted fail
+func fakeBuild(t *testing.T, parent *types.Block, reverted map[libcommon.Hash]bool) BuildFunc {
+	return func(param *core.BlockBuilderParameters, gasLimit uint64, txPool stagedsync.TxPoolForMining, interrupt *int32) (*types.BlockWithReceipts, error) {
+		var rlps types2.TxsRlp
+		if _, _, err := txPool.YieldBest(100, &rlps, nil, 0, gasLimit, 0, mapset.NewSet[[32]byte]()); err != nil {
+			return nil, err
+		}
+		header := &types.Header{
+			ParentHash: param.ParentHash,
+			Number:     new(big.Int).Add(parent.Number(), big.NewInt(1)),
+			Time:       param.Timestamp,
+			GasLimit:   gasLimit,
+			Coinbase:   param.SuggestedFeeRecipient,
+			BaseFee:    big.NewInt(1),
+			Difficulty: big.NewInt(0),
+		}
+		var txs types.Transactions
+		var receipts types.Receipts
+		for _, enc := range rlps.Txs {
+			txn, err := types.DecodeTransaction(enc)
+			require.NoError(t, err)
+			receipt := &types.Receipt{Status: types.ReceiptStatusSuccessful, GasUsed: 21_000, TxHash: txn.Hash()}
+			if reverted[txn.Hash()] {
+				receipt.Status = types.ReceiptStatusFailed
+			}
+			header.GasUsed += receipt.GasUsed
+			txs, receipts = append(txs, txn), append(receipts, receipt)
+		}
+		return &types.BlockWithReceipts{Block: types.NewBlock(header, txs, nil, receipts, nil, nil), Receipts: receipts}, nil
+	}
+}
+
+func newBundle(t *testing.T, m *mock.MockSentry, nonce uint64) *Bundle {
+	txn, err := types.SignTx(&types.DynamicFeeTransaction{
+		CommonTx: types.CommonTx{Nonce: nonce, Gas: 21_000, To: &proposerFeeRecipient, Value: uint256.NewInt(1)},
+		ChainID:  uint256.MustFromBig(m.ChainConfig.ChainID),
+		Tip:      tip,
+		FeeCap:   uint256.NewInt(10_000_000_000),
+	}, *types.LatestSignerForChainID(m.ChainConfig.ChainID), m.Key)
+	require.NoError(t, err)
+	txn.SetSender(m.Address)
+	return &Bundle{Txs: types.Transactions{txn}}
+}
+
+func builderConfig(relay string, genesisTime uint64) Config {
+	secretKey := make([]byte, 32)
+	secretKey[31] = 7
+	return Config{
+		Relays:       []string{relay},
+		SecretKey:    secretKey,
+		Interval:     time.Hour,
+		GenesisTime:  genesisTime,
+		BeaconConfig: &clparams.MainnetBeaconConfig,
+	}
+}
+
+func TestSubmitBlock(t *testing.T) {
+	m := mock.Mock(t)
+	const slot = 100
+	timestamp := uint64(time.Now().Add(3 * time.Second).Unix())
+	genesisTime := timestamp - slot*clparams.MainnetBeaconConfig.SecondsPerSlot
+
+	type submission struct {
+		version string
+		req     struct {
+			Message          *BidTrace         `json:"message"`
+			ExecutionPayload map[string]any    `json:"execution_payload"`
+			Signature        libcommon.Bytes96 `json:"signature"`
+		}
+	}
+	submissions := make(chan submission, 1)
+	relay := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/relay/v1/builder/validators":
+			duties := []ProposerDuty{{Slot: slot, ValidatorIndex: 5, Entry: &cltypes.ValidatorRegistration{
+				Message: cltypes.ValidatorRegistrationMessage{FeeRecipient: proposerFeeRecipient, GasLimit: "30000000", Timestamp: "1", PubKey: proposerPubkey},
+			}}}
+			require.NoError(t, json.NewEncoder(w).Encode(duties))
+		case "/relay/v1/builder/blocks":
+			var s submission
+			s.version = r.Header.Get("Eth-Consensus-Version")
+			require.NoError(t, json.NewDecoder(r.Body).Decode(&s.req))
+			select {
+			case submissions <- s:
+			default:
+			}
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer relay.Close()
+
+	b, err := New(context.Background(), builderConfig(relay.URL, genesisTime), m.DB, fakeBuild(t, m.Genesis, nil), emptyTxPool{}, log.New())
+	require.NoError(t, err)
+	bundle := newBundle(t, m, 0)
+	b.Bundles().Add(bundle)
+
+	b.OnPayloadAttributes(&core.BlockBuilderParameters{ParentHash: m.Genesis.Hash(), Timestamp: timestamp})
+	var s submission
+	select {
+	case s = <-submissions:
+	case <-time.After(3 * time.Second):
+		t.Fatal("block is not submitted")
+	}
+
+	bid := s.req.Message
+	require.Equal(t, "bellatrix", s.version)
+	require.Equal(t, uint64(slot), bid.Slot)
+	require.Equal(t, m.Genesis.Hash(), bid.ParentHash)
+	require.Equal(t, proposerFeeRecipient, bid.ProposerFeeRecipient)
+	require.Equal(t, proposerPubkey, bid.ProposerPubkey)
+	require.Equal(t, uint64(30_000_000), bid.GasLimit)
+	require.Equal(t, uint64(21_000), bid.GasUsed)
+	require.Equal(t, new(uint256.Int).Mul(tip, uint256.NewInt(21_000)), bid.Value)
+	require.Equal(t, bid.BlockHash.Hex(), s.req.ExecutionPayload["block_hash"])
+	require.Equal(t, proposerFeeRecipient.Hex(), libcommon.HexToAddress(s.req.ExecutionPayload["fee_recipient"].(string)).Hex())
+
+	ok, err := b.signer.Verify(bid, s.req.Signature, b.signer.Pubkey())
+	require.NoError(t, err)
+	require.True(t, ok)
+	bid.Value = uint256.NewInt(1)
+	ok, err = b.signer.Verify(bid, s.req.Signature, b.signer.Pubkey())
+	require.NoError(t, err)
+	require.False(t, ok)
+}
+
+func TestBundles(t *testing.T) {
+	m := mock.Mock(t)
+	first, reverting, allowed := newBundle(t, m, 0), newBundle(t, m, 1), newBundle(t, m, 2)
+	allowed.RevertingTxHashes = []libcommon.Hash{allowed.Txs[0].Hash()}
+	reverted := map[libcommon.Hash]bool{reverting.Txs[0].Hash(): true, allowed.Txs[0].Hash(): true}
+	expired := newBundle(t, m, 3)
+	expired.BlockNumber = 5
+	future := newBundle(t, m, 4)
+	future.MinTimestamp = 1_000
+
+	b, err := New(context.Background(), builderConfig("http://127.0.0.1:1", 0), m.DB, fakeBuild(t, m.Genesis, reverted), emptyTxPool{}, log.New())
+	require.NoError(t, err)
+	for _, bundle := range []*Bundle{first, reverting, allowed, expired, future} {
+		b.Bundles().Add(bundle)
+	}
+	require.Equal(t, first.Hash(), b.Bundles().Add(first))
+	require.Equal(t, 5, b.Bundles().Len())
+
+	var interrupt int32
+	result, value, err := b.buildBlock(&core.BlockBuilderParameters{ParentHash: m.Genesis.Hash(), Timestamp: 100}, 30_000_000, &interrupt)
+	require.NoError(t, err)
+	txs := result.Block.Transactions()
+	require.Len(t, txs, 2)
+	require.Equal(t, first.Txs[0].Hash(), txs[0].Hash())
+	require.Equal(t, allowed.Txs[0].Hash(), txs[1].Hash())
+	require.Equal(t, new(uint256.Int).Mul(tip, uint256.NewInt(2*21_000)), value)
+
+	b.Bundles().Prune(6, 100)
+	require.Equal(t, 4, b.Bundles().Len())
+}
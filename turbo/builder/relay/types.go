@@ -0,0 +1,167 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+
+	"github.com/Giulio2002/bls"
+	"github.com/holiman/uint256"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/cl/cltypes"
+	"github.com/erigontech/erigon/cl/fork"
+	"github.com/erigontech/erigon/cl/merkle_tree"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+)
+
+// BidTrace - bid of builder for slot, signed by builder's BLS key.
+// ref: https://flashbots.github.io/relay-specs/#/Builder/submitBlock
+type BidTrace struct {
+	Slot                 uint64
+	ParentHash           libcommon.Hash
+	BlockHash            libcommon.Hash
+	BuilderPubkey        libcommon.Bytes48
+	ProposerPubkey       libcommon.Bytes48
+	ProposerFeeRecipient libcommon.Address
+	GasLimit             uint64
+	GasUsed              uint64
+	Value                *uint256.Int
+}
+
+type bidTraceJSON struct {
+	Slot                 uint64            `json:"slot,string"`
+	ParentHash           libcommon.Hash    `json:"parent_hash"`
+	BlockHash            libcommon.Hash    `json:"block_hash"`
+	BuilderPubkey        libcommon.Bytes48 `json:"builder_pubkey"`
+	ProposerPubkey       libcommon.Bytes48 `json:"proposer_pubkey"`
+	ProposerFeeRecipient libcommon.Address `json:"proposer_fee_recipient"`
+	GasLimit             uint64            `json:"gas_limit,string"`
+	GasUsed              uint64            `json:"gas_used,string"`
+	Value                string            `json:"value"`
+}
+
+func (b *BidTrace) MarshalJSON() ([]byte, error) {
+	return json.Marshal(bidTraceJSON{
+		Slot:                 b.Slot,
+		ParentHash:           b.ParentHash,
+		BlockHash:            b.BlockHash,
+		BuilderPubkey:        b.BuilderPubkey,
+		ProposerPubkey:       b.ProposerPubkey,
+		ProposerFeeRecipient: b.ProposerFeeRecipient,
+		GasLimit:             b.GasLimit,
+		GasUsed:              b.GasUsed,
+		Value:                b.Value.Dec(),
+	})
+}
+
+func (b *BidTrace) UnmarshalJSON(data []byte) error {
+	var aux bidTraceJSON
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+	value, err := uint256.FromDecimal(aux.Value)
+	if err != nil {
+		return fmt.Errorf("bid value %q: %w", aux.Value, err)
+	}
+	*b = BidTrace{
+		Slot:                 aux.Slot,
+		ParentHash:           aux.ParentHash,
+		BlockHash:            aux.BlockHash,
+		BuilderPubkey:        aux.BuilderPubkey,
+		ProposerPubkey:       aux.ProposerPubkey,
+		ProposerFeeRecipient: aux.ProposerFeeRecipient,
+		GasLimit:             aux.GasLimit,
+		GasUsed:              aux.GasUsed,
+		Value:                value,
+	}
+	return nil
+}
+
+func (b *BidTrace) HashSSZ() ([32]byte, error) {
+	value := b.Value.Bytes32() // ssz uint256 is little-endian
+	for i, j := 0, len(value)-1; i < j; i, j = i+1, j-1 {
+		value[i], value[j] = value[j], value[i]
+	}
+	return merkle_tree.HashTreeRoot(b.Slot, b.ParentHash[:], b.BlockHash[:], b.BuilderPubkey[:], b.ProposerPubkey[:],
+		b.ProposerFeeRecipient[:], b.GasLimit, b.GasUsed, value[:])
+}
+
+// SubmitBlockRequest - body of block submission to relay. BlobsBundle is set since Deneb.
+type SubmitBlockRequest struct {
+	Message          *BidTrace                   `json:"message"`
+	ExecutionPayload *cltypes.Eth1Block          `json:"execution_payload"`
+	BlobsBundle      *engine_types.BlobsBundleV1 `json:"blobs_bundle,omitempty"`
+	Signature        libcommon.Bytes96           `json:"signature"`
+}
+
+// ProposerDuty - proposer of slot registered with relay, its fee recipient and gas limit preference
+type ProposerDuty struct {
+	Slot           uint64                         `json:"slot,string"`
+	ValidatorIndex uint64                         `json:"validator_index,string"`
+	Entry          *cltypes.ValidatorRegistration `json:"entry"`
+}
+
+func (d *ProposerDuty) GasLimit() (uint64, error) {
+	return strconv.ParseUint(d.Entry.Message.GasLimit, 10, 64)
+}
+
+// Signer - signs bids by builder's BLS key in application builder domain
+type Signer struct {
+	key    *bls.PrivateKey
+	pubkey libcommon.Bytes48
+	domain []byte
+}
+
+// NewSigner - signer by BLS secret key. Domain of builder API doesn't depend on fork: it's computed from genesis fork version.
+func NewSigner(secretKey []byte, domainType libcommon.Bytes4, genesisForkVersion libcommon.Bytes4) (*Signer, error) {
+	key, err := bls.NewPrivateKeyFromBytes(secretKey)
+	if err != nil {
+		return nil, fmt.Errorf("builder secret key: %w", err)
+	}
+	domain, err := fork.ComputeDomain(domainType[:], genesisForkVersion, [32]byte{})
+	if err != nil {
+		return nil, err
+	}
+	s := &Signer{key: key, domain: domain}
+	copy(s.pubkey[:], bls.CompressPublicKey(key.PublicKey()))
+	return s, nil
+}
+
+func (s *Signer) Pubkey() libcommon.Bytes48 { return s.pubkey }
+
+func (s *Signer) Sign(bid *BidTrace) (libcommon.Bytes96, error) {
+	root, err := fork.ComputeSigningRoot(bid, s.domain)
+	if err != nil {
+		return libcommon.Bytes96{}, err
+	}
+	var sig libcommon.Bytes96
+	copy(sig[:], s.key.Sign(root[:]).Bytes())
+	return sig, nil
+}
+
+// Verify - whether bid is signed by pubkey, it's what relay checks
+func (s *Signer) Verify(bid *BidTrace, sig libcommon.Bytes96, pubkey libcommon.Bytes48) (bool, error) {
+	root, err := fork.ComputeSigningRoot(bid, s.domain)
+	if err != nil {
+		return false, err
+	}
+	return bls.Verify(sig[:], root[:], pubkey[:])
+}
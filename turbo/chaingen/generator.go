@@ -0,0 +1,347 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package chaingen generates deterministic chains of any length from a scenario:
+// ETH and ERC-20 traffic, contract deployments, self-destructs, blob transactions, withdrawals and reorgs.
+// Blocks are made by core.GenerateChain batch by batch, every batch is inserted before the next one is generated.
+package chaingen
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"math/rand"
+	"strings"
+	"time"
+
+	"github.com/holiman/uint256"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/crypto/kzg"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/accounts/abi"
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/consensus/ethash"
+	"github.com/erigontech/erigon/consensus/merge"
+	"github.com/erigontech/erigon/consensus/misc"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/jsonrpc/contracts"
+)
+
+var (
+	coinbase     = libcommon.HexToAddress("0xc0ffee")
+	sideCoinbase = libcommon.HexToAddress("0x51de")
+	tip          = uint256.NewInt(params.GWei)
+	// initcode which writes storage slot and self-destructs: PUSH1 1, PUSH1 0, SSTORE, CALLER, SELFDESTRUCT
+	selfDestructCode = libcommon.FromHex("0x600160005533ff")
+)
+
+// InsertFunc - inserts blocks and makes the last one head of the chain, chain may be a side chain of current head
+type InsertFunc func(chain *core.ChainPack) error
+
+type token struct {
+	address  libcommon.Address
+	balances map[int]uint64 // by account
+	holders  []int          // accounts with non-zero balance
+}
+
+// Generator - generates chain of a scenario. Generation state is in memory, so chain is generated from genesis in one run.
+type Generator struct {
+	scenario *Scenario
+	config   *chain.Config
+	engine   consensus.Engine
+	signer   *types.Signer
+	tokenABI abi.ABI
+	keys     []*ecdsa.PrivateKey
+	accounts []libcommon.Address
+	rand     *rand.Rand
+
+	tokens      []*token
+	withdrawals uint64 // index of next withdrawal
+}
+
+func New(s *Scenario) (*Generator, error) {
+	if err := s.Validate(); err != nil {
+		return nil, err
+	}
+	config, err := s.ChainConfig()
+	if err != nil {
+		return nil, err
+	}
+	tokenABI, err := abi.JSON(strings.NewReader(contracts.TokenABI))
+	if err != nil {
+		return nil, err
+	}
+	g := &Generator{
+		scenario: s,
+		config:   config,
+		engine:   merge.New(ethash.NewFaker()),
+		signer:   types.LatestSignerForChainID(config.ChainID),
+		tokenABI: tokenABI,
+		rand:     rand.New(rand.NewSource(s.Seed)),
+	}
+	for i := 0; i < s.Accounts; i++ {
+		key, err := accountKey(s.Seed, i)
+		if err != nil {
+			return nil, err
+		}
+		g.keys = append(g.keys, key)
+		g.accounts = append(g.accounts, crypto.PubkeyToAddress(key.PublicKey))
+	}
+	return g, nil
+}
+
+// accountKey - key of i-th account: keccak256(seed, i)
+func accountKey(seed int64, i int) (*ecdsa.PrivateKey, error) {
+	var buf [16]byte
+	binary.BigEndian.PutUint64(buf[:8], uint64(seed))
+	binary.BigEndian.PutUint64(buf[8:], uint64(i))
+	return crypto.ToECDSA(crypto.Keccak256(buf[:]))
+}
+
+func (g *Generator) ChainConfig() *chain.Config { return g.config }
+
+// Engine - consensus engine which blocks are generated and verified by
+func (g *Generator) Engine() consensus.Engine { return g.engine }
+
+// Accounts - prefunded accounts which send transactions, their keys are derived from seed
+func (g *Generator) Accounts() []libcommon.Address { return g.accounts }
+
+func (g *Generator) Genesis() *types.Genesis {
+	balance := new(big.Int).Mul(new(big.Int).SetUint64(g.scenario.Balance), big.NewInt(params.Ether))
+	alloc := make(types.GenesisAlloc, len(g.accounts))
+	for _, addr := range g.accounts {
+		alloc[addr] = types.GenesisAccount{Balance: balance}
+	}
+	return &types.Genesis{
+		Config:     g.config,
+		GasLimit:   g.scenario.GasLimit,
+		Difficulty: big.NewInt(0),
+		Alloc:      alloc,
+	}
+}
+
+// Run - generates chain of the scenario on top of genesis and inserts it by batches.
+// Generated blocks are read from state of db, so it must be the db which blocks are inserted into.
+func (g *Generator) Run(ctx context.Context, db kv.RwDB, genesis *types.Block, insert InsertFunc, logger log.Logger) error {
+	s := g.scenario
+	head := genesis
+	logEvery := time.NewTicker(30 * time.Second)
+	defer logEvery.Stop()
+	started, lastBlock := time.Now(), uint64(0)
+	for head.NumberU64() < s.Blocks {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		number := head.NumberU64()
+		if s.ReorgEvery > 0 && number > 0 && number%s.ReorgEvery == 0 {
+			n := min(s.ReorgDepth, s.Blocks-number)
+			canonical, err := core.GenerateChain(g.config, head, g.engine, db, int(n), g.generateBlock)
+			if err != nil {
+				return err
+			}
+			side, err := g.GenerateSideChain(db, head, int(n))
+			if err != nil {
+				return err
+			}
+			if err := insert(side); err != nil {
+				return fmt.Errorf("side chain of block %d: %w", number, err)
+			}
+			if err := insert(canonical); err != nil {
+				return fmt.Errorf("reorg at block %d: %w", number, err)
+			}
+			logger.Debug("[chaingen] reorg", "block", number, "depth", n)
+			head = canonical.TopBlock
+			continue
+		}
+
+		n := min(s.Batch, s.Blocks-number)
+		if s.ReorgEvery > 0 {
+			n = min(n, s.ReorgEvery-number%s.ReorgEvery)
+		}
+		batch, err := core.GenerateChain(g.config, head, g.engine, db, int(n), g.generateBlock)
+		if err != nil {
+			return err
+		}
+		if err := insert(batch); err != nil {
+			return fmt.Errorf("blocks %d-%d: %w", number+1, batch.TopBlock.NumberU64(), err)
+		}
+		head = batch.TopBlock
+
+		select {
+		case <-logEvery.C:
+			speed := float64(head.NumberU64()-lastBlock) / time.Since(started).Seconds()
+			started, lastBlock = time.Now(), head.NumberU64()
+			logger.Info("[chaingen] progress", "block", head.NumberU64(), "of", s.Blocks, "blk/s", fmt.Sprintf("%.1f", speed), "tokens", len(g.tokens))
+		default:
+		}
+	}
+	logger.Info("[chaingen] generated", "blocks", head.NumberU64(), "head", head.Hash(), "tokens", len(g.tokens))
+	return nil
+}
+
+// GenerateSideChain - n blocks on top of parent with ETH transfers only, they differ from canonical ones by coinbase.
+// It doesn't change generation state, so canonical chain is the same with and without side chains.
+func (g *Generator) GenerateSideChain(db kv.RwDB, parent *types.Block, n int) (*core.ChainPack, error) {
+	rnd := rand.New(rand.NewSource(g.scenario.Seed ^ int64(parent.NumberU64())))
+	return core.GenerateChain(g.config, parent, g.engine, db, n, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(sideCoinbase)
+		b.SetExtra([]byte("side"))
+		for j := 0; j < max(g.scenario.Transfers, 1); j++ {
+			from := rnd.Intn(len(g.accounts))
+			g.addTx(b, from, &types.DynamicFeeTransaction{CommonTx: types.CommonTx{
+				Gas: transferGas, To: &g.accounts[rnd.Intn(len(g.accounts))], Value: uint256.NewInt(1 + uint64(rnd.Intn(params.GWei))),
+			}})
+		}
+	})
+}
+
+// generateBlock - traffic of canonical block, it uses and changes generation state
+func (g *Generator) generateBlock(i int, b *core.BlockGen) {
+	s := g.scenario
+	number := b.Number().Uint64()
+	b.SetCoinbase(coinbase)
+
+	if (s.TokenTransfers > 0 && len(g.tokens) == 0) || (s.DeployEvery > 0 && (number-1)%s.DeployEvery == 0) {
+		g.deployToken(b)
+	}
+	for j := 0; j < s.Transfers; j++ {
+		to := g.accounts[g.rand.Intn(len(g.accounts))]
+		if j%2 == 1 {
+			g.rand.Read(to[:]) // new address
+		}
+		g.addTx(b, g.rand.Intn(len(g.accounts)), &types.DynamicFeeTransaction{CommonTx: types.CommonTx{
+			Gas: transferGas, To: &to, Value: uint256.NewInt(1 + uint64(g.rand.Intn(params.GWei))),
+		}})
+	}
+	for j := 0; j < s.TokenTransfers; j++ {
+		g.transferToken(b)
+	}
+	if s.SelfDestructEvery > 0 && number%s.SelfDestructEvery == 0 {
+		g.addTx(b, g.rand.Intn(len(g.accounts)), &types.DynamicFeeTransaction{CommonTx: types.CommonTx{
+			Gas: selfDestructGas, Value: uint256.NewInt(1), Data: selfDestructCode,
+		}})
+	}
+	if s.BlobTxEvery > 0 && number%s.BlobTxEvery == 0 {
+		g.addBlobTx(b)
+	}
+	for j := 0; j < s.WithdrawalsPerBlock; j++ {
+		b.AddWithdrawal(&types.Withdrawal{
+			Index:     g.withdrawals,
+			Validator: uint64(g.rand.Intn(1_000_000)),
+			Address:   g.accounts[g.rand.Intn(len(g.accounts))],
+			Amount:    1 + uint64(g.rand.Intn(params.GWei)), // in GWei
+		})
+		g.withdrawals++
+	}
+}
+
+// addTx - signs transaction of account and adds it to block, txn.Nonce, txn.ChainID and fees are set here
+func (g *Generator) addTx(b *core.BlockGen, from int, txn *types.DynamicFeeTransaction) {
+	g.fillTx(b, from, txn)
+	signed, err := types.SignTx(txn, *g.signer, g.keys[from])
+	if err != nil {
+		panic(err)
+	}
+	b.AddTx(signed)
+}
+
+func (g *Generator) fillTx(b *core.BlockGen, from int, txn *types.DynamicFeeTransaction) {
+	txn.Nonce = b.TxNonce(g.accounts[from])
+	txn.ChainID = uint256.MustFromBig(g.config.ChainID)
+	txn.Tip = tip
+	txn.FeeCap = new(uint256.Int).Mul(uint256.MustFromBig(b.GetHeader().BaseFee), uint256.NewInt(2))
+	txn.FeeCap.Add(txn.FeeCap, tip)
+}
+
+func (g *Generator) deployToken(b *core.BlockGen) {
+	minter := g.rand.Intn(len(g.accounts))
+	args, err := g.tokenABI.Pack("", g.accounts[minter])
+	if err != nil {
+		panic(err)
+	}
+	address := crypto.CreateAddress(g.accounts[minter], b.TxNonce(g.accounts[minter]))
+	g.addTx(b, minter, &types.DynamicFeeTransaction{CommonTx: types.CommonTx{
+		Gas: tokenDeployGas, Value: new(uint256.Int), Data: append(libcommon.FromHex(contracts.TokenBin), args...),
+	}})
+
+	const supply = 1 << 62
+	mint, err := g.tokenABI.Pack("mint", g.accounts[minter], new(big.Int).SetUint64(supply))
+	if err != nil {
+		panic(err)
+	}
+	g.addTx(b, minter, &types.DynamicFeeTransaction{CommonTx: types.CommonTx{
+		Gas: tokenMintGas, To: &address, Value: new(uint256.Int), Data: mint,
+	}})
+	g.tokens = append(g.tokens, &token{address: address, balances: map[int]uint64{minter: supply}, holders: []int{minter}})
+}
+
+func (g *Generator) transferToken(b *core.BlockGen) {
+	t := g.tokens[g.rand.Intn(len(g.tokens))]
+	h := g.rand.Intn(len(t.holders))
+	from, to := t.holders[h], g.rand.Intn(len(g.accounts))
+	amount := min(1+uint64(g.rand.Intn(1_000_000)), t.balances[from])
+	data, err := g.tokenABI.Pack("transfer", g.accounts[to], new(big.Int).SetUint64(amount))
+	if err != nil {
+		panic(err)
+	}
+	g.addTx(b, from, &types.DynamicFeeTransaction{CommonTx: types.CommonTx{
+		Gas: tokenTransferGas, To: &t.address, Value: new(uint256.Int), Data: data,
+	}})
+
+	if t.balances[from] -= amount; t.balances[from] == 0 {
+		delete(t.balances, from)
+		t.holders[h] = t.holders[len(t.holders)-1]
+		t.holders = t.holders[:len(t.holders)-1]
+	}
+	if t.balances[to] == 0 {
+		t.holders = append(t.holders, to)
+	}
+	t.balances[to] += amount
+}
+
+// addBlobTx - blob transaction without sidecar: blocks have only versioned hashes of blobs
+func (g *Generator) addBlobTx(b *core.BlockGen) {
+	from := g.rand.Intn(len(g.accounts))
+	txn := &types.BlobTx{DynamicFeeTransaction: types.DynamicFeeTransaction{CommonTx: types.CommonTx{
+		Gas: transferGas, To: &g.accounts[g.rand.Intn(len(g.accounts))], Value: new(uint256.Int),
+	}}}
+	g.fillTx(b, from, &txn.DynamicFeeTransaction)
+	blobGasPrice, err := misc.GetBlobGasPrice(g.config, *b.GetHeader().ExcessBlobGas)
+	if err != nil {
+		panic(err)
+	}
+	txn.MaxFeePerBlobGas = new(uint256.Int).Mul(blobGasPrice, uint256.NewInt(2))
+	for j := 0; j < g.scenario.BlobsPerTx; j++ {
+		var hash libcommon.Hash
+		g.rand.Read(hash[:])
+		hash[0] = kzg.BlobCommitmentVersionKZG
+		txn.BlobVersionedHashes = append(txn.BlobVersionedHashes, hash)
+	}
+	signed, err := types.SignTx(txn, *g.signer, g.keys[from])
+	if err != nil {
+		panic(err)
+	}
+	b.AddTx(signed)
+}
@@ -0,0 +1,141 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package chaingen
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+func testScenario() *Scenario {
+	return &Scenario{
+		Seed:                7,
+		Blocks:              30,
+		Batch:               8,
+		Accounts:            5,
+		Transfers:           3,
+		TokenTransfers:      3,
+		DeployEvery:         10,
+		SelfDestructEvery:   5,
+		BlobTxEvery:         4,
+		BlobsPerTx:          2,
+		WithdrawalsPerBlock: 2,
+		ReorgEvery:          12,
+		ReorgDepth:          3,
+	}
+}
+
+// generate - generates scenario into mock, returns it and all inserted chains
+func generate(t *testing.T, s *Scenario) (*mock.MockSentry, []*core.ChainPack) {
+	g, err := New(s)
+	require.NoError(t, err)
+	m := mock.MockWithGenesisEngine(t, g.Genesis(), g.Engine(), false, true)
+	var inserted []*core.ChainPack
+	err = g.Run(context.Background(), m.DB, m.Genesis, func(chain *core.ChainPack) error {
+		inserted = append(inserted, chain)
+		return m.InsertChain(chain)
+	}, log.New())
+	require.NoError(t, err)
+	return m, inserted
+}
+
+func TestGenerate(t *testing.T) {
+	m, inserted := generate(t, testScenario())
+
+	var sides []*types.Block
+	var txTypes = map[byte]int{}
+	var contracts, withdrawals int
+	for _, chain := range inserted {
+		for _, block := range chain.Blocks {
+			if block.Coinbase() == sideCoinbase {
+				sides = append(sides, block)
+				continue
+			}
+			withdrawals += len(block.Withdrawals())
+			for _, txn := range block.Transactions() {
+				txTypes[txn.Type()]++
+				if txn.GetTo() == nil {
+					contracts++
+				}
+			}
+		}
+	}
+	require.Equal(t, 30*2, withdrawals)
+	require.Equal(t, 30/4, txTypes[types.BlobTxType])
+	require.Equal(t, 3+30/5, contracts) // tokens at blocks 1, 11, 21 and self-destructs
+	require.Len(t, sides, 2*3)          // side chains of blocks 12 and 24
+	side := sides[2]
+	require.Equal(t, uint64(15), side.NumberU64())
+
+	err := m.DB.View(context.Background(), func(tx kv.Tx) error {
+		head := rawdb.ReadCurrentHeader(tx)
+		require.Equal(t, uint64(30), head.Number.Uint64())
+		canonical, err := m.BlockReader.CanonicalHash(context.Background(), tx, side.NumberU64())
+		require.NoError(t, err)
+		require.NotEqual(t, side.Hash(), canonical)
+		require.NotNil(t, rawdb.ReadHeader(tx, side.Hash(), side.NumberU64())) // side chain is kept
+
+		block, err := m.BlockReader.BlockByNumber(context.Background(), tx, 30)
+		require.NoError(t, err)
+		require.Len(t, block.Transactions(), 3+3+1) // transfers, token transfers, self-destruct
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestGenerateIsDeterministic(t *testing.T) {
+	scenario := func(seed int64) *Scenario {
+		s := testScenario()
+		s.Seed, s.Blocks, s.ReorgEvery = seed, 12, 6
+		return s
+	}
+	_, first := generate(t, scenario(7))
+	_, second := generate(t, scenario(7))
+	require.Equal(t, len(first), len(second))
+	for i := range first {
+		require.Equal(t, first[i].TopBlock.Hash(), second[i].TopBlock.Hash())
+	}
+
+	_, other := generate(t, scenario(8))
+	require.NotEqual(t, first[len(first)-1].TopBlock.Hash(), other[len(other)-1].TopBlock.Hash())
+}
+
+func TestValidate(t *testing.T) {
+	s := &Scenario{Blocks: 10, Fork: ForkShanghai, BlobTxEvery: 1}
+	require.ErrorContains(t, s.Validate(), "blob transactions need cancun fork")
+
+	s = &Scenario{Blocks: 10, ReorgEvery: 5, ReorgDepth: 5}
+	require.ErrorContains(t, s.Validate(), "reorgDepth")
+
+	s = &Scenario{Blocks: 10, Transfers: 2000}
+	require.ErrorContains(t, s.Validate(), "gasLimit")
+
+	s = &Scenario{Blocks: 10}
+	require.NoError(t, s.Validate())
+	require.Equal(t, ForkCancun, s.Fork)
+	require.Equal(t, uint64(1337), s.ChainID)
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package chaingen
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+
+	"github.com/erigontech/erigon-lib/chain"
+
+	"github.com/erigontech/erigon/params"
+)
+
+const (
+	ForkParis    = "paris"
+	ForkShanghai = "shanghai"
+	ForkCancun   = "cancun"
+)
+
+// Gas limits of generated transactions, they are used to check that traffic of a block fits into it
+const (
+	transferGas      = 21_000
+	tokenTransferGas = 100_000
+	tokenDeployGas   = 500_000
+	tokenMintGas     = 100_000
+	selfDestructGas  = 100_000
+)
+
+// Scenario - what chain is generated, it's read from JSON file.
+// Same scenario always produces same chain: keys of accounts and all random choices are derived from Seed.
+type Scenario struct {
+	Seed     int64  `json:"seed"`
+	ChainID  uint64 `json:"chainId"`  // also network id
+	Fork     string `json:"fork"`     // latest fork: paris, shanghai or cancun. Chain is PoS from genesis
+	Blocks   uint64 `json:"blocks"`   // number of generated blocks
+	Batch    uint64 `json:"batch"`    // blocks are generated and inserted by batches of this size
+	GasLimit uint64 `json:"gasLimit"` // of every block
+	Accounts int    `json:"accounts"` // number of prefunded accounts which send transactions
+	Balance  uint64 `json:"balance"`  // of every account in genesis, in ether
+
+	Transfers           int    `json:"transfers"`           // ETH transfers per block, half of them to new addresses
+	TokenTransfers      int    `json:"tokenTransfers"`      // ERC-20 transfers per block
+	DeployEvery         uint64 `json:"deployEvery"`         // new ERC-20 contract every N blocks, 0 - only one at block 1 if there are token transfers
+	SelfDestructEvery   uint64 `json:"selfDestructEvery"`   // contract which is created and self-destructed in same transaction every N blocks
+	BlobTxEvery         uint64 `json:"blobTxEvery"`         // blob transaction every N blocks, needs cancun
+	BlobsPerTx          int    `json:"blobsPerTx"`          // blobs of every blob transaction
+	WithdrawalsPerBlock int    `json:"withdrawalsPerBlock"` // needs shanghai
+	ReorgEvery          uint64 `json:"reorgEvery"`          // every N blocks next ReorgDepth blocks are inserted as a side chain first and then replaced by canonical ones
+	ReorgDepth          uint64 `json:"reorgDepth"`
+}
+
+// ReadScenario - reads scenario file, missing fields are set to defaults
+func ReadScenario(file string) (*Scenario, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	s := &Scenario{}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(s); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", file, err)
+	}
+	if err := s.Validate(); err != nil {
+		return nil, fmt.Errorf("scenario %s: %w", file, err)
+	}
+	return s, nil
+}
+
+func (s *Scenario) setDefaults() {
+	if s.ChainID == 0 {
+		s.ChainID = 1337
+	}
+	if s.Fork == "" {
+		s.Fork = ForkCancun
+	}
+	if s.Batch == 0 {
+		s.Batch = 1_000
+	}
+	if s.GasLimit == 0 {
+		s.GasLimit = 30_000_000
+	}
+	if s.Accounts == 0 {
+		s.Accounts = 100
+	}
+	if s.Balance == 0 {
+		s.Balance = 1_000_000
+	}
+	if s.BlobTxEvery > 0 && s.BlobsPerTx == 0 {
+		s.BlobsPerTx = 1
+	}
+}
+
+// Validate - sets defaults and checks that scenario can be generated
+func (s *Scenario) Validate() error {
+	s.setDefaults()
+	if s.Blocks == 0 {
+		return errors.New("blocks must be set")
+	}
+	config, err := s.ChainConfig()
+	if err != nil {
+		return err
+	}
+	if s.Transfers < 0 || s.TokenTransfers < 0 || s.BlobsPerTx < 0 || s.WithdrawalsPerBlock < 0 || s.Accounts < 0 {
+		return errors.New("counts can't be negative")
+	}
+	if s.BlobTxEvery > 0 {
+		if config.CancunTime == nil {
+			return fmt.Errorf("blob transactions need %s fork", ForkCancun)
+		}
+		if max := config.GetMaxBlobsPerBlock(); uint64(s.BlobsPerTx) > max {
+			return fmt.Errorf("blobsPerTx %d is more than %d blobs per block", s.BlobsPerTx, max)
+		}
+	}
+	if s.WithdrawalsPerBlock > 0 && config.ShanghaiTime == nil {
+		return fmt.Errorf("withdrawals need %s fork", ForkShanghai)
+	}
+	if s.ReorgEvery > 0 && (s.ReorgDepth == 0 || s.ReorgDepth >= s.ReorgEvery) {
+		return fmt.Errorf("reorgDepth must be in [1, reorgEvery), got %d", s.ReorgDepth)
+	}
+	if gas := s.maxBlockGas(); gas > s.GasLimit {
+		return fmt.Errorf("traffic of a block needs up to %d gas, gasLimit is %d", gas, s.GasLimit)
+	}
+	return nil
+}
+
+// maxBlockGas - sum of gas limits of transactions of the busiest block
+func (s *Scenario) maxBlockGas() uint64 {
+	gas := uint64(s.Transfers)*transferGas + uint64(s.TokenTransfers)*tokenTransferGas
+	if s.TokenTransfers > 0 || s.DeployEvery > 0 {
+		gas += tokenDeployGas + tokenMintGas
+	}
+	if s.SelfDestructEvery > 0 {
+		gas += selfDestructGas
+	}
+	if s.BlobTxEvery > 0 {
+		gas += transferGas
+	}
+	return gas
+}
+
+// ChainConfig - all forks up to scenario's one are active from genesis, terminal total difficulty is passed at genesis
+func (s *Scenario) ChainConfig() (*chain.Config, error) {
+	config := *params.AllProtocolChanges
+	config.ChainName = "chaingen"
+	config.ChainID = new(big.Int).SetUint64(s.ChainID)
+	config.TerminalTotalDifficulty = big.NewInt(0)
+	config.TerminalTotalDifficultyPassed = true
+	config.ShanghaiTime, config.CancunTime, config.PragueTime, config.OsakaTime = nil, nil, nil, nil
+	switch s.Fork {
+	case ForkCancun:
+		config.CancunTime = big.NewInt(0)
+		fallthrough
+	case ForkShanghai:
+		config.ShanghaiTime = big.NewInt(0)
+	case ForkParis:
+	default:
+		return nil, fmt.Errorf("unknown fork %q, expected one of %s, %s, %s", s.Fork, ForkParis, ForkShanghai, ForkCancun)
+	}
+	return &config, nil
+}
@@ -45,6 +45,11 @@ var DefaultFlags = []cli.Flag{
 	&utils.TxPoolCommitEveryFlag,
 	&PruneDistanceFlag,
 	&PruneBlocksDistanceFlag,
+	&PruneLogsDistanceFlag,
+	&PruneTracesDistanceFlag,
+	&PruneStateDistanceFlag,
+	&PruneCodeDistanceFlag,
+	&PruneHistoryAllowlistFlag,
 	&PruneModeFlag,
 	&BatchSizeFlag,
 	&BodyCacheLimitFlag,
@@ -67,6 +72,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.HTTPPortFlag,
 	&utils.AuthRpcAddr,
 	&utils.AuthRpcPort,
+	&utils.AuthRpcURL,
+	&utils.AuthRpcIPCPath,
 	&utils.JWTSecretPath,
 	&utils.HttpCompressionFlag,
 	&utils.HTTPCORSDomainFlag,
@@ -87,6 +94,8 @@ var DefaultFlags = []cli.Flag{
 	&utils.RpcGasCapFlag,
 	&utils.RpcBatchLimit,
 	&utils.RpcReturnDataLimit,
+	&utils.RpcResultCacheSizeFlag,
+	&utils.RpcResultCacheDirFlag,
 	&utils.AllowUnprotectedTxs,
 	&utils.RpcMaxGetProofRewindBlockCount,
 	&utils.RPCGlobalTxFeeCapFlag,
@@ -158,6 +167,10 @@ var DefaultFlags = []cli.Flag{
 	&utils.MinerNoVerfiyFlag,
 	&utils.MinerSigningKeyFileFlag,
 	&utils.MinerRecommitIntervalFlag,
+	&utils.BuilderRelaysFlag,
+	&utils.BuilderSecretKeyFlag,
+	&utils.BuilderIntervalFlag,
+	&utils.BuilderGenesisTimeFlag,
 	&utils.SentryAddrFlag,
 	&utils.SentryLogPeerInfoFlag,
 	&utils.DownloaderAddrFlag,
@@ -187,6 +200,9 @@ var DefaultFlags = []cli.Flag{
 
 	&utils.OtsSearchMaxCapFlag,
 
+	&utils.ShadowCommitmentFlag,
+	&utils.ShadowCommitmentConversionFlag,
+
 	&utils.SilkwormExecutionFlag,
 	&utils.SilkwormRpcDaemonFlag,
 	&utils.SilkwormSentryFlag,
@@ -98,6 +98,26 @@ var (
 		Name:  "prune.distance.blocks",
 		Usage: `Keep block history for the latest N blocks (default: everything)`,
 	}
+	PruneLogsDistanceFlag = cli.StringFlag{
+		Name:  "prune.distance.logs",
+		Usage: `Keep receipts, logs and their indices for the latest N blocks or "all", at most as long as state and code history (default: same as state history)`,
+	}
+	PruneTracesDistanceFlag = cli.StringFlag{
+		Name:  "prune.distance.traces",
+		Usage: `Keep call-trace indices for the latest N blocks or "all", at most as long as state and code history (default: same as state history)`,
+	}
+	PruneStateDistanceFlag = cli.StringFlag{
+		Name:  "prune.distance.state",
+		Usage: `Keep accounts and storage history for the latest N blocks or "all" (default: same as state history)`,
+	}
+	PruneCodeDistanceFlag = cli.StringFlag{
+		Name:  "prune.distance.code",
+		Usage: `Keep code history for the latest N blocks or "all" (default: same as state history)`,
+	}
+	PruneHistoryAllowlistFlag = cli.StringFlag{
+		Name:  "prune.history.allowlist",
+		Usage: `Comma separated list of addresses: write accounts, storage and code history only of them, historical reads of other addresses fail. Requires --prune.distance.logs=0 --prune.distance.traces=0 (default: all addresses)`,
+	}
 	ExperimentsFlag = cli.StringFlag{
 		Name: "experiments",
 		Usage: `Enable some experimental stages:
@@ -272,43 +292,7 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *ethconfig.Config, logger log.
 	if cfg.Genesis != nil {
 		chainId = cfg.Genesis.Config.ChainID.Uint64()
 	}
-	// Sanitize prune flag
-	if ctx.String(PruneModeFlag.Name) != "archive" && (ctx.IsSet(PruneBlocksDistanceFlag.Name) || ctx.IsSet(PruneDistanceFlag.Name)) {
-		utils.Fatalf("error: --prune.distance and --prune.distance.blocks are only allowed with --prune.mode=archive")
-	}
-	distance := ctx.Uint64(PruneDistanceFlag.Name)
-	blockDistance := ctx.Uint64(PruneBlocksDistanceFlag.Name)
-
-	if !ctx.IsSet(PruneBlocksDistanceFlag.Name) {
-		blockDistance = math.MaxUint64
-	}
-	if !ctx.IsSet(PruneDistanceFlag.Name) {
-		distance = math.MaxUint64
-	}
-	mode, err := prune.FromCli(
-		chainId,
-		distance,
-		blockDistance,
-		libcommon.CliString2Array(ctx.String(ExperimentsFlag.Name)),
-	)
-	if err != nil {
-		utils.Fatalf(fmt.Sprintf("error while parsing mode: %v", err))
-	}
-	// Full mode prunes all but the latest state
-	if ctx.String(PruneModeFlag.Name) == "full" {
-		mode.Blocks = prune.Distance(math.MaxUint64)
-		mode.History = prune.Distance(0)
-	}
-	// Minimal mode prunes all but the latest state including blocks
-	if ctx.String(PruneModeFlag.Name) == "minimal" {
-		mode.Blocks = prune.Distance(2048) // 2048 is just some blocks to allow reorgs
-		mode.History = prune.Distance(0)
-	}
-
-	if err != nil {
-		utils.Fatalf(fmt.Sprintf("error while parsing mode: %v", err))
-	}
-	cfg.Prune = mode
+	cfg.Prune = PruneModeFromFlags(ctx, chainId)
 	if ctx.String(BatchSizeFlag.Name) != "" {
 		err := cfg.BatchSize.UnmarshalText([]byte(ctx.String(BatchSizeFlag.Name)))
 		if err != nil {
@@ -392,6 +376,68 @@ func ApplyFlagsForEthConfig(ctx *cli.Context, cfg *ethconfig.Config, logger log.
 	}
 }
 
+// PruneModeFromFlags - builds prune mode from --prune.* flags
+func PruneModeFromFlags(ctx *cli.Context, chainId uint64) prune.Mode {
+	// Sanitize prune flag
+	if ctx.String(PruneModeFlag.Name) != "archive" && (ctx.IsSet(PruneBlocksDistanceFlag.Name) || ctx.IsSet(PruneDistanceFlag.Name)) {
+		utils.Fatalf("error: --prune.distance and --prune.distance.blocks are only allowed with --prune.mode=archive")
+	}
+	distance := ctx.Uint64(PruneDistanceFlag.Name)
+	blockDistance := ctx.Uint64(PruneBlocksDistanceFlag.Name)
+
+	if !ctx.IsSet(PruneBlocksDistanceFlag.Name) {
+		blockDistance = math.MaxUint64
+	}
+	if !ctx.IsSet(PruneDistanceFlag.Name) {
+		distance = math.MaxUint64
+	}
+	mode, err := prune.FromCli(
+		chainId,
+		distance,
+		blockDistance,
+		libcommon.CliString2Array(ctx.String(ExperimentsFlag.Name)),
+	)
+	if err != nil {
+		utils.Fatalf(fmt.Sprintf("error while parsing mode: %v", err))
+	}
+	// Full mode prunes all but the latest state
+	if ctx.String(PruneModeFlag.Name) == "full" {
+		mode.Blocks = prune.Distance(math.MaxUint64)
+		mode.History = prune.Distance(0)
+	}
+	// Minimal mode prunes all but the latest state including blocks
+	if ctx.String(PruneModeFlag.Name) == "minimal" {
+		mode.Blocks = prune.Distance(2048) // 2048 is just some blocks to allow reorgs
+		mode.History = prune.Distance(0)
+	}
+	// Parts of history may be kept longer or shorter than the rest of it with any preset
+	for part, flag := range map[prune.HistoryPart]*cli.StringFlag{
+		prune.LogsHistory:       &PruneLogsDistanceFlag,
+		prune.CallTracesHistory: &PruneTracesDistanceFlag,
+		prune.StateHistory:      &PruneStateDistanceFlag,
+		prune.CodeHistory:       &PruneCodeDistanceFlag,
+	} {
+		if !ctx.IsSet(flag.Name) {
+			continue
+		}
+		distance, err := prune.ParseDistance(ctx.String(flag.Name))
+		if err != nil {
+			utils.Fatalf("error: --%s: %v", flag.Name, err)
+		}
+		mode.SetRetention(part, distance)
+	}
+	for _, addr := range libcommon.CliString2Array(ctx.String(PruneHistoryAllowlistFlag.Name)) {
+		if !libcommon.IsHexAddress(addr) {
+			utils.Fatalf("error: --%s: invalid address %s", PruneHistoryAllowlistFlag.Name, addr)
+		}
+		mode.HistoryAllowlist = append(mode.HistoryAllowlist, libcommon.HexToAddress(addr))
+	}
+	if err := mode.Validate(); err != nil {
+		utils.Fatalf("error: --prune.*: %v", err)
+	}
+	return mode
+}
+
 func ApplyFlagsForEthConfigCobra(f *pflag.FlagSet, cfg *ethconfig.Config) {
 	pruneMode := f.String(PruneModeFlag.Name, PruneModeFlag.DefaultText, PruneModeFlag.Usage)
 	pruneBlockDistance := f.Uint64(PruneBlocksDistanceFlag.Name, PruneBlocksDistanceFlag.Value, PruneBlocksDistanceFlag.Usage)
@@ -492,6 +538,8 @@ func setEmbeddedRpcDaemon(ctx *cli.Context, cfg *nodecfg.Config, logger log.Logg
 		HttpPort:                 ctx.Int(utils.HTTPPortFlag.Name),
 		AuthRpcHTTPListenAddress: ctx.String(utils.AuthRpcAddr.Name),
 		AuthRpcPort:              ctx.Int(utils.AuthRpcPort.Name),
+		AuthRpcURL:               ctx.String(utils.AuthRpcURL.Name),
+		AuthRpcIPCPath:           ctx.String(utils.AuthRpcIPCPath.Name),
 		JWTSecretPath:            jwtSecretPath,
 		TraceRequests:            ctx.Bool(utils.HTTPTraceFlag.Name),
 		DebugSingleRequest:       ctx.Bool(utils.HTTPDebugSingleFlag.Name),
@@ -532,6 +580,7 @@ func setEmbeddedRpcDaemon(ctx *cli.Context, cfg *nodecfg.Config, logger log.Logg
 		TraceCompatibility:          ctx.Bool(utils.RpcTraceCompatFlag.Name),
 		BatchLimit:                  ctx.Int(utils.RpcBatchLimit.Name),
 		ReturnDataLimit:             ctx.Int(utils.RpcReturnDataLimit.Name),
+		ResultCacheDir:              ctx.String(utils.RpcResultCacheDirFlag.Name),
 		AllowUnprotectedTxs:         ctx.Bool(utils.AllowUnprotectedTxs.Name),
 		MaxGetProofRewindBlockCount: ctx.Int(utils.RpcMaxGetProofRewindBlockCount.Name),
 
@@ -568,6 +617,10 @@ func setEmbeddedRpcDaemon(ctx *cli.Context, cfg *nodecfg.Config, logger log.Logg
 		utils.Fatalf("Invalid state.cache value provided")
 	}
 
+	if err = c.ResultCacheSize.UnmarshalText([]byte(ctx.String(utils.RpcResultCacheSizeFlag.Name))); err != nil {
+		utils.Fatalf("Invalid %s value provided", utils.RpcResultCacheSizeFlag.Name)
+	}
+
 	/*
 		rootCmd.PersistentFlags().BoolVar(&cfg.GRPCServerEnabled, "grpc", false, "Enable GRPC server")
 		rootCmd.PersistentFlags().StringVar(&cfg.GRPCListenAddress, "grpc.addr", node.DefaultGRPCHost, "GRPC server listening interface")
@@ -322,6 +322,10 @@ func (fv *ForkValidator) validateAndStorePayload(txc wrap.TxContainer, header *t
 		if criticalError != nil {
 			return
 		}
+		// progress includes block with wrong state root: it's updated before the root check
+		if latestValidNumber >= header.Number.Uint64() {
+			latestValidNumber = header.Number.Uint64() - 1
+		}
 		latestValidHash, criticalError = rawdb.ReadCanonicalHash(txc.Tx, latestValidNumber)
 		if criticalError != nil {
 			return
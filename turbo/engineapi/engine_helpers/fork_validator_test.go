@@ -0,0 +1,59 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package engine_helpers
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv/memdb"
+	"github.com/erigontech/erigon-lib/wrap"
+
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+	"github.com/erigontech/erigon/turbo/shards"
+)
+
+func TestValidateAndStorePayloadLatestValidHash(t *testing.T) {
+	_, tx := memdb.NewTestTx(t)
+	parentHash := libcommon.Hash{5}
+	require.NoError(t, rawdb.WriteCanonicalHash(tx, parentHash, 5))
+	header := &types.Header{Number: big.NewInt(6), ParentHash: parentHash}
+
+	// execution stage progress is updated before the state root check, so it includes the invalid block
+	validate := func(txc wrap.TxContainer, header *types.Header, _ *types.RawBody, _ uint64, _ []*types.Header, _ []*types.RawBody, _ *shards.Notifications) error {
+		if err := stages.SaveStageProgress(txc.Tx, stages.Execution, header.Number.Uint64()); err != nil {
+			return err
+		}
+		return fmt.Errorf("%w: wrong trie root", consensus.ErrInvalidBlock)
+	}
+	fv := NewForkValidator(context.Background(), 5, validate, t.TempDir(), nil)
+	status, latestValidHash, validationErr, criticalErr := fv.validateAndStorePayload(wrap.TxContainer{Tx: tx}, header, nil, 0, nil, nil, nil)
+	require.NoError(t, criticalErr)
+	require.ErrorIs(t, validationErr, consensus.ErrInvalidBlock)
+	require.Equal(t, engine_types.InvalidStatus, status)
+	require.Equal(t, parentHash, latestValidHash)
+	require.Equal(t, libcommon.Hash{}, fv.ExtendingForkHeadHash())
+}
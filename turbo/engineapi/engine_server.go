@@ -87,6 +87,7 @@ func NewEngineServer(logger log.Logger, config *chain.Config, executionService e
 		blockDownloader:  blockDownloader,
 		chainRW:          chainRW,
 		proposing:        proposing,
+		test:             test,
 		hd:               hd,
 		caplin:           caplin,
 	}
@@ -0,0 +1,50 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package engineapi
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/gointerfaces/executionproto"
+	"github.com/erigontech/erigon-lib/gointerfaces/typesproto"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+)
+
+// unknownHeadersExecution - execution module which doesn't know any header
+type unknownHeadersExecution struct {
+	executionproto.ExecutionClient
+}
+
+func (unknownHeadersExecution) GetHeaderHashNumber(context.Context, *typesproto.H256, ...grpc.CallOption) (*executionproto.GetHeaderHashNumberResponse, error) {
+	return &executionproto.GetHeaderHashNumberResponse{}, nil
+}
+
+func TestForkChoiceUnknownHeadInTestMode(t *testing.T) {
+	// in test mode there is no block downloader: unknown head isn't downloaded
+	srv := NewEngineServer(log.New(), params.TestChainConfig, unknownHeadersExecution{}, nil, nil, false, true, false)
+	status, err := srv.HandlesForkChoice(context.Background(), "test", &engine_types.ForkChoiceState{HeadHash: libcommon.Hash{1}}, 0)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.SyncingStatus, status.Status)
+}
@@ -0,0 +1,218 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package enginetest
+
+import (
+	"context"
+	"encoding/binary"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	execution "github.com/erigontech/erigon-lib/gointerfaces/executionproto"
+
+	"github.com/erigontech/erigon/consensus/merge"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/turbo/engineapi"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+	"github.com/erigontech/erigon/turbo/execution/eth1/eth1_utils"
+)
+
+// FeeRecipient - suggested fee recipient of payloads built by CLMock
+var FeeRecipient = libcommon.Address{0xc1}
+
+// CLMock - consensus layer which proposes block every slot and follows its own forkchoice, as hive's CL mock does.
+// Safe block is parent of the head, finalized block stays at genesis until it's moved by a test.
+// Only V3 methods (cancun) are used.
+type CLMock struct {
+	tb        testing.TB
+	engine    *engineapi.EngineServer
+	execution execution.ExecutionClient
+
+	Head, Safe, Finalized libcommon.Hash
+	headTime              uint64
+	withdrawalIndex       uint64
+	// beacon roots of payloads, newPayload needs root of payload's beacon block
+	beaconRoots map[libcommon.Hash]libcommon.Hash
+}
+
+func newCLMock(tb testing.TB, engine *engineapi.EngineServer, executionService execution.ExecutionClient, genesis *types.Block) *CLMock {
+	return &CLMock{
+		tb:          tb,
+		engine:      engine,
+		execution:   executionService,
+		Head:        genesis.Hash(),
+		Safe:        genesis.Hash(),
+		Finalized:   genesis.Hash(),
+		headTime:    genesis.Time(),
+		beaconRoots: map[libcommon.Hash]libcommon.Hash{},
+	}
+}
+
+// ForkchoiceState - current forkchoice of CL
+func (cl *CLMock) ForkchoiceState() *engine_types.ForkChoiceState {
+	return &engine_types.ForkChoiceState{HeadHash: cl.Head, SafeBlockHash: cl.Safe, FinalizedBlockHash: cl.Finalized}
+}
+
+// PayloadAttributes - attributes of next slot after head: one withdrawal and prevRandao and beacon root derived from timestamp
+func (cl *CLMock) PayloadAttributes() *engine_types.PayloadAttributes {
+	timestamp := cl.headTime + SlotTime
+	var slot [8]byte
+	binary.BigEndian.PutUint64(slot[:], timestamp)
+	beaconRoot := crypto.Keccak256Hash([]byte("beacon root"), slot[:])
+	return &engine_types.PayloadAttributes{
+		Timestamp:             hexutil.Uint64(timestamp),
+		PrevRandao:            crypto.Keccak256Hash([]byte("randao"), slot[:]),
+		SuggestedFeeRecipient: FeeRecipient,
+		Withdrawals: []*types.Withdrawal{
+			{Index: cl.withdrawalIndex, Validator: cl.withdrawalIndex, Address: libcommon.Address{0xee}, Amount: 1},
+		},
+		ParentBeaconBlockRoot: &beaconRoot,
+	}
+}
+
+// waitReady - Erigon answers SYNCING while execution is busy, e.g. with the rest of previous forkchoice update after
+// its reply, so CLMock waits for execution before every call to make scenarios deterministic
+func (cl *CLMock) waitReady() {
+	require.Eventually(cl.tb, func() bool {
+		ready, err := cl.execution.Ready(context.Background(), &emptypb.Empty{})
+		require.NoError(cl.tb, err)
+		return ready.Ready
+	}, 10*time.Second, time.Millisecond)
+}
+
+// ForkchoiceUpdated - engine_forkchoiceUpdatedV3, doesn't change forkchoice of CL
+func (cl *CLMock) ForkchoiceUpdated(state *engine_types.ForkChoiceState, attributes *engine_types.PayloadAttributes) (*engine_types.ForkChoiceUpdatedResponse, error) {
+	cl.waitReady()
+	return cl.engine.ForkchoiceUpdatedV3(context.Background(), state, attributes)
+}
+
+// NewPayload - engine_newPayloadV3, expected blob hashes are taken from payload's transactions
+func (cl *CLMock) NewPayload(payload *engine_types.ExecutionPayload) (*engine_types.PayloadStatus, error) {
+	txs := make([][]byte, len(payload.Transactions))
+	for i, txn := range payload.Transactions {
+		txs[i] = txn
+	}
+	transactions, err := types.DecodeTransactions(txs)
+	require.NoError(cl.tb, err)
+	blobHashes := []libcommon.Hash{}
+	for _, txn := range transactions {
+		blobHashes = append(blobHashes, txn.GetBlobHashes()...)
+	}
+	beaconRoot := cl.beaconRoots[payload.BlockHash]
+	cl.waitReady()
+	return cl.engine.NewPayloadV3(context.Background(), payload, blobHashes, &beaconRoot)
+}
+
+// BuildPayload - payload built by Erigon on top of CL's head with given attributes, forkchoice isn't changed
+func (cl *CLMock) BuildPayload(attributes *engine_types.PayloadAttributes) *engine_types.ExecutionPayload {
+	resp, err := cl.ForkchoiceUpdated(cl.ForkchoiceState(), attributes)
+	require.NoError(cl.tb, err)
+	require.Equal(cl.tb, engine_types.ValidStatus, resp.PayloadStatus.Status)
+	require.NotNil(cl.tb, resp.PayloadId)
+	payload, err := cl.engine.GetPayloadV3(context.Background(), *resp.PayloadId)
+	require.NoError(cl.tb, err)
+	cl.beaconRoots[payload.ExecutionPayload.BlockHash] = *attributes.ParentBeaconBlockRoot
+	return payload.ExecutionPayload
+}
+
+// ProduceBlock - one slot of CL: payload is built, sent and made the head
+func (cl *CLMock) ProduceBlock() *engine_types.ExecutionPayload {
+	payload := cl.BuildPayload(cl.PayloadAttributes())
+	status, err := cl.NewPayload(payload)
+	require.NoError(cl.tb, err)
+	require.Equal(cl.tb, engine_types.ValidStatus, status.Status, "%v", status.ValidationError)
+	cl.withdrawalIndex++
+	cl.MoveHead(payload)
+	return payload
+}
+
+// ProduceBlocks - n slots of CL
+func (cl *CLMock) ProduceBlocks(n int) []*engine_types.ExecutionPayload {
+	payloads := make([]*engine_types.ExecutionPayload, n)
+	for i := range payloads {
+		payloads[i] = cl.ProduceBlock()
+	}
+	return payloads
+}
+
+// MoveHead - forkchoiceUpdated to payload which must be VALID, its parent becomes safe. Returns when Erigon has switched to it
+func (cl *CLMock) MoveHead(payload *engine_types.ExecutionPayload) {
+	state := &engine_types.ForkChoiceState{HeadHash: payload.BlockHash, SafeBlockHash: payload.ParentHash, FinalizedBlockHash: cl.Finalized}
+	resp, err := cl.ForkchoiceUpdated(state, nil)
+	require.NoError(cl.tb, err)
+	require.Equal(cl.tb, engine_types.ValidStatus, resp.PayloadStatus.Status, "%v", resp.PayloadStatus.ValidationError)
+	cl.Head, cl.Safe = state.HeadHash, state.SafeBlockHash
+	cl.headTime = uint64(payload.Timestamp)
+	cl.waitReady() // reply is sent before new head is committed
+}
+
+// PayloadFromBlock - payload of block made outside of Erigon, e.g. by Harness.GenerateChain
+func (cl *CLMock) PayloadFromBlock(block *types.Block) *engine_types.ExecutionPayload {
+	payload := engine_types.ConvertRpcBlockToExecutionPayload(eth1_utils.ConvertBlockToRPC(block))
+	if root := block.Header().ParentBeaconBlockRoot; root != nil {
+		cl.beaconRoots[payload.BlockHash] = *root
+	}
+	return payload
+}
+
+// CustomizePayload - copy of payload changed by modify, block hash is recomputed, so only execution can detect changes
+func (cl *CLMock) CustomizePayload(payload *engine_types.ExecutionPayload, modify func(*engine_types.ExecutionPayload)) *engine_types.ExecutionPayload {
+	custom := *payload
+	modify(&custom)
+	beaconRoot := cl.beaconRoots[payload.BlockHash]
+	custom.BlockHash = payloadHeader(&custom, &beaconRoot).Hash()
+	cl.beaconRoots[custom.BlockHash] = beaconRoot
+	return &custom
+}
+
+// payloadHeader - header of cancun payload, as engine server makes it
+func payloadHeader(payload *engine_types.ExecutionPayload, beaconRoot *libcommon.Hash) *types.Header {
+	txs := make([][]byte, len(payload.Transactions))
+	for i, txn := range payload.Transactions {
+		txs[i] = txn
+	}
+	withdrawalsHash := types.DeriveSha(types.Withdrawals(payload.Withdrawals))
+	return &types.Header{
+		ParentHash:            payload.ParentHash,
+		Coinbase:              payload.FeeRecipient,
+		Root:                  payload.StateRoot,
+		Bloom:                 types.BytesToBloom(payload.LogsBloom),
+		BaseFee:               (*big.Int)(payload.BaseFeePerGas),
+		Extra:                 payload.ExtraData,
+		Number:                new(big.Int).SetUint64(uint64(payload.BlockNumber)),
+		GasUsed:               uint64(payload.GasUsed),
+		GasLimit:              uint64(payload.GasLimit),
+		Time:                  uint64(payload.Timestamp),
+		MixDigest:             payload.PrevRandao,
+		UncleHash:             types.EmptyUncleHash,
+		Difficulty:            merge.ProofOfStakeDifficulty,
+		Nonce:                 merge.ProofOfStakeNonce,
+		ReceiptHash:           payload.ReceiptsRoot,
+		TxHash:                types.DeriveSha(types.BinaryTransactions(txs)),
+		WithdrawalsHash:       &withdrawalsHash,
+		BlobGasUsed:           (*uint64)(payload.BlobGasUsed),
+		ExcessBlobGas:         (*uint64)(payload.ExcessBlobGas),
+		ParentBeaconBlockRoot: beaconRoot,
+	}
+}
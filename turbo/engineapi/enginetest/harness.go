@@ -0,0 +1,140 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package enginetest - in-process Engine API test harness, hive-style scenarios run by `go test`.
+// Harness is Erigon on top of mock.MockSentry with EngineServer and CLMock which drives it as consensus layer does:
+// builds payloads by forkchoiceUpdated with attributes and getPayload, sends them by newPayload, moves forkchoice.
+// Side and invalid chains are made by core.GenerateChain and sent as payloads.
+package enginetest
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/direct"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/consensus/ethash"
+	"github.com/erigontech/erigon/consensus/merge"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
+	"github.com/erigontech/erigon/ethdb/prune"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/engineapi"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+// GenesisTime - timestamp of genesis, CLMock makes slot every SlotTime seconds after it
+const (
+	GenesisTime = 1_700_000_000
+	SlotTime    = 12
+)
+
+// Harness - Erigon with Engine API server, driven by CL
+type Harness struct {
+	tb     testing.TB
+	Mock   *mock.MockSentry
+	Engine *engineapi.EngineServer
+	CL     *CLMock
+
+	Key     *ecdsa.PrivateKey // prefunded account of genesis, mock's key
+	Address libcommon.Address
+}
+
+// New - Harness with PoS from genesis chain, all forks up to cancun are active.
+// Engine server works as in production: missing blocks are requested from mock's peers, which don't have them,
+// so payloads with unknown parent and forkchoice to unknown head are answered by SYNCING.
+func New(tb testing.TB) *Harness {
+	key, _ := crypto.HexToECDSA("b71c71a67e1177ad4e901695e1b4b9ee17ae16c6668d313eac2f96dbcda3f291")
+	address := crypto.PubkeyToAddress(key.PublicKey)
+	config := *params.AllProtocolChanges
+	config.PragueTime, config.OsakaTime = nil, nil
+	gspec := &types.Genesis{
+		Config:     &config,
+		Timestamp:  GenesisTime,
+		GasLimit:   30_000_000,
+		Difficulty: libcommon.Big0,
+		Alloc: types.GenesisAlloc{
+			address: {Balance: new(big.Int).Mul(big.NewInt(1_000_000), big.NewInt(params.Ether))},
+		},
+	}
+	// payload building reads transactions from txpool
+	m := mock.MockWithEverything(tb, gspec, key, prune.DefaultMode, merge.New(ethash.NewFaker()), 128 /* blockBufferSize */, true /* withTxPool */, false, true)
+	executionRpc := direct.NewExecutionClientDirect(m.Eth1ExecutionService)
+	engine := engineapi.NewEngineServer(log.New(), m.ChainConfig, executionRpc, m.HeaderDownload(), m.EngineBlockDownloader(executionRpc),
+		false /* caplin */, false /* test */, true /* proposing */)
+	h := &Harness{tb: tb, Mock: m, Engine: engine, Key: key, Address: address}
+	h.CL = newCLMock(tb, engine, executionRpc, m.Genesis)
+	return h
+}
+
+// Block - block by hash, nil if it's unknown
+func (h *Harness) Block(hash libcommon.Hash) *types.Block {
+	var block *types.Block
+	err := h.Mock.DB.View(context.Background(), func(tx kv.Tx) error {
+		var err error
+		block, err = h.Mock.BlockReader.BlockByHash(context.Background(), tx, hash)
+		return err
+	})
+	require.NoError(h.tb, err)
+	return block
+}
+
+// HeadHash - head block of Erigon
+func (h *Harness) HeadHash() libcommon.Hash {
+	var hash libcommon.Hash
+	err := h.Mock.DB.View(context.Background(), func(tx kv.Tx) error {
+		hash = rawdb.ReadHeadBlockHash(tx)
+		return nil
+	})
+	require.NoError(h.tb, err)
+	return hash
+}
+
+// CanonicalHash - hash of canonical block of given number, zero hash if there is no such block
+func (h *Harness) CanonicalHash(number uint64) libcommon.Hash {
+	var hash libcommon.Hash
+	err := h.Mock.DB.View(context.Background(), func(tx kv.Tx) error {
+		var err error
+		hash, err = h.Mock.BlockReader.CanonicalHash(context.Background(), tx, number)
+		return err
+	})
+	require.NoError(h.tb, err)
+	return hash
+}
+
+// GenerateChain - n blocks on top of head which are not sent to Erigon, for side chains and invalid payloads.
+// State is read from Erigon, so chain can start only from its head. Coinbase differs from blocks built by CLMock.
+func (h *Harness) GenerateChain(n int, gen func(int, *core.BlockGen)) []*types.Block {
+	block := h.Block(h.HeadHash())
+	require.NotNil(h.tb, block)
+	chain, err := core.GenerateChain(h.Mock.ChainConfig, block, h.Mock.Engine, h.Mock.DB, n, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(libcommon.Address{0x51, 0xde})
+		if gen != nil {
+			gen(i, b)
+		}
+	})
+	require.NoError(h.tb, err)
+	return chain.Blocks
+}
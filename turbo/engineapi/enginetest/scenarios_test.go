@@ -0,0 +1,265 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package enginetest
+
+import (
+	"context"
+	"encoding/binary"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_helpers"
+	"github.com/erigontech/erigon/turbo/engineapi/engine_types"
+)
+
+func TestProduceBlocks(t *testing.T) {
+	h := New(t)
+	payloads := h.CL.ProduceBlocks(3)
+	for i, payload := range payloads {
+		require.Equal(t, payload.BlockHash, h.CanonicalHash(uint64(i+1)))
+		require.Equal(t, FeeRecipient, payload.FeeRecipient)
+		require.Len(t, payload.Withdrawals, 1)
+	}
+	require.Equal(t, payloads[2].BlockHash, h.HeadHash())
+
+	// already known payload is VALID again
+	status, err := h.CL.NewPayload(payloads[1])
+	require.NoError(t, err)
+	require.Equal(t, engine_types.ValidStatus, status.Status)
+}
+
+// Sidechain Reorg: two payloads are built on the same parent, forkchoice switches between them
+func TestSidechainReorg(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlocks(2)
+
+	attributes := h.CL.PayloadAttributes()
+	canonical := h.CL.BuildPayload(attributes)
+	attributes.PrevRandao = libcommon.Hash{0x01}
+	side := h.CL.BuildPayload(attributes)
+	require.NotEqual(t, canonical.BlockHash, side.BlockHash)
+
+	for _, payload := range []*engine_types.ExecutionPayload{canonical, side} {
+		status, err := h.CL.NewPayload(payload)
+		require.NoError(t, err)
+		require.Equal(t, engine_types.ValidStatus, status.Status)
+	}
+	h.CL.MoveHead(canonical)
+	require.Equal(t, canonical.BlockHash, h.CanonicalHash(3))
+	h.CL.MoveHead(side)
+	require.Equal(t, side.BlockHash, h.CanonicalHash(3))
+	require.Equal(t, side.BlockHash, h.HeadHash())
+	require.Equal(t, side.PrevRandao, h.Block(h.HeadHash()).MixDigest())
+}
+
+// Re-org to a longer side chain and back to the original chain
+func TestReorgBackAndForth(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlock()
+	side := h.GenerateChain(4, nil) // on top of block 1
+	original := h.CL.ProduceBlocks(3)
+
+	var sideTip *engine_types.ExecutionPayload
+	for _, block := range side {
+		sideTip = h.CL.PayloadFromBlock(block)
+		status, err := h.CL.NewPayload(sideTip)
+		require.NoError(t, err)
+		require.Equal(t, engine_types.ValidStatus, status.Status, "%v", status.ValidationError)
+	}
+	require.Equal(t, original[2].BlockHash, h.HeadHash())
+
+	h.CL.MoveHead(sideTip)
+	require.Equal(t, side[3].Hash(), h.CanonicalHash(5))
+	require.Equal(t, side[0].Hash(), h.CanonicalHash(2))
+
+	h.CL.MoveHead(original[2])
+	require.Equal(t, original[2].BlockHash, h.HeadHash())
+	require.Equal(t, original[0].BlockHash, h.CanonicalHash(2))
+	require.Equal(t, libcommon.Hash{}, h.CanonicalHash(5))
+
+	// CL builds on the original chain after re-org
+	next := h.CL.ProduceBlock()
+	require.Equal(t, original[2].BlockHash, next.ParentHash)
+	require.Equal(t, next.BlockHash, h.CanonicalHash(5))
+}
+
+// Invalid Ancestor Chain: payload with wrong state root and its descendants are INVALID, latestValidHash is the last valid ancestor
+func TestInvalidAncestor(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlocks(2)
+	head := h.CL.Head
+
+	signer := types.LatestSigner(h.Mock.ChainConfig)
+	chain := h.GenerateChain(3, func(i int, b *core.BlockGen) {
+		transfer := types.NewTransaction(b.TxNonce(h.Address), libcommon.Address{0xaa}, uint256.NewInt(1), params.TxGas, uint256.NewInt(10*params.GWei), nil)
+		txn, err := types.SignTx(transfer, *signer, h.Key)
+		require.NoError(t, err)
+		b.AddTx(txn)
+	})
+	valid := h.CL.PayloadFromBlock(chain[0])
+	invalid := h.CL.CustomizePayload(h.CL.PayloadFromBlock(chain[1]), func(p *engine_types.ExecutionPayload) {
+		p.StateRoot = libcommon.Hash{0xba, 0xd}
+	})
+	child := h.CL.CustomizePayload(h.CL.PayloadFromBlock(chain[2]), func(p *engine_types.ExecutionPayload) {
+		p.ParentHash = invalid.BlockHash
+	})
+
+	status, err := h.CL.NewPayload(valid)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.ValidStatus, status.Status, "%v", status.ValidationError)
+
+	status, err = h.CL.NewPayload(invalid)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.InvalidStatus, status.Status)
+	require.Equal(t, valid.BlockHash, *status.LatestValidHash)
+
+	status, err = h.CL.NewPayload(child)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.InvalidStatus, status.Status)
+	require.Equal(t, valid.BlockHash, *status.LatestValidHash)
+
+	resp, err := h.CL.ForkchoiceUpdated(&engine_types.ForkChoiceState{HeadHash: child.BlockHash, SafeBlockHash: head, FinalizedBlockHash: h.CL.Finalized}, nil)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.InvalidStatus, resp.PayloadStatus.Status)
+	require.Equal(t, valid.BlockHash, *resp.PayloadStatus.LatestValidHash)
+	require.Equal(t, head, h.HeadHash())
+
+	// chain goes on from valid ancestor
+	h.CL.MoveHead(valid)
+	require.Equal(t, valid.BlockHash, h.HeadHash())
+}
+
+// Invalid block hash: payload whose fields don't match its hash is rejected before execution
+func TestInvalidBlockHash(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlock()
+	payload := h.CL.BuildPayload(h.CL.PayloadAttributes())
+	payload.GasUsed++
+	status, err := h.CL.NewPayload(payload)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.InvalidStatus, status.Status)
+	require.Nil(t, status.LatestValidHash)
+}
+
+// Syncing: payload with unknown parent and forkchoice to unknown head are SYNCING, until the gap is filled
+func TestSyncingResponses(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlock()
+	chain := h.GenerateChain(3, nil)
+	payloads := make([]*engine_types.ExecutionPayload, len(chain))
+	for i, block := range chain {
+		payloads[i] = h.CL.PayloadFromBlock(block)
+	}
+	tip := payloads[2]
+
+	status, err := h.CL.NewPayload(tip)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.SyncingStatus, status.Status)
+	require.Nil(t, status.LatestValidHash)
+
+	resp, err := h.CL.ForkchoiceUpdated(&engine_types.ForkChoiceState{HeadHash: tip.BlockHash, SafeBlockHash: h.CL.Head, FinalizedBlockHash: h.CL.Finalized}, nil)
+	require.NoError(t, err)
+	require.Equal(t, engine_types.SyncingStatus, resp.PayloadStatus.Status)
+	require.Nil(t, resp.PayloadId)
+
+	for _, payload := range payloads {
+		status, err = h.CL.NewPayload(payload)
+		require.NoError(t, err)
+		require.Equal(t, engine_types.ValidStatus, status.Status, "%v", status.ValidationError)
+	}
+	h.CL.MoveHead(tip)
+	require.Equal(t, tip.BlockHash, h.HeadHash())
+}
+
+// Payload ID: same attributes give the same id, any change of attributes gives new one, unknown id is an error
+func TestPayloadId(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlock()
+
+	payloadId := func(attributes *engine_types.PayloadAttributes) uint64 {
+		resp, err := h.CL.ForkchoiceUpdated(h.CL.ForkchoiceState(), attributes)
+		require.NoError(t, err)
+		require.Equal(t, engine_types.ValidStatus, resp.PayloadStatus.Status)
+		require.NotNil(t, resp.PayloadId)
+		return binary.BigEndian.Uint64(*resp.PayloadId)
+	}
+	attributes := h.CL.PayloadAttributes()
+	id := payloadId(attributes)
+	require.Equal(t, id, payloadId(h.CL.PayloadAttributes()))
+
+	ids := map[uint64]string{id: "original"}
+	for name, modify := range map[string]func(a *engine_types.PayloadAttributes){
+		"timestamp":    func(a *engine_types.PayloadAttributes) { a.Timestamp++ },
+		"prevRandao":   func(a *engine_types.PayloadAttributes) { a.PrevRandao[0]++ },
+		"feeRecipient": func(a *engine_types.PayloadAttributes) { a.SuggestedFeeRecipient[0]++ },
+		"withdrawals":  func(a *engine_types.PayloadAttributes) { a.Withdrawals[0].Amount++ },
+		"beaconRoot":   func(a *engine_types.PayloadAttributes) { a.ParentBeaconBlockRoot[0]++ },
+	} {
+		a := h.CL.PayloadAttributes()
+		modify(a)
+		id := payloadId(a)
+		require.NotContains(t, ids, id, "%s gives same id as %s", name, ids[id])
+		ids[id] = name
+	}
+
+	_, err := h.Engine.GetPayloadV3(context.Background(), *engine_types.ConvertPayloadId(1 << 40))
+	require.ErrorIs(t, err, &engine_helpers.UnknownPayloadErr)
+}
+
+// Invalid payload attributes: timestamp not after head and missing beacon root
+func TestInvalidPayloadAttributes(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlock()
+
+	attributes := h.CL.PayloadAttributes()
+	attributes.Timestamp -= SlotTime
+	_, err := h.CL.ForkchoiceUpdated(h.CL.ForkchoiceState(), attributes)
+	require.ErrorIs(t, err, &engine_helpers.InvalidPayloadAttributesErr)
+
+	attributes = h.CL.PayloadAttributes()
+	attributes.ParentBeaconBlockRoot = nil
+	_, err = h.CL.ForkchoiceUpdated(h.CL.ForkchoiceState(), attributes)
+	require.ErrorIs(t, err, &engine_helpers.InvalidPayloadAttributesErr)
+}
+
+// Inconsistent Head in ForkchoiceState: safe block which isn't ancestor of head is -38002
+func TestInconsistentForkchoiceState(t *testing.T) {
+	h := New(t)
+	h.CL.ProduceBlock()
+	attributes := h.CL.PayloadAttributes()
+	canonical := h.CL.BuildPayload(attributes)
+	attributes.PrevRandao = libcommon.Hash{0x01}
+	side := h.CL.BuildPayload(attributes)
+	for _, payload := range []*engine_types.ExecutionPayload{canonical, side} {
+		status, err := h.CL.NewPayload(payload)
+		require.NoError(t, err)
+		require.Equal(t, engine_types.ValidStatus, status.Status)
+	}
+	h.CL.MoveHead(canonical)
+	next := h.CL.ProduceBlock()
+
+	_, err := h.CL.ForkchoiceUpdated(&engine_types.ForkChoiceState{HeadHash: next.BlockHash, SafeBlockHash: side.BlockHash, FinalizedBlockHash: h.CL.Finalized}, nil)
+	require.ErrorIs(t, err, &engine_helpers.InvalidForkchoiceStateErr)
+	require.Equal(t, next.BlockHash, h.HeadHash())
+}
@@ -70,6 +70,7 @@ type EthereumExecutionModule struct {
 	nextPayloadId  uint64
 	lastParameters *core.BlockBuilderParameters
 	builderFunc    builder.BlockBuilderFunc
+	rebuild        builder.RebuildConfig
 	builders       map[uint64]*builder.BlockBuilder
 
 	// Changes accumulator
@@ -94,7 +95,7 @@ type EthereumExecutionModule struct {
 
 func NewEthereumExecutionModule(blockReader services.FullBlockReader, db kv.RwDB,
 	executionPipeline *stagedsync.Sync, forkValidator *engine_helpers.ForkValidator,
-	config *chain.Config, builderFunc builder.BlockBuilderFunc,
+	config *chain.Config, builderFunc builder.BlockBuilderFunc, rebuild builder.RebuildConfig,
 	hook *stages.Hook, accumulator *shards.Accumulator,
 	stateChangeConsumer shards.StateChangeConsumer,
 	logger log.Logger, engine consensus.Engine,
@@ -109,6 +110,7 @@ func NewEthereumExecutionModule(blockReader services.FullBlockReader, db kv.RwDB
 		forkValidator:       forkValidator,
 		builders:            make(map[uint64]*builder.BlockBuilder),
 		builderFunc:         builderFunc,
+		rebuild:             rebuild,
 		config:              config,
 		semaphore:           semaphore.NewWeighted(1),
 		hook:                hook,
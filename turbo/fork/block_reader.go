@@ -0,0 +1,155 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"context"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/ethconfig"
+	"github.com/erigontech/erigon/rlp"
+	"github.com/erigontech/erigon/turbo/services"
+	"github.com/erigontech/erigon/turbo/snapshotsync/freezeblocks"
+)
+
+// BlockReader - blocks of source datadir (db and snapshots) up to fork point, mined blocks after it.
+// Mined blocks are only in db: they are read by reader without snapshots. Blocks of source datadir after fork point are hidden.
+type BlockReader struct {
+	services.FullBlockReader
+	own       *freezeblocks.BlockReader
+	forkBlock uint64
+}
+
+var _ services.FullBlockReader = (*BlockReader)(nil)
+
+func NewBlockReader(base services.FullBlockReader, forkBlock uint64) *BlockReader {
+	own := freezeblocks.NewBlockReader(freezeblocks.NewRoSnapshots(ethconfig.BlocksFreezing{}, "", 0, log.New()), freezeblocks.NewBorRoSnapshots(ethconfig.BlocksFreezing{}, "", 0, log.New()))
+	return &BlockReader{FullBlockReader: base, own: own, forkBlock: forkBlock}
+}
+
+func (r *BlockReader) reader(blockNum uint64) services.FullBlockReader {
+	if blockNum > r.forkBlock {
+		return r.own
+	}
+	return r.FullBlockReader
+}
+
+// mined - whether hash is of mined block. HeaderNumber of source datadir after fork point is hidden by Tx.
+func (r *BlockReader) mined(tx kv.Getter, hash common.Hash) bool {
+	n := rawdb.ReadHeaderNumber(tx, hash)
+	return n != nil && *n > r.forkBlock
+}
+
+func (r *BlockReader) FrozenBlocks() uint64 {
+	return min(r.FullBlockReader.FrozenBlocks(), r.forkBlock+1)
+}
+
+func (r *BlockReader) BlockByNumber(ctx context.Context, tx kv.Tx, number uint64) (*types.Block, error) {
+	return r.reader(number).BlockByNumber(ctx, tx, number)
+}
+
+func (r *BlockReader) BlockByHash(ctx context.Context, tx kv.Tx, hash common.Hash) (*types.Block, error) {
+	if r.mined(tx, hash) {
+		return r.own.BlockByHash(ctx, tx, hash)
+	}
+	block, err := r.FullBlockReader.BlockByHash(ctx, tx, hash)
+	if err != nil || block == nil || block.NumberU64() > r.forkBlock {
+		return nil, err
+	}
+	return block, nil
+}
+
+func (r *BlockReader) CurrentBlock(tx kv.Tx) (*types.Block, error) {
+	number := rawdb.ReadHeaderNumber(tx, rawdb.ReadHeadBlockHash(tx))
+	if number == nil {
+		return nil, nil
+	}
+	return r.reader(*number).CurrentBlock(tx)
+}
+
+func (r *BlockReader) BlockWithSenders(ctx context.Context, tx kv.Getter, hash common.Hash, number uint64) (*types.Block, []common.Address, error) {
+	return r.reader(number).BlockWithSenders(ctx, tx, hash, number)
+}
+
+func (r *BlockReader) Header(ctx context.Context, tx kv.Getter, hash common.Hash, number uint64) (*types.Header, error) {
+	return r.reader(number).Header(ctx, tx, hash, number)
+}
+
+func (r *BlockReader) HeaderByNumber(ctx context.Context, tx kv.Getter, number uint64) (*types.Header, error) {
+	return r.reader(number).HeaderByNumber(ctx, tx, number)
+}
+
+func (r *BlockReader) HeaderByHash(ctx context.Context, tx kv.Getter, hash common.Hash) (*types.Header, error) {
+	if r.mined(tx, hash) {
+		return r.own.HeaderByHash(ctx, tx, hash)
+	}
+	header, err := r.FullBlockReader.HeaderByHash(ctx, tx, hash)
+	if err != nil || header == nil || header.Number.Uint64() > r.forkBlock {
+		return nil, err
+	}
+	return header, nil
+}
+
+func (r *BlockReader) ReadAncestor(tx kv.Getter, hash common.Hash, number, ancestor uint64, maxNonCanonical *uint64) (common.Hash, uint64) {
+	return r.reader(number).ReadAncestor(tx, hash, number, ancestor, maxNonCanonical)
+}
+
+func (r *BlockReader) CanonicalHash(ctx context.Context, tx kv.Getter, number uint64) (common.Hash, error) {
+	return r.reader(number).CanonicalHash(ctx, tx, number)
+}
+
+func (r *BlockReader) BadHeaderNumber(ctx context.Context, tx kv.Getter, hash common.Hash) (*uint64, error) {
+	return r.own.BadHeaderNumber(ctx, tx, hash)
+}
+
+func (r *BlockReader) BodyWithTransactions(ctx context.Context, tx kv.Getter, hash common.Hash, number uint64) (*types.Body, error) {
+	return r.reader(number).BodyWithTransactions(ctx, tx, hash, number)
+}
+
+func (r *BlockReader) BodyRlp(ctx context.Context, tx kv.Getter, hash common.Hash, number uint64) (rlp.RawValue, error) {
+	return r.reader(number).BodyRlp(ctx, tx, hash, number)
+}
+
+func (r *BlockReader) Body(ctx context.Context, tx kv.Getter, hash common.Hash, number uint64) (*types.Body, uint32, error) {
+	return r.reader(number).Body(ctx, tx, hash, number)
+}
+
+func (r *BlockReader) HasSenders(ctx context.Context, tx kv.Getter, hash common.Hash, number uint64) (bool, error) {
+	return r.reader(number).HasSenders(ctx, tx, hash, number)
+}
+
+// TxnLookup - TxLookup of db has mined transactions, TxLookup of source datadir after fork point is hidden by Tx
+func (r *BlockReader) TxnLookup(ctx context.Context, tx kv.Getter, txnHash common.Hash) (uint64, bool, error) {
+	number, ok, err := r.own.TxnLookup(ctx, tx, txnHash)
+	if err != nil || ok {
+		return number, ok, err
+	}
+	number, ok, err = r.FullBlockReader.TxnLookup(ctx, tx, txnHash)
+	if err != nil || !ok || number > r.forkBlock {
+		return 0, false, err
+	}
+	return number, true, nil
+}
+
+func (r *BlockReader) TxnByIdxInBlock(ctx context.Context, tx kv.Getter, number uint64, i int) (types.Transaction, error) {
+	return r.reader(number).TxnByIdxInBlock(ctx, tx, number, i)
+}
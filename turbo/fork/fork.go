@@ -0,0 +1,230 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package fork - local dev chain on top of state of existing datadir at historical block.
+//
+// Source datadir is only read: its blocks up to fork point and its state history as of fork point
+// (`DomainGetAsOf`) are the base. Blocks mined on top of it are written to in-memory MDBX, their state
+// changes and inverted indices are kept in memory. No state root is computed: mined blocks have root of fork point.
+package fork
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"unsafe"
+
+	"github.com/c2h5oh/datasize"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/mdbx"
+	"github.com/erigontech/erigon-lib/kv/membatchwithdb"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/eth/stagedsync/stages"
+	"github.com/erigontech/erigon/turbo/services"
+)
+
+var ErrReadOnly = errors.New("fork: db is read-only, blocks are added only by miner")
+
+// DB - kv.RwDB of fork. It's read-only for users: blocks are added by miner.
+// Transactions implement kv.TemporalTx.
+type DB struct {
+	base    kv.RwDB // temporal db of source datadir
+	overlay kv.RwDB
+	state   *stateOverlay
+	tmpDir  string
+
+	forkBlock uint64
+	forkHash  common.Hash
+	forkTxNum uint64 // first txNum after fork point: base state is read as of it
+
+	lock  sync.RWMutex // commit of overlay and visibility of its state are atomic for readers
+	limit uint64       // first txNum of not committed block
+
+	blockReader *BlockReader
+	logger      log.Logger
+}
+
+var _ kv.RwDB = (*DB)(nil)
+
+// New - fork of base temporal db at block forkBlock. Base must be opened read-only, it's not closed by fork.
+func New(ctx context.Context, base kv.RwDB, baseReader services.FullBlockReader, forkBlock uint64, tmpDir string, logger log.Logger) (*DB, error) {
+	db := &DB{base: base, state: newStateOverlay(), tmpDir: tmpDir, forkBlock: forkBlock, logger: logger}
+	if err := base.View(ctx, func(tx kv.Tx) error {
+		if _, ok := tx.(kv.TemporalTx); !ok {
+			return errors.New("fork: base db is not temporal")
+		}
+		executed, err := stages.GetStageProgress(tx, stages.Execution)
+		if err != nil {
+			return err
+		}
+		if forkBlock > executed {
+			return fmt.Errorf("fork: block %d is not executed yet, execution progress: %d", forkBlock, executed)
+		}
+		if db.forkHash, err = baseReader.CanonicalHash(ctx, tx, forkBlock); err != nil {
+			return err
+		}
+		if db.forkHash == (common.Hash{}) {
+			return fmt.Errorf("fork: block %d not found", forkBlock)
+		}
+		maxTxNum, err := rawdbv3.TxNums.Max(tx, forkBlock)
+		if err != nil {
+			return err
+		}
+		db.forkTxNum = maxTxNum + 1
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	db.limit = db.forkTxNum
+	db.blockReader = NewBlockReader(baseReader, forkBlock)
+
+	overlay, err := mdbx.NewMDBX(logger).InMem(tmpDir).GrowthStep(64 * datasize.MB).MapSize(512 * datasize.GB).Open(ctx)
+	if err != nil {
+		return nil, err
+	}
+	db.overlay = overlay
+	if err := db.initOverlay(ctx); err != nil {
+		overlay.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// initOverlay - fork point is the head of chain: for RPC "latest", "safe" and "finalized" blocks and for all stages
+func (db *DB) initOverlay(ctx context.Context) error {
+	tx, err := db.beginRw(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	// ids of transactions of mined blocks continue sequence of base
+	if err := db.base.View(ctx, func(baseTx kv.Tx) error {
+		return baseTx.ForEach(kv.Sequence, nil, func(k, v []byte) error {
+			return tx.overlay.Put(kv.Sequence, k, v)
+		})
+	}); err != nil {
+		return err
+	}
+	if err := writeHead(tx, db.forkHash, db.forkBlock); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func writeHead(tx kv.RwTx, hash common.Hash, number uint64) error {
+	if err := rawdb.WriteHeadHeaderHash(tx, hash); err != nil {
+		return err
+	}
+	rawdb.WriteHeadBlockHash(tx, hash)
+	rawdb.WriteForkchoiceHead(tx, hash)
+	rawdb.WriteForkchoiceSafe(tx, hash)
+	rawdb.WriteForkchoiceFinalized(tx, hash)
+	for _, stage := range stages.AllStages {
+		if err := stages.SaveStageProgress(tx, stage, number); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ForkBlock - number of block of source datadir the fork is based on
+func (db *DB) ForkBlock() uint64 { return db.forkBlock }
+
+// BlockReader - reader of blocks of source datadir up to fork point and of mined blocks
+func (db *DB) BlockReader() *BlockReader { return db.blockReader }
+
+func (db *DB) newTx(ctx context.Context, overlayTx kv.RwTx, limit uint64) (*Tx, error) {
+	baseTx, err := db.base.BeginRo(ctx) //nolint:gocritic
+	if err != nil {
+		overlayTx.Rollback()
+		return nil, err
+	}
+	temporalTx := baseTx.(kv.TemporalTx)
+	bounded := &boundedTx{TemporalTx: temporalTx, maxBlock: db.forkBlock}
+	return &Tx{
+		MemoryMutation: membatchwithdb.NewMemoryBatchWithCustomDB(bounded, db.overlay, overlayTx, db.tmpDir),
+		db:             db,
+		base:           temporalTx,
+		overlay:        overlayTx,
+		limit:          limit,
+	}, nil
+}
+
+func (db *DB) BeginRo(ctx context.Context) (kv.Tx, error) {
+	db.lock.RLock()
+	defer db.lock.RUnlock()
+	overlayTx, err := db.overlay.BeginRo(ctx) //nolint:gocritic
+	if err != nil {
+		return nil, err
+	}
+	// overlay is only read: MemoryMutation just needs type of its write transaction
+	return db.newTx(ctx, overlayTx.(kv.RwTx), db.limit)
+}
+
+func (db *DB) View(ctx context.Context, f func(tx kv.Tx) error) error {
+	tx, err := db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	return f(tx)
+}
+
+// beginRw - write transaction of miner. Only one miner writes at a time.
+func (db *DB) beginRw(ctx context.Context) (*Tx, error) {
+	overlayTx, err := db.overlay.BeginRw(ctx) //nolint:gocritic
+	if err != nil {
+		return nil, err
+	}
+	db.lock.RLock()
+	limit := db.limit
+	db.lock.RUnlock()
+	return db.newTx(ctx, overlayTx, limit)
+}
+
+// commit - commits mined block, its state becomes visible for new transactions
+func (db *DB) commit(tx *Tx, limit uint64) error {
+	db.lock.Lock()
+	defer db.lock.Unlock()
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	db.limit = limit
+	return nil
+}
+
+func (db *DB) BeginRw(ctx context.Context) (kv.RwTx, error)       { return nil, ErrReadOnly }
+func (db *DB) BeginRwNosync(ctx context.Context) (kv.RwTx, error) { return nil, ErrReadOnly }
+func (db *DB) Update(ctx context.Context, f func(tx kv.RwTx) error) error {
+	return ErrReadOnly
+}
+func (db *DB) UpdateNosync(ctx context.Context, f func(tx kv.RwTx) error) error {
+	return ErrReadOnly
+}
+
+func (db *DB) ReadOnly() bool          { return true }
+func (db *DB) AllTables() kv.TableCfg  { return db.base.AllTables() }
+func (db *DB) PageSize() uint64        { return db.base.PageSize() }
+func (db *DB) CHandle() unsafe.Pointer { return nil }
+
+// Close - closes overlay, mined blocks are lost
+func (db *DB) Close() { db.overlay.Close() }
@@ -0,0 +1,204 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package fork_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/holiman/uint256"
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	txpoolproto "github.com/erigontech/erigon-lib/gointerfaces/txpoolproto"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/order"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+	"github.com/erigontech/erigon-lib/kv/stream"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/params"
+	"github.com/erigontech/erigon/turbo/fork"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+var to = libcommon.HexToAddress("0x1000000000000000000000000000000000000001")
+
+func transfer(t *testing.T, m *mock.MockSentry, nonce uint64, amount uint64) types.Transaction {
+	t.Helper()
+	txn, err := types.SignTx(types.NewTransaction(nonce, to, uint256.NewInt(amount), params.TxGas, uint256.NewInt(params.GWei), nil), *types.LatestSignerForChainID(m.ChainConfig.ChainID), m.Key)
+	require.NoError(t, err)
+	return txn
+}
+
+// forkMock - 3 blocks with transfers of 1, 10 and 100 wei, fork at block 1
+func forkMock(t *testing.T) (*mock.MockSentry, *core.ChainPack, *fork.DB, *fork.Miner) {
+	m := mock.Mock(t)
+	amounts := []uint64{1, 10, 100}
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, len(amounts), func(i int, b *core.BlockGen) {
+		b.AddTx(transfer(t, m, uint64(i), amounts[i]))
+	})
+	require.NoError(t, err)
+	require.NoError(t, m.InsertChain(chain))
+
+	db, err := fork.New(context.Background(), m.DB, m.BlockReader, 1, t.TempDir(), log.New())
+	require.NoError(t, err)
+	t.Cleanup(db.Close)
+	miner, err := fork.NewMiner(context.Background(), db, m.ChainConfig, libcommon.Address{}, log.New())
+	require.NoError(t, err)
+	return m, chain, db, miner
+}
+
+func balance(t *testing.T, tx kv.Tx, addr libcommon.Address) uint64 {
+	t.Helper()
+	acc, err := state.NewReaderV4(tx.(kv.TemporalGetter)).ReadAccountData(addr)
+	require.NoError(t, err)
+	if acc == nil {
+		return 0
+	}
+	return acc.Balance.Uint64()
+}
+
+func TestForkPoint(t *testing.T) {
+	m, chain, db, _ := forkMock(t)
+	ctx := context.Background()
+	br := db.BlockReader()
+	require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+		require.Equal(t, uint64(1), balance(t, tx, to), "state of block 1")
+		require.Equal(t, uint64(1), *rawdb.ReadCurrentBlockNumber(tx))
+
+		header, err := br.HeaderByNumber(ctx, tx, 1)
+		require.NoError(t, err)
+		require.Equal(t, chain.Blocks[0].Hash(), header.Hash())
+		// blocks of source chain after fork point are hidden
+		header, err = br.HeaderByNumber(ctx, tx, 2)
+		require.NoError(t, err)
+		require.Nil(t, header)
+		block, err := br.BlockByHash(ctx, tx, chain.Blocks[1].Hash())
+		require.NoError(t, err)
+		require.Nil(t, block)
+		_, ok, err := br.TxnLookup(ctx, tx, chain.Blocks[1].Transactions()[0].Hash())
+		require.NoError(t, err)
+		require.False(t, ok)
+		hash, err := rawdb.ReadCanonicalHash(tx, 2)
+		require.NoError(t, err)
+		require.Equal(t, libcommon.Hash{}, hash)
+		return nil
+	}))
+	_, err := db.BeginRw(ctx)
+	require.ErrorIs(t, err, fork.ErrReadOnly)
+
+	// source chain is not changed
+	require.NoError(t, m.DB.View(ctx, func(tx kv.Tx) error {
+		require.Equal(t, uint64(111), balance(t, tx, to))
+		return nil
+	}))
+}
+
+func TestMine(t *testing.T) {
+	m, chain, db, miner := forkMock(t)
+	ctx := context.Background()
+
+	// nonce 0 is used: skipped
+	block, skipped, err := miner.Mine(ctx, types.Transactions{transfer(t, m, 0, 1000), transfer(t, m, 1, 5000)})
+	require.NoError(t, err)
+	require.Len(t, skipped, 1)
+	require.Equal(t, uint64(2), block.NumberU64())
+	require.Len(t, block.Transactions(), 1)
+	require.NotEqual(t, chain.Blocks[1].Hash(), block.Hash())
+
+	br := db.BlockReader()
+	var forkTxNum uint64
+	require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+		require.Equal(t, uint64(5001), balance(t, tx, to))
+		require.Equal(t, uint64(2), *rawdb.ReadCurrentBlockNumber(tx))
+
+		mined, err := br.BlockByNumber(ctx, tx, 2)
+		require.NoError(t, err)
+		require.Equal(t, block.Hash(), mined.Hash())
+		n, ok, err := br.TxnLookup(ctx, tx, block.Transactions()[0].Hash())
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.Equal(t, uint64(2), n)
+
+		ttx := tx.(kv.TemporalTx)
+		maxTxNum, err := rawdbv3.TxNums.Max(tx, 1)
+		require.NoError(t, err)
+		forkTxNum = maxTxNum + 1
+		// history: state as of fork point and as of mined transaction
+		v, ok, err := ttx.DomainGetAsOf(kv.AccountsDomain, to[:], nil, forkTxNum)
+		require.NoError(t, err)
+		require.True(t, ok)
+		require.NotEmpty(t, v)
+		require.Equal(t, uint64(1), balanceOf(t, v))
+		v, _, err = ttx.DomainGetAsOf(kv.AccountsDomain, to[:], nil, forkTxNum+1)
+		require.NoError(t, err)
+		require.Equal(t, uint64(1), balanceOf(t, v), "user transaction is after system one")
+
+		// indices: transfer of block 1 and of mined block, transfer of source block 2 is hidden
+		txNums, err := stream.ToArrayU64(mustIndex(t, ttx, kv.TracesToIdx, to[:], 0, -1, order.Asc))
+		require.NoError(t, err)
+		require.Len(t, txNums, 2)
+		require.Equal(t, forkTxNum+1, txNums[1])
+		desc, err := stream.ToArrayU64(mustIndex(t, ttx, kv.TracesToIdx, to[:], -1, -1, order.Desc))
+		require.NoError(t, err)
+		require.Equal(t, []uint64{txNums[1], txNums[0]}, desc)
+
+		changed, err := ttx.HistoryRange(kv.AccountsHistory, int(forkTxNum), -1, order.Asc, -1)
+		require.NoError(t, err)
+		var toChanged bool
+		for changed.HasNext() {
+			k, _, err := changed.Next()
+			require.NoError(t, err)
+			toChanged = toChanged || bytes.Equal(k, to[:])
+		}
+		require.True(t, toChanged)
+		return nil
+	}))
+
+	pool := fork.NewTxPool(ctx, miner)
+	var buf bytes.Buffer
+	require.NoError(t, transfer(t, m, 2, 7).MarshalBinary(&buf))
+	reply, err := pool.Add(ctx, &txpoolproto.AddRequest{RlpTxs: [][]byte{buf.Bytes(), {0x01}}})
+	require.NoError(t, err)
+	require.Equal(t, []txpoolproto.ImportResult{txpoolproto.ImportResult_SUCCESS, txpoolproto.ImportResult_INVALID}, reply.Imported)
+	require.NoError(t, db.View(ctx, func(tx kv.Tx) error {
+		require.Equal(t, uint64(5008), balance(t, tx, to))
+		require.Equal(t, uint64(3), *rawdb.ReadCurrentBlockNumber(tx))
+		return nil
+	}))
+}
+
+func mustIndex(t *testing.T, tx kv.TemporalTx, name kv.InvertedIdx, k []byte, from, to int, asc order.By) stream.U64 {
+	t.Helper()
+	it, err := tx.IndexRange(name, k, from, to, asc, -1)
+	require.NoError(t, err)
+	return it
+}
+
+func balanceOf(t *testing.T, v []byte) uint64 {
+	t.Helper()
+	var acc accounts.Account
+	require.NoError(t, accounts.DeserialiseV3(&acc, v))
+	return acc.Balance.Uint64()
+}
@@ -0,0 +1,285 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/erigontech/erigon-lib/chain"
+	libcommon "github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/gointerfaces"
+	remote "github.com/erigontech/erigon-lib/gointerfaces/remoteproto"
+	types2 "github.com/erigontech/erigon-lib/gointerfaces/typesproto"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/state/exec3"
+	"github.com/erigontech/erigon/consensus"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/eth/ethconsensusconfig"
+	"github.com/erigontech/erigon/eth/stagedsync"
+	"github.com/erigontech/erigon/turbo/shards"
+)
+
+// Miner - builds blocks on top of fork head by dev consensus: fake ethash before the Merge, no seal after it.
+// Block is built from given transactions in given order, invalid ones are skipped.
+type Miner struct {
+	db          *DB
+	engine      consensus.Engine
+	chainConfig *chain.Config
+	etherbase   libcommon.Address
+	events      *shards.Events
+
+	lock   sync.Mutex
+	logger log.Logger
+}
+
+func NewMiner(ctx context.Context, db *DB, chainConfig *chain.Config, etherbase libcommon.Address, logger log.Logger) (*Miner, error) {
+	if chainConfig.Clique != nil || chainConfig.Aura != nil || chainConfig.Bor != nil {
+		return nil, fmt.Errorf("fork: consensus of chain %s is not supported, only ethash and proof-of-stake chains", chainConfig.ChainName)
+	}
+	return &Miner{
+		db:          db,
+		engine:      ethconsensusconfig.CreateConsensusEngineBareBones(ctx, chainConfig, logger),
+		chainConfig: chainConfig,
+		etherbase:   etherbase,
+		events:      shards.NewEvents(),
+		logger:      logger,
+	}, nil
+}
+
+func (m *Miner) Engine() consensus.Engine { return m.engine }
+
+// Mine - mines block with txs, returns it with errors of skipped transactions by their hashes
+func (m *Miner) Mine(ctx context.Context, txs types.Transactions) (*types.Block, map[libcommon.Hash]error, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	tx, err := m.db.beginRw(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer tx.Rollback()
+	br := m.db.blockReader
+
+	parentNum := rawdb.ReadCurrentBlockNumber(tx)
+	if parentNum == nil {
+		return nil, nil, fmt.Errorf("fork: head not found")
+	}
+	parent, err := br.HeaderByNumber(ctx, tx, *parentNum)
+	if err != nil {
+		return nil, nil, err
+	}
+	if parent == nil {
+		return nil, nil, fmt.Errorf("fork: head header %d not found", *parentNum)
+	}
+	parentMaxTxNum, err := rawdbv3.TxNums.Max(tx, *parentNum)
+	if err != nil {
+		return nil, nil, err
+	}
+	minTxNum := parentMaxTxNum + 1
+	committed := false
+	defer func() {
+		if !committed {
+			m.db.state.truncate(minTxNum)
+		}
+	}()
+
+	chainReader := stagedsync.ChainReader{Cfg: *m.chainConfig, Db: tx, BlockReader: br, Logger: m.logger}
+	header := m.makeHeader(chainReader, parent)
+	rules := m.chainConfig.Rules(header.Number.Uint64(), header.Time)
+	getHeader := func(hash libcommon.Hash, number uint64) *types.Header {
+		h, _ := br.Header(ctx, tx, hash, number)
+		return h
+	}
+	blockHashFunc := core.GetHashFn(header, getHeader)
+
+	ibs := state.New(state.NewReaderV4(tx))
+	writer := state.NewWriterV4(m.db.state)
+
+	// txNums of block: system tx, transactions, system tx
+	m.db.state.SetTxNum(minTxNum)
+	m.engine.Initialize(m.chainConfig, chainReader, header, ibs, func(contract libcommon.Address, data []byte, ibState *state.IntraBlockState, header *types.Header, constCall bool) ([]byte, error) {
+		return core.SysCallContract(contract, data, m.chainConfig, ibState, header, m.engine, constCall)
+	}, m.logger, nil)
+	if err := ibs.FinalizeTx(rules, writer); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		included    types.Transactions
+		receipts    types.Receipts
+		skipped     = map[libcommon.Hash]error{}
+		gasPool     = new(core.GasPool).AddGas(header.GasLimit).AddBlobGas(m.chainConfig.GetMaxBlobGasPerBlock())
+		usedBlobGas uint64
+		tracer      = exec3.NewCallTracer()
+		vmConfig    = vm.Config{Debug: true, Tracer: tracer}
+	)
+	for _, txn := range txs {
+		txNum := minTxNum + 1 + uint64(len(included))
+		m.db.state.SetTxNum(txNum)
+		ibs.SetTxContext(txn.Hash(), libcommon.Hash{}, len(included))
+		tracer.Reset()
+		gasSnap, blobGasSnap, snap := gasPool.Gas(), gasPool.BlobGas(), ibs.Snapshot()
+		receipt, _, err := core.ApplyTransaction(m.chainConfig, blockHashFunc, m.engine, &header.Coinbase, gasPool, ibs, writer, header, txn, &header.GasUsed, &usedBlobGas, vmConfig)
+		if err != nil {
+			ibs.RevertToSnapshot(snap)
+			gasPool = new(core.GasPool).AddGas(gasSnap).AddBlobGas(blobGasSnap)
+			m.db.state.truncate(txNum)
+			skipped[txn.Hash()] = err
+			continue
+		}
+		included, receipts = append(included, txn), append(receipts, receipt)
+		for addr := range tracer.Froms() {
+			m.db.state.IndexAdd(kv.TracesFromIdx, addr[:])
+		}
+		for addr := range tracer.Tos() {
+			m.db.state.IndexAdd(kv.TracesToIdx, addr[:])
+		}
+		for _, l := range receipt.Logs {
+			m.db.state.IndexAdd(kv.LogAddrIdx, l.Address[:])
+			for _, topic := range l.Topics {
+				m.db.state.IndexAdd(kv.LogTopicIdx, topic[:])
+			}
+		}
+	}
+	if header.BlobGasUsed != nil {
+		*header.BlobGasUsed = usedBlobGas
+	}
+
+	maxTxNum := minTxNum + 1 + uint64(len(included))
+	m.db.state.SetTxNum(maxTxNum)
+	var withdrawals []*types.Withdrawal
+	if m.chainConfig.IsShanghai(header.Time) {
+		withdrawals = []*types.Withdrawal{}
+	}
+	syscall := func(contract libcommon.Address, data []byte) ([]byte, error) {
+		return core.SysCallContract(contract, data, m.chainConfig, ibs, header, m.engine, false /* constCall */)
+	}
+	block, _, _, err := m.engine.FinalizeAndAssemble(m.chainConfig, header, ibs, included, nil, receipts, withdrawals, nil, chainReader, syscall, nil, m.logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ibs.CommitBlock(rules, writer); err != nil {
+		return nil, nil, err
+	}
+
+	if err := m.writeBlock(tx, block, parent, maxTxNum); err != nil {
+		return nil, nil, err
+	}
+	if err := m.db.commit(tx, maxTxNum+1); err != nil {
+		return nil, nil, err
+	}
+	committed = true
+	m.notify(block, receipts)
+	m.logger.Info("[fork] mined block", "number", block.NumberU64(), "hash", block.Hash(), "txs", len(included), "skipped", len(skipped), "gas", block.GasUsed())
+	return block, skipped, nil
+}
+
+func (m *Miner) makeHeader(chainReader consensus.ChainReader, parent *types.Header) *types.Header {
+	header := core.MakeEmptyHeader(parent, m.chainConfig, max(uint64(time.Now().Unix()), parent.Time+1), nil)
+	header.Coinbase = m.etherbase
+	if _, err := rand.Read(header.MixDigest[:]); err != nil {
+		panic(err)
+	}
+	if parent.Difficulty.Sign() != 0 {
+		if d := m.engine.CalcDifficulty(chainReader, header.Time, parent.Time, parent.Difficulty, parent.Number.Uint64(), parent.Hash(), parent.UncleHash, parent.AuRaStep); d != nil {
+			header.Difficulty = d
+		}
+	}
+	if m.chainConfig.IsCancun(header.Time) {
+		header.ParentBeaconBlockRoot = &libcommon.Hash{}
+	}
+	return header
+}
+
+func (m *Miner) writeBlock(tx *Tx, block *types.Block, parent *types.Header, maxTxNum uint64) error {
+	hash, number := block.Hash(), block.NumberU64()
+	if err := rawdb.WriteBlock(tx, block); err != nil {
+		return err
+	}
+	if err := rawdb.WriteCanonicalHash(tx, hash, number); err != nil {
+		return err
+	}
+	senders := make([]libcommon.Address, len(block.Transactions()))
+	for i, txn := range block.Transactions() {
+		senders[i], _ = txn.GetSender()
+	}
+	if err := rawdb.WriteSenders(tx, hash, number, senders); err != nil {
+		return err
+	}
+	rawdb.WriteTxLookupEntries(tx, block)
+	parentTd, err := rawdb.ReadTd(tx, parent.Hash(), parent.Number.Uint64())
+	if err != nil {
+		return err
+	}
+	if parentTd != nil {
+		if err := rawdb.WriteTd(tx, hash, number, new(big.Int).Add(parentTd, block.Difficulty())); err != nil {
+			return err
+		}
+	}
+	if err := rawdbv3.TxNums.Append(tx, number, maxTxNum); err != nil {
+		return err
+	}
+	return writeHead(tx, hash, number)
+}
+
+// notify - new header and logs for RPC subscriptions
+func (m *Miner) notify(block *types.Block, receipts types.Receipts) {
+	var buf bytes.Buffer
+	if err := block.Header().EncodeRLP(&buf); err != nil {
+		m.logger.Warn("[fork] header encoding", "err", err)
+		return
+	}
+	m.events.OnNewHeader([][]byte{buf.Bytes()})
+
+	var logs []*remote.SubscribeLogsReply
+	var logIndex uint64
+	for txIndex, receipt := range receipts {
+		for _, l := range receipt.Logs {
+			r := &remote.SubscribeLogsReply{
+				Address:          gointerfaces.ConvertAddressToH160(l.Address),
+				BlockHash:        gointerfaces.ConvertHashToH256(block.Hash()),
+				BlockNumber:      block.NumberU64(),
+				Data:             l.Data,
+				LogIndex:         logIndex,
+				Topics:           make([]*types2.H256, 0, len(l.Topics)),
+				TransactionHash:  gointerfaces.ConvertHashToH256(block.Transactions()[txIndex].Hash()),
+				TransactionIndex: uint64(txIndex),
+			}
+			logIndex++
+			for _, topic := range l.Topics {
+				r.Topics = append(r.Topics, gointerfaces.ConvertHashToH256(topic))
+			}
+			logs = append(logs, r)
+		}
+	}
+	if len(logs) > 0 {
+		m.events.OnLogs(logs)
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"context"
+
+	"google.golang.org/grpc"
+
+	remote "github.com/erigontech/erigon-lib/gointerfaces/remoteproto"
+	"github.com/erigontech/erigon-lib/kv/kvcache"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/rpcdaemon/cli"
+	"github.com/erigontech/erigon/cmd/rpcdaemon/cli/httpcfg"
+	"github.com/erigontech/erigon/ethdb/privateapi"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/builder"
+	"github.com/erigontech/erigon/turbo/jsonrpc"
+)
+
+// noStateChanges - fork has no state stream: RPC runs without state cache
+type noStateChanges struct{}
+
+func (noStateChanges) StateChanges(ctx context.Context, _ *remote.StateChangeRequest, _ ...grpc.CallOption) (remote.KV_StateChangesClient, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+// APIList - JSON-RPC of fork: standard namespaces served by embedded services, eth_sendRawTransaction mines a block
+func APIList(ctx context.Context, db *DB, miner *Miner, cfg *httpcfg.HttpCfg, logger log.Logger) []rpc.API {
+	backend := NewBackend(miner.etherbase, miner.chainConfig.ChainID.Uint64())
+	ethBackendServer := privateapi.NewEthBackendServer(ctx, backend, db, miner.events, db.blockReader, logger, builder.NewLatestBlockBuiltStore())
+	miningServer := privateapi.NewMiningServer(ctx, backend, nil, logger)
+	stateCacheCfg := kvcache.DefaultCoherentConfig
+	stateCacheCfg.CacheSize = 0
+
+	eth, txPool, mining, stateCache, ff, _ := cli.EmbeddedServices(ctx, db, stateCacheCfg, cfg.RpcFiltersConfig, db.blockReader,
+		ethBackendServer, NewTxPool(ctx, miner), miningServer, noStateChanges{}, logger)
+	// blocks of fork are never finalized: there is nothing to cache
+	return jsonrpc.APIList(db, eth, txPool, mining, ff, stateCache, db.blockReader, cfg, miner.engine, logger, nil, nil)
+}
+
+// Serve - serves JSON-RPC of fork until ctx is done
+func Serve(ctx context.Context, db *DB, miner *Miner, cfg *httpcfg.HttpCfg, logger log.Logger) error {
+	return cli.StartRpcServer(ctx, cfg, APIList(ctx, db, miner, cfg, logger), logger)
+}
@@ -0,0 +1,254 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+)
+
+// version - value of key written by txNum, nil value - deleted
+type version struct {
+	txNum uint64
+	seq   uint64 // order of writes of the same txNum
+	v     []byte
+}
+
+// stateOverlay - in-memory domains, histories and inverted indices of blocks built on top of fork point.
+// Versions are appended in txNum order: only miner writes and it executes blocks one by one.
+type stateOverlay struct {
+	lock       sync.RWMutex
+	domains    []map[string][]version
+	prefixDels []map[string][]version // DomainDelPrefix: prefix => txNums of deletes
+	indices    map[kv.InvertedIdx]map[string][]uint64
+
+	// fields below are used only by the writer
+	txNum uint64
+	seq   uint64
+}
+
+var _ kv.TemporalPutDel = (*stateOverlay)(nil)
+
+func newStateOverlay() *stateOverlay {
+	s := &stateOverlay{
+		domains:    make([]map[string][]version, kv.DomainsLen()),
+		prefixDels: make([]map[string][]version, kv.DomainsLen()),
+		indices:    map[kv.InvertedIdx]map[string][]uint64{},
+	}
+	for i := range s.domains {
+		s.domains[i] = map[string][]version{}
+		s.prefixDels[i] = map[string][]version{}
+	}
+	return s
+}
+
+// idxAliases - names of inverted indices which are accepted by aggregator as aliases of the same index
+var idxAliases = map[kv.InvertedIdx]kv.InvertedIdx{
+	kv.TblLogAddressIdx: kv.LogAddrIdx,
+	kv.TblLogTopicsIdx:  kv.LogTopicIdx,
+	kv.LogTopicIndex:    kv.LogTopicIdx,
+}
+
+func canonicalIdx(name kv.InvertedIdx) kv.InvertedIdx {
+	if alias, ok := idxAliases[name]; ok {
+		return alias
+	}
+	return name
+}
+
+func historyDomain(name kv.History) (kv.Domain, error) {
+	if d, ok := kv.DomainByHistory(name); ok {
+		return d, nil
+	}
+	return 0, fmt.Errorf("unknown history %s", name)
+}
+
+// SetTxNum - txNum of following writes
+func (s *stateOverlay) SetTxNum(txNum uint64) { s.txNum = txNum }
+
+func (s *stateOverlay) put(domain kv.Domain, k []byte, v []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.seq++
+	key := string(k)
+	s.domains[domain][key] = append(s.domains[domain][key], version{txNum: s.txNum, seq: s.seq, v: v})
+	s.indexAdd(domain.HistoryIdx(), k)
+}
+
+func (s *stateOverlay) DomainPut(domain kv.Domain, k1, k2 []byte, val, prevVal []byte, prevStep uint64) error {
+	if val == nil {
+		return s.DomainDel(domain, k1, k2, prevVal, prevStep)
+	}
+	s.put(domain, append(append([]byte{}, k1...), k2...), bytes.Clone(val))
+	return nil
+}
+
+func (s *stateOverlay) DomainDel(domain kv.Domain, k1, k2 []byte, prevVal []byte, prevStep uint64) error {
+	s.put(domain, append(append([]byte{}, k1...), k2...), nil)
+	return nil
+}
+
+func (s *stateOverlay) DomainDelPrefix(domain kv.Domain, prefix []byte) error {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.seq++
+	key := string(prefix)
+	s.prefixDels[domain][key] = append(s.prefixDels[domain][key], version{txNum: s.txNum, seq: s.seq})
+	return nil
+}
+
+func (s *stateOverlay) AppendablePut(name kv.Appendable, ts kv.TxnId, v []byte) error {
+	return fmt.Errorf("fork: appendable %s is not supported", name)
+}
+
+// IndexAdd - adds key of inverted index at current txNum
+func (s *stateOverlay) IndexAdd(name kv.InvertedIdx, k []byte) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	s.indexAdd(name, k)
+}
+
+func (s *stateOverlay) indexAdd(name kv.InvertedIdx, k []byte) {
+	name = canonicalIdx(name)
+	idx, ok := s.indices[name]
+	if !ok {
+		idx = map[string][]uint64{}
+		s.indices[name] = idx
+	}
+	key := string(k)
+	if l := idx[key]; len(l) > 0 && l[len(l)-1] == s.txNum {
+		return
+	}
+	idx[key] = append(idx[key], s.txNum)
+}
+
+// truncate - forgets writes of txNum >= from: block was not committed
+func (s *stateOverlay) truncate(from uint64) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	for _, m := range append(append([]map[string][]version{}, s.domains...), s.prefixDels...) {
+		for k, versions := range m {
+			i := sort.Search(len(versions), func(i int) bool { return versions[i].txNum >= from })
+			if i == 0 {
+				delete(m, k)
+			} else {
+				m[k] = versions[:i]
+			}
+		}
+	}
+	for _, idx := range s.indices {
+		for k, l := range idx {
+			i := sort.Search(len(l), func(i int) bool { return l[i] >= from })
+			if i == 0 {
+				delete(idx, k)
+			} else {
+				idx[k] = l[:i]
+			}
+		}
+	}
+}
+
+// last - the latest version written before txNum `before`
+func last(versions []version, before uint64) (version, bool) {
+	i := sort.Search(len(versions), func(i int) bool { return versions[i].txNum >= before })
+	if i == 0 {
+		return version{}, false
+	}
+	return versions[i-1], true
+}
+
+// get - value of key as of `before` txNum. found=false - key wasn't changed after fork point
+func (s *stateOverlay) get(domain kv.Domain, k []byte, before uint64) (v []byte, found bool) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	return s.getLocked(domain, k, before)
+}
+
+func (s *stateOverlay) getLocked(domain kv.Domain, k []byte, before uint64) (v []byte, found bool) {
+	written, found := last(s.domains[domain][string(k)], before)
+	if domain == kv.StorageDomain && len(k) >= length.Addr {
+		if deleted, ok := last(s.prefixDels[domain][string(k[:length.Addr])], before); ok && (!found || deleted.seq > written.seq) {
+			return nil, true
+		}
+	}
+	return written.v, found
+}
+
+// prefixDeleted - whether all keys with prefix of k were deleted before txNum `before`, without later writes of k
+func (s *stateOverlay) prefixDeleted(domain kv.Domain, k []byte, before uint64) bool {
+	if domain != kv.StorageDomain || len(k) < length.Addr {
+		return false
+	}
+	_, ok := last(s.prefixDels[domain][string(k[:length.Addr])], before)
+	return ok
+}
+
+// index - txNums of inverted index key in [from, to)
+func (s *stateOverlay) index(name kv.InvertedIdx, k []byte, from, to uint64) []uint64 {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	l := s.indices[canonicalIdx(name)][string(k)]
+	i := sort.Search(len(l), func(i int) bool { return l[i] >= from })
+	j := sort.Search(len(l), func(i int) bool { return l[i] >= to })
+	return append([]uint64{}, l[i:j]...)
+}
+
+// changedKeys - sorted keys of domain changed in [from, to) txNums
+func (s *stateOverlay) changedKeys(domain kv.Domain, from, to uint64) [][]byte {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	var keys [][]byte
+	for k, versions := range s.domains[domain] {
+		i := sort.Search(len(versions), func(i int) bool { return versions[i].txNum >= from })
+		if i < len(versions) && versions[i].txNum < to {
+			keys = append(keys, []byte(k))
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return bytes.Compare(keys[i], keys[j]) < 0 })
+	return keys
+}
+
+// rangeAsOf - sorted keys in [from, to) changed after fork point with their values as of `before`, deleted keys have empty values
+func (s *stateOverlay) rangeAsOf(domain kv.Domain, from, to []byte, before uint64) (keys, values [][]byte) {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+	for k := range s.domains[domain] {
+		key := []byte(k)
+		if (from != nil && bytes.Compare(key, from) < 0) || (to != nil && bytes.Compare(key, to) >= 0) {
+			continue
+		}
+		if v, ok := s.getLocked(domain, key, before); ok {
+			keys, values = append(keys, key), append(values, v)
+		}
+	}
+	sort.Sort(kvSorter{keys, values})
+	return keys, values
+}
+
+type kvSorter struct{ keys, values [][]byte }
+
+func (s kvSorter) Len() int           { return len(s.keys) }
+func (s kvSorter) Less(i, j int) bool { return bytes.Compare(s.keys[i], s.keys[j]) < 0 }
+func (s kvSorter) Swap(i, j int) {
+	s.keys[i], s.keys[j] = s.keys[j], s.keys[i]
+	s.values[i], s.values[j] = s.values[j], s.values[i]
+}
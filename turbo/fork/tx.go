@@ -0,0 +1,408 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/big"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/membatchwithdb"
+	"github.com/erigontech/erigon-lib/kv/order"
+	"github.com/erigontech/erigon-lib/kv/stream"
+)
+
+// Tx - transaction of fork: tables and state of source datadir as of fork point, blocks and state of overlay on top.
+// Tables are merged by MemoryMutation, temporal methods merge base history with in-memory overlay state.
+type Tx struct {
+	*membatchwithdb.MemoryMutation
+	db      *DB
+	base    kv.TemporalTx
+	overlay kv.RwTx
+	limit   uint64 // overlay versions with txNum >= limit are not visible: their block is not committed yet
+}
+
+var _ kv.TemporalTx = (*Tx)(nil)
+
+// Rollback - MemoryMutation closes its db on rollback, but overlay db outlives transactions
+func (tx *Tx) Rollback() {
+	tx.overlay.Rollback()
+	tx.base.Rollback()
+}
+
+func (tx *Tx) Close() { tx.Rollback() }
+
+func (tx *Tx) Commit() error {
+	defer tx.base.Rollback()
+	return tx.overlay.Commit()
+}
+
+func (tx *Tx) DomainGet(name kv.Domain, k, k2 []byte) (v []byte, step uint64, err error) {
+	if k2 != nil {
+		k = append(common.Copy(k), k2...)
+	}
+	if v, ok := tx.db.state.get(name, k, tx.limit); ok {
+		return v, 0, nil
+	}
+	v, _, err = tx.base.DomainGetAsOf(name, k, nil, tx.db.forkTxNum)
+	return v, 0, err
+}
+
+func (tx *Tx) DomainGetAsOf(name kv.Domain, k, k2 []byte, ts uint64) (v []byte, ok bool, err error) {
+	if k2 != nil {
+		k = append(common.Copy(k), k2...)
+	}
+	if ts > tx.db.forkTxNum {
+		if v, ok := tx.db.state.get(name, k, min(ts, tx.limit)); ok {
+			return v, true, nil
+		}
+		ts = tx.db.forkTxNum
+	}
+	return tx.base.DomainGetAsOf(name, k, nil, ts)
+}
+
+// HistorySeek - always found: "not found in history" of base means "see latest", which is not the latest of fork
+func (tx *Tx) HistorySeek(name kv.History, k []byte, ts uint64) (v []byte, ok bool, err error) {
+	d, err := historyDomain(name)
+	if err != nil {
+		return nil, false, err
+	}
+	v, _, err = tx.DomainGetAsOf(d, k, nil, ts)
+	return v, true, err
+}
+
+func (tx *Tx) IndexRange(name kv.InvertedIdx, k []byte, fromTs, toTs int, asc order.By, limit int) (stream.U64, error) {
+	fork, visible := int(tx.db.forkTxNum), int(tx.limit)
+	var (
+		baseIt stream.U64 = stream.EmptyU64
+		own    []uint64
+		err    error
+	)
+	if asc {
+		baseTo := toTs
+		if baseTo < 0 || baseTo > fork {
+			baseTo = fork
+		}
+		if fromTs < baseTo {
+			if baseIt, err = tx.base.IndexRange(name, k, fromTs, baseTo, asc, limit); err != nil {
+				return nil, err
+			}
+		}
+		from, to := max(fromTs, fork), visible
+		if toTs >= 0 && toTs < to {
+			to = toTs
+		}
+		if from < to {
+			own = tx.db.state.index(name, k, uint64(from), uint64(to))
+		}
+		return stream.Union[uint64](baseIt, stream.Array(own), asc, limit), nil
+	}
+
+	// descending: (toTs, fromTs]
+	baseFrom := fromTs
+	if baseFrom < 0 || baseFrom >= fork {
+		baseFrom = fork - 1
+	}
+	if toTs < baseFrom {
+		if baseIt, err = tx.base.IndexRange(name, k, baseFrom, toTs, asc, limit); err != nil {
+			return nil, err
+		}
+	}
+	from, to := max(toTs+1, fork), visible
+	if fromTs >= 0 && fromTs+1 < to {
+		to = fromTs + 1
+	}
+	if from < to {
+		own = tx.db.state.index(name, k, uint64(from), uint64(to))
+	}
+	return stream.Union[uint64](baseIt, stream.ReverseArray(own), asc, limit), nil
+}
+
+// HistoryRange - keys changed in [fromTs, toTs) with their values as of fromTs. Only ascending order is supported, like in Aggregator.
+func (tx *Tx) HistoryRange(name kv.History, fromTs, toTs int, asc order.By, limit int) (stream.KV, error) {
+	if !asc {
+		return nil, fmt.Errorf("fork: descending HistoryRange is not supported")
+	}
+	d, err := historyDomain(name)
+	if err != nil {
+		return nil, err
+	}
+	fork, visible := int(tx.db.forkTxNum), int(tx.limit)
+	var baseIt stream.KV = stream.EmptyKV
+	baseTo := toTs
+	if baseTo < 0 || baseTo > fork {
+		baseTo = fork
+	}
+	if fromTs < baseTo {
+		if baseIt, err = tx.base.HistoryRange(name, fromTs, baseTo, asc, limit); err != nil {
+			return nil, err
+		}
+	}
+	from, to := max(fromTs, fork), visible
+	if toTs >= 0 && toTs < to {
+		to = toTs
+	}
+	var keys, values [][]byte
+	if from < to {
+		keys = tx.db.state.changedKeys(d, uint64(from), uint64(to))
+		for _, k := range keys {
+			v, ok := tx.db.state.get(d, k, uint64(from))
+			if !ok {
+				if v, _, err = tx.base.DomainGetAsOf(d, k, nil, tx.db.forkTxNum); err != nil {
+					baseIt.Close()
+					return nil, err
+				}
+			}
+			values = append(values, v)
+		}
+	}
+	// changes before fork point are older: base values win
+	return stream.UnionKV(baseIt, kvStream(keys, values), limit), nil
+}
+
+// DomainRange - state as of ts in [fromKey, toKey). Only ascending order is supported, like in Aggregator.
+func (tx *Tx) DomainRange(name kv.Domain, fromKey, toKey []byte, ts uint64, asc order.By, limit int) (stream.KV, error) {
+	if !asc {
+		return nil, fmt.Errorf("fork: descending DomainRange is not supported")
+	}
+	baseIt, err := tx.base.DomainRange(name, fromKey, toKey, min(ts, tx.db.forkTxNum), asc, -1)
+	if err != nil {
+		return nil, err
+	}
+	defer baseIt.Close()
+	var (
+		keys, values [][]byte
+		before       = min(ts, tx.limit)
+		overlaid     = map[string]struct{}{}
+	)
+	if ts > tx.db.forkTxNum {
+		keys, values = tx.db.state.rangeAsOf(name, fromKey, toKey, before)
+		for _, k := range keys {
+			overlaid[string(k)] = struct{}{}
+		}
+	}
+	it := stream.UnionKV(kvStream(keys, values), baseIt, -1)
+	var resKeys, resValues [][]byte
+	for it.HasNext() && (limit < 0 || len(resKeys) < limit) {
+		k, v, err := it.Next()
+		if err != nil {
+			return nil, err
+		}
+		if len(v) == 0 {
+			continue
+		}
+		if _, ok := overlaid[string(k)]; !ok && ts > tx.db.forkTxNum && tx.db.state.prefixDeleted(name, k, before) {
+			continue
+		}
+		resKeys, resValues = append(resKeys, common.Copy(k)), append(resValues, common.Copy(v))
+	}
+	return kvStream(resKeys, resValues), nil
+}
+
+func (tx *Tx) AppendableGet(name kv.Appendable, ts kv.TxnId) ([]byte, bool, error) {
+	return tx.base.AppendableGet(name, ts)
+}
+
+func kvStream(keys, values [][]byte) stream.KV {
+	return stream.PaginateKV(func(string) ([][]byte, [][]byte, string, error) { return keys, values, "", nil })
+}
+
+// boundedTx - transaction of source datadir which doesn't show blocks after fork point
+type boundedTx struct {
+	kv.TemporalTx
+	maxBlock uint64
+}
+
+// numberPrefixed - tables with keys starting from block number
+var numberPrefixed = map[string]struct{}{
+	kv.HeaderCanonical: {},
+	kv.Headers:         {},
+	kv.HeaderTD:        {},
+	kv.BlockBody:       {},
+	kv.Senders:         {},
+	kv.MaxTxNum:        {},
+}
+
+// hiddenFunc - which entries of table are after fork point, nil if table is not bounded.
+// stop=true - table is ordered by block number, all following entries are hidden too.
+func (tx *boundedTx) hiddenFunc(table string) (hidden func(k, v []byte) bool, stop bool) {
+	if _, ok := numberPrefixed[table]; ok {
+		return func(k, v []byte) bool { return len(k) >= 8 && binary.BigEndian.Uint64(k[:8]) > tx.maxBlock }, true
+	}
+	switch table {
+	case kv.HeaderNumber:
+		return func(k, v []byte) bool { return len(v) == 8 && binary.BigEndian.Uint64(v) > tx.maxBlock }, false
+	case kv.TxLookup:
+		return func(k, v []byte) bool { return new(big.Int).SetBytes(v).Uint64() > tx.maxBlock }, false
+	}
+	return nil, false
+}
+
+func (tx *boundedTx) CursorDupSort(table string) (kv.CursorDupSort, error) {
+	c, err := tx.TemporalTx.CursorDupSort(table)
+	if err != nil {
+		return nil, err
+	}
+	hidden, stop := tx.hiddenFunc(table)
+	if hidden == nil {
+		return c, nil
+	}
+	return &boundedCursor{CursorDupSort: c, hidden: hidden, stop: stop, maxBlock: tx.maxBlock}, nil
+}
+
+func (tx *boundedTx) Cursor(table string) (kv.Cursor, error) { return tx.CursorDupSort(table) }
+
+func (tx *boundedTx) GetOne(table string, key []byte) ([]byte, error) {
+	c, err := tx.CursorDupSort(table)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	_, v, err := c.SeekExact(key)
+	return v, err
+}
+
+func (tx *boundedTx) Has(table string, key []byte) (bool, error) {
+	v, err := tx.GetOne(table, key)
+	return v != nil, err
+}
+
+func (tx *boundedTx) filter(table string, it stream.KV) stream.KV {
+	hidden, _ := tx.hiddenFunc(table)
+	if hidden == nil {
+		return it
+	}
+	return stream.FilterKV(it, func(k, v []byte) bool { return !hidden(k, v) })
+}
+
+func (tx *boundedTx) Range(table string, fromPrefix, toPrefix []byte) (stream.KV, error) {
+	return tx.RangeAscend(table, fromPrefix, toPrefix, -1)
+}
+
+func (tx *boundedTx) RangeAscend(table string, fromPrefix, toPrefix []byte, limit int) (stream.KV, error) {
+	if _, ok := numberPrefixed[table]; ok {
+		if to := hexutility.EncodeTs(tx.maxBlock + 1); toPrefix == nil || string(toPrefix) > string(to) {
+			toPrefix = to
+		}
+		return tx.TemporalTx.RangeAscend(table, fromPrefix, toPrefix, limit)
+	}
+	it, err := tx.TemporalTx.RangeAscend(table, fromPrefix, toPrefix, -1)
+	if err != nil {
+		return nil, err
+	}
+	return limitKV(tx.filter(table, it), limit), nil
+}
+
+func (tx *boundedTx) RangeDescend(table string, fromPrefix, toPrefix []byte, limit int) (stream.KV, error) {
+	it, err := tx.TemporalTx.RangeDescend(table, fromPrefix, toPrefix, -1)
+	if err != nil {
+		return nil, err
+	}
+	return limitKV(tx.filter(table, it), limit), nil
+}
+
+func (tx *boundedTx) Prefix(table string, prefix []byte) (stream.KV, error) {
+	nextPrefix, ok := kv.NextSubtree(prefix)
+	if !ok {
+		return tx.Range(table, prefix, nil)
+	}
+	return tx.Range(table, prefix, nextPrefix)
+}
+
+// limitKV - first `limit` entries of stream, negative limit - unlimited
+func limitKV(it stream.KV, limit int) stream.KV {
+	if limit < 0 {
+		return it
+	}
+	return stream.PaginateKV(func(string) ([][]byte, [][]byte, string, error) {
+		defer it.Close()
+		var keys, values [][]byte
+		for it.HasNext() && len(keys) < limit {
+			k, v, err := it.Next()
+			if err != nil {
+				return nil, nil, "", err
+			}
+			keys, values = append(keys, k), append(values, v)
+		}
+		return keys, values, "", nil
+	})
+}
+
+// boundedCursor - cursor which doesn't show entries after fork point
+type boundedCursor struct {
+	kv.CursorDupSort
+	hidden   func(k, v []byte) bool
+	stop     bool
+	maxBlock uint64
+}
+
+func (c *boundedCursor) forward(k, v []byte, err error) ([]byte, []byte, error) {
+	for ; err == nil && k != nil && c.hidden(k, v); k, v, err = c.CursorDupSort.Next() {
+		if c.stop {
+			return nil, nil, nil
+		}
+	}
+	return k, v, err
+}
+
+func (c *boundedCursor) backward(k, v []byte, err error) ([]byte, []byte, error) {
+	for ; err == nil && k != nil && c.hidden(k, v); k, v, err = c.CursorDupSort.Prev() {
+	}
+	return k, v, err
+}
+
+func (c *boundedCursor) First() ([]byte, []byte, error) { return c.forward(c.CursorDupSort.First()) }
+func (c *boundedCursor) Seek(seek []byte) ([]byte, []byte, error) {
+	return c.forward(c.CursorDupSort.Seek(seek))
+}
+func (c *boundedCursor) Next() ([]byte, []byte, error) { return c.forward(c.CursorDupSort.Next()) }
+func (c *boundedCursor) NextNoDup() ([]byte, []byte, error) {
+	return c.forward(c.CursorDupSort.NextNoDup())
+}
+func (c *boundedCursor) Prev() ([]byte, []byte, error) { return c.backward(c.CursorDupSort.Prev()) }
+
+func (c *boundedCursor) Last() ([]byte, []byte, error) {
+	if c.stop {
+		k, _, err := c.CursorDupSort.Seek(hexutility.EncodeTs(c.maxBlock + 1))
+		if err != nil {
+			return nil, nil, err
+		}
+		if k != nil {
+			return c.backward(c.CursorDupSort.Prev())
+		}
+	}
+	return c.backward(c.CursorDupSort.Last())
+}
+
+func (c *boundedCursor) SeekExact(key []byte) ([]byte, []byte, error) {
+	k, v, err := c.CursorDupSort.SeekExact(key)
+	if err != nil || k == nil || c.hidden(k, v) {
+		return nil, nil, err
+	}
+	return k, v, nil
+}
+
+func (c *boundedCursor) Current() ([]byte, []byte, error) {
+	k, v, err := c.CursorDupSort.Current()
+	if err != nil || k == nil || c.hidden(k, v) {
+		return nil, nil, err
+	}
+	return k, v, nil
+}
@@ -0,0 +1,147 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package fork
+
+import (
+	"context"
+
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+	remote "github.com/erigontech/erigon-lib/gointerfaces/remoteproto"
+	txpool "github.com/erigontech/erigon-lib/gointerfaces/txpoolproto"
+	typesproto "github.com/erigontech/erigon-lib/gointerfaces/typesproto"
+	txpool2 "github.com/erigontech/erigon-lib/txpool"
+
+	"github.com/erigontech/erigon/core/types"
+)
+
+// TxPool - txpool of fork for RPC: there is no pool, every added batch of transactions is mined immediately
+type TxPool struct {
+	txpool.UnimplementedTxpoolServer
+	ctx   context.Context
+	miner *Miner
+}
+
+var _ txpool.TxpoolServer = (*TxPool)(nil)
+
+func NewTxPool(ctx context.Context, miner *Miner) *TxPool {
+	return &TxPool{ctx: ctx, miner: miner}
+}
+
+func (p *TxPool) Version(context.Context, *emptypb.Empty) (*typesproto.VersionReply, error) {
+	return txpool2.TxPoolAPIVersion, nil
+}
+
+func (p *TxPool) Add(ctx context.Context, in *txpool.AddRequest) (*txpool.AddReply, error) {
+	reply := &txpool.AddReply{Imported: make([]txpool.ImportResult, len(in.RlpTxs)), Errors: make([]string, len(in.RlpTxs))}
+	signer := types.LatestSigner(p.miner.chainConfig)
+	var txs types.Transactions
+	var positions []int
+	for i, rlpTx := range in.RlpTxs {
+		txn, err := types.DecodeWrappedTransaction(rlpTx)
+		if err != nil {
+			reply.Imported[i], reply.Errors[i] = txpool.ImportResult_INVALID, err.Error()
+			continue
+		}
+		sender, err := txn.Sender(*signer)
+		if err != nil {
+			reply.Imported[i], reply.Errors[i] = txpool.ImportResult_INVALID, err.Error()
+			continue
+		}
+		txn.SetSender(sender)
+		txs, positions = append(txs, txn), append(positions, i)
+	}
+	if len(txs) == 0 {
+		return reply, nil
+	}
+	_, skipped, err := p.miner.Mine(ctx, txs)
+	if err != nil {
+		return nil, err
+	}
+	for j, txn := range txs {
+		i := positions[j]
+		if err, ok := skipped[txn.Hash()]; ok {
+			reply.Imported[i], reply.Errors[i] = txpool.ImportResult_INVALID, err.Error()
+			continue
+		}
+		reply.Imported[i], reply.Errors[i] = txpool.ImportResult_SUCCESS, txpool.ImportResult_SUCCESS.String()
+	}
+	return reply, nil
+}
+
+// FindUnknown - all transactions are unknown: mined ones aren't in pool
+func (p *TxPool) FindUnknown(_ context.Context, in *txpool.TxHashes) (*txpool.TxHashes, error) {
+	return in, nil
+}
+
+func (p *TxPool) Transactions(context.Context, *txpool.TransactionsRequest) (*txpool.TransactionsReply, error) {
+	return &txpool.TransactionsReply{}, nil
+}
+
+func (p *TxPool) All(context.Context, *txpool.AllRequest) (*txpool.AllReply, error) {
+	return &txpool.AllReply{}, nil
+}
+
+func (p *TxPool) Pending(context.Context, *emptypb.Empty) (*txpool.PendingReply, error) {
+	return &txpool.PendingReply{}, nil
+}
+
+func (p *TxPool) Status(context.Context, *txpool.StatusRequest) (*txpool.StatusReply, error) {
+	return &txpool.StatusReply{}, nil
+}
+
+// Nonce - not found: RPC takes nonce from state
+func (p *TxPool) Nonce(context.Context, *txpool.NonceRequest) (*txpool.NonceReply, error) {
+	return &txpool.NonceReply{}, nil
+}
+
+// OnAdd - there are no pending transactions. Stream is kept open: RPC re-subscribes on close.
+func (p *TxPool) OnAdd(_ *txpool.OnAddRequest, server txpool.Txpool_OnAddServer) error {
+	select {
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	case <-server.Context().Done():
+		return server.Context().Err()
+	}
+}
+
+// Backend - privateapi.EthBackend of fork: node without peers
+type Backend struct {
+	etherbase libcommon.Address
+	networkID uint64
+}
+
+func NewBackend(etherbase libcommon.Address, networkID uint64) *Backend {
+	return &Backend{etherbase: etherbase, networkID: networkID}
+}
+
+func (b *Backend) Etherbase() (libcommon.Address, error) { return b.etherbase, nil }
+func (b *Backend) NetVersion() (uint64, error)           { return b.networkID, nil }
+func (b *Backend) NetPeerCount() (uint64, error)         { return 0, nil }
+func (b *Backend) NodesInfo(limit int) (*remote.NodesInfoReply, error) {
+	return &remote.NodesInfoReply{}, nil
+}
+func (b *Backend) Peers(ctx context.Context) (*remote.PeersReply, error) {
+	return &remote.PeersReply{}, nil
+}
+func (b *Backend) AddPeer(ctx context.Context, url *remote.AddPeerRequest) (*remote.AddPeerReply, error) {
+	return &remote.AddPeerReply{Success: false}, nil
+}
+
+// IsMining - miner is always on: transactions are mined on arrival
+func (b *Backend) IsMining() bool { return true }
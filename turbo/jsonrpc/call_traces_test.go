@@ -89,6 +89,49 @@ func TestCallTraceOneByOne(t *testing.T) {
 	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, blockNumbersFromTraces(t, stream.Buffer()))
 }
 
+func TestCallTraceFilterCursor(t *testing.T) {
+	m := mock.Mock(t)
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 10, func(i int, gen *core.BlockGen) {
+		gen.SetCoinbase(common.Address{1})
+	})
+	if err != nil {
+		t.Fatalf("generate chain: %v", err)
+	}
+	if err = m.InsertChain(chain); err != nil {
+		t.Fatalf("inserting chain: %v", err)
+	}
+
+	api := NewTraceAPI(newBaseApiForTest(m), m.DB, &httpcfg.HttpCfg{})
+	stream := jsoniter.ConfigDefault.BorrowStream(nil)
+	defer jsoniter.ConfigDefault.ReturnStream(stream)
+	var fromBlock, toBlock, count uint64 = 1, 10, 3
+	toAddress1 := common.Address{1}
+	var blockNumbers []int
+	cursor := &PageCursor{}
+	for pages := 0; cursor != nil; pages++ {
+		require.Less(t, pages, 4)
+		stream.Reset(nil)
+		traceReq := TraceFilterRequest{
+			FromBlock: (*hexutil.Uint64)(&fromBlock),
+			ToBlock:   (*hexutil.Uint64)(&toBlock),
+			ToAddress: []*common.Address{&toAddress1},
+			Count:     &count,
+			Cursor:    cursor,
+		}
+		if err = api.Filter(context.Background(), traceReq, new(bool), nil, stream); err != nil {
+			t.Fatalf("trace_filter failed: %v", err)
+		}
+		var page struct {
+			Traces     jsoniter.RawMessage `json:"traces"`
+			NextCursor *PageCursor         `json:"nextCursor"`
+		}
+		require.NoError(t, jsoniter.Unmarshal(stream.Buffer(), &page))
+		blockNumbers = append(blockNumbers, blockNumbersFromTraces(t, page.Traces)...)
+		cursor = page.NextCursor
+	}
+	assert.Equal(t, []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, blockNumbers)
+}
+
 func TestCallTraceUnwind(t *testing.T) {
 	m := mock.Mock(t)
 	var chainA, chainB *core.ChainPack
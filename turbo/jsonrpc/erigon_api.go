@@ -19,6 +19,8 @@ package jsonrpc
 import (
 	"context"
 
+	jsoniter "github.com/json-iterator/go"
+
 	"github.com/erigontech/erigon-lib/common/hexutil"
 
 	"github.com/erigontech/erigon-lib/common"
@@ -45,10 +47,17 @@ type ErigonAPI interface {
 	GetBlockByTimestamp(ctx context.Context, timeStamp rpc.Timestamp, fullTx bool) (map[string]interface{}, error)
 	GetBalanceChangesInBlock(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (map[common.Address]*hexutil.Big, error)
 
+	// State related (see ./erigon_state_diff.go)
+	GetStateDiff(ctx context.Context, fromBlock, toBlock rpc.BlockNumber, cursor StateDiffCursor, limit uint64, addresses []common.Address) (*ErigonStateDiff, error)
+
+	// Commitment related (see ./erigon_commitment.go)
+	GetCommitmentRoots(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*ErigonCommitmentRoots, error)
+
 	// Receipt related (see ./erigon_receipts.go)
 	GetLogsByHash(ctx context.Context, hash common.Hash) ([][]*types.Log, error)
 	//GetLogsByNumber(ctx context.Context, number rpc.BlockNumber) ([][]*types.Log, error)
-	GetLogs(ctx context.Context, crit filters.FilterCriteria) (types.ErigonLogs, error)
+	GetLogs(ctx context.Context, crit filters.FilterCriteria, stream *jsoniter.Stream) error
+	GetLogsPage(ctx context.Context, crit filters.FilterCriteria, cursor PageCursor, limit uint64) (*ErigonLogsPage, error)
 	GetLatestLogs(ctx context.Context, crit filters.FilterCriteria, logOptions filters.LogFilterOptions) (types.ErigonLogs, error)
 	// Gets cannonical block receipt through hash. If the block is not cannonical returns error
 	GetBlockReceiptsByBlockHash(ctx context.Context, cannonicalBlockHash common.Hash) ([]map[string]interface{}, error)
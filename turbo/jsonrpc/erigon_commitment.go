@@ -0,0 +1,71 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	libstate "github.com/erigontech/erigon-lib/state"
+
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/rpchelper"
+)
+
+// ErigonCommitmentRoots - roots of state commitments after block.
+// ShadowRoot is nil if node doesn't compute shadow commitment (--experimental.commitment.shadow) or didn't compute
+// it for this block: during bulk sync commitment is computed once per batch of blocks.
+type ErigonCommitmentRoots struct {
+	BlockNumber        hexutil.Uint64 `json:"blockNumber"`
+	BlockHash          common.Hash    `json:"blockHash"`
+	StateRoot          common.Hash    `json:"stateRoot"`
+	ShadowRoot         *common.Hash   `json:"shadowRoot"`
+	ConversionComplete bool           `json:"conversionComplete"` // shadow commitment covers whole state, not only keys touched since it was enabled
+}
+
+// GetCommitmentRoots implements erigon_getCommitmentRoots. Returns state root of block and root of shadow commitment.
+func (api *ErigonImpl) GetCommitmentRoots(ctx context.Context, blockNrOrHash rpc.BlockNumberOrHash) (*ErigonCommitmentRoots, error) {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	blockNumber, hash, _, err := rpchelper.GetBlockNumber(blockNrOrHash, tx, api.filters)
+	if err != nil {
+		return nil, err
+	}
+	header, err := api._blockReader.Header(ctx, tx, hash, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if header == nil {
+		return nil, fmt.Errorf("block %d not found", blockNumber)
+	}
+
+	roots := &ErigonCommitmentRoots{BlockNumber: hexutil.Uint64(blockNumber), BlockHash: hash, StateRoot: header.Root}
+	shadowRoot, complete, ok, err := libstate.ReadShadowCommitmentRoot(tx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		roots.ShadowRoot, roots.ConversionComplete = &shadowRoot, complete
+	}
+	return roots, nil
+}
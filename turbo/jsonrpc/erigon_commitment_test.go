@@ -0,0 +1,55 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/erigontech/erigon/rpc"
+)
+
+func TestErigonGetCommitmentRoots(t *testing.T) {
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	api := NewErigonAPI(newBaseApiForTest(m), m.DB, nil)
+
+	roots, err := api.GetCommitmentRoots(m.Ctx, rpc.BlockNumberOrHashWithNumber(1))
+	require.NoError(t, err)
+	header, err := api.GetHeaderByNumber(m.Ctx, 1)
+	require.NoError(t, err)
+	require.Equal(t, header.Hash(), roots.BlockHash)
+	require.Equal(t, header.Root, roots.StateRoot)
+	require.Nil(t, roots.ShadowRoot)
+
+	shadowRoot := common.HexToHash("0x01020304")
+	require.NoError(t, m.DB.Update(m.Ctx, func(tx kv.RwTx) error {
+		return tx.Put(kv.ShadowCommitmentRoots, binary.BigEndian.AppendUint64(nil, 1), append(shadowRoot.Bytes(), 1))
+	}))
+	roots, err = api.GetCommitmentRoots(m.Ctx, rpc.BlockNumberOrHashWithHash(header.Hash(), true))
+	require.NoError(t, err)
+	require.Equal(t, &shadowRoot, roots.ShadowRoot)
+	require.True(t, roots.ConversionComplete)
+
+	_, err = api.GetCommitmentRoots(m.Ctx, rpc.BlockNumberOrHashWithNumber(1_000_000))
+	require.Error(t, err)
+}
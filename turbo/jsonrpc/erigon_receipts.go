@@ -22,6 +22,7 @@ import (
 	"fmt"
 
 	"github.com/RoaringBitmap/roaring"
+	jsoniter "github.com/json-iterator/go"
 
 	"github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/kv"
@@ -66,20 +67,59 @@ func (api *ErigonImpl) GetLogsByHash(ctx context.Context, hash common.Hash) ([][
 }
 
 // GetLogs implements erigon_getLogs. Returns an array of logs matching a given filter object.
-func (api *ErigonImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (types.ErigonLogs, error) {
-	var begin, end uint64
-	erigonLogs := types.ErigonLogs{}
-
+func (api *ErigonImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria, stream *jsoniter.Stream) error {
 	tx, beginErr := api.db.BeginRo(ctx)
 	if beginErr != nil {
-		return erigonLogs, beginErr
+		return rpc.PreStreamError(beginErr)
+	}
+	defer tx.Rollback()
+
+	begin, end, found, err := api.logsRange(ctx, tx, crit)
+	if !found {
+		if err == nil {
+			stream.WriteNil() // unknown block hash
+		}
+		return rpc.PreStreamError(err)
+	}
+	return api.writeLogsV3(ctx, tx.(kv.TemporalTx), begin, end, crit, stream, func(log *types.ErigonLog) any { return log })
+}
+
+// ErigonLogsPage - result of erigon_getLogsPage
+type ErigonLogsPage struct {
+	Logs       types.ErigonLogs `json:"logs"`
+	NextCursor *PageCursor      `json:"nextCursor"` // nil if there are no more pages
+}
+
+// GetLogsPage implements erigon_getLogsPage. Same as erigon_getLogs, but returns at most `limit` logs
+// (up to maxPageLimit) and cursor to continue from. Pass empty cursor ("0x") to get first page.
+func (api *ErigonImpl) GetLogsPage(ctx context.Context, crit filters.FilterCriteria, cursor PageCursor, limit uint64) (*ErigonLogsPage, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, err
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
 	}
 	defer tx.Rollback()
 
+	begin, end, found, err := api.logsRange(ctx, tx, crit)
+	if !found {
+		return nil, err
+	}
+	page := newPager(&cursor, limit)
+	logs, err := api.getLogsV3(ctx, tx.(kv.TemporalTx), begin, end, crit, page)
+	if err != nil {
+		return nil, err
+	}
+	return &ErigonLogsPage{Logs: logs, NextCursor: page.next}, nil
+}
+
+// logsRange - converts block range of `crit` into internal representation. !found - if `crit.BlockHash` is unknown
+func (api *ErigonImpl) logsRange(ctx context.Context, tx kv.Tx, crit filters.FilterCriteria) (begin, end uint64, found bool, err error) {
 	if crit.BlockHash != nil {
 		header, err := api._blockReader.HeaderByHash(ctx, tx, *crit.BlockHash)
 		if header == nil {
-			return nil, err
+			return 0, 0, false, err
 		}
 		begin = header.Number.Uint64()
 		end = header.Number.Uint64()
@@ -88,7 +128,7 @@ func (api *ErigonImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria)
 		// Convert the RPC block numbers into internal representations
 		latest, err := rpchelper.GetLatestBlockNumber(tx)
 		if err != nil {
-			return nil, err
+			return 0, 0, false, err
 		}
 
 		begin = 0
@@ -96,7 +136,7 @@ func (api *ErigonImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria)
 			if crit.FromBlock.Sign() >= 0 {
 				begin = crit.FromBlock.Uint64()
 			} else if !crit.FromBlock.IsInt64() || crit.FromBlock.Int64() != int64(rpc.LatestBlockNumber) {
-				return nil, fmt.Errorf("negative value for FromBlock: %v", crit.FromBlock)
+				return 0, 0, false, fmt.Errorf("negative value for FromBlock: %v", crit.FromBlock)
 			}
 		}
 		end = latest
@@ -104,18 +144,17 @@ func (api *ErigonImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria)
 			if crit.ToBlock.Sign() >= 0 {
 				end = crit.ToBlock.Uint64()
 			} else if !crit.ToBlock.IsInt64() || crit.ToBlock.Int64() != int64(rpc.LatestBlockNumber) {
-				return nil, fmt.Errorf("negative value for ToBlock: %v", crit.ToBlock)
+				return 0, 0, false, fmt.Errorf("negative value for ToBlock: %v", crit.ToBlock)
 			}
 		}
 	}
 	if end < begin {
-		return nil, fmt.Errorf("end (%d) < begin (%d)", end, begin)
+		return 0, 0, false, fmt.Errorf("end (%d) < begin (%d)", end, begin)
 	}
 	if end > roaring.MaxUint32 {
-		return nil, fmt.Errorf("end (%d) > MaxUint32", end)
+		return 0, 0, false, fmt.Errorf("end (%d) > MaxUint32", end)
 	}
-
-	return api.getLogsV3(ctx, tx.(kv.TemporalTx), begin, end, crit)
+	return begin, end, true, nil
 }
 
 // GetLatestLogs implements erigon_getLatestLogs.
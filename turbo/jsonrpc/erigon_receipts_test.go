@@ -17,12 +17,14 @@
 package jsonrpc
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"math/big"
 	"testing"
 
 	"github.com/holiman/uint256"
+	jsoniter "github.com/json-iterator/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
@@ -43,18 +45,40 @@ import (
 	"github.com/erigontech/erigon/turbo/stages/mock"
 )
 
+// streamedResult - decodes result of streaming method
+func streamedResult[T any](write func(stream *jsoniter.Stream) error) (res T, err error) {
+	var buf bytes.Buffer
+	stream := jsoniter.NewStream(jsoniter.ConfigDefault, &buf, 4096)
+	if err = write(stream); err != nil {
+		return res, err
+	}
+	if err = stream.Flush(); err != nil {
+		return res, err
+	}
+	err = json.Unmarshal(buf.Bytes(), &res)
+	return res, err
+}
+
+func ethGetLogs(ctx context.Context, api *APIImpl, crit filters.FilterCriteria) (types.Logs, error) {
+	return streamedResult[types.Logs](func(stream *jsoniter.Stream) error { return api.GetLogs(ctx, crit, stream) })
+}
+
+func erigonGetLogs(ctx context.Context, api *ErigonImpl, crit filters.FilterCriteria) (types.ErigonLogs, error) {
+	return streamedResult[types.ErigonLogs](func(stream *jsoniter.Stream) error { return api.GetLogs(ctx, crit, stream) })
+}
+
 func TestGetLogs(t *testing.T) {
 	assert := assert.New(t)
 	m, _, _ := rpcdaemontest.CreateTestSentry(t)
 	{
 		ethApi := NewEthAPI(newBaseApiForTest(m), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
 
-		logs, err := ethApi.GetLogs(context.Background(), filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(10)})
+		logs, err := ethGetLogs(context.Background(), ethApi, filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(10)})
 		assert.NoError(err)
 		assert.Equal(uint64(10), logs[0].BlockNumber)
 
 		// filter by wrong address
-		logs, err = ethApi.GetLogs(context.Background(), filters.FilterCriteria{
+		logs, err = ethGetLogs(context.Background(), ethApi, filters.FilterCriteria{
 			FromBlock: big.NewInt(10),
 			ToBlock:   big.NewInt(10),
 			Addresses: common.Addresses{libcommon.Address{}},
@@ -63,7 +87,7 @@ func TestGetLogs(t *testing.T) {
 		assert.Equal(0, len(logs))
 
 		// filter by wrong address
-		logs, err = ethApi.GetLogs(m.Ctx, filters.FilterCriteria{
+		logs, err = ethGetLogs(m.Ctx, ethApi, filters.FilterCriteria{
 			FromBlock: big.NewInt(10),
 			ToBlock:   big.NewInt(10),
 			Topics:    [][]libcommon.Hash{{libcommon.HexToHash("0x68f6a0f063c25c6678c443b9a484086f15ba8f91f60218695d32a5251f2050eb")}},
@@ -78,7 +102,7 @@ func TestErigonGetLatestLogs(t *testing.T) {
 	m, _, _ := rpcdaemontest.CreateTestSentry(t)
 	db := m.DB
 	api := NewErigonAPI(newBaseApiForTest(m), db, nil)
-	expectedLogs, _ := api.GetLogs(m.Ctx, filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())})
+	expectedLogs, _ := erigonGetLogs(m.Ctx, api, filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())})
 
 	expectedErigonLogs := make(types.ErigonLogs, 0)
 	for i := len(expectedLogs) - 1; i >= 0; i-- {
@@ -119,12 +143,41 @@ func TestErigonGetLatestLogs(t *testing.T) {
 	assert.EqualValues(expectedLog, actual[0])
 }
 
+func TestErigonGetLogsPage(t *testing.T) {
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	api := NewErigonAPI(newBaseApiForTest(m), m.DB, nil)
+	crit := filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())}
+	expectedLogs, err := erigonGetLogs(m.Ctx, api, crit)
+	require.NoError(t, err)
+	require.NotEmpty(t, expectedLogs)
+
+	var actual types.ErigonLogs
+	cursor := PageCursor{}
+	for pages := 0; ; pages++ {
+		require.LessOrEqual(t, pages, len(expectedLogs))
+		page, err := api.GetLogsPage(m.Ctx, crit, cursor, 1)
+		require.NoError(t, err)
+		actual = append(actual, page.Logs...)
+		if page.NextCursor == nil {
+			break
+		}
+		require.Len(t, page.Logs, 1)
+		cursor = *page.NextCursor
+	}
+	require.Equal(t, expectedLogs, actual)
+
+	_, err = api.GetLogsPage(m.Ctx, crit, PageCursor{}, 0)
+	require.Error(t, err)
+	_, err = api.GetLogsPage(m.Ctx, crit, PageCursor{}, maxPageLimit+1)
+	require.Error(t, err)
+}
+
 func TestErigonGetLatestLogsIgnoreTopics(t *testing.T) {
 	assert := assert.New(t)
 	m, _, _ := rpcdaemontest.CreateTestSentry(t)
 	db := m.DB
 	api := NewErigonAPI(newBaseApiForTest(m), db, nil)
-	expectedLogs, _ := api.GetLogs(m.Ctx, filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())})
+	expectedLogs, _ := erigonGetLogs(m.Ctx, api, filters.FilterCriteria{FromBlock: big.NewInt(0), ToBlock: big.NewInt(rpc.LatestBlockNumber.Int64())})
 
 	expectedErigonLogs := make([]*types.ErigonLog, 0)
 	for i := len(expectedLogs) - 1; i >= 0; i-- {
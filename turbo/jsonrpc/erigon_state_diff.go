@@ -0,0 +1,215 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/rawdbv3"
+
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/rpchelper"
+)
+
+// stateDiffDomains - order in which erigon_getStateDiff returns changes
+var stateDiffDomains = []kv.Domain{kv.AccountsDomain, kv.StorageDomain, kv.CodeDomain}
+
+// StateDiffCursor - opaque continuation token of erigon_getStateDiff: domain and first key of next page.
+// Empty cursor ("0x") means "first page".
+type StateDiffCursor struct {
+	domain kv.Domain
+	key    []byte
+}
+
+func (c StateDiffCursor) MarshalText() ([]byte, error) {
+	if c.domain == kv.AccountsDomain && len(c.key) == 0 {
+		return []byte("0x"), nil
+	}
+	return []byte(hexutility.Encode(append([]byte{byte(c.domain)}, c.key...))), nil
+}
+
+func (c *StateDiffCursor) UnmarshalText(input []byte) error {
+	var b hexutility.Bytes
+	if len(input) > 0 {
+		if err := b.UnmarshalText(input); err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+	}
+	if len(b) == 0 {
+		*c = StateDiffCursor{}
+		return nil
+	}
+	if d := kv.Domain(b[0]); d != kv.AccountsDomain && d != kv.StorageDomain && d != kv.CodeDomain {
+		return fmt.Errorf("invalid cursor domain: %d", b[0])
+	}
+	c.domain, c.key = kv.Domain(b[0]), common.Copy(b[1:])
+	return nil
+}
+
+// ErigonAccountState - account fields. nil means: account doesn't exist
+type ErigonAccountState struct {
+	Balance  *hexutil.Big   `json:"balance"`
+	Nonce    hexutil.Uint64 `json:"nonce"`
+	CodeHash common.Hash    `json:"codeHash"`
+}
+
+type ErigonAccountChange struct {
+	Address common.Address      `json:"address"`
+	Before  *ErigonAccountState `json:"before"`
+	After   *ErigonAccountState `json:"after"`
+}
+
+type ErigonStorageChange struct {
+	Address common.Address `json:"address"`
+	Slot    common.Hash    `json:"slot"`
+	Before  common.Hash    `json:"before"`
+	After   common.Hash    `json:"after"`
+}
+
+type ErigonCodeChange struct {
+	Address common.Address   `json:"address"`
+	Before  hexutility.Bytes `json:"before"`
+	After   hexutility.Bytes `json:"after"`
+}
+
+// ErigonStateDiff - result of erigon_getStateDiff
+type ErigonStateDiff struct {
+	Accounts   []ErigonAccountChange `json:"accounts"`
+	Storage    []ErigonStorageChange `json:"storage"`
+	Code       []ErigonCodeChange    `json:"code"`
+	NextCursor *StateDiffCursor      `json:"nextCursor"` // nil if there are no more pages
+}
+
+// GetStateDiff implements erigon_getStateDiff. Returns accounts, storage slots and code changed by blocks [fromBlock, toBlock]:
+// values before `fromBlock` and after `toBlock`. Changes are read from state history (without re-execution), so values which
+// got back to their original value are not returned. Non-empty `addresses` filter changes.
+// At most `limit` changes are returned with cursor to continue from. Pass empty cursor ("0x") to get first page.
+func (api *ErigonImpl) GetStateDiff(ctx context.Context, fromBlock, toBlock rpc.BlockNumber, cursor StateDiffCursor, limit uint64, addresses []common.Address) (*ErigonStateDiff, error) {
+	if err := checkPageLimit(limit); err != nil {
+		return nil, err
+	}
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	from, _, _, err := rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(fromBlock), tx, api.filters)
+	if err != nil {
+		return nil, err
+	}
+	to, _, _, err := rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(toBlock), tx, api.filters)
+	if err != nil {
+		return nil, err
+	}
+	if to < from {
+		return nil, fmt.Errorf("toBlock (%d) < fromBlock (%d)", to, from)
+	}
+	fromTxNum, err := rawdbv3.TxNums.Min(tx, from)
+	if err != nil {
+		return nil, err
+	}
+	toTxNum, err := rawdbv3.TxNums.Max(tx, to)
+	if err != nil {
+		return nil, err
+	}
+
+	prefixes := make([][]byte, len(addresses))
+	for i := range addresses {
+		prefixes[i] = addresses[i].Bytes()
+	}
+
+	result := &ErigonStateDiff{
+		Accounts: []ErigonAccountChange{},
+		Storage:  []ErigonStorageChange{},
+		Code:     []ErigonCodeChange{},
+	}
+	var taken uint64
+	for _, domain := range stateDiffDomains {
+		if domain < cursor.domain {
+			continue
+		}
+		var fromKey []byte
+		if domain == cursor.domain {
+			fromKey = cursor.key
+		}
+		if err := kv.StateDiff(tx.(kv.TemporalTx), domain, int(fromTxNum), int(toTxNum+1), fromKey, prefixes, func(k, before, after []byte) (bool, error) {
+			if taken == limit {
+				result.NextCursor = &StateDiffCursor{domain: domain, key: common.Copy(k)}
+				return false, nil
+			}
+			taken++
+			return true, result.add(domain, k, before, after)
+		}); err != nil {
+			return nil, err
+		}
+		if result.NextCursor != nil {
+			break
+		}
+	}
+	return result, nil
+}
+
+func (d *ErigonStateDiff) add(domain kv.Domain, k, before, after []byte) error {
+	switch domain {
+	case kv.AccountsDomain:
+		b, err := stateDiffAccount(before)
+		if err != nil {
+			return err
+		}
+		a, err := stateDiffAccount(after)
+		if err != nil {
+			return err
+		}
+		d.Accounts = append(d.Accounts, ErigonAccountChange{Address: common.BytesToAddress(k), Before: b, After: a})
+	case kv.StorageDomain:
+		if len(k) != length.Addr+length.Hash {
+			return fmt.Errorf("unexpected storage key length: %x", k)
+		}
+		d.Storage = append(d.Storage, ErigonStorageChange{
+			Address: common.BytesToAddress(k[:length.Addr]),
+			Slot:    common.BytesToHash(k[length.Addr:]),
+			Before:  common.BytesToHash(before),
+			After:   common.BytesToHash(after),
+		})
+	case kv.CodeDomain:
+		d.Code = append(d.Code, ErigonCodeChange{Address: common.BytesToAddress(k), Before: common.Copy(before), After: common.Copy(after)})
+	}
+	return nil
+}
+
+func stateDiffAccount(enc []byte) (*ErigonAccountState, error) {
+	if len(enc) == 0 {
+		return nil, nil
+	}
+	var acc accounts.Account
+	if err := accounts.DeserialiseV3(&acc, enc); err != nil {
+		return nil, err
+	}
+	return &ErigonAccountState{
+		Balance:  (*hexutil.Big)(acc.Balance.ToBig()),
+		Nonce:    hexutil.Uint64(acc.Nonce),
+		CodeHash: acc.CodeHash,
+	}, nil
+}
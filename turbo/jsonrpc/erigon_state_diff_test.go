@@ -0,0 +1,89 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/erigontech/erigon/rpc"
+)
+
+func TestErigonGetStateDiff(t *testing.T) {
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	api := NewErigonAPI(newBaseApiForTest(m), m.DB, nil)
+	ethApi := NewEthAPI(newBaseApiForTest(m), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+	addr := common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7")
+
+	all, err := api.GetStateDiff(m.Ctx, 1, rpc.LatestBlockNumber, StateDiffCursor{}, maxPageLimit, nil)
+	require.NoError(t, err)
+	require.Nil(t, all.NextCursor)
+	require.NotEmpty(t, all.Accounts)
+	require.NotEmpty(t, all.Storage)
+	require.NotEmpty(t, all.Code)
+
+	// before/after values match state of block 0 and latest block
+	filtered, err := api.GetStateDiff(m.Ctx, 1, rpc.LatestBlockNumber, StateDiffCursor{}, maxPageLimit, []common.Address{addr})
+	require.NoError(t, err)
+	require.Len(t, filtered.Accounts, 1)
+	require.Empty(t, filtered.Storage)
+	change := filtered.Accounts[0]
+	require.Equal(t, addr, change.Address)
+	balanceBefore, err := ethApi.GetBalance(m.Ctx, addr, rpc.BlockNumberOrHashWithNumber(0))
+	require.NoError(t, err)
+	balanceAfter, err := ethApi.GetBalance(m.Ctx, addr, rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber))
+	require.NoError(t, err)
+	require.Equal(t, balanceBefore.ToInt(), change.Before.Balance.ToInt())
+	require.Equal(t, balanceAfter.ToInt(), change.After.Balance.ToInt())
+	require.Zero(t, change.Before.Nonce)
+	require.NotZero(t, change.After.Nonce)
+
+	// pages of 1 change contain same changes
+	var paged ErigonStateDiff
+	cursor := StateDiffCursor{}
+	for pages := 0; ; pages++ {
+		require.LessOrEqual(t, pages, len(all.Accounts)+len(all.Storage)+len(all.Code))
+		page, err := api.GetStateDiff(m.Ctx, 1, rpc.LatestBlockNumber, cursor, 1, nil)
+		require.NoError(t, err)
+		require.Equal(t, 1, len(page.Accounts)+len(page.Storage)+len(page.Code))
+		paged.Accounts = append(paged.Accounts, page.Accounts...)
+		paged.Storage = append(paged.Storage, page.Storage...)
+		paged.Code = append(paged.Code, page.Code...)
+		if page.NextCursor == nil {
+			break
+		}
+
+		// cursor survives json round-trip
+		text, err := page.NextCursor.MarshalText()
+		require.NoError(t, err)
+		require.NoError(t, cursor.UnmarshalText(text))
+		require.Equal(t, *page.NextCursor, cursor)
+	}
+	require.Equal(t, all.Accounts, paged.Accounts)
+	require.Equal(t, all.Storage, paged.Storage)
+	require.Equal(t, all.Code, paged.Code)
+
+	_, err = api.GetStateDiff(m.Ctx, 2, 1, StateDiffCursor{}, 1, nil)
+	require.Error(t, err)
+	_, err = api.GetStateDiff(m.Ctx, 1, rpc.LatestBlockNumber, StateDiffCursor{}, maxPageLimit+1, nil)
+	require.Error(t, err)
+}
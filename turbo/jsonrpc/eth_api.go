@@ -19,6 +19,7 @@ package jsonrpc
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"math/big"
 	"sync"
@@ -27,11 +28,13 @@ import (
 
 	"github.com/erigontech/erigon-lib/common/datadir"
 	"github.com/erigontech/erigon/turbo/jsonrpc/receipts"
+	"github.com/erigontech/erigon/turbo/jsonrpc/resultcache"
 
 	"github.com/erigontech/erigon-lib/common/hexutil"
 
 	lru "github.com/hashicorp/golang-lru/v2"
 	"github.com/holiman/uint256"
+	jsoniter "github.com/json-iterator/go"
 
 	"github.com/erigontech/erigon-lib/log/v3"
 
@@ -75,8 +78,8 @@ type EthAPI interface {
 
 	// Receipt related (see ./eth_receipts.go)
 	GetTransactionReceipt(ctx context.Context, hash common.Hash) (map[string]interface{}, error)
-	GetLogs(ctx context.Context, crit ethFilters.FilterCriteria) (types.Logs, error)
-	GetBlockReceipts(ctx context.Context, numberOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error)
+	GetLogs(ctx context.Context, crit ethFilters.FilterCriteria, stream *jsoniter.Stream) error
+	GetBlockReceipts(ctx context.Context, numberOrHash rpc.BlockNumberOrHash) (json.RawMessage, error)
 
 	// Uncle related (see ./eth_uncles.go)
 	GetUncleByBlockNumberAndIndex(ctx context.Context, blockNr rpc.BlockNumber, index hexutil.Uint) (map[string]interface{}, error)
@@ -144,6 +147,7 @@ type BaseAPI struct {
 	evmCallTimeout    time.Duration
 	dirs              datadir.Dirs
 	receiptsGenerator *receipts.Generator
+	resultCache       *resultcache.Cache // nil if disabled
 }
 
 func NewBaseApi(f *rpchelper.Filters, stateCache kvcache.Cache, blockReader services.FullBlockReader, singleNodeMode bool, evmCallTimeout time.Duration, engine consensus.EngineReader, dirs datadir.Dirs, bridgeReader bridgeReader) *BaseAPI {
@@ -297,6 +301,14 @@ func (api *BaseAPI) headerByRPCNumber(ctx context.Context, number rpc.BlockNumbe
 // history for blocks that have been pruned away giving nonce too low errors
 // etc. as red herrings
 func (api *BaseAPI) checkPruneHistory(tx kv.Tx, block uint64) error {
+	// history removed by previous prune mode is not downloaded yet
+	growPendingFrom, _, err := prune.GrowPendingFrom(tx)
+	if err != nil {
+		return err
+	}
+	if block < growPendingFrom {
+		return errors.New("history has been pruned for this block")
+	}
 	p, err := api.pruneMode(tx)
 	if err != nil {
 		return err
@@ -305,7 +317,9 @@ func (api *BaseAPI) checkPruneHistory(tx kv.Tx, block uint64) error {
 		// no prune info found
 		return nil
 	}
-	if p.History.Enabled() {
+	// re-execution of block needs state and code history
+	stateHistory, codeHistory := p.Retention(prune.StateHistory), p.Retention(prune.CodeHistory)
+	if stateHistory.Enabled() || codeHistory.Enabled() {
 		latest, _, _, err := rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber), tx, api.filters)
 		if err != nil {
 			return err
@@ -313,7 +327,7 @@ func (api *BaseAPI) checkPruneHistory(tx kv.Tx, block uint64) error {
 		if latest <= 1 {
 			return nil
 		}
-		prunedTo := p.History.PruneTo(latest)
+		prunedTo := max(stateHistory.PruneTo(latest), codeHistory.PruneTo(latest))
 		if block < prunedTo {
 			return errors.New("history has been pruned for this block")
 		}
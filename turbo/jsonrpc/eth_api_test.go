@@ -103,6 +103,47 @@ func TestGetStorageAt_ByBlockNumber_WithRequireCanonicalDefault(t *testing.T) {
 	assert.Equal(common.HexToHash("0x0").String(), result)
 }
 
+func TestGetTransactionCount_ByTransactionIndex(t *testing.T) {
+	assert := assert.New(t)
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	api := NewEthAPI(newBaseApiForTest(m), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+	addr := common.HexToAddress("0x71562b71999873db5b286df957af199ec94617f7")
+	ctx := context.Background()
+
+	// block 6 has 32 transactions from addr
+	atBlock := func(blockNum rpc.BlockNumber, txIndex *uint64) rpc.BlockNumberOrHash {
+		bnh := rpc.BlockNumberOrHashWithNumber(blockNum)
+		bnh.TransactionIndex = (*hexutil.Uint64)(txIndex)
+		return bnh
+	}
+	idx := func(i uint64) *uint64 { return &i }
+
+	before, err := api.GetTransactionCount(ctx, addr, atBlock(5, nil))
+	assert.NoError(err)
+	after, err := api.GetTransactionCount(ctx, addr, atBlock(6, nil))
+	assert.NoError(err)
+	assert.Equal(uint64(*before)+32, uint64(*after))
+
+	for _, i := range []uint64{0, 1, 17, 32} {
+		nonce, err := api.GetTransactionCount(ctx, addr, atBlock(6, idx(i)))
+		assert.NoError(err)
+		assert.Equal(uint64(*before)+i, uint64(*nonce), "tx index %d", i)
+	}
+
+	_, err = api.GetTransactionCount(ctx, addr, atBlock(6, idx(33)))
+	assert.ErrorContains(err, "out of range")
+
+	// receiver of first transaction of block
+	receiver := common.Address{}
+	receiver[7] = 1
+	balance, err := api.GetBalance(ctx, receiver, atBlock(6, idx(0)))
+	assert.NoError(err)
+	assert.Zero(balance.ToInt().Sign())
+	balance, err = api.GetBalance(ctx, receiver, atBlock(6, idx(1)))
+	assert.NoError(err)
+	assert.Equal(uint64(1_000_000_000_000_000), balance.ToInt().Uint64())
+}
+
 func TestGetStorageAt_ByBlockHash_WithRequireCanonicalDefault(t *testing.T) {
 	assert := assert.New(t)
 	m, _, _ := rpcdaemontest.CreateTestSentry(t)
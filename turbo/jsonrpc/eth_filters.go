@@ -18,8 +18,10 @@ package jsonrpc
 
 import (
 	"context"
+	"fmt"
 	"strings"
 
+	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/log/v3"
 
 	"github.com/erigontech/erigon/common/debug"
@@ -265,8 +267,17 @@ func (api *APIImpl) NewPendingTransactionsWithBody(ctx context.Context) (*rpc.Su
 	return rpcSub, nil
 }
 
+// logsBackfillQueueLimit - new logs and headers which arrive during backfill of logs subscription are queued.
+// Subscription fails if backfill can't keep up with the chain.
+const logsBackfillQueueLimit = 100_000
+
 // Logs send a notification each time a new log appears.
-func (api *APIImpl) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc.Subscription, error) {
+// If `crit.FromBlock` is set - historical logs starting from this block are sent first, then subscription
+// switches to new logs (without gaps and duplicates). Each log of such subscription has `checkpoint` field:
+// subscribe with it (and the same filter) to resume right after this log - e.g. after reconnect.
+// Logs of reorged blocks are sent again with `removed: true`.
+// If backfill fails, the subscription is ended with an error.
+func (api *APIImpl) Logs(ctx context.Context, crit filters.FilterCriteria, checkpoint *LogsCheckpoint) (*rpc.Subscription, error) {
 	if api.filters == nil {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
@@ -274,27 +285,102 @@ func (api *APIImpl) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc
 	if !supported {
 		return &rpc.Subscription{}, rpc.ErrNotificationsUnsupported
 	}
+	backfill := checkpoint != nil || (crit.FromBlock != nil && crit.FromBlock.Sign() >= 0)
+	if backfill && checkpoint == nil && !crit.FromBlock.IsUint64() {
+		return &rpc.Subscription{}, fmt.Errorf("invalid fromBlock: %v", crit.FromBlock)
+	}
+	if checkpoint != nil {
+		if err := api.checkLogsCheckpoint(ctx, checkpoint); err != nil {
+			return &rpc.Subscription{}, err
+		}
+	}
 
 	rpcSub := notifier.CreateSubscription()
+	// subscribe before backfill - to not miss logs of blocks which appear during it
+	logs, id := api.filters.SubscribeLogs(api.SubscribeLogsChannelSize, crit)
+	headers, headersID := api.filters.SubscribeNewHeads(32)
 
 	go func() {
 		defer debug.LogPanic()
-		logs, id := api.filters.SubscribeLogs(api.SubscribeLogsChannelSize, crit)
 		defer api.filters.UnsubscribeLogs(id)
+		defer api.filters.UnsubscribeHeads(headersID)
+
+		delivery := newLogsDelivery(func(h any) {
+			if err := notifier.Notify(rpcSub.ID, h); err != nil {
+				log.Warn("[rpc] error while notifying subscription", "err", err)
+			}
+		}, backfill, checkpoint)
+
+		fail := func(err error) {
+			if err := notifier.Fail(rpcSub.ID, err); err != nil {
+				log.Warn("[rpc] error while notifying subscription", "err", err)
+			}
+		}
+
+		// new logs and headers are queued until backfill is done
+		var queued []any
+		var backfillDone chan error
+		backfillCtx, cancelBackfill := context.WithCancel(context.Background())
+		defer cancelBackfill()
+		if backfill {
+			backfillDone = make(chan error, 1)
+			go func() {
+				defer debug.LogPanic()
+				backfillDone <- api.backfillLogs(backfillCtx, crit, checkpoint, delivery)
+			}()
+		}
+		handle := func(event any) {
+			switch event := event.(type) {
+			case *types.Log:
+				delivery.send(event, false)
+			case *types.Header:
+				delivery.newHeader(event)
+			}
+		}
+		// enqueueOrHandle - returns false if subscription failed
+		enqueueOrHandle := func(event any) bool {
+			if backfillDone == nil {
+				handle(event)
+				return true
+			}
+			if len(queued) >= logsBackfillQueueLimit {
+				cancelBackfill()
+				<-backfillDone // nothing is sent after failure
+				fail(fmt.Errorf("more than %d new logs and headers arrived during backfill: resubscribe with last received checkpoint", logsBackfillQueueLimit))
+				return false
+			}
+			queued = append(queued, event)
+			return true
+		}
 
 		for {
 			select {
 			case h, ok := <-logs:
-				if h != nil {
-					err := notifier.Notify(rpcSub.ID, h)
-					if err != nil {
-						log.Warn("[rpc] error while notifying subscription", "err", err)
-					}
+				if h != nil && !enqueueOrHandle(h) {
+					return
 				}
 				if !ok {
 					log.Warn("[rpc] log channel was closed")
 					return
 				}
+			case h, ok := <-headers:
+				if h != nil && !enqueueOrHandle(h) {
+					return
+				}
+				if !ok {
+					log.Warn("[rpc] new heads channel was closed")
+					return
+				}
+			case err := <-backfillDone:
+				backfillDone = nil
+				if err != nil {
+					fail(fmt.Errorf("backfill failed: %w", err))
+					return
+				}
+				for _, event := range queued {
+					handle(event)
+				}
+				queued = nil
 			case <-rpcSub.Err():
 				return
 			}
@@ -303,3 +389,52 @@ func (api *APIImpl) Logs(ctx context.Context, crit filters.FilterCriteria) (*rpc
 
 	return rpcSub, nil
 }
+
+// checkLogsCheckpoint - subscription can be resumed only from checkpoint on canonical chain
+func (api *APIImpl) checkLogsCheckpoint(ctx context.Context, checkpoint *LogsCheckpoint) error {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	hash, err := api._blockReader.CanonicalHash(ctx, tx, checkpoint.blockNum)
+	if err != nil {
+		return err
+	}
+	if hash != checkpoint.blockHash {
+		return fmt.Errorf("checkpoint block %d (%x) is not canonical: resubscribe with fromBlock", checkpoint.blockNum, checkpoint.blockHash)
+	}
+	return nil
+}
+
+// backfillLogs - sends historical logs matching `crit`: from `crit.FromBlock` (or right after `checkpoint`)
+// to latest executed block.
+func (api *APIImpl) backfillLogs(ctx context.Context, crit filters.FilterCriteria, checkpoint *LogsCheckpoint, delivery *logsDelivery) error {
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	latest, _, _, err := rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(rpc.LatestExecutedBlockNumber), tx, nil)
+	if err != nil {
+		return err
+	}
+	var begin, skip uint64
+	if checkpoint != nil {
+		begin, skip = checkpoint.blockNum, checkpoint.logs
+	} else {
+		begin = crit.FromBlock.Uint64()
+	}
+	if begin > latest {
+		return nil
+	}
+	return api.iterateLogsV3(ctx, tx.(kv.TemporalTx), begin, latest, crit, nil, func(log *types.ErigonLog) error {
+		if log.BlockNumber == begin && skip > 0 {
+			skip-- // sent before checkpoint
+			return nil
+		}
+		delivery.send(ethLog(log), true)
+		return nil
+	})
+}
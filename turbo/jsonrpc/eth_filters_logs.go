@@ -0,0 +1,194 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+
+	"github.com/erigontech/erigon/core/types"
+)
+
+// logsReorgDepth - logs of this amount of latest blocks are kept by logs subscription: to re-send them with
+// `removed: true` if their block gets reorged
+const logsReorgDepth = 64
+
+// LogsCheckpoint - opaque resume token of logs subscription: position right after `logs`-th matching log of block.
+// It's valid only with the same filter.
+type LogsCheckpoint struct {
+	blockNum  uint64
+	blockHash common.Hash
+	logs      uint64
+}
+
+func (c LogsCheckpoint) MarshalText() ([]byte, error) {
+	var b [8 + 32 + 8]byte
+	binary.BigEndian.PutUint64(b[:], c.blockNum)
+	copy(b[8:], c.blockHash[:])
+	binary.BigEndian.PutUint64(b[40:], c.logs)
+	return []byte(hexutility.Encode(b[:])), nil
+}
+
+func (c *LogsCheckpoint) UnmarshalText(input []byte) error {
+	var b hexutility.Bytes
+	if err := b.UnmarshalText(input); err != nil {
+		return fmt.Errorf("invalid checkpoint: %w", err)
+	}
+	if len(b) != 8+32+8 {
+		return fmt.Errorf("invalid checkpoint length: %d", len(b))
+	}
+	c.blockNum = binary.BigEndian.Uint64(b)
+	c.blockHash = common.BytesToHash(b[8:40])
+	c.logs = binary.BigEndian.Uint64(b[40:])
+	return nil
+}
+
+// checkpointedLog - notification of logs subscription with backfill: log and checkpoint to resume after it
+type checkpointedLog struct {
+	log        *types.Log
+	checkpoint *LogsCheckpoint // nil - nothing to resume from, subscribe with the same `fromBlock` again
+}
+
+func (l checkpointedLog) MarshalJSON() ([]byte, error) {
+	b, err := json.Marshal(l.log)
+	if err != nil || l.checkpoint == nil || len(b) < 2 || b[len(b)-1] != '}' {
+		return b, err
+	}
+	cp, err := json.Marshal(l.checkpoint)
+	if err != nil {
+		return nil, err
+	}
+	b = append(b[:len(b)-1], `,"checkpoint":`...)
+	b = append(b, cp...)
+	return append(b, '}'), nil
+}
+
+// sentLogsBlock - logs of one block sent by subscription
+type sentLogsBlock struct {
+	num        uint64
+	hash       common.Hash
+	skipped    uint64 // logs of this block sent before subscription was resumed from checkpoint
+	logs       []*types.Log
+	backfilled bool
+}
+
+// logsDelivery - sends logs of subscription in order, without duplicates, and re-sends logs of reorged blocks
+// with `removed: true`. Reorgs are detected by block hashes of new logs and new headers: new logs' own `removed`
+// flag is ignored - after unwind it's set on logs of new canonical blocks (see stagedsync.ReadLogs).
+// Not thread-safe.
+type logsDelivery struct {
+	notify          func(any)
+	withCheckpoints bool
+
+	blocks     []*sentLogsBlock // ascending by block number, only latest logsReorgDepth blocks
+	checkpoint *LogsCheckpoint  // after last sent log
+	base       *LogsCheckpoint  // after last log of blocks which are not in `blocks` anymore
+}
+
+func newLogsDelivery(notify func(any), withCheckpoints bool, resumeFrom *LogsCheckpoint) *logsDelivery {
+	return &logsDelivery{notify: notify, withCheckpoints: withCheckpoints, checkpoint: resumeFrom, base: resumeFrom}
+}
+
+func (d *logsDelivery) send(l *types.Log, backfilled bool) {
+	if l.Removed {
+		canonical := *l
+		canonical.Removed = false
+		l = &canonical
+	}
+	// logs come in order: log of not-latest block means either reorg or block already sent by backfill
+	d.reorg(l.BlockNumber, l.BlockHash, false)
+
+	var b *sentLogsBlock
+	if n := len(d.blocks); n > 0 && d.blocks[n-1].hash == l.BlockHash {
+		b = d.blocks[n-1]
+	} else if n > 0 && d.blocks[n-1].num >= l.BlockNumber {
+		return // sent by backfill
+	} else {
+		b = &sentLogsBlock{num: l.BlockNumber, hash: l.BlockHash, backfilled: backfilled}
+		if d.checkpoint != nil && d.checkpoint.blockNum == b.num && d.checkpoint.blockHash == b.hash {
+			b.skipped = d.checkpoint.logs
+		}
+		d.blocks = append(d.blocks, b)
+		d.prune()
+	}
+	if b.backfilled && !backfilled {
+		return // sent by backfill
+	}
+	b.logs = append(b.logs, l)
+	d.checkpoint = &LogsCheckpoint{blockNum: b.num, blockHash: b.hash, logs: b.skipped + uint64(len(b.logs))}
+	d.notify(d.format(l, d.checkpoint))
+}
+
+// newHeader - new canonical block: logs of previous block with the same number are reorged.
+// Headers and logs come by different channels, so header may come after logs of next blocks.
+func (d *logsDelivery) newHeader(h *types.Header) {
+	d.reorg(h.Number.Uint64(), h.Hash(), true)
+}
+
+// reorg - if logs of block `hash` weren't sent, but logs of blocks >= `num` were (or exactly `num` if
+// `sameHeightOnly`): re-sends them with `removed: true`
+func (d *logsDelivery) reorg(num uint64, hash common.Hash, sameHeightOnly bool) {
+	i := len(d.blocks)
+	for i > 0 && d.blocks[i-1].num >= num {
+		i--
+	}
+	if i == len(d.blocks) || (sameHeightOnly && d.blocks[i].num != num) {
+		return
+	}
+	for _, b := range d.blocks[i:] {
+		if b.hash == hash {
+			return
+		}
+	}
+	reorged := d.blocks[i:]
+	d.blocks = d.blocks[:i]
+	d.checkpoint = d.base
+	if i > 0 {
+		last := d.blocks[i-1]
+		d.checkpoint = &LogsCheckpoint{blockNum: last.num, blockHash: last.hash, logs: last.skipped + uint64(len(last.logs))}
+	}
+	for j := len(reorged) - 1; j >= 0; j-- {
+		for k := len(reorged[j].logs) - 1; k >= 0; k-- {
+			removed := *reorged[j].logs[k]
+			removed.Removed = true
+			d.notify(d.format(&removed, d.checkpoint))
+		}
+	}
+}
+
+func (d *logsDelivery) prune() {
+	newest := d.blocks[len(d.blocks)-1].num
+	i := 0
+	for ; i < len(d.blocks) && d.blocks[i].num+logsReorgDepth <= newest; i++ {
+		b := d.blocks[i]
+		d.base = &LogsCheckpoint{blockNum: b.num, blockHash: b.hash, logs: b.skipped + uint64(len(b.logs))}
+	}
+	if i > 0 {
+		d.blocks = append(d.blocks[:0:0], d.blocks[i:]...)
+	}
+}
+
+func (d *logsDelivery) format(l *types.Log, checkpoint *LogsCheckpoint) any {
+	if !d.withCheckpoints {
+		return l
+	}
+	return checkpointedLog{log: l, checkpoint: checkpoint}
+}
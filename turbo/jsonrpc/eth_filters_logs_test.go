@@ -0,0 +1,114 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"encoding/json"
+	"math/big"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	libcommon "github.com/erigontech/erigon-lib/common"
+
+	"github.com/erigontech/erigon/core/types"
+)
+
+func TestLogsCheckpointJSON(t *testing.T) {
+	cp := LogsCheckpoint{blockNum: 10, blockHash: libcommon.Hash{1}, logs: 3}
+	b, err := json.Marshal(cp)
+	require.NoError(t, err)
+	var decoded LogsCheckpoint
+	require.NoError(t, json.Unmarshal(b, &decoded))
+	require.Equal(t, cp, decoded)
+	require.Error(t, json.Unmarshal([]byte(`"0x01"`), &decoded))
+
+	b, err = json.Marshal(checkpointedLog{log: &types.Log{BlockNumber: 10}, checkpoint: &cp})
+	require.NoError(t, err)
+	var withCheckpoint struct {
+		BlockNumber string          `json:"blockNumber"`
+		Checkpoint  *LogsCheckpoint `json:"checkpoint"`
+	}
+	require.NoError(t, json.Unmarshal(b, &withCheckpoint))
+	require.Equal(t, "0xa", withCheckpoint.BlockNumber)
+	require.Equal(t, cp, *withCheckpoint.Checkpoint)
+}
+
+func TestLogsDelivery(t *testing.T) {
+	var sent []checkpointedLog
+	notify := func(l any) { sent = append(sent, l.(checkpointedLog)) }
+	newLog := func(num uint64, hash byte) *types.Log {
+		return &types.Log{BlockNumber: num, BlockHash: libcommon.Hash{hash}}
+	}
+
+	t.Run("reorg by header", func(t *testing.T) {
+		sent = nil
+		d := newLogsDelivery(notify, true, nil)
+		d.send(newLog(1, 1), true)
+		d.send(newLog(2, 2), true)
+		d.send(newLog(2, 2), true)
+		d.send(newLog(2, 2), false) // duplicate of backfilled
+		require.Len(t, sent, 3)
+		require.Equal(t, LogsCheckpoint{blockNum: 2, blockHash: libcommon.Hash{2}, logs: 2}, *sent[2].checkpoint)
+
+		d.newHeader(&types.Header{Number: big.NewInt(3)}) // not reorg
+		require.Len(t, sent, 3)
+		d.newHeader(&types.Header{Number: big.NewInt(2)})
+		require.Len(t, sent, 5)
+		for _, l := range sent[3:] {
+			require.True(t, l.log.Removed)
+			require.Equal(t, LogsCheckpoint{blockNum: 1, blockHash: libcommon.Hash{1}, logs: 1}, *l.checkpoint)
+		}
+		require.False(t, sent[1].log.Removed)
+	})
+
+	t.Run("resume", func(t *testing.T) {
+		sent = nil
+		cp := &LogsCheckpoint{blockNum: 1, blockHash: libcommon.Hash{1}, logs: 5}
+		d := newLogsDelivery(notify, true, cp)
+		d.send(newLog(1, 1), true)
+		require.Equal(t, uint64(6), sent[0].checkpoint.logs)
+		d.send(newLog(1, 0x11), false) // reorg of block 1
+		require.Len(t, sent, 3)
+		require.True(t, sent[1].log.Removed)
+		require.Equal(t, cp, sent[1].checkpoint)
+		require.Equal(t, LogsCheckpoint{blockNum: 1, blockHash: libcommon.Hash{0x11}, logs: 1}, *sent[2].checkpoint)
+	})
+
+	t.Run("logs after unwind", func(t *testing.T) {
+		sent = nil
+		d := newLogsDelivery(notify, true, nil)
+		d.send(newLog(1, 1), false)
+		unwound := newLog(1, 0x11) // log of new canonical block is flagged as removed by unwind
+		unwound.Removed = true
+		d.send(unwound, false)
+		require.Len(t, sent, 3)
+		require.True(t, sent[1].log.Removed)
+		require.Equal(t, libcommon.Hash{1}, sent[1].log.BlockHash)
+		require.False(t, sent[2].log.Removed)
+		require.Equal(t, libcommon.Hash{0x11}, sent[2].log.BlockHash)
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		d := newLogsDelivery(func(any) {}, false, nil)
+		for i := uint64(1); i <= 2*logsReorgDepth; i++ {
+			d.send(newLog(i, byte(i)), false)
+		}
+		require.Len(t, d.blocks, logsReorgDepth)
+		require.Equal(t, uint64(logsReorgDepth), d.base.blockNum)
+	})
+}
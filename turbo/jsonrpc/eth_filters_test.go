@@ -17,7 +17,13 @@
 package jsonrpc
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -28,14 +34,23 @@ import (
 	"github.com/erigontech/erigon/rpc/rpccfg"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 
+	"github.com/erigontech/erigon-lib/gointerfaces"
+	remote "github.com/erigontech/erigon-lib/gointerfaces/remoteproto"
 	txpool "github.com/erigontech/erigon-lib/gointerfaces/txpoolproto"
+	typesproto "github.com/erigontech/erigon-lib/gointerfaces/typesproto"
+	"github.com/erigontech/erigon-lib/kv"
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 
 	"github.com/erigontech/erigon-lib/log/v3"
 
 	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcdaemontest"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
 	"github.com/erigontech/erigon/eth/filters"
+	"github.com/erigontech/erigon/eth/stagedsync"
+	"github.com/erigontech/erigon/rpc"
 	"github.com/erigontech/erigon/turbo/rpchelper"
 	"github.com/erigontech/erigon/turbo/stages/mock"
 )
@@ -117,3 +132,203 @@ func TestLogsSubscribeAndUnsubscribe_WithoutConcurrentMapIssue(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestLogsSubscribeBackfill(t *testing.T) {
+	require := require.New(t)
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, nil, nil, nil, func() {}, m.Log)
+	api := NewEthAPI(NewBaseApi(ff, kvcache.New(kvcache.DefaultCoherentConfig), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+
+	srv := rpc.NewServer(50, false, false, true, log.New(), 0)
+	require.NoError(srv.RegisterName("eth", api))
+	client := rpc.DialInProc(srv, log.New())
+	defer client.Close()
+
+	type notification struct {
+		types.Log
+		Checkpoint *LogsCheckpoint `json:"checkpoint"`
+	}
+	next := func(ch chan json.RawMessage, sub *rpc.ClientSubscription) notification {
+		select {
+		case msg := <-ch:
+			var n notification
+			require.NoError(json.Unmarshal(msg, &n.Log))
+			require.NoError(json.Unmarshal(msg, &struct {
+				Checkpoint **LogsCheckpoint `json:"checkpoint"`
+			}{&n.Checkpoint}))
+			return n
+		case err := <-sub.Err():
+			t.Fatal(err)
+		case <-time.After(10 * time.Second):
+			t.Fatal("timeout")
+		}
+		return notification{}
+	}
+
+	logs := make(chan json.RawMessage, 8)
+	sub, err := client.EthSubscribe(ctx, logs, "logs", map[string]any{"fromBlock": "0x0"})
+	require.NoError(err)
+	defer sub.Unsubscribe()
+
+	backfilled := next(logs, sub)
+	require.Equal(uint64(10), backfilled.BlockNumber)
+	require.Equal(libcommon.HexToHash("0x6804117de2f3e6ee32953e78ced1db7b20214e0d8c745a03b8fecf7cc8ee76ef"), backfilled.BlockHash)
+	require.NotNil(backfilled.Checkpoint)
+
+	newLog := func(blockNum uint64, blockHash libcommon.Hash) *remote.SubscribeLogsReply {
+		return &remote.SubscribeLogsReply{
+			Address:         gointerfaces.ConvertAddressToH160(backfilled.Address),
+			BlockHash:       gointerfaces.ConvertHashToH256(blockHash),
+			BlockNumber:     blockNum,
+			Data:            backfilled.Data,
+			Topics:          []*typesproto.H256{gointerfaces.ConvertHashToH256(backfilled.Topics[0])},
+			TransactionHash: gointerfaces.ConvertHashToH256(backfilled.TxHash),
+		}
+	}
+	ff.OnNewLogs(newLog(10, backfilled.BlockHash)) // already sent by backfill
+	ff.OnNewLogs(newLog(11, libcommon.Hash{11}))
+	live := next(logs, sub)
+	require.Equal(uint64(11), live.BlockNumber)
+	require.False(live.Removed)
+
+	// reorg of block 11
+	ff.OnNewLogs(newLog(11, libcommon.Hash{0x11}))
+	removed := next(logs, sub)
+	require.Equal(libcommon.Hash{11}, removed.BlockHash)
+	require.True(removed.Removed)
+	require.Equal(backfilled.Checkpoint, removed.Checkpoint)
+	replaced := next(logs, sub)
+	require.Equal(libcommon.Hash{0x11}, replaced.BlockHash)
+	require.False(replaced.Removed)
+
+	// resume after backfilled log: nothing to backfill
+	resumed := make(chan json.RawMessage, 8)
+	sub2, err := client.EthSubscribe(ctx, resumed, "logs", map[string]any{}, backfilled.Checkpoint)
+	require.NoError(err)
+	defer sub2.Unsubscribe()
+	ff.OnNewLogs(newLog(12, libcommon.Hash{12}))
+	require.Equal(uint64(12), next(resumed, sub2).BlockNumber)
+
+	_, err = client.EthSubscribe(ctx, resumed, "logs", map[string]any{}, &LogsCheckpoint{blockNum: 10, blockHash: libcommon.Hash{10}})
+	require.ErrorContains(err, "not canonical")
+}
+
+// failingRoDB - every read transaction fails
+type failingRoDB struct{ kv.RoDB }
+
+func (failingRoDB) BeginRo(context.Context) (kv.Tx, error) { return nil, errors.New("db is broken") }
+
+func TestLogsSubscribeBackfillFailure(t *testing.T) {
+	require := require.New(t)
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, nil, nil, nil, func() {}, m.Log)
+	api := NewEthAPI(NewBaseApi(ff, kvcache.New(kvcache.DefaultCoherentConfig), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil), failingRoDB{m.DB}, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+
+	srv := rpc.NewServer(50, false, false, true, log.New(), 0)
+	require.NoError(srv.RegisterName("eth", api))
+	client := rpc.DialInProc(srv, log.New())
+	defer client.Close()
+
+	logs := make(chan json.RawMessage, 8)
+	sub, err := client.EthSubscribe(ctx, logs, "logs", map[string]any{"fromBlock": "0x0"})
+	require.NoError(err)
+	select {
+	case err := <-sub.Err():
+		require.ErrorContains(err, "backfill failed: db is broken")
+	case <-logs:
+		t.Fatal("unexpected log")
+	case <-time.After(10 * time.Second):
+		t.Fatal("timeout")
+	}
+}
+
+func TestGetLogsValidationError(t *testing.T) {
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	api := NewEthAPI(newBaseApiForTest(m), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+	srv := rpc.NewServer(50, false, false, false /* streaming */, log.New(), 0)
+	require.NoError(t, srv.RegisterName("eth", api))
+	httpSrv := httptest.NewServer(srv)
+	defer httpSrv.Close()
+
+	for name, params := range map[string]string{
+		"end < begin":   `{"fromBlock":"0x5","toBlock":"0x1"}`,
+		"unknown block": `{"blockHash":"0x0000000000000000000000000000000000000000000000000000000000000001"}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			req := `{"jsonrpc":"2.0","id":1,"method":"eth_getLogs","params":[` + params + `]}`
+			resp, err := http.Post(httpSrv.URL, "application/json", strings.NewReader(req))
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			var res map[string]json.RawMessage
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&res))
+			require.NotContains(t, res, "result")
+			require.Contains(t, res, "error")
+		})
+	}
+}
+
+// filtersNotifier - delivers stagedsync notifications straight to rpchelper.Filters, as remote KV server does
+type filtersNotifier struct{ ff *rpchelper.Filters }
+
+func (n filtersNotifier) OnNewHeader([][]byte)        {}
+func (n filtersNotifier) OnNewPendingLogs(types.Logs) {}
+func (n filtersNotifier) HasLogSubsriptions() bool    { return true }
+func (n filtersNotifier) OnLogs(logs []*remote.SubscribeLogsReply) {
+	for _, l := range logs {
+		n.ff.OnNewLogs(l)
+	}
+}
+
+func TestFilterChangesAcrossUnwind(t *testing.T) {
+	require := require.New(t)
+	m, _, _ := rpcdaemontest.CreateTestSentry(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, nil, nil, nil, func() {}, m.Log)
+	api := NewEthAPI(NewBaseApi(ff, kvcache.New(kvcache.DefaultCoherentConfig), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil), m.DB, nil, nil, nil, 5000000, 1e18, 100_000, false, 100_000, 128, log.New())
+
+	// logs which stage Finish notifies about
+	require.NoError(m.DB.Update(ctx, func(tx kv.RwTx) error {
+		block, err := m.BlockReader.BlockByNumber(ctx, tx, 10)
+		require.NoError(err)
+		require.NotEmpty(block.Transactions())
+		receipts := make(types.Receipts, len(block.Transactions()))
+		for i := range receipts {
+			receipts[i] = &types.Receipt{}
+		}
+		receipts[0].Logs = []*types.Log{{Address: libcommon.Address{1}, Data: []byte{1}}}
+		return rawdb.WriteReceipts(tx, 10, receipts)
+	}))
+
+	id, err := api.NewFilter(ctx, filters.FilterCriteria{})
+	require.NoError(err)
+	notify := func(finishBefore uint64, unwindTo *uint64) *types.Log {
+		tx, err := m.DB.BeginRo(ctx)
+		require.NoError(err)
+		defer tx.Rollback()
+		require.NoError(stagedsync.NotifyNewHeaders(ctx, finishBefore, 10, unwindTo, filtersNotifier{ff}, tx, m.Log, m.BlockReader))
+		var changes []any
+		require.Eventually(func() bool {
+			changes, err = api.GetFilterChanges(ctx, id)
+			require.NoError(err)
+			return len(changes) > 0
+		}, 10*time.Second, 10*time.Millisecond)
+		require.Len(changes, 1)
+		return changes[0].(*types.Log)
+	}
+
+	added := notify(9, nil)
+	require.Equal(uint64(10), added.BlockNumber)
+	require.Equal(libcommon.Address{1}, added.Address)
+	require.False(added.Removed)
+
+	// block 10 is unwound and executed again
+	unwindTo := uint64(9)
+	reorged := notify(10, &unwindTo)
+	require.Equal(uint64(10), reorged.BlockNumber)
+	require.True(reorged.Removed)
+}
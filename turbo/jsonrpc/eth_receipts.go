@@ -18,9 +18,11 @@ package jsonrpc
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 
 	"github.com/RoaringBitmap/roaring"
+	jsoniter "github.com/json-iterator/go"
 
 	"github.com/erigontech/erigon-lib/log/v3"
 
@@ -52,23 +54,34 @@ func (api *BaseAPI) getReceipts(ctx context.Context, tx kv.Tx, block *types.Bloc
 }
 
 // GetLogs implements eth_getLogs. Returns an array of logs matching a given filter object.
-func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (types.Logs, error) {
-	var begin, end uint64
-	logs := types.Logs{}
-
+// Logs are written to `stream` as soon as they are found: response size is not limited by RAM.
+func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria, stream *jsoniter.Stream) error {
 	tx, beginErr := api.db.BeginRo(ctx)
 	if beginErr != nil {
-		return logs, beginErr
+		return rpc.PreStreamError(beginErr)
 	}
 	defer tx.Rollback()
 
+	begin, end, err := api.getLogsRange(ctx, tx, crit)
+	if err != nil {
+		return rpc.PreStreamError(err)
+	}
+
+	// closed range below finalized block can't change: reorg of `end` will invalidate cached result
+	return cachedStream(ctx, api.BaseAPI, tx, end, "eth_getLogs", []any{begin, end, crit.Addresses, crit.Topics}, stream, func(stream *jsoniter.Stream) error {
+		return api.writeLogsV3(ctx, tx.(kv.TemporalTx), begin, end, crit, stream, func(log *types.ErigonLog) any { return ethLog(log) })
+	})
+}
+
+// getLogsRange - validates `crit` and resolves it to range of blocks [begin, end]
+func (api *APIImpl) getLogsRange(ctx context.Context, tx kv.Tx, crit filters.FilterCriteria) (begin, end uint64, err error) {
 	if crit.BlockHash != nil {
 		block, err := api.blockByHashWithSenders(ctx, tx, *crit.BlockHash)
 		if err != nil {
-			return nil, err
+			return 0, 0, err
 		}
 		if block == nil {
-			return nil, fmt.Errorf("block not found: %x", *crit.BlockHash)
+			return 0, 0, fmt.Errorf("block not found: %x", *crit.BlockHash)
 		}
 
 		num := block.NumberU64()
@@ -78,7 +91,7 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (t
 		// Convert the RPC block numbers into internal representations
 		latest, _, _, err := rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(rpc.LatestExecutedBlockNumber), tx, nil)
 		if err != nil {
-			return nil, err
+			return 0, 0, err
 		}
 
 		begin = latest
@@ -90,7 +103,7 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (t
 				blockNum := rpc.BlockNumber(fromBlock)
 				begin, _, _, err = rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(blockNum), tx, api.filters)
 				if err != nil {
-					return nil, err
+					return 0, 0, err
 				}
 			}
 
@@ -104,45 +117,68 @@ func (api *APIImpl) GetLogs(ctx context.Context, crit filters.FilterCriteria) (t
 				blockNum := rpc.BlockNumber(toBlock)
 				end, _, _, err = rpchelper.GetBlockNumber(rpc.BlockNumberOrHashWithNumber(blockNum), tx, api.filters)
 				if err != nil {
-					return nil, err
+					return 0, 0, err
 				}
 			}
 		}
 	}
 
 	if end < begin {
-		return nil, fmt.Errorf("end (%d) < begin (%d)", end, begin)
+		return 0, 0, fmt.Errorf("end (%d) < begin (%d)", end, begin)
 	}
 	if end > roaring.MaxUint32 {
 		latest, err := rpchelper.GetLatestBlockNumber(tx)
 		if err != nil {
-			return nil, err
+			return 0, 0, err
 		}
 		if begin > latest {
-			return nil, fmt.Errorf("begin (%d) > latest (%d)", begin, latest)
+			return 0, 0, fmt.Errorf("begin (%d) > latest (%d)", begin, latest)
 		}
 		end = latest
 	}
+	return begin, end, nil
+}
 
-	erigonLogs, err := api.getLogsV3(ctx, tx.(kv.TemporalTx), begin, end, crit)
-	if err != nil {
-		return nil, err
+// ethLog - converts log to eth_getLogs format (without timestamp)
+func ethLog(log *types.ErigonLog) *types.Log {
+	return &types.Log{
+		Address:     log.Address,
+		Topics:      log.Topics,
+		Data:        log.Data,
+		BlockNumber: log.BlockNumber,
+		TxHash:      log.TxHash,
+		TxIndex:     log.TxIndex,
+		BlockHash:   log.BlockHash,
+		Index:       log.Index,
+		Removed:     log.Removed,
 	}
-	logs = make(types.Logs, len(erigonLogs))
-	for i, log := range erigonLogs {
-		logs[i] = &types.Log{
-			Address:     log.Address,
-			Topics:      log.Topics,
-			Data:        log.Data,
-			BlockNumber: log.BlockNumber,
-			TxHash:      log.TxHash,
-			TxIndex:     log.TxIndex,
-			BlockHash:   log.BlockHash,
-			Index:       log.Index,
-			Removed:     log.Removed,
+}
+
+// writeLogsV3 - writes JSON array of logs to `stream` while iterating over them. `format` converts log to
+// method-specific JSON representation.
+// In case of error array is closed anyway - to keep response valid JSON.
+func (api *BaseAPI) writeLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end uint64, crit filters.FilterCriteria, stream *jsoniter.Stream, format func(log *types.ErigonLog) any) error {
+	stream.WriteArrayStart()
+	first := true
+	err := api.iterateLogsV3(ctx, tx, begin, end, crit, nil, func(log *types.ErigonLog) error {
+		b, err := json.Marshal(format(log))
+		if err != nil {
+			return err
 		}
+		if first {
+			first = false
+		} else {
+			stream.WriteMore()
+		}
+		// if stream has writer - this goes directly to client
+		_, err = stream.Write(b)
+		return err
+	})
+	stream.WriteArrayEnd()
+	if err != nil {
+		return err
 	}
-	return logs, nil
+	return stream.Flush()
 }
 
 // The Topic list restricts matches to particular event topics. Each event has a list
@@ -224,6 +260,11 @@ func applyFilters(out *roaring.Bitmap, tx kv.Tx, begin, end uint64, crit filters
 }
 
 func applyFiltersV3(tx kv.TemporalTx, begin, end uint64, crit filters.FilterCriteria) (out stream.U64, err error) {
+	return applyFiltersFromTxNumV3(tx, begin, end, nil, crit)
+}
+
+// applyFiltersFromTxNumV3 - same as applyFiltersV3, but skips txNums of previous pages
+func applyFiltersFromTxNumV3(tx kv.TemporalTx, begin, end uint64, page *pager, crit filters.FilterCriteria) (out stream.U64, err error) {
 	//[from,to)
 	var fromTxNum, toTxNum uint64
 	if begin > 0 {
@@ -232,6 +273,7 @@ func applyFiltersV3(tx kv.TemporalTx, begin, end uint64, crit filters.FilterCrit
 			return out, err
 		}
 	}
+	fromTxNum = page.fromTxNum(fromTxNum)
 	toTxNum, err = rawdbv3.TxNums.Max(tx, end)
 	if err != nil {
 		return out, err
@@ -262,9 +304,22 @@ func applyFiltersV3(tx kv.TemporalTx, begin, end uint64, crit filters.FilterCrit
 	return out, nil
 }
 
-func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end uint64, crit filters.FilterCriteria) ([]*types.ErigonLog, error) {
+// getLogsV3 - if `page` is not nil: returns only logs of this page
+func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end uint64, crit filters.FilterCriteria, page *pager) ([]*types.ErigonLog, error) {
 	logs := []*types.ErigonLog{}
+	if err := api.iterateLogsV3(ctx, tx, begin, end, crit, page, func(log *types.ErigonLog) error {
+		logs = append(logs, log)
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+	return logs, nil
+}
 
+// iterateLogsV3 - calls `yield` for each log matching `crit` in blocks [begin, end], in order.
+// Logs are found by LogAddrIdx/LogTopicIdx inverted indices and re-execution of matched txs only.
+// if `page` is not nil: yields only logs of this page
+func (api *BaseAPI) iterateLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end uint64, crit filters.FilterCriteria, page *pager, yield func(log *types.ErigonLog) error) error {
 	addrMap := make(map[common.Address]struct{}, len(crit.Addresses))
 	for _, v := range crit.Addresses {
 		addrMap[v] = struct{}{}
@@ -272,27 +327,27 @@ func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end
 
 	chainConfig, err := api.chainConfig(ctx, tx)
 	if err != nil {
-		return nil, err
+		return err
 	}
 	exec := exec3.NewTraceWorker(tx, chainConfig, api.engine(), api._blockReader, nil)
 
 	var blockHash common.Hash
 	var header *types.Header
 
-	txNumbers, err := applyFiltersV3(tx, begin, end, crit)
+	txNumbers, err := applyFiltersFromTxNumV3(tx, begin, end, page, crit)
 	if err != nil {
-		return logs, err
+		return err
 	}
 	it := rawdbv3.TxNums2BlockNums(tx, txNumbers, order.Asc)
 	defer it.Close()
 	var timestamp uint64
-	for it.HasNext() {
+	for it.HasNext() && !page.done() {
 		if err = ctx.Err(); err != nil {
-			return nil, err
+			return err
 		}
 		txNum, blockNum, txIndex, isFinalTxn, blockNumChanged, err := it.Next()
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if isFinalTxn {
 			continue
@@ -302,7 +357,7 @@ func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end
 
 		if blockNumChanged {
 			if header, err = api._blockReader.HeaderByNumber(ctx, tx, blockNum); err != nil {
-				return nil, err
+				return err
 			}
 			if header == nil {
 				log.Warn("[rpc] header is nil", "blockNum", blockNum)
@@ -316,7 +371,7 @@ func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end
 		//fmt.Printf("txNum=%d, blockNum=%d, txIndex=%d, maxTxNumInBlock=%d,mixTxNumInBlock=%d\n", txNum, blockNum, txIndex, maxTxNumInBlock, minTxNumInBlock)
 		txn, err := api._txnReader.TxnByIdxInBlock(ctx, tx, blockNum, txIndex)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		if txn == nil {
 			continue
@@ -324,7 +379,7 @@ func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end
 
 		_, err = exec.ExecTxn(txNum, txIndex, txn)
 		if err != nil {
-			return nil, err
+			return err
 		}
 		rawLogs := exec.GetLogs(txIndex, txn)
 		//TODO: logIndex within the block! no way to calc it now
@@ -341,7 +396,10 @@ func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end
 		}
 		//TODO: maybe Logs by default and enreach them with
 		for _, filteredLog := range filtered {
-			logs = append(logs, &types.ErigonLog{
+			if !page.take(txNum) {
+				continue
+			}
+			if err := yield(&types.ErigonLog{
 				Address:     filteredLog.Address,
 				Topics:      filteredLog.Topics,
 				Data:        filteredLog.Data,
@@ -352,13 +410,15 @@ func (api *BaseAPI) getLogsV3(ctx context.Context, tx kv.TemporalTx, begin, end
 				Index:       filteredLog.Index,
 				Removed:     filteredLog.Removed,
 				Timestamp:   timestamp,
-			})
+			}); err != nil {
+				return err
+			}
 		}
 	}
 
 	//stats := api._agg.GetAndResetStats()
 	//log.Info("Finished", "duration", time.Since(start), "history queries", stats.FilesQueries, "ef search duration", stats.EfSearchTime)
-	return logs, nil
+	return nil
 }
 
 // The Topic list restricts matches to particular event topics. Each event has a list
@@ -489,7 +549,7 @@ func (api *APIImpl) GetTransactionReceipt(ctx context.Context, txnHash common.Ha
 }
 
 // GetBlockReceipts - receipts for individual block
-func (api *APIImpl) GetBlockReceipts(ctx context.Context, numberOrHash rpc.BlockNumberOrHash) ([]map[string]interface{}, error) {
+func (api *APIImpl) GetBlockReceipts(ctx context.Context, numberOrHash rpc.BlockNumberOrHash) (json.RawMessage, error) {
 	tx, err := api.db.BeginRo(ctx)
 	if err != nil {
 		return nil, err
@@ -500,41 +560,43 @@ func (api *APIImpl) GetBlockReceipts(ctx context.Context, numberOrHash rpc.Block
 	if err != nil {
 		return nil, err
 	}
-	block, err := api.blockWithSenders(ctx, tx, blockHash, blockNum)
-	if err != nil {
-		return nil, err
-	}
-	if block == nil {
-		return nil, nil
-	}
-	chainConfig, err := api.chainConfig(ctx, tx)
-	if err != nil {
-		return nil, err
-	}
-	receipts, err := api.getReceipts(ctx, tx, block)
-	if err != nil {
-		return nil, fmt.Errorf("getReceipts error: %w", err)
-	}
-	result := make([]map[string]interface{}, 0, len(receipts))
-	for _, receipt := range receipts {
-		txn := block.Transactions()[receipt.TransactionIndex]
-		result = append(result, ethutils.MarshalReceipt(receipt, txn, chainConfig, block.HeaderNoCopy(), txn.Hash(), true))
-	}
+	return cachedResult(ctx, api.BaseAPI, tx, blockNum, "eth_getBlockReceipts", []any{blockNum}, func() ([]map[string]interface{}, error) {
+		block, err := api.blockWithSenders(ctx, tx, blockHash, blockNum)
+		if err != nil {
+			return nil, err
+		}
+		if block == nil {
+			return nil, nil
+		}
+		chainConfig, err := api.chainConfig(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		receipts, err := api.getReceipts(ctx, tx, block)
+		if err != nil {
+			return nil, fmt.Errorf("getReceipts error: %w", err)
+		}
+		result := make([]map[string]interface{}, 0, len(receipts))
+		for _, receipt := range receipts {
+			txn := block.Transactions()[receipt.TransactionIndex]
+			result = append(result, ethutils.MarshalReceipt(receipt, txn, chainConfig, block.HeaderNoCopy(), txn.Hash(), true))
+		}
 
-	if chainConfig.Bor != nil {
-		borTx := rawdb.ReadBorTransactionForBlock(tx, blockNum)
-		if borTx != nil {
-			borReceipt, err := rawdb.ReadBorReceipt(tx, block.Hash(), blockNum, receipts)
-			if err != nil {
-				return nil, err
-			}
-			if borReceipt != nil {
-				result = append(result, ethutils.MarshalReceipt(borReceipt, borTx, chainConfig, block.HeaderNoCopy(), borReceipt.TxHash, false))
+		if chainConfig.Bor != nil {
+			borTx := rawdb.ReadBorTransactionForBlock(tx, blockNum)
+			if borTx != nil {
+				borReceipt, err := rawdb.ReadBorReceipt(tx, block.Hash(), blockNum, receipts)
+				if err != nil {
+					return nil, err
+				}
+				if borReceipt != nil {
+					result = append(result, ethutils.MarshalReceipt(borReceipt, borTx, chainConfig, block.HeaderNoCopy(), borReceipt.TxHash, false))
+				}
 			}
 		}
-	}
 
-	return result, nil
+		return result, nil
+	})
 }
 
 // MapTxNum2BlockNumIter - enrich iterator by TxNumbers, adding more info:
@@ -51,13 +51,15 @@ type TransactionsWithReceipts struct {
 	Receipts  []map[string]interface{} `json:"receipts"`
 	FirstPage bool                     `json:"firstPage"`
 	LastPage  bool                     `json:"lastPage"`
+	// NextCursor - set only if search was requested with cursor. nil if there are no more pages.
+	NextCursor *PageCursor `json:"nextCursor,omitempty"`
 }
 
 type OtterscanAPI interface {
 	GetApiLevel() uint8
 	GetInternalOperations(ctx context.Context, hash common.Hash) ([]*InternalOperation, error)
-	SearchTransactionsBefore(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error)
-	SearchTransactionsAfter(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error)
+	SearchTransactionsBefore(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16, cursor *PageCursor) (*TransactionsWithReceipts, error)
+	SearchTransactionsAfter(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16, cursor *PageCursor) (*TransactionsWithReceipts, error)
 	GetBlockDetails(ctx context.Context, number rpc.BlockNumber) (map[string]interface{}, error)
 	GetBlockDetailsByHash(ctx context.Context, hash common.Hash) (map[string]interface{}, error)
 	GetBlockTransactions(ctx context.Context, number rpc.BlockNumber, pageNumber uint8, pageSize uint8) (map[string]interface{}, error)
@@ -187,7 +189,10 @@ func (api *OtterscanAPIImpl) GetInternalOperations(ctx context.Context, hash com
 // they are just returned. But it may return a little more than pageSize if there are more txs
 // than the necessary to fill pageSize in the last found block, i.e., let's say you want pageSize == 25,
 // you already found 24 txs, the next block contains 4 matches, then this function will return 28 txs.
-func (api *OtterscanAPIImpl) SearchTransactionsBefore(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error) {
+//
+// Optional cursor (empty "0x" for first page) makes pages exactly pageSize long: search continues
+// from the returned nextCursor, blockNum is used only for the first page.
+func (api *OtterscanAPIImpl) SearchTransactionsBefore(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16, cursor *PageCursor) (*TransactionsWithReceipts, error) {
 	if uint64(pageSize) > api.maxPageSize {
 		return nil, fmt.Errorf("max allowed page size: %v", api.maxPageSize)
 	}
@@ -198,7 +203,7 @@ func (api *OtterscanAPIImpl) SearchTransactionsBefore(ctx context.Context, addr
 	}
 	defer dbtx.Rollback()
 
-	return api.searchTransactionsBeforeV3(dbtx.(kv.TemporalTx), ctx, addr, blockNum, pageSize)
+	return api.searchTransactionsBeforeV3(dbtx.(kv.TemporalTx), ctx, addr, blockNum, pageSize, cursor)
 }
 
 // Search transactions that touch a certain address.
@@ -209,7 +214,9 @@ func (api *OtterscanAPIImpl) SearchTransactionsBefore(ctx context.Context, addr
 // they are just returned. But it may return a little more than pageSize if there are more txs
 // than the necessary to fill pageSize in the last found block, i.e., let's say you want pageSize == 25,
 // you already found 24 txs, the next block contains 4 matches, then this function will return 28 txs.
-func (api *OtterscanAPIImpl) SearchTransactionsAfter(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error) {
+//
+// Optional cursor works same way as in SearchTransactionsBefore.
+func (api *OtterscanAPIImpl) SearchTransactionsAfter(ctx context.Context, addr common.Address, blockNum uint64, pageSize uint16, cursor *PageCursor) (*TransactionsWithReceipts, error) {
 	if uint64(pageSize) > api.maxPageSize {
 		return nil, fmt.Errorf("max allowed page size: %v", api.maxPageSize)
 	}
@@ -220,7 +227,7 @@ func (api *OtterscanAPIImpl) SearchTransactionsAfter(ctx context.Context, addr c
 	}
 	defer dbtx.Rollback()
 
-	return api.searchTransactionsAfterV3(dbtx.(kv.TemporalTx), ctx, addr, blockNum, pageSize)
+	return api.searchTransactionsAfterV3(dbtx.(kv.TemporalTx), ctx, addr, blockNum, pageSize, cursor)
 }
 
 func (api *OtterscanAPIImpl) traceBlocks(ctx context.Context, addr common.Address, chainConfig *chain.Config, pageSize, resultCount uint16, callFromToProvider BlockProvider) ([]*TransactionsWithReceipts, bool, error) {
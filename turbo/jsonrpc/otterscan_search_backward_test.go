@@ -170,7 +170,7 @@ func TestSearchTransactionsBefore(t *testing.T) {
 	addr := libcommon.HexToAddress("0x537e697c7ab75a26f9ecf0ce810e3154dfcaaf44")
 	t.Run("small page size", func(t *testing.T) {
 		require := require.New(t)
-		results, err := api.SearchTransactionsBefore(m.Ctx, addr, 10, 2)
+		results, err := api.SearchTransactionsBefore(m.Ctx, addr, 10, 2, nil)
 		require.NoError(err)
 		require.False(results.FirstPage)
 		require.False(results.LastPage)
@@ -179,16 +179,34 @@ func TestSearchTransactionsBefore(t *testing.T) {
 	})
 	t.Run("big page size", func(t *testing.T) {
 		require := require.New(t)
-		results, err := api.SearchTransactionsBefore(m.Ctx, addr, 10, 10)
+		results, err := api.SearchTransactionsBefore(m.Ctx, addr, 10, 10, nil)
 		require.NoError(err)
 		require.False(results.FirstPage)
 		require.True(results.LastPage)
 		require.Equal(3, len(results.Txs))
 		require.Equal(3, len(results.Receipts))
 	})
+	t.Run("cursor", func(t *testing.T) {
+		require := require.New(t)
+		var all []*RPCTransaction
+		cursor := &PageCursor{}
+		for i := 0; cursor != nil; i++ {
+			require.Less(i, 3)
+			results, err := api.SearchTransactionsBefore(m.Ctx, addr, 10, 1, cursor)
+			require.NoError(err)
+			require.Equal(1, len(results.Txs))
+			require.Equal(results.NextCursor == nil, results.LastPage)
+			all = append(all, results.Txs...)
+			cursor = results.NextCursor
+		}
+		results, err := api.SearchTransactionsBefore(m.Ctx, addr, 10, 10, nil)
+		require.NoError(err)
+		require.Nil(results.NextCursor)
+		require.Equal(results.Txs, all)
+	})
 	t.Run("filter last block", func(t *testing.T) {
 		require := require.New(t)
-		results, err := api.SearchTransactionsBefore(m.Ctx, addr, 5, 10)
+		results, err := api.SearchTransactionsBefore(m.Ctx, addr, 5, 10, nil)
 
 		require.NoError(err)
 		require.False(results.FirstPage)
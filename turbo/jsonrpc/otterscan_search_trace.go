@@ -138,5 +138,5 @@ func (api *OtterscanAPIImpl) traceBlock(dbtx kv.Tx, ctx context.Context, blockNu
 		}
 	}
 
-	return found, &TransactionsWithReceipts{rpcTxs, receipts, false, false}, nil
+	return found, &TransactionsWithReceipts{rpcTxs, receipts, false, false, nil}, nil
 }
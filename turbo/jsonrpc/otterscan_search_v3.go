@@ -33,37 +33,37 @@ import (
 
 type txNumsIterFactory func(tx kv.TemporalTx, addr common.Address, fromTxNum int) (*rawdbv3.MapTxNum2BlockNumIter, error)
 
-func (api *OtterscanAPIImpl) buildSearchResults(ctx context.Context, tx kv.TemporalTx, iterFactory txNumsIterFactory, addr common.Address, fromTxNum int, pageSize uint16) ([]*RPCTransaction, []map[string]interface{}, bool, error) {
+// buildSearchResults - if `exact`: page is cut right at `pageSize` and `nextTxNum` is the first txNum of next page
+func (api *OtterscanAPIImpl) buildSearchResults(ctx context.Context, tx kv.TemporalTx, iterFactory txNumsIterFactory, addr common.Address, fromTxNum int, pageSize uint16, exact bool) (txs []*RPCTransaction, receipts []map[string]interface{}, hasMore bool, nextTxNum uint64, err error) {
 	chainConfig, err := api.chainConfig(ctx, tx)
 	if err != nil {
-		return nil, nil, false, err
+		return nil, nil, false, 0, err
 	}
 
 	txNumsIter, err := iterFactory(tx, addr, fromTxNum)
 	if err != nil {
-		return nil, nil, false, err
+		return nil, nil, false, 0, err
 	}
 
 	exec := exec3.NewTraceWorker(tx, chainConfig, api.engine(), api._blockReader, nil)
 	var blockHash common.Hash
 	var header *types.Header
-	txs := make([]*RPCTransaction, 0, pageSize)
-	receipts := make([]map[string]interface{}, 0, pageSize)
+	txs = make([]*RPCTransaction, 0, pageSize)
+	receipts = make([]map[string]interface{}, 0, pageSize)
 	resultCount := uint16(0)
 
 	mustReadHeader := true
 	reachedPageSize := false
-	hasMore := false
 	for txNumsIter.HasNext() {
 		txNum, blockNum, txIndex, isFinalTxn, blockNumChanged, err := txNumsIter.Next()
 		if err != nil {
-			return nil, nil, false, err
+			return nil, nil, false, 0, err
 		}
 
 		// Even if the desired page size is reached, drain the entire matching
 		// txs inside the block; reproduces e2 behavior. An e3/paginated-aware
 		// ots spec could improve in this area.
-		if blockNumChanged && reachedPageSize {
+		if !exact && blockNumChanged && reachedPageSize {
 			hasMore = true
 			break
 		}
@@ -75,10 +75,9 @@ func (api *OtterscanAPIImpl) buildSearchResults(ctx context.Context, tx kv.Tempo
 		if isFinalTxn {
 			continue
 		}
-
 		if mustReadHeader {
 			if header, err = api._blockReader.HeaderByNumber(ctx, tx, blockNum); err != nil {
-				return nil, nil, false, err
+				return nil, nil, false, 0, err
 			}
 			if header == nil {
 				log.Warn("[rpc] header is nil", "blockNum", blockNum)
@@ -91,15 +90,19 @@ func (api *OtterscanAPIImpl) buildSearchResults(ctx context.Context, tx kv.Tempo
 
 		txn, err := api._txnReader.TxnByIdxInBlock(ctx, tx, blockNum, txIndex)
 		if err != nil {
-			return nil, nil, false, err
+			return nil, nil, false, 0, err
 		}
 		if txn == nil {
 			log.Warn("[rpc] txn not found", "blockNum", blockNum, "txIndex", txIndex)
 			continue
 		}
+		if exact && reachedPageSize {
+			hasMore, nextTxNum = true, txNum
+			break
+		}
 		res, err := exec.ExecTxn(txNum, txIndex, txn)
 		if err != nil {
-			return nil, nil, false, err
+			return nil, nil, false, 0, err
 		}
 		rawLogs := exec.GetLogs(txIndex, txn)
 		rpcTx := NewRPCTransaction(txn, blockHash, blockNum, uint64(txIndex), header.BaseFee)
@@ -129,7 +132,7 @@ func (api *OtterscanAPIImpl) buildSearchResults(ctx context.Context, tx kv.Tempo
 		}
 	}
 
-	return txs, receipts, hasMore, nil
+	return txs, receipts, hasMore, nextTxNum, nil
 }
 
 func createBackwardTxNumIter(tx kv.TemporalTx, addr common.Address, fromTxNum int) (*rawdbv3.MapTxNum2BlockNumIter, error) {
@@ -147,8 +150,11 @@ func createBackwardTxNumIter(tx kv.TemporalTx, addr common.Address, fromTxNum in
 	return rawdbv3.TxNums2BlockNums(tx, txNums, order.Desc), nil
 }
 
-func (api *OtterscanAPIImpl) searchTransactionsBeforeV3(tx kv.TemporalTx, ctx context.Context, addr common.Address, fromBlockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error) {
+func (api *OtterscanAPIImpl) searchTransactionsBeforeV3(tx kv.TemporalTx, ctx context.Context, addr common.Address, fromBlockNum uint64, pageSize uint16, cursor *PageCursor) (*TransactionsWithReceipts, error) {
 	isFirstPage := false
+	if cursor != nil && *cursor != (PageCursor{}) {
+		fromBlockNum = 0
+	}
 	if fromBlockNum == 0 {
 		isFirstPage = true
 	} else {
@@ -165,13 +171,17 @@ func (api *OtterscanAPIImpl) searchTransactionsBeforeV3(tx kv.TemporalTx, ctx co
 		}
 		fromTxNum = int(_txNum)
 	}
+	if cursor != nil && *cursor != (PageCursor{}) {
+		isFirstPage = false
+		fromTxNum = int(cursor.txNum)
+	}
 
-	txs, receipts, hasMore, err := api.buildSearchResults(ctx, tx, createBackwardTxNumIter, addr, fromTxNum, pageSize)
+	txs, receipts, hasMore, nextTxNum, err := api.buildSearchResults(ctx, tx, createBackwardTxNumIter, addr, fromTxNum, pageSize, cursor != nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return &TransactionsWithReceipts{txs, receipts, isFirstPage, !hasMore}, nil
+	return &TransactionsWithReceipts{txs, receipts, isFirstPage, !hasMore, nextPageCursor(cursor, hasMore, nextTxNum)}, nil
 }
 
 func createForwardTxNumIter(tx kv.TemporalTx, addr common.Address, fromTxNum int) (*rawdbv3.MapTxNum2BlockNumIter, error) {
@@ -189,9 +199,12 @@ func createForwardTxNumIter(tx kv.TemporalTx, addr common.Address, fromTxNum int
 	return rawdbv3.TxNums2BlockNums(tx, txNums, order.Asc), nil
 }
 
-func (api *OtterscanAPIImpl) searchTransactionsAfterV3(tx kv.TemporalTx, ctx context.Context, addr common.Address, fromBlockNum uint64, pageSize uint16) (*TransactionsWithReceipts, error) {
+func (api *OtterscanAPIImpl) searchTransactionsAfterV3(tx kv.TemporalTx, ctx context.Context, addr common.Address, fromBlockNum uint64, pageSize uint16, cursor *PageCursor) (*TransactionsWithReceipts, error) {
 	isLastPage := false
 	fromTxNum := -1
+	if cursor != nil && *cursor != (PageCursor{}) {
+		fromBlockNum = 0
+	}
 	if fromBlockNum == 0 {
 		isLastPage = true
 	} else {
@@ -202,13 +215,25 @@ func (api *OtterscanAPIImpl) searchTransactionsAfterV3(tx kv.TemporalTx, ctx con
 		}
 		fromTxNum = int(_txNum)
 	}
+	if cursor != nil && *cursor != (PageCursor{}) {
+		isLastPage = false
+		fromTxNum = int(cursor.txNum)
+	}
 
-	txs, receipts, hasMore, err := api.buildSearchResults(ctx, tx, createForwardTxNumIter, addr, fromTxNum, pageSize)
+	txs, receipts, hasMore, nextTxNum, err := api.buildSearchResults(ctx, tx, createForwardTxNumIter, addr, fromTxNum, pageSize, cursor != nil)
 	if err != nil {
 		return nil, err
 	}
 	slices.Reverse(txs)
 	slices.Reverse(receipts)
 
-	return &TransactionsWithReceipts{txs, receipts, !hasMore, isLastPage}, nil
+	return &TransactionsWithReceipts{txs, receipts, !hasMore, isLastPage, nextPageCursor(cursor, hasMore, nextTxNum)}, nil
+}
+
+// nextPageCursor - nil if search was requested without cursor or there are no more pages
+func nextPageCursor(cursor *PageCursor, hasMore bool, nextTxNum uint64) *PageCursor {
+	if cursor == nil || !hasMore {
+		return nil
+	}
+	return &PageCursor{txNum: nextTxNum}
 }
@@ -0,0 +1,125 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/erigontech/erigon-lib/common/hexutility"
+)
+
+// PageCursor - opaque continuation token of paginated methods (trace_filter, ots_searchTransactions*, erigon_getLogsPage).
+// It's a position in txNum space: next page seeks inverted indices to it directly - instead of
+// skipping results of all previous pages (as offset-based `after` does).
+// Empty cursor ("0x") means "first page".
+type PageCursor struct {
+	txNum uint64
+	skip  uint64 // amount of results of txNum already returned: 1 txn may produce many traces or logs
+}
+
+func (c PageCursor) MarshalText() ([]byte, error) {
+	if c == (PageCursor{}) {
+		return []byte("0x"), nil
+	}
+	var b [16]byte
+	binary.BigEndian.PutUint64(b[:], c.txNum)
+	binary.BigEndian.PutUint64(b[8:], c.skip)
+	return []byte(hexutility.Encode(b[:])), nil
+}
+
+func (c *PageCursor) UnmarshalText(input []byte) error {
+	var b hexutility.Bytes
+	if len(input) > 0 {
+		if err := b.UnmarshalText(input); err != nil {
+			return fmt.Errorf("invalid cursor: %w", err)
+		}
+	}
+	switch len(b) {
+	case 0:
+		*c = PageCursor{}
+	case 16:
+		c.txNum, c.skip = binary.BigEndian.Uint64(b), binary.BigEndian.Uint64(b[8:])
+	default:
+		return fmt.Errorf("invalid cursor length: %d", len(b))
+	}
+	return nil
+}
+
+// maxPageLimit - max amount of results of one page of paged erigon_ methods
+const maxPageLimit = 10_000
+
+func checkPageLimit(limit uint64) error {
+	if limit == 0 {
+		return errors.New("limit must be positive")
+	}
+	if limit > maxPageLimit {
+		return fmt.Errorf("max allowed limit: %d", maxPageLimit)
+	}
+	return nil
+}
+
+// pager - selects results of one page while iterating over txNums. nil pager takes everything.
+type pager struct {
+	start PageCursor
+	limit uint64
+	taken uint64
+
+	txNum, inTxn uint64 // current txn and amount of its results seen
+	next         *PageCursor
+}
+
+func newPager(start *PageCursor, limit uint64) *pager {
+	if start == nil {
+		return nil
+	}
+	return &pager{start: *start, limit: limit}
+}
+
+// fromTxNum - where iteration must start to not miss results of this page
+func (p *pager) fromTxNum(fromTxNum uint64) uint64 {
+	if p == nil {
+		return fromTxNum
+	}
+	return max(fromTxNum, p.start.txNum)
+}
+
+// take - registers one more result of txn `txNum`. Returns false if result doesn't belong to this page.
+func (p *pager) take(txNum uint64) bool {
+	if p == nil {
+		return true
+	}
+	if txNum != p.txNum {
+		p.txNum, p.inTxn = txNum, 0
+	}
+	p.inTxn++
+	if txNum == p.start.txNum && p.inTxn <= p.start.skip {
+		return false // returned on previous page
+	}
+	if p.taken >= p.limit {
+		if p.next == nil {
+			p.next = &PageCursor{txNum: txNum, skip: p.inTxn - 1}
+		}
+		return false
+	}
+	p.taken++
+	return true
+}
+
+// done - page is full and cursor of next page is known: iteration can stop
+func (p *pager) done() bool { return p != nil && p.next != nil }
@@ -0,0 +1,65 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageCursorJSON(t *testing.T) {
+	for _, c := range []PageCursor{{}, {txNum: 1}, {txNum: 100, skip: 3}} {
+		enc, err := json.Marshal(c)
+		require.NoError(t, err)
+		var dec PageCursor
+		require.NoError(t, json.Unmarshal(enc, &dec))
+		require.Equal(t, c, dec)
+	}
+	var dec PageCursor
+	require.NoError(t, json.Unmarshal([]byte(`""`), &dec))
+	require.Equal(t, PageCursor{}, dec)
+	require.Error(t, json.Unmarshal([]byte(`"0x01"`), &dec))
+	require.Error(t, json.Unmarshal([]byte(`"zz"`), &dec))
+}
+
+func TestPager(t *testing.T) {
+	// results of txNum: 1 -> 2 results, 2 -> 3 results, 5 -> 1 result
+	results := []uint64{1, 1, 2, 2, 2, 5}
+	var got []uint64
+	cursor := &PageCursor{}
+	for pages := 0; cursor != nil; pages++ {
+		require.Less(t, pages, len(results))
+		p := newPager(cursor, 2)
+		for _, txNum := range results {
+			if txNum < p.fromTxNum(0) || p.done() {
+				continue
+			}
+			if p.take(txNum) {
+				got = append(got, txNum)
+			}
+		}
+		cursor = p.next
+	}
+	require.Equal(t, results, got)
+
+	var p *pager // nil pager takes everything
+	require.True(t, p.take(1))
+	require.False(t, p.done())
+	require.Equal(t, uint64(7), p.fromTxNum(7))
+}
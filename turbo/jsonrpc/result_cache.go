@@ -0,0 +1,130 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+
+	jsoniter "github.com/json-iterator/go"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+
+	"github.com/erigontech/erigon/turbo/jsonrpc/resultcache"
+	"github.com/erigontech/erigon/turbo/rpchelper"
+)
+
+// resultCacheTarget - returns canonical hash of `blockNum` if results computed for it may be cached:
+// cache is enabled and block is finalized. Zero hash means "don't cache".
+func (api *BaseAPI) resultCacheTarget(ctx context.Context, tx kv.Tx, blockNum uint64) (common.Hash, error) {
+	if api.resultCache == nil {
+		return common.Hash{}, nil
+	}
+	finalized, err := rpchelper.GetFinalizedBlockNumber(tx)
+	if err != nil || blockNum > finalized { // finalized block is unknown before the first FCU
+		return common.Hash{}, nil
+	}
+	return api._blockReader.CanonicalHash(ctx, tx, blockNum)
+}
+
+// cachedResult - returns JSON of `compute()` result, from cache if it was already computed for finalized block `blockNum`.
+// Result is cached as it's encoded, so cached and fresh responses are the same.
+// `params` must identify result uniquely within `method` (block numbers already resolved).
+func cachedResult[T any](ctx context.Context, api *BaseAPI, tx kv.Tx, blockNum uint64, method string, params []any, compute func() (T, error)) (json.RawMessage, error) {
+	blockHash, err := api.resultCacheTarget(ctx, tx, blockNum)
+	if err != nil || blockHash == (common.Hash{}) {
+		return marshalResult(compute())
+	}
+	key, err := resultcache.Key(method, params...)
+	if err != nil {
+		return marshalResult(compute())
+	}
+	if data, ok := api.resultCache.Get(ctx, key, blockHash); ok {
+		return data, nil
+	}
+	data, err := marshalResult(compute())
+	if err != nil {
+		return nil, err
+	}
+	api.resultCache.Put(ctx, key, blockHash, data)
+	return data, nil
+}
+
+func marshalResult[T any](res T, err error) (json.RawMessage, error) {
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(res)
+}
+
+// maxCachedStreamSize - results of streaming methods bigger than this are not cached (and not held in RAM)
+const maxCachedStreamSize = 16 * 1024 * 1024
+
+// cachedStream - same as `cachedResult`, but for streaming methods. Result is written to `stream` as it's produced,
+// copy of it is kept in RAM for cache - until it grows bigger than maxCachedStreamSize.
+func cachedStream(ctx context.Context, api *BaseAPI, tx kv.Tx, blockNum uint64, method string, params []any, stream *jsoniter.Stream, compute func(stream *jsoniter.Stream) error) error {
+	blockHash, err := api.resultCacheTarget(ctx, tx, blockNum)
+	if err != nil || blockHash == (common.Hash{}) {
+		return compute(stream)
+	}
+	key, err := resultcache.Key(method, params...)
+	if err != nil {
+		return compute(stream)
+	}
+	if data, ok := api.resultCache.Get(ctx, key, blockHash); ok {
+		stream.WriteRaw(string(data))
+		return nil
+	}
+
+	tee := &cacheTee{dst: stream}
+	teeStream := jsoniter.NewStream(jsoniter.ConfigDefault, tee, 4096)
+	err = compute(teeStream)
+	if flushErr := teeStream.Flush(); err == nil {
+		err = flushErr
+	}
+	if err != nil {
+		return err
+	}
+	if !tee.overflow {
+		api.resultCache.Put(ctx, key, blockHash, tee.buf.Bytes())
+	}
+	return nil
+}
+
+// cacheTee - passes everything to `dst` and keeps copy of it in `buf`, unless it's too big
+type cacheTee struct {
+	dst      *jsoniter.Stream
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (t *cacheTee) Write(p []byte) (int, error) {
+	if !t.overflow {
+		if t.buf.Len()+len(p) > maxCachedStreamSize {
+			t.overflow = true
+			t.buf = bytes.Buffer{}
+		} else {
+			t.buf.Write(p)
+		}
+	}
+	if _, err := t.dst.Write(p); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package resultcache keeps JSON-encoded RPC results which can't change anymore:
+// results computed for finalized blocks. Every entry is bound to the hash of the
+// block it was computed for, so a (deep) reorg invalidates it on next read.
+package resultcache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/hashicorp/golang-lru/v2/simplelru"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/kv/mdbx"
+	"github.com/erigontech/erigon-lib/log/v3"
+	"github.com/erigontech/erigon-lib/metrics"
+	mdbx2 "github.com/erigontech/mdbx-go/mdbx"
+
+	"github.com/erigontech/erigon/crypto"
+)
+
+const (
+	tableName = "RpcResultCache"
+	diskLimit = 16 * datasize.GB // when reached - on-disk part of cache is truncated
+)
+
+var (
+	hitsCounter   = metrics.GetOrCreateCounter(`rpc_result_cache{result="hit"}`)
+	missesCounter = metrics.GetOrCreateCounter(`rpc_result_cache{result="miss"}`)
+	reorgsCounter = metrics.GetOrCreateCounter(`rpc_result_cache{result="reorg"}`)
+)
+
+type entry struct {
+	blockHash common.Hash
+	data      []byte
+}
+
+func (e entry) size() uint64 { return uint64(length.Hash + len(e.data)) }
+
+// Cache - LRU of RPC results bounded by size of results. If `dir` is set - evicted results are still available from disk.
+type Cache struct {
+	lock   sync.Mutex
+	lru    *simplelru.LRU[string, entry]
+	size   uint64 // of results in lru
+	limit  uint64
+	db     kv.RwDB // nil if cache is in-memory only
+	logger log.Logger
+}
+
+// New - returns nil if `limit` is 0: nil *Cache is valid and never caches anything.
+// Caller must Close the cache.
+func New(ctx context.Context, limit datasize.ByteSize, dir string, logger log.Logger) (*Cache, error) {
+	if limit == 0 {
+		return nil, nil
+	}
+	c := &Cache{limit: limit.Bytes(), logger: logger}
+	var err error
+	// amount of entries isn't limited: size is
+	if c.lru, err = simplelru.NewLRU[string, entry](math.MaxInt, func(_ string, e entry) { c.size -= e.size() }); err != nil {
+		return nil, err
+	}
+	if dir == "" {
+		return c, nil
+	}
+	c.db, err = mdbx.NewMDBX(logger).
+		Path(dir).
+		WithTableCfg(func(_ kv.TableCfg) kv.TableCfg { return kv.TableCfg{tableName: {}} }).
+		MapSize(diskLimit).
+		GrowthStep(16 * datasize.MB).
+		Open(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("open rpc result cache: %w", err)
+	}
+	return c, nil
+}
+
+func (c *Cache) Close() {
+	if c == nil || c.db == nil {
+		return
+	}
+	c.db.Close()
+}
+
+// addRAM - caller must hold the lock. Results bigger than the whole cache are kept on disk only.
+func (c *Cache) addRAM(key string, e entry) {
+	c.lru.Remove(key) // eviction callback accounts size of previous result
+	if e.size() > c.limit {
+		return
+	}
+	c.lru.Add(key, e)
+	c.size += e.size()
+	for c.size > c.limit {
+		c.lru.RemoveOldest()
+	}
+}
+
+func (c *Cache) getRAM(key string) (entry, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.lru.Get(key)
+}
+
+func (c *Cache) putRAM(key string, e entry) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.addRAM(key, e)
+}
+
+func (c *Cache) removeRAM(key string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.lru.Remove(key)
+}
+
+// Key - builds cache key from method name and its (already resolved) parameters.
+func Key(method string, params ...any) (string, error) {
+	enc, err := json.Marshal(params)
+	if err != nil {
+		return "", err
+	}
+	return method + string(crypto.Keccak256(enc)), nil
+}
+
+// Get - returns result stored for `key` if it was computed on block `blockHash`.
+// Results computed on non-canonical block are removed.
+func (c *Cache) Get(ctx context.Context, key string, blockHash common.Hash) ([]byte, bool) {
+	if c == nil {
+		return nil, false
+	}
+	e, ok := c.getRAM(key)
+	if !ok && c.db != nil {
+		e, ok = c.readDisk(ctx, key)
+		if ok {
+			c.putRAM(key, e)
+		}
+	}
+	if !ok {
+		missesCounter.Inc()
+		return nil, false
+	}
+	if e.blockHash != blockHash {
+		reorgsCounter.Inc()
+		c.remove(ctx, key)
+		return nil, false
+	}
+	hitsCounter.Inc()
+	return e.data, true
+}
+
+// Put - caller must guarantee that `blockHash` is finalized.
+func (c *Cache) Put(ctx context.Context, key string, blockHash common.Hash, data []byte) {
+	if c == nil {
+		return
+	}
+	c.putRAM(key, entry{blockHash: blockHash, data: data})
+	if c.db == nil {
+		return
+	}
+	err := c.writeDisk(ctx, key, blockHash, data)
+	if err == nil {
+		return
+	}
+	c.logger.Warn("[rpc] result cache write failed", "err", err)
+	if !isDiskFull(err) {
+		return
+	}
+	// start from scratch instead of refusing new results forever
+	if err := c.db.Update(ctx, func(tx kv.RwTx) error { return tx.ClearBucket(tableName) }); err != nil {
+		c.logger.Warn("[rpc] result cache truncate failed", "err", err)
+		return
+	}
+	c.logger.Info("[rpc] result cache on disk reached its limit and was truncated", "limit", diskLimit)
+	if err := c.writeDisk(ctx, key, blockHash, data); err != nil {
+		c.logger.Warn("[rpc] result cache write failed", "err", err)
+	}
+}
+
+func isDiskFull(err error) bool {
+	var opErr *mdbx2.OpError
+	return errors.As(err, &opErr) && mdbx2.IsMapFull(opErr)
+}
+
+func (c *Cache) remove(ctx context.Context, key string) {
+	c.removeRAM(key)
+	if c.db == nil {
+		return
+	}
+	if err := c.db.Update(ctx, func(tx kv.RwTx) error { return tx.Delete(tableName, []byte(key)) }); err != nil {
+		c.logger.Warn("[rpc] result cache delete failed", "err", err)
+	}
+}
+
+func (c *Cache) readDisk(ctx context.Context, key string) (e entry, ok bool) {
+	if err := c.db.View(ctx, func(tx kv.Tx) error {
+		v, err := tx.GetOne(tableName, []byte(key))
+		if err != nil || len(v) < length.Hash {
+			return err
+		}
+		e.blockHash = common.BytesToHash(v[:length.Hash])
+		e.data = common.Copy(v[length.Hash:])
+		ok = true
+		return nil
+	}); err != nil {
+		c.logger.Warn("[rpc] result cache read failed", "err", err)
+		return e, false
+	}
+	return e, ok
+}
+
+func (c *Cache) writeDisk(ctx context.Context, key string, blockHash common.Hash, data []byte) error {
+	v := make([]byte, length.Hash+len(data))
+	copy(v, blockHash[:])
+	copy(v[length.Hash:], data)
+	return c.db.Update(ctx, func(tx kv.RwTx) error { return tx.Put(tableName, []byte(key), v) })
+}
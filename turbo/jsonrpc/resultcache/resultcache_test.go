@@ -0,0 +1,110 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package resultcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/c2h5oh/datasize"
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/length"
+	"github.com/erigontech/erigon-lib/log/v3"
+)
+
+func TestCache(t *testing.T) {
+	ctx := context.Background()
+	logger := log.New()
+	h1, h2 := common.Hash{1}, common.Hash{2}
+	oneEntry := datasize.ByteSize(length.Hash + 2)
+
+	t.Run("disabled", func(t *testing.T) {
+		c, err := New(ctx, 0, "", logger)
+		require.NoError(t, err)
+		require.Nil(t, c)
+		c.Put(ctx, "k", h1, []byte("v"))
+		_, ok := c.Get(ctx, "k", h1)
+		require.False(t, ok)
+	})
+
+	t.Run("reorg", func(t *testing.T) {
+		c, err := New(ctx, datasize.KB, "", logger)
+		require.NoError(t, err)
+		c.Put(ctx, "k", h1, []byte("v"))
+		v, ok := c.Get(ctx, "k", h1)
+		require.True(t, ok)
+		require.Equal(t, []byte("v"), v)
+
+		_, ok = c.Get(ctx, "k", h2)
+		require.False(t, ok)
+		_, ok = c.Get(ctx, "k", h1) // removed by reorg
+		require.False(t, ok)
+	})
+
+	t.Run("size", func(t *testing.T) {
+		c, err := New(ctx, 2*oneEntry, "", logger)
+		require.NoError(t, err)
+		c.Put(ctx, "k1", h1, []byte("v1"))
+		c.Put(ctx, "k2", h1, []byte("v2"))
+		c.Put(ctx, "k1", h1, []byte("v1")) // replacing result doesn't count it twice
+		_, ok := c.Get(ctx, "k2", h1)
+		require.True(t, ok)
+		c.Put(ctx, "k3", h1, []byte("v3")) // evicts least recently used k1
+		_, ok = c.Get(ctx, "k1", h1)
+		require.False(t, ok)
+		_, ok = c.Get(ctx, "k2", h1)
+		require.True(t, ok)
+
+		c.Put(ctx, "big", h1, make([]byte, 3*oneEntry)) // bigger than cache: not kept, nothing evicted
+		_, ok = c.Get(ctx, "big", h1)
+		require.False(t, ok)
+		_, ok = c.Get(ctx, "k3", h1)
+		require.True(t, ok)
+	})
+
+	t.Run("disk", func(t *testing.T) {
+		dir := t.TempDir()
+		c, err := New(ctx, oneEntry, dir, logger)
+		require.NoError(t, err)
+		c.Put(ctx, "k1", h1, []byte("v1"))
+		c.Put(ctx, "k2", h1, []byte("v2")) // evicts k1 from RAM
+		v, ok := c.Get(ctx, "k1", h1)
+		require.True(t, ok)
+		require.Equal(t, []byte("v1"), v)
+		c.Close()
+
+		c, err = New(ctx, oneEntry, dir, logger)
+		require.NoError(t, err)
+		defer c.Close()
+		v, ok = c.Get(ctx, "k2", h1)
+		require.True(t, ok)
+		require.Equal(t, []byte("v2"), v)
+	})
+
+	t.Run("key", func(t *testing.T) {
+		k1, err := Key("eth_getBlockReceipts", uint64(1))
+		require.NoError(t, err)
+		k2, err := Key("eth_getBlockReceipts", uint64(2))
+		require.NoError(t, err)
+		k3, err := Key("trace_block", uint64(1))
+		require.NoError(t, err)
+		require.NotEqual(t, k1, k2)
+		require.NotEqual(t, k1, k3)
+	})
+}
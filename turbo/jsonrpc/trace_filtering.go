@@ -18,6 +18,7 @@ package jsonrpc
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
@@ -180,7 +181,7 @@ func rewardKindToString(kind consensus.RewardKind) string {
 }
 
 // Block implements trace_block
-func (api *TraceAPIImpl) Block(ctx context.Context, blockNr rpc.BlockNumber, gasBailOut *bool, traceConfig *config.TraceConfig) (ParityTraces, error) {
+func (api *TraceAPIImpl) Block(ctx context.Context, blockNr rpc.BlockNumber, gasBailOut *bool, traceConfig *config.TraceConfig) (json.RawMessage, error) {
 	if gasBailOut == nil {
 		gasBailOut = new(bool) // false by default
 	}
@@ -194,63 +195,65 @@ func (api *TraceAPIImpl) Block(ctx context.Context, blockNr rpc.BlockNumber, gas
 		return nil, err
 	}
 	if blockNum == 0 {
-		return []ParityTrace{}, nil
+		return json.RawMessage("[]"), nil
 	}
-	bn := hexutil.Uint64(blockNum)
+	return cachedResult(ctx, api.BaseAPI, tx, blockNum, "trace_block", []any{blockNum, *gasBailOut, traceConfig}, func() (ParityTraces, error) {
+		bn := hexutil.Uint64(blockNum)
 
-	// Extract transactions from block
-	block, bErr := api.blockWithSenders(ctx, tx, hash, blockNum)
-	if bErr != nil {
-		return nil, bErr
-	}
-	if block == nil {
-		return nil, fmt.Errorf("could not find block %d", uint64(bn))
-	}
+		// Extract transactions from block
+		block, bErr := api.blockWithSenders(ctx, tx, hash, blockNum)
+		if bErr != nil {
+			return nil, bErr
+		}
+		if block == nil {
+			return nil, fmt.Errorf("could not find block %d", uint64(bn))
+		}
 
-	cfg, err := api.chainConfig(ctx, tx)
-	if err != nil {
-		return nil, err
-	}
-	signer := types.MakeSigner(cfg, blockNum, block.Time())
-	traces, syscall, err := api.callManyTransactions(ctx, tx, block, []string{TraceTypeTrace}, -1 /* all txn indices */, *gasBailOut /* gasBailOut */, signer, cfg, traceConfig)
-	if err != nil {
-		return nil, err
-	}
+		cfg, err := api.chainConfig(ctx, tx)
+		if err != nil {
+			return nil, err
+		}
+		signer := types.MakeSigner(cfg, blockNum, block.Time())
+		traces, syscall, err := api.callManyTransactions(ctx, tx, block, []string{TraceTypeTrace}, -1 /* all txn indices */, *gasBailOut /* gasBailOut */, signer, cfg, traceConfig)
+		if err != nil {
+			return nil, err
+		}
 
-	out := make([]ParityTrace, 0, len(traces))
-	for txno, trace := range traces {
-		txpos := uint64(txno)
-		for _, pt := range trace.Trace {
-			pt.BlockHash = &hash
-			pt.BlockNumber = &blockNum
-			pt.TransactionHash = trace.TransactionHash
-			pt.TransactionPosition = &txpos
-			out = append(out, *pt)
+		out := make([]ParityTrace, 0, len(traces))
+		for txno, trace := range traces {
+			txpos := uint64(txno)
+			for _, pt := range trace.Trace {
+				pt.BlockHash = &hash
+				pt.BlockNumber = &blockNum
+				pt.TransactionHash = trace.TransactionHash
+				pt.TransactionPosition = &txpos
+				out = append(out, *pt)
+			}
 		}
-	}
 
-	rewards, err := api.engine().CalculateRewards(cfg, block.Header(), block.Uncles(), syscall)
-	if err != nil {
-		return nil, err
-	}
+		rewards, err := api.engine().CalculateRewards(cfg, block.Header(), block.Uncles(), syscall)
+		if err != nil {
+			return nil, err
+		}
 
-	for _, r := range rewards {
-		var tr ParityTrace
-		rewardAction := &RewardTraceAction{}
-		rewardAction.Author = r.Beneficiary
-		rewardAction.RewardType = rewardKindToString(r.Kind)
-		rewardAction.Value.ToInt().Set(r.Amount.ToBig())
-		tr.Action = rewardAction
-		tr.BlockHash = &common.Hash{}
-		copy(tr.BlockHash[:], block.Hash().Bytes())
-		tr.BlockNumber = new(uint64)
-		*tr.BlockNumber = block.NumberU64()
-		tr.Type = "reward" // nolint: goconst
-		tr.TraceAddress = []int{}
-		out = append(out, tr)
-	}
+		for _, r := range rewards {
+			var tr ParityTrace
+			rewardAction := &RewardTraceAction{}
+			rewardAction.Author = r.Beneficiary
+			rewardAction.RewardType = rewardKindToString(r.Kind)
+			rewardAction.Value.ToInt().Set(r.Amount.ToBig())
+			tr.Action = rewardAction
+			tr.BlockHash = &common.Hash{}
+			copy(tr.BlockHash[:], block.Hash().Bytes())
+			tr.BlockNumber = new(uint64)
+			*tr.BlockNumber = block.NumberU64()
+			tr.Type = "reward" // nolint: goconst
+			tr.TraceAddress = []int{}
+			out = append(out, tr)
+		}
 
-	return out, err
+		return out, err
+	})
 }
 
 func traceFilterBitmapsV3(tx kv.TemporalTx, req TraceFilterRequest, from, to uint64) (fromAddresses, toAddresses map[common.Address]struct{}, allBlocks stream.U64, err error) {
@@ -349,6 +352,18 @@ func (api *TraceAPIImpl) filterV3(ctx context.Context, dbtx kv.TemporalTx, fromB
 		return err
 	}
 	toTxNum++ //+1 because internally Erigon using semantic [from, to), but some RPC have different semantic
+
+	count := uint64(^uint(0)) // this just makes it easier to use below
+	if req.Count != nil {
+		count = *req.Count
+	}
+	after := uint64(0) // this just makes it easier to use below
+	if req.After != nil && req.Cursor == nil {
+		after = *req.After
+	}
+	page := newPager(req.Cursor, count)
+	fromTxNum = page.fromTxNum(fromTxNum)
+
 	fromAddresses, toAddresses, allTxs, err := traceFilterBitmapsV3(dbtx, req, fromTxNum, toTxNum)
 	if err != nil {
 		return err
@@ -363,18 +378,14 @@ func (api *TraceAPIImpl) filterV3(ctx context.Context, dbtx kv.TemporalTx, fromB
 	engine := api.engine()
 
 	var json = jsoniter.ConfigCompatibleWithStandardLibrary
+	if page != nil {
+		stream.WriteObjectStart()
+		stream.WriteObjectField("traces")
+	}
 	stream.WriteArrayStart()
 	first := true
 	// Execute all transactions in picked blocks
 
-	count := uint64(^uint(0)) // this just makes it easier to use below
-	if req.Count != nil {
-		count = *req.Count
-	}
-	after := uint64(0) // this just makes it easier to use below
-	if req.After != nil {
-		after = *req.After
-	}
 	vmConfig := vm.Config{}
 	nSeen := uint64(0)
 	nExported := uint64(0)
@@ -389,7 +400,7 @@ func (api *TraceAPIImpl) filterV3(ctx context.Context, dbtx kv.TemporalTx, fromB
 	stateReader.SetTx(dbtx)
 	noop := state.NewNoopWriter()
 	isPos := false
-	for it.HasNext() {
+	for it.HasNext() && !page.done() {
 		txNum, blockNum, txIndex, isFnalTxn, blockNumChanged, err := it.Next()
 		if err != nil {
 			if first {
@@ -485,7 +496,7 @@ func (api *TraceAPIImpl) filterV3(ctx context.Context, dbtx kv.TemporalTx, fromB
 					stream.WriteObjectEnd()
 					continue
 				}
-				if nSeen > after && nExported < count {
+				if page.take(txNum) && nSeen > after && nExported < count {
 					if first {
 						first = false
 					} else {
@@ -525,7 +536,7 @@ func (api *TraceAPIImpl) filterV3(ctx context.Context, dbtx kv.TemporalTx, fromB
 							stream.WriteObjectEnd()
 							continue
 						}
-						if nSeen > after && nExported < count {
+						if page.take(txNum) && nSeen > after && nExported < count {
 							if first {
 								first = false
 							} else {
@@ -659,7 +670,7 @@ func (api *TraceAPIImpl) filterV3(ctx context.Context, dbtx kv.TemporalTx, fromB
 					stream.WriteObjectEnd()
 					continue
 				}
-				if nSeen > after && nExported < count {
+				if page.take(txNum) && nSeen > after && nExported < count {
 					if first {
 						first = false
 					} else {
@@ -674,6 +685,16 @@ func (api *TraceAPIImpl) filterV3(ctx context.Context, dbtx kv.TemporalTx, fromB
 		}
 	}
 	stream.WriteArrayEnd()
+	if page != nil {
+		stream.WriteMore()
+		stream.WriteObjectField("nextCursor")
+		if page.next == nil {
+			stream.WriteNil()
+		} else {
+			stream.WriteVal(page.next)
+		}
+		stream.WriteObjectEnd()
+	}
 	return stream.Flush()
 }
 
@@ -830,6 +851,9 @@ type TraceFilterRequest struct {
 	Mode        TraceFilterMode   `json:"mode"`
 	After       *uint64           `json:"after"`
 	Count       *uint64           `json:"count"`
+	// Cursor - if set (even to empty "0x"): `after` is ignored, result is `{"traces": [...], "nextCursor": ...}`
+	// and next page can be requested by passing `nextCursor` back
+	Cursor *PageCursor `json:"cursor"`
 }
 
 type TraceFilterMode string
@@ -266,66 +266,68 @@ func (api *PrivateDebugAPIImpl) TraceTransaction(ctx context.Context, hash commo
 		isBorStateSyncTxn = true
 	}
 
-	// check pruning to ensure we have history at this block level
-	err = api.BaseAPI.checkPruneHistory(tx, blockNum)
-	if err != nil {
-		stream.WriteNil()
-		return err
-	}
+	return cachedStream(ctx, api.BaseAPI, tx, blockNum, "debug_traceTransaction", []any{hash, config}, stream, func(stream *jsoniter.Stream) error {
+		// check pruning to ensure we have history at this block level
+		err = api.BaseAPI.checkPruneHistory(tx, blockNum)
+		if err != nil {
+			stream.WriteNil()
+			return err
+		}
 
-	block, err := api.blockByNumberWithSenders(ctx, tx, blockNum)
-	if err != nil {
-		stream.WriteNil()
-		return err
-	}
-	if block == nil {
-		stream.WriteNil()
-		return nil
-	}
-	var txnIndex int
-	var txn types.Transaction
-	for i := 0; i < block.Transactions().Len() && !isBorStateSyncTxn; i++ {
-		transaction := block.Transactions()[i]
-		if transaction.Hash() == hash {
-			txnIndex = i
-			txn = transaction
-			break
+		block, err := api.blockByNumberWithSenders(ctx, tx, blockNum)
+		if err != nil {
+			stream.WriteNil()
+			return err
 		}
-	}
-	if txn == nil {
-		if isBorStateSyncTxn {
-			// bor state sync txn is appended at the end of the block
-			txnIndex = block.Transactions().Len()
-		} else {
+		if block == nil {
 			stream.WriteNil()
-			return fmt.Errorf("transaction %#x not found", hash)
+			return nil
 		}
-	}
-	engine := api.engine()
+		var txnIndex int
+		var txn types.Transaction
+		for i := 0; i < block.Transactions().Len() && !isBorStateSyncTxn; i++ {
+			transaction := block.Transactions()[i]
+			if transaction.Hash() == hash {
+				txnIndex = i
+				txn = transaction
+				break
+			}
+		}
+		if txn == nil {
+			if isBorStateSyncTxn {
+				// bor state sync txn is appended at the end of the block
+				txnIndex = block.Transactions().Len()
+			} else {
+				stream.WriteNil()
+				return fmt.Errorf("transaction %#x not found", hash)
+			}
+		}
+		engine := api.engine()
 
-	msg, blockCtx, txCtx, ibs, _, err := transactions.ComputeTxEnv(ctx, engine, block, chainConfig, api._blockReader, tx, txnIndex)
-	if err != nil {
-		stream.WriteNil()
-		return err
-	}
-	if isBorStateSyncTxn {
-		return polygontracer.TraceBorStateSyncTxnDebugAPI(
-			ctx,
-			tx,
-			chainConfig,
-			config,
-			ibs,
-			api._blockReader,
-			block.Hash(),
-			blockNum,
-			block.Time(),
-			blockCtx,
-			stream,
-			api.evmCallTimeout,
-		)
-	}
-	// Trace the transaction and return
-	return transactions.TraceTx(ctx, msg, blockCtx, txCtx, ibs, config, chainConfig, stream, api.evmCallTimeout)
+		msg, blockCtx, txCtx, ibs, _, err := transactions.ComputeTxEnv(ctx, engine, block, chainConfig, api._blockReader, tx, txnIndex)
+		if err != nil {
+			stream.WriteNil()
+			return err
+		}
+		if isBorStateSyncTxn {
+			return polygontracer.TraceBorStateSyncTxnDebugAPI(
+				ctx,
+				tx,
+				chainConfig,
+				config,
+				ibs,
+				api._blockReader,
+				block.Hash(),
+				blockNum,
+				block.Time(),
+				blockCtx,
+				stream,
+				api.evmCallTimeout,
+			)
+		}
+		// Trace the transaction and return
+		return transactions.TraceTx(ctx, msg, blockCtx, txCtx, ibs, config, chainConfig, stream, api.evmCallTimeout)
+	})
 }
 
 // TraceCall implements debug_traceCall. Returns Geth style call traces.
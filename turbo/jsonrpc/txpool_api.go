@@ -22,6 +22,7 @@ import (
 	"strconv"
 
 	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
 
 	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/gointerfaces"
@@ -36,6 +37,7 @@ import (
 type TxPoolAPI interface {
 	Content(ctx context.Context) (map[string]map[string]map[string]*RPCTransaction, error)
 	ContentFrom(ctx context.Context, addr libcommon.Address) (map[string]map[string]*RPCTransaction, error)
+	SimulatePending(ctx context.Context, count *hexutil.Uint, transactions []hexutility.Bytes) (*SimulatePendingResult, error)
 }
 
 // TxPoolAPIImpl data structure to store things needed for net_ commands
@@ -18,6 +18,7 @@ package jsonrpc
 
 import (
 	"bytes"
+	"crypto/ecdsa"
 	"fmt"
 	"testing"
 
@@ -26,12 +27,14 @@ import (
 
 	libcommon "github.com/erigontech/erigon-lib/common"
 	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
 	txpool "github.com/erigontech/erigon-lib/gointerfaces/txpoolproto"
 	"github.com/erigontech/erigon-lib/kv/kvcache"
 
 	"github.com/erigontech/erigon/cmd/rpcdaemon/rpcdaemontest"
 	"github.com/erigontech/erigon/core"
 	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/crypto"
 	"github.com/erigontech/erigon/params"
 	"github.com/erigontech/erigon/rpc/rpccfg"
 	"github.com/erigontech/erigon/turbo/rpchelper"
@@ -79,3 +82,135 @@ func TestTxPoolContent(t *testing.T) {
 	require.Equal(status["pending"], hexutil.Uint(1))
 	require.Equal(status["queued"], hexutil.Uint(0))
 }
+
+func TestTxPoolSimulatePending(t *testing.T) {
+	m, require := mock.MockWithTxPool(t), require.New(t)
+	signer := types.LatestSignerForChainID(m.ChainConfig.ChainID)
+	key2, _ := crypto.GenerateKey()
+	key3, _ := crypto.GenerateKey()
+	addr2, addr3 := crypto.PubkeyToAddress(key2.PublicKey), crypto.PubkeyToAddress(key3.PublicKey)
+	gasPrice := uint256.NewInt(10 * params.GWei)
+
+	// first caller claims slot 0 of contract, calls of others revert
+	claim := crypto.CreateAddress(m.Address, 0)
+	code := hexutility.MustDecodeHex("0x6011600c60003960116000f3" + "60005415600b57600080fd5b3360005500")
+	chain, err := core.GenerateChain(m.ChainConfig, m.Genesis, m.Engine, m.DB, 1, func(i int, b *core.BlockGen) {
+		b.SetCoinbase(libcommon.Address{1})
+		for nonce, txn := range []types.Transaction{
+			types.NewContractCreation(0, uint256.NewInt(0), 100_000, gasPrice, code),
+			types.NewTransaction(1, addr2, uint256.NewInt(params.Ether/10), params.TxGas, gasPrice, nil),
+			types.NewTransaction(2, addr3, uint256.NewInt(params.Ether/10), params.TxGas, gasPrice, nil),
+		} {
+			signed, err := types.SignTx(txn, *signer, m.Key)
+			require.NoError(err, nonce)
+			b.AddTx(signed)
+		}
+	})
+	require.NoError(err)
+	require.NoError(m.InsertChain(chain))
+
+	ctx, conn := rpcdaemontest.CreateTestGrpcConn(t, m)
+	txPool := txpool.NewTxpoolClient(conn)
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, nil, txPool, txpool.NewMiningClient(conn), func() {}, m.Log)
+	api := NewTxPoolAPI(NewBaseApi(ff, kvcache.New(kvcache.DefaultCoherentConfig), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil), m.DB, txPool)
+
+	encode := func(txn types.Transaction, key *ecdsa.PrivateKey) (types.Transaction, []byte) {
+		signed, err := types.SignTx(txn, *signer, key)
+		require.NoError(err)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(signed.MarshalBinary(buf))
+		return signed, buf.Bytes()
+	}
+	// higher tip goes first
+	txA, rlpA := encode(types.NewTransaction(0, claim, uint256.NewInt(0), 100_000, uint256.NewInt(20*params.GWei), nil), key2)
+	txB, rlpB := encode(types.NewTransaction(3, claim, uint256.NewInt(0), 100_000, gasPrice, nil), m.Key)
+	reply, err := txPool.Add(ctx, &txpool.AddRequest{RlpTxs: [][]byte{rlpA, rlpB}})
+	require.NoError(err)
+	for _, res := range reply.Imported {
+		require.Equal(res, txpool.ImportResult_SUCCESS, fmt.Sprintf("%s", reply.Errors))
+	}
+
+	txC, rlpC := encode(types.NewTransaction(0, libcommon.Address{9}, uint256.NewInt(1), params.TxGas, gasPrice, nil), key3)
+	txD, rlpD := encode(types.NewTransaction(1, claim, uint256.NewInt(0), 100_000, gasPrice, nil), key3)
+	res, err := api.SimulatePending(ctx, nil, []hexutility.Bytes{rlpC, rlpD})
+	require.NoError(err)
+	require.Equal(hexutil.Uint64(2), res.BlockNumber)
+	require.Len(res.Pending, 2)
+	require.Len(res.Submitted, 2)
+
+	a, b := res.Pending[0], res.Pending[1]
+	require.Equal(txA.Hash(), a.Hash)
+	require.Equal(addr2, a.From)
+	require.True(a.Included && a.Success, a.Error)
+	require.Empty(a.InvalidatedBy)
+	require.Equal([]ErigonStorageChange{{Address: claim, Slot: libcommon.Hash{}, After: libcommon.BytesToHash(addr2[:])}}, a.StateDiff.Storage)
+	var nonceChanged bool
+	for _, change := range a.StateDiff.Accounts {
+		if change.Address == addr2 {
+			nonceChanged = change.Before.Nonce == 0 && change.After.Nonce == 1
+		}
+	}
+	require.True(nonceChanged)
+
+	// claimed by A
+	require.Equal(txB.Hash(), b.Hash)
+	require.True(b.Included)
+	require.False(b.Success)
+	require.Equal("execution reverted", b.Error)
+	require.Equal([]libcommon.Hash{txA.Hash()}, b.InvalidatedBy)
+	require.Empty(b.StateDiff.Storage)
+	require.Greater(b.GasUsed, hexutil.Uint64(params.TxGas))
+
+	c, d := res.Submitted[0], res.Submitted[1]
+	require.Equal(txC.Hash(), c.Hash)
+	require.True(c.Success, c.Error)
+	require.Empty(c.ConflictsWith)
+	require.Equal(txD.Hash(), d.Hash)
+	require.False(d.Success)
+	require.Empty(d.InvalidatedBy) // nonce is too high on top of latest state
+	require.Equal([]libcommon.Hash{txA.Hash()}, d.ConflictsWith)
+	require.Equal(res.GasUsed, a.GasUsed+b.GasUsed+c.GasUsed+d.GasUsed)
+
+	// replacement of pending transaction
+	_, rlpE := encode(types.NewTransaction(0, libcommon.Address{9}, uint256.NewInt(1), params.TxGas, uint256.NewInt(30*params.GWei), nil), key2)
+	one := hexutil.Uint(1)
+	res, err = api.SimulatePending(ctx, &one, []hexutility.Bytes{rlpE})
+	require.NoError(err)
+	require.Len(res.Pending, 1)
+	require.False(res.Submitted[0].Included)
+	require.Contains(res.Submitted[0].Error, "nonce too low")
+	require.Equal([]libcommon.Hash{txA.Hash()}, res.Submitted[0].InvalidatedBy)
+	require.Equal([]libcommon.Hash{txA.Hash()}, res.Submitted[0].ConflictsWith)
+
+	tooMany := hexutil.Uint(simulatePendingMaxCount + 1)
+	_, err = api.SimulatePending(ctx, &tooMany, nil)
+	require.Error(err)
+}
+
+func TestTxPoolSimulatePendingRestoresGasPool(t *testing.T) {
+	m, require := mock.MockWithTxPool(t), require.New(t)
+	signer := types.LatestSignerForChainID(m.ChainConfig.ChainID)
+	ctx, conn := rpcdaemontest.CreateTestGrpcConn(t, m)
+	txPool := txpool.NewTxpoolClient(conn)
+	ff := rpchelper.New(ctx, rpchelper.DefaultFiltersConfig, nil, txPool, txpool.NewMiningClient(conn), func() {}, m.Log)
+	api := NewTxPoolAPI(NewBaseApi(ff, kvcache.New(kvcache.DefaultCoherentConfig), m.BlockReader, false, rpccfg.DefaultEvmCallTimeout, m.Engine, m.Dirs, nil), m.DB, txPool)
+
+	encode := func(txn types.Transaction) []byte {
+		signed, err := types.SignTx(txn, *signer, m.Key)
+		require.NoError(err)
+		buf := bytes.NewBuffer(nil)
+		require.NoError(signed.MarshalBinary(buf))
+		return buf.Bytes()
+	}
+	gasPrice := uint256.NewInt(10 * params.GWei)
+	// gas bought by rejected transaction must be returned to the block
+	low := encode(types.NewTransaction(0, libcommon.Address{9}, uint256.NewInt(1), params.TxGas-1000, gasPrice, nil))
+	full := encode(types.NewTransaction(0, libcommon.Address{9}, uint256.NewInt(1), m.Genesis.GasLimit(), gasPrice, nil))
+	res, err := api.SimulatePending(ctx, nil, []hexutility.Bytes{low, full})
+	require.NoError(err)
+	require.Len(res.Submitted, 2)
+	require.False(res.Submitted[0].Included)
+	require.Contains(res.Submitted[0].Error, "intrinsic gas too low")
+	require.True(res.Submitted[1].Included && res.Submitted[1].Success, res.Submitted[1].Error)
+	require.Equal(hexutil.Uint64(params.TxGas), res.GasUsed)
+}
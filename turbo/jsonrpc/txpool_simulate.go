@@ -0,0 +1,513 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package jsonrpc
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"time"
+
+	"github.com/holiman/uint256"
+	"google.golang.org/protobuf/types/known/emptypb"
+
+	"github.com/erigontech/erigon-lib/chain"
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/common/hexutility"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/consensus/misc"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/core/types/accounts"
+	"github.com/erigontech/erigon/core/vm"
+	"github.com/erigontech/erigon/core/vm/evmtypes"
+	"github.com/erigontech/erigon/rpc"
+	"github.com/erigontech/erigon/turbo/adapter/ethapi"
+	"github.com/erigontech/erigon/turbo/rpchelper"
+)
+
+const (
+	simulatePendingDefaultCount = 100
+	simulatePendingMaxCount     = 1000
+)
+
+// SimulatedStateDiff - changes made by one transaction
+type SimulatedStateDiff struct {
+	Accounts []ErigonAccountChange `json:"accounts"`
+	Storage  []ErigonStorageChange `json:"storage"`
+	Code     []ErigonCodeChange    `json:"code"`
+}
+
+// SimulatedTransaction - result of one transaction of txpool_simulatePending
+type SimulatedTransaction struct {
+	Hash common.Hash    `json:"hash"`
+	From common.Address `json:"from"`
+	// Included is false if transaction can't be included into the block: nonce gap, insufficient funds, block gas limit, etc.
+	Included    bool               `json:"included"`
+	Success     bool               `json:"success"`
+	Error       string             `json:"error,omitempty"`
+	ReturnValue hexutility.Bytes   `json:"returnValue,omitempty"`
+	GasUsed     hexutil.Uint64     `json:"gasUsed"`
+	Logs        []*types.Log       `json:"logs"`
+	StateDiff   SimulatedStateDiff `json:"stateDiff"`
+	// InvalidatedBy - preceding transactions which changed state read by this transaction,
+	// set only if this transaction fails in the block but succeeds on top of latest state
+	InvalidatedBy []common.Hash `json:"invalidatedBy,omitempty"`
+	// ConflictsWith - pending transactions which write state read or written by this one or read state written by this one.
+	// Set only for submitted transactions.
+	ConflictsWith []common.Hash `json:"conflictsWith,omitempty"`
+}
+
+// SimulatePendingResult - result of txpool_simulatePending
+type SimulatePendingResult struct {
+	BlockNumber hexutil.Uint64          `json:"blockNumber"`
+	BaseFee     *hexutil.Big            `json:"baseFeePerGas,omitempty"`
+	GasLimit    hexutil.Uint64          `json:"gasLimit"`
+	GasUsed     hexutil.Uint64          `json:"gasUsed"`
+	Pending     []*SimulatedTransaction `json:"pending"`
+	Submitted   []*SimulatedTransaction `json:"submitted"`
+}
+
+// SimulatePending implements txpool_simulatePending. Executes best `count` pending transactions of the pool (100 by default)
+// in a block on top of latest state, then `transactions` (signed, RLP encoded) one after another.
+// Conflicts are detected by state accessed by transactions: accounts (balance, nonce, code) and storage slots.
+// Fee recipient of the block isn't taken into account.
+func (api *TxPoolAPIImpl) SimulatePending(ctx context.Context, count *hexutil.Uint, transactions []hexutility.Bytes) (*SimulatePendingResult, error) {
+	limit := simulatePendingDefaultCount
+	if count != nil {
+		limit = int(*count)
+	}
+	if limit > simulatePendingMaxCount {
+		return nil, fmt.Errorf("count %d exceeds limit %d", limit, simulatePendingMaxCount)
+	}
+	submitted := make([]types.Transaction, len(transactions))
+	for i, enc := range transactions {
+		txn, err := types.DecodeWrappedTransaction(enc)
+		if err != nil {
+			return nil, fmt.Errorf("decoding transaction %d: %w", i, err)
+		}
+		submitted[i] = txn
+	}
+
+	reply, err := api.pool.Pending(ctx, &emptypb.Empty{})
+	if err != nil {
+		return nil, err
+	}
+	pending := make([]types.Transaction, 0, min(limit, len(reply.Txs)))
+	for i := 0; i < len(reply.Txs) && len(pending) < limit; i++ {
+		txn, err := types.DecodeWrappedTransaction(reply.Txs[i].RlpTx)
+		if err != nil {
+			return nil, fmt.Errorf("decoding transaction from: %x: %w", reply.Txs[i].RlpTx, err)
+		}
+		pending = append(pending, txn)
+	}
+
+	tx, err := api.db.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	chainConfig, err := api.chainConfig(ctx, tx)
+	if err != nil {
+		return nil, err
+	}
+	latest := rpc.BlockNumberOrHashWithNumber(rpc.LatestBlockNumber)
+	blockNum, _, _, err := rpchelper.GetBlockNumber(latest, tx, api.filters)
+	if err != nil {
+		return nil, err
+	}
+	parent, err := api._blockReader.HeaderByNumber(ctx, tx, blockNum)
+	if err != nil {
+		return nil, err
+	}
+	if parent == nil {
+		return nil, fmt.Errorf("header %d not found", blockNum)
+	}
+	stateReader, err := rpchelper.CreateStateReader(ctx, tx, latest, 0, api.filters, api.stateCache, chainConfig.ChainName)
+	if err != nil {
+		return nil, err
+	}
+
+	header := pendingHeader(chainConfig, parent)
+	getHash := func(i uint64) common.Hash {
+		hash, err := api._blockReader.CanonicalHash(ctx, tx, i)
+		if err != nil {
+			log.Debug("Can't get block hash by number", "number", i, "only-canonical", true)
+		}
+		return hash
+	}
+	sim := newPendingSimulation(chainConfig, header, core.NewEVMBlockContext(header, getHash, api.engine(), &header.Coinbase, chainConfig), stateReader)
+
+	var cancel context.CancelFunc
+	if api.evmCallTimeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, api.evmCallTimeout)
+	} else {
+		ctx, cancel = context.WithCancel(ctx)
+	}
+	defer cancel()
+	go func() {
+		<-ctx.Done()
+		sim.evm.Cancel()
+	}()
+
+	result := &SimulatePendingResult{
+		BlockNumber: hexutil.Uint64(header.Number.Uint64()),
+		GasLimit:    hexutil.Uint64(header.GasLimit),
+		Pending:     make([]*SimulatedTransaction, 0, len(pending)),
+		Submitted:   make([]*SimulatedTransaction, 0, len(submitted)),
+	}
+	if header.BaseFee != nil {
+		result.BaseFee = (*hexutil.Big)(header.BaseFee)
+	}
+	for _, txn := range pending {
+		res, err := sim.apply(txn)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", api.evmCallTimeout)
+		}
+		result.Pending = append(result.Pending, res)
+	}
+	pendingAccesses := sim.accesses
+	for _, txn := range submitted {
+		res, err := sim.apply(txn)
+		if err != nil {
+			return nil, err
+		}
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("execution aborted (timeout = %v)", api.evmCallTimeout)
+		}
+		own := sim.accesses[len(sim.accesses)-1]
+		for _, p := range pendingAccesses {
+			if p.conflicts(own) {
+				res.ConflictsWith = append(res.ConflictsWith, p.hash)
+			}
+		}
+		result.Submitted = append(result.Submitted, res)
+	}
+	result.GasUsed = hexutil.Uint64(header.GasLimit - sim.gp.Gas())
+	return result, nil
+}
+
+// pendingHeader - header of the block following `parent`, as it's built by the miner
+func pendingHeader(chainConfig *chain.Config, parent *types.Header) *types.Header {
+	header := &types.Header{
+		ParentHash: parent.Hash(),
+		Number:     new(big.Int).Add(parent.Number, common.Big1),
+		GasLimit:   parent.GasLimit,
+		Time:       max(uint64(time.Now().Unix()), parent.Time+1),
+		Difficulty: new(big.Int).Set(parent.Difficulty),
+		MixDigest:  parent.MixDigest,
+	}
+	if chainConfig.IsLondon(header.Number.Uint64()) {
+		header.BaseFee = misc.CalcBaseFee(chainConfig, parent)
+	}
+	if chainConfig.IsCancun(header.Time) {
+		excessBlobGas := misc.CalcExcessBlobGas(chainConfig, parent)
+		header.ExcessBlobGas = &excessBlobGas
+	}
+	return header
+}
+
+// stateKey - account (balance, nonce, code) or storage slot of account
+type stateKey struct {
+	addr    common.Address
+	slot    common.Hash
+	storage bool
+}
+
+// txAccesses - state read and changed by transaction
+type txAccesses struct {
+	hash   common.Hash
+	reads  map[stateKey]struct{}
+	writes map[stateKey]struct{}
+}
+
+// invalidates - `a` changed state read by `b`
+func (a *txAccesses) invalidates(b *txAccesses) bool {
+	for k := range a.writes {
+		if _, ok := b.reads[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *txAccesses) conflicts(b *txAccesses) bool {
+	if a.invalidates(b) || b.invalidates(a) {
+		return true
+	}
+	for k := range a.writes {
+		if _, ok := b.writes[k]; ok {
+			return true
+		}
+	}
+	return false
+}
+
+type pendingSimulation struct {
+	chainConfig *chain.Config
+	header      *types.Header
+	blockCtx    evmtypes.BlockContext
+	rules       *chain.Rules
+	signer      *types.Signer
+	stateReader state.StateReader
+	ibs         *state.IntraBlockState
+	evm         *vm.EVM
+	gp          *core.GasPool
+	tracer      *simulationReadTracer
+	writer      *simulationWriter
+	accesses    []*txAccesses // of executed transactions
+}
+
+func newPendingSimulation(chainConfig *chain.Config, header *types.Header, blockCtx evmtypes.BlockContext, stateReader state.StateReader) *pendingSimulation {
+	s := &pendingSimulation{
+		chainConfig: chainConfig,
+		header:      header,
+		blockCtx:    blockCtx,
+		rules:       chainConfig.Rules(header.Number.Uint64(), header.Time),
+		signer:      types.MakeSigner(chainConfig, header.Number.Uint64(), header.Time),
+		stateReader: stateReader,
+		ibs:         state.New(stateReader),
+		gp:          new(core.GasPool).AddGas(header.GasLimit).AddBlobGas(chainConfig.GetMaxBlobGasPerBlock()),
+		tracer:      &simulationReadTracer{},
+		writer:      newSimulationWriter(stateReader, blockCtx.Coinbase),
+	}
+	s.evm = vm.NewEVM(blockCtx, evmtypes.TxContext{}, s.ibs, chainConfig, vm.Config{Debug: true, Tracer: s.tracer})
+	return s
+}
+
+// apply - executes transaction on top of previously executed ones
+func (s *pendingSimulation) apply(txn types.Transaction) (*SimulatedTransaction, error) {
+	msg, err := txn.AsMessage(*s.signer, s.header.BaseFee, s.rules)
+	if err != nil {
+		return nil, fmt.Errorf("transaction %x: %w", txn.Hash(), err)
+	}
+	res := &SimulatedTransaction{Hash: txn.Hash(), From: msg.From(), Logs: []*types.Log{}}
+	accesses := &txAccesses{hash: txn.Hash(), reads: map[stateKey]struct{}{{addr: msg.From()}: {}}}
+	if to := msg.To(); to != nil {
+		accesses.reads[stateKey{addr: *to}] = struct{}{}
+	}
+	s.tracer.reads = accesses.reads
+	s.writer.start()
+
+	s.ibs.SetTxContext(txn.Hash(), common.Hash{}, len(s.accesses))
+	gasSnap := s.gp.Gas()
+	blobGasSnap := s.gp.BlobGas()
+	snapshot := s.ibs.Snapshot()
+	s.evm.Reset(core.NewEVMTxContext(msg), s.ibs)
+	result, applyErr := core.ApplyMessage(s.evm, msg, s.gp, true /* refunds */, false /* gasBailout */)
+	if applyErr != nil {
+		s.ibs.RevertToSnapshot(snapshot)
+		s.gp = new(core.GasPool).AddGas(gasSnap).AddBlobGas(blobGasSnap) // restore gas pool as well as ibs
+		res.Error = applyErr.Error()
+	} else {
+		res.Included = true
+		res.GasUsed = hexutil.Uint64(result.UsedGas)
+		if result.Err != nil {
+			if len(result.Revert()) > 0 {
+				res.Error = ethapi.NewRevertError(result).Error()
+			} else {
+				res.Error = result.Err.Error()
+			}
+		} else {
+			res.Success = true
+		}
+		res.ReturnValue = common.CopyBytes(result.Return())
+		if logs := s.ibs.GetLogs(txn.Hash()); logs != nil {
+			res.Logs = logs
+		}
+	}
+	if err := s.ibs.FinalizeTx(s.rules, s.writer); err != nil {
+		return nil, err
+	}
+	res.StateDiff, accesses.writes = s.writer.diff, s.writer.writes
+
+	// block gas limit doesn't depend on state
+	if !res.Success && !errors.Is(applyErr, core.ErrGasLimitReached) && s.succeedsAlone(msg) {
+		for _, prev := range s.accesses {
+			if prev.invalidates(accesses) {
+				res.InvalidatedBy = append(res.InvalidatedBy, prev.hash)
+			}
+		}
+	}
+	s.accesses = append(s.accesses, accesses)
+	return res, nil
+}
+
+// succeedsAlone - transaction succeeds on top of latest state
+func (s *pendingSimulation) succeedsAlone(msg types.Message) bool {
+	ibs := state.New(s.stateReader)
+	evm := vm.NewEVM(s.blockCtx, core.NewEVMTxContext(msg), ibs, s.chainConfig, vm.Config{})
+	gp := new(core.GasPool).AddGas(s.header.GasLimit).AddBlobGas(s.chainConfig.GetMaxBlobGasPerBlock())
+	result, err := core.ApplyMessage(evm, msg, gp, true /* refunds */, false /* gasBailout */)
+	return err == nil && result.Err == nil
+}
+
+// simulationReadTracer - collects accounts and storage slots read by transaction
+type simulationReadTracer struct {
+	DefaultTracer
+	reads map[stateKey]struct{}
+}
+
+func (t *simulationReadTracer) CaptureStart(env *vm.EVM, from common.Address, to common.Address, precompile bool, create bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+	t.reads[stateKey{addr: from}] = struct{}{}
+	t.reads[stateKey{addr: to}] = struct{}{}
+}
+
+func (t *simulationReadTracer) CaptureEnter(typ vm.OpCode, from common.Address, to common.Address, precompile bool, create bool, input []byte, gas uint64, value *uint256.Int, code []byte) {
+	t.reads[stateKey{addr: to}] = struct{}{}
+}
+
+func (t *simulationReadTracer) CaptureState(pc uint64, op vm.OpCode, gas, cost uint64, scope *vm.ScopeContext, rData []byte, depth int, err error) {
+	stackData := scope.Stack.Data
+	stackLen := len(stackData)
+	switch {
+	case stackLen >= 1 && (op == vm.SLOAD || op == vm.SSTORE):
+		t.reads[stateKey{addr: scope.Contract.Address(), slot: stackData[stackLen-1].Bytes32(), storage: true}] = struct{}{}
+	case stackLen >= 1 && (op == vm.EXTCODECOPY || op == vm.EXTCODEHASH || op == vm.EXTCODESIZE || op == vm.BALANCE || op == vm.SELFDESTRUCT):
+		t.reads[stateKey{addr: stackData[stackLen-1].Bytes20()}] = struct{}{}
+	case op == vm.SELFBALANCE:
+		t.reads[stateKey{addr: scope.Contract.Address()}] = struct{}{}
+	}
+}
+
+// simulationWriter - collects changes of every transaction. IntraBlockState passes values at the beginning of the block as
+// original ones and writes all dirty storage of account after every transaction, so values written by previous
+// transactions are kept to find out what was changed by current one.
+type simulationWriter struct {
+	stateReader state.StateReader
+	coinbase    common.Address
+	accounts    map[common.Address]*accounts.Account // nil - account doesn't exist
+	storage     map[stateKey]uint256.Int
+	code        map[common.Address][]byte
+
+	// of current transaction
+	diff   SimulatedStateDiff
+	writes map[stateKey]struct{}
+}
+
+func newSimulationWriter(stateReader state.StateReader, coinbase common.Address) *simulationWriter {
+	return &simulationWriter{
+		stateReader: stateReader,
+		coinbase:    coinbase,
+		accounts:    map[common.Address]*accounts.Account{},
+		storage:     map[stateKey]uint256.Int{},
+		code:        map[common.Address][]byte{},
+	}
+}
+
+func (w *simulationWriter) start() {
+	w.diff = SimulatedStateDiff{Accounts: []ErigonAccountChange{}, Storage: []ErigonStorageChange{}, Code: []ErigonCodeChange{}}
+	w.writes = map[stateKey]struct{}{}
+}
+
+func (w *simulationWriter) write(k stateKey) {
+	// fee recipient is changed by every transaction
+	if k.storage || k.addr != w.coinbase {
+		w.writes[k] = struct{}{}
+	}
+}
+
+func (w *simulationWriter) account(address common.Address) (*accounts.Account, error) {
+	if acc, ok := w.accounts[address]; ok {
+		return acc, nil
+	}
+	return w.stateReader.ReadAccountData(address)
+}
+
+func (w *simulationWriter) setAccount(address common.Address, account *accounts.Account) error {
+	before, err := w.account(address)
+	if err != nil {
+		return err
+	}
+	if before == nil && account == nil || before != nil && account != nil && before.Equals(account) {
+		return nil
+	}
+	w.diff.Accounts = append(w.diff.Accounts, ErigonAccountChange{Address: address, Before: simulatedAccount(before), After: simulatedAccount(account)})
+	w.accounts[address] = account
+	w.write(stateKey{addr: address})
+	return nil
+}
+
+func simulatedAccount(acc *accounts.Account) *ErigonAccountState {
+	if acc == nil {
+		return nil
+	}
+	return &ErigonAccountState{
+		Balance:  (*hexutil.Big)(acc.Balance.ToBig()),
+		Nonce:    hexutil.Uint64(acc.Nonce),
+		CodeHash: acc.CodeHash,
+	}
+}
+
+func (w *simulationWriter) UpdateAccountData(address common.Address, original, account *accounts.Account) error {
+	acc := new(accounts.Account)
+	acc.Copy(account)
+	return w.setAccount(address, acc)
+}
+
+func (w *simulationWriter) UpdateAccountCode(address common.Address, incarnation uint64, codeHash common.Hash, code []byte) error {
+	before, ok := w.code[address]
+	if !ok {
+		acc, err := w.account(address)
+		if err != nil {
+			return err
+		}
+		if acc != nil {
+			if before, err = w.stateReader.ReadAccountCode(address, acc.Incarnation, acc.CodeHash); err != nil {
+				return err
+			}
+		}
+	}
+	if bytes.Equal(before, code) {
+		return nil
+	}
+	w.diff.Code = append(w.diff.Code, ErigonCodeChange{Address: address, Before: common.CopyBytes(before), After: common.CopyBytes(code)})
+	w.code[address] = common.CopyBytes(code)
+	w.write(stateKey{addr: address})
+	return nil
+}
+
+func (w *simulationWriter) DeleteAccount(address common.Address, original *accounts.Account) error {
+	return w.setAccount(address, nil)
+}
+
+func (w *simulationWriter) WriteAccountStorage(address common.Address, incarnation uint64, key *common.Hash, original, value *uint256.Int) error {
+	k := stateKey{addr: address, slot: *key, storage: true}
+	before, ok := w.storage[k]
+	if !ok {
+		before = *original
+	}
+	if before.Eq(value) {
+		return nil
+	}
+	w.diff.Storage = append(w.diff.Storage, ErigonStorageChange{Address: address, Slot: *key, Before: before.Bytes32(), After: value.Bytes32()})
+	w.storage[k] = *value
+	w.write(k)
+	return nil
+}
+
+func (w *simulationWriter) CreateContract(address common.Address) error {
+	return nil
+}
@@ -0,0 +1,328 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+// Package replay re-executes historical blocks on top of state history, without writing to db.
+// Blocks are executed by exec3 workers, receipts they produce are compared with the ones of datadir:
+// stored receipts if node persists them, gas used, receipts root and bloom of headers otherwise.
+// Replay stops at the first divergence and reports it with state diff of re-execution.
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/state/exec3"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/state"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/eth/tracers"
+	_ "github.com/erigontech/erigon/eth/tracers/js"
+	_ "github.com/erigontech/erigon/eth/tracers/native"
+)
+
+// TraceFunc - receives result of tracer for every re-executed transaction, in order of transactions
+type TraceFunc func(blockNum uint64, txIndex int, txHash common.Hash, result json.RawMessage) error
+
+// Config - range of blocks and tracer attached to re-execution
+type Config struct {
+	From, To uint64 // From must be above genesis
+
+	Tracer       string          // name of tracer registered in eth/tracers, e.g. callTracer; empty - no tracing
+	TracerConfig json.RawMessage // config of tracer, as of debug_traceTransaction
+	OnTrace      TraceFunc
+}
+
+// Divergence - first difference between re-execution and datadir
+type Divergence struct {
+	BlockNum  uint64
+	BlockHash common.Hash
+	TxIndex   int // -1 if divergence is found only for whole block, e.g. by receipts root
+	TxHash    common.Hash
+	Field     string
+	Expected  string // value of datadir
+	Got       string // value of re-execution
+
+	// StateDiff - diff of prestateTracer for the transaction, or list of diffs of all block's transactions
+	StateDiff json.RawMessage
+}
+
+func (d *Divergence) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "block %d (%x)", d.BlockNum, d.BlockHash)
+	if d.TxIndex >= 0 {
+		fmt.Fprintf(&sb, ", tx %d (%x)", d.TxIndex, d.TxHash)
+	}
+	fmt.Fprintf(&sb, ": %s: expected %s, got %s", d.Field, d.Expected, d.Got)
+	return sb.String()
+}
+
+var errDiverged = errors.New("diverged")
+
+// Run - re-executes blocks [cfg.From, cfg.To], returns nil divergence if all of them match datadir.
+// Transactions are executed by exec3 workers in parallel, results are compared in order of blocks.
+// If tracer is set, it's attached to re-execution of every transaction, results are passed to cfg.OnTrace in order.
+func Run(ctx context.Context, cfg Config, execArgs *exec3.ExecArgs, logger log.Logger) (*Divergence, error) {
+	if cfg.From == 0 {
+		return nil, errors.New("genesis can't be replayed")
+	}
+	if cfg.From > cfg.To {
+		return nil, fmt.Errorf("empty range: from %d > to %d", cfg.From, cfg.To)
+	}
+	if cfg.Tracer != "" {
+		// fail before execution if tracer doesn't exist
+		if _, err := tracers.New(cfg.Tracer, &tracers.Context{}, cfg.TracerConfig); err != nil {
+			return nil, fmt.Errorf("tracer %s: %w", cfg.Tracer, err)
+		}
+	}
+
+	tx, err := execArgs.ChainDB.BeginRo(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+	ttx, ok := tx.(kv.TemporalTx)
+	if !ok {
+		return nil, errors.New("replay needs temporal db")
+	}
+
+	logEvery := time.NewTicker(20 * time.Second)
+	defer logEvery.Stop()
+	var (
+		receipts   types.Receipts
+		usedGas    uint64
+		divergence *Divergence
+		traced     = &tracedTxs{byTxNum: map[uint64]tracedTx{}}
+	)
+	consumer := exec3.TraceConsumer{
+		NewTracer: func(txTask *state.TxTask) exec3.GenericTracer {
+			if cfg.Tracer == "" {
+				return nil
+			}
+			return traced.add(txTask, cfg.Tracer, cfg.TracerConfig)
+		},
+		Reduce: func(txTask *state.TxTask, tx kv.Tx) error {
+			ttx := tx.(kv.TemporalTx)
+			switch {
+			case txTask.TxIndex == -1: // block initialisation
+				receipts, usedGas = receipts[:0], 0
+			case txTask.Final:
+				stored := rawdb.ReadRawReceipts(tx, txTask.BlockNum)
+				byzantium := execArgs.ChainConfig.IsByzantium(txTask.BlockNum)
+				if divergence = compare(txTask.Header, txTask.Txs, stored, receipts, byzantium); divergence == nil {
+					select {
+					case <-logEvery.C:
+						logger.Info("[replay] progress", "block", txTask.BlockNum, "to", cfg.To)
+					default:
+					}
+					return nil
+				}
+				diff, err := stateDiff(ttx, txTask, divergence.TxIndex, execArgs)
+				if err != nil {
+					return err
+				}
+				divergence.StateDiff = diff
+				return errDiverged
+			default:
+				usedGas += txTask.UsedGas
+				receipt := txTask.CreateReceipt(usedGas)
+				receipt.Bloom = types.CreateBloom(types.Receipts{receipt})
+				receipts = append(receipts, receipt)
+				if cfg.Tracer == "" {
+					return nil
+				}
+				result, err := traced.result(txTask.TxNum)
+				if err != nil {
+					return fmt.Errorf("tracing block %d, tx %d: %w", txTask.BlockNum, txTask.TxIndex, err)
+				}
+				if cfg.OnTrace != nil {
+					return cfg.OnTrace(txTask.BlockNum, txTask.TxIndex, txTask.Tx.Hash(), result)
+				}
+			}
+			return nil
+		},
+	}
+	if err = exec3.CustomTraceMapReduce(cfg.From, cfg.To, consumer, ctx, ttx, execArgs, logger); err != nil && !errors.Is(err, errDiverged) {
+		return nil, err
+	}
+	return divergence, nil
+}
+
+// compare - first divergence of receipts produced by re-execution with stored receipts (if any) and header
+func compare(header *types.Header, txs types.Transactions, stored, produced types.Receipts, byzantium bool) *Divergence {
+	blockDivergence := func(field string, expected, got any) *Divergence {
+		return &Divergence{BlockNum: header.Number.Uint64(), BlockHash: header.Hash(), TxIndex: -1,
+			Field: field, Expected: fmt.Sprint(expected), Got: fmt.Sprint(got)}
+	}
+	if stored != nil {
+		if len(stored) != len(produced) {
+			return blockDivergence("receipts count", len(stored), len(produced))
+		}
+		var prevCumulative uint64
+		for i, expected := range stored {
+			got := produced[i]
+			txDivergence := func(field string, expected, got any) *Divergence {
+				d := blockDivergence(field, expected, got)
+				d.TxIndex, d.TxHash = i, txs[i].Hash()
+				return d
+			}
+			if len(expected.PostState) == 0 && expected.Status != got.Status { // pre-byzantium receipts have root instead of status
+				return txDivergence("status", expected.Status, got.Status)
+			}
+			if expectedGas := expected.CumulativeGasUsed - prevCumulative; expectedGas != got.GasUsed {
+				return txDivergence("gas used", expectedGas, got.GasUsed)
+			}
+			if expected.CumulativeGasUsed != got.CumulativeGasUsed {
+				return txDivergence("cumulative gas used", expected.CumulativeGasUsed, got.CumulativeGasUsed)
+			}
+			if len(expected.Logs) != len(got.Logs) {
+				return txDivergence("logs count", len(expected.Logs), len(got.Logs))
+			}
+			for j, l := range expected.Logs {
+				if field, e, g := compareLog(l, got.Logs[j]); field != "" {
+					return txDivergence(fmt.Sprintf("log %d %s", j, field), e, g)
+				}
+			}
+			prevCumulative = expected.CumulativeGasUsed
+		}
+	}
+	var gasUsed uint64
+	if len(produced) > 0 {
+		gasUsed = produced[len(produced)-1].CumulativeGasUsed
+	}
+	if gasUsed != header.GasUsed {
+		return blockDivergence("gas used", header.GasUsed, gasUsed)
+	}
+	// receipts of pre-byzantium blocks have intermediate state roots, re-execution doesn't calculate them
+	if byzantium {
+		if root := types.DeriveSha(produced); root != header.ReceiptHash {
+			return blockDivergence("receipts root", header.ReceiptHash, root)
+		}
+	}
+	if bloom := types.CreateBloom(produced); bloom != header.Bloom {
+		return blockDivergence("logs bloom", fmt.Sprintf("%x", header.Bloom), fmt.Sprintf("%x", bloom))
+	}
+	return nil
+}
+
+func compareLog(expected, got *types.Log) (field string, e, g any) {
+	if expected.Address != got.Address {
+		return "address", expected.Address, got.Address
+	}
+	if len(expected.Topics) != len(got.Topics) {
+		return "topics count", len(expected.Topics), len(got.Topics)
+	}
+	for i, topic := range expected.Topics {
+		if topic != got.Topics[i] {
+			return fmt.Sprintf("topic %d", i), topic, got.Topics[i]
+		}
+	}
+	if string(expected.Data) != string(got.Data) {
+		return "data", fmt.Sprintf("%x", expected.Data), fmt.Sprintf("%x", got.Data)
+	}
+	return "", nil, nil
+}
+
+// stateDiff - prestateTracer's diff of transaction txIndex, or of all transactions of the block if txIndex is -1.
+// Transactions are executed once more for it, only when divergence is found.
+func stateDiff(tx kv.TemporalTx, final *state.TxTask, txIndex int, execArgs *exec3.ExecArgs) (json.RawMessage, error) {
+	diffMode := json.RawMessage(`{"diffMode":true}`)
+	// tx nums of block: initialisation, transactions, finalisation
+	firstTxNum := final.TxNum - uint64(len(final.Txs))
+	if txIndex >= 0 {
+		return traceTx(tx, final.Header, firstTxNum+uint64(txIndex), txIndex, final.Txs[txIndex], "prestateTracer", diffMode, execArgs)
+	}
+	type txDiff struct {
+		TxHash common.Hash     `json:"txHash"`
+		Diff   json.RawMessage `json:"diff"`
+	}
+	diffs := make([]txDiff, len(final.Txs))
+	for i, txn := range final.Txs {
+		diff, err := traceTx(tx, final.Header, firstTxNum+uint64(i), i, txn, "prestateTracer", diffMode, execArgs)
+		if err != nil {
+			return nil, err
+		}
+		diffs[i] = txDiff{TxHash: txn.Hash(), Diff: diff}
+	}
+	return json.Marshal(diffs)
+}
+
+type tracedTx struct {
+	tracer tracers.Tracer
+	err    error
+}
+
+// tracedTxs - tracers attached to transactions by exec3 workers, until their results are reduced
+type tracedTxs struct {
+	lock    sync.Mutex
+	byTxNum map[uint64]tracedTx
+}
+
+// add - new tracer for transaction of txTask, it replaces the one of previous attempt of execution
+func (t *tracedTxs) add(txTask *state.TxTask, name string, config json.RawMessage) exec3.GenericTracer {
+	tracer, err := tracers.New(name, &tracers.Context{BlockHash: txTask.BlockHash, TxIndex: txTask.TxIndex, TxHash: txTask.Tx.Hash()}, config)
+	t.lock.Lock()
+	defer t.lock.Unlock()
+	t.byTxNum[txTask.TxNum] = tracedTx{tracer: tracer, err: err}
+	if err != nil {
+		return nil
+	}
+	return genericTracer{tracer}
+}
+
+func (t *tracedTxs) result(txNum uint64) (json.RawMessage, error) {
+	t.lock.Lock()
+	traced, ok := t.byTxNum[txNum]
+	delete(t.byTxNum, txNum)
+	t.lock.Unlock()
+	if !ok {
+		return nil, fmt.Errorf("txNum %d wasn't traced", txNum)
+	}
+	if traced.err != nil {
+		return nil, traced.err
+	}
+	return traced.tracer.GetResult()
+}
+
+// traceTx - result of tracer for transaction executed on state history
+func traceTx(tx kv.TemporalTx, header *types.Header, txNum uint64, txIndex int, txn types.Transaction, name string, config json.RawMessage, execArgs *exec3.ExecArgs) (json.RawMessage, error) {
+	tracer, err := tracers.New(name, &tracers.Context{BlockHash: header.Hash(), TxIndex: txIndex, TxHash: txn.Hash()}, config)
+	if err != nil {
+		return nil, err
+	}
+	worker := exec3.NewTraceWorker(tx, execArgs.ChainConfig, execArgs.Engine, execArgs.BlockReader, genericTracer{tracer})
+	worker.ChangeBlock(header)
+	if _, err = worker.ExecTxn(txNum, txIndex, txn); err != nil {
+		return nil, err
+	}
+	return tracer.GetResult()
+}
+
+// genericTracer - tracers of eth/tracers don't search for transactions
+type genericTracer struct {
+	tracers.Tracer
+}
+
+func (genericTracer) SetTransaction(types.Transaction) {}
+func (genericTracer) Found() bool                      { return false }
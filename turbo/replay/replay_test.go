@@ -0,0 +1,225 @@
+// Copyright 2024 The Erigon Authors
+// This file is part of Erigon.
+//
+// Erigon is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// Erigon is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with Erigon. If not, see <http://www.gnu.org/licenses/>.
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/erigontech/erigon-lib/common"
+	"github.com/erigontech/erigon-lib/common/hexutil"
+	"github.com/erigontech/erigon-lib/kv"
+	"github.com/erigontech/erigon-lib/log/v3"
+
+	"github.com/erigontech/erigon/cmd/state/exec3"
+	"github.com/erigontech/erigon/core"
+	"github.com/erigontech/erigon/core/rawdb"
+	"github.com/erigontech/erigon/core/types"
+	"github.com/erigontech/erigon/turbo/chaingen"
+	"github.com/erigontech/erigon/turbo/jsonrpc/receipts"
+	"github.com/erigontech/erigon/turbo/stages/mock"
+)
+
+const testBlocks = 20
+
+// generate - chain of chaingen with token transfers (logs), blob txs, withdrawals and self-destructs
+func generate(t *testing.T) (*mock.MockSentry, *exec3.ExecArgs) {
+	g, err := chaingen.New(&chaingen.Scenario{
+		Seed:                3,
+		Blocks:              testBlocks,
+		Accounts:            4,
+		Transfers:           2,
+		TokenTransfers:      2,
+		DeployEvery:         5,
+		SelfDestructEvery:   7,
+		BlobTxEvery:         3,
+		BlobsPerTx:          1,
+		WithdrawalsPerBlock: 1,
+	})
+	require.NoError(t, err)
+	m := mock.MockWithGenesisEngine(t, g.Genesis(), g.Engine(), false, true)
+	err = g.Run(context.Background(), m.DB, m.Genesis, func(chain *core.ChainPack) error {
+		return m.InsertChain(chain)
+	}, log.New())
+	require.NoError(t, err)
+	return m, &exec3.ExecArgs{
+		ChainDB:     m.DB,
+		BlockReader: m.BlockReader,
+		Engine:      m.Engine,
+		Dirs:        m.Dirs,
+		ChainConfig: m.ChainConfig,
+		Workers:     2,
+	}
+}
+
+func block(t *testing.T, m *mock.MockSentry, n uint64) *types.Block {
+	var b *types.Block
+	err := m.DB.View(context.Background(), func(tx kv.Tx) error {
+		var err error
+		b, err = m.BlockReader.BlockByNumber(context.Background(), tx, n)
+		return err
+	})
+	require.NoError(t, err)
+	require.NotNil(t, b)
+	return b
+}
+
+func TestReplay(t *testing.T) {
+	m, execArgs := generate(t)
+
+	type trace struct {
+		blockNum uint64
+		txIndex  int
+		txHash   common.Hash
+	}
+	var traces []trace
+	gasUsed := map[uint64]uint64{}
+	divergence, err := Run(context.Background(), Config{From: 1, To: testBlocks, Tracer: "callTracer",
+		OnTrace: func(blockNum uint64, txIndex int, txHash common.Hash, result json.RawMessage) error {
+			var call struct {
+				GasUsed hexutil.Uint64 `json:"gasUsed"`
+			}
+			require.NoError(t, json.Unmarshal(result, &call))
+			gasUsed[blockNum] += uint64(call.GasUsed)
+			traces = append(traces, trace{blockNum, txIndex, txHash})
+			return nil
+		},
+	}, execArgs, log.New())
+	require.NoError(t, err)
+	require.Nil(t, divergence)
+
+	var expected []trace
+	for n := uint64(1); n <= testBlocks; n++ {
+		b := block(t, m, n)
+		for i, txn := range b.Transactions() {
+			expected = append(expected, trace{n, i, txn.Hash()})
+		}
+		require.Equal(t, b.GasUsed(), gasUsed[n], "tracer is attached to compared execution of block %d", n)
+	}
+	require.NotEmpty(t, expected)
+	require.Equal(t, expected, traces)
+
+	_, err = Run(context.Background(), Config{From: 1, To: testBlocks, Tracer: "noSuchTracer"}, execArgs, log.New())
+	require.ErrorContains(t, err, "noSuchTracer")
+}
+
+func TestReplayDivergence(t *testing.T) {
+	m, execArgs := generate(t)
+
+	// stored receipts of block which differ from chain by status of second transaction
+	var n uint64
+	var b *types.Block
+	for n = 1; n <= testBlocks; n++ {
+		if b = block(t, m, n); b.Transactions().Len() > 1 {
+			break
+		}
+	}
+	require.Greater(t, b.Transactions().Len(), 1)
+	err := m.DB.Update(context.Background(), func(tx kv.RwTx) error {
+		stored, err := receipts.NewGenerator(1, m.BlockReader, m.Engine).GetReceipts(context.Background(), m.ChainConfig, tx, b)
+		if err != nil {
+			return err
+		}
+		require.Equal(t, types.ReceiptStatusSuccessful, stored[1].Status)
+		stored[1].Status = types.ReceiptStatusFailed
+		return rawdb.WriteReceipts(tx, n, stored)
+	})
+	require.NoError(t, err)
+
+	divergence, err := Run(context.Background(), Config{From: 1, To: testBlocks}, execArgs, log.New())
+	require.NoError(t, err)
+	require.NotNil(t, divergence)
+	require.Equal(t, n, divergence.BlockNum)
+	require.Equal(t, b.Hash(), divergence.BlockHash)
+	require.Equal(t, 1, divergence.TxIndex)
+	require.Equal(t, b.Transactions()[1].Hash(), divergence.TxHash)
+	require.Equal(t, "status", divergence.Field)
+	var diff struct {
+		Pre, Post map[common.Address]json.RawMessage
+	}
+	require.NoError(t, json.Unmarshal(divergence.StateDiff, &diff))
+	sender, err := types.MakeSigner(m.ChainConfig, n, b.Time()).Sender(b.Transactions()[1])
+	require.NoError(t, err)
+	require.Contains(t, diff.Post, sender)
+}
+
+func TestCompare(t *testing.T) {
+	txs := types.Transactions{
+		types.NewTransaction(0, common.Address{1}, nil, 21_000, nil, nil),
+		types.NewTransaction(1, common.Address{2}, nil, 50_000, nil, nil),
+	}
+	receipts := func() types.Receipts {
+		rs := types.Receipts{
+			{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 21_000, GasUsed: 21_000},
+			{Status: types.ReceiptStatusSuccessful, CumulativeGasUsed: 61_000, GasUsed: 40_000,
+				Logs: types.Logs{{Address: common.Address{2}, Topics: []common.Hash{{3}}, Data: []byte{4}}}},
+		}
+		for _, r := range rs {
+			r.Bloom = types.CreateBloom(types.Receipts{r})
+		}
+		return rs
+	}
+	produced := receipts()
+	header := &types.Header{Number: common.Big1, GasUsed: 61_000, ReceiptHash: types.DeriveSha(produced), Bloom: types.CreateBloom(produced)}
+
+	require.Nil(t, compare(header, txs, nil, produced, true))
+	require.Nil(t, compare(header, txs, receipts(), produced, true))
+
+	tests := []struct {
+		name    string
+		tamper  func(header *types.Header, stored types.Receipts) types.Receipts
+		txIndex int
+		field   string
+	}{
+		{"status", func(_ *types.Header, s types.Receipts) types.Receipts {
+			s[0].Status = types.ReceiptStatusFailed
+			return s
+		}, 0, "status"},
+		{"gas", func(_ *types.Header, s types.Receipts) types.Receipts { s[0].CumulativeGasUsed = 22_000; return s }, 0, "gas used"},
+		{"cumulative gas", func(_ *types.Header, s types.Receipts) types.Receipts { s[1].CumulativeGasUsed = 62_000; return s }, 1, "gas used"},
+		{"logs", func(_ *types.Header, s types.Receipts) types.Receipts { s[1].Logs = nil; return s }, 1, "logs count"},
+		{"topic", func(_ *types.Header, s types.Receipts) types.Receipts {
+			s[1].Logs[0].Topics[0] = common.Hash{5}
+			return s
+		}, 1, "log 0 topic 0"},
+		{"data", func(_ *types.Header, s types.Receipts) types.Receipts { s[1].Logs[0].Data = nil; return s }, 1, "log 0 data"},
+		{"count", func(_ *types.Header, s types.Receipts) types.Receipts { return s[:1] }, -1, "receipts count"},
+		{"header gas", func(h *types.Header, s types.Receipts) types.Receipts { h.GasUsed = 1; return s }, -1, "gas used"},
+		{"receipts root", func(h *types.Header, s types.Receipts) types.Receipts { h.ReceiptHash = common.Hash{1}; return s }, -1, "receipts root"},
+		{"bloom", func(h *types.Header, s types.Receipts) types.Receipts { h.Bloom = types.Bloom{}; return s }, -1, "logs bloom"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := types.CopyHeader(header)
+			d := compare(h, txs, tt.tamper(h, receipts()), produced, true)
+			require.NotNil(t, d)
+			require.Equal(t, tt.field, d.Field)
+			require.Equal(t, tt.txIndex, d.TxIndex)
+			if tt.txIndex >= 0 {
+				require.Equal(t, txs[tt.txIndex].Hash(), d.TxHash)
+			}
+		})
+	}
+
+	// receipts root isn't checked before byzantium
+	h := types.CopyHeader(header)
+	h.ReceiptHash = common.Hash{1}
+	require.Nil(t, compare(h, txs, nil, produced, false))
+}
@@ -129,6 +129,9 @@ func CreateStateReader(ctx context.Context, tx kv.Tx, blockNrOrHash rpc.BlockNum
 	if err != nil {
 		return nil, err
 	}
+	if txIndex, ok := blockNrOrHash.TxIndex(); ok {
+		return CreateStateReaderBeforeTx(tx, blockNumber, txIndex, chainName)
+	}
 	return CreateStateReaderFromBlockNumber(ctx, tx, blockNumber, latest, txnIndex, stateCache, chainName)
 }
 
@@ -155,6 +158,27 @@ func CreateHistoryStateReader(tx kv.Tx, blockNumber uint64, txnIndex int, chainN
 	return r, nil
 }
 
+// CreateStateReaderBeforeTx - state right before execution of transaction `txIndex` of block `blockNumber`.
+// History is indexed by txNum - so no re-execution of block needed.
+func CreateStateReaderBeforeTx(tx kv.Tx, blockNumber, txIndex uint64, chainName string) (state.StateReader, error) {
+	minTxNum, err := rawdbv3.TxNums.Min(tx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	maxTxNum, err := rawdbv3.TxNums.Max(tx, blockNumber)
+	if err != nil {
+		return nil, err
+	}
+	if maxTxNum <= minTxNum {
+		return nil, fmt.Errorf("state of block %d is not available", blockNumber)
+	}
+	// first and last txNums of block belong to system transactions
+	if txAmount := maxTxNum - minTxNum - 1; txIndex > txAmount {
+		return nil, fmt.Errorf("transaction index %d out of range: block %d has %d transactions", txIndex, blockNumber, txAmount)
+	}
+	return CreateHistoryStateReader(tx, blockNumber, int(txIndex), chainName)
+}
+
 func NewLatestStateReader(tx kv.Tx) state.StateReader {
 	return state.NewReaderV4(tx.(kv.TemporalGetter))
 }
@@ -263,7 +263,127 @@ func getMaxStepRangeInSnapshots(preverified snapcfg.Preverified) (uint64, error)
 
 func computeBlocksToPrune(blockReader services.FullBlockReader, p prune.Mode) (blocksToPrune uint64, historyToPrune uint64) {
 	frozenBlocks := blockReader.Snapshots().SegmentsMax()
-	return p.Blocks.PruneTo(frozenBlocks), p.History.PruneTo(frozenBlocks)
+	// download history files needed by least pruned part of history, files of other parts will be removed by PruneHistoryFiles
+	return p.Blocks.PruneTo(frozenBlocks), p.HistoryPruneTo(frozenBlocks)
+}
+
+// PruneHistoryFiles - removes history and inverted index files which are older than retention of their part of history.
+// Retention is counted from block `head`. Returns removed files (relative to snapshots dir).
+func PruneHistoryFiles(tx kv.Tx, agg *state.Aggregator, p prune.Mode, head uint64) ([]string, error) {
+	if !p.HistoryEnabled() {
+		return nil, nil
+	}
+	txNumOf := func(amount prune.BlockAmount) (uint64, error) {
+		if !amount.Enabled() {
+			return 0, nil
+		}
+		pruneTo := amount.PruneTo(head)
+		if pruneTo == 0 {
+			return 0, nil
+		}
+		return rawdbv3.TxNums.Min(tx, pruneTo)
+	}
+
+	retention := state.FilesRetention{Histories: map[kv.Domain]uint64{}, Indices: map[kv.InvertedIdxPos]uint64{}}
+	for _, part := range prune.HistoryParts {
+		txNum, err := txNumOf(p.Retention(part))
+		if err != nil {
+			return nil, err
+		}
+		switch part {
+		case prune.LogsHistory:
+			retention.Indices[kv.LogAddrIdxPos], retention.Indices[kv.LogTopicIdxPos] = txNum, txNum
+		case prune.CallTracesHistory:
+			retention.Indices[kv.TracesFromIdxPos], retention.Indices[kv.TracesToIdxPos] = txNum, txNum
+		case prune.StateHistory:
+			retention.Histories[kv.AccountsDomain], retention.Histories[kv.StorageDomain] = txNum, txNum
+		case prune.CodeHistory:
+			retention.Histories[kv.CodeDomain] = txNum
+		}
+	}
+	txNum, err := txNumOf(p.History)
+	if err != nil {
+		return nil, err
+	}
+	for _, cfg := range kv.UserInvertedIdxs() {
+		retention.Indices[cfg.Pos] = txNum
+	}
+	return agg.PruneFiles(retention)
+}
+
+// UpdateGrowPending - moves start of state and code history which is not downloaded yet after migration
+// to another prune mode (see prune.GrowPendingFrom) to the first block covered by history files.
+func UpdateGrowPending(tx kv.RwTx, agg *state.Aggregator) error {
+	pendingFrom, ok, err := prune.GrowPendingFrom(tx)
+	if err != nil || !ok {
+		return err
+	}
+	ac := agg.BeginFilesRo()
+	defer ac.Close()
+	var startTxNum uint64
+	for _, d := range []kv.Domain{kv.AccountsDomain, kv.StorageDomain, kv.CodeDomain} {
+		txNum, ok := ac.HistoryFilesStartTxNum(d)
+		if !ok {
+			return nil
+		}
+		startTxNum = max(startTxNum, txNum)
+	}
+	if startTxNum == 0 {
+		return prune.ClearGrowPending(tx)
+	}
+	ok, blockNum, err := rawdbv3.TxNums.FindBlockNum(tx, startTxNum)
+	if err != nil || !ok {
+		return err
+	}
+	// files may start in the middle of block
+	if availableFrom := blockNum + 1; availableFrom < pendingFrom {
+		return prune.SetGrowPendingFrom(tx, availableFrom)
+	}
+	return nil
+}
+
+// PruneBlockFiles - removes transactions files of blocks which are older than retention of prune mode.
+// `onDelete` is called before removing of each file. Returns removed files.
+func PruneBlockFiles(blockReader services.FullBlockReader, snapDir string, p prune.Mode, executionProgress uint64, onDelete func(file string) error) (deleted []string, err error) {
+	headNumber := blockReader.FrozenBlocks()
+	// If we are behind the execution stage, we should not prune snapshots
+	if headNumber == 0 || headNumber > executionProgress || !p.Blocks.Enabled() {
+		return nil, nil
+	}
+
+	// Keep at least 2 block snapshots as we do not want FrozenBlocks to be 0
+	pruneTo := p.Blocks.PruneTo(headNumber)
+	if pruneTo > executionProgress {
+		return nil, nil
+	}
+
+	for _, file := range blockReader.FrozenFiles() {
+		if !strings.Contains(file, "transactions") {
+			continue
+		}
+
+		// take the snapshot file name and parse it to get the "from"
+		info, _, ok := snaptype.ParseFileName(snapDir, file)
+		if !ok {
+			continue
+		}
+		if info.To >= pruneTo {
+			continue
+		}
+		if info.To-info.From != snaptype.Erigon2MergeLimit {
+			continue
+		}
+		if onDelete != nil {
+			if err := onDelete(file); err != nil {
+				return deleted, err
+			}
+		}
+		if err := blockReader.Snapshots().Delete(file); err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, file)
+	}
+	return deleted, nil
 }
 
 // WaitForDownloader - wait for Downloader service to download all expected snapshots
@@ -297,7 +417,7 @@ func WaitForDownloader(ctx context.Context, logPrefix string, dirs datadir.Dirs,
 
 	blockPrune, historyPrune := computeBlocksToPrune(blockReader, prune)
 	blackListForPruning := make(map[string]struct{})
-	wantToPrune := prune.Blocks.Enabled() || prune.History.Enabled()
+	wantToPrune := prune.Blocks.Enabled() || prune.HistoryEnabled()
 	if !headerchain && wantToPrune {
 		minStep, err := getMaxStepRangeInSnapshots(preverifiedBlockSnapshots)
 		if err != nil {
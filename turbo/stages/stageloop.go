@@ -315,8 +315,19 @@ type Hook struct {
 	blockReader   services.FullBlockReader
 	updateHead    func(ctx context.Context)
 	db            kv.RoDB
+
+	unwindRequests UnwindRequests
+}
+
+// UnwindRequests - source of unwinds requested outside of staged sync (for example by dev chain RPC).
+// Requested unwind is applied before next sync cycle, the block after unwind point is marked as bad - so chain with
+// lower total difficulty can become canonical.
+type UnwindRequests interface {
+	NextUnwind() (unwindPoint uint64, badBlock libcommon.Hash, ok bool)
 }
 
+func (h *Hook) SetUnwindRequests(unwindRequests UnwindRequests) { h.unwindRequests = unwindRequests }
+
 func NewHook(ctx context.Context, db kv.RoDB, notifications *shards.Notifications, sync *stagedsync.Sync, blockReader services.FullBlockReader, chainConfig *chain.Config, logger log.Logger, updateHead func(ctx context.Context)) *Hook {
 	return &Hook{ctx: ctx, db: db, notifications: notifications, sync: sync, blockReader: blockReader, chainConfig: chainConfig, logger: logger, updateHead: updateHead}
 }
@@ -329,6 +340,12 @@ func (h *Hook) beforeRun(tx kv.Tx, inSync bool) error {
 		}
 		notifications.Accumulator.Reset(stateVersion)
 	}
+	if h.unwindRequests != nil {
+		if unwindPoint, badBlock, ok := h.unwindRequests.NextUnwind(); ok {
+			h.logger.Info("Unwind requested", "to", unwindPoint, "badBlock", badBlock)
+			return h.sync.UnwindTo(unwindPoint, stagedsync.BadBlock(badBlock, errors.New("requested unwind")), tx)
+		}
+	}
 	return nil
 }
 func (h *Hook) BeforeRun(tx kv.Tx, inSync bool) error {